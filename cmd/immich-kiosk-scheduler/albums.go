@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/immich"
+)
+
+var albumsCmd = &cobra.Command{
+	Use:   "albums",
+	Short: "Show or refresh the album names cached by a running server",
+	Long: `Show or refresh the Immich album names cached by a running server, which
+the UI and status API show instead of album IDs. The server refreshes them
+every immich.sync_interval; sync refreshes them now.
+
+Syncing requires an API token, taken from --token or the IKS_API_TOKEN
+environment variable.`,
+}
+
+var albumsSyncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Refresh the album names from Immich now",
+	Args:  cobra.NoArgs,
+	RunE:  runAlbumsSync,
+}
+
+var albumsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the cached albums",
+	Args:  cobra.NoArgs,
+	RunE:  runAlbumsList,
+}
+
+// albumsStatus mirrors the server's albums response.
+type albumsStatus struct {
+	Albums []immich.Album     `json:"albums"`
+	Status immich.CacheStatus `json:"status"`
+}
+
+func init() {
+	albumsCmd.PersistentFlags().String("server", "http://localhost:8080", "server URL")
+	albumsCmd.PersistentFlags().String("token", "", "API token (default: $IKS_API_TOKEN)")
+
+	albumsCmd.AddCommand(albumsSyncCmd)
+	albumsCmd.AddCommand(albumsListCmd)
+}
+
+func runAlbumsSync(cmd *cobra.Command, args []string) error {
+	var status albumsStatus
+	if err := apiRequest(cmd, http.MethodPost, "/api/v1/albums/sync", nil, &status); err != nil {
+		return err
+	}
+	fmt.Printf("Synced %d albums\n", len(status.Albums))
+	return nil
+}
+
+func runAlbumsList(cmd *cobra.Command, args []string) error {
+	var status albumsStatus
+	if err := apiRequest(cmd, http.MethodGet, "/api/v1/albums", nil, &status); err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tASSETS")
+	for _, a := range status.Albums {
+		fmt.Fprintf(w, "%s\t%s\t%d\n", a.ID, a.AlbumName, a.AssetCount)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if status.Status.LastSync != nil {
+		fmt.Printf("\nLast synced %s\n", status.Status.LastSync.Local().Format(time.DateTime))
+	}
+	if status.Status.LastError != "" {
+		fmt.Printf("Last sync failed: %s\n", status.Status.LastError)
+	}
+	return nil
+}
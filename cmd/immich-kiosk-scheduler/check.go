@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/immich"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/lint"
+)
+
+// Exit codes of the check command.
+const (
+	checkExitErrors   = 1
+	checkExitWarnings = 2
+)
+
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Lint the configuration file",
+	Long: `Lint the configuration file with extended rules: duplicate names,
+duplicate album IDs, reversed ranges, entries shadowed entirely by earlier
+entries and an unreachable default album. Overlaps and gaps are reported
+for information. With immich.url configured, referenced albums must also
+exist in Immich.
+
+Exit codes: 0 = clean (info only), 1 = errors, 2 = warnings but no errors.`,
+	RunE: runCheck,
+}
+
+func init() {
+	addOutputFlag(checkCmd, "text", "json")
+}
+
+func runCheck(cmd *cobra.Command, args []string) error {
+	output, _ := cmd.Flags().GetString("output")
+	if output != "text" && output != "json" {
+		return fmt.Errorf("unknown output format %q", output)
+	}
+
+	if cfgFile == "" {
+		cfgFile = "config.yaml"
+	}
+
+	var findings []lint.Finding
+	cfg, err := config.Load(cfgFile)
+	if err != nil {
+		findings = []lint.Finding{{Rule: lint.RuleInvalidConfig, Severity: lint.SeverityError, Message: err.Error()}}
+	} else {
+		findings = lint.Check(cfg)
+		if cfg.Immich.URL != "" && lint.Count(findings, lint.SeverityError) == 0 {
+			findings = append(findings, checkImmichAlbums(cmd.Context(), cfg)...)
+		}
+	}
+
+	if output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(findings); err != nil {
+			return err
+		}
+	} else {
+		printFindings(findings)
+	}
+
+	cmd.SilenceErrors = true
+	cmd.SilenceUsage = true
+	switch {
+	case lint.Count(findings, lint.SeverityError) > 0:
+		return &exitError{code: checkExitErrors}
+	case lint.Count(findings, lint.SeverityWarning) > 0:
+		return &exitError{code: checkExitWarnings}
+	}
+	return nil
+}
+
+// checkImmichAlbums reports referenced albums missing from Immich. An
+// unreachable Immich is a warning, so the check still works offline.
+func checkImmichAlbums(ctx context.Context, cfg *config.Config) []lint.Finding {
+	albums, err := immich.New(cfg.Immich).Albums(ctx)
+	if err != nil {
+		return []lint.Finding{{Rule: lint.RuleImmich, Severity: lint.SeverityWarning, Message: "albums not checked: " + err.Error()}}
+	}
+	known := make(map[string]bool, len(albums))
+	for _, a := range albums {
+		known[a.ID] = true
+	}
+	return lint.UnknownAlbums(cfg, known)
+}
+
+func printFindings(findings []lint.Finding) {
+	for _, f := range findings {
+		fmt.Printf("%-8s %-20s %s\n", f.Severity, f.Rule, f.Message)
+	}
+	if len(findings) > 0 {
+		fmt.Println()
+	}
+
+	fmt.Printf("%s: %d errors, %d warnings, %d info\n", cfgFile,
+		lint.Count(findings, lint.SeverityError),
+		lint.Count(findings, lint.SeverityWarning),
+		lint.Count(findings, lint.SeverityInfo),
+	)
+}
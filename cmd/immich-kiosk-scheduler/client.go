@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/pkg/client"
+)
+
+// apiRequestTimeout bounds requests to the running server.
+const apiRequestTimeout = 10 * time.Second
+
+// addServerFlags adds the --server and --token flags of commands that call
+// a running server. With an empty default server, commands work on the
+// local config file unless --server is given.
+func addServerFlags(cmd *cobra.Command, defaultServer string) {
+	cmd.PersistentFlags().String("server", defaultServer, "server URL")
+	cmd.PersistentFlags().String("token", "", "API token (default: $IKS_API_TOKEN)")
+}
+
+// apiToken returns the API token from --token or IKS_API_TOKEN.
+func apiToken(cmd *cobra.Command) string {
+	if token, _ := cmd.Flags().GetString("token"); token != "" {
+		return token
+	}
+	return os.Getenv("IKS_API_TOKEN")
+}
+
+// apiClient returns a client for the server given by --server, or nil when
+// it is empty.
+func apiClient(cmd *cobra.Command) *client.Client {
+	server, _ := cmd.Flags().GetString("server")
+	if server == "" {
+		return nil
+	}
+	c := client.New(server, apiToken(cmd))
+	c.HTTPClient = &http.Client{Timeout: apiRequestTimeout}
+	return c
+}
+
+// apiRequest calls the server API, decoding the JSON response into out
+// when it is not nil.
+func apiRequest(cmd *cobra.Command, method, path string, body, out any) error {
+	server, _ := cmd.Flags().GetString("server")
+	token := apiToken(cmd)
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(cmd.Context(), method, strings.TrimRight(server, "/")+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	client := &http.Client{Timeout: apiRequestTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&apiErr) == nil && apiErr.Error != "" {
+			return fmt.Errorf("%s: %s", resp.Status, apiErr.Error)
+		}
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
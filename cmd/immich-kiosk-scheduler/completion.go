@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+)
+
+var completionCmd = &cobra.Command{
+	Use:   "completion [bash|zsh|fish|powershell]",
+	Short: "Generate the shell completion script",
+	Long: `Generate the completion script for a shell. Besides commands and flags,
+it completes profile and party mode names from the config file.
+
+  # bash (requires the bash-completion package)
+  immich-kiosk-scheduler completion bash > /etc/bash_completion.d/immich-kiosk-scheduler
+
+  # zsh
+  immich-kiosk-scheduler completion zsh > "${fpath[1]}/_immich-kiosk-scheduler"
+
+  # fish
+  immich-kiosk-scheduler completion fish > ~/.config/fish/completions/immich-kiosk-scheduler.fish
+
+  # PowerShell
+  immich-kiosk-scheduler completion powershell | Out-String | Invoke-Expression`,
+	ValidArgs:             []string{"bash", "zsh", "fish", "powershell"},
+	Args:                  cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+	DisableFlagsInUseLine: true,
+	RunE:                  runCompletion,
+}
+
+func init() {
+	rootCmd.AddCommand(completionCmd)
+}
+
+func runCompletion(cmd *cobra.Command, args []string) error {
+	switch args[0] {
+	case "bash":
+		return rootCmd.GenBashCompletionV2(os.Stdout, true)
+	case "zsh":
+		return rootCmd.GenZshCompletion(os.Stdout)
+	case "fish":
+		return rootCmd.GenFishCompletion(os.Stdout, true)
+	default:
+		return rootCmd.GenPowerShellCompletionWithDesc(os.Stdout)
+	}
+}
+
+// addOutputFlag adds the --output flag choosing one of formats, the first
+// being the default.
+func addOutputFlag(cmd *cobra.Command, formats ...string) {
+	cmd.Flags().StringP("output", "o", formats[0], fmt.Sprintf("output format (%s)", strings.Join(formats, ", ")))
+	_ = cmd.RegisterFlagCompletionFunc("output", cobra.FixedCompletions(formats, cobra.ShellCompDirectiveNoFileComp))
+}
+
+// addProfileFlag adds the --profile flag, completed with the profiles of
+// the config file.
+func addProfileFlag(cmd *cobra.Command, usage string) {
+	cmd.Flags().String("profile", "", usage)
+	_ = cmd.RegisterFlagCompletionFunc("profile", completeConfigNames((*config.Config).ProfileNames))
+}
+
+// completeConfigNames completes a single argument with names read from the
+// config file. Completion fails quietly when the file cannot be loaded.
+func completeConfigNames(names func(*config.Config) []string) cobra.CompletionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		path := cfgFile
+		if path == "" {
+			path = "config.yaml"
+		}
+		cfg, err := config.Load(path)
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return names(cfg), cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// overrideAlbumNames returns the control albums and album aliases.
+func overrideAlbumNames(cfg *config.Config) []string {
+	names := make([]string, 0, len(cfg.Control.Albums)+len(cfg.Albums))
+	for _, a := range cfg.Control.Albums {
+		names = append(names, a.Name)
+	}
+	for alias := range cfg.Albums {
+		names = append(names, alias)
+	}
+	return names
+}
+
+// partyModeNames returns the names of the configured party modes.
+func partyModeNames(cfg *config.Config) []string {
+	names := make([]string, len(cfg.PartyModes))
+	for i, mode := range cfg.PartyModes {
+		names[i] = mode.Name
+	}
+	return names
+}
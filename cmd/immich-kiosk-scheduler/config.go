@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"go.yaml.in/yaml/v3"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the configuration",
+}
+
+var configPrintCmd = &cobra.Command{
+	Use:   "print",
+	Short: "Print the effective configuration",
+	Long: `Print the configuration the server would use: the config file merged with
+environment variables and command line overrides, on top of the built-in
+defaults. Secrets such as passwords, tokens and request headers are redacted.`,
+	RunE: runConfigPrint,
+}
+
+var configPrintDefaultCmd = &cobra.Command{
+	Use:   "print-default",
+	Short: "Print the built-in defaults",
+	RunE:  runConfigPrintDefault,
+}
+
+var configEncryptCmd = &cobra.Command{
+	Use:   "encrypt [value]",
+	Short: "Encrypt a secret for the config file",
+	Long: `Encrypt a value with age for use in the config file, e.g. as
+metrics_password. The value is read from standard input when not given, so
+it does not end up in the shell history. Recipients are age X25519 public
+keys (age1...).
+
+  echo -n "s3cret" | immich-kiosk-scheduler config encrypt -r age1...
+
+The server decrypts ENC[age:...] values at load time with the key in
+secrets.age_key_file or IKS_SECRETS_AGE_KEY.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runConfigEncrypt,
+}
+
+func init() {
+	configEncryptCmd.Flags().StringArrayP("recipient", "r", nil, "age recipient to encrypt to; repeatable (default: $IKS_SECRETS_AGE_RECIPIENT)")
+	configCmd.AddCommand(configEncryptCmd)
+
+	for _, cmd := range []*cobra.Command{configPrintCmd, configPrintDefaultCmd} {
+		addOutputFlag(cmd, "yaml", "json")
+		configCmd.AddCommand(cmd)
+	}
+	rootCmd.AddCommand(configCmd)
+}
+
+func runConfigPrint(cmd *cobra.Command, args []string) error {
+	if cfgFile == "" {
+		cfgFile = "config.yaml"
+	}
+
+	cfg, err := loadServeConfig(cfgFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	return printSettings(cmd, os.Stdout, cfg.RedactedSettings())
+}
+
+func runConfigPrintDefault(cmd *cobra.Command, args []string) error {
+	settings, err := config.DefaultSettings()
+	if err != nil {
+		return err
+	}
+	return printSettings(cmd, os.Stdout, settings)
+}
+
+func runConfigEncrypt(cmd *cobra.Command, args []string) error {
+	recipients, _ := cmd.Flags().GetStringArray("recipient")
+	if len(recipients) == 0 {
+		if r := os.Getenv("IKS_SECRETS_AGE_RECIPIENT"); r != "" {
+			recipients = []string{r}
+		}
+	}
+
+	var value string
+	if len(args) > 0 {
+		value = args[0]
+	} else {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		value = strings.TrimSuffix(string(data), "\n")
+	}
+
+	encrypted, err := config.EncryptValue(value, recipients)
+	if err != nil {
+		return err
+	}
+	fmt.Println(encrypted)
+	return nil
+}
+
+// printSettings writes settings in the format chosen by the --output flag.
+func printSettings(cmd *cobra.Command, w io.Writer, settings map[string]any) error {
+	output, _ := cmd.Flags().GetString("output")
+	switch output {
+	case "yaml":
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(settings); err != nil {
+			return err
+		}
+		return enc.Close()
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(settings)
+	default:
+		return fmt.Errorf("unknown output format %q", output)
+	}
+}
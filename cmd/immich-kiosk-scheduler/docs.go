@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var docsCmd = &cobra.Command{
+	Use:   "docs",
+	Short: "Generate documentation",
+}
+
+var docsManCmd = &cobra.Command{
+	Use:   "man",
+	Short: "Generate man pages",
+	Long: `Generate a man page for every command in --dir, named after the command
+path, e.g. immich-kiosk-scheduler-schedule-coverage.1.
+
+  immich-kiosk-scheduler docs man --dir /usr/local/share/man/man1`,
+	Args: cobra.NoArgs,
+	RunE: runDocsMan,
+}
+
+func init() {
+	docsManCmd.Flags().String("dir", ".", "directory to write the man pages to")
+	_ = docsManCmd.MarkFlagDirname("dir")
+	docsCmd.AddCommand(docsManCmd)
+	rootCmd.AddCommand(docsCmd)
+}
+
+func runDocsMan(cmd *cobra.Command, args []string) error {
+	dir, _ := cmd.Flags().GetString("dir")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	date := time.Now()
+	if built, err := time.Parse(time.RFC3339, buildDate); err == nil {
+		date = built
+	}
+
+	n := 0
+	err := walkCommands(rootCmd, func(c *cobra.Command) error {
+		path := filepath.Join(dir, manPageName(c)+".1")
+		n++
+		return os.WriteFile(path, manPage(c, date), 0o644)
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %d man pages to %s\n", n, dir)
+	return nil
+}
+
+// walkCommands calls fn for c and its documented subcommands.
+func walkCommands(c *cobra.Command, fn func(*cobra.Command) error) error {
+	if err := fn(c); err != nil {
+		return err
+	}
+	for _, sub := range c.Commands() {
+		if !sub.IsAvailableCommand() || sub.IsAdditionalHelpTopicCommand() {
+			continue
+		}
+		if err := walkCommands(sub, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// manPageName names the man page of a command after its command path.
+func manPageName(c *cobra.Command) string {
+	return strings.ReplaceAll(c.CommandPath(), " ", "-")
+}
+
+// manPage renders the man page of a command in roff.
+func manPage(c *cobra.Command, date time.Time) []byte {
+	var b bytes.Buffer
+	name := manPageName(c)
+
+	fmt.Fprintf(&b, ".TH %q 1 %q %q \"User Commands\"\n", strings.ToUpper(name), date.Format("Jan 2006"), "immich-kiosk-scheduler "+version)
+	fmt.Fprintf(&b, ".SH NAME\n%s \\- %s\n", roffEscape(name), roffEscape(c.Short))
+	fmt.Fprintf(&b, ".SH SYNOPSIS\n.B %s\n", roffEscape(c.UseLine()))
+
+	description := c.Long
+	if description == "" {
+		description = c.Short
+	}
+	fmt.Fprintf(&b, ".SH DESCRIPTION\n.nf\n%s\n.fi\n", roffEscape(description))
+
+	writeManFlags(&b, "OPTIONS", c.NonInheritedFlags())
+	writeManFlags(&b, "OPTIONS INHERITED FROM PARENT COMMANDS", c.InheritedFlags())
+
+	var related []string
+	if c.HasParent() {
+		related = append(related, manPageName(c.Parent()))
+	}
+	for _, sub := range c.Commands() {
+		if sub.IsAvailableCommand() && !sub.IsAdditionalHelpTopicCommand() {
+			related = append(related, manPageName(sub))
+		}
+	}
+	if len(related) > 0 {
+		b.WriteString(".SH SEE ALSO\n")
+		for i, page := range related {
+			sep := ","
+			if i == len(related)-1 {
+				sep = ""
+			}
+			fmt.Fprintf(&b, ".BR %s (1)%s\n", roffEscape(page), sep)
+		}
+	}
+	return b.Bytes()
+}
+
+// writeManFlags writes a section listing flags, if there are any.
+func writeManFlags(b *bytes.Buffer, section string, flags *pflag.FlagSet) {
+	if !flags.HasAvailableFlags() {
+		return
+	}
+	fmt.Fprintf(b, ".SH %s\n", section)
+	flags.VisitAll(func(f *pflag.Flag) {
+		if f.Hidden {
+			return
+		}
+		b.WriteString(".TP\n")
+		if f.Shorthand != "" {
+			fmt.Fprintf(b, "\\fB\\-%s\\fP, ", f.Shorthand)
+		}
+		fmt.Fprintf(b, "\\fB\\-\\-%s\\fP", roffEscape(f.Name))
+		if varname, _ := pflag.UnquoteUsage(f); varname != "" {
+			fmt.Fprintf(b, " \\fI%s\\fP", varname)
+		}
+		b.WriteString("\n")
+		usage := f.Usage
+		if f.DefValue != "" && f.DefValue != "false" && f.DefValue != "0" && f.DefValue != "[]" {
+			usage += fmt.Sprintf(" (default %s)", f.DefValue)
+		}
+		fmt.Fprintf(b, "%s\n", roffEscape(usage))
+	})
+}
+
+// roffEscape escapes text for roff: backslashes, dashes, and lines that
+// would start with a control character.
+func roffEscape(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\e`)
+	s = strings.ReplaceAll(s, "-", `\-`)
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			lines[i] = `\&` + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/rules"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/scheduler"
+)
+
+var explainCmd = &cobra.Command{
+	Use:   "explain",
+	Short: "Explain how the schedule resolves a date",
+	Long: `Walk through every schedule entry in evaluation order for a date and print
+why each one matched or did not: its date range, its recurrence rule and
+each condition of its when, such as the weekday or time of day. The entry
+that wins, or the default album, is printed last.
+
+--date accepts MM-DD (this year), YYYY-MM-DD or YYYY-MM-DDTHH:MM, like the
+test command. Home Assistant and MQTT states are not read, so conditions
+on them see empty values.`,
+	RunE: runExplain,
+}
+
+func init() {
+	explainCmd.Flags().String("date", "", "date to explain (MM-DD, YYYY-MM-DD or YYYY-MM-DDTHH:MM, defaults to now)")
+	explainCmd.Flags().String("device", "", "device name that conditions on device see")
+	addProfileFlag(explainCmd, "profile to explain (default: the configured profile)")
+}
+
+func runExplain(cmd *cobra.Command, args []string) error {
+	setupLogger("info", viper.GetString("log_format"), nil)
+
+	if cfgFile == "" {
+		cfgFile = "config.yaml"
+	}
+
+	cfg, err := config.Load(cfgFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if cfg, err = profileConfig(cmd, cfg); err != nil {
+		return err
+	}
+
+	sched, err := scheduler.New(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create scheduler: %w", err)
+	}
+
+	dateStr, _ := cmd.Flags().GetString("date")
+	at, layout, err := parseTestDate(dateStr, time.Now().In(cfg.Location()))
+	if err != nil {
+		return err
+	}
+	device, _ := cmd.Flags().GetString("device")
+
+	fmt.Printf("Explaining schedule for %s\n", at.Format(layout))
+
+	selected := ""
+	for _, st := range sched.Trace(rules.Env{Time: at, Device: device}) {
+		fmt.Println()
+		fmt.Printf("%d. %s (%s)\n", st.Index+1, st.Entry.Name, st.Entry.AlbumLabel())
+		for _, line := range explainStep(st, at, selected, cfg.EndBoundary) {
+			fmt.Println("   " + line)
+		}
+		if st.Selected {
+			selected = st.Entry.Name
+		}
+	}
+
+	d := sched.Resolve(at)
+	redirect, err := redirectURL(cfg, d.Album)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println()
+	if selected == "" {
+		fmt.Println("No entry matched, the default album is shown.")
+	}
+	fmt.Printf("Schedule:  %s\n", d.Schedule)
+	fmt.Printf("Album ID:  %s\n", d.Album)
+	fmt.Printf("Redirect:  %s\n", redirect)
+	return nil
+}
+
+// explainStep describes the checks of one entry, stopping at the first that
+// fails, and the outcome. selected names the entry selected before it, if
+// any, and endBoundary is the top-level end_boundary.
+func explainStep(st scheduler.Step, at time.Time, selected, endBoundary string) []string {
+	e := st.Entry
+	day := at.Format("01-02")
+	var lines []string
+
+	if e.Start != "" || e.End != "" {
+		dates := e.Start + " to " + e.End
+		if e.EndExclusive(endBoundary) {
+			dates += " (end exclusive)"
+		}
+		if !st.InDates {
+			return append(lines, fmt.Sprintf("date range:  %s does not include %s", dates, day),
+				"result:      skipped")
+		}
+		lines = append(lines, fmt.Sprintf("date range:  %s includes %s", dates, day))
+	}
+	if e.RRule != "" {
+		if !st.Occurs {
+			return append(lines, "recurrence:  no occurrence covers "+day,
+				"result:      skipped")
+		}
+		lines = append(lines, "recurrence:  an occurrence covers "+day)
+	}
+	if e.When != "" {
+		if st.ConditionMet {
+			lines = append(lines, "condition:   "+e.When+" holds")
+		} else {
+			lines = append(lines, "condition:   "+e.When+" does not hold")
+			if len(st.Clauses) > 1 {
+				for _, c := range st.Clauses {
+					if !c.Holds {
+						lines = append(lines, "             "+c.Condition+" is false")
+					}
+				}
+			}
+			return append(lines, "result:      skipped")
+		}
+	}
+
+	if st.Selected {
+		return append(lines, "result:      selected")
+	}
+	return append(lines, "result:      matches, but "+selected+" was selected first")
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/lambda"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/scheduler"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/server"
+)
+
+// serveLambda answers AWS Lambda invocations with the server's routes. The
+// configuration comes from the environment only; reloads, Git sync and the
+// remote store are not available.
+func serveLambda(ctx context.Context, cmd *cobra.Command) error {
+	setupLogger(viper.GetString("log_level"), viper.GetString("log_format"), nil)
+
+	api := os.Getenv(lambda.RuntimeAPIEnv)
+	if api == "" {
+		return fmt.Errorf("--lambda requires %s; run the binary as a Lambda function", lambda.RuntimeAPIEnv)
+	}
+
+	cfg, err := config.LoadEnv()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	applyConfigLogging(cmd, cfg)
+	applyTimezone(cfg)
+
+	sched, err := scheduler.New(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create scheduler: %w", err)
+	}
+	srv, err := server.New(cfg, sched)
+	if err != nil {
+		return fmt.Errorf("failed to create server: %w", err)
+	}
+	srv.SetVersion(version, commit)
+
+	slog.Info("scheduler initialized",
+		slog.Int("schedules", sched.GetScheduleCount()),
+		slog.String("current_schedule", sched.GetCurrentScheduleName()),
+	)
+
+	return lambda.New(api, srv.Handler(), slog.Default()).Run(ctx)
+}
@@ -3,19 +3,35 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"text/tabwriter"
 	"time"
+	// Embed the time zone database, so test --timezone works on hosts and
+	// images without one.
+	_ "time/tzdata"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
 	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/gitsync"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/homeassistant"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/immich"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/logging"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/metrics"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/mqtt"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/remote"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/rules"
 	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/scheduler"
 	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/server"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/telegram"
 )
 
 var (
@@ -25,13 +41,28 @@ var (
 )
 
 var (
-	cfgFile  string
-	port     int
-	logLevel string
+	cfgFile    string
+	port       int
+	logLevel   string
+	logFormat  string
+	lambdaMode bool
 )
 
+// exitError reports a failure that has already been printed, with a specific exit code.
+type exitError struct {
+	code int
+}
+
+func (e *exitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
 func main() {
 	if err := rootCmd.Execute(); err != nil {
+		var exitErr *exitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.code)
+		}
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
@@ -58,7 +89,15 @@ var serveCmd = &cobra.Command{
 var testCmd = &cobra.Command{
 	Use:   "test",
 	Short: "Test the schedule for a specific date",
-	Long: `Test which album would be selected for a specific date.
+	Long: `Test which album would be selected for a specific date and list the
+entries whose range includes it, showing which one won and why the others
+did not.
+
+--date accepts MM-DD (this year), YYYY-MM-DD or YYYY-MM-DDTHH:MM. Dates
+without a time are tested at midnight. Times are in the configured timezone,
+the one the server evaluates the schedule in, or the local time zone when
+none is configured; --timezone tests another.
+
 This is useful for verifying your schedule configuration.`,
 	RunE: runTest,
 }
@@ -69,21 +108,44 @@ func init() {
 	// Global flags
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file path (default: ./config.yaml)")
 	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "log level (debug, info, warn, error)")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "auto", "log format (auto, json, text)")
+	rootCmd.PersistentFlags().Bool("strict-config", false, "reject unknown configuration keys instead of ignoring them")
 
 	// Bind to env vars
 	_ = viper.BindPFlag("config", rootCmd.PersistentFlags().Lookup("config"))
 	_ = viper.BindPFlag("log_level", rootCmd.PersistentFlags().Lookup("log-level"))
+	_ = viper.BindPFlag("log_format", rootCmd.PersistentFlags().Lookup("log-format"))
+	_ = viper.BindPFlag("strict_config", rootCmd.PersistentFlags().Lookup("strict-config"))
+	_ = rootCmd.MarkPersistentFlagFilename("config", "yaml", "yml", "json")
+	_ = rootCmd.RegisterFlagCompletionFunc("log-level", cobra.FixedCompletions([]string{"debug", "info", "warn", "error"}, cobra.ShellCompDirectiveNoFileComp))
+	_ = rootCmd.RegisterFlagCompletionFunc("log-format", cobra.FixedCompletions([]string{"auto", "json", "text"}, cobra.ShellCompDirectiveNoFileComp))
 
 	// Serve command flags
 	serveCmd.Flags().IntVar(&port, "port", 8080, "port to listen on")
 	_ = viper.BindPFlag("port", serveCmd.Flags().Lookup("port"))
+	serveCmd.Flags().BoolVar(&lambdaMode, "lambda", false, "serve AWS Lambda invocations, with the configuration read from the environment")
 
 	// Test command flags
-	testCmd.Flags().String("date", "", "date to test (MM-DD format, defaults to today)")
+	testCmd.Flags().String("date", "", "date to test (MM-DD, YYYY-MM-DD or YYYY-MM-DDTHH:MM, defaults to now)")
+	testCmd.Flags().String("timezone", "", "IANA time zone to test in, e.g. Europe/Berlin (default: the configured timezone)")
+	addProfileFlag(testCmd, "profile to test (default: the configured profile)")
 
 	// Register commands
 	rootCmd.AddCommand(serveCmd)
 	rootCmd.AddCommand(testCmd)
+	rootCmd.AddCommand(explainCmd)
+	rootCmd.AddCommand(validateCmd)
+	rootCmd.AddCommand(checkCmd)
+	rootCmd.AddCommand(scheduleCmd)
+	rootCmd.AddCommand(simulateCmd)
+	rootCmd.AddCommand(partyCmd)
+	rootCmd.AddCommand(profileCmd)
+	rootCmd.AddCommand(statusCmd)
+	rootCmd.AddCommand(nextCmd)
+	rootCmd.AddCommand(overrideCmd)
+	rootCmd.AddCommand(freezeCmd)
+	rootCmd.AddCommand(unfreezeCmd)
+	rootCmd.AddCommand(albumsCmd)
 }
 
 func initConfig() {
@@ -94,29 +156,78 @@ func initConfig() {
 	if cfgFile == "" {
 		cfgFile = viper.GetString("config")
 	}
+	config.SetStrict(viper.GetBool("strict_config"))
 }
 
-func setupLogger(level string) {
-	var logLevel slog.Level
-	switch level {
-	case "debug":
-		logLevel = slog.LevelDebug
-	case "warn":
-		logLevel = slog.LevelWarn
-	case "error":
-		logLevel = slog.LevelError
-	default:
-		logLevel = slog.LevelInfo
-	}
-
-	handler := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-		Level: logLevel,
+// newLogHandler creates the handler for log output. The Windows service
+// replaces it to write to the event log.
+var newLogHandler = func(level, format string) slog.Handler {
+	return logging.NewHandler(os.Stdout, format, &slog.HandlerOptions{
+		Level: logging.ParseLevel(level),
 	})
+}
+
+// setupLogger sets the default logger, masking the redacted fields and the
+// albums in the paths of URLs built from albumURLs.
+func setupLogger(level, format string, redact []string, albumURLs ...string) {
+	handler := newLogHandler(level, format)
+	if len(redact) > 0 {
+		handler = logging.NewRedactHandler(handler, redact, albumURLs...)
+	}
 	slog.SetDefault(slog.New(handler))
 }
 
+// applyConfigLogging re-initializes the logger from the loaded configuration,
+// keeping any level or format given explicitly on the command line.
+func applyConfigLogging(cmd *cobra.Command, cfg *config.Config) {
+	level, format := cfg.LogLevel, cfg.LogFormat
+	if cmd.Flags().Changed("log-level") {
+		level = logLevel
+	}
+	if cmd.Flags().Changed("log-format") {
+		format = logFormat
+	}
+	setupLogger(level, format, cfg.LogRedact, cfg.AlbumPathURLs()...)
+}
+
+// applyTimezone makes the configured timezone the local time zone, which
+// the schedule is evaluated in. It must run before the server starts;
+// changes take effect on restart.
+func applyTimezone(cfg *config.Config) {
+	if cfg.Timezone != "" {
+		time.Local = cfg.Location()
+		slog.Info("using time zone", slog.String("timezone", cfg.Timezone))
+	}
+}
+
 func runServe(cmd *cobra.Command, args []string) error {
-	setupLogger(viper.GetString("log_level"))
+	// Under the Windows service manager the service handler runs the server.
+	if ok, err := runAsService(cmd); ok {
+		return err
+	}
+
+	// Handle graceful shutdown
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		<-sigCh
+		slog.Info("received shutdown signal")
+		cancel()
+	}()
+
+	if lambdaMode {
+		return serveLambda(ctx, cmd)
+	}
+	return serve(ctx, cmd)
+}
+
+// serve runs the server until ctx is canceled.
+func serve(ctx context.Context, cmd *cobra.Command) error {
+	setupLogger(viper.GetString("log_level"), viper.GetString("log_format"), nil)
 
 	if cfgFile == "" {
 		cfgFile = "config.yaml"
@@ -124,15 +235,13 @@ func runServe(cmd *cobra.Command, args []string) error {
 
 	slog.Info("loading configuration", slog.String("file", cfgFile))
 
-	cfg, err := config.Load(cfgFile)
+	cfg, err := loadServeConfig(cfgFile)
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
-	// Override port from CLI/env if set
-	if viper.IsSet("port") {
-		cfg.Port = viper.GetInt("port")
-	}
+	applyConfigLogging(cmd, cfg)
+	applyTimezone(cfg)
 
 	sched, err := scheduler.New(cfg)
 	if err != nil {
@@ -144,30 +253,178 @@ func runServe(cmd *cobra.Command, args []string) error {
 		slog.String("current_schedule", sched.GetCurrentScheduleName()),
 		slog.String("current_album", sched.GetCurrentAlbum()),
 	)
+	for _, w := range sched.Warnings() {
+		slog.Warn("schedule warning", slog.Any("warning", w))
+	}
+	if cfg.Debug.AllowDateOverride {
+		slog.Warn("debug date override is enabled; clients can choose the date used for redirects")
+	}
 
 	srv, err := server.New(cfg, sched)
 	if err != nil {
 		return fmt.Errorf("failed to create server: %w", err)
 	}
+	srv.SetVersion(version, commit)
 
-	// Handle graceful shutdown
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	// Once Git sync or the remote store has supplied a configuration, reloads
+	// read it instead of --config.
+	var syncer *gitsync.Syncer
+	if cfg.GitSync.Enabled {
+		syncer = gitsync.New(cfg.GitSync, slog.Default())
+		srv.SetGitSync(syncer)
+	}
+	var watcher *remote.Watcher
+	if cfg.Remote.Provider != "" {
+		if watcher, err = remote.NewWatcher(cfg.Remote, slog.Default()); err != nil {
+			return fmt.Errorf("failed to create remote config watcher: %w", err)
+		}
+		srv.SetRemote(watcher)
+	}
+	remoteFormat := cfg.Remote.Format
+	reload := func() {
+		if watcher != nil {
+			if value := watcher.Value(); value != nil {
+				_ = srv.Reload(func() (*config.Config, error) { return loadRemoteConfig(value, remoteFormat) })
+				return
+			}
+		}
+		path := cfgFile
+		if syncer != nil && syncer.Synced() {
+			path = syncer.ConfigPath()
+		}
+		_ = srv.Reload(func() (*config.Config, error) { return loadServeConfig(path) })
+	}
+
+	// Reload on SIGHUP, keeping the previous configuration if the new one is invalid
+	hupCh := make(chan os.Signal, 1)
+	signal.Notify(hupCh, syscall.SIGHUP)
 
 	go func() {
-		<-sigCh
-		slog.Info("received shutdown signal")
-		cancel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hupCh:
+				slog.Info("received SIGHUP, reloading configuration")
+				reload()
+			}
+		}
 	}()
 
+	if cfg.WatchConfig {
+		err := config.Watch(ctx, cfgFile, func() {
+			slog.Info("configuration file changed, reloading", slog.String("file", cfgFile))
+			reload()
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if syncer != nil {
+		go syncer.Run(ctx, func(path string) error {
+			return srv.Reload(func() (*config.Config, error) { return loadServeConfig(path) })
+		})
+	}
+
+	if watcher != nil {
+		go watcher.Run(ctx, func(value []byte) error {
+			return srv.Reload(func() (*config.Config, error) { return loadRemoteConfig(value, remoteFormat) })
+		})
+	}
+
+	if cfg.Immich.URL != "" {
+		cache := immich.NewAlbumCache(immich.New(cfg.Immich), cfg.Immich.AlbumSyncInterval(), srv.Store(), slog.Default())
+		srv.SetAlbumCache(cache)
+		go cache.Run(ctx)
+	}
+
+	if cfg.HomeAssistant.URL != "" {
+		ha := homeassistant.New(cfg.HomeAssistant, srv.HomeAssistantEntities, slog.Default())
+		srv.SetHomeAssistant(ha)
+		go ha.Run(ctx)
+	}
+
+	if cfg.MQTT.Broker != "" {
+		client := mqtt.New(cfg.MQTT, srv.MQTTTopics, slog.Default())
+		srv.SetMQTT(client)
+		go client.Run(ctx)
+	}
+
+	if cfg.Telegram.Enabled() {
+		go telegram.New(cfg.Telegram, srv.Command, slog.Default()).Run(ctx)
+	}
+
+	if cfg.Metrics.Backend == "statsd" {
+		exporter, err := metrics.NewStatsDExporter(cfg.Metrics.StatsD, prometheus.DefaultGatherer)
+		if err != nil {
+			return fmt.Errorf("failed to create statsd exporter: %w", err)
+		}
+		// The exporter outlives the server so its final flush includes
+		// the requests drained during shutdown.
+		exportCtx, stopExport := context.WithCancel(context.WithoutCancel(ctx))
+		done := make(chan struct{})
+		go func() {
+			exporter.Run(exportCtx)
+			close(done)
+		}()
+		defer func() {
+			stopExport()
+			<-done
+		}()
+	}
+
 	return srv.StartWithContext(ctx)
 }
 
+// profileConfig returns the configuration with the schedule of the profile
+// named by the --profile flag, or of the configured profile.
+func profileConfig(cmd *cobra.Command, cfg *config.Config) (*config.Config, error) {
+	name, _ := cmd.Flags().GetString("profile")
+	if name == "" {
+		name = cfg.DefaultProfile()
+	}
+	return cfg.WithProfile(name)
+}
+
+// loadServeConfig loads a configuration file and applies command line overrides.
+func loadServeConfig(path string) (*config.Config, error) {
+	cfg, err := config.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	applyServeOverrides(cfg)
+	return cfg, nil
+}
+
+// loadRemoteConfig parses a configuration read from the remote store and
+// applies command line overrides.
+func loadRemoteConfig(value []byte, format string) (*config.Config, error) {
+	cfg, err := config.LoadBytes(value, format)
+	if err != nil {
+		return nil, err
+	}
+	applyServeOverrides(cfg)
+	return cfg, nil
+}
+
+// applyServeOverrides applies command line flags that take precedence over
+// the configuration.
+func applyServeOverrides(cfg *config.Config) {
+	// Override port from CLI/env if set
+	if viper.IsSet("port") {
+		cfg.Port = viper.GetInt("port")
+		if os.Getenv("IKS_PORT") == "" {
+			cfg.SetSource("port", config.SourceFlag)
+		}
+	}
+}
+
 func runTest(cmd *cobra.Command, args []string) error {
-	setupLogger("info")
+	setupLogger("info", viper.GetString("log_format"), nil)
 
 	if cfgFile == "" {
 		cfgFile = "config.yaml"
@@ -177,34 +434,103 @@ func runTest(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
+	if cfg, err = profileConfig(cmd, cfg); err != nil {
+		return err
+	}
 
 	sched, err := scheduler.New(cfg)
 	if err != nil {
 		return fmt.Errorf("failed to create scheduler: %w", err)
 	}
 
+	loc := cfg.Location()
+	if name, _ := cmd.Flags().GetString("timezone"); name != "" {
+		if loc, err = time.LoadLocation(name); err != nil {
+			return fmt.Errorf("invalid timezone %q: %w", name, err)
+		}
+	}
+
 	// Parse date flag
 	dateStr, _ := cmd.Flags().GetString("date")
-	var testDate time.Time
-
+	testDate, layout, err := parseTestDate(dateStr, time.Now().In(loc))
+	if err != nil {
+		return err
+	}
 	if dateStr == "" {
-		testDate = time.Now()
-		fmt.Printf("Testing schedule for today (%s)\n\n", testDate.Format("January 2"))
+		fmt.Printf("Testing schedule for today (%s)\n\n", testDate.Format(layout))
 	} else {
-		month, day, err := scheduler.ParseMonthDay(dateStr)
-		if err != nil {
-			return fmt.Errorf("invalid date format: %w", err)
-		}
-		testDate = time.Date(time.Now().Year(), time.Month(month), day, 0, 0, 0, 0, time.Local)
-		fmt.Printf("Testing schedule for %s\n\n", testDate.Format("January 2"))
+		fmt.Printf("Testing schedule for %s\n\n", testDate.Format(layout))
 	}
 
-	album := sched.GetAlbumForDate(testDate)
-	scheduleName := sched.GetScheduleNameForDate(testDate)
+	d := sched.Resolve(testDate)
+	redirect, err := redirectURL(cfg, d.Album)
+	if err != nil {
+		return err
+	}
 
-	fmt.Printf("Schedule:  %s\n", scheduleName)
-	fmt.Printf("Album ID:  %s\n", album)
-	fmt.Printf("Redirect:  %s?album=%s\n", cfg.KioskURL, album)
+	fmt.Printf("Schedule:  %s\n", d.Schedule)
+	fmt.Printf("Album ID:  %s\n", d.Album)
+	fmt.Printf("Redirect:  %s\n", redirect)
 
-	return nil
+	return printRuleChain(sched.Trace(rules.Env{Time: testDate}))
+}
+
+// parseTestDate parses the --date flag of the test command in the time zone
+// of now, returning the time and a layout for showing it. An empty flag
+// means now; MM-DD refers to the current year.
+func parseTestDate(s string, now time.Time) (time.Time, string, error) {
+	switch {
+	case s == "":
+		return now, "January 2 15:04 MST", nil
+	case strings.Contains(s, "T"):
+		t, err := time.ParseInLocation("2006-01-02T15:04", s, now.Location())
+		if err != nil {
+			return time.Time{}, "", fmt.Errorf("invalid date-time %q: use YYYY-MM-DDTHH:MM", s)
+		}
+		return t, "Monday, January 2, 2006 15:04 MST", nil
+	case strings.Count(s, "-") == 2:
+		t, err := time.ParseInLocation("2006-01-02", s, now.Location())
+		if err != nil {
+			return time.Time{}, "", fmt.Errorf("invalid date %q: use YYYY-MM-DD", s)
+		}
+		return t, "Monday, January 2, 2006", nil
+	}
+	month, day, err := scheduler.ParseMonthDay(s)
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("invalid date format: %w", err)
+	}
+	return time.Date(now.Year(), time.Month(month), day, 0, 0, 0, 0, now.Location()), "January 2", nil
+}
+
+// printRuleChain lists the entries whose range includes the tested day, in
+// evaluation order, with why each one was or was not selected.
+func printRuleChain(steps []scheduler.Step) error {
+	fmt.Println()
+	fmt.Println("Matching entries:")
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	matched, selected := false, ""
+	for _, st := range steps {
+		if !st.InRange {
+			continue
+		}
+		matched = true
+		var result string
+		switch {
+		case st.Selected:
+			result, selected = "selected", st.Entry.Name
+		case !st.ConditionMet:
+			result = "condition not met"
+		default:
+			result = "overridden by " + selected
+		}
+		when := "-"
+		if st.Entry.When != "" {
+			when = "when " + st.Entry.When
+		}
+		fmt.Fprintf(tw, "  %d.\t%s\t%s\t%s\t%s\n", st.Index+1, st.Entry.Name, st.Entry.Dates(), when, result)
+	}
+	if !matched {
+		fmt.Println("  none, the default album is shown")
+	}
+	return tw.Flush()
 }
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/scheduler"
+)
+
+func TestParseTestDate_TimeZone(t *testing.T) {
+	loc, err := time.LoadLocation("America/Los_Angeles")
+	require.NoError(t, err)
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC).In(loc)
+
+	at, _, err := parseTestDate("2026-12-31T23:30", now)
+	require.NoError(t, err)
+	assert.Equal(t, loc, at.Location())
+	// 23:30 on New Year's Eve in Los Angeles is New Year's Day in UTC...
+	assert.Equal(t, time.Date(2027, 1, 1, 7, 30, 0, 0, time.UTC), at.UTC())
+
+	// ...but the schedule is evaluated on the day in Los Angeles.
+	sched, err := scheduler.New(&config.Config{
+		DefaultAlbum: "default",
+		Schedule:     []config.ScheduleEntry{{Name: "new-year", Album: "new-year", Start: "01-01", End: "01-01"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "default", sched.GetAlbumForDate(at))
+	assert.Equal(t, "new-year", sched.GetAlbumForDate(at.UTC()))
+
+	day, _, err := parseTestDate("12-31", now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 12, 31, 0, 0, 0, 0, loc), day)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var partyCmd = &cobra.Command{
+	Use:   "party",
+	Short: "Start or stop a party mode on a running server",
+	Long: `Start or stop a party mode on a running server through its API.
+
+Starting and stopping require an API token, taken from --token or the
+IKS_API_TOKEN environment variable.`,
+}
+
+var partyStartCmd = &cobra.Command{
+	Use:   "start <name>",
+	Short: "Start a party mode",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runPartyStart,
+	// Names are completed from the local config file.
+	ValidArgsFunction: completeConfigNames(partyModeNames),
+}
+
+var partyStopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Stop the running party mode",
+	Args:  cobra.NoArgs,
+	RunE:  runPartyStop,
+}
+
+var partyStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the party modes and the running one",
+	Args:  cobra.NoArgs,
+	RunE:  runPartyStatus,
+}
+
+// partyMode mirrors the server's description of a running party mode.
+type partyMode struct {
+	Name   string            `json:"name"`
+	Album  string            `json:"album"`
+	Params map[string]string `json:"params"`
+	Until  time.Time         `json:"until"`
+}
+
+func init() {
+	addServerFlags(partyCmd, "http://localhost:8080")
+	partyStartCmd.Flags().Duration("duration", 0, "how long the party mode lasts (default: configured duration)")
+
+	partyCmd.AddCommand(partyStartCmd)
+	partyCmd.AddCommand(partyStopCmd)
+	partyCmd.AddCommand(partyStatusCmd)
+}
+
+func runPartyStart(cmd *cobra.Command, args []string) error {
+	body := map[string]string{}
+	if d, _ := cmd.Flags().GetDuration("duration"); d > 0 {
+		body["duration"] = d.String()
+	}
+
+	var mode partyMode
+	if err := apiRequest(cmd, http.MethodPost, "/api/v1/party/"+url.PathEscape(args[0]), body, &mode); err != nil {
+		return err
+	}
+	fmt.Printf("Party mode %q started until %s\n", mode.Name, mode.Until.Local().Format("2006-01-02 15:04"))
+	return nil
+}
+
+func runPartyStop(cmd *cobra.Command, args []string) error {
+	if err := apiRequest(cmd, http.MethodDelete, "/api/v1/party", nil, nil); err != nil {
+		return err
+	}
+	fmt.Println("Party mode stopped")
+	return nil
+}
+
+func runPartyStatus(cmd *cobra.Command, args []string) error {
+	var status struct {
+		Modes  []string   `json:"modes"`
+		Active *partyMode `json:"active"`
+	}
+	if err := apiRequest(cmd, http.MethodGet, "/api/v1/party", nil, &status); err != nil {
+		return err
+	}
+
+	fmt.Printf("Party modes: %s\n", strings.Join(status.Modes, ", "))
+	if status.Active == nil {
+		fmt.Println("No party mode running")
+		return nil
+	}
+	fmt.Printf("Running:     %s until %s\n", status.Active.Name, status.Active.Until.Local().Format("2006-01-02 15:04"))
+	return nil
+}
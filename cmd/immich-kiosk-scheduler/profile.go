@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+)
+
+var profileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Show or switch the schedule profile of a running server",
+	Long: `Show or switch the active schedule profile of a running server through
+its API. The choice is persisted in the state directory and survives
+configuration reloads and restarts.
+
+Switching requires an API token, taken from --token or the IKS_API_TOKEN
+environment variable.`,
+}
+
+var profileSwitchCmd = &cobra.Command{
+	Use:   "switch <name>",
+	Short: "Activate a profile",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runProfileSwitch,
+	// Names are completed from the local config file.
+	ValidArgsFunction: completeConfigNames((*config.Config).ProfileNames),
+}
+
+var profileStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the profiles and the active one",
+	Args:  cobra.NoArgs,
+	RunE:  runProfileStatus,
+}
+
+// profileStatus mirrors the server's profile response.
+type profileStatus struct {
+	Profiles []string `json:"profiles"`
+	Active   string   `json:"active"`
+}
+
+func init() {
+	addServerFlags(profileCmd, "http://localhost:8080")
+
+	profileCmd.AddCommand(profileSwitchCmd)
+	profileCmd.AddCommand(profileStatusCmd)
+}
+
+func runProfileSwitch(cmd *cobra.Command, args []string) error {
+	var status profileStatus
+	if err := apiRequest(cmd, http.MethodPut, "/api/v1/profile/"+url.PathEscape(args[0]), nil, &status); err != nil {
+		return err
+	}
+	fmt.Printf("Profile %q active\n", status.Active)
+	return nil
+}
+
+func runProfileStatus(cmd *cobra.Command, args []string) error {
+	var status profileStatus
+	if err := apiRequest(cmd, http.MethodGet, "/api/v1/profile", nil, &status); err != nil {
+		return err
+	}
+	if len(status.Profiles) == 0 {
+		fmt.Println("No profiles configured")
+		return nil
+	}
+	fmt.Printf("Profiles: %s\n", strings.Join(status.Profiles, ", "))
+	fmt.Printf("Active:   %s\n", status.Active)
+	return nil
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/scheduler"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/pkg/client"
+)
+
+// coverageSymbols labels entries in the ASCII strip chart, in evaluation order.
+const coverageSymbols = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+
+// coverageDefaultSymbol marks days served by the default album.
+const coverageDefaultSymbol = '.'
+
+// coverageYear is the leap year used to lay out the strip chart.
+const coverageYear = 2024
+
+var scheduleCmd = &cobra.Command{
+	Use:   "schedule",
+	Short: "Inspect the schedule",
+}
+
+var scheduleCoverageCmd = &cobra.Command{
+	Use:   "coverage",
+	Short: "Show how much of the year each entry covers",
+	Long: `Show how many days each schedule entry matches and is selected on,
+how many days fall back to the default album, and a strip chart of the year.
+
+Output formats: text (ASCII strip chart), json, html (standalone page).`,
+	RunE: runScheduleCoverage,
+}
+
+var scheduleListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the schedule entries in evaluation order",
+	Long:  "List the schedule entries in evaluation order; the first matching entry wins.\n" + remoteHelp,
+	Args:  cobra.NoArgs,
+	RunE:  runScheduleList,
+}
+
+func init() {
+	addOutputFlag(scheduleCoverageCmd, "text", "json", "html")
+	addProfileFlag(scheduleCoverageCmd, "profile to inspect (default: the configured profile)")
+	scheduleCmd.AddCommand(scheduleCoverageCmd)
+
+	addServerFlags(scheduleListCmd, "")
+	addOutputFlag(scheduleListCmd, "text", "json")
+	addProfileFlag(scheduleListCmd, "profile to list locally (default: the configured profile)")
+	scheduleCmd.AddCommand(scheduleListCmd)
+}
+
+func runScheduleList(cmd *cobra.Command, args []string) error {
+	output, _ := cmd.Flags().GetString("output")
+	if output != "text" && output != "json" {
+		return fmt.Errorf("unknown output format %q", output)
+	}
+
+	var entries []client.ScheduleEntry
+	var defaultAlbum string
+	if c := apiClient(cmd); c != nil {
+		schedules, err := c.ListSchedules(cmd.Context())
+		if err != nil {
+			return err
+		}
+		entries, defaultAlbum = schedules.Schedules, schedules.DefaultAlbum
+	} else {
+		cfg, _, err := loadLocalScheduler(cmd)
+		if err != nil {
+			return err
+		}
+		// The JSON of both is the same.
+		data, err := json.Marshal(cfg.Schedule)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return err
+		}
+		defaultAlbum = cfg.DefaultAlbum
+	}
+
+	if output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(client.Schedules{DefaultAlbum: defaultAlbum, Schedules: entries})
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tSTART\tEND\tRRULE\tALBUM\tWHEN")
+	for _, e := range entries {
+		album := e.Album
+		if len(e.Albums) > 0 {
+			album = strings.Join(e.Albums, ", ")
+		}
+		rule := strings.Join(strings.Fields(e.RRule), " ")
+		if e.Days > 1 {
+			rule += fmt.Sprintf(" (%d days)", e.Days)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", e.Name, e.Start, e.End, rule, album, e.When)
+	}
+	fmt.Fprintf(tw, "(default)\t\t\t\t%s\t\n", defaultAlbum)
+	return tw.Flush()
+}
+
+func runScheduleCoverage(cmd *cobra.Command, args []string) error {
+	output, _ := cmd.Flags().GetString("output")
+	if output != "text" && output != "json" && output != "html" {
+		return fmt.Errorf("unknown output format %q", output)
+	}
+
+	if cfgFile == "" {
+		cfgFile = "config.yaml"
+	}
+
+	cfg, err := config.Load(cfgFile)
+	if err != nil {
+		return err
+	}
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	if cfg, err = profileConfig(cmd, cfg); err != nil {
+		return err
+	}
+
+	sched, err := scheduler.New(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create scheduler: %w", err)
+	}
+	coverage := sched.Coverage()
+
+	switch output {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(coverage)
+	case "html":
+		return writeCoverageHTML(os.Stdout, coverage)
+	default:
+		writeCoverageText(os.Stdout, coverage)
+		return nil
+	}
+}
+
+// coverageSymbol returns the strip chart symbol for a selection index.
+func coverageSymbol(idx int) byte {
+	switch {
+	case idx < 0:
+		return coverageDefaultSymbol
+	case idx < len(coverageSymbols):
+		return coverageSymbols[idx]
+	default:
+		return '#'
+	}
+}
+
+// coverageDate returns the date of the day at index i of the selection.
+func coverageDate(i int) time.Time {
+	return time.Date(coverageYear, time.January, 1+i, 0, 0, 0, 0, time.UTC)
+}
+
+// percent formats days as a share of the year.
+func percent(days, total int) string {
+	return fmt.Sprintf("%.1f%%", float64(days)*100/float64(total))
+}
+
+func writeCoverageText(w io.Writer, c scheduler.Coverage) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "\tENTRY\tALBUM\tMATCHES\tSELECTED\tSHARE")
+	for i, e := range c.Entries {
+		fmt.Fprintf(tw, "%c\t%s\t%s\t%d\t%d\t%s\n",
+			coverageSymbol(i), e.Name, e.Album, e.Days, e.SelectedDays, percent(e.SelectedDays, c.TotalDays))
+	}
+	fmt.Fprintf(tw, "%c\t%s\t%s\t%d\t%d\t%s\n",
+		coverageDefaultSymbol, "(default)", c.DefaultAlbum, c.DefaultDays, c.DefaultDays, percent(c.DefaultDays, c.TotalDays))
+	tw.Flush()
+	fmt.Fprintln(w)
+
+	fmt.Fprintf(w, "     %s\n", "1        10        20        30")
+	rows := make([][]byte, 12)
+	for i, idx := range c.Selection {
+		month := coverageDate(i).Month() - 1
+		rows[month] = append(rows[month], coverageSymbol(idx))
+	}
+	for m, row := range rows {
+		fmt.Fprintf(w, "%s  %s\n", time.Month(m + 1).String()[:3], row)
+	}
+}
+
+// coverageColor returns a distinct background color for an entry.
+func coverageColor(idx int) template.CSS {
+	if idx < 0 {
+		return "#e5e7eb"
+	}
+	// Golden-angle hue spacing keeps neighbouring entries distinguishable.
+	return template.CSS(fmt.Sprintf("hsl(%d, 65%%, 55%%)", (idx*137)%360))
+}
+
+var coverageTemplate = template.Must(template.New("coverage").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Schedule coverage</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 2rem; color: #111827; }
+table.summary { border-collapse: collapse; margin-bottom: 2rem; }
+table.summary th, table.summary td { padding: 0.25rem 0.75rem; text-align: left; }
+table.summary td.num { text-align: right; }
+.swatch { display: inline-block; width: 0.9rem; height: 0.9rem; border-radius: 2px; vertical-align: middle; }
+table.strip { border-collapse: separate; border-spacing: 2px; }
+table.strip th { font-weight: normal; font-size: 0.8rem; padding-right: 0.5rem; text-align: right; }
+table.strip td { width: 1.1rem; height: 1.1rem; border-radius: 2px; }
+</style>
+</head>
+<body>
+<h1>Schedule coverage</h1>
+<table class="summary">
+<tr><th></th><th>Entry</th><th>Album</th><th>Matches</th><th>Selected</th><th>Share</th></tr>
+{{- range .Entries}}
+<tr><td><span class="swatch" style="background: {{.Color}}"></span></td><td>{{.Name}}</td><td>{{.Album}}</td><td class="num">{{.Days}}</td><td class="num">{{.SelectedDays}}</td><td class="num">{{.Share}}</td></tr>
+{{- end}}
+</table>
+<table class="strip">
+{{- range .Months}}
+<tr><th>{{.Name}}</th>{{range .Days}}<td style="background: {{.Color}}" title="{{.Title}}"></td>{{end}}</tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
+
+type coverageHTMLEntry struct {
+	Name, Album, Share string
+	Days, SelectedDays int
+	Color              template.CSS
+}
+
+type coverageHTMLDay struct {
+	Title string
+	Color template.CSS
+}
+
+type coverageHTMLMonth struct {
+	Name string
+	Days []coverageHTMLDay
+}
+
+func writeCoverageHTML(w io.Writer, c scheduler.Coverage) error {
+	data := struct {
+		Entries []coverageHTMLEntry
+		Months  []coverageHTMLMonth
+	}{}
+
+	for i, e := range c.Entries {
+		data.Entries = append(data.Entries, coverageHTMLEntry{
+			Name: e.Name, Album: e.Album, Days: e.Days, SelectedDays: e.SelectedDays,
+			Share: percent(e.SelectedDays, c.TotalDays), Color: coverageColor(i),
+		})
+	}
+	data.Entries = append(data.Entries, coverageHTMLEntry{
+		Name: "(default)", Album: c.DefaultAlbum, Days: c.DefaultDays, SelectedDays: c.DefaultDays,
+		Share: percent(c.DefaultDays, c.TotalDays), Color: coverageColor(-1),
+	})
+
+	for i, idx := range c.Selection {
+		date := coverageDate(i)
+		if date.Day() == 1 {
+			data.Months = append(data.Months, coverageHTMLMonth{Name: date.Month().String()[:3]})
+		}
+		name := "default"
+		if idx >= 0 {
+			name = c.Entries[idx].Name
+		}
+		month := &data.Months[len(data.Months)-1]
+		month.Days = append(month.Days, coverageHTMLDay{
+			Title: date.Format("01-02") + ": " + name,
+			Color: coverageColor(idx),
+		})
+	}
+
+	return coverageTemplate.Execute(w, data)
+}
@@ -0,0 +1,10 @@
+//go:build !windows
+
+package main
+
+import "github.com/spf13/cobra"
+
+// runAsService reports false: services are only supported on Windows.
+func runAsService(*cobra.Command) (bool, error) {
+	return false, nil
+}
@@ -0,0 +1,288 @@
+//go:build windows
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+	"golang.org/x/sys/windows/svc/mgr"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/logging"
+)
+
+// serviceName is the name of the Windows service and its event log source.
+const serviceName = "immich-kiosk-scheduler"
+
+// serviceStopTimeout bounds how long `service stop` waits for the service.
+const serviceStopTimeout = 30 * time.Second
+
+var serviceCmd = &cobra.Command{
+	Use:   "service",
+	Short: "Manage the Windows service",
+	Long: `Install, start, stop or remove immich-kiosk-scheduler as a Windows service.
+The service runs "serve" with the configuration given to "service install"
+and logs to the Windows event log.`,
+}
+
+var serviceInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Install the Windows service",
+	Args:  cobra.NoArgs,
+	RunE:  runServiceInstall,
+}
+
+var serviceUninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Remove the Windows service",
+	Args:  cobra.NoArgs,
+	RunE:  runServiceUninstall,
+}
+
+var serviceStartCmd = &cobra.Command{
+	Use:   "start",
+	Short: "Start the Windows service",
+	Args:  cobra.NoArgs,
+	RunE:  runServiceStart,
+}
+
+var serviceStopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Stop the Windows service",
+	Args:  cobra.NoArgs,
+	RunE:  runServiceStop,
+}
+
+func init() {
+	serviceCmd.AddCommand(serviceInstallCmd)
+	serviceCmd.AddCommand(serviceUninstallCmd)
+	serviceCmd.AddCommand(serviceStartCmd)
+	serviceCmd.AddCommand(serviceStopCmd)
+	rootCmd.AddCommand(serviceCmd)
+}
+
+func runServiceInstall(cmd *cobra.Command, args []string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate executable: %w", err)
+	}
+	// Services start in the system directory, so the config path must be absolute.
+	path := cfgFile
+	if path == "" {
+		path = "config.yaml"
+	}
+	if path, err = filepath.Abs(path); err != nil {
+		return fmt.Errorf("failed to resolve config path: %w", err)
+	}
+
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to the service manager: %w", err)
+	}
+	defer m.Disconnect()
+
+	if s, err := m.OpenService(serviceName); err == nil {
+		s.Close()
+		return fmt.Errorf("service %s already exists", serviceName)
+	}
+	s, err := m.CreateService(serviceName, exe, mgr.Config{
+		DisplayName: "Immich Kiosk Scheduler",
+		Description: "Redirects Immich Kiosk displays to the scheduled album",
+		StartType:   mgr.StartAutomatic,
+	}, "serve", "--config", path)
+	if err != nil {
+		return fmt.Errorf("failed to create service: %w", err)
+	}
+	defer s.Close()
+
+	if err := eventlog.InstallAsEventCreate(serviceName, eventlog.Error|eventlog.Warning|eventlog.Info); err != nil {
+		_ = s.Delete()
+		return fmt.Errorf("failed to register event log source: %w", err)
+	}
+
+	fmt.Printf("Installed service %s (config: %s)\n", serviceName, path)
+	return nil
+}
+
+func runServiceUninstall(cmd *cobra.Command, args []string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to the service manager: %w", err)
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(serviceName)
+	if err != nil {
+		return fmt.Errorf("service %s is not installed", serviceName)
+	}
+	defer s.Close()
+
+	if err := s.Delete(); err != nil {
+		return fmt.Errorf("failed to remove service: %w", err)
+	}
+	if err := eventlog.Remove(serviceName); err != nil {
+		return fmt.Errorf("failed to remove event log source: %w", err)
+	}
+
+	fmt.Printf("Removed service %s\n", serviceName)
+	return nil
+}
+
+func runServiceStart(cmd *cobra.Command, args []string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to the service manager: %w", err)
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(serviceName)
+	if err != nil {
+		return fmt.Errorf("service %s is not installed", serviceName)
+	}
+	defer s.Close()
+
+	if err := s.Start(); err != nil {
+		return fmt.Errorf("failed to start service: %w", err)
+	}
+
+	fmt.Printf("Started service %s\n", serviceName)
+	return nil
+}
+
+func runServiceStop(cmd *cobra.Command, args []string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to the service manager: %w", err)
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(serviceName)
+	if err != nil {
+		return fmt.Errorf("service %s is not installed", serviceName)
+	}
+	defer s.Close()
+
+	status, err := s.Control(svc.Stop)
+	if err != nil {
+		return fmt.Errorf("failed to stop service: %w", err)
+	}
+	deadline := time.Now().Add(serviceStopTimeout)
+	for status.State != svc.Stopped {
+		if time.Now().After(deadline) {
+			return errors.New("timed out waiting for the service to stop")
+		}
+		time.Sleep(300 * time.Millisecond)
+		if status, err = s.Query(); err != nil {
+			return fmt.Errorf("failed to query service status: %w", err)
+		}
+	}
+
+	fmt.Printf("Stopped service %s\n", serviceName)
+	return nil
+}
+
+// runAsService runs the server under the Windows service manager, logging to
+// the event log. It reports false when the process was started interactively.
+func runAsService(cmd *cobra.Command) (bool, error) {
+	isService, err := svc.IsWindowsService()
+	if err != nil || !isService {
+		return false, err
+	}
+
+	elog, err := eventlog.Open(serviceName)
+	if err != nil {
+		return true, fmt.Errorf("failed to open event log: %w", err)
+	}
+	defer elog.Close()
+
+	newLogHandler = func(level, format string) slog.Handler {
+		return slog.NewTextHandler(&eventLogWriter{log: elog}, &slog.HandlerOptions{
+			Level: logging.ParseLevel(level),
+			// The event log records the time itself.
+			ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+				if len(groups) == 0 && a.Key == slog.TimeKey {
+					return slog.Attr{}
+				}
+				return a
+			},
+		})
+	}
+
+	return true, svc.Run(serviceName, &kioskService{cmd: cmd})
+}
+
+// kioskService adapts serve to the service control handler interface.
+type kioskService struct {
+	cmd *cobra.Command
+}
+
+// Execute runs the server until the service manager asks it to stop.
+func (k *kioskService) Execute(args []string, requests <-chan svc.ChangeRequest, changes chan<- svc.Status) (bool, uint32) {
+	changes <- svc.Status{State: svc.StartPending}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- serve(ctx, k.cmd) }()
+
+	changes <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for {
+		select {
+		case err := <-done:
+			if err != nil {
+				slog.Error("server failed", slog.Any("error", err))
+				return false, 1
+			}
+			return false, 0
+		case req := <-requests:
+			switch req.Cmd {
+			case svc.Interrogate:
+				changes <- req.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				slog.Info("received service stop request")
+				changes <- svc.Status{State: svc.StopPending}
+				cancel()
+				if err := <-done; err != nil {
+					slog.Error("server failed", slog.Any("error", err))
+					return false, 1
+				}
+				return false, 0
+			}
+		}
+	}
+}
+
+// eventLogWriter writes each log line to the event log, mapping the slog
+// level of text output to the event type.
+type eventLogWriter struct {
+	log *eventlog.Log
+}
+
+// eventID is the event ID used for all log entries.
+const eventID = 1
+
+func (w *eventLogWriter) Write(p []byte) (int, error) {
+	msg := string(bytes.TrimRight(p, "\n"))
+	var err error
+	switch {
+	case bytes.HasPrefix(p, []byte("level=ERROR")):
+		err = w.log.Error(eventID, msg)
+	case bytes.HasPrefix(p, []byte("level=WARN")):
+		err = w.log.Warning(eventID, msg)
+	default:
+		err = w.log.Info(eventID, msg)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/scheduler"
+)
+
+// simulateDateLayout is the date format of the --from/--to flags and the output.
+const simulateDateLayout = "2006-01-02"
+
+// simulateMaxDays bounds the simulated period to keep output sizes sane.
+const simulateMaxDays = 366 * 10
+
+var simulateCmd = &cobra.Command{
+	Use:   "simulate",
+	Short: "Simulate the schedule over a date range",
+	Long: `Resolve the schedule for every day in a date range and print one row per
+day with the schedule name, album and redirect URL.
+
+Diff the output before and after a configuration change to review its effect:
+
+  immich-kiosk-scheduler simulate --config old.yaml > before.csv
+  immich-kiosk-scheduler simulate --config new.yaml > after.csv
+  diff before.csv after.csv`,
+	RunE: runSimulate,
+}
+
+func init() {
+	simulateCmd.Flags().String("from", "", "first day to simulate (YYYY-MM-DD, defaults to January 1 of the current year)")
+	simulateCmd.Flags().String("to", "", "last day to simulate (YYYY-MM-DD, defaults to December 31 of the --from year)")
+	addOutputFlag(simulateCmd, "csv", "json")
+	addProfileFlag(simulateCmd, "profile to simulate (default: the configured profile)")
+}
+
+// simulatedDay is one row of the simulate output.
+type simulatedDay struct {
+	Date        string `json:"date"`
+	Schedule    string `json:"schedule"`
+	Album       string `json:"album"`
+	RedirectURL string `json:"redirect_url"`
+}
+
+func runSimulate(cmd *cobra.Command, args []string) error {
+	output, _ := cmd.Flags().GetString("output")
+	if output != "csv" && output != "json" {
+		return fmt.Errorf("unknown output format %q", output)
+	}
+
+	fromStr, _ := cmd.Flags().GetString("from")
+	toStr, _ := cmd.Flags().GetString("to")
+	from, to, err := simulateRange(fromStr, toStr, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if cfgFile == "" {
+		cfgFile = "config.yaml"
+	}
+
+	cfg, err := config.Load(cfgFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	if cfg, err = profileConfig(cmd, cfg); err != nil {
+		return err
+	}
+
+	sched, err := scheduler.New(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create scheduler: %w", err)
+	}
+
+	days, err := simulate(cfg, sched, from, to)
+	if err != nil {
+		return err
+	}
+
+	if output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(days)
+	}
+
+	w := csv.NewWriter(os.Stdout)
+	_ = w.Write([]string{"date", "schedule", "album", "redirect_url"})
+	for _, d := range days {
+		_ = w.Write([]string{d.Date, d.Schedule, d.Album, d.RedirectURL})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// simulateRange parses the --from/--to flags, applying the defaults.
+func simulateRange(fromStr, toStr string, now time.Time) (from, to time.Time, err error) {
+	from = time.Date(now.Year(), time.January, 1, 0, 0, 0, 0, time.Local)
+	if fromStr != "" {
+		if from, err = time.ParseInLocation(simulateDateLayout, fromStr, time.Local); err != nil {
+			return from, to, fmt.Errorf("invalid --from date: %w", err)
+		}
+	}
+
+	to = time.Date(from.Year(), time.December, 31, 0, 0, 0, 0, time.Local)
+	if toStr != "" {
+		if to, err = time.ParseInLocation(simulateDateLayout, toStr, time.Local); err != nil {
+			return from, to, fmt.Errorf("invalid --to date: %w", err)
+		}
+	}
+
+	if to.Before(from) {
+		return from, to, fmt.Errorf("--to (%s) is before --from (%s)", to.Format(simulateDateLayout), from.Format(simulateDateLayout))
+	}
+	if to.Sub(from) > simulateMaxDays*24*time.Hour {
+		return from, to, fmt.Errorf("date range exceeds %d days", simulateMaxDays)
+	}
+	return from, to, nil
+}
+
+// simulate resolves the schedule for every day from from to to, inclusive.
+func simulate(cfg *config.Config, sched *scheduler.Scheduler, from, to time.Time) ([]simulatedDay, error) {
+	var days []simulatedDay
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		album := sched.GetAlbumForDate(d)
+		redirect, err := redirectURL(cfg, album)
+		if err != nil {
+			return nil, err
+		}
+		days = append(days, simulatedDay{
+			Date:        d.Format(simulateDateLayout),
+			Schedule:    sched.GetScheduleNameForDate(d),
+			Album:       album,
+			RedirectURL: redirect,
+		})
+	}
+	return days, nil
+}
+
+// redirectURL builds the redirect target for an album as the server would
+// for a request without passthrough parameters.
+func redirectURL(cfg *config.Config, album string) (string, error) {
+	u, err := url.Parse(cfg.KioskURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid kiosk URL: %w", err)
+	}
+	q := u.Query()
+	q.Set("album", album)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
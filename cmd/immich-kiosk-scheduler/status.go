@@ -0,0 +1,277 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/scheduler"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/pkg/client"
+)
+
+// remoteHelp explains the local and remote modes of the status, next and
+// schedule list commands.
+const remoteHelp = `
+Without --server, the local config file is evaluated. With --server, the
+running server is asked instead, so overrides, party modes, profiles and
+runtime schedule changes are taken into account.`
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the current schedule and album",
+	Long:  "Show the schedule and album selected now and the next scheduled change.\n" + remoteHelp,
+	Args:  cobra.NoArgs,
+	RunE:  runStatus,
+}
+
+var nextCmd = &cobra.Command{
+	Use:   "next",
+	Short: "Show the next schedule changes",
+	Long:  "Show when the selected schedule changes next and what it changes to.\n" + remoteHelp,
+	Args:  cobra.NoArgs,
+	RunE:  runNext,
+}
+
+var overrideCmd = &cobra.Command{
+	Use:   "override <album>",
+	Short: "Show an album on a running server for a while",
+	Long: `Show a control album or an album alias on a running server, replacing
+the schedule until the override ends. --clear returns to the schedule and
+also stops a running party mode.
+
+Overrides require an API token, taken from --token or the IKS_API_TOKEN
+environment variable.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runOverride,
+	// Names are completed from the local config file.
+	ValidArgsFunction: completeConfigNames(overrideAlbumNames),
+}
+
+var freezeCmd = &cobra.Command{
+	Use:   "freeze",
+	Short: "Keep showing the current album on a running server",
+	Long: `Pin the album a running server shows now, so schedule changes are
+ignored until the freeze is released with unfreeze. A freeze replaces a
+running override or party mode, keeping its album. Restarting the server
+also releases it.
+
+Freezing requires an API token, taken from --token or the IKS_API_TOKEN
+environment variable.`,
+	Args: cobra.NoArgs,
+	RunE: runFreeze,
+}
+
+var unfreezeCmd = &cobra.Command{
+	Use:   "unfreeze",
+	Short: "Release a freeze on a running server",
+	Long: `Release a freeze started with freeze, returning to the schedule.
+
+Unfreezing requires an API token, taken from --token or the IKS_API_TOKEN
+environment variable.`,
+	Args: cobra.NoArgs,
+	RunE: runUnfreeze,
+}
+
+func init() {
+	addServerFlags(statusCmd, "")
+	addProfileFlag(statusCmd, "profile to evaluate locally (default: the configured profile)")
+	addServerFlags(nextCmd, "")
+	addProfileFlag(nextCmd, "profile to evaluate locally (default: the configured profile)")
+	nextCmd.Flags().IntP("count", "n", 1, "number of changes to show")
+	addServerFlags(overrideCmd, "http://localhost:8080")
+	overrideCmd.Flags().Duration("duration", 0, "how long the override lasts (default: the album's duration)")
+	overrideCmd.Flags().Bool("clear", false, "end the running override")
+	addServerFlags(freezeCmd, "http://localhost:8080")
+	addServerFlags(unfreezeCmd, "http://localhost:8080")
+}
+
+// loadLocalScheduler loads the local config file and builds its scheduler.
+func loadLocalScheduler(cmd *cobra.Command) (*config.Config, *scheduler.Scheduler, error) {
+	if cfgFile == "" {
+		cfgFile = "config.yaml"
+	}
+	cfg, err := config.Load(cfgFile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	if cfg, err = profileConfig(cmd, cfg); err != nil {
+		return nil, nil, err
+	}
+	sched, err := scheduler.New(cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create scheduler: %w", err)
+	}
+	return cfg, sched, nil
+}
+
+// localTransitions returns up to count schedule changes after now.
+func localTransitions(sched *scheduler.Scheduler, now time.Time, count int) []client.Transition {
+	var transitions []client.Transition
+	for t := now; len(transitions) < count; {
+		at, next, ok := sched.NextTransition(t)
+		if !ok {
+			break
+		}
+		transitions = append(transitions, client.Transition{At: at, Schedule: next.Schedule, Album: next.Album})
+		t = at
+	}
+	return transitions
+}
+
+// albumLabel describes an album by name when known.
+func albumLabel(id, name string) string {
+	if name == "" {
+		return id
+	}
+	return fmt.Sprintf("%s (%s)", name, id)
+}
+
+// formatTime formats a time in the local time zone.
+func formatTime(t time.Time) string {
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+func runStatus(cmd *cobra.Command, args []string) error {
+	if c := apiClient(cmd); c != nil {
+		status, err := c.Status(cmd.Context())
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Schedule:     %s\n", status.Schedule)
+		fmt.Printf("Album:        %s\n", albumLabel(status.Album, status.AlbumName))
+		if status.Override != nil {
+			until := "released"
+			if !status.Override.Until.IsZero() {
+				until = formatTime(status.Override.Until)
+			}
+			fmt.Printf("Override:     %s until %s\n", status.Override.Name, until)
+		}
+		if status.Profile != "" {
+			fmt.Printf("Profile:      %s\n", status.Profile)
+		}
+		if status.Maintenance {
+			fmt.Println("Maintenance:  enabled")
+		}
+		fmt.Printf("Revision:     %s\n", status.ConfigRevision)
+		transitions, err := c.Transitions(cmd.Context(), 1)
+		if err != nil {
+			return err
+		}
+		printNextChange(transitions)
+		return nil
+	}
+
+	_, sched, err := loadLocalScheduler(cmd)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	d := sched.Resolve(now)
+	fmt.Printf("Schedule:     %s\n", d.Schedule)
+	fmt.Printf("Album:        %s\n", d.Album)
+	printNextChange(localTransitions(sched, now, 1))
+	return nil
+}
+
+// printNextChange prints the first of transitions.
+func printNextChange(transitions []client.Transition) {
+	if len(transitions) == 0 {
+		fmt.Println("Next change:  none within a year")
+		return
+	}
+	t := transitions[0]
+	fmt.Printf("Next change:  %s (%s) from %s\n", t.Schedule, albumLabel(t.Album, t.AlbumName), formatTime(t.At))
+}
+
+func runNext(cmd *cobra.Command, args []string) error {
+	count, _ := cmd.Flags().GetInt("count")
+	if count < 1 {
+		return fmt.Errorf("--count must be at least 1")
+	}
+
+	var transitions []client.Transition
+	if c := apiClient(cmd); c != nil {
+		var err error
+		if transitions, err = c.Transitions(cmd.Context(), count); err != nil {
+			return err
+		}
+	} else {
+		_, sched, err := loadLocalScheduler(cmd)
+		if err != nil {
+			return err
+		}
+		transitions = localTransitions(sched, time.Now(), count)
+	}
+
+	if len(transitions) == 0 {
+		fmt.Println("No schedule change within the next year")
+		return nil
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "FROM\tSCHEDULE\tALBUM")
+	for _, t := range transitions {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", formatTime(t.At), t.Schedule, albumLabel(t.Album, t.AlbumName))
+	}
+	return tw.Flush()
+}
+
+func runOverride(cmd *cobra.Command, args []string) error {
+	c := apiClient(cmd)
+	if c == nil {
+		return errors.New("override needs a running server; set --server")
+	}
+	if clearOverride, _ := cmd.Flags().GetBool("clear"); clearOverride {
+		if len(args) > 0 {
+			return errors.New("--clear takes no album")
+		}
+		if err := c.ClearOverride(cmd.Context()); err != nil {
+			return err
+		}
+		fmt.Println("Back to the schedule")
+		return nil
+	}
+	if len(args) == 0 {
+		return errors.New("name an album to show, or pass --clear")
+	}
+
+	d, _ := cmd.Flags().GetDuration("duration")
+	override, err := c.Override(cmd.Context(), args[0], d)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Showing %s until %s\n", override.Name, formatTime(override.Until))
+	return nil
+}
+
+func runFreeze(cmd *cobra.Command, args []string) error {
+	c := apiClient(cmd)
+	if c == nil {
+		return errors.New("freeze needs a running server; set --server")
+	}
+	frozen, err := c.Freeze(cmd.Context())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Frozen on %s (%s) until unfrozen\n", frozen.Name, frozen.Album)
+	return nil
+}
+
+func runUnfreeze(cmd *cobra.Command, args []string) error {
+	c := apiClient(cmd)
+	if c == nil {
+		return errors.New("unfreeze needs a running server; set --server")
+	}
+	if err := c.Unfreeze(cmd.Context()); err != nil {
+		return err
+	}
+	fmt.Println("Back to the schedule")
+	return nil
+}
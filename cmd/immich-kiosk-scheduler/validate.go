@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/scheduler"
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validate the configuration file",
+	Long: `Validate the configuration file and analyze the schedule.
+
+Besides hard errors, this reports warnings for entries that overlap
+(including which one wins) and for days of the year not covered by any
+entry, where the default album is used.`,
+	RunE: runValidate,
+}
+
+func init() {
+	addOutputFlag(validateCmd, "text", "json")
+}
+
+// validateResult is the JSON output of the validate command.
+type validateResult struct {
+	Valid    bool                `json:"valid"`
+	Error    string              `json:"error,omitempty"`
+	Warnings []scheduler.Warning `json:"warnings"`
+}
+
+func runValidate(cmd *cobra.Command, args []string) error {
+	output, _ := cmd.Flags().GetString("output")
+	if output != "text" && output != "json" {
+		return fmt.Errorf("unknown output format %q", output)
+	}
+
+	if cfgFile == "" {
+		cfgFile = "config.yaml"
+	}
+
+	result := validateResult{Valid: true, Warnings: []scheduler.Warning{}}
+
+	cfg, err := config.Load(cfgFile)
+	if err == nil {
+		cfg, err = profileConfig(cmd, cfg)
+	}
+	if err == nil {
+		var sched *scheduler.Scheduler
+		sched, err = scheduler.New(cfg)
+		if err == nil {
+			result.Warnings = sched.Warnings()
+		}
+	}
+	if err != nil {
+		result.Valid = false
+		result.Error = err.Error()
+	}
+
+	if output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if encErr := enc.Encode(result); encErr != nil {
+			return encErr
+		}
+	} else {
+		printValidateResult(result)
+	}
+
+	if err != nil {
+		// The error has already been reported; only signal failure.
+		cmd.SilenceErrors = true
+		cmd.SilenceUsage = true
+		return &exitError{code: 1}
+	}
+	return nil
+}
+
+func printValidateResult(result validateResult) {
+	if !result.Valid {
+		fmt.Printf("Configuration %s is invalid:\n  %s\n", cfgFile, result.Error)
+		return
+	}
+
+	fmt.Printf("Configuration %s is valid\n", cfgFile)
+	if len(result.Warnings) == 0 {
+		return
+	}
+
+	fmt.Printf("\nWarnings:\n")
+	for _, w := range result.Warnings {
+		fmt.Printf("  [%s] %s\n", w.Kind, w.Message)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/spf13/cobra"
+)
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print the version",
+	Long: `Print the version, commit and build date. --json prints them as a JSON
+object for scripts, --short prints the version alone.`,
+	Args: cobra.NoArgs,
+	RunE: runVersion,
+}
+
+// versionInfo is the JSON output of the version command.
+type versionInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	Date      string `json:"date"`
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
+}
+
+func init() {
+	versionCmd.Flags().Bool("json", false, "print the version information as JSON")
+	versionCmd.Flags().Bool("short", false, "print only the version")
+	versionCmd.MarkFlagsMutuallyExclusive("json", "short")
+	rootCmd.AddCommand(versionCmd)
+}
+
+func runVersion(cmd *cobra.Command, args []string) error {
+	if short, _ := cmd.Flags().GetBool("short"); short {
+		fmt.Println(version)
+		return nil
+	}
+
+	info := versionInfo{
+		Version:   version,
+		Commit:    commit,
+		Date:      buildDate,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+	if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(info)
+	}
+
+	fmt.Printf("immich-kiosk-scheduler %s\n", info.Version)
+	fmt.Printf("Commit:     %s\n", info.Commit)
+	fmt.Printf("Built:      %s\n", info.Date)
+	fmt.Printf("Go version: %s\n", info.GoVersion)
+	fmt.Printf("Platform:   %s\n", info.Platform)
+	return nil
+}
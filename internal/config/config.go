@@ -2,33 +2,2013 @@
 package config
 
 import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"maps"
+	"net"
+	"net/mail"
+	"net/netip"
 	"net/url"
+	"os"
+	"path/filepath"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"text/template"
+	"time"
 
+	"github.com/go-viper/mapstructure/v2"
 	"github.com/spf13/viper"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/rrule"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/rules"
 )
 
 // ScheduleEntry represents a single schedule entry that maps a date range to an album.
 type ScheduleEntry struct {
+	Name  string `mapstructure:"name" json:"name"`
+	Album string `mapstructure:"album" json:"album"`
+	Start string `mapstructure:"start" json:"start"` // Format: MM-DD
+	End   string `mapstructure:"end" json:"end"`     // Format: MM-DD
+	// EndBoundary is EndInclusive, End being the last day covered, or
+	// EndExclusive, End being the first day no longer covered so that
+	// adjacent entries can share it. Empty uses the top-level end_boundary.
+	EndBoundary string `mapstructure:"end_boundary" json:"end_boundary,omitempty"`
+	// RRule is an optional RFC 5545 recurrence rule (see package rrule),
+	// possibly preceded by a DTSTART line, selecting the days within Start
+	// and End, which may then be left out to cover the whole year. Each
+	// occurrence lasts Days days.
+	RRule string `mapstructure:"rrule" json:"rrule,omitempty"`
+	Days  int    `mapstructure:"days" json:"days,omitempty"`
+	// Params override default and passthrough params while the entry is
+	// selected; RemoveParams drops them from the redirect.
+	Params       map[string]string `mapstructure:"params" json:"params,omitempty"`
+	RemoveParams []string          `mapstructure:"remove_params" json:"remove_params,omitempty"`
+	// When is an optional condition (see package rules) that must also hold
+	// for the entry to be selected.
+	When string `mapstructure:"when" json:"when,omitempty"`
+	// Albums, instead of Album, alternates the entry between albums, each
+	// shown for RotateMinutes in turn. Turns follow the wall clock, so all
+	// displays show the same album.
+	Albums        []string `mapstructure:"albums" json:"albums,omitempty"`
+	RotateMinutes int      `mapstructure:"rotate_minutes" json:"rotate_minutes,omitempty"`
+	// MinDuration, a duration such as "30m", keeps the entry, or a rotated
+	// album of it, selected at least that long once selected, even if its
+	// condition stops holding.
+	MinDuration string `mapstructure:"min_duration" json:"min_duration,omitempty"`
+	// Discovered marks entries created from Immich album names rather than
+	// configured; they are never saved back to the configuration.
+	Discovered bool `mapstructure:"-" json:"discovered,omitempty"`
+}
+
+// MinDwell returns the parsed MinDuration, or zero when it is unset or
+// invalid.
+func (s *ScheduleEntry) MinDwell() time.Duration {
+	d, _ := time.ParseDuration(s.MinDuration)
+	return d
+}
+
+// End boundaries of schedule entries.
+const (
+	EndInclusive = "inclusive"
+	EndExclusive = "exclusive"
+)
+
+// EndExclusive reports whether End is the first day the entry no longer
+// covers. defaultBoundary, the top-level end_boundary, applies when the
+// entry does not set one. Entries with a recurrence rule alone have no End.
+func (s *ScheduleEntry) EndExclusive(defaultBoundary string) bool {
+	if s.Start == "" && s.End == "" {
+		return false
+	}
+	if s.EndBoundary != "" {
+		return s.EndBoundary == EndExclusive
+	}
+	return defaultBoundary == EndExclusive
+}
+
+// validateEndBoundary checks an end_boundary value.
+func validateEndBoundary(boundary string) error {
+	switch boundary {
+	case "", EndInclusive, EndExclusive:
+		return nil
+	}
+	return fmt.Errorf("end_boundary must be %s or %s, got %q", EndInclusive, EndExclusive, boundary)
+}
+
+// AlbumIDs returns the albums the entry shows: Albums when it rotates,
+// otherwise Album.
+func (s *ScheduleEntry) AlbumIDs() []string {
+	if len(s.Albums) > 0 {
+		return s.Albums
+	}
+	return []string{s.Album}
+}
+
+// Dates describes the days the entry covers for display, such as
+// "11-15 to 01-01" or "FREQ=YEARLY;BYMONTH=7;BYDAY=2MO for 7 days".
+func (s *ScheduleEntry) Dates() string {
+	dates := s.Start + " to " + s.End
+	if s.RRule == "" {
+		return dates
+	}
+	rule := strings.Join(strings.Fields(s.RRule), " ")
+	if s.Days > 1 {
+		rule += fmt.Sprintf(" for %d days", s.Days)
+	}
+	if s.Start == "" && s.End == "" {
+		return rule
+	}
+	return rule + " from " + dates
+}
+
+// AlbumLabel describes the entry's albums for display.
+func (s *ScheduleEntry) AlbumLabel() string {
+	return strings.Join(s.AlbumIDs(), ", ")
+}
+
+// StatsDConfig configures the StatsD metrics backend.
+type StatsDConfig struct {
+	Address  string        `mapstructure:"address"`
+	Prefix   string        `mapstructure:"prefix"`
+	Flavor   string        `mapstructure:"flavor"` // statsd or dogstatsd
+	Interval time.Duration `mapstructure:"interval"`
+}
+
+// MetricsConfig selects and configures the metrics backend.
+type MetricsConfig struct {
+	Backend string       `mapstructure:"backend"` // prometheus or statsd
+	StatsD  StatsDConfig `mapstructure:"statsd"`
+}
+
+// AccessLogConfig controls HTTP access logging.
+type AccessLogConfig struct {
+	// SampleRate logs 1 in N successful redirects; 0 or 1 logs every request.
+	// Errors are always logged.
+	SampleRate int `mapstructure:"sample_rate"`
+}
+
+// TracingConfig controls W3C Trace Context propagation.
+type TracingConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+}
+
+// CompressionConfig controls gzip compression of API and UI responses.
+type CompressionConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	Level   int  `mapstructure:"level"` // 1 (fastest) to 9 (smallest)
+}
+
+// Hook events.
+const (
+	HookEventTransition      = "transition"
+	HookEventDiscovery       = "discovery"
+	HookEventDeviceStale     = "device_stale"
+	HookEventDeviceRecovered = "device_recovered"
+)
+
+// hookEvents lists the events hooks can subscribe to.
+var hookEvents = []string{HookEventTransition, HookEventDiscovery, HookEventDeviceStale, HookEventDeviceRecovered}
+
+// HookConfig configures a webhook notified of schedule events.
+type HookConfig struct {
+	Name    string            `mapstructure:"name"`
+	URL     string            `mapstructure:"url"`
+	Events  []string          `mapstructure:"events"` // empty means all events
+	Headers map[string]string `mapstructure:"headers"`
+	Timeout time.Duration     `mapstructure:"timeout"`
+}
+
+// Validate checks the hook configuration.
+func (h *HookConfig) Validate() error {
+	if strings.TrimSpace(h.Name) == "" {
+		return fmt.Errorf("hook name is required")
+	}
+	u, err := url.Parse(h.URL)
+	if err != nil {
+		return fmt.Errorf("invalid url: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an absolute http or https URL, got %q", h.URL)
+	}
+	for _, event := range h.Events {
+		if !slices.Contains(hookEvents, event) {
+			return fmt.Errorf("unknown event %q", event)
+		}
+	}
+	if h.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative")
+	}
+	return nil
+}
+
+// Wants reports whether the hook subscribes to the event.
+func (h *HookConfig) Wants(event string) bool {
+	if len(h.Events) == 0 {
+		return true
+	}
+	return slices.Contains(h.Events, event)
+}
+
+// Notification providers.
+const (
+	NotifyNtfy     = "ntfy"
+	NotifyPushover = "pushover"
+	NotifyGotify   = "gotify"
+)
+
+// Notification priorities, mapped onto each provider's scale.
+const (
+	NotifyPriorityLow     = "low"
+	NotifyPriorityDefault = "default"
+	NotifyPriorityHigh    = "high"
+)
+
+// Default servers of the hosted providers.
+const (
+	DefaultNtfyURL     = "https://ntfy.sh"
+	DefaultPushoverURL = "https://api.pushover.net"
+)
+
+// NotificationConfig configures a push notification service notified of
+// schedule events.
+type NotificationConfig struct {
+	Name     string `mapstructure:"name"`
+	Provider string `mapstructure:"provider"`
+	// URL is the server; ntfy and Pushover default to the hosted services.
+	URL   string `mapstructure:"url"`
+	Topic string `mapstructure:"topic"` // ntfy
+	// Token is the ntfy access token (optional) or the Pushover or Gotify
+	// application token.
+	Token     string        `mapstructure:"token"`
+	User      string        `mapstructure:"user"`      // Pushover user or group key
+	Events    []string      `mapstructure:"events"`    // empty means all events
+	Schedules []string      `mapstructure:"schedules"` // empty means all schedules
+	Priority  string        `mapstructure:"priority"`  // empty picks one by event
+	Timeout   time.Duration `mapstructure:"timeout"`
+}
+
+// Validate checks the notification configuration.
+func (n *NotificationConfig) Validate() error {
+	if strings.TrimSpace(n.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	switch n.Provider {
+	case NotifyNtfy:
+		if n.Topic == "" {
+			return fmt.Errorf("topic is required for ntfy")
+		}
+	case NotifyPushover:
+		if n.Token == "" || n.User == "" {
+			return fmt.Errorf("token and user are required for pushover")
+		}
+	case NotifyGotify:
+		if n.URL == "" || n.Token == "" {
+			return fmt.Errorf("url and token are required for gotify")
+		}
+	default:
+		return fmt.Errorf("provider must be %s, %s or %s, got %q", NotifyNtfy, NotifyPushover, NotifyGotify, n.Provider)
+	}
+	if n.URL != "" {
+		u, err := url.Parse(n.URL)
+		if err != nil {
+			return fmt.Errorf("invalid url: %w", err)
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("url must be an absolute http or https URL, got %q", n.URL)
+		}
+	}
+	for _, event := range n.Events {
+		if !slices.Contains(hookEvents, event) {
+			return fmt.Errorf("unknown event %q", event)
+		}
+	}
+	switch n.Priority {
+	case "", NotifyPriorityLow, NotifyPriorityDefault, NotifyPriorityHigh:
+	default:
+		return fmt.Errorf("priority must be %s, %s or %s, got %q", NotifyPriorityLow, NotifyPriorityDefault, NotifyPriorityHigh, n.Priority)
+	}
+	if n.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative")
+	}
+	return nil
+}
+
+// ServerURL returns the provider's server without a trailing slash.
+func (n *NotificationConfig) ServerURL() string {
+	u := n.URL
+	if u == "" {
+		switch n.Provider {
+		case NotifyNtfy:
+			u = DefaultNtfyURL
+		case NotifyPushover:
+			u = DefaultPushoverURL
+		}
+	}
+	return strings.TrimSuffix(u, "/")
+}
+
+// Wants reports whether the notification subscribes to an event switching
+// to schedule.
+func (n *NotificationConfig) Wants(event, schedule string) bool {
+	if len(n.Events) > 0 && !slices.Contains(n.Events, event) {
+		return false
+	}
+	return len(n.Schedules) == 0 || slices.Contains(n.Schedules, schedule)
+}
+
+// GitSyncConfig configures pulling the configuration from a Git repository.
+type GitSyncConfig struct {
+	Enabled    bool          `mapstructure:"enabled"`
+	Repository string        `mapstructure:"repository"`
+	Branch     string        `mapstructure:"branch"`
+	Path       string        `mapstructure:"path"`      // config file within the repository
+	Directory  string        `mapstructure:"directory"` // local checkout; defaults to a temp directory
+	Interval   time.Duration `mapstructure:"interval"`
+}
+
+// minGitSyncInterval keeps the syncer from hammering the Git remote.
+const minGitSyncInterval = 10 * time.Second
+
+// Validate checks the Git sync configuration.
+func (g *GitSyncConfig) Validate() error {
+	if !g.Enabled {
+		return nil
+	}
+	if strings.TrimSpace(g.Repository) == "" {
+		return fmt.Errorf("repository is required")
+	}
+	if strings.TrimSpace(g.Branch) == "" {
+		return fmt.Errorf("branch is required")
+	}
+	if !filepath.IsLocal(g.Path) {
+		return fmt.Errorf("path must be a relative path inside the repository, got %q", g.Path)
+	}
+	if g.Interval < minGitSyncInterval {
+		return fmt.Errorf("interval must be at least %s", minGitSyncInterval)
+	}
+	return nil
+}
+
+// RemoteConfig configures loading the configuration from a key in a
+// key-value store instead of the local file.
+type RemoteConfig struct {
+	Provider string `mapstructure:"provider"` // consul or etcd; empty disables
+	Endpoint string `mapstructure:"endpoint"` // e.g. http://127.0.0.1:8500
+	Key      string `mapstructure:"key"`
+	Format   string `mapstructure:"format"`   // yaml or json
+	Token    string `mapstructure:"token"`    // Consul ACL token
+	Username string `mapstructure:"username"` // etcd authentication
+	Password string `mapstructure:"password"`
+}
+
+// Validate checks the remote configuration.
+func (r *RemoteConfig) Validate() error {
+	switch r.Provider {
+	case "":
+		return nil
+	case "consul", "etcd":
+	default:
+		return fmt.Errorf("provider must be consul or etcd, got %q", r.Provider)
+	}
+	u, err := url.Parse(r.Endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("endpoint must be an absolute http or https URL, got %q", r.Endpoint)
+	}
+	if strings.TrimSpace(r.Key) == "" {
+		return fmt.Errorf("key is required")
+	}
+	if r.Format != "yaml" && r.Format != "json" {
+		return fmt.Errorf("format must be yaml or json, got %q", r.Format)
+	}
+	return nil
+}
+
+// minAPITokenLength rejects tokens short enough to guess.
+const minAPITokenLength = 16
+
+// API token roles.
+const (
+	// RoleViewer may read from the admin API but not change anything.
+	RoleViewer = "viewer"
+	// RoleEditor may also change the schedule and start overrides.
+	RoleEditor = "editor"
+)
+
+// APIToken grants access to the admin API endpoints.
+type APIToken struct {
+	Name  string `mapstructure:"name"`
+	Token string `mapstructure:"token"`
+	// Role is RoleViewer or RoleEditor; empty means RoleEditor.
+	Role string `mapstructure:"role"`
+}
+
+// Validate checks the API token.
+func (t *APIToken) Validate() error {
+	if strings.TrimSpace(t.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(t.Token) < minAPITokenLength {
+		return fmt.Errorf("token must be at least %d characters", minAPITokenLength)
+	}
+	switch t.Role {
+	case "", RoleViewer, RoleEditor:
+	default:
+		return fmt.Errorf("role must be %q or %q", RoleViewer, RoleEditor)
+	}
+	return nil
+}
+
+// CanEdit reports whether the token may change the configuration and overrides.
+func (t *APIToken) CanEdit() bool {
+	return t.Role != RoleViewer
+}
+
+// ControlAlbum is an album offered on the household control page.
+type ControlAlbum struct {
 	Name  string `mapstructure:"name"`
 	Album string `mapstructure:"album"`
-	Start string `mapstructure:"start"` // Format: MM-DD
-	End   string `mapstructure:"end"`   // Format: MM-DD
+	// Duration is how long the album is shown; zero uses the default of three hours.
+	Duration time.Duration `mapstructure:"duration"`
+}
+
+// ControlConfig configures the household control page at /control.
+type ControlConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Username and Password protect the page with HTTP Basic Authentication.
+	Username string         `mapstructure:"username"`
+	Password string         `mapstructure:"password"`
+	Albums   []ControlAlbum `mapstructure:"albums"`
+}
+
+// Validate checks the control page configuration.
+func (c *ControlConfig) Validate() error {
+	if (c.Username == "") != (c.Password == "") {
+		return fmt.Errorf("username and password must be set together")
+	}
+	if !c.Enabled {
+		return nil
+	}
+	names := make(map[string]bool, len(c.Albums))
+	for i, a := range c.Albums {
+		if strings.TrimSpace(a.Name) == "" {
+			return fmt.Errorf("album %d: name is required", i)
+		}
+		if strings.TrimSpace(a.Album) == "" {
+			return fmt.Errorf("album %d (%s): album is required", i, a.Name)
+		}
+		if a.Duration < 0 {
+			return fmt.Errorf("album %d (%s): duration must not be negative", i, a.Name)
+		}
+		if names[a.Name] {
+			return fmt.Errorf("album name %q is used more than once", a.Name)
+		}
+		names[a.Name] = true
+	}
+	return nil
+}
+
+// PartyMode is a named override bundle: an album and kiosk parameters
+// applied to every kiosk for a limited time.
+type PartyMode struct {
+	Name string `mapstructure:"name"`
+	// Album replaces the scheduled album; empty keeps it.
+	Album string `mapstructure:"album"`
+	// Params are added to the redirect URL, e.g. faster transitions.
+	Params map[string]string `mapstructure:"params"`
+	// Duration is how long the mode lasts; zero uses the default of three hours.
+	Duration time.Duration `mapstructure:"duration"`
+}
+
+// Validate checks the party mode.
+func (p *PartyMode) Validate() error {
+	if strings.TrimSpace(p.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	if p.Album == "" && len(p.Params) == 0 {
+		return fmt.Errorf("album or params is required")
+	}
+	if p.Duration < 0 {
+		return fmt.Errorf("duration must not be negative")
+	}
+	for param := range p.Params {
+		if _, ok := SanitizeParam(param); !ok || param == "album" {
+			return fmt.Errorf("invalid param %q", param)
+		}
+	}
+	return nil
+}
+
+// DevicesConfig configures the monitoring of registered devices.
+type DevicesConfig struct {
+	// StaleAfter is how long a registered device may go without a redirect
+	// before device_stale hooks fire; zero disables the check for devices
+	// registered without their own interval.
+	StaleAfter time.Duration `mapstructure:"stale_after"`
+}
+
+// Validate checks the devices configuration.
+func (d *DevicesConfig) Validate() error {
+	if d.StaleAfter < 0 {
+		return fmt.Errorf("stale_after must not be negative")
+	}
+	if d.StaleAfter > 0 && d.StaleAfter < time.Minute {
+		return fmt.Errorf("stale_after must be at least 1m, got %s", d.StaleAfter)
+	}
+	return nil
+}
+
+// DefaultShutdownTimeout is how long shutdown waits when shutdown.timeout
+// is not set.
+const DefaultShutdownTimeout = 10 * time.Second
+
+// ShutdownConfig configures graceful shutdown.
+type ShutdownConfig struct {
+	// Timeout bounds how long shutdown waits for in-flight requests to
+	// finish and for pending hooks, emails and notifications to be sent.
+	Timeout time.Duration `mapstructure:"timeout"`
+	// FlushTimeout is the part of Timeout kept for sending pending
+	// deliveries, which slow requests cannot use up.
+	FlushTimeout time.Duration `mapstructure:"flush_timeout"`
+}
+
+// Validate checks the shutdown configuration.
+func (s *ShutdownConfig) Validate() error {
+	if s.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative")
+	}
+	if s.FlushTimeout < 0 {
+		return fmt.Errorf("flush_timeout must not be negative")
+	}
+	if s.FlushTimeout >= s.TotalTimeout() {
+		return fmt.Errorf("flush_timeout must be less than timeout (%s)", s.TotalTimeout())
+	}
+	return nil
+}
+
+// TotalTimeout returns how long shutdown waits for work to finish.
+func (s *ShutdownConfig) TotalTimeout() time.Duration {
+	if s.Timeout == 0 {
+		return DefaultShutdownTimeout
+	}
+	return s.Timeout
+}
+
+// DrainTimeout returns how long shutdown waits for in-flight requests: the
+// timeout less the flush timeout, or the whole timeout when no flush
+// timeout is set, leaving deliveries whatever remains of it.
+func (s *ShutdownConfig) DrainTimeout() time.Duration {
+	return s.TotalTimeout() - s.FlushTimeout
+}
+
+// TransitionsConfig damps transitions between selections, so conditions,
+// decision services or overrides changing rapidly do not make displays
+// thrash between albums.
+type TransitionsConfig struct {
+	// Cooldown is the least time between two transitions.
+	Cooldown time.Duration `mapstructure:"cooldown"`
+	// Debounce is how long a new selection must last before it is shown.
+	Debounce time.Duration `mapstructure:"debounce"`
+}
+
+// Validate checks the transitions configuration.
+func (t *TransitionsConfig) Validate() error {
+	if t.Cooldown < 0 {
+		return fmt.Errorf("cooldown must not be negative")
+	}
+	if t.Debounce < 0 {
+		return fmt.Errorf("debounce must not be negative")
+	}
+	return nil
+}
+
+// Enabled reports whether transitions are damped.
+func (t *TransitionsConfig) Enabled() bool {
+	return t.Cooldown > 0 || t.Debounce > 0
+}
+
+// ShadowConfig evaluates a candidate configuration alongside the active one:
+// every redirect also records what the candidate would have served, without
+// serving it.
+type ShadowConfig struct {
+	// Config is the path of the candidate configuration file; empty
+	// disables shadow evaluation.
+	Config string `mapstructure:"config"`
+}
+
+// DeviceProfile overrides the passthrough rules and default params for the
+// displays identified by the device query parameter, e.g. an e-ink frame
+// needing other params than a TV.
+type DeviceProfile struct {
+	Name string `mapstructure:"name"`
+	// Devices lists the device identifiers the profile applies to.
+	Devices []string `mapstructure:"devices"`
+	// PassthroughMode, PassthroughParams and BlockedParams replace the
+	// top-level passthrough rules when any of them is set.
+	PassthroughMode   string   `mapstructure:"passthrough_mode"`
+	PassthroughParams []string `mapstructure:"passthrough_params"`
+	BlockedParams     []string `mapstructure:"blocked_params"`
+	// DefaultParams are merged over the top-level default_params.
+	DefaultParams map[string]string `mapstructure:"default_params"`
+}
+
+// OverridesPassthrough reports whether the profile replaces the top-level
+// passthrough rules.
+func (p *DeviceProfile) OverridesPassthrough() bool {
+	return p.PassthroughMode != "" || len(p.PassthroughParams) > 0 || len(p.BlockedParams) > 0
+}
+
+// Validate checks the device profile.
+func (p *DeviceProfile) Validate() error {
+	if strings.TrimSpace(p.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(p.Devices) == 0 {
+		return fmt.Errorf("devices is required")
+	}
+	if slices.ContainsFunc(p.Devices, func(d string) bool { return strings.TrimSpace(d) == "" }) {
+		return fmt.Errorf("devices must not contain empty identifiers")
+	}
+	switch p.PassthroughMode {
+	case "", PassthroughAllowlist, PassthroughAllExcept:
+	default:
+		return fmt.Errorf("passthrough_mode must be %s or %s, got %q", PassthroughAllowlist, PassthroughAllExcept, p.PassthroughMode)
+	}
+	for param := range p.DefaultParams {
+		if _, ok := SanitizeParam(param); !ok || param == "album" {
+			return fmt.Errorf("default_params: invalid param %q", param)
+		}
+	}
+	return nil
+}
+
+// Profile is a named schedule list. One profile is active at a time and it
+// can be switched at runtime, e.g. to "grandparents-visiting".
+type Profile struct {
+	Name     string          `mapstructure:"name" json:"name"`
+	Schedule []ScheduleEntry `mapstructure:"schedule" json:"schedule"`
+}
+
+// kioskNameRegex matches kiosk names, which are used in URL paths.
+var kioskNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Kiosk is a named display with its own kiosk URL, default album,
+// passthrough params and schedule, served under /kiosks/{name}. Unset
+// fields use the top-level values; default params are merged with them.
+type Kiosk struct {
+	Name              string            `mapstructure:"name"`
+	KioskURL          string            `mapstructure:"kiosk_url"`
+	DefaultAlbum      string            `mapstructure:"default_album"`
+	PassthroughParams []string          `mapstructure:"passthrough_params"`
+	DefaultParams     map[string]string `mapstructure:"default_params"`
+	// Schedule replaces the top-level schedule, or the active profile's,
+	// when set.
+	Schedule []ScheduleEntry `mapstructure:"schedule"`
+}
+
+// minGuestLinkSecretLength keeps guest link signatures from being forged by
+// guessing the key.
+const minGuestLinkSecretLength = 32
+
+// GuestLinksConfig configures signed guest override links. Links are
+// enabled when a secret is set.
+type GuestLinksConfig struct {
+	Secret string `mapstructure:"secret"`
+	// BaseURL is the public URL of the server used in generated links;
+	// empty derives it from the request.
+	BaseURL string `mapstructure:"base_url"`
+	// MaxValidity caps how long a generated link stays valid.
+	MaxValidity time.Duration `mapstructure:"max_validity"`
+}
+
+// Enabled reports whether guest links are accepted.
+func (g *GuestLinksConfig) Enabled() bool {
+	return g.Secret != ""
+}
+
+// Validate checks the guest links configuration.
+func (g *GuestLinksConfig) Validate() error {
+	if !g.Enabled() {
+		return nil
+	}
+	if len(g.Secret) < minGuestLinkSecretLength {
+		return fmt.Errorf("secret must be at least %d characters", minGuestLinkSecretLength)
+	}
+	if g.BaseURL != "" {
+		u, err := url.Parse(g.BaseURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("base_url must be an http or https URL")
+		}
+	}
+	if g.MaxValidity <= 0 {
+		return fmt.Errorf("max_validity must be positive")
+	}
+	return nil
+}
+
+// MaintenanceConfig makes the redirect endpoint answer with a maintenance
+// page instead of redirecting, e.g. while Immich is being migrated.
+type MaintenanceConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Message is shown on the maintenance page; empty uses a default text.
+	Message string `mapstructure:"message"`
+	// RetryAfter is sent in the Retry-After header; zero uses five minutes.
+	RetryAfter time.Duration `mapstructure:"retry_after"`
+}
+
+// Validate checks the maintenance configuration.
+func (m *MaintenanceConfig) Validate() error {
+	if m.RetryAfter < 0 {
+		return fmt.Errorf("retry_after must not be negative")
+	}
+	return nil
+}
+
+// QuietHoursNoAlbum as the quiet hours album serves a blank page.
+const QuietHoursNoAlbum = "none"
+
+// QuietHoursConfig replaces the schedule with a night album or a blank page
+// during a daily time window, so wall displays do not cycle bright photos at
+// night.
+type QuietHoursConfig struct {
+	Start string `mapstructure:"start"` // HH:MM
+	End   string `mapstructure:"end"`   // HH:MM; before Start to span midnight
+	// Album is shown during quiet hours; empty or "none" serves a blank page.
+	Album string `mapstructure:"album"`
+}
+
+// Enabled reports whether quiet hours are configured.
+func (q *QuietHoursConfig) Enabled() bool {
+	return q.Start != "" || q.End != ""
+}
+
+// Validate checks the quiet hours configuration.
+func (q *QuietHoursConfig) Validate() error {
+	if !q.Enabled() {
+		if q.Album != "" {
+			return fmt.Errorf("start and end are required")
+		}
+		return nil
+	}
+	return validateClockWindow(q.Start, q.End)
+}
+
+// NightAlbum returns the album shown during quiet hours, or "" for a blank page.
+func (q *QuietHoursConfig) NightAlbum() string {
+	if q.Album == QuietHoursNoAlbum {
+		return ""
+	}
+	return q.Album
+}
+
+// Window reports whether t falls within quiet hours, evaluated in t's
+// location, and if so when they end.
+func (q *QuietHoursConfig) Window(t time.Time) (time.Time, bool) {
+	if !q.Enabled() {
+		return time.Time{}, false
+	}
+	return clockWindow(q.Start, q.End, t)
+}
+
+// NightParams are kiosk parameters added to redirects during a daily time
+// window without changing the album, e.g. a lower brightness at night.
+type NightParams struct {
+	Start  string            `mapstructure:"start"` // HH:MM
+	End    string            `mapstructure:"end"`   // HH:MM; before Start to span midnight
+	Params map[string]string `mapstructure:"params"`
+}
+
+// Validate checks the night window.
+func (n *NightParams) Validate() error {
+	if err := validateClockWindow(n.Start, n.End); err != nil {
+		return err
+	}
+	if len(n.Params) == 0 {
+		return fmt.Errorf("params is required")
+	}
+	for param := range n.Params {
+		if _, ok := SanitizeParam(param); !ok || param == "album" {
+			return fmt.Errorf("invalid param %q", param)
+		}
+	}
+	return nil
+}
+
+// NightParamsAt returns the params of the night windows that include t,
+// merged in order so later windows win, or nil outside all of them.
+func (c *Config) NightParamsAt(t time.Time) map[string]string {
+	var params map[string]string
+	for _, n := range c.NightParams {
+		if _, ok := clockWindow(n.Start, n.End, t); !ok {
+			continue
+		}
+		if params == nil {
+			params = make(map[string]string, len(n.Params))
+		}
+		maps.Copy(params, n.Params)
+	}
+	return params
+}
+
+// validateClockWindow checks the HH:MM bounds of a daily window.
+func validateClockWindow(startClock, endClock string) error {
+	start, err := parseClock(startClock)
+	if err != nil {
+		return fmt.Errorf("invalid start: %w", err)
+	}
+	end, err := parseClock(endClock)
+	if err != nil {
+		return fmt.Errorf("invalid end: %w", err)
+	}
+	if start == end {
+		return fmt.Errorf("start and end must differ")
+	}
+	return nil
+}
+
+// clockWindow reports whether t falls within the daily window from
+// startClock to endClock, evaluated in t's location, and if so when the
+// window ends.
+func clockWindow(startClock, endClock string, t time.Time) (time.Time, bool) {
+	start, err := parseClock(startClock)
+	if err != nil {
+		return time.Time{}, false
+	}
+	end, err := parseClock(endClock)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	minute := t.Hour()*60 + t.Minute()
+	var days int // days from t's date to the end of the window
+	switch {
+	case start < end && minute >= start && minute < end:
+	case start > end && minute >= start:
+		days = 1
+	case start > end && minute < end:
+	default:
+		return time.Time{}, false
+	}
+	return time.Date(t.Year(), t.Month(), t.Day()+days, end/60, end%60, 0, 0, t.Location()), true
+}
+
+// parseClock parses an HH:MM time of day into minutes after midnight.
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a time of day, expected HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// PageTemplate is a custom html/template page, read from File or given
+// inline as HTML. ${...} in HTML, e.g. in its CSS, is kept as written and
+// not expanded from the environment.
+type PageTemplate struct {
+	File string  `mapstructure:"file"`
+	HTML Literal `mapstructure:"html"`
+}
+
+// IsSet reports whether a custom page is configured.
+func (p *PageTemplate) IsSet() bool {
+	return p.File != "" || p.HTML != ""
+}
+
+// PagesConfig replaces the built-in pages served to displays.
+type PagesConfig struct {
+	Maintenance PageTemplate `mapstructure:"maintenance"`
+	Error       PageTemplate `mapstructure:"error"`
+	NotFound    PageTemplate `mapstructure:"not_found"`
+	QuietHours  PageTemplate `mapstructure:"quiet_hours"`
+}
+
+// Validate checks the custom pages.
+func (p *PagesConfig) Validate() error {
+	pages := []struct {
+		name string
+		page PageTemplate
+	}{{"maintenance", p.Maintenance}, {"error", p.Error}, {"not_found", p.NotFound}, {"quiet_hours", p.QuietHours}}
+	for _, p := range pages {
+		if p.page.File != "" && p.page.HTML != "" {
+			return fmt.Errorf("%s: file and html are mutually exclusive", p.name)
+		}
+	}
+	return nil
+}
+
+// InfoPageConfig configures the informational page served on / instead of
+// a redirect.
+type InfoPageConfig struct {
+	// KioskUserAgents lists User-Agent substrings of the displays. When set,
+	// requests to / from any other User-Agent get the info page.
+	KioskUserAgents []string `mapstructure:"kiosk_user_agents"`
+}
+
+// LoopProtectionConfig configures the detection of a kiosk_url that leads
+// back to the scheduler.
+type LoopProtectionConfig struct {
+	// MarkerHeader names the response header carrying the scheduler's
+	// instance ID, which the startup probe of kiosk_url looks for.
+	MarkerHeader string `mapstructure:"marker_header"`
+	// Probe requests kiosk_url at startup and after it changes.
+	Probe bool `mapstructure:"probe"`
+}
+
+// DefaultForwardAuthTokenParam is the query parameter carrying a forward-auth
+// token when forward_auth.token_param is not set.
+const DefaultForwardAuthTokenParam = "token"
+
+// ForwardAuthToken grants access to the kiosk path via the forward-auth endpoint.
+type ForwardAuthToken struct {
+	Name  string `mapstructure:"name"`
+	Token string `mapstructure:"token"`
+}
+
+// ForwardAuthConfig configures the /auth/verify endpoint used by reverse
+// proxies (Traefik, Caddy) to authorize kiosk requests.
+type ForwardAuthConfig struct {
+	Enabled bool               `mapstructure:"enabled"`
+	Tokens  []ForwardAuthToken `mapstructure:"tokens"`
+	// AllowedNetworks lists IP addresses and CIDR ranges allowed without a token.
+	AllowedNetworks []string `mapstructure:"allowed_networks"`
+	// TrustedProxies lists the addresses and CIDR ranges of the reverse
+	// proxies whose forwarding headers carry the client address. Requests
+	// from other peers are checked against their own address.
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
+	// TokenParam names the query parameter of the original request that may
+	// carry the token, for displays that cannot send headers.
+	TokenParam string `mapstructure:"token_param"`
+}
+
+// Validate checks the forward-auth configuration.
+func (f *ForwardAuthConfig) Validate() error {
+	if _, err := f.Networks(); err != nil {
+		return err
+	}
+	if _, err := f.Proxies(); err != nil {
+		return err
+	}
+	if f.TokenParam != "" && !paramRegex.MatchString(f.TokenParam) {
+		return fmt.Errorf("invalid token_param %q", f.TokenParam)
+	}
+	names := make(map[string]bool, len(f.Tokens))
+	for i, t := range f.Tokens {
+		if strings.TrimSpace(t.Name) == "" {
+			return fmt.Errorf("token %d: name is required", i)
+		}
+		if len(t.Token) < minAPITokenLength {
+			return fmt.Errorf("token %d (%s): token must be at least %d characters", i, t.Name, minAPITokenLength)
+		}
+		if names[t.Name] {
+			return fmt.Errorf("token name %q is used more than once", t.Name)
+		}
+		names[t.Name] = true
+	}
+	if f.Enabled && len(f.Tokens) == 0 && len(f.AllowedNetworks) == 0 {
+		return fmt.Errorf("at least one token or allowed network is required")
+	}
+	return nil
+}
+
+// Networks parses AllowedNetworks; single addresses become /32 or /128 prefixes.
+func (f *ForwardAuthConfig) Networks() ([]netip.Prefix, error) {
+	return parsePrefixes(f.AllowedNetworks, "allowed network")
+}
+
+// Proxies parses TrustedProxies like Networks.
+func (f *ForwardAuthConfig) Proxies() ([]netip.Prefix, error) {
+	return parsePrefixes(f.TrustedProxies, "trusted proxy")
+}
+
+// parsePrefixes parses addresses and CIDR ranges; single addresses become
+// /32 or /128 prefixes. kind names the list in errors.
+func parsePrefixes(networks []string, kind string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(networks))
+	for _, n := range networks {
+		n = strings.TrimSpace(n)
+		if addr, err := netip.ParseAddr(n); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(n)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q", kind, n)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// Param returns the query parameter carrying a token.
+func (f *ForwardAuthConfig) Param() string {
+	if f.TokenParam == "" {
+		return DefaultForwardAuthTokenParam
+	}
+	return f.TokenParam
+}
+
+// DefaultKioskAuthParam is Immich Kiosk's password query parameter, used
+// when kiosk_auth.param is not set.
+const DefaultKioskAuthParam = "password"
+
+// headerNameRegex matches valid HTTP header names.
+var headerNameRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9-]*$`)
+
+// KioskAuthConfig supplies the kiosk's password, so displays do not need to
+// know it.
+type KioskAuthConfig struct {
+	Password string `mapstructure:"password"`
+	// Param is the query parameter added to redirects.
+	Param string `mapstructure:"param"`
+	// Header, instead of the query parameter, carries the password in
+	// forward-auth responses, for proxies that copy it to the kiosk request.
+	Header string `mapstructure:"header"`
+}
+
+// Validate checks the kiosk credential configuration.
+func (k *KioskAuthConfig) Validate() error {
+	if k.Param != "" && !paramRegex.MatchString(k.Param) {
+		return fmt.Errorf("invalid param %q", k.Param)
+	}
+	if k.Header != "" && !headerNameRegex.MatchString(k.Header) {
+		return fmt.Errorf("invalid header %q", k.Header)
+	}
+	return nil
+}
+
+// QueryParam returns the query parameter carrying the password in
+// redirects, or "" when no password is added to redirects.
+func (k *KioskAuthConfig) QueryParam() string {
+	if k.Password == "" || k.Header != "" {
+		return ""
+	}
+	if k.Param == "" {
+		return DefaultKioskAuthParam
+	}
+	return k.Param
+}
+
+// Cache-busting modes of CacheBustConfig.
+const (
+	CacheBustTimestamp = "timestamp"
+	CacheBustRevision  = "revision"
+)
+
+// DefaultCacheBustParam is the query parameter cache_bust adds when
+// cache_bust.param is not set.
+const DefaultCacheBustParam = "_ts"
+
+// CacheBustConfig adds a query parameter to redirects, so caches and kiosk
+// browsers that hold on to a URL load a new one when the schedule changes.
+type CacheBustConfig struct {
+	// Mode is CacheBustTimestamp for the Unix time of each redirect,
+	// CacheBustRevision for a value that changes only with the
+	// configuration and the selected schedule and album, or empty.
+	Mode  string `mapstructure:"mode"`
+	Param string `mapstructure:"param"`
+}
+
+// Validate checks the cache-busting configuration.
+func (c *CacheBustConfig) Validate() error {
+	if c.Mode != "" && c.Mode != CacheBustTimestamp && c.Mode != CacheBustRevision {
+		return fmt.Errorf("invalid mode %q, expected %s or %s", c.Mode, CacheBustTimestamp, CacheBustRevision)
+	}
+	if c.Param != "" && !paramRegex.MatchString(c.Param) {
+		return fmt.Errorf("invalid param %q", c.Param)
+	}
+	return nil
+}
+
+// QueryParam returns the query parameter added to redirects, or "" when
+// cache busting is off.
+func (c *CacheBustConfig) QueryParam() string {
+	if c.Mode == "" {
+		return ""
+	}
+	if c.Param == "" {
+		return DefaultCacheBustParam
+	}
+	return c.Param
+}
+
+// KioskConfigAlbumsKey is the Immich Kiosk setting listing the albums to
+// show, which the /kiosk-config.yaml document sets to the scheduled album.
+const KioskConfigAlbumsKey = "albums"
+
+// KioskConfigConfig serves /kiosk-config.yaml, an Immich Kiosk configuration
+// document with the scheduled album, for Kiosk deployments that load their
+// configuration from a URL instead of following redirects.
+type KioskConfigConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Settings are the other Kiosk settings of the document, e.g. duration.
+	Settings map[string]any `mapstructure:"settings"`
+}
+
+// Validate checks the Kiosk configuration document settings.
+func (k *KioskConfigConfig) Validate() error {
+	for key := range k.Settings {
+		if strings.EqualFold(key, KioskConfigAlbumsKey) || strings.EqualFold(key, "album") {
+			return fmt.Errorf("settings must not set %q, the scheduler sets the albums", key)
+		}
+	}
+	return nil
+}
+
+// Default redirect signing parameters.
+const (
+	DefaultSigningParam          = "sig"
+	DefaultSigningTimestampParam = "ts"
+)
+
+// SigningConfig signs redirects, so a proxy in front of the kiosk can reject
+// URLs not generated by the scheduler. Signing is enabled when a secret is set.
+type SigningConfig struct {
+	Secret string `mapstructure:"secret"`
+	// Param names the signature query parameter.
+	Param string `mapstructure:"param"`
+	// TimestampParam names the query parameter holding the signing time.
+	TimestampParam string `mapstructure:"timestamp_param"`
+}
+
+// Enabled reports whether redirects are signed.
+func (s *SigningConfig) Enabled() bool {
+	return s.Secret != ""
+}
+
+// Validate checks the signing configuration.
+func (s *SigningConfig) Validate() error {
+	if !s.Enabled() {
+		return nil
+	}
+	if len(s.Secret) < minGuestLinkSecretLength {
+		return fmt.Errorf("secret must be at least %d characters", minGuestLinkSecretLength)
+	}
+	if s.Param != "" && !paramRegex.MatchString(s.Param) {
+		return fmt.Errorf("invalid param %q", s.Param)
+	}
+	if s.TimestampParam != "" && !paramRegex.MatchString(s.TimestampParam) {
+		return fmt.Errorf("invalid timestamp_param %q", s.TimestampParam)
+	}
+	if s.SignatureParam() == s.TimestampParamName() {
+		return fmt.Errorf("param and timestamp_param must differ")
+	}
+	return nil
+}
+
+// SignatureParam returns the signature query parameter.
+func (s *SigningConfig) SignatureParam() string {
+	if s.Param == "" {
+		return DefaultSigningParam
+	}
+	return s.Param
+}
+
+// TimestampParamName returns the timestamp query parameter.
+func (s *SigningConfig) TimestampParamName() string {
+	if s.TimestampParam == "" {
+		return DefaultSigningTimestampParam
+	}
+	return s.TimestampParam
+}
+
+// Immich defaults, used when timeout or sync_interval is not set.
+const (
+	DefaultImmichTimeout      = 10 * time.Second
+	DefaultImmichSyncInterval = time.Hour
+)
+
+// minImmichSyncInterval keeps the album cache from hammering Immich.
+const minImmichSyncInterval = time.Minute
+
+// DefaultStatsRetentionDays is how many days of statistics are kept when
+// stats.retention_days is not set.
+const DefaultStatsRetentionDays = 365
+
+// StatsConfig configures the daily statistics of redirects and active
+// schedules kept in the state store.
+type StatsConfig struct {
+	// RetentionDays is how many days of statistics are kept, including today.
+	RetentionDays int `mapstructure:"retention_days"`
+}
+
+// Validate checks the statistics configuration.
+func (s *StatsConfig) Validate() error {
+	if s.RetentionDays < 0 {
+		return fmt.Errorf("retention_days must not be negative")
+	}
+	return nil
+}
+
+// Retention returns how many days of statistics are kept.
+func (s *StatsConfig) Retention() int {
+	if s.RetentionDays == 0 {
+		return DefaultStatsRetentionDays
+	}
+	return s.RetentionDays
+}
+
+// ImmichConfig configures access to the Immich API, used to check and
+// resolve the albums referenced by the configuration.
+type ImmichConfig struct {
+	URL string `mapstructure:"url"` // e.g. http://immich.local:2283; empty disables
+	// APIKey is an Immich API key with read access to albums, people, tags
+	// and search.
+	APIKey  string        `mapstructure:"api_key"`
+	Timeout time.Duration `mapstructure:"timeout"`
+	// SyncInterval is how often the album names shown in the UI and status
+	// API are refreshed.
+	SyncInterval time.Duration `mapstructure:"sync_interval"`
+	// Discovery adds a schedule entry for every album named like
+	// "Christmas [12-01..12-31]".
+	Discovery bool `mapstructure:"discovery"`
+}
+
+// Validate checks the Immich configuration.
+func (i *ImmichConfig) Validate() error {
+	if i.URL == "" {
+		if i.Discovery {
+			return fmt.Errorf("discovery requires url")
+		}
+		return nil
+	}
+	u, err := url.Parse(i.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an absolute http or https URL, got %q", i.URL)
+	}
+	if strings.TrimSpace(i.APIKey) == "" {
+		return fmt.Errorf("api_key is required")
+	}
+	if i.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative")
+	}
+	if i.SyncInterval != 0 && i.SyncInterval < minImmichSyncInterval {
+		return fmt.Errorf("sync_interval must be at least %s", minImmichSyncInterval)
+	}
+	return nil
+}
+
+// RequestTimeout returns the timeout of a single Immich API request.
+func (i *ImmichConfig) RequestTimeout() time.Duration {
+	if i.Timeout == 0 {
+		return DefaultImmichTimeout
+	}
+	return i.Timeout
+}
+
+// AlbumSyncInterval returns how often the album cache is refreshed.
+func (i *ImmichConfig) AlbumSyncInterval() time.Duration {
+	if i.SyncInterval == 0 {
+		return DefaultImmichSyncInterval
+	}
+	return i.SyncInterval
+}
+
+// DefaultHomeAssistantInterval is how often Home Assistant is polled when
+// home_assistant.interval is not set.
+const DefaultHomeAssistantInterval = 30 * time.Second
+
+// minHomeAssistantInterval keeps the poller from hammering Home Assistant.
+const minHomeAssistantInterval = 5 * time.Second
+
+// HomeAssistantConfig configures reading entity states from Home Assistant
+// for when conditions such as ha["person.alex"] == "home".
+type HomeAssistantConfig struct {
+	URL string `mapstructure:"url"` // e.g. http://homeassistant.local:8123; empty disables
+	// Token is a long-lived access token.
+	Token    string        `mapstructure:"token"`
+	Interval time.Duration `mapstructure:"interval"`
+}
+
+// Validate checks the Home Assistant configuration.
+func (h *HomeAssistantConfig) Validate() error {
+	if h.URL == "" {
+		return nil
+	}
+	u, err := url.Parse(h.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an absolute http or https URL, got %q", h.URL)
+	}
+	if strings.TrimSpace(h.Token) == "" {
+		return fmt.Errorf("token is required")
+	}
+	if h.Interval != 0 && h.Interval < minHomeAssistantInterval {
+		return fmt.Errorf("interval must be at least %s", minHomeAssistantInterval)
+	}
+	return nil
+}
+
+// PollInterval returns how often entity states are polled.
+func (h *HomeAssistantConfig) PollInterval() time.Duration {
+	if h.Interval == 0 {
+		return DefaultHomeAssistantInterval
+	}
+	return h.Interval
+}
+
+// SMTP connection security modes.
+const (
+	SMTPStartTLS = "starttls"
+	SMTPTLS      = "tls"
+	SMTPNone     = "none"
+)
+
+// Email defaults, used when smtp.port or smtp.tls is not set.
+const (
+	DefaultSMTPPort = 587
+	DefaultSMTPTLS  = SMTPStartTLS
+)
+
+// EmailEventUpcoming notifies of the next transition ahead of time; email
+// rules also accept the hook events.
+const EmailEventUpcoming = "upcoming"
+
+// EmailTemplateFuncs are the functions available to email templates.
+var EmailTemplateFuncs = template.FuncMap{"join": strings.Join}
+
+// SMTPConfig configures the mail server notification emails are sent through.
+type SMTPConfig struct {
+	Host     string `mapstructure:"host"`
+	Port     int    `mapstructure:"port"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	// TLS is SMTPStartTLS, SMTPTLS for implicit TLS, usually on port 465,
+	// or SMTPNone for local relays.
+	TLS string `mapstructure:"tls"`
+}
+
+// Address returns the host:port of the mail server.
+func (s *SMTPConfig) Address() string {
+	port := s.Port
+	if port == 0 {
+		port = DefaultSMTPPort
+	}
+	return net.JoinHostPort(s.Host, strconv.Itoa(port))
+}
+
+// Security returns the connection security mode.
+func (s *SMTPConfig) Security() string {
+	if s.TLS == "" {
+		return DefaultSMTPTLS
+	}
+	return s.TLS
+}
+
+// EmailRule selects the events an email is sent for.
+type EmailRule struct {
+	Name string `mapstructure:"name"`
+	// Event is EmailEventUpcoming or a hook event such as transition or
+	// device_stale.
+	Event string `mapstructure:"event"`
+	// Before is how long ahead of a transition upcoming emails are sent.
+	Before time.Duration `mapstructure:"before"`
+	// Schedules limits the rule to transitions to these schedules; empty
+	// matches all.
+	Schedules []string `mapstructure:"schedules"`
+	// To replaces the default recipients.
+	To []string `mapstructure:"to"`
+	// Subject and Body are text/template templates; empty uses the
+	// defaults for the event.
+	Subject string `mapstructure:"subject"`
+	Body    string `mapstructure:"body"`
+}
+
+// Validate checks the email rule.
+func (r *EmailRule) Validate() error {
+	if strings.TrimSpace(r.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	switch {
+	case r.Event == EmailEventUpcoming:
+		if r.Before <= 0 {
+			return fmt.Errorf("before is required for upcoming events")
+		}
+	case slices.Contains(hookEvents, r.Event):
+		if r.Before != 0 {
+			return fmt.Errorf("before only applies to upcoming events")
+		}
+	default:
+		return fmt.Errorf("event must be %s or one of %s, got %q", EmailEventUpcoming, strings.Join(hookEvents, ", "), r.Event)
+	}
+	for _, to := range r.To {
+		if _, err := mail.ParseAddress(to); err != nil {
+			return fmt.Errorf("invalid recipient %q: %w", to, err)
+		}
+	}
+	if _, err := template.New("subject").Funcs(EmailTemplateFuncs).Parse(r.Subject); err != nil {
+		return fmt.Errorf("subject: %w", err)
+	}
+	if _, err := template.New("body").Funcs(EmailTemplateFuncs).Parse(r.Body); err != nil {
+		return fmt.Errorf("body: %w", err)
+	}
+	return nil
+}
+
+// DefaultTelegramURL is the Telegram Bot API server.
+const DefaultTelegramURL = "https://api.telegram.org"
+
+// TelegramConfig configures the Telegram bot controlling the schedule.
+type TelegramConfig struct {
+	// Token is the bot token from @BotFather; empty disables the bot.
+	Token string `mapstructure:"token"`
+	// AllowedChats are the chat IDs the bot answers commands from.
+	AllowedChats []int64 `mapstructure:"allowed_chats"`
+	// URL is the Bot API server, for self-hosted ones.
+	URL string `mapstructure:"url"`
+}
+
+// Enabled reports whether the bot is configured.
+func (t *TelegramConfig) Enabled() bool {
+	return t.Token != ""
+}
+
+// Validate checks the Telegram configuration.
+func (t *TelegramConfig) Validate() error {
+	if !t.Enabled() {
+		return nil
+	}
+	if len(t.AllowedChats) == 0 {
+		return fmt.Errorf("allowed_chats is required, or anyone could control the kiosk")
+	}
+	if t.URL != "" {
+		u, err := url.Parse(t.URL)
+		if err != nil {
+			return fmt.Errorf("invalid url: %w", err)
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("url must be an absolute http or https URL, got %q", t.URL)
+		}
+	}
+	return nil
+}
+
+// APIURL returns the Bot API server without a trailing slash.
+func (t *TelegramConfig) APIURL() string {
+	if t.URL == "" {
+		return DefaultTelegramURL
+	}
+	return strings.TrimSuffix(t.URL, "/")
+}
+
+// Allowed reports whether the bot answers commands from a chat.
+func (t *TelegramConfig) Allowed(chat int64) bool {
+	return slices.Contains(t.AllowedChats, chat)
+}
+
+// SlashCommandsConfig configures the Slack and Discord slash command
+// endpoints, which run the same commands as the Telegram bot.
+type SlashCommandsConfig struct {
+	// SlackSigningSecret verifies requests to /chat/slack; empty disables
+	// the endpoint.
+	SlackSigningSecret string `mapstructure:"slack_signing_secret"`
+	// DiscordPublicKey is the application's hex-encoded Ed25519 public key
+	// verifying requests to /chat/discord; empty disables the endpoint.
+	DiscordPublicKey string `mapstructure:"discord_public_key"`
+}
+
+// Validate checks the slash command configuration.
+func (s *SlashCommandsConfig) Validate() error {
+	if s.DiscordPublicKey != "" {
+		if _, err := s.DiscordKey(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DiscordKey decodes the Discord public key.
+func (s *SlashCommandsConfig) DiscordKey() (ed25519.PublicKey, error) {
+	key, err := hex.DecodeString(s.DiscordPublicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("discord_public_key must be %d hex-encoded bytes", ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// EmailConfig configures email notifications of schedule and device events.
+type EmailConfig struct {
+	SMTP SMTPConfig `mapstructure:"smtp"`
+	From string     `mapstructure:"from"`
+	// To are the recipients of rules without their own.
+	To    []string    `mapstructure:"to"`
+	Rules []EmailRule `mapstructure:"rules"`
+}
+
+// Enabled reports whether emails are sent.
+func (e *EmailConfig) Enabled() bool {
+	return e.SMTP.Host != "" && len(e.Rules) > 0
+}
+
+// Validate checks the email configuration.
+func (e *EmailConfig) Validate() error {
+	if e.SMTP.Host == "" {
+		if len(e.Rules) > 0 {
+			return fmt.Errorf("smtp.host is required for rules")
+		}
+		return nil
+	}
+	if e.SMTP.Port < 0 || e.SMTP.Port > 65535 {
+		return fmt.Errorf("smtp.port must be between 1 and 65535, got %d", e.SMTP.Port)
+	}
+	switch e.SMTP.TLS {
+	case "", SMTPStartTLS, SMTPTLS, SMTPNone:
+	default:
+		return fmt.Errorf("smtp.tls must be %s, %s or %s, got %q", SMTPStartTLS, SMTPTLS, SMTPNone, e.SMTP.TLS)
+	}
+	if e.SMTP.Password != "" && e.SMTP.Username == "" {
+		return fmt.Errorf("smtp.username is required with smtp.password")
+	}
+	if _, err := mail.ParseAddress(e.From); err != nil {
+		return fmt.Errorf("invalid from %q: %w", e.From, err)
+	}
+	for _, to := range e.To {
+		if _, err := mail.ParseAddress(to); err != nil {
+			return fmt.Errorf("invalid recipient %q: %w", to, err)
+		}
+	}
+	names := make(map[string]bool, len(e.Rules))
+	for i, rule := range e.Rules {
+		if err := rule.Validate(); err != nil {
+			return fmt.Errorf("rule %d (%s): %w", i, rule.Name, err)
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("rule name %q is used more than once", rule.Name)
+		}
+		names[rule.Name] = true
+		if len(rule.To) == 0 && len(e.To) == 0 {
+			return fmt.Errorf("rule %d (%s): no recipients, set to", i, rule.Name)
+		}
+	}
+	return nil
+}
+
+// MQTT defaults, used when client_id or keep_alive is not set.
+const (
+	DefaultMQTTClientID  = "immich-kiosk-scheduler"
+	DefaultMQTTKeepAlive = 30 * time.Second
+)
+
+// MQTTConfig configures the MQTT broker connection. Retained and published
+// topic values are available to when conditions such as
+// mqtt["home/mode"] == "guest".
+type MQTTConfig struct {
+	// Broker is the broker address: tcp://host:1883, or tls://host:8883
+	// for TLS. Empty disables MQTT.
+	Broker    string        `mapstructure:"broker"`
+	ClientID  string        `mapstructure:"client_id"`
+	Username  string        `mapstructure:"username"`
+	Password  string        `mapstructure:"password"`
+	KeepAlive time.Duration `mapstructure:"keep_alive"`
+}
+
+// Validate checks the MQTT configuration.
+func (m *MQTTConfig) Validate() error {
+	if m.Broker == "" {
+		return nil
+	}
+	u, err := url.Parse(m.Broker)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("broker must be a URL such as tcp://mqtt.local:1883, got %q", m.Broker)
+	}
+	switch u.Scheme {
+	case "tcp", "mqtt", "tls", "ssl", "mqtts":
+	default:
+		return fmt.Errorf("broker scheme must be tcp or tls, got %q", u.Scheme)
+	}
+	if m.Password != "" && m.Username == "" {
+		return fmt.Errorf("password requires a username")
+	}
+	if m.KeepAlive != 0 && (m.KeepAlive < time.Second || m.KeepAlive > 18*time.Hour) {
+		return fmt.Errorf("keep_alive must be between 1s and 18h")
+	}
+	return nil
+}
+
+// ID returns the client identifier sent to the broker.
+func (m *MQTTConfig) ID() string {
+	if m.ClientID == "" {
+		return DefaultMQTTClientID
+	}
+	return m.ClientID
+}
+
+// KeepAliveInterval returns the keep-alive interval.
+func (m *MQTTConfig) KeepAliveInterval() time.Duration {
+	if m.KeepAlive == 0 {
+		return DefaultMQTTKeepAlive
+	}
+	return m.KeepAlive
+}
+
+// Decision service defaults, used when the timeout or cache_ttl is zero.
+const (
+	DefaultDecisionTimeout  = 2 * time.Second
+	DefaultDecisionCacheTTL = 5 * time.Minute
+)
+
+// DecisionConfig configures an external HTTP service that decides the album
+// in place of the local schedule, which remains the fallback.
+type DecisionConfig struct {
+	URL string `mapstructure:"url"` // empty disables
+	// Device identifies this scheduler to the service.
+	Device  string            `mapstructure:"device"`
+	Headers map[string]string `mapstructure:"headers"`
+	Timeout time.Duration     `mapstructure:"timeout"`
+	// CacheTTL is how long a decision is reused when the service does not
+	// return its own ttl.
+	CacheTTL time.Duration `mapstructure:"cache_ttl"`
+}
+
+// Validate checks the decision service configuration.
+func (d *DecisionConfig) Validate() error {
+	if d.URL == "" {
+		return nil
+	}
+	u, err := url.Parse(d.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an absolute http or https URL, got %q", d.URL)
+	}
+	if d.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative")
+	}
+	if d.CacheTTL < 0 {
+		return fmt.Errorf("cache_ttl must not be negative")
+	}
+	return nil
+}
+
+// RequestTimeout returns the timeout of a request to the service.
+func (d *DecisionConfig) RequestTimeout() time.Duration {
+	if d.Timeout == 0 {
+		return DefaultDecisionTimeout
+	}
+	return d.Timeout
+}
+
+// TTL returns how long a decision without its own ttl is reused.
+func (d *DecisionConfig) TTL() time.Duration {
+	if d.CacheTTL == 0 {
+		return DefaultDecisionCacheTTL
+	}
+	return d.CacheTTL
+}
+
+// DebugConfig enables features intended for testing and staging only.
+type DebugConfig struct {
+	// AllowDateOverride honours the X-IKS-Date header and _date query
+	// parameter on the redirect endpoint.
+	AllowDateOverride bool `mapstructure:"allow_date_override"`
+	// Expvar serves Go runtime stats and scheduler vars on /debug/vars,
+	// with the admin surface.
+	Expvar bool `mapstructure:"expvar"`
+}
+
+// Health detail levels.
+const (
+	HealthDetailFull    = "full"
+	HealthDetailMinimal = "minimal"
+)
+
+// HealthConfig controls the health endpoint.
+type HealthConfig struct {
+	// Deep probes kiosk_url on every health check, as ?deep=1 does.
+	Deep bool `mapstructure:"deep"`
+	// Detail is full or minimal; minimal hides the schedule, album and
+	// check messages from requests without an API token.
+	Detail string `mapstructure:"detail"`
+}
+
+// Validate checks the health configuration.
+func (h *HealthConfig) Validate() error {
+	switch h.Detail {
+	case "", HealthDetailFull, HealthDetailMinimal:
+		return nil
+	}
+	return fmt.Errorf("unknown detail %q, expected %s or %s", h.Detail, HealthDetailFull, HealthDetailMinimal)
+}
+
+// Config holds all application configuration.
+type Config struct {
+	KioskURL     string `mapstructure:"kiosk_url"`
+	DefaultAlbum string `mapstructure:"default_album"`
+	Port         int    `mapstructure:"port"`
+	LogLevel     string `mapstructure:"log_level"`
+	LogFormat    string `mapstructure:"log_format"`
+	// LogRedact lists log attributes whose values are masked, e.g. album
+	// or remote, and "query" to mask the query values of URLs.
+	LogRedact   []string `mapstructure:"log_redact"`
+	WatchConfig bool     `mapstructure:"watch_config"`
+	// AdminListen is the address, e.g. 127.0.0.1:8081, of a second listener
+	// serving the admin API, UI and metrics instead of port; empty serves
+	// everything on port.
+	AdminListen string `mapstructure:"admin_listen"`
+	// MetricsPort serves /metrics alone on its own port, outside the
+	// middleware of the other listeners; 0 serves it with the admin surface.
+	MetricsPort int `mapstructure:"metrics_port"`
+	// H2C also serves HTTP/2 without TLS, for clients and proxies that
+	// speak it with prior knowledge.
+	H2C               bool     `mapstructure:"h2c"`
+	PassthroughParams []string `mapstructure:"passthrough_params"`
+	// PassthroughMode selects which client query parameters are forwarded:
+	// PassthroughAllowlist forwards PassthroughParams, PassthroughAllExcept
+	// forwards all but BlockedParams.
+	PassthroughMode string   `mapstructure:"passthrough_mode"`
+	BlockedParams   []string `mapstructure:"blocked_params"`
+	// ParamMap renames client query parameters before they are forwarded,
+	// keyed by the incoming name.
+	ParamMap map[string]string `mapstructure:"param_map"`
+	// DefaultParams are added to every redirect; passthrough values override them.
+	DefaultParams map[string]string `mapstructure:"default_params"`
+	// DeviceProfiles override passthrough rules and default params per
+	// device; a device belongs to at most one profile.
+	DeviceProfiles []DeviceProfile `mapstructure:"device_profiles"`
+	Devices        DevicesConfig   `mapstructure:"devices"`
+	Schedule       []ScheduleEntry `mapstructure:"schedule"`
+	// EndBoundary is the end_boundary of entries that do not set their
+	// own: EndInclusive, the default, or EndExclusive.
+	EndBoundary string `mapstructure:"end_boundary"`
+	// Timezone is the IANA time zone the schedule is evaluated in; empty
+	// uses the local one, e.g. from TZ.
+	Timezone string `mapstructure:"timezone"`
+	// Profiles replace Schedule with named schedule lists, one of which is
+	// active; see WithProfile.
+	Profiles []Profile `mapstructure:"profiles"`
+	// Profile is the profile active until another one is chosen at runtime;
+	// empty selects the first profile.
+	Profile string `mapstructure:"profile"`
+	// Kiosks are further displays served under their own routes, sharing
+	// the rest of the configuration; see WithKiosk.
+	Kiosks          []Kiosk           `mapstructure:"kiosks"`
+	MetricsUsername string            `mapstructure:"metrics_username"`
+	MetricsPassword string            `mapstructure:"metrics_password"`
+	Metrics         MetricsConfig     `mapstructure:"metrics"`
+	AccessLog       AccessLogConfig   `mapstructure:"access_log"`
+	Tracing         TracingConfig     `mapstructure:"tracing"`
+	Compression     CompressionConfig `mapstructure:"compression"`
+	Debug           DebugConfig       `mapstructure:"debug"`
+	Health          HealthConfig      `mapstructure:"health"`
+	Shutdown        ShutdownConfig    `mapstructure:"shutdown"`
+	Transitions     TransitionsConfig `mapstructure:"transitions"`
+	Shadow          ShadowConfig      `mapstructure:"shadow"`
+	Hooks           []HookConfig      `mapstructure:"hooks"`
+	// Notifications are push notification services notified like hooks.
+	Notifications []NotificationConfig `mapstructure:"notifications"`
+	GitSync       GitSyncConfig        `mapstructure:"git_sync"`
+	Remote        RemoteConfig         `mapstructure:"remote"`
+	APITokens     []APIToken           `mapstructure:"api_tokens"`
+	Control       ControlConfig        `mapstructure:"control"`
+	PartyModes    []PartyMode          `mapstructure:"party_modes"`
+	GuestLinks    GuestLinksConfig     `mapstructure:"guest_links"`
+	Maintenance   MaintenanceConfig    `mapstructure:"maintenance"`
+	QuietHours    QuietHoursConfig     `mapstructure:"quiet_hours"`
+	// Albums maps aliases to album IDs; album references may use either.
+	Albums map[string]string `mapstructure:"albums"`
+	// ValidateAlbumIDs checks the format of album IDs: "uuid" or empty.
+	ValidateAlbumIDs string               `mapstructure:"validate_album_ids"`
+	NightParams      []NightParams        `mapstructure:"night_params"`
+	Pages            PagesConfig          `mapstructure:"pages"`
+	InfoPage         InfoPageConfig       `mapstructure:"info_page"`
+	LoopProtection   LoopProtectionConfig `mapstructure:"loop_protection"`
+	ForwardAuth      ForwardAuthConfig    `mapstructure:"forward_auth"`
+	KioskAuth        KioskAuthConfig      `mapstructure:"kiosk_auth"`
+	CacheBust        CacheBustConfig      `mapstructure:"cache_bust"`
+	KioskConfig      KioskConfigConfig    `mapstructure:"kiosk_config"`
+	Signing          SigningConfig        `mapstructure:"signing"`
+	Email            EmailConfig          `mapstructure:"email"`
+	Telegram         TelegramConfig       `mapstructure:"telegram"`
+	SlashCommands    SlashCommandsConfig  `mapstructure:"slash_commands"`
+	Decision         DecisionConfig       `mapstructure:"decision"`
+	Immich           ImmichConfig         `mapstructure:"immich"`
+	HomeAssistant    HomeAssistantConfig  `mapstructure:"home_assistant"`
+	MQTT             MQTTConfig           `mapstructure:"mqtt"`
+	// StateDir persists runtime state such as the audit log; empty keeps it in memory.
+	StateDir string        `mapstructure:"state_dir"`
+	Stats    StatsConfig   `mapstructure:"stats"`
+	Secrets  SecretsConfig `mapstructure:"secrets"`
+
+	// sources records where settings were taken from; see Sources. It is
+	// shared between clones and replaced, never modified, by SetSource.
+	sources map[string]string
+}
+
+// targetsSelf reports whether the kiosk URL is the scheduler's own redirect
+// endpoint on this machine: a loopback host on the scheduler's port, at /.
+func targetsSelf(u *url.URL, port int) bool {
+	if u.Path != "" && u.Path != "/" {
+		return false
+	}
+	host := u.Hostname()
+	if host != "localhost" {
+		ip := net.ParseIP(host)
+		if ip == nil || !(ip.IsLoopback() || ip.IsUnspecified()) {
+			return false
+		}
+	}
+	p := u.Port()
+	if p == "" {
+		p = "80"
+		if u.Scheme == "https" {
+			p = "443"
+		}
+	}
+	return p == strconv.Itoa(port)
+}
+
+// validateListenAddr checks a host:port listen address, which must not use
+// the public port.
+func validateListenAddr(addr string, publicPort int) error {
+	_, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid address %q, expected host:port", addr)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 65535 {
+		return fmt.Errorf("port must be between 1 and 65535, got %q", portStr)
+	}
+	if port == publicPort {
+		return fmt.Errorf("port %d is already used by port", port)
+	}
+	return nil
+}
+
+// validateMetricsPort checks that the metrics port, if any, is free and
+// has metrics to serve.
+func (c *Config) validateMetricsPort() error {
+	if c.MetricsPort == 0 {
+		return nil
+	}
+	if c.MetricsPort < 1 || c.MetricsPort > 65535 {
+		return fmt.Errorf("must be between 1 and 65535")
+	}
+	if c.MetricsPort == c.Port {
+		return fmt.Errorf("port %d is already used by port", c.MetricsPort)
+	}
+	if c.AdminListen != "" {
+		if _, p, err := net.SplitHostPort(c.AdminListen); err == nil && p == strconv.Itoa(c.MetricsPort) {
+			return fmt.Errorf("port %d is already used by admin_listen", c.MetricsPort)
+		}
+	}
+	if !c.Metrics.PrometheusEnabled() {
+		return fmt.Errorf("requires the prometheus metrics backend")
+	}
+	return nil
+}
+
+// Passthrough modes.
+const (
+	PassthroughAllowlist = "allowlist"
+	PassthroughAllExcept = "all_except"
+)
+
+// AlbumPlaceholder in the path of kiosk_url is replaced by the selected
+// album instead of passing it as the album query parameter.
+const AlbumPlaceholder = "{album}"
+
+// placeholderRegex finds placeholders in kiosk_url.
+var placeholderRegex = regexp.MustCompile(`\{[^{}]*\}`)
+
+// HasAlbumPath reports whether kiosk_url selects the album in its path.
+func (c *Config) HasAlbumPath() bool {
+	return strings.Contains(c.KioskURL, AlbumPlaceholder)
+}
+
+// Location returns the time zone the schedule is evaluated in: timezone,
+// or the local time zone when it is not set.
+func (c *Config) Location() *time.Location {
+	if c.Timezone == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// AlbumPathURLs returns the kiosk URLs, of the configuration and of its
+// kiosks, that select the album in their path.
+func (c *Config) AlbumPathURLs() []string {
+	var urls []string
+	if c.HasAlbumPath() {
+		urls = append(urls, c.KioskURL)
+	}
+	for _, k := range c.Kiosks {
+		if strings.Contains(k.KioskURL, AlbumPlaceholder) && !slices.Contains(urls, k.KioskURL) {
+			urls = append(urls, k.KioskURL)
+		}
+	}
+	return urls
+}
+
+// uuidRegex matches Immich album IDs.
+var uuidRegex = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// AlbumIDFormatUUID as validate_album_ids requires album IDs to be UUIDs.
+const AlbumIDFormatUUID = "uuid"
+
+// AlbumRef is an album referenced by the configuration, as written: an
+// album ID or an alias.
+type AlbumRef struct {
+	Where string // e.g. "schedule entry christmas"
+	Album string
+}
+
+// AlbumRefs returns the albums referenced by the configuration, in all
+// profiles, each once.
+func (c *Config) AlbumRefs() []AlbumRef {
+	var refs []AlbumRef
+	seen := make(map[string]bool)
+	add := func(where, album string) {
+		if album != "" && !seen[album] {
+			seen[album] = true
+			refs = append(refs, AlbumRef{Where: where, Album: album})
+		}
+	}
+
+	add("default_album", c.DefaultAlbum)
+	addSchedule := func(prefix string, schedule []ScheduleEntry) {
+		for _, e := range schedule {
+			for _, album := range e.AlbumIDs() {
+				add(prefix+"schedule entry "+e.Name, album)
+			}
+		}
+	}
+	addSchedule("", c.Schedule)
+	for _, p := range c.Profiles {
+		addSchedule("profile "+p.Name+": ", p.Schedule)
+	}
+	for _, k := range c.Kiosks {
+		add("kiosk "+k.Name+" default_album", k.DefaultAlbum)
+		addSchedule("kiosk "+k.Name+": ", k.Schedule)
+	}
+	for _, a := range c.Control.Albums {
+		add("control album "+a.Name, a.Album)
+	}
+	for _, m := range c.PartyModes {
+		add("party mode "+m.Name, m.Album)
+	}
+	add("quiet_hours", c.QuietHours.NightAlbum())
+	return refs
+}
+
+// AlbumID resolves an album reference: an alias from albums, matched
+// case-insensitively, or else an album ID, returned as is.
+func (c *Config) AlbumID(ref string) string {
+	if id, ok := c.Albums[strings.ToLower(ref)]; ok {
+		return id
+	}
+	return ref
+}
+
+// validateAlbums checks the aliases and that, with aliases configured,
+// every reference that is not an album ID names one of them.
+func (c *Config) validateAlbums() error {
+	if len(c.Albums) == 0 {
+		return nil
+	}
+	for alias, id := range c.Albums {
+		if strings.TrimSpace(alias) == "" {
+			return fmt.Errorf("albums: alias name is required")
+		}
+		if strings.TrimSpace(id) == "" {
+			return fmt.Errorf("albums: alias %q has no album ID", alias)
+		}
+	}
+	for _, ref := range c.AlbumRefs() {
+		if _, ok := c.Albums[strings.ToLower(ref.Album)]; !ok && !uuidRegex.MatchString(ref.Album) {
+			return fmt.Errorf("%s: unknown album alias %q", ref.Where, ref.Album)
+		}
+	}
+	return nil
 }
 
-// Config holds all application configuration.
-type Config struct {
-	KioskURL          string          `mapstructure:"kiosk_url"`
-	DefaultAlbum      string          `mapstructure:"default_album"`
-	Port              int             `mapstructure:"port"`
-	LogLevel          string          `mapstructure:"log_level"`
-	PassthroughParams []string        `mapstructure:"passthrough_params"`
-	Schedule          []ScheduleEntry `mapstructure:"schedule"`
-	MetricsUsername   string          `mapstructure:"metrics_username"`
-	MetricsPassword   string          `mapstructure:"metrics_password"`
+// validateAlbumIDs checks the format of album IDs, after resolving aliases,
+// as selected by validate_album_ids.
+func (c *Config) validateAlbumIDs() error {
+	switch c.ValidateAlbumIDs {
+	case "":
+		return nil
+	case AlbumIDFormatUUID:
+	default:
+		return fmt.Errorf("validate_album_ids must be %q or empty, got %q", AlbumIDFormatUUID, c.ValidateAlbumIDs)
+	}
+
+	for alias, id := range c.Albums {
+		if !uuidRegex.MatchString(id) {
+			return fmt.Errorf("albums: alias %q: album ID %q is not a UUID", alias, id)
+		}
+	}
+	for _, ref := range c.AlbumRefs() {
+		if id := c.AlbumID(ref.Album); !uuidRegex.MatchString(id) {
+			return fmt.Errorf("%s: album ID %q is not a UUID", ref.Where, id)
+		}
+	}
+	return nil
 }
 
 // dateRegex validates MM-DD format.
@@ -42,27 +2022,94 @@ func (s *ScheduleEntry) Validate() error {
 	if strings.TrimSpace(s.Name) == "" {
 		return fmt.Errorf("schedule entry name is required")
 	}
-	if strings.TrimSpace(s.Album) == "" {
+	switch {
+	case len(s.Albums) > 0 && s.Album != "":
+		return fmt.Errorf("album and albums cannot both be set")
+	case len(s.Albums) > 0:
+		if len(s.Albums) < 2 {
+			return fmt.Errorf("albums needs at least two albums to rotate")
+		}
+		if slices.ContainsFunc(s.Albums, func(a string) bool { return strings.TrimSpace(a) == "" }) {
+			return fmt.Errorf("albums must not contain empty album IDs")
+		}
+		if s.RotateMinutes < 1 {
+			return fmt.Errorf("rotate_minutes must be at least 1 with albums")
+		}
+	case strings.TrimSpace(s.Album) == "":
 		return fmt.Errorf("schedule entry album is required")
+	case s.RotateMinutes != 0:
+		return fmt.Errorf("rotate_minutes requires albums")
+	}
+	if s.RRule != "" {
+		if _, err := rrule.Parse(s.RRule); err != nil {
+			return fmt.Errorf("invalid rrule: %w", err)
+		}
+		if s.Days < 0 || s.Days > 366 {
+			return fmt.Errorf("days must be between 1 and 366")
+		}
+	} else if s.Days != 0 {
+		return fmt.Errorf("days requires rrule")
+	}
+
+	// A recurrence rule alone covers the whole year.
+	if s.RRule == "" || s.Start != "" || s.End != "" {
+		if !dateRegex.MatchString(s.Start) {
+			return fmt.Errorf("invalid start date format %q, expected MM-DD", s.Start)
+		}
+		if !dateRegex.MatchString(s.End) {
+			return fmt.Errorf("invalid end date format %q, expected MM-DD", s.End)
+		}
+
+		// Validate month/day values
+		if err := validateDate(s.Start); err != nil {
+			return fmt.Errorf("invalid start date: %w", err)
+		}
+		if err := validateDate(s.End); err != nil {
+			return fmt.Errorf("invalid end date: %w", err)
+		}
+	} else if s.EndBoundary != "" {
+		return fmt.Errorf("end_boundary requires start and end")
 	}
-	if !dateRegex.MatchString(s.Start) {
-		return fmt.Errorf("invalid start date format %q, expected MM-DD", s.Start)
+	if err := validateEndBoundary(s.EndBoundary); err != nil {
+		return err
 	}
-	if !dateRegex.MatchString(s.End) {
-		return fmt.Errorf("invalid end date format %q, expected MM-DD", s.End)
+	if s.MinDuration != "" {
+		if d, err := time.ParseDuration(s.MinDuration); err != nil || d <= 0 {
+			return fmt.Errorf("min_duration must be a positive duration such as 30m, got %q", s.MinDuration)
+		}
 	}
 
-	// Validate month/day values
-	if err := validateDate(s.Start); err != nil {
-		return fmt.Errorf("invalid start date: %w", err)
+	for param := range s.Params {
+		if _, ok := SanitizeParam(param); !ok || param == "album" {
+			return fmt.Errorf("invalid param %q", param)
+		}
 	}
-	if err := validateDate(s.End); err != nil {
-		return fmt.Errorf("invalid end date: %w", err)
+	for _, param := range s.RemoveParams {
+		if _, ok := SanitizeParam(param); !ok || param == "album" {
+			return fmt.Errorf("invalid remove_params entry %q", param)
+		}
+	}
+
+	if s.When != "" {
+		if _, err := rules.Compile(s.When); err != nil {
+			return fmt.Errorf("invalid when: %w", err)
+		}
 	}
 
 	return nil
 }
 
+// Equal reports whether two schedule entries are identical.
+func (s ScheduleEntry) Equal(other ScheduleEntry) bool {
+	return s.Name == other.Name && s.Album == other.Album &&
+		s.Start == other.Start && s.End == other.End && s.EndBoundary == other.EndBoundary &&
+		maps.Equal(s.Params, other.Params) &&
+		slices.Equal(s.RemoveParams, other.RemoveParams) &&
+		s.When == other.When && s.RRule == other.RRule && s.Days == other.Days &&
+		slices.Equal(s.Albums, other.Albums) && s.RotateMinutes == other.RotateMinutes &&
+		s.MinDuration == other.MinDuration
+}
+
 // validateDate checks if the MM-DD string represents a valid date.
 func validateDate(date string) error {
 	parts := strings.Split(date, "-")
@@ -106,6 +2153,14 @@ func (c *Config) Validate() error {
 	if parsedURL.Host == "" {
 		return fmt.Errorf("kiosk_url must include a host")
 	}
+	if strings.Contains(parsedURL.RawQuery, AlbumPlaceholder) || strings.Contains(parsedURL.Fragment, AlbumPlaceholder) {
+		return fmt.Errorf("kiosk_url: the %s placeholder is only supported in the path", AlbumPlaceholder)
+	}
+	for _, p := range placeholderRegex.FindAllString(parsedURL.Path, -1) {
+		if p != AlbumPlaceholder {
+			return fmt.Errorf("kiosk_url: unknown placeholder %s, only %s is supported", p, AlbumPlaceholder)
+		}
+	}
 
 	if strings.TrimSpace(c.DefaultAlbum) == "" {
 		return fmt.Errorf("default_album is required")
@@ -113,16 +2168,519 @@ func (c *Config) Validate() error {
 	if c.Port < 1 || c.Port > 65535 {
 		return fmt.Errorf("port must be between 1 and 65535")
 	}
+	if c.AdminListen != "" {
+		if err := validateListenAddr(c.AdminListen, c.Port); err != nil {
+			return fmt.Errorf("admin_listen: %w", err)
+		}
+	}
+	if err := c.validateMetricsPort(); err != nil {
+		return fmt.Errorf("metrics_port: %w", err)
+	}
+	if targetsSelf(parsedURL, c.Port) {
+		return fmt.Errorf("kiosk_url %q points at the scheduler itself, which would redirect clients in a loop", c.KioskURL)
+	}
+	switch c.LogFormat {
+	case "", "auto", "json", "text":
+	default:
+		return fmt.Errorf("log_format must be auto, json or text, got %q", c.LogFormat)
+	}
+	if slices.ContainsFunc(c.LogRedact, func(f string) bool { return strings.TrimSpace(f) == "" }) {
+		return fmt.Errorf("log_redact must not contain empty field names")
+	}
+	switch c.PassthroughMode {
+	case "", PassthroughAllowlist, PassthroughAllExcept:
+	default:
+		return fmt.Errorf("passthrough_mode must be %s or %s, got %q", PassthroughAllowlist, PassthroughAllExcept, c.PassthroughMode)
+	}
+	for param := range c.DefaultParams {
+		if _, ok := SanitizeParam(param); !ok || param == "album" {
+			return fmt.Errorf("default_params: invalid param %q", param)
+		}
+	}
+	targets := make(map[string]string, len(c.ParamMap))
+	for _, from := range slices.Sorted(maps.Keys(c.ParamMap)) {
+		to := c.ParamMap[from]
+		if _, ok := SanitizeParam(from); !ok || from == "album" {
+			return fmt.Errorf("param_map: invalid param %q", from)
+		}
+		if _, ok := SanitizeParam(to); !ok || to == "album" {
+			return fmt.Errorf("param_map: invalid target %q for %q", to, from)
+		}
+		if other, ok := targets[to]; ok {
+			return fmt.Errorf("param_map: %q and %q both map to %q", other, from, to)
+		}
+		targets[to] = from
+	}
+
+	if err := validateEndBoundary(c.EndBoundary); err != nil {
+		return err
+	}
+	if c.Timezone != "" {
+		if _, err := time.LoadLocation(c.Timezone); err != nil {
+			return fmt.Errorf("timezone: %w", err)
+		}
+	}
+	if err := c.validateSchedule(c.Schedule); err != nil {
+		return err
+	}
+	if len(c.Profiles) > 0 && len(c.Schedule) > 0 {
+		return fmt.Errorf("schedule and profiles cannot both be set; move the schedule into a profile")
+	}
+	profileNames := make(map[string]bool, len(c.Profiles))
+	for i, profile := range c.Profiles {
+		if strings.TrimSpace(profile.Name) == "" {
+			return fmt.Errorf("profile %d: name is required", i)
+		}
+		if profileNames[profile.Name] {
+			return fmt.Errorf("profile name %q is used more than once", profile.Name)
+		}
+		profileNames[profile.Name] = true
+		if err := c.validateSchedule(profile.Schedule); err != nil {
+			return fmt.Errorf("profile %d (%s): %w", i, profile.Name, err)
+		}
+	}
+	if c.Profile != "" && !profileNames[c.Profile] {
+		return fmt.Errorf("profile %q is not defined in profiles", c.Profile)
+	}
+
+	if c.AccessLog.SampleRate < 0 {
+		return fmt.Errorf("access_log.sample_rate must not be negative")
+	}
+
+	if c.Compression.Enabled && (c.Compression.Level < 1 || c.Compression.Level > 9) {
+		return fmt.Errorf("compression.level must be between 1 and 9")
+	}
+
+	if err := c.Metrics.Validate(); err != nil {
+		return fmt.Errorf("metrics: %w", err)
+	}
+
+	if err := c.GitSync.Validate(); err != nil {
+		return fmt.Errorf("git_sync: %w", err)
+	}
+
+	if err := c.Remote.Validate(); err != nil {
+		return fmt.Errorf("remote: %w", err)
+	}
+	if c.GitSync.Enabled && c.Remote.Provider != "" {
+		return fmt.Errorf("git_sync and remote cannot both be enabled")
+	}
+
+	tokenNames := make(map[string]bool, len(c.APITokens))
+	for i, token := range c.APITokens {
+		if err := token.Validate(); err != nil {
+			return fmt.Errorf("api token %d (%s): %w", i, token.Name, err)
+		}
+		if tokenNames[token.Name] {
+			return fmt.Errorf("api token name %q is used more than once", token.Name)
+		}
+		tokenNames[token.Name] = true
+	}
+
+	for i, hook := range c.Hooks {
+		if err := hook.Validate(); err != nil {
+			return fmt.Errorf("hook %d (%s): %w", i, hook.Name, err)
+		}
+	}
+
+	for i, notification := range c.Notifications {
+		if err := notification.Validate(); err != nil {
+			return fmt.Errorf("notification %d (%s): %w", i, notification.Name, err)
+		}
+	}
+
+	if err := c.Control.Validate(); err != nil {
+		return fmt.Errorf("control: %w", err)
+	}
+	if c.Control.Enabled && len(c.Control.Albums) == 0 && len(c.PartyModes) == 0 {
+		return fmt.Errorf("control: at least one album or party mode is required")
+	}
+
+	if err := c.GuestLinks.Validate(); err != nil {
+		return fmt.Errorf("guest_links: %w", err)
+	}
+
+	if err := c.Maintenance.Validate(); err != nil {
+		return fmt.Errorf("maintenance: %w", err)
+	}
+
+	if err := c.QuietHours.Validate(); err != nil {
+		return fmt.Errorf("quiet_hours: %w", err)
+	}
+
+	for i, n := range c.NightParams {
+		if err := n.Validate(); err != nil {
+			return fmt.Errorf("night_params %d: %w", i, err)
+		}
+	}
+
+	if h := c.LoopProtection.MarkerHeader; h != "" && !paramRegex.MatchString(h) {
+		return fmt.Errorf("loop_protection.marker_header %q is not a valid header name", h)
+	}
+
+	if err := c.Pages.Validate(); err != nil {
+		return fmt.Errorf("pages: %w", err)
+	}
+
+	if err := c.ForwardAuth.Validate(); err != nil {
+		return fmt.Errorf("forward_auth: %w", err)
+	}
+
+	if err := c.KioskAuth.Validate(); err != nil {
+		return fmt.Errorf("kiosk_auth: %w", err)
+	}
+	if c.KioskAuth.Header != "" && !c.ForwardAuth.Enabled {
+		return fmt.Errorf("kiosk_auth: header requires forward_auth to be enabled")
+	}
+
+	if err := c.Signing.Validate(); err != nil {
+		return fmt.Errorf("signing: %w", err)
+	}
+
+	if err := c.CacheBust.Validate(); err != nil {
+		return fmt.Errorf("cache_bust: %w", err)
+	}
+	if param := c.CacheBust.QueryParam(); param != "" {
+		if param == c.KioskAuth.QueryParam() ||
+			(c.Signing.Enabled() && (param == c.Signing.SignatureParam() || param == c.Signing.TimestampParamName())) {
+			return fmt.Errorf("cache_bust: param %q is already used by kiosk_auth or signing", param)
+		}
+	}
+
+	if err := c.KioskConfig.Validate(); err != nil {
+		return fmt.Errorf("kiosk_config: %w", err)
+	}
+
+	if err := c.Email.Validate(); err != nil {
+		return fmt.Errorf("email: %w", err)
+	}
+
+	if err := c.Telegram.Validate(); err != nil {
+		return fmt.Errorf("telegram: %w", err)
+	}
+
+	if err := c.SlashCommands.Validate(); err != nil {
+		return fmt.Errorf("slash_commands: %w", err)
+	}
+
+	if err := c.Decision.Validate(); err != nil {
+		return fmt.Errorf("decision: %w", err)
+	}
+
+	if err := c.Immich.Validate(); err != nil {
+		return fmt.Errorf("immich: %w", err)
+	}
+
+	if err := c.HomeAssistant.Validate(); err != nil {
+		return fmt.Errorf("home_assistant: %w", err)
+	}
+
+	if err := c.MQTT.Validate(); err != nil {
+		return fmt.Errorf("mqtt: %w", err)
+	}
+
+	if err := c.Health.Validate(); err != nil {
+		return fmt.Errorf("health: %w", err)
+	}
+
+	if err := c.Stats.Validate(); err != nil {
+		return fmt.Errorf("stats: %w", err)
+	}
+
+	if err := c.validateDeviceProfiles(); err != nil {
+		return err
+	}
+	if err := c.Devices.Validate(); err != nil {
+		return fmt.Errorf("devices: %w", err)
+	}
+	if err := c.Shutdown.Validate(); err != nil {
+		return fmt.Errorf("shutdown: %w", err)
+	}
+	if err := c.Transitions.Validate(); err != nil {
+		return fmt.Errorf("transitions: %w", err)
+	}
+
+	partyNames := make(map[string]bool, len(c.PartyModes))
+	for i, mode := range c.PartyModes {
+		if err := mode.Validate(); err != nil {
+			return fmt.Errorf("party mode %d (%s): %w", i, mode.Name, err)
+		}
+		if partyNames[mode.Name] {
+			return fmt.Errorf("party mode name %q is used more than once", mode.Name)
+		}
+		partyNames[mode.Name] = true
+	}
+
+	if err := c.validateAlbums(); err != nil {
+		return err
+	}
+	if err := c.validateKiosks(); err != nil {
+		return err
+	}
+	return c.validateAlbumIDs()
+}
+
+// validateKiosks checks the kiosk names and validates each kiosk's
+// configuration as WithKiosk derives it.
+func (c *Config) validateKiosks() error {
+	names := make(map[string]bool, len(c.Kiosks))
+	for i, k := range c.Kiosks {
+		if !kioskNameRegex.MatchString(k.Name) {
+			return fmt.Errorf("kiosk %d: name %q must be lowercase letters, digits, - and _", i, k.Name)
+		}
+		if names[k.Name] {
+			return fmt.Errorf("kiosk name %q is used more than once", k.Name)
+		}
+		names[k.Name] = true
+
+		kiosk, err := c.WithKiosk(k.Name)
+		if err != nil {
+			return err
+		}
+		if err := kiosk.Validate(); err != nil {
+			return fmt.Errorf("kiosk %d (%s): %w", i, k.Name, err)
+		}
+	}
+	return nil
+}
+
+// validateDeviceProfiles checks the device profiles and that no device
+// belongs to two of them.
+func (c *Config) validateDeviceProfiles() error {
+	names := make(map[string]bool, len(c.DeviceProfiles))
+	devices := make(map[string]string)
+	for i, p := range c.DeviceProfiles {
+		if err := p.Validate(); err != nil {
+			return fmt.Errorf("device profile %d (%s): %w", i, p.Name, err)
+		}
+		if names[p.Name] {
+			return fmt.Errorf("device profile name %q is used more than once", p.Name)
+		}
+		names[p.Name] = true
+		for _, d := range p.Devices {
+			if other, ok := devices[d]; ok {
+				return fmt.Errorf("device %q is in device profiles %q and %q", d, other, p.Name)
+			}
+			devices[d] = p.Name
+		}
+	}
+	return nil
+}
+
+// Validate checks if the metrics configuration is valid.
+func (m *MetricsConfig) Validate() error {
+	switch m.Backend {
+	case "", "prometheus":
+		return nil
+	case "statsd":
+	default:
+		return fmt.Errorf("unknown backend %q, expected prometheus or statsd", m.Backend)
+	}
+
+	if strings.TrimSpace(m.StatsD.Address) == "" {
+		return fmt.Errorf("statsd.address is required for the statsd backend")
+	}
+	if m.StatsD.Flavor != "" && m.StatsD.Flavor != "statsd" && m.StatsD.Flavor != "dogstatsd" {
+		return fmt.Errorf("unknown statsd.flavor %q, expected statsd or dogstatsd", m.StatsD.Flavor)
+	}
+	if m.StatsD.Interval < 0 {
+		return fmt.Errorf("statsd.interval must not be negative")
+	}
+	return nil
+}
+
+// PrometheusEnabled reports whether metrics are exposed on /metrics.
+func (m *MetricsConfig) PrometheusEnabled() bool {
+	return m.Backend == "" || m.Backend == "prometheus"
+}
 
-	for i, entry := range c.Schedule {
+// validateSchedule checks the entries of a schedule list.
+func (c *Config) validateSchedule(entries []ScheduleEntry) error {
+	for i, entry := range entries {
 		if err := entry.Validate(); err != nil {
 			return fmt.Errorf("schedule entry %d (%s): %w", i, entry.Name, err)
 		}
+		if entry.EndExclusive(c.EndBoundary) && entry.Start == entry.End {
+			return fmt.Errorf("schedule entry %d (%s): an exclusive end equal to start covers no days", i, entry.Name)
+		}
+		if entry.When == "" {
+			continue
+		}
+		when, _ := rules.Compile(entry.When)
+		if c.HomeAssistant.URL == "" && len(when.References(rules.SourceHomeAssistant)) > 0 {
+			return fmt.Errorf("schedule entry %d (%s): when reads Home Assistant states but home_assistant.url is not set", i, entry.Name)
+		}
+		topics := when.References(rules.SourceMQTT)
+		if c.MQTT.Broker == "" && len(topics) > 0 {
+			return fmt.Errorf("schedule entry %d (%s): when reads MQTT topics but mqtt.broker is not set", i, entry.Name)
+		}
+		for _, topic := range topics {
+			if topic == "" || strings.ContainsAny(topic, "+#") {
+				return fmt.Errorf("schedule entry %d (%s): mqtt[%q] must name a single topic without wildcards", i, entry.Name, topic)
+			}
+		}
 	}
-
 	return nil
 }
 
+// ProfileNames returns the names of the profiles in order.
+func (c *Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for _, p := range c.Profiles {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+// DefaultProfile returns the profile active until another one is chosen:
+// Profile, or the first profile when unset. It is empty without profiles.
+func (c *Config) DefaultProfile() string {
+	if c.Profile != "" || len(c.Profiles) == 0 {
+		return c.Profile
+	}
+	return c.Profiles[0].Name
+}
+
+// WithProfile returns a copy of the configuration whose Schedule is the
+// named profile's schedule, so it can be used like a configuration without
+// profiles. Without profiles, the name must be empty and c is returned.
+func (c *Config) WithProfile(name string) (*Config, error) {
+	if len(c.Profiles) == 0 && name == "" {
+		return c, nil
+	}
+	i := slices.IndexFunc(c.Profiles, func(p Profile) bool { return p.Name == name })
+	if i < 0 {
+		return nil, fmt.Errorf("profile %q not found", name)
+	}
+	clone := c.Clone()
+	clone.Schedule = slices.Clone(c.Profiles[i].Schedule)
+	return clone, nil
+}
+
+// WithoutProfile reverses WithProfile: it returns a copy of the
+// configuration with Schedule stored back into the named profile, keeping
+// changes made to the schedule. Without a name, c is returned.
+func (c *Config) WithoutProfile(name string) *Config {
+	i := slices.IndexFunc(c.Profiles, func(p Profile) bool { return p.Name == name })
+	if name == "" || i < 0 {
+		return c
+	}
+	clone := c.Clone()
+	clone.Profiles[i].Schedule = clone.Schedule
+	clone.Schedule = nil
+	return clone
+}
+
+// KioskNames returns the names of the kiosks in order.
+func (c *Config) KioskNames() []string {
+	names := make([]string, 0, len(c.Kiosks))
+	for _, k := range c.Kiosks {
+		names = append(names, k.Name)
+	}
+	return names
+}
+
+// WithKiosk returns a copy of the configuration for the named kiosk: the
+// kiosk's settings replace the top-level ones they set, and its default
+// params are added to the top-level ones. The copy has no kiosks.
+func (c *Config) WithKiosk(name string) (*Config, error) {
+	i := slices.IndexFunc(c.Kiosks, func(k Kiosk) bool { return k.Name == name })
+	if i < 0 {
+		return nil, fmt.Errorf("kiosk %q not found", name)
+	}
+	k := c.Kiosks[i]
+	clone := c.Clone()
+	clone.Kiosks = nil
+	if k.KioskURL != "" {
+		clone.KioskURL = k.KioskURL
+	}
+	if k.DefaultAlbum != "" {
+		clone.DefaultAlbum = k.DefaultAlbum
+	}
+	if k.PassthroughParams != nil {
+		clone.PassthroughParams = slices.Clone(k.PassthroughParams)
+	}
+	if len(k.DefaultParams) > 0 {
+		if clone.DefaultParams == nil {
+			clone.DefaultParams = make(map[string]string, len(k.DefaultParams))
+		}
+		maps.Copy(clone.DefaultParams, k.DefaultParams)
+	}
+	if k.Schedule != nil {
+		clone.Schedule = slices.Clone(k.Schedule)
+		clone.Profiles = nil
+		clone.Profile = ""
+	}
+	return clone, nil
+}
+
+// WithoutDiscovered returns the configuration without entries discovered
+// from Immich, in any profile, or c itself when it has none.
+func (c *Config) WithoutDiscovered() *Config {
+	discovered := func(e ScheduleEntry) bool { return e.Discovered }
+	found := slices.ContainsFunc(c.Schedule, discovered)
+	for _, p := range c.Profiles {
+		found = found || slices.ContainsFunc(p.Schedule, discovered)
+	}
+	if !found {
+		return c
+	}
+
+	clone := c.Clone()
+	clone.Schedule = slices.DeleteFunc(clone.Schedule, discovered)
+	for i := range clone.Profiles {
+		clone.Profiles[i].Schedule = slices.DeleteFunc(clone.Profiles[i].Schedule, discovered)
+	}
+	return clone
+}
+
+// Revision returns a short content hash identifying this configuration.
+// Two configurations with identical values have the same revision.
+func (c *Config) Revision() string {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Clone returns a copy of the configuration whose slices can be modified
+// without affecting the original.
+func (c *Config) Clone() *Config {
+	clone := *c
+	clone.Schedule = slices.Clone(c.Schedule)
+	clone.PassthroughParams = slices.Clone(c.PassthroughParams)
+	clone.BlockedParams = slices.Clone(c.BlockedParams)
+	clone.ParamMap = maps.Clone(c.ParamMap)
+	clone.DefaultParams = maps.Clone(c.DefaultParams)
+	clone.DeviceProfiles = slices.Clone(c.DeviceProfiles)
+	clone.Hooks = slices.Clone(c.Hooks)
+	clone.Notifications = slices.Clone(c.Notifications)
+	clone.Telegram.AllowedChats = slices.Clone(c.Telegram.AllowedChats)
+	clone.APITokens = slices.Clone(c.APITokens)
+	clone.Control.Albums = slices.Clone(c.Control.Albums)
+	clone.PartyModes = slices.Clone(c.PartyModes)
+	clone.NightParams = slices.Clone(c.NightParams)
+	clone.Albums = maps.Clone(c.Albums)
+	clone.Profiles = slices.Clone(c.Profiles)
+	for i := range clone.Profiles {
+		clone.Profiles[i].Schedule = slices.Clone(clone.Profiles[i].Schedule)
+	}
+	clone.Kiosks = slices.Clone(c.Kiosks)
+	for i := range clone.Kiosks {
+		clone.Kiosks[i].PassthroughParams = slices.Clone(clone.Kiosks[i].PassthroughParams)
+		clone.Kiosks[i].DefaultParams = maps.Clone(clone.Kiosks[i].DefaultParams)
+		clone.Kiosks[i].Schedule = slices.Clone(clone.Kiosks[i].Schedule)
+	}
+	clone.InfoPage.KioskUserAgents = slices.Clone(c.InfoPage.KioskUserAgents)
+	clone.ForwardAuth.Tokens = slices.Clone(c.ForwardAuth.Tokens)
+	clone.ForwardAuth.AllowedNetworks = slices.Clone(c.ForwardAuth.AllowedNetworks)
+	clone.ForwardAuth.TrustedProxies = slices.Clone(c.ForwardAuth.TrustedProxies)
+	clone.Decision.Headers = maps.Clone(c.Decision.Headers)
+	return &clone
+}
+
 // SanitizeParam validates and sanitizes a parameter name.
 // Returns the sanitized parameter and whether it's valid.
 func SanitizeParam(param string) (string, bool) {
@@ -136,17 +2694,20 @@ func SanitizeParam(param string) (string, bool) {
 	return param, true
 }
 
+// strict makes Load, LoadBytes and LoadEnv reject unknown keys.
+var strict atomic.Bool
+
+// SetStrict makes loading a configuration with unknown keys, such as a
+// misspelled default_album, fail instead of ignoring them.
+func SetStrict(enabled bool) {
+	strict.Store(enabled)
+}
+
 // Load reads configuration from file and environment variables.
 // Environment variables take precedence over file values.
 // Environment variable prefix is IKS_ (e.g., IKS_KIOSK_URL).
 func Load(configPath string) (*Config, error) {
-	v := viper.New()
-
-	// Set defaults
-	v.SetDefault("port", 8080)
-	v.SetDefault("log_level", "info")
-	v.SetDefault("passthrough_params", []string{})
-	v.SetDefault("schedule", []ScheduleEntry{})
+	v := newViper()
 
 	// Read config file
 	if configPath != "" {
@@ -156,6 +2717,41 @@ func Load(configPath string) (*Config, error) {
 		}
 	}
 
+	return unmarshal(v, SourceFile)
+}
+
+// LoadBytes reads configuration in the given format (yaml or json) from data,
+// with the same defaults and environment variable overrides as Load.
+func LoadBytes(data []byte, format string) (*Config, error) {
+	v := newViper()
+
+	v.SetConfigType(format)
+	if err := v.ReadConfig(bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+
+	return unmarshal(v, SourceData)
+}
+
+// ConfigDataEnv is the environment variable holding a complete YAML or JSON
+// configuration for LoadEnv.
+const ConfigDataEnv = "IKS_CONFIG_DATA"
+
+// LoadEnv reads the configuration from the environment only, for deployments
+// without a file system such as serverless functions. The configuration is
+// taken from IKS_CONFIG_DATA when set, with the usual IKS_* overrides.
+func LoadEnv() (*Config, error) {
+	if data := os.Getenv(ConfigDataEnv); data != "" {
+		return LoadBytes([]byte(data), "yaml")
+	}
+	return Load("")
+}
+
+// newViper creates a viper instance with defaults and environment variable bindings.
+func newViper() *viper.Viper {
+	v := viper.New()
+	setDefaults(v)
+
 	// Bind environment variables
 	v.SetEnvPrefix("IKS")
 	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
@@ -164,19 +2760,176 @@ func Load(configPath string) (*Config, error) {
 	// Manually bind specific env vars for proper override behavior
 	_ = v.BindEnv("kiosk_url", "IKS_KIOSK_URL")
 	_ = v.BindEnv("default_album", "IKS_DEFAULT_ALBUM")
+	_ = v.BindEnv("end_boundary", "IKS_END_BOUNDARY")
+	_ = v.BindEnv("timezone", "IKS_TIMEZONE")
+	_ = v.BindEnv("validate_album_ids", "IKS_VALIDATE_ALBUM_IDS")
 	_ = v.BindEnv("port", "IKS_PORT")
+	_ = v.BindEnv("h2c", "IKS_H2C")
+	_ = v.BindEnv("admin_listen", "IKS_ADMIN_LISTEN")
+	_ = v.BindEnv("metrics_port", "IKS_METRICS_PORT")
 	_ = v.BindEnv("log_level", "IKS_LOG_LEVEL")
+	_ = v.BindEnv("log_format", "IKS_LOG_FORMAT")
+	_ = v.BindEnv("watch_config", "IKS_WATCH_CONFIG")
+	_ = v.BindEnv("passthrough_mode", "IKS_PASSTHROUGH_MODE")
+	_ = v.BindEnv("cache_bust.mode", "IKS_CACHE_BUST_MODE")
+	_ = v.BindEnv("kiosk_config.enabled", "IKS_KIOSK_CONFIG_ENABLED")
 	_ = v.BindEnv("metrics_username", "IKS_METRICS_USERNAME")
 	_ = v.BindEnv("metrics_password", "IKS_METRICS_PASSWORD")
+	_ = v.BindEnv("access_log.sample_rate", "IKS_ACCESS_LOG_SAMPLE_RATE")
+	_ = v.BindEnv("tracing.enabled", "IKS_TRACING_ENABLED")
+	_ = v.BindEnv("compression.enabled", "IKS_COMPRESSION_ENABLED")
+	_ = v.BindEnv("debug.allow_date_override", "IKS_DEBUG_ALLOW_DATE_OVERRIDE")
+	_ = v.BindEnv("debug.expvar", "IKS_DEBUG_EXPVAR")
+	_ = v.BindEnv("health.deep", "IKS_HEALTH_DEEP")
+	_ = v.BindEnv("health.detail", "IKS_HEALTH_DETAIL")
+	_ = v.BindEnv("shutdown.timeout", "IKS_SHUTDOWN_TIMEOUT")
+	_ = v.BindEnv("shutdown.flush_timeout", "IKS_SHUTDOWN_FLUSH_TIMEOUT")
+	_ = v.BindEnv("transitions.cooldown", "IKS_TRANSITIONS_COOLDOWN")
+	_ = v.BindEnv("transitions.debounce", "IKS_TRANSITIONS_DEBOUNCE")
+	_ = v.BindEnv("shadow.config", "IKS_SHADOW_CONFIG")
+	_ = v.BindEnv("git_sync.enabled", "IKS_GIT_SYNC_ENABLED")
+	_ = v.BindEnv("git_sync.repository", "IKS_GIT_SYNC_REPOSITORY")
+	_ = v.BindEnv("git_sync.branch", "IKS_GIT_SYNC_BRANCH")
+	_ = v.BindEnv("remote.provider", "IKS_REMOTE_PROVIDER")
+	_ = v.BindEnv("remote.endpoint", "IKS_REMOTE_ENDPOINT")
+	_ = v.BindEnv("remote.key", "IKS_REMOTE_KEY")
+	_ = v.BindEnv("remote.token", "IKS_REMOTE_TOKEN")
+	_ = v.BindEnv("remote.username", "IKS_REMOTE_USERNAME")
+	_ = v.BindEnv("remote.password", "IKS_REMOTE_PASSWORD")
+	_ = v.BindEnv("control.enabled", "IKS_CONTROL_ENABLED")
+	_ = v.BindEnv("control.username", "IKS_CONTROL_USERNAME")
+	_ = v.BindEnv("control.password", "IKS_CONTROL_PASSWORD")
+	_ = v.BindEnv("guest_links.secret", "IKS_GUEST_LINKS_SECRET")
+	_ = v.BindEnv("guest_links.base_url", "IKS_GUEST_LINKS_BASE_URL")
+	_ = v.BindEnv("state_dir", "IKS_STATE_DIR")
+	_ = v.BindEnv("maintenance.enabled", "IKS_MAINTENANCE_ENABLED")
+	_ = v.BindEnv("forward_auth.enabled", "IKS_FORWARD_AUTH_ENABLED")
+	_ = v.BindEnv("kiosk_auth.password", "IKS_KIOSK_AUTH_PASSWORD")
+	_ = v.BindEnv("signing.secret", "IKS_SIGNING_SECRET")
+	_ = v.BindEnv("devices.stale_after", "IKS_DEVICES_STALE_AFTER")
+	_ = v.BindEnv("email.smtp.host", "IKS_EMAIL_SMTP_HOST")
+	_ = v.BindEnv("email.smtp.username", "IKS_EMAIL_SMTP_USERNAME")
+	_ = v.BindEnv("email.smtp.password", "IKS_EMAIL_SMTP_PASSWORD")
+	_ = v.BindEnv("telegram.token", "IKS_TELEGRAM_TOKEN")
+	_ = v.BindEnv("slash_commands.slack_signing_secret", "IKS_SLASH_COMMANDS_SLACK_SIGNING_SECRET")
+	_ = v.BindEnv("slash_commands.discord_public_key", "IKS_SLASH_COMMANDS_DISCORD_PUBLIC_KEY")
+	_ = v.BindEnv("decision.url", "IKS_DECISION_URL")
+	_ = v.BindEnv("immich.url", "IKS_IMMICH_URL")
+	_ = v.BindEnv("immich.api_key", "IKS_IMMICH_API_KEY")
+	_ = v.BindEnv("home_assistant.url", "IKS_HOME_ASSISTANT_URL")
+	_ = v.BindEnv("home_assistant.token", "IKS_HOME_ASSISTANT_TOKEN")
+	_ = v.BindEnv("mqtt.broker", "IKS_MQTT_BROKER")
+	_ = v.BindEnv("mqtt.username", "IKS_MQTT_USERNAME")
+	_ = v.BindEnv("mqtt.password", "IKS_MQTT_PASSWORD")
+	_ = v.BindEnv("decision.device", "IKS_DECISION_DEVICE")
+	_ = v.BindEnv("maintenance.message", "IKS_MAINTENANCE_MESSAGE")
+	_ = v.BindEnv("metrics.backend", "IKS_METRICS_BACKEND")
+	_ = v.BindEnv("metrics.statsd.address", "IKS_METRICS_STATSD_ADDRESS")
+	_ = v.BindEnv("secrets.age_key_file", "IKS_SECRETS_AGE_KEY_FILE")
+
+	return v
+}
+
+// setDefaults sets the built-in defaults.
+func setDefaults(v *viper.Viper) {
+	v.SetDefault("port", 8080)
+	v.SetDefault("log_level", "info")
+	v.SetDefault("log_format", "auto")
+	v.SetDefault("watch_config", false)
+	v.SetDefault("passthrough_params", []string{})
+	v.SetDefault("passthrough_mode", PassthroughAllowlist)
+	v.SetDefault("blocked_params", []string{})
+	v.SetDefault("schedule", []ScheduleEntry{})
+	v.SetDefault("hooks", []HookConfig{})
+	v.SetDefault("notifications", []NotificationConfig{})
+	v.SetDefault("api_tokens", []APIToken{})
+	v.SetDefault("access_log.sample_rate", 1)
+	v.SetDefault("tracing.enabled", false)
+	v.SetDefault("compression.enabled", true)
+	v.SetDefault("compression.level", 5)
+	v.SetDefault("debug.allow_date_override", false)
+	v.SetDefault("debug.expvar", false)
+	v.SetDefault("health.detail", HealthDetailFull)
+	v.SetDefault("git_sync.enabled", false)
+	v.SetDefault("git_sync.branch", "main")
+	v.SetDefault("git_sync.path", "config.yaml")
+	v.SetDefault("git_sync.interval", "5m")
+	v.SetDefault("remote.format", "yaml")
+	v.SetDefault("control.enabled", false)
+	v.SetDefault("control.albums", []ControlAlbum{})
+	v.SetDefault("party_modes", []PartyMode{})
+	v.SetDefault("info_page.kiosk_user_agents", []string{})
+	v.SetDefault("loop_protection.marker_header", "X-IKS-Instance")
+	v.SetDefault("loop_protection.probe", true)
+	v.SetDefault("forward_auth.enabled", false)
+	v.SetDefault("forward_auth.tokens", []ForwardAuthToken{})
+	v.SetDefault("forward_auth.allowed_networks", []string{})
+	v.SetDefault("forward_auth.trusted_proxies", []string{})
+	v.SetDefault("guest_links.max_validity", "720h")
+	v.SetDefault("home_assistant.interval", DefaultHomeAssistantInterval.String())
+	v.SetDefault("mqtt.client_id", DefaultMQTTClientID)
+	v.SetDefault("mqtt.keep_alive", DefaultMQTTKeepAlive.String())
+	v.SetDefault("decision.timeout", DefaultDecisionTimeout.String())
+	v.SetDefault("decision.cache_ttl", DefaultDecisionCacheTTL.String())
+	v.SetDefault("maintenance.enabled", false)
+	v.SetDefault("metrics.backend", "prometheus")
+	v.SetDefault("metrics.statsd.address", "127.0.0.1:8125")
+	v.SetDefault("metrics.statsd.prefix", "immich_kiosk_scheduler.")
+	v.SetDefault("metrics.statsd.flavor", "statsd")
+	v.SetDefault("metrics.statsd.interval", "10s")
+}
+
+// unmarshal decodes and validates the configuration held by v.
+func unmarshal(v *viper.Viper, source string) (*Config, error) {
+	// Environment variables are expanded, then encrypted values decrypted,
+	// as values are decoded.
+	keyFile, err := interpolate(v.GetString("secrets.age_key_file"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal config: secrets.age_key_file: %w", err)
+	}
+	decrypter := ageDecrypter{keyFile: keyFile, key: os.Getenv(AgeKeyEnv)}
+	hook := mapstructure.ComposeDecodeHookFunc(
+		interpolateHook,
+		decrypter.decodeHook,
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+	)
 
-	var cfg Config
-	if err := v.Unmarshal(&cfg); err != nil {
+	var (
+		cfg      Config
+		metadata mapstructure.Metadata
+	)
+	withMetadata := func(dc *mapstructure.DecoderConfig) {
+		dc.Metadata = &metadata
+	}
+	if err := v.Unmarshal(&cfg, viper.DecodeHook(hook), withMetadata); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
+	if strict.Load() && len(metadata.Unused) > 0 {
+		slices.Sort(metadata.Unused)
+		return nil, fmt.Errorf("unknown configuration keys: %s", strings.Join(metadata.Unused, ", "))
+	}
 
 	if err := cfg.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid configuration: %w", err)
 	}
 
+	cfg.sources = make(map[string]string)
+	for _, key := range v.AllKeys() {
+		switch {
+		case os.Getenv(envName(key)) != "":
+			cfg.sources[key] = SourceEnv
+		case v.InConfig(key):
+			cfg.sources[key] = source
+		case v.IsSet(key):
+			cfg.sources[key] = SourceDefault
+		}
+	}
 	return &cfg, nil
 }
+
+// envName returns the environment variable overriding a setting, e.g.
+// IKS_ACCESS_LOG_SAMPLE_RATE for access_log.sample_rate.
+func envName(key string) string {
+	return "IKS_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
@@ -1,9 +1,11 @@
 package config
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -25,6 +27,146 @@ func TestScheduleEntry_Validate(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "rotating albums",
+			entry: ScheduleEntry{
+				Name:          "party",
+				Albums:        []string{"decor-album", "people-album"},
+				RotateMinutes: 10,
+				Start:         "06-01",
+				End:           "06-01",
+			},
+			wantErr: false,
+		},
+		{
+			name: "album and albums",
+			entry: ScheduleEntry{
+				Name:          "party",
+				Album:         "decor-album",
+				Albums:        []string{"decor-album", "people-album"},
+				RotateMinutes: 10,
+				Start:         "06-01",
+				End:           "06-01",
+			},
+			wantErr: true,
+		},
+		{
+			name: "single rotating album",
+			entry: ScheduleEntry{
+				Name:          "party",
+				Albums:        []string{"decor-album"},
+				RotateMinutes: 10,
+				Start:         "06-01",
+				End:           "06-01",
+			},
+			wantErr: true,
+		},
+		{
+			name: "albums without rotate_minutes",
+			entry: ScheduleEntry{
+				Name:   "party",
+				Albums: []string{"decor-album", "people-album"},
+				Start:  "06-01",
+				End:    "06-01",
+			},
+			wantErr: true,
+		},
+		{
+			name: "rotate_minutes without albums",
+			entry: ScheduleEntry{
+				Name:          "party",
+				Album:         "decor-album",
+				RotateMinutes: 10,
+				Start:         "06-01",
+				End:           "06-01",
+			},
+			wantErr: true,
+		},
+		{
+			name: "recurrence rule without dates",
+			entry: ScheduleEntry{
+				Name:  "camp",
+				Album: "camp-album",
+				RRule: "FREQ=YEARLY;BYMONTH=7;BYDAY=2MO",
+				Days:  7,
+			},
+			wantErr: false,
+		},
+		{
+			name: "recurrence rule within dates",
+			entry: ScheduleEntry{
+				Name:  "summer-weekends",
+				Album: "summer-album",
+				RRule: "FREQ=WEEKLY;BYDAY=SA,SU",
+				Start: "06-01",
+				End:   "08-31",
+			},
+			wantErr: false,
+		},
+		{
+			name: "recurrence rule with only a start",
+			entry: ScheduleEntry{
+				Name:  "summer-weekends",
+				Album: "summer-album",
+				RRule: "FREQ=WEEKLY;BYDAY=SA,SU",
+				Start: "06-01",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid recurrence rule",
+			entry: ScheduleEntry{
+				Name:  "camp",
+				Album: "camp-album",
+				RRule: "FREQ=YEARLY;BYMONTH=7",
+			},
+			wantErr: true,
+		},
+		{
+			name: "days without recurrence rule",
+			entry: ScheduleEntry{
+				Name:  "camp",
+				Album: "camp-album",
+				Start: "07-08",
+				End:   "07-08",
+				Days:  7,
+			},
+			wantErr: true,
+		},
+		{
+			name: "entry params",
+			entry: ScheduleEntry{
+				Name:         "christmas",
+				Album:        "abc-123",
+				Start:        "11-15",
+				End:          "01-01",
+				Params:       map[string]string{"image_effect": "none"},
+				RemoveParams: []string{"duration"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "entry params cannot set album",
+			entry: ScheduleEntry{
+				Name:   "christmas",
+				Album:  "abc-123",
+				Start:  "11-15",
+				End:    "01-01",
+				Params: map[string]string{"album": "other"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid remove param",
+			entry: ScheduleEntry{
+				Name:         "christmas",
+				Album:        "abc-123",
+				Start:        "11-15",
+				End:          "01-01",
+				RemoveParams: []string{"bad name"},
+			},
+			wantErr: true,
+		},
 		{
 			name: "missing name",
 			entry: ScheduleEntry{
@@ -35,132 +177,1272 @@ func TestScheduleEntry_Validate(t *testing.T) {
 			wantErr: true,
 		},
 		{
-			name: "missing album",
-			entry: ScheduleEntry{
-				Name:  "christmas",
-				Start: "11-15",
-				End:   "01-01",
+			name: "missing album",
+			entry: ScheduleEntry{
+				Name:  "christmas",
+				Start: "11-15",
+				End:   "01-01",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid start date format",
+			entry: ScheduleEntry{
+				Name:  "christmas",
+				Album: "abc-123",
+				Start: "2024-11-15",
+				End:   "01-01",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid end date format",
+			entry: ScheduleEntry{
+				Name:  "christmas",
+				Album: "abc-123",
+				Start: "11-15",
+				End:   "january-01",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid month",
+			entry: ScheduleEntry{
+				Name:  "test",
+				Album: "abc-123",
+				Start: "13-15",
+				End:   "01-01",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid day",
+			entry: ScheduleEntry{
+				Name:  "test",
+				Album: "abc-123",
+				Start: "11-32",
+				End:   "01-01",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.entry.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr bool
+	}{
+		{
+			name: "valid config",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				Schedule: []ScheduleEntry{
+					{Name: "test", Album: "abc", Start: "01-01", End: "12-31"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "missing kiosk url",
+			config: Config{
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing default album",
+			config: Config{
+				KioskURL: "https://kiosk.example.com",
+				Port:     8080,
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid port",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         0,
+			},
+			wantErr: true,
+		},
+		{
+			name: "port too high",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         70000,
+			},
+			wantErr: true,
+		},
+		{
+			name: "statsd backend",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				Metrics: MetricsConfig{
+					Backend: "statsd",
+					StatsD:  StatsDConfig{Address: "127.0.0.1:8125", Flavor: "dogstatsd"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "unknown metrics backend",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				Metrics:      MetricsConfig{Backend: "graphite"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "statsd backend without address",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				Metrics:      MetricsConfig{Backend: "statsd"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid schedule entry",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				Schedule: []ScheduleEntry{
+					{Name: "", Album: "abc", Start: "01-01", End: "12-31"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid hook",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				Hooks: []HookConfig{
+					{Name: "ha", URL: "http://homeassistant.local:8123/api/webhook/kiosk", Events: []string{"transition"}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "hook with relative url",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				Hooks:        []HookConfig{{Name: "ha", URL: "/api/webhook"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "git sync",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				GitSync: GitSyncConfig{
+					Enabled: true, Repository: "https://github.com/example/kiosk-config.git",
+					Branch: "main", Path: "kiosk/config.yaml", Interval: 5 * time.Minute,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "git sync without repository",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				GitSync:      GitSyncConfig{Enabled: true, Branch: "main", Path: "config.yaml", Interval: 5 * time.Minute},
+			},
+			wantErr: true,
+		},
+		{
+			name: "git sync path escaping repository",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				GitSync: GitSyncConfig{
+					Enabled: true, Repository: "https://github.com/example/kiosk-config.git",
+					Branch: "main", Path: "../config.yaml", Interval: 5 * time.Minute,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "remote consul",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				Remote:       RemoteConfig{Provider: "consul", Endpoint: "http://127.0.0.1:8500", Key: "kiosk/config", Format: "yaml"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "remote unknown provider",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				Remote:       RemoteConfig{Provider: "zookeeper", Endpoint: "http://127.0.0.1:2181", Key: "kiosk", Format: "yaml"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "remote and git sync together",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				Remote:       RemoteConfig{Provider: "etcd", Endpoint: "http://127.0.0.1:2379", Key: "/kiosk", Format: "yaml"},
+				GitSync: GitSyncConfig{
+					Enabled: true, Repository: "https://github.com/example/kiosk-config.git",
+					Branch: "main", Path: "config.yaml", Interval: 5 * time.Minute,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "short api token",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				APITokens:    []APIToken{{Name: "automation", Token: "short"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid default param",
+			config: Config{
+				KioskURL:      "https://kiosk.example.com",
+				DefaultAlbum:  "default-album-id",
+				Port:          8080,
+				DefaultParams: map[string]string{"album": "other"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "forward auth without tokens or networks",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				ForwardAuth:  ForwardAuthConfig{Enabled: true},
+			},
+			wantErr: true,
+		},
+		{
+			name: "forward auth invalid network",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				ForwardAuth:  ForwardAuthConfig{Enabled: true, AllowedNetworks: []string{"192.168.1.0/33"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "forward auth invalid trusted proxy",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				ForwardAuth: ForwardAuthConfig{
+					Enabled:         true,
+					AllowedNetworks: []string{"192.168.1.0/24"},
+					TrustedProxies:  []string{"traefik"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "forward auth short token",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				ForwardAuth: ForwardAuthConfig{
+					Enabled: true,
+					Tokens:  []ForwardAuthToken{{Name: "frame", Token: "short"}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "decision service",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				Decision:     DecisionConfig{URL: "http://decide.local/album", CacheTTL: time.Minute},
+			},
+			wantErr: false,
+		},
+		{
+			name: "decision service relative url",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				Decision:     DecisionConfig{URL: "/album"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid when condition",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				Schedule: []ScheduleEntry{
+					{Name: "weekend", Album: "weekend-album", Start: "01-01", End: "12-31", When: `weekday == 6`},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "home assistant condition without url",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				Schedule: []ScheduleEntry{
+					{Name: "family", Album: "family-album", Start: "01-01", End: "12-31", When: `ha["person.alex"] == "home"`},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "home assistant without token",
+			config: Config{
+				KioskURL:      "https://kiosk.example.com",
+				DefaultAlbum:  "default-album-id",
+				Port:          8080,
+				HomeAssistant: HomeAssistantConfig{URL: "http://homeassistant.local:8123"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "mqtt condition without broker",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				Schedule: []ScheduleEntry{
+					{Name: "guests", Album: "guest-album", Start: "01-01", End: "12-31", When: `mqtt["home/mode"] == "guest"`},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "mqtt condition with wildcard topic",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				MQTT:         MQTTConfig{Broker: "tcp://mqtt.local:1883"},
+				Schedule: []ScheduleEntry{
+					{Name: "guests", Album: "guest-album", Start: "01-01", End: "12-31", When: `mqtt["home/+"] == "guest"`},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "mqtt condition",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				MQTT:         MQTTConfig{Broker: "tcp://mqtt.local:1883"},
+				Schedule: []ScheduleEntry{
+					{Name: "guests", Album: "guest-album", Start: "01-01", End: "12-31", When: `mqtt["home/mode"] == "guest"`},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "mqtt broker with unsupported scheme",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				MQTT:         MQTTConfig{Broker: "ws://mqtt.local:9001"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "profiles",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				Profiles: []Profile{
+					{Name: "normal"},
+					{Name: "grandparents-visiting", Schedule: []ScheduleEntry{{Name: "family", Album: "family-album", Start: "01-01", End: "12-31"}}},
+				},
+				Profile: "normal",
+			},
+			wantErr: false,
+		},
+		{
+			name: "profiles with schedule",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				Schedule:     []ScheduleEntry{{Name: "family", Album: "family-album", Start: "01-01", End: "12-31"}},
+				Profiles:     []Profile{{Name: "normal"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "duplicate profile name",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				Profiles:     []Profile{{Name: "normal"}, {Name: "normal"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid profile entry",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				Profiles: []Profile{
+					{Name: "normal", Schedule: []ScheduleEntry{{Name: "family", Album: "family-album", Start: "13-01", End: "12-31"}}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown active profile",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				Profiles:     []Profile{{Name: "normal"}},
+				Profile:      "open-house",
+			},
+			wantErr: true,
+		},
+		{
+			name: "quiet hours",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				QuietHours:   QuietHoursConfig{Start: "23:00", End: "07:00", Album: QuietHoursNoAlbum},
+			},
+			wantErr: false,
+		},
+		{
+			name: "quiet hours invalid time",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				QuietHours:   QuietHoursConfig{Start: "25:00", End: "07:00"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "quiet hours without end",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				QuietHours:   QuietHoursConfig{Start: "23:00", Album: "night-album"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "night params",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				NightParams:  []NightParams{{Start: "22:00", End: "06:30", Params: map[string]string{"brightness": "30"}}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "night params without params",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				NightParams:  []NightParams{{Start: "22:00", End: "06:30"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "night params with album",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				NightParams:  []NightParams{{Start: "22:00", End: "06:30", Params: map[string]string{"album": "night"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "immich",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				Immich:       ImmichConfig{URL: "http://immich.local:2283", APIKey: "key"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "immich without api key",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				Immich:       ImmichConfig{URL: "http://immich.local:2283"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "admin listener",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				AdminListen:  "127.0.0.1:8081",
+			},
+			wantErr: false,
+		},
+		{
+			name: "admin listener on the public port",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				AdminListen:  ":8080",
+			},
+			wantErr: true,
+		},
+		{
+			name: "admin listener without port",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				AdminListen:  "127.0.0.1",
+			},
+			wantErr: true,
+		},
+		{
+			name: "metrics port",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				MetricsPort:  9090,
+			},
+			wantErr: false,
+		},
+		{
+			name: "metrics port on the admin listener",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				AdminListen:  "127.0.0.1:9090",
+				MetricsPort:  9090,
+			},
+			wantErr: true,
+		},
+		{
+			name: "metrics port with statsd",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				MetricsPort:  9090,
+				Metrics:      MetricsConfig{Backend: "statsd", StatsD: StatsDConfig{Address: "127.0.0.1:8125"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "kiosk auth",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				KioskAuth:    KioskAuthConfig{Password: "kiosk-secret", Param: "password"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "kiosk auth invalid param",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				KioskAuth:    KioskAuthConfig{Password: "kiosk-secret", Param: "pass word"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "kiosk auth header without forward auth",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				KioskAuth:    KioskAuthConfig{Password: "kiosk-secret", Header: "X-Kiosk-Password"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "cache bust",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				CacheBust:    CacheBustConfig{Mode: CacheBustRevision},
+			},
+			wantErr: false,
+		},
+		{
+			name: "cache bust invalid mode",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				CacheBust:    CacheBustConfig{Mode: "random"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "cache bust param used by signing",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				CacheBust:    CacheBustConfig{Mode: CacheBustTimestamp, Param: "ts"},
+				Signing:      SigningConfig{Secret: "0123456789abcdef0123456789abcdef"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "kiosk config sets albums",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				KioskConfig:  KioskConfigConfig{Enabled: true, Settings: map[string]any{"albums": []string{"x"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "signing",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				Signing:      SigningConfig{Secret: "0123456789abcdef0123456789abcdef"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "signing secret too short",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				Signing:      SigningConfig{Secret: "short"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "signing params equal",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				Signing:      SigningConfig{Secret: "0123456789abcdef0123456789abcdef", Param: "ts"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "device profiles",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				DeviceProfiles: []DeviceProfile{
+					{Name: "eink", Devices: []string{"hallway"}, PassthroughParams: []string{"refresh"}},
+					{Name: "tv", Devices: []string{"living-room"}, DefaultParams: map[string]string{"transition": "fade"}},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "device in two device profiles",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				DeviceProfiles: []DeviceProfile{
+					{Name: "eink", Devices: []string{"hallway"}},
+					{Name: "tv", Devices: []string{"hallway"}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "device profile without devices",
+			config: Config{
+				KioskURL:       "https://kiosk.example.com",
+				DefaultAlbum:   "default-album-id",
+				Port:           8080,
+				DeviceProfiles: []DeviceProfile{{Name: "eink"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "device profile invalid passthrough mode",
+			config: Config{
+				KioskURL:       "https://kiosk.example.com",
+				DefaultAlbum:   "default-album-id",
+				Port:           8080,
+				DeviceProfiles: []DeviceProfile{{Name: "eink", Devices: []string{"hallway"}, PassthroughMode: "all"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "devices stale after",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				Devices:      DevicesConfig{StaleAfter: 15 * time.Minute},
+				Hooks:        []HookConfig{{Name: "alerts", URL: "https://example.com/hook", Events: []string{HookEventDeviceStale}}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "devices stale after too short",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				Devices:      DevicesConfig{StaleAfter: 30 * time.Second},
+			},
+			wantErr: true,
+		},
+		{
+			name: "exclusive end",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				EndBoundary:  EndExclusive,
+				Schedule: []ScheduleEntry{
+					{Name: "winter", Album: "abc", Start: "12-21", End: "03-20"},
+					{Name: "spring", Album: "def", Start: "03-20", End: "06-21", EndBoundary: EndInclusive},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "unknown end boundary",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				EndBoundary:  "open",
+			},
+			wantErr: true,
+		},
+		{
+			name: "exclusive end equal to start",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				Schedule: []ScheduleEntry{
+					{Name: "eve", Album: "abc", Start: "12-24", End: "12-24", EndBoundary: EndExclusive},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "end boundary without dates",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				Schedule: []ScheduleEntry{
+					{Name: "mondays", Album: "abc", RRule: "FREQ=WEEKLY;BYDAY=MO", EndBoundary: EndExclusive},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid min duration",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				Schedule: []ScheduleEntry{
+					{Name: "rain", Album: "abc", Start: "01-01", End: "12-31", MinDuration: "30"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative transition cooldown",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				Transitions:  TransitionsConfig{Cooldown: -time.Minute},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative shutdown timeout",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				Shutdown:     ShutdownConfig{Timeout: -time.Second},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown timezone",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				Timezone:     "Mars/Olympus_Mons",
+			},
+			wantErr: true,
+		},
+		{
+			name: "shutdown flush timeout not below timeout",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				Shutdown:     ShutdownConfig{Timeout: 5 * time.Second, FlushTimeout: 5 * time.Second},
+			},
+			wantErr: true,
+		},
+		{
+			name: "email",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				Email: EmailConfig{
+					SMTP: SMTPConfig{Host: "smtp.example.com", Username: "kiosk", Password: "secret"},
+					From: "Kiosk <kiosk@example.com>",
+					To:   []string{"admin@example.com"},
+					Rules: []EmailRule{
+						{Name: "heads-up", Event: EmailEventUpcoming, Before: 72 * time.Hour},
+						{Name: "stale", Event: HookEventDeviceStale, Subject: "{{.Device.Name}} {{join .Added \",\"}}"},
+					},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "email upcoming without before",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				Email: EmailConfig{
+					SMTP:  SMTPConfig{Host: "smtp.example.com"},
+					From:  "kiosk@example.com",
+					To:    []string{"admin@example.com"},
+					Rules: []EmailRule{{Name: "heads-up", Event: EmailEventUpcoming}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "email invalid template",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				Email: EmailConfig{
+					SMTP:  SMTPConfig{Host: "smtp.example.com"},
+					From:  "kiosk@example.com",
+					To:    []string{"admin@example.com"},
+					Rules: []EmailRule{{Name: "on-transition", Event: HookEventTransition, Body: "{{.Current"}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "email without recipients",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				Email: EmailConfig{
+					SMTP:  SMTPConfig{Host: "smtp.example.com"},
+					From:  "kiosk@example.com",
+					Rules: []EmailRule{{Name: "on-transition", Event: HookEventTransition}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "email rules without smtp host",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				Email: EmailConfig{
+					From:  "kiosk@example.com",
+					To:    []string{"admin@example.com"},
+					Rules: []EmailRule{{Name: "on-transition", Event: HookEventTransition}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid notifications",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				Notifications: []NotificationConfig{
+					{Name: "phones", Provider: NotifyNtfy, Topic: "kiosk-alerts", Events: []string{HookEventDeviceStale}},
+					{Name: "pushover", Provider: NotifyPushover, Token: "app-token", User: "user-key", Priority: NotifyPriorityHigh},
+					{Name: "gotify", Provider: NotifyGotify, URL: "https://gotify.example.com", Token: "app-token"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "ntfy notification without topic",
+			config: Config{
+				KioskURL:      "https://kiosk.example.com",
+				DefaultAlbum:  "default-album-id",
+				Port:          8080,
+				Notifications: []NotificationConfig{{Name: "phones", Provider: NotifyNtfy}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "gotify notification without url",
+			config: Config{
+				KioskURL:      "https://kiosk.example.com",
+				DefaultAlbum:  "default-album-id",
+				Port:          8080,
+				Notifications: []NotificationConfig{{Name: "gotify", Provider: NotifyGotify, Token: "app-token"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "notification with unknown provider",
+			config: Config{
+				KioskURL:      "https://kiosk.example.com",
+				DefaultAlbum:  "default-album-id",
+				Port:          8080,
+				Notifications: []NotificationConfig{{Name: "sms", Provider: "twilio"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "notification with invalid priority",
+			config: Config{
+				KioskURL:      "https://kiosk.example.com",
+				DefaultAlbum:  "default-album-id",
+				Port:          8080,
+				Notifications: []NotificationConfig{{Name: "phones", Provider: NotifyNtfy, Topic: "kiosk", Priority: "urgent"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "telegram without allowed chats",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				Telegram:     TelegramConfig{Token: "123:abc"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid discord public key",
+			config: Config{
+				KioskURL:      "https://kiosk.example.com",
+				DefaultAlbum:  "default-album-id",
+				Port:          8080,
+				SlashCommands: SlashCommandsConfig{DiscordPublicKey: "not-hex"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "empty log redact field",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				LogRedact:    []string{"album", ""},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown health detail",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				Health:       HealthConfig{Detail: "none"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative stats retention",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				Stats:        StatsConfig{RetentionDays: -1},
+			},
+			wantErr: true,
+		},
+		{
+			name: "param map",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				ParamMap:     map[string]string{"t": "transition"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "param map to album",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				ParamMap:     map[string]string{"a": "album"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "param map duplicate target",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				ParamMap:     map[string]string{"t": "transition", "tr": "transition"},
 			},
 			wantErr: true,
 		},
 		{
-			name: "invalid start date format",
-			entry: ScheduleEntry{
-				Name:  "christmas",
-				Album: "abc-123",
-				Start: "2024-11-15",
-				End:   "01-01",
+			name: "unknown passthrough mode",
+			config: Config{
+				KioskURL:        "https://kiosk.example.com",
+				DefaultAlbum:    "default-album-id",
+				Port:            8080,
+				PassthroughMode: "denylist",
 			},
 			wantErr: true,
 		},
 		{
-			name: "invalid end date format",
-			entry: ScheduleEntry{
-				Name:  "christmas",
-				Album: "abc-123",
-				Start: "11-15",
-				End:   "january-01",
+			name: "kiosk url path template",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com/album/{album}",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+			},
+			wantErr: false,
+		},
+		{
+			name: "kiosk url template in query",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com/?albums={album}",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
 			},
 			wantErr: true,
 		},
 		{
-			name: "invalid month",
-			entry: ScheduleEntry{
-				Name:  "test",
-				Album: "abc-123",
-				Start: "13-15",
-				End:   "01-01",
+			name: "kiosk url unknown placeholder",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com/{albun}",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
 			},
 			wantErr: true,
 		},
 		{
-			name: "invalid day",
-			entry: ScheduleEntry{
-				Name:  "test",
-				Album: "abc-123",
-				Start: "11-32",
-				End:   "01-01",
+			name: "kiosk url pointing at the scheduler",
+			config: Config{
+				KioskURL:     "http://127.0.0.1:8080/",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
 			},
 			wantErr: true,
 		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			err := tt.entry.Validate()
-			if tt.wantErr {
-				assert.Error(t, err)
-			} else {
-				assert.NoError(t, err)
-			}
-		})
-	}
-}
-
-func TestConfig_Validate(t *testing.T) {
-	tests := []struct {
-		name    string
-		config  Config
-		wantErr bool
-	}{
 		{
-			name: "valid config",
+			name: "kiosk url on localhost with another port",
 			config: Config{
-				KioskURL:     "https://kiosk.example.com",
+				KioskURL:     "http://localhost:3000",
 				DefaultAlbum: "default-album-id",
 				Port:         8080,
-				Schedule: []ScheduleEntry{
-					{Name: "test", Album: "abc", Start: "01-01", End: "12-31"},
-				},
 			},
 			wantErr: false,
 		},
 		{
-			name: "missing kiosk url",
+			name: "page with file and html",
 			config: Config{
+				KioskURL:     "https://kiosk.example.com",
 				DefaultAlbum: "default-album-id",
 				Port:         8080,
+				Pages:        PagesConfig{Error: PageTemplate{File: "error.html", HTML: "<p>Oops</p>"}},
 			},
 			wantErr: true,
 		},
 		{
-			name: "missing default album",
+			name: "unknown api token role",
 			config: Config{
-				KioskURL: "https://kiosk.example.com",
-				Port:     8080,
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				APITokens:    []APIToken{{Name: "dashboard", Token: "0123456789abcdef", Role: "admin"}},
 			},
 			wantErr: true,
 		},
 		{
-			name: "invalid port",
+			name: "duplicate api token names",
 			config: Config{
 				KioskURL:     "https://kiosk.example.com",
 				DefaultAlbum: "default-album-id",
-				Port:         0,
+				Port:         8080,
+				APITokens: []APIToken{
+					{Name: "automation", Token: "0123456789abcdef"},
+					{Name: "automation", Token: "fedcba9876543210"},
+				},
 			},
 			wantErr: true,
 		},
 		{
-			name: "port too high",
+			name: "hook with unknown event",
 			config: Config{
 				KioskURL:     "https://kiosk.example.com",
 				DefaultAlbum: "default-album-id",
-				Port:         70000,
+				Port:         8080,
+				Hooks:        []HookConfig{{Name: "ha", URL: "http://ha.local/hook", Events: []string{"birthday"}}},
 			},
 			wantErr: true,
 		},
 		{
-			name: "invalid schedule entry",
+			name: "control without albums",
 			config: Config{
 				KioskURL:     "https://kiosk.example.com",
 				DefaultAlbum: "default-album-id",
 				Port:         8080,
-				Schedule: []ScheduleEntry{
-					{Name: "", Album: "abc", Start: "01-01", End: "12-31"},
+				Control:      ControlConfig{Enabled: true},
+			},
+			wantErr: true,
+		},
+		{
+			name: "control with username but no password",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				Control: ControlConfig{
+					Enabled:  true,
+					Username: "family",
+					Albums:   []ControlAlbum{{Name: "Vacation", Album: "vacation-album"}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "control with duplicate album names",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				Control: ControlConfig{
+					Enabled: true,
+					Albums: []ControlAlbum{
+						{Name: "Vacation", Album: "vacation-album"},
+						{Name: "Vacation", Album: "other-album"},
+					},
 				},
 			},
 			wantErr: true,
 		},
+		{
+			name: "party mode with album param",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				PartyModes:   []PartyMode{{Name: "party", Params: map[string]string{"album": "x"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "party mode without album or params",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				PartyModes:   []PartyMode{{Name: "party"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "short guest link secret",
+			config: Config{
+				KioskURL:     "https://kiosk.example.com",
+				DefaultAlbum: "default-album-id",
+				Port:         8080,
+				GuestLinks:   GuestLinksConfig{Secret: "short", MaxValidity: time.Hour},
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -262,6 +1544,10 @@ schedule: []
 	assert.Equal(t, 8080, cfg.Port)
 	assert.Equal(t, "info", cfg.LogLevel)
 	assert.Empty(t, cfg.PassthroughParams)
+	assert.Equal(t, "prometheus", cfg.Metrics.Backend)
+	assert.Equal(t, "127.0.0.1:8125", cfg.Metrics.StatsD.Address)
+	assert.Equal(t, 10*time.Second, cfg.Metrics.StatsD.Interval)
+	assert.False(t, cfg.Debug.AllowDateOverride)
 }
 
 func TestPassthroughParamsSanitization(t *testing.T) {
@@ -289,3 +1575,337 @@ func TestPassthroughParamsSanitization(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadBytes(t *testing.T) {
+	cfg, err := LoadBytes([]byte(`{"kiosk_url": "https://kiosk.example.com", "default_album": "remote-default"}`), "json")
+	require.NoError(t, err)
+
+	assert.Equal(t, "remote-default", cfg.DefaultAlbum)
+	assert.Equal(t, 8080, cfg.Port)
+
+	_, err = LoadBytes([]byte("kiosk_url: [unterminated"), "yaml")
+	assert.Error(t, err)
+}
+
+func TestLoadBytes_Strict(t *testing.T) {
+	typo := []byte(`
+kiosk_url: "https://kiosk.example.com"
+defult_album: "typo"
+default_album: "default-123"
+schedule:
+  - name: christmas
+    albun: "christmas-456"
+    album: "christmas-456"
+    start: "11-15"
+    end: "01-01"
+`)
+	_, err := LoadBytes(typo, "yaml")
+	require.NoError(t, err)
+
+	SetStrict(true)
+	t.Cleanup(func() { SetStrict(false) })
+	_, err = LoadBytes(typo, "yaml")
+	require.Error(t, err)
+	assert.ErrorContains(t, err, "defult_album")
+	assert.ErrorContains(t, err, "albun")
+
+	// Every known key, with the defaults and environment variables, loads.
+	_, err = Load("../../config.example.yaml")
+	assert.NoError(t, err)
+}
+
+func TestLoadEnv(t *testing.T) {
+	t.Setenv("IKS_KIOSK_URL", "https://kiosk.example.com")
+	t.Setenv("IKS_DEFAULT_ALBUM", "env-default")
+
+	cfg, err := LoadEnv()
+	require.NoError(t, err)
+	assert.Equal(t, "env-default", cfg.DefaultAlbum)
+	assert.Empty(t, cfg.Schedule)
+
+	t.Setenv(ConfigDataEnv, `
+default_album: data-default
+schedule:
+  - name: christmas
+    album: christmas-album
+    start: "11-15"
+    end: "01-01"
+`)
+
+	cfg, err = LoadEnv()
+	require.NoError(t, err)
+	assert.Equal(t, "https://kiosk.example.com", cfg.KioskURL)
+	// IKS_* variables still override the configuration data
+	assert.Equal(t, "env-default", cfg.DefaultAlbum)
+	require.Len(t, cfg.Schedule, 1)
+	assert.Equal(t, "christmas", cfg.Schedule[0].Name)
+}
+
+func TestConfig_Clone(t *testing.T) {
+	cfg := &Config{
+		DefaultAlbum: "default-album-id",
+		Schedule:     []ScheduleEntry{{Name: "christmas", Album: "christmas-album", Start: "11-15", End: "01-01"}},
+	}
+
+	clone := cfg.Clone()
+	clone.Schedule[0].Album = "changed"
+	clone.Schedule = append(clone.Schedule, ScheduleEntry{Name: "new"})
+
+	assert.Equal(t, "christmas-album", cfg.Schedule[0].Album)
+	assert.Len(t, cfg.Schedule, 1)
+}
+
+func TestConfig_WithProfile(t *testing.T) {
+	cfg := &Config{
+		DefaultAlbum: "default-album-id",
+		Profiles: []Profile{
+			{Name: "normal", Schedule: []ScheduleEntry{{Name: "christmas", Album: "christmas-album", Start: "11-15", End: "01-01"}}},
+			{Name: "open-house", Schedule: []ScheduleEntry{{Name: "landscapes", Album: "landscape-album", Start: "01-01", End: "12-31"}}},
+		},
+	}
+	assert.Equal(t, "normal", cfg.DefaultProfile())
+	assert.Equal(t, []string{"normal", "open-house"}, cfg.ProfileNames())
+
+	active, err := cfg.WithProfile("open-house")
+	require.NoError(t, err)
+	assert.Equal(t, "landscapes", active.Schedule[0].Name)
+	assert.Empty(t, cfg.Schedule)
+
+	// Changes to the active schedule are stored back into the profile.
+	active.Schedule = append(active.Schedule, ScheduleEntry{Name: "spring", Album: "spring-album", Start: "03-01", End: "05-31"})
+	stored := active.WithoutProfile("open-house")
+	assert.Empty(t, stored.Schedule)
+	assert.Len(t, stored.Profiles[1].Schedule, 2)
+	assert.Len(t, cfg.Profiles[1].Schedule, 1)
+
+	_, err = cfg.WithProfile("missing")
+	assert.Error(t, err)
+
+	// Without profiles, the configuration is used as is.
+	plain := &Config{DefaultAlbum: "default-album-id"}
+	same, err := plain.WithProfile(plain.DefaultProfile())
+	require.NoError(t, err)
+	assert.Same(t, plain, same)
+}
+
+func TestConfig_WithKiosk(t *testing.T) {
+	cfg := &Config{
+		KioskURL:          "https://kiosk.example.com",
+		DefaultAlbum:      "default-album-id",
+		Port:              8080,
+		PassthroughParams: []string{"transition"},
+		DefaultParams:     map[string]string{"duration": "30"},
+		Schedule:          []ScheduleEntry{{Name: "christmas", Album: "christmas-album", Start: "11-15", End: "01-01"}},
+		Kiosks: []Kiosk{
+			{Name: "kitchen", KioskURL: "https://kitchen.example.com", DefaultParams: map[string]string{"show_time": "true"}},
+			{Name: "office", DefaultAlbum: "office-album", PassthroughParams: []string{}, Schedule: []ScheduleEntry{}},
+		},
+	}
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, []string{"kitchen", "office"}, cfg.KioskNames())
+
+	kitchen, err := cfg.WithKiosk("kitchen")
+	require.NoError(t, err)
+	assert.Equal(t, "https://kitchen.example.com", kitchen.KioskURL)
+	assert.Equal(t, "default-album-id", kitchen.DefaultAlbum)
+	assert.Equal(t, []string{"transition"}, kitchen.PassthroughParams)
+	assert.Equal(t, map[string]string{"duration": "30", "show_time": "true"}, kitchen.DefaultParams)
+	assert.Len(t, kitchen.Schedule, 1)
+	assert.Empty(t, kitchen.Kiosks)
+	assert.Equal(t, map[string]string{"duration": "30"}, cfg.DefaultParams)
+
+	office, err := cfg.WithKiosk("office")
+	require.NoError(t, err)
+	assert.Equal(t, "https://kiosk.example.com", office.KioskURL)
+	assert.Equal(t, "office-album", office.DefaultAlbum)
+	assert.Empty(t, office.PassthroughParams)
+	assert.Empty(t, office.Schedule)
+
+	_, err = cfg.WithKiosk("missing")
+	assert.Error(t, err)
+
+	// Kiosks are validated like the top-level configuration.
+	cfg.Kiosks[0].KioskURL = "ftp://kitchen.example.com"
+	assert.ErrorContains(t, cfg.Validate(), "kiosk 0 (kitchen)")
+	cfg.Kiosks[0].KioskURL = ""
+	cfg.Kiosks[1].Name = "kitchen"
+	assert.ErrorContains(t, cfg.Validate(), "more than once")
+	cfg.Kiosks[1].Name = "Office Display"
+	assert.ErrorContains(t, cfg.Validate(), "must be lowercase")
+}
+
+func TestQuietHoursConfig_Window(t *testing.T) {
+	overnight := QuietHoursConfig{Start: "23:00", End: "07:00"}
+	tests := []struct {
+		name  string
+		quiet QuietHoursConfig
+		at    time.Time
+		end   time.Time
+		ok    bool
+	}{
+		{"before midnight", overnight, time.Date(2024, 3, 9, 23, 30, 0, 0, time.UTC), time.Date(2024, 3, 10, 7, 0, 0, 0, time.UTC), true},
+		{"after midnight", overnight, time.Date(2024, 3, 10, 3, 0, 0, 0, time.UTC), time.Date(2024, 3, 10, 7, 0, 0, 0, time.UTC), true},
+		{"end is exclusive", overnight, time.Date(2024, 3, 10, 7, 0, 0, 0, time.UTC), time.Time{}, false},
+		{"daytime", overnight, time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC), time.Time{}, false},
+		{"same day window", QuietHoursConfig{Start: "13:00", End: "15:30"}, time.Date(2024, 3, 10, 13, 0, 0, 0, time.UTC), time.Date(2024, 3, 10, 15, 30, 0, 0, time.UTC), true},
+		{"disabled", QuietHoursConfig{}, time.Date(2024, 3, 10, 3, 0, 0, 0, time.UTC), time.Time{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			end, ok := tt.quiet.Window(tt.at)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.end, end)
+		})
+	}
+}
+
+func TestConfig_Location(t *testing.T) {
+	assert.Equal(t, time.Local, (&Config{}).Location())
+	assert.Equal(t, "Europe/Berlin", (&Config{Timezone: "Europe/Berlin"}).Location().String())
+}
+
+func TestShutdownConfig_DrainTimeout(t *testing.T) {
+	tests := []struct {
+		name     string
+		shutdown ShutdownConfig
+		drain    time.Duration
+	}{
+		{"default", ShutdownConfig{}, 10 * time.Second},
+		{"whole timeout", ShutdownConfig{Timeout: 30 * time.Second}, 30 * time.Second},
+		{"flush timeout", ShutdownConfig{Timeout: 30 * time.Second, FlushTimeout: 10 * time.Second}, 20 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.drain, tt.shutdown.DrainTimeout())
+		})
+	}
+}
+
+func TestConfig_NightParamsAt(t *testing.T) {
+	cfg := Config{NightParams: []NightParams{
+		{Start: "21:00", End: "07:00", Params: map[string]string{"brightness": "40", "duration": "60"}},
+		{Start: "23:00", End: "05:00", Params: map[string]string{"brightness": "10"}},
+	}}
+
+	assert.Nil(t, cfg.NightParamsAt(time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)))
+	assert.Equal(t, map[string]string{"brightness": "40", "duration": "60"},
+		cfg.NightParamsAt(time.Date(2024, 3, 10, 22, 0, 0, 0, time.UTC)))
+	assert.Equal(t, map[string]string{"brightness": "10", "duration": "60"},
+		cfg.NightParamsAt(time.Date(2024, 3, 10, 2, 0, 0, 0, time.UTC)))
+}
+
+func TestConfig_AlbumAliases(t *testing.T) {
+	const christmasID = "5f0c9d4e-2b1a-4c3d-8e7f-6a5b4c3d2e1f"
+	cfg := Config{
+		KioskURL:     "https://kiosk.example.com",
+		DefaultAlbum: "family",
+		Port:         8080,
+		Albums:       map[string]string{"christmas": christmasID, "family": "family-album-id"},
+		Schedule: []ScheduleEntry{
+			{Name: "xmas", Album: "Christmas", Start: "12-01", End: "12-31"},
+			{Name: "new-year", Album: christmasID, Start: "01-01", End: "01-02"},
+		},
+	}
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, christmasID, cfg.AlbumID("Christmas"))
+	assert.Equal(t, "family-album-id", cfg.AlbumID("family"))
+	assert.Equal(t, "other-id", cfg.AlbumID("other-id"))
+
+	cfg.PartyModes = []PartyMode{{Name: "birthday", Album: "birthdays"}}
+	assert.EqualError(t, cfg.Validate(), `party mode birthday: unknown album alias "birthdays"`)
+
+	cfg.PartyModes = nil
+	cfg.Albums["empty"] = ""
+	assert.ErrorContains(t, cfg.Validate(), `alias "empty" has no album ID`)
+}
+
+func TestConfig_ValidateAlbumIDs(t *testing.T) {
+	const familyID = "0a1b2c3d-4e5f-4a6b-8c7d-9e0f1a2b3c4d"
+	cfg := Config{
+		KioskURL:         "https://kiosk.example.com",
+		DefaultAlbum:     familyID,
+		Port:             8080,
+		ValidateAlbumIDs: AlbumIDFormatUUID,
+		Schedule: []ScheduleEntry{
+			{Name: "christmas", Album: "5F0C9D4E-2B1A-4C3D-8E7F-6A5B4C3D2E1F", Start: "12-01", End: "12-31"},
+		},
+	}
+	require.NoError(t, cfg.Validate())
+
+	cfg.Schedule[0].Album = "5f0c9d4e-2b1a-4c3d-8e7f-6a5b4c3d2e1"
+	assert.EqualError(t, cfg.Validate(), `schedule entry christmas: album ID "5f0c9d4e-2b1a-4c3d-8e7f-6a5b4c3d2e1" is not a UUID`)
+
+	// Aliases are checked by the ID they resolve to.
+	cfg.Schedule[0].Album = "christmas"
+	cfg.Albums = map[string]string{"christmas": "https://immich.example.com/albums/5f0c9d4e"}
+	assert.EqualError(t, cfg.Validate(), `albums: alias "christmas": album ID "https://immich.example.com/albums/5f0c9d4e" is not a UUID`)
+	cfg.Albums["christmas"] = "5f0c9d4e-2b1a-4c3d-8e7f-6a5b4c3d2e1f"
+	require.NoError(t, cfg.Validate())
+
+	cfg.ValidateAlbumIDs = "ulid"
+	assert.ErrorContains(t, cfg.Validate(), "validate_album_ids must be")
+}
+
+func TestConfig_WithoutDiscovered(t *testing.T) {
+	cfg := &Config{
+		Schedule: []ScheduleEntry{
+			{Name: "christmas", Album: "christmas-album"},
+			{Name: "easter", Album: "easter-album", Discovered: true},
+		},
+		Profiles: []Profile{{Name: "visitors", Schedule: []ScheduleEntry{{Name: "summer", Discovered: true}}}},
+	}
+
+	stripped := cfg.WithoutDiscovered()
+	assert.Equal(t, []ScheduleEntry{{Name: "christmas", Album: "christmas-album"}}, stripped.Schedule)
+	assert.Empty(t, stripped.Profiles[0].Schedule)
+	assert.Len(t, cfg.Schedule, 2, "the original is unchanged")
+
+	assert.Same(t, stripped, stripped.WithoutDiscovered())
+}
+
+func TestConfig_Revision(t *testing.T) {
+	a := Config{KioskURL: "https://kiosk.example.com", DefaultAlbum: "a", Port: 8080}
+	b := a
+
+	assert.Equal(t, a.Revision(), b.Revision())
+	assert.Len(t, a.Revision(), 16)
+
+	b.Schedule = []ScheduleEntry{{Name: "x", Album: "y", Start: "01-01", End: "01-02"}}
+	assert.NotEqual(t, a.Revision(), b.Revision())
+}
+
+func TestWatch(t *testing.T) {
+	tempDir := t.TempDir()
+	configPath := filepath.Join(tempDir, "config.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte("port: 8080\n"), 0644))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changed := make(chan struct{}, 1)
+	require.NoError(t, Watch(ctx, configPath, func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}))
+
+	// Unrelated files in the same directory are ignored
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "other.yaml"), []byte("x"), 0644))
+	select {
+	case <-changed:
+		t.Fatal("unexpected change notification")
+	case <-time.After(2 * watchDebounce):
+	}
+
+	// Atomic save: write a temp file and rename it over the config
+	tmp := filepath.Join(tempDir, "config.yaml.tmp")
+	require.NoError(t, os.WriteFile(tmp, []byte("port: 9090\n"), 0644))
+	require.NoError(t, os.Rename(tmp, configPath))
+
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected change notification")
+	}
+}
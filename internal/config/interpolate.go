@@ -0,0 +1,70 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// envNameRegex matches the names of environment variables in references.
+var envNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// interpolate expands the environment variable references in s: ${NAME} is
+// replaced by the variable's value and ${NAME:-default} by the default when
+// the variable is unset or empty. $${ is a literal ${. Referencing an unset
+// variable without a default is an error.
+func interpolate(s string) (string, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+
+	var b strings.Builder
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			b.WriteString(s)
+			return b.String(), nil
+		}
+		if i > 0 && s[i-1] == '$' {
+			b.WriteString(s[:i-1])
+			b.WriteString("${")
+			s = s[i+2:]
+			continue
+		}
+		b.WriteString(s[:i])
+
+		end := strings.IndexByte(s[i:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated variable reference %q", s[i:])
+		}
+		ref := s[i+2 : i+end]
+		name, def, hasDefault := strings.Cut(ref, ":-")
+		if !envNameRegex.MatchString(name) {
+			return "", fmt.Errorf("invalid variable reference ${%s}", ref)
+		}
+
+		value, ok := os.LookupEnv(name)
+		if hasDefault && value == "" {
+			value = def
+		} else if !ok {
+			return "", fmt.Errorf("environment variable %s is not set; use ${%s:-default} for a default or $${%s} for a literal", name, name, name)
+		}
+		b.WriteString(value)
+		s = s[i+end+1:]
+	}
+}
+
+// Literal is a string setting decoded as written, without expanding
+// environment variable references, e.g. inline page templates.
+type Literal string
+
+// interpolateHook expands environment variable references in string values
+// as they are decoded, except in Literal settings.
+func interpolateHook(from, to reflect.Type, data any) (any, error) {
+	if from.Kind() != reflect.String || to == reflect.TypeFor[Literal]() {
+		return data, nil
+	}
+	return interpolate(reflect.ValueOf(data).String())
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterpolate(t *testing.T) {
+	t.Setenv("KIOSK_HOST", "kiosk.example.com")
+	t.Setenv("EMPTY", "")
+
+	tests := []struct {
+		in      string
+		want    string
+		wantErr string
+	}{
+		{in: "https://kiosk.example.com", want: "https://kiosk.example.com"},
+		{in: "https://${KIOSK_HOST}/", want: "https://kiosk.example.com/"},
+		{in: "${KIOSK_HOST}:${KIOSK_HOST}", want: "kiosk.example.com:kiosk.example.com"},
+		{in: "${MISSING:-fallback}", want: "fallback"},
+		{in: "${EMPTY:-fallback}", want: "fallback"},
+		{in: "${EMPTY}", want: ""},
+		{in: "$${KIOSK_HOST}", want: "${KIOSK_HOST}"},
+		{in: "pa$$word $HOME", want: "pa$$word $HOME"},
+		{in: "${MISSING}", wantErr: "environment variable MISSING is not set"},
+		{in: "${KIOSK_HOST", wantErr: "unterminated"},
+		{in: "${1BAD}", wantErr: "invalid variable reference"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := interpolate(tt.in)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestLoad_Interpolation(t *testing.T) {
+	t.Setenv("KIOSK_HOST", "kiosk.example.com")
+	t.Setenv("SCHEDULER_PORT", "9090")
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `kiosk_url: "https://${KIOSK_HOST}"
+default_album: "${DEFAULT_ALBUM:-default-album-id}"
+port: ${SCHEDULER_PORT}
+passthrough_params: ["${PARAM:-transition}"]
+`
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+	cfg, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, "https://kiosk.example.com", cfg.KioskURL)
+	assert.Equal(t, "default-album-id", cfg.DefaultAlbum)
+	assert.Equal(t, 9090, cfg.Port)
+	assert.Equal(t, []string{"transition"}, cfg.PassthroughParams)
+
+	require.NoError(t, os.WriteFile(path, []byte("kiosk_url: \"https://${UNSET_HOST}\"\ndefault_album: x\n"), 0644))
+	_, err = Load(path)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "kiosk_url")
+	assert.Contains(t, err.Error(), "UNSET_HOST is not set")
+}
+
+func TestLoad_InterpolationSkipsPageTemplates(t *testing.T) {
+	t.Setenv("X", "expanded")
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `kiosk_url: "https://kiosk.example.com"
+default_album: "${X}"
+pages:
+  not_found:
+    html: '<style nonce="{{ .Nonce }}">p::after { content: "${X}"; }</style><p>${X}</p>'
+`
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+
+	cfg, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, "expanded", cfg.DefaultAlbum)
+	assert.Equal(t, Literal(`<style nonce="{{ .Nonce }}">p::after { content: "${X}"; }</style><p>${X}</p>`), cfg.Pages.NotFound.HTML)
+}
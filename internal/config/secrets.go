@@ -0,0 +1,122 @@
+package config
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+
+	"filippo.io/age"
+)
+
+// Encrypted values have the form ENC[age:<base64 age ciphertext>].
+const (
+	encryptedPrefix = "ENC[age:"
+	encryptedSuffix = "]"
+)
+
+// AgeKeyEnv is the environment variable holding the age key, as an
+// alternative to secrets.age_key_file.
+const AgeKeyEnv = "IKS_SECRETS_AGE_KEY"
+
+// SecretsConfig configures the decryption of encrypted values.
+type SecretsConfig struct {
+	// AgeKeyFile is the age identity file decrypting ENC[age:...] values.
+	AgeKeyFile string `mapstructure:"age_key_file"`
+}
+
+// IsEncrypted reports whether value is encrypted.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, encryptedPrefix) && strings.HasSuffix(value, encryptedSuffix)
+}
+
+// EncryptValue encrypts a value to the given age recipients (age1...), for
+// use in the configuration.
+func EncryptValue(plaintext string, recipients []string) (string, error) {
+	if len(recipients) == 0 {
+		return "", fmt.Errorf("at least one recipient is required")
+	}
+	parsed := make([]age.Recipient, 0, len(recipients))
+	for _, r := range recipients {
+		recipient, err := age.ParseX25519Recipient(strings.TrimSpace(r))
+		if err != nil {
+			return "", fmt.Errorf("invalid recipient %q: %w", r, err)
+		}
+		parsed = append(parsed, recipient)
+	}
+
+	var ciphertext bytes.Buffer
+	w, err := age.Encrypt(&ciphertext, parsed...)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.WriteString(w, plaintext); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(ciphertext.Bytes()) + encryptedSuffix, nil
+}
+
+// ageDecrypter decrypts values with the key read from keyFile, or with key.
+type ageDecrypter struct {
+	keyFile string
+	key     string
+}
+
+// decrypt decrypts an encrypted value.
+func (d ageDecrypter) decrypt(value string) (string, error) {
+	encoded := strings.TrimSuffix(strings.TrimPrefix(value, encryptedPrefix), encryptedSuffix)
+	ciphertext, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("invalid encrypted value: %w", err)
+	}
+
+	identities, err := d.identities()
+	if err != nil {
+		return "", err
+	}
+	r, err := age.Decrypt(bytes.NewReader(ciphertext), identities...)
+	if err != nil {
+		return "", fmt.Errorf("age decrypt: %w", err)
+	}
+	plaintext, err := io.ReadAll(r)
+	if err != nil {
+		return "", fmt.Errorf("age decrypt: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// identities parses the age identities of the key file or key.
+func (d ageDecrypter) identities() ([]age.Identity, error) {
+	key := []byte(d.key)
+	if d.keyFile != "" {
+		var err error
+		if key, err = os.ReadFile(d.keyFile); err != nil {
+			return nil, fmt.Errorf("failed to read age key: %w", err)
+		}
+	} else if d.key == "" {
+		return nil, fmt.Errorf("found an encrypted value, but no age key: set secrets.age_key_file or %s", AgeKeyEnv)
+	}
+	identities, err := age.ParseIdentities(bytes.NewReader(key))
+	if err != nil {
+		return nil, fmt.Errorf("invalid age key: %w", err)
+	}
+	return identities, nil
+}
+
+// decodeHook decrypts the encrypted values being decoded into strings.
+func (d ageDecrypter) decodeHook(from, to reflect.Type, data any) (any, error) {
+	if from.Kind() != reflect.String || to.Kind() != reflect.String {
+		return data, nil
+	}
+	value := reflect.ValueOf(data).String()
+	if !IsEncrypted(value) {
+		return data, nil
+	}
+	return d.decrypt(value)
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newAgeKey returns a new age identity and the value s3cret encrypted to it.
+func newAgeKey(t *testing.T) (*age.X25519Identity, string) {
+	t.Helper()
+	identity, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+	value, err := EncryptValue("s3cret", []string{identity.Recipient().String()})
+	require.NoError(t, err)
+	return identity, value
+}
+
+// encryptedConfig writes a config file with an encrypted metrics password.
+func encryptedConfig(t *testing.T, password, extra string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := "kiosk_url: https://kiosk.example.com\ndefault_album: default-album-id\n" +
+		"metrics_username: prometheus\nmetrics_password: \"" + password + "\"\n" +
+		"hooks:\n  - name: n8n\n    url: https://n8n.local/hook\n    headers:\n      Authorization: \"" + password + "\"\n" + extra
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestLoad_EncryptedValues(t *testing.T) {
+	identity, password := newAgeKey(t)
+	keyFile := filepath.Join(t.TempDir(), "key.txt")
+	require.NoError(t, os.WriteFile(keyFile, []byte("# created: 2025-01-01\n"+identity.String()+"\n"), 0600))
+
+	cfg, err := Load(encryptedConfig(t, password, "secrets:\n  age_key_file: "+keyFile+"\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", cfg.MetricsPassword)
+	assert.Equal(t, "s3cret", cfg.Hooks[0].Headers["authorization"])
+	assert.Equal(t, "prometheus", cfg.MetricsUsername)
+}
+
+func TestLoad_EncryptedValuesKeyFromEnv(t *testing.T) {
+	identity, password := newAgeKey(t)
+	t.Setenv(AgeKeyEnv, identity.String())
+
+	cfg, err := Load(encryptedConfig(t, password, ""))
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", cfg.MetricsPassword)
+}
+
+func TestLoad_EncryptedValuesErrors(t *testing.T) {
+	_, password := newAgeKey(t)
+
+	_, err := Load(encryptedConfig(t, password, ""))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no age key")
+	assert.Contains(t, err.Error(), "metrics_password")
+
+	other, _ := newAgeKey(t)
+	t.Setenv(AgeKeyEnv, other.String())
+	_, err = Load(encryptedConfig(t, password, ""))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no identity matched")
+
+	t.Setenv(AgeKeyEnv, "AGE-SECRET-KEY-1WRONG")
+	_, err = Load(encryptedConfig(t, password, ""))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid age key")
+}
+
+func TestEncryptValue(t *testing.T) {
+	identity, value := newAgeKey(t)
+	assert.True(t, IsEncrypted(value))
+
+	plaintext, err := ageDecrypter{key: identity.String()}.decrypt(value)
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", plaintext)
+
+	_, err = EncryptValue("s3cret", nil)
+	assert.Error(t, err)
+	_, err = EncryptValue("s3cret", []string{"age1example"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,198 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// redacted replaces secret values in redacted settings.
+const redacted = "[redacted]"
+
+// secretKeys are the setting keys holding secrets, in any section.
+var secretKeys = map[string]bool{
+	"password":             true,
+	"token":                true,
+	"secret":               true,
+	"api_key":              true,
+	"metrics_password":     true,
+	"slack_signing_secret": true,
+}
+
+// Sources of settings, as reported by Sources.
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	// SourceData is configuration data read by LoadBytes, e.g. from a
+	// remote store or IKS_CONFIG_DATA.
+	SourceData = "data"
+	SourceEnv  = "env"
+	SourceFlag = "flag"
+	// SourceAPI marks settings changed at runtime through the admin API.
+	SourceAPI = "api"
+)
+
+// durationType is the type of duration settings, shown as strings.
+var durationType = reflect.TypeFor[time.Duration]()
+
+// Settings returns the configuration as nested maps keyed like the
+// configuration file, e.g. for printing the effective configuration.
+func (c *Config) Settings() map[string]any {
+	return settingsOf(reflect.ValueOf(*c)).(map[string]any)
+}
+
+// RedactedSettings returns the settings with secrets, such as passwords,
+// tokens and request headers, replaced. Unset secrets are left empty.
+func (c *Config) RedactedSettings() map[string]any {
+	settings := c.Settings()
+	redactSettings(settings)
+	return settings
+}
+
+// Sources returns where each setting was taken from, keyed like the settings
+// with dots, e.g. forward_auth.enabled. Lists such as schedule have a single
+// key. Settings never given a value are left out, as are all settings of a
+// configuration that was not loaded with Load or LoadBytes.
+func (c *Config) Sources() map[string]string {
+	return maps.Clone(c.sources)
+}
+
+// SetSource records where a setting was taken from, replacing the sources
+// of the settings below it.
+func (c *Config) SetSource(key, source string) {
+	sources := make(map[string]string, len(c.sources)+1)
+	for k, s := range c.sources {
+		if !strings.HasPrefix(k, key+".") {
+			sources[k] = s
+		}
+	}
+	sources[key] = source
+	c.sources = sources
+}
+
+// MarkChanged records source for the settings that differ from previous.
+func (c *Config) MarkChanged(previous *Config, source string) {
+	before := flattenSettings(previous.Settings(), "", nil)
+	for key, value := range flattenSettings(c.Settings(), "", nil) {
+		if old, ok := before[key]; !ok || !reflect.DeepEqual(old, value) {
+			c.SetSource(key, source)
+		}
+	}
+}
+
+// flattenSettings adds settings to flat, keyed with dots like Sources.
+func flattenSettings(settings map[string]any, prefix string, flat map[string]any) map[string]any {
+	if flat == nil {
+		flat = make(map[string]any)
+	}
+	for key, value := range settings {
+		if nested, ok := value.(map[string]any); ok && len(nested) > 0 {
+			flattenSettings(nested, prefix+key+".", flat)
+			continue
+		}
+		flat[prefix+key] = value
+	}
+	return flat
+}
+
+// DefaultSettings returns the settings of a configuration holding only the
+// built-in defaults.
+func DefaultSettings() (map[string]any, error) {
+	v := viper.New()
+	setDefaults(v)
+	var cfg Config
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal defaults: %w", err)
+	}
+	return cfg.Settings(), nil
+}
+
+// settingsOf converts a configuration value to settings, naming struct
+// fields after their mapstructure tags.
+func settingsOf(v reflect.Value) any {
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		settings := make(map[string]any, v.NumField())
+		for i := range v.NumField() {
+			field := v.Type().Field(i)
+			name, _, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+			if !field.IsExported() || name == "" || name == "-" {
+				continue
+			}
+			settings[name] = settingsOf(v.Field(i))
+		}
+		return settings
+	case reflect.Slice:
+		list := make([]any, v.Len())
+		for i := range v.Len() {
+			list[i] = settingsOf(v.Index(i))
+		}
+		return list
+	case reflect.Map:
+		settings := make(map[string]any, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			settings[fmt.Sprint(iter.Key().Interface())] = settingsOf(iter.Value())
+		}
+		return settings
+	case reflect.Pointer:
+		if v.IsNil() {
+			return nil
+		}
+		return settingsOf(v.Elem())
+	default:
+		return v.Interface()
+	}
+}
+
+// RedactURL returns a URL without the credentials in its user info, e.g. a
+// token in https://token@host/repo.git. Other values are returned unchanged.
+func RedactURL(value string) string {
+	if !strings.Contains(value, "@") {
+		return value
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.User == nil || u.Host == "" {
+		return value
+	}
+	u.User = nil
+	return u.String()
+}
+
+// redactSettings replaces the secrets in settings. All header values are
+// secret, since they commonly carry credentials.
+func redactSettings(settings map[string]any) {
+	for key, value := range settings {
+		switch value := value.(type) {
+		case string:
+			if secretKeys[key] && value != "" {
+				settings[key] = redacted
+			} else {
+				settings[key] = RedactURL(value)
+			}
+		case map[string]any:
+			if key == "headers" {
+				for name := range value {
+					value[name] = redacted
+				}
+				continue
+			}
+			redactSettings(value)
+		case []any:
+			for _, item := range value {
+				if item, ok := item.(map[string]any); ok {
+					redactSettings(item)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce coalesces the burst of events editors emit for a single save.
+const watchDebounce = 500 * time.Millisecond
+
+// Watch calls onChange whenever the config file at path changes, until the
+// context is cancelled. The containing directory is watched so that atomic
+// saves (write to temp file + rename) and Kubernetes ConfigMap symlink swaps
+// are detected.
+func Watch(ctx context.Context, path string, onChange func()) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create file watcher: %w", err)
+	}
+
+	cleanPath := filepath.Clean(path)
+	if err := watcher.Add(filepath.Dir(cleanPath)); err != nil {
+		watcher.Close()
+		return fmt.Errorf("failed to watch %s: %w", filepath.Dir(cleanPath), err)
+	}
+
+	go func() {
+		defer watcher.Close()
+
+		realPath, _ := filepath.EvalSymlinks(cleanPath)
+		var debounce *time.Timer
+
+		for {
+			select {
+			case <-ctx.Done():
+				if debounce != nil {
+					debounce.Stop()
+				}
+				return
+
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+
+				currentPath, _ := filepath.EvalSymlinks(cleanPath)
+				written := filepath.Clean(event.Name) == cleanPath && event.Has(fsnotify.Write|fsnotify.Create)
+				relinked := currentPath != "" && currentPath != realPath
+				if !written && !relinked {
+					continue
+				}
+				realPath = currentPath
+
+				if debounce != nil {
+					debounce.Stop()
+				}
+				debounce = time.AfterFunc(watchDebounce, onChange)
+
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				slog.Warn("config file watcher error", slog.Any("error", err))
+			}
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,286 @@
+// Package decision asks an external HTTP service which album to show,
+// falling back to the local schedule when the service is unavailable.
+package decision
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/scheduler"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/tracing"
+)
+
+// retryDelay is how long the fallback decision is used after a failed
+// request before the service is asked again.
+const retryDelay = 30 * time.Second
+
+// maxResponseSize bounds the response body read from the service.
+const maxResponseSize = 64 << 10
+
+// maxCached bounds the decisions a Backend caches, since devices are named
+// by clients.
+const maxCached = 1024
+
+// Decision service metrics
+var requestsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "immich_kiosk_scheduler_decision_requests_total",
+		Help: "Total number of decision service requests by result (success, failure)",
+	},
+	[]string{"result"},
+)
+
+func init() {
+	prometheus.MustRegister(requestsTotal)
+}
+
+// Selection is a decision as exchanged with the service.
+type Selection struct {
+	Schedule string `json:"schedule"`
+	Album    string `json:"album"`
+}
+
+// Request is the JSON body posted to the service.
+type Request struct {
+	Time time.Time `json:"time"`
+	Date string    `json:"date"`
+	// Device is the requesting display, or the configured device for
+	// requests without one.
+	Device string `json:"device,omitempty"`
+	// Local is the decision of the local schedule.
+	Local Selection `json:"local"`
+}
+
+// Response is the JSON body the service answers with. Album is required;
+// Schedule defaults to "remote" and TTL, in seconds, to the configured
+// cache_ttl.
+type Response struct {
+	Schedule string `json:"schedule"`
+	Album    string `json:"album"`
+	TTL      int    `json:"ttl"`
+}
+
+// remoteSchedule names decisions for which the service returned no schedule.
+const remoteSchedule = "remote"
+
+// Backend resolves decisions with the service. Transitions and entries come
+// from the fallback, since the service only answers for a point in time.
+// Decisions are cached per device, and the service is asked at most once at
+// a time per device, without blocking other devices.
+type Backend struct {
+	cfg      config.DecisionConfig
+	fallback scheduler.Backend
+	client   *http.Client
+	logger   *slog.Logger
+
+	mu       sync.Mutex
+	cache    map[string]cached
+	inflight map[string]*call
+	// generation counts Reset calls, so a request started before one does
+	// not fill the cache after it.
+	generation uint64
+}
+
+// cached is a decision made at from.
+type cached struct {
+	from     time.Time
+	decision scheduler.Decision
+}
+
+// call is a request to the service in flight, whose decision is set when
+// done is closed.
+type call struct {
+	done     chan struct{}
+	decision scheduler.Decision
+}
+
+var _ scheduler.Backend = (*Backend)(nil)
+
+// New creates a Backend for the configured service.
+func New(cfg config.DecisionConfig, fallback scheduler.Backend, logger *slog.Logger) *Backend {
+	return &Backend{
+		cfg:      cfg,
+		fallback: fallback,
+		client:   &http.Client{Transport: tracing.NewTransport(nil)},
+		logger:   logger,
+		cache:    make(map[string]cached),
+		inflight: make(map[string]*call),
+	}
+}
+
+// Resolve returns the service's decision for t for the configured device.
+func (b *Backend) Resolve(t time.Time) scheduler.Decision {
+	return b.ResolveDevice(t, "")
+}
+
+// ResolveDevice returns the service's decision for t for the requesting
+// device, or the configured one when device is empty, reusing the device's
+// last decision while it is valid. Concurrent calls for a device share one
+// request. When the service fails, the fallback decides and the service is
+// asked again after a short delay.
+func (b *Backend) ResolveDevice(t time.Time, device string) scheduler.Decision {
+	if device == "" {
+		device = b.cfg.Device
+	}
+
+	b.mu.Lock()
+	if c, ok := b.cache[device]; ok && !t.Before(c.from) && t.Before(c.decision.Until) {
+		b.mu.Unlock()
+		return c.decision
+	}
+	if c, ok := b.inflight[device]; ok {
+		b.mu.Unlock()
+		<-c.done
+		return c.decision
+	}
+	c := &call{done: make(chan struct{})}
+	b.inflight[device] = c
+	generation := b.generation
+	b.mu.Unlock()
+
+	c.decision = b.decide(t, device)
+
+	b.mu.Lock()
+	delete(b.inflight, device)
+	if generation == b.generation {
+		b.store(device, cached{from: t, decision: c.decision})
+	}
+	b.mu.Unlock()
+	close(c.done)
+	return c.decision
+}
+
+// Reset drops the cached decisions, so the service is asked again.
+func (b *Backend) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	clear(b.cache)
+	b.generation++
+}
+
+// store caches the decision for device, dropping expired decisions when the
+// cache is full. The caller must hold b.mu.
+func (b *Backend) store(device string, c cached) {
+	if _, ok := b.cache[device]; !ok && len(b.cache) >= maxCached {
+		maps.DeleteFunc(b.cache, func(_ string, old cached) bool {
+			return !c.from.Before(old.decision.Until)
+		})
+		if len(b.cache) >= maxCached {
+			return
+		}
+	}
+	b.cache[device] = c
+}
+
+// decide asks the service for the decision for device at t, falling back to
+// the local schedule.
+func (b *Backend) decide(t time.Time, device string) scheduler.Decision {
+	local := b.fallback.Resolve(t)
+	d, err := b.fetch(t, device, local)
+	if err != nil {
+		requestsTotal.WithLabelValues("failure").Inc()
+		b.logger.Warn("decision service failed, using local schedule",
+			slog.String("url", b.cfg.URL), slog.Any("error", err))
+		d = local
+		d.Until = earliest(local.Until, t.Add(retryDelay))
+	} else {
+		requestsTotal.WithLabelValues("success").Inc()
+	}
+	return d
+}
+
+// NextTransition returns the fallback's next transition.
+func (b *Backend) NextTransition(t time.Time) (time.Time, scheduler.Decision, bool) {
+	return b.fallback.NextTransition(t)
+}
+
+// List returns the fallback's entries.
+func (b *Backend) List() []config.ScheduleEntry {
+	return b.fallback.List()
+}
+
+// fetch asks the service for the decision for device at t. The decision
+// holds until its ttl expires, and never past the end of the local decision,
+// since the date sent to the service changes then.
+func (b *Backend) fetch(t time.Time, device string, local scheduler.Decision) (scheduler.Decision, error) {
+	body, err := json.Marshal(Request{
+		Time:   t,
+		Date:   t.Format(time.DateOnly),
+		Device: device,
+		Local:  Selection{Schedule: local.Schedule, Album: local.Album},
+	})
+	if err != nil {
+		return scheduler.Decision{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), b.cfg.RequestTimeout())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return scheduler.Decision{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "immich-kiosk-scheduler")
+	for k, v := range b.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return scheduler.Decision{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return scheduler.Decision{}, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	var res Response
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&res); err != nil {
+		return scheduler.Decision{}, fmt.Errorf("invalid response: %w", err)
+	}
+	if strings.TrimSpace(res.Album) == "" {
+		return scheduler.Decision{}, fmt.Errorf("invalid response: album is required")
+	}
+
+	ttl := b.cfg.TTL()
+	if res.TTL > 0 {
+		ttl = time.Duration(res.TTL) * time.Second
+	}
+	d := scheduler.Decision{
+		Schedule: res.Schedule,
+		Album:    res.Album,
+		Until:    earliest(local.Until, t.Add(ttl)),
+	}
+	if d.Schedule == "" {
+		d.Schedule = remoteSchedule
+	}
+	// A decision naming a local entry picks up that entry's parameters.
+	entries := b.fallback.List()
+	for i := range entries {
+		if entries[i].Name == d.Schedule {
+			d.Entry = &entries[i]
+			break
+		}
+	}
+	return d, nil
+}
+
+// earliest returns the earlier of two times.
+func earliest(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
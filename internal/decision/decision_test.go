@@ -0,0 +1,195 @@
+package decision
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/scheduler"
+)
+
+func newFallback(t *testing.T) *scheduler.Scheduler {
+	t.Helper()
+	s, err := scheduler.New(&config.Config{
+		DefaultAlbum: "default-album",
+		Schedule: []config.ScheduleEntry{
+			{Name: "christmas", Album: "christmas-album", Start: "12-01", End: "12-26", Params: map[string]string{"transition": "fade"}},
+		},
+	})
+	require.NoError(t, err)
+	return s
+}
+
+func newBackend(t *testing.T, url string, fallback scheduler.Backend) *Backend {
+	t.Helper()
+	cfg := config.DecisionConfig{URL: url, Device: "living-room", Headers: map[string]string{"Authorization": "Bearer secret"}}
+	return New(cfg, fallback, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestBackend_Resolve(t *testing.T) {
+	var calls atomic.Int32
+	service := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+
+		var req Request
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, "2024-12-24", req.Date)
+		assert.Equal(t, "living-room", req.Device)
+		assert.Equal(t, Selection{Schedule: "christmas", Album: "christmas-album"}, req.Local)
+
+		_ = json.NewEncoder(w).Encode(Response{Schedule: "christmas", Album: "family-christmas", TTL: 60})
+	}))
+	defer service.Close()
+
+	b := newBackend(t, service.URL, newFallback(t))
+	at := time.Date(2024, 12, 24, 9, 0, 0, 0, time.UTC)
+
+	d := b.Resolve(at)
+	assert.Equal(t, "christmas", d.Schedule)
+	assert.Equal(t, "family-christmas", d.Album)
+	assert.Equal(t, at.Add(time.Minute), d.Until)
+	require.NotNil(t, d.Entry)
+	assert.Equal(t, "fade", d.Entry.Params["transition"])
+
+	// The decision is reused until its ttl expires.
+	b.Resolve(at.Add(30 * time.Second))
+	assert.Equal(t, int32(1), calls.Load())
+	b.Resolve(at.Add(time.Minute))
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestBackend_ResolveUntilEndOfDay(t *testing.T) {
+	service := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `{"album": "evening-album"}`)
+	}))
+	defer service.Close()
+
+	b := newBackend(t, service.URL, newFallback(t))
+	d := b.Resolve(time.Date(2024, 7, 1, 23, 58, 0, 0, time.UTC))
+	assert.Equal(t, remoteSchedule, d.Schedule)
+	assert.Nil(t, d.Entry)
+	assert.Equal(t, time.Date(2024, 7, 2, 0, 0, 0, 0, time.UTC), d.Until)
+}
+
+func TestBackend_Fallback(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{"server error", func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "boom", http.StatusInternalServerError)
+		}},
+		{"invalid json", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, `not json`)
+		}},
+		{"missing album", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, `{"schedule": "christmas"}`)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			service := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				tt.handler(w, r)
+			}))
+			defer service.Close()
+
+			b := newBackend(t, service.URL, newFallback(t))
+			at := time.Date(2024, 12, 24, 9, 0, 0, 0, time.UTC)
+
+			d := b.Resolve(at)
+			assert.Equal(t, "christmas", d.Schedule)
+			assert.Equal(t, "christmas-album", d.Album)
+			assert.Equal(t, at.Add(retryDelay), d.Until)
+
+			// The service is retried once the delay has passed.
+			b.Resolve(at.Add(time.Second))
+			assert.Equal(t, int32(1), calls.Load())
+			b.Resolve(at.Add(retryDelay))
+			assert.Equal(t, int32(2), calls.Load())
+		})
+	}
+}
+
+func TestBackend_DelegatesTransitions(t *testing.T) {
+	fallback := newFallback(t)
+	b := newBackend(t, "http://127.0.0.1:1", fallback)
+
+	at, next, ok := b.NextTransition(time.Date(2024, 12, 24, 9, 0, 0, 0, time.UTC))
+	require.True(t, ok)
+	assert.Equal(t, time.Date(2024, 12, 27, 0, 0, 0, 0, time.UTC), at)
+	assert.Equal(t, scheduler.DefaultSchedule, next.Schedule)
+	assert.Equal(t, fallback.List(), b.List())
+}
+
+func TestBackend_ResolveDevice(t *testing.T) {
+	var calls atomic.Int32
+	service := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		var req Request
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		_ = json.NewEncoder(w).Encode(Response{Album: req.Device + "-album", TTL: 60})
+	}))
+	defer service.Close()
+
+	b := newBackend(t, service.URL, newFallback(t))
+	at := time.Date(2024, 12, 24, 9, 0, 0, 0, time.UTC)
+
+	// Each device is asked for and cached on its own; requests without a
+	// device use the configured one.
+	assert.Equal(t, "kitchen-album", b.ResolveDevice(at, "kitchen").Album)
+	assert.Equal(t, "hallway-album", b.ResolveDevice(at, "hallway").Album)
+	assert.Equal(t, "living-room-album", b.Resolve(at).Album)
+	assert.Equal(t, "kitchen-album", b.ResolveDevice(at.Add(time.Second), "kitchen").Album)
+	assert.Equal(t, int32(3), calls.Load())
+
+	// Reset drops the cached decisions.
+	b.Reset()
+	b.ResolveDevice(at.Add(time.Second), "kitchen")
+	assert.Equal(t, int32(4), calls.Load())
+}
+
+func TestBackend_ResolveDoesNotBlockOtherDevices(t *testing.T) {
+	release := make(chan struct{})
+	var calls atomic.Int32
+	service := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		if req.Device == "slow" {
+			calls.Add(1)
+			<-release
+		}
+		_ = json.NewEncoder(w).Encode(Response{Album: req.Device + "-album"})
+	}))
+	defer service.Close()
+
+	b := newBackend(t, service.URL, newFallback(t))
+	at := time.Date(2024, 12, 24, 9, 0, 0, 0, time.UTC)
+
+	results := make(chan string, 2)
+	for range 2 {
+		go func() { results <- b.ResolveDevice(at, "slow").Album }()
+	}
+	require.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, time.Millisecond)
+
+	// Another device is answered while the slow request is in flight.
+	assert.Equal(t, "fast-album", b.ResolveDevice(at, "fast").Album)
+
+	close(release)
+	assert.Equal(t, "slow-album", <-results)
+	assert.Equal(t, "slow-album", <-results)
+	// Concurrent calls for a device share one request.
+	assert.Equal(t, int32(1), calls.Load())
+}
@@ -0,0 +1,276 @@
+// Package email sends notification emails of schedule and device events
+// over SMTP, rendered from the templates of the matching rules.
+package email
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/hooks"
+)
+
+// sendTimeout bounds the delivery of one email.
+const sendTimeout = 30 * time.Second
+
+// Email metrics
+var deliveriesTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "immich_kiosk_scheduler_email_deliveries_total",
+		Help: "Total number of notification emails by rule and result",
+	},
+	[]string{"rule", "result"},
+)
+
+func init() {
+	prometheus.MustRegister(deliveriesTotal)
+}
+
+// Default templates by event.
+var (
+	defaultSubjects = map[string]string{
+		config.HookEventTransition:      `Kiosk now showing {{.Current.Schedule}}`,
+		config.EmailEventUpcoming:       `Kiosk switches to {{.Current.Schedule}} on {{.At.Format "Monday, 2 January"}}`,
+		config.HookEventDiscovery:       `Kiosk schedule entries discovered in Immich`,
+		config.HookEventDeviceStale:     `Display {{.Device.Name}} stopped polling`,
+		config.HookEventDeviceRecovered: `Display {{.Device.Name}} is back`,
+	}
+	defaultBodies = map[string]string{
+		config.HookEventTransition: `The kiosk switched from {{.Previous.Schedule}} to {{.Current.Schedule}} on {{.At.Format "Monday, 2 January 2006 at 15:04"}}.
+
+Album: {{or .CurrentAlbumName .Current.Album}}
+`,
+		config.EmailEventUpcoming: `The kiosk switches from {{.Previous.Schedule}} to {{.Current.Schedule}} on {{.At.Format "Monday, 2 January 2006"}}.
+
+Album: {{or .CurrentAlbumName .Current.Album}}
+`,
+		config.HookEventDiscovery: `Schedule entries changed after discovering Immich albums.
+{{with .Added}}
+Added: {{join . ", "}}{{end}}{{with .Removed}}
+Removed: {{join . ", "}}{{end}}
+`,
+		config.HookEventDeviceStale: `Display {{.Device.Name}} ({{.Device.ID}}) has not asked for a slideshow since {{with .Device.LastSeen}}{{.Format "Monday, 2 January 2006 at 15:04"}}{{else}}the scheduler started{{end}}.
+
+It was last showing {{or .Current.Schedule "nothing yet"}}.
+`,
+		config.HookEventDeviceRecovered: `Display {{.Device.Name}} ({{.Device.ID}}) is polling again and now shows {{.Current.Schedule}}.
+`,
+	}
+)
+
+// Data is passed to the subject and body templates.
+type Data struct {
+	hooks.Event
+	// At is when the transition happens, for upcoming events, or when the
+	// event occurred.
+	At time.Time
+	// PreviousAlbumName and CurrentAlbumName are the Immich album names,
+	// where known.
+	PreviousAlbumName string
+	CurrentAlbumName  string
+}
+
+// Message is a rendered email.
+type Message struct {
+	Rule    string
+	From    string
+	To      []string
+	Subject string
+	Body    string
+}
+
+// Render renders the message of a rule for data.
+func Render(cfg config.EmailConfig, rule config.EmailRule, data Data) (Message, error) {
+	subject, err := render("subject", cmp.Or(rule.Subject, defaultSubjects[rule.Event]), data)
+	if err != nil {
+		return Message{}, err
+	}
+	body, err := render("body", cmp.Or(rule.Body, defaultBodies[rule.Event]), data)
+	if err != nil {
+		return Message{}, err
+	}
+	to := rule.To
+	if len(to) == 0 {
+		to = cfg.To
+	}
+	// Headers must be a single line.
+	subject = strings.Join(strings.Fields(subject), " ")
+	return Message{Rule: rule.Name, From: cfg.From, To: to, Subject: subject, Body: body}, nil
+}
+
+func render(name, text string, data Data) (string, error) {
+	tmpl, err := template.New(name).Funcs(config.EmailTemplateFuncs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s template: %w", name, err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render %s: %w", name, err)
+	}
+	return buf.String(), nil
+}
+
+// Matches reports whether a rule applies to an event.
+func Matches(rule config.EmailRule, event hooks.Event) bool {
+	if rule.Event != event.Event {
+		return false
+	}
+	return len(rule.Schedules) == 0 || slices.Contains(rule.Schedules, event.Current.Schedule)
+}
+
+// Bytes returns the message in RFC 5322 format, as sent.
+func (m Message) Bytes(date time.Time) []byte {
+	var buf bytes.Buffer
+	header := func(name, value string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", name, value)
+	}
+	header("From", m.From)
+	header("To", strings.Join(m.To, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	header("Date", date.Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+	header("Content-Type", `text/plain; charset="utf-8"`)
+	header("Content-Transfer-Encoding", "quoted-printable")
+	header("Auto-Submitted", "auto-generated")
+	buf.WriteString("\r\n")
+	qp := quotedprintable.NewWriter(&buf)
+	_, _ = qp.Write([]byte(strings.ReplaceAll(m.Body, "\n", "\r\n")))
+	_ = qp.Close()
+	return buf.Bytes()
+}
+
+// Notifier delivers emails asynchronously.
+type Notifier struct {
+	logger *slog.Logger
+	wg     sync.WaitGroup
+}
+
+// NewNotifier creates a Notifier.
+func NewNotifier(logger *slog.Logger) *Notifier {
+	return &Notifier{logger: logger}
+}
+
+// Notify sends the emails of the rules matching the event without
+// blocking. Failures are logged and counted; deliveries are not retried.
+func (n *Notifier) Notify(cfg config.EmailConfig, data Data) {
+	if !cfg.Enabled() {
+		return
+	}
+	for _, rule := range cfg.Rules {
+		if Matches(rule, data.Event) {
+			n.Send(cfg, rule, data)
+		}
+	}
+}
+
+// Send renders and sends the email of a rule without blocking.
+func (n *Notifier) Send(cfg config.EmailConfig, rule config.EmailRule, data Data) {
+	msg, err := Render(cfg, rule, data)
+	if err != nil {
+		deliveriesTotal.WithLabelValues(rule.Name, "failure").Inc()
+		n.logger.Warn("email rendering failed", slog.String("rule", rule.Name), slog.Any("error", err))
+		return
+	}
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+		defer cancel()
+		if err := n.deliver(ctx, cfg.SMTP, msg); err != nil {
+			deliveriesTotal.WithLabelValues(rule.Name, "failure").Inc()
+			n.logger.Warn("email delivery failed",
+				slog.String("rule", rule.Name),
+				slog.String("event", data.Event.Event),
+				slog.Any("error", err),
+			)
+			return
+		}
+		deliveriesTotal.WithLabelValues(rule.Name, "success").Inc()
+		n.logger.Debug("email delivered", slog.String("rule", rule.Name), slog.String("event", data.Event.Event))
+	}()
+}
+
+// Wait blocks until all in-flight deliveries have finished.
+func (n *Notifier) Wait() {
+	n.wg.Wait()
+}
+
+// deliver sends a message through the mail server.
+func (n *Notifier) deliver(ctx context.Context, cfg config.SMTPConfig, msg Message) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", cfg.Address())
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	tlsConfig := &tls.Config{ServerName: cfg.Host, MinVersion: tls.VersionTLS12}
+	if cfg.Security() == config.SMTPTLS {
+		conn = tls.Client(conn, tlsConfig)
+	}
+	c, err := smtp.NewClient(conn, cfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if cfg.Security() == config.SMTPStartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("%s does not support STARTTLS; set smtp.tls to tls or none", cfg.Host)
+		}
+		if err := c.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if cfg.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)); err != nil {
+			return fmt.Errorf("authentication failed: %w", err)
+		}
+	}
+
+	from, err := mail.ParseAddress(msg.From)
+	if err != nil {
+		return err
+	}
+	if err := c.Mail(from.Address); err != nil {
+		return err
+	}
+	for _, to := range msg.To {
+		addr, err := mail.ParseAddress(to)
+		if err != nil {
+			return err
+		}
+		if err := c.Rcpt(addr.Address); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg.Bytes(time.Now())); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
@@ -0,0 +1,190 @@
+package email
+
+import (
+	"log/slog"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/hooks"
+)
+
+// smtpSession is what a fake mail server received.
+type smtpSession struct {
+	auth bool
+	from string
+	to   []string
+	data string
+}
+
+// serveSMTP runs a fake mail server accepting one message without TLS.
+func serveSMTP(t *testing.T) (config.SMTPConfig, <-chan smtpSession) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+
+	sessions := make(chan smtpSession, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		tp := textproto.NewConn(conn)
+		var s smtpSession
+		_ = tp.PrintfLine("220 test ESMTP")
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			cmd, arg, _ := strings.Cut(line, " ")
+			switch strings.ToUpper(cmd) {
+			case "EHLO", "HELO":
+				_ = tp.PrintfLine("250-test")
+				_ = tp.PrintfLine("250 AUTH PLAIN")
+			case "AUTH":
+				s.auth = true
+				_ = tp.PrintfLine("235 authenticated")
+			case "MAIL":
+				s.from = arg
+				_ = tp.PrintfLine("250 ok")
+			case "RCPT":
+				s.to = append(s.to, arg)
+				_ = tp.PrintfLine("250 ok")
+			case "DATA":
+				_ = tp.PrintfLine("354 go ahead")
+				lines, err := tp.ReadDotLines()
+				if err != nil {
+					return
+				}
+				s.data = strings.Join(lines, "\n")
+				_ = tp.PrintfLine("250 queued")
+			case "QUIT":
+				_ = tp.PrintfLine("221 bye")
+				sessions <- s
+				return
+			default:
+				_ = tp.PrintfLine("502 unsupported")
+			}
+		}
+	}()
+
+	host, port, err := net.SplitHostPort(l.Addr().String())
+	require.NoError(t, err)
+	p, err := strconv.Atoi(port)
+	require.NoError(t, err)
+	return config.SMTPConfig{Host: host, Port: p, TLS: config.SMTPNone}, sessions
+}
+
+func TestNotifier_Notify(t *testing.T) {
+	smtp, sessions := serveSMTP(t)
+	smtp.Username, smtp.Password = "kiosk", "secret"
+	cfg := config.EmailConfig{
+		SMTP: smtp,
+		From: "Kiosk <kiosk@example.com>",
+		To:   []string{"admin@example.com"},
+		Rules: []config.EmailRule{
+			{Name: "on-transition", Event: config.HookEventTransition, Schedules: []string{"christmas"}},
+			{Name: "stale", Event: config.HookEventDeviceStale},
+		},
+	}
+
+	n := NewNotifier(slog.Default())
+	at := time.Date(2024, 11, 15, 0, 0, 12, 0, time.UTC)
+	n.Notify(cfg, Data{
+		Event: hooks.Event{
+			Event:    config.HookEventTransition,
+			Time:     at,
+			Previous: hooks.Selection{Schedule: "fall", Album: "fall-album"},
+			Current:  hooks.Selection{Schedule: "christmas", Album: "christmas-album"},
+		},
+		At:               at,
+		CurrentAlbumName: "Christmas 🎄",
+	})
+	n.Wait()
+
+	require.Len(t, sessions, 1)
+	s := <-sessions
+	assert.True(t, s.auth)
+	assert.Equal(t, "FROM:<kiosk@example.com>", s.from)
+	assert.Equal(t, []string{"TO:<admin@example.com>"}, s.to)
+	assert.Contains(t, s.data, "Subject: Kiosk now showing christmas\n")
+	assert.Contains(t, s.data, "The kiosk switched from fall to christmas on Friday, 15 November 2024")
+	assert.Contains(t, s.data, "Album: Christmas =F0=9F=8E=84")
+}
+
+func TestNotify_NoMatchingRule(t *testing.T) {
+	cfg := config.EmailConfig{
+		// Nothing listens here; a delivery attempt would fail the test.
+		SMTP:  config.SMTPConfig{Host: "127.0.0.1", Port: 1, TLS: config.SMTPNone},
+		From:  "kiosk@example.com",
+		To:    []string{"admin@example.com"},
+		Rules: []config.EmailRule{{Name: "on-transition", Event: config.HookEventTransition, Schedules: []string{"christmas"}}},
+	}
+	n := NewNotifier(slog.Default())
+	before := deliveries(t, "on-transition")
+	n.Notify(cfg, Data{Event: hooks.Event{Event: config.HookEventTransition, Current: hooks.Selection{Schedule: "fall"}}})
+	n.Notify(cfg, Data{Event: hooks.Event{Event: config.HookEventDiscovery, Current: hooks.Selection{Schedule: "christmas"}}})
+	n.Wait()
+	assert.Equal(t, before, deliveries(t, "on-transition"))
+}
+
+func deliveries(t *testing.T, rule string) float64 {
+	t.Helper()
+	var total float64
+	for _, result := range []string{"success", "failure"} {
+		m, err := deliveriesTotal.GetMetricWithLabelValues(rule, result)
+		require.NoError(t, err)
+		total += testutil.ToFloat64(m)
+	}
+	return total
+}
+
+func TestRender(t *testing.T) {
+	cfg := config.EmailConfig{From: "kiosk@example.com", To: []string{"admin@example.com"}}
+	data := Data{
+		Event: hooks.Event{
+			Event:    config.EmailEventUpcoming,
+			Previous: hooks.Selection{Schedule: "fall"},
+			Current:  hooks.Selection{Schedule: "christmas", Album: "christmas-album"},
+		},
+		At: time.Date(2024, 11, 15, 0, 0, 0, 0, time.UTC),
+	}
+
+	msg, err := Render(cfg, config.EmailRule{Name: "heads-up", Event: config.EmailEventUpcoming}, data)
+	require.NoError(t, err)
+	assert.Equal(t, "Kiosk switches to christmas on Friday, 15 November", msg.Subject)
+	assert.Equal(t, "The kiosk switches from fall to christmas on Friday, 15 November 2024.\n\nAlbum: christmas-album\n", msg.Body)
+	assert.Equal(t, []string{"admin@example.com"}, msg.To)
+
+	msg, err = Render(cfg, config.EmailRule{
+		Name:    "custom",
+		Event:   config.EmailEventUpcoming,
+		To:      []string{"family@example.com"},
+		Subject: "{{.Current.Schedule}}\n  soon",
+		Body:    "Get the decorations out!",
+	}, data)
+	require.NoError(t, err)
+	assert.Equal(t, "christmas soon", msg.Subject, "folded to one line")
+	assert.Equal(t, "Get the decorations out!", msg.Body)
+	assert.Equal(t, []string{"family@example.com"}, msg.To)
+}
+
+func TestMessage_Bytes(t *testing.T) {
+	msg := Message{From: "kiosk@example.com", To: []string{"a@example.com", "b@example.com"}, Subject: "Grüße", Body: "line 1\nline 2\n"}
+	data := string(msg.Bytes(time.Date(2024, 12, 24, 18, 0, 0, 0, time.UTC)))
+	assert.Contains(t, data, "To: a@example.com, b@example.com\r\n")
+	assert.Contains(t, data, "Subject: =?utf-8?q?Gr=C3=BC=C3=9Fe?=\r\n")
+	assert.Contains(t, data, "Date: Tue, 24 Dec 2024 18:00:00 +0000\r\n")
+	assert.True(t, strings.HasSuffix(data, "\r\n\r\nline 1\r\nline 2\r\n"))
+}
@@ -0,0 +1,243 @@
+// Package gitsync keeps a local checkout of a Git repository holding the
+// configuration up to date, so the schedule can live in a reviewed repository.
+package gitsync
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+)
+
+// gitTimeout bounds a single git invocation.
+const gitTimeout = 2 * time.Minute
+
+// Git sync metrics
+var (
+	syncsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "immich_kiosk_scheduler_git_sync_total",
+			Help: "Total number of Git sync attempts by result",
+		},
+		[]string{"result"},
+	)
+
+	lastSuccessTimestamp = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "immich_kiosk_scheduler_git_sync_last_success_timestamp_seconds",
+			Help: "Unix time of the last successful Git sync",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(syncsTotal)
+	prometheus.MustRegister(lastSuccessTimestamp)
+}
+
+// Status describes the outcome of Git syncs.
+type Status struct {
+	// Repository is the repository URL without credentials.
+	Repository  string     `json:"repository"`
+	Branch      string     `json:"branch"`
+	Commit      string     `json:"commit,omitempty"` // last applied commit
+	LastAttempt *time.Time `json:"last_attempt,omitempty"`
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+}
+
+// Syncer pulls a Git repository on an interval and applies new commits.
+type Syncer struct {
+	cfg    config.GitSyncConfig
+	dir    string
+	logger *slog.Logger
+	// repository is the repository URL without credentials, for logs and
+	// the status.
+	repository string
+
+	mu       sync.Mutex
+	status   Status
+	rejected string // commit whose configuration failed to apply
+}
+
+// New creates a Syncer. The checkout lives in cfg.Directory, or in a
+// directory under the system temp directory when unset.
+func New(cfg config.GitSyncConfig, logger *slog.Logger) *Syncer {
+	dir := cfg.Directory
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "immich-kiosk-scheduler-git")
+	}
+	repository := config.RedactURL(cfg.Repository)
+	return &Syncer{
+		cfg:        cfg,
+		dir:        dir,
+		logger:     logger,
+		repository: repository,
+		status:     Status{Repository: repository, Branch: cfg.Branch},
+	}
+}
+
+// ConfigPath returns the path of the configuration file in the local checkout.
+func (s *Syncer) ConfigPath() string {
+	return filepath.Join(s.dir, s.cfg.Path)
+}
+
+// Synced reports whether a commit has been applied successfully.
+func (s *Syncer) Synced() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status.Commit != ""
+}
+
+// Status returns the current sync status.
+func (s *Syncer) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+// Run syncs immediately and then on every interval until the context is
+// cancelled. apply is called with the configuration file path whenever a new
+// commit is checked out; a commit it rejects is not retried until the
+// branch moves again.
+func (s *Syncer) Run(ctx context.Context, apply func(path string) error) {
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		s.SyncOnce(ctx, apply)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SyncOnce pulls the repository and applies the configuration if the
+// checked out commit changed.
+func (s *Syncer) SyncOnce(ctx context.Context, apply func(path string) error) {
+	commit, err := s.pull(ctx)
+	if err != nil {
+		s.record("", err)
+		return
+	}
+
+	s.mu.Lock()
+	applied, rejected := s.status.Commit, s.rejected
+	s.mu.Unlock()
+
+	switch commit {
+	case applied:
+		s.record(commit, nil)
+		return
+	case rejected:
+		// Keep reporting why this commit was rejected until the branch moves.
+		s.touch()
+		return
+	}
+
+	s.logger.Info("applying configuration from git",
+		slog.String("repository", s.repository),
+		slog.String("commit", commit),
+	)
+	if err := apply(s.ConfigPath()); err != nil {
+		s.mu.Lock()
+		s.rejected = commit
+		s.mu.Unlock()
+		s.record("", fmt.Errorf("commit %s: %w", shortCommit(commit), err))
+		return
+	}
+	s.record(commit, nil)
+}
+
+// touch updates the last attempt time without changing the outcome.
+func (s *Syncer) touch() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.status.LastAttempt = &now
+}
+
+// record stores the outcome of a sync; commit is the applied commit on success.
+func (s *Syncer) record(commit string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.status.LastAttempt = &now
+	if err != nil {
+		syncsTotal.WithLabelValues("failure").Inc()
+		s.status.LastError = err.Error()
+		s.logger.Error("git sync failed", slog.String("repository", s.repository), slog.Any("error", err))
+		return
+	}
+
+	syncsTotal.WithLabelValues("success").Inc()
+	lastSuccessTimestamp.Set(float64(now.Unix()))
+	s.status.LastSuccess = &now
+	s.status.LastError = ""
+	s.status.Commit = commit
+	s.rejected = ""
+}
+
+// pull clones the repository on first use and fast-forwards it to the remote
+// branch afterwards, returning the checked out commit.
+func (s *Syncer) pull(ctx context.Context) (string, error) {
+	if _, err := os.Stat(filepath.Join(s.dir, ".git")); err != nil {
+		if err := os.RemoveAll(s.dir); err != nil {
+			return "", fmt.Errorf("failed to clear checkout directory: %w", err)
+		}
+		if _, err := s.git(ctx, "", "clone", "--quiet", "--depth", "1", "--branch", s.cfg.Branch, s.cfg.Repository, s.dir); err != nil {
+			return "", err
+		}
+	} else {
+		if _, err := s.git(ctx, s.dir, "fetch", "--quiet", "--depth", "1", "origin", s.cfg.Branch); err != nil {
+			return "", err
+		}
+		if _, err := s.git(ctx, s.dir, "reset", "--quiet", "--hard", "FETCH_HEAD"); err != nil {
+			return "", err
+		}
+	}
+	return s.git(ctx, s.dir, "rev-parse", "HEAD")
+}
+
+// git runs a git command and returns its trimmed standard output.
+func (s *Syncer) git(ctx context.Context, dir string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, gitTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	// Never prompt for credentials; use URL credentials, a credential helper or SSH keys.
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		// Git repeats the repository URL, with its credentials, in errors.
+		msg := strings.ReplaceAll(strings.TrimSpace(stderr.String()), s.cfg.Repository, s.repository)
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, msg)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// shortCommit abbreviates a commit hash for messages.
+func shortCommit(commit string) string {
+	if len(commit) > 12 {
+		return commit[:12]
+	}
+	return commit
+}
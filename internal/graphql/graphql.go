@@ -0,0 +1,520 @@
+// Package graphql executes read-only GraphQL queries against a schema of
+// resolver functions. It implements what dashboards need to fetch several
+// resources in one request: queries with fields, aliases, arguments,
+// variables, fragments, @include and @skip, and __typename. Mutations,
+// subscriptions and introspection are not supported.
+package graphql
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// maxDepth bounds the nesting of selection sets.
+const maxDepth = 16
+
+// Object is an object type of a schema.
+type Object struct {
+	Name   string
+	Fields map[string]*Field
+}
+
+// Field is a field of an object type.
+type Field struct {
+	// Type is the object type of the field's value, or nil for scalars,
+	// which are returned as JSON. A slice of objects is a list.
+	Type *Object
+	// Args holds the accepted arguments and their defaults; a nil default
+	// leaves the argument out unless it is given.
+	Args map[string]any
+	// Resolve returns the field's value from its parent's value. When nil,
+	// the field is read from the struct field or map key of the same JSON
+	// name.
+	Resolve func(parent any, args map[string]any) (any, error)
+}
+
+// Schema is a read-only schema whose queries start at Query.
+type Schema struct {
+	Query *Object
+}
+
+// Request is a GraphQL request as sent over HTTP.
+type Request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+// Response is the result of a request. Data is nil when the request could
+// not be executed; resolver errors leave their fields null instead.
+type Response struct {
+	Data   any     `json:"data,omitempty"`
+	Errors []Error `json:"errors,omitempty"`
+}
+
+// Error is an error in a request or while resolving a field.
+type Error struct {
+	Message   string     `json:"message"`
+	Locations []Location `json:"locations,omitempty"`
+	// Path is the response path of the field whose resolver failed.
+	Path []any `json:"path,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Location is a position in a query.
+type Location struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// Execute runs a request. Requests that do not parse or validate return
+// only errors.
+func (s *Schema) Execute(req Request) Response {
+	doc, err := parse(req.Query)
+	if err != nil {
+		return Response{Errors: []Error{*err}}
+	}
+	op, err := doc.operation(req.OperationName)
+	if err != nil {
+		return Response{Errors: []Error{*err}}
+	}
+	ex := &executor{doc: doc, src: req.Query}
+	if err := ex.coerceVariables(op, req.Variables); err != nil {
+		return Response{Errors: []Error{*err}}
+	}
+	if errs := ex.validate(s.Query, op.selections, 0); len(errs) > 0 {
+		return Response{Errors: errs}
+	}
+	data := ex.selectionSet(s.Query, nil, op.selections, nil)
+	return Response{Data: data, Errors: ex.errors}
+}
+
+// operation returns the operation to run: the named one, or the only one.
+func (d *document) operation(name string) (*operation, *Error) {
+	var op *operation
+	switch {
+	case name != "":
+		i := slices.IndexFunc(d.operations, func(o *operation) bool { return o.name == name })
+		if i < 0 {
+			return nil, &Error{Message: fmt.Sprintf("Unknown operation named %q.", name)}
+		}
+		op = d.operations[i]
+	case len(d.operations) > 1:
+		return nil, &Error{Message: "Must provide operation name if query contains multiple operations."}
+	default:
+		op = d.operations[0]
+	}
+	if op.kind != "query" {
+		return nil, &Error{Message: fmt.Sprintf("Only queries are supported, not %ss.", op.kind)}
+	}
+	return op, nil
+}
+
+type executor struct {
+	doc *document
+	src string
+	// vars holds the variable values; defined holds the declared variables.
+	vars    map[string]any
+	defined map[string]bool
+	errors  []Error
+}
+
+// coerceVariables applies defaults to the provided variable values and
+// checks that required ones are set.
+func (ex *executor) coerceVariables(op *operation, provided map[string]any) *Error {
+	ex.vars = map[string]any{}
+	ex.defined = map[string]bool{}
+	for _, def := range op.variables {
+		ex.defined[def.name] = true
+		v, ok := provided[def.name]
+		switch {
+		case ok:
+			ex.vars[def.name] = v
+		case def.hasDefault:
+			ex.vars[def.name] = def.def
+		case def.nonNull:
+			return &Error{Message: fmt.Sprintf("Variable \"$%s\" of required type was not provided.", def.name)}
+		}
+		if def.nonNull && ok && v == nil {
+			return &Error{Message: fmt.Sprintf("Variable \"$%s\" of non-null type must not be null.", def.name)}
+		}
+	}
+	return nil
+}
+
+// value substitutes variables in a parsed value, reporting false when it is
+// an unset variable.
+func (ex *executor) value(v any) (any, bool) {
+	switch v := v.(type) {
+	case variable:
+		val, ok := ex.vars[string(v)]
+		return val, ok
+	case []any:
+		list := make([]any, 0, len(v))
+		for _, item := range v {
+			val, _ := ex.value(item)
+			list = append(list, val)
+		}
+		return list, true
+	case map[string]any:
+		obj := make(map[string]any, len(v))
+		for k, item := range v {
+			if val, ok := ex.value(item); ok {
+				obj[k] = val
+			}
+		}
+		return obj, true
+	default:
+		return v, true
+	}
+}
+
+// undefinedVariable returns the name of a variable referenced by v that the
+// operation does not declare.
+func (ex *executor) undefinedVariable(v any) (string, bool) {
+	switch v := v.(type) {
+	case variable:
+		return string(v), !ex.defined[string(v)]
+	case []any:
+		for _, item := range v {
+			if name, ok := ex.undefinedVariable(item); ok {
+				return name, true
+			}
+		}
+	case map[string]any:
+		for _, item := range v {
+			if name, ok := ex.undefinedVariable(item); ok {
+				return name, true
+			}
+		}
+	}
+	return "", false
+}
+
+// included evaluates the @include and @skip directives of a selection.
+func (ex *executor) included(sel *selection) (bool, *Error) {
+	for _, d := range sel.directives {
+		if d.name != "include" && d.name != "skip" {
+			return false, ex.errorAt(d.pos, "Unknown directive \"@%s\".", d.name)
+		}
+		v, _ := ex.value(d.args["if"])
+		cond, ok := v.(bool)
+		if !ok {
+			return false, ex.errorAt(d.pos, "Directive \"@%s\" requires a Boolean argument \"if\".", d.name)
+		}
+		if cond == (d.name == "skip") {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// fieldGroup holds the fields selected under one response key.
+type fieldGroup struct {
+	key    string
+	fields []*selection
+}
+
+// collect gathers the fields a selection set selects on obj, following
+// fragments, in the order of their first selection.
+func (ex *executor) collect(obj *Object, sels []selection, visited map[string]bool, groups []fieldGroup) ([]fieldGroup, *Error) {
+	for i := range sels {
+		sel := &sels[i]
+		ok, err := ex.included(sel)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		switch sel.kind {
+		case selField:
+			key := sel.key()
+			j := slices.IndexFunc(groups, func(g fieldGroup) bool { return g.key == key })
+			if j < 0 {
+				groups = append(groups, fieldGroup{key: key})
+				j = len(groups) - 1
+			}
+			groups[j].fields = append(groups[j].fields, sel)
+		case selSpread:
+			if visited[sel.name] {
+				continue
+			}
+			f, ok := ex.doc.fragments[sel.name]
+			if !ok {
+				return nil, ex.errorAt(sel.pos, "Unknown fragment %q.", sel.name)
+			}
+			visited[sel.name] = true
+			if f.typeCond != obj.Name {
+				continue
+			}
+			if groups, err = ex.collect(obj, f.selections, visited, groups); err != nil {
+				return nil, err
+			}
+		case selInline:
+			if sel.typeCond != "" && sel.typeCond != obj.Name {
+				continue
+			}
+			if groups, err = ex.collect(obj, sel.selections, visited, groups); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return groups, nil
+}
+
+// subselections merges the selection sets of the fields in a group.
+func (g *fieldGroup) subselections() []selection {
+	if len(g.fields) == 1 {
+		return g.fields[0].selections
+	}
+	var sels []selection
+	for _, f := range g.fields {
+		sels = append(sels, f.selections...)
+	}
+	return sels
+}
+
+// validate checks a selection set against obj.
+func (ex *executor) validate(obj *Object, sels []selection, depth int) []Error {
+	if depth > maxDepth {
+		return []Error{*ex.errorAt(sels[0].pos, "The query is nested more than %d levels deep.", maxDepth)}
+	}
+	groups, err := ex.collect(obj, sels, map[string]bool{}, nil)
+	if err != nil {
+		return []Error{*err}
+	}
+	var errs []Error
+	for _, g := range groups {
+		first := g.fields[0]
+		if first.name == "__typename" {
+			if len(first.selections) > 0 {
+				errs = append(errs, *ex.errorAt(first.pos, "Field \"__typename\" must not have a selection since type \"String\" has no subfields."))
+			}
+			continue
+		}
+		def, ok := obj.Fields[first.name]
+		if !ok {
+			errs = append(errs, *ex.errorAt(first.pos, "Cannot query field %q on type %q.", first.name, obj.Name))
+			continue
+		}
+		for _, f := range g.fields {
+			if f.name != first.name {
+				errs = append(errs, *ex.errorAt(f.pos, "Fields %q conflict because %q and %q are different fields.", g.key, first.name, f.name))
+			}
+			for name, v := range f.args {
+				if _, ok := def.Args[name]; !ok {
+					errs = append(errs, *ex.errorAt(f.pos, "Unknown argument %q on field \"%s.%s\".", name, obj.Name, f.name))
+				}
+				if undefined, ok := ex.undefinedVariable(v); ok {
+					errs = append(errs, *ex.errorAt(f.pos, "Variable \"$%s\" is not defined.", undefined))
+				}
+			}
+		}
+		switch subs := g.subselections(); {
+		case def.Type == nil && len(subs) > 0:
+			errs = append(errs, *ex.errorAt(first.pos, "Field %q must not have a selection since it is a scalar.", first.name))
+		case def.Type != nil && len(subs) == 0:
+			errs = append(errs, *ex.errorAt(first.pos, "Field %q of type %q must have a selection of subfields.", first.name, def.Type.Name))
+		case def.Type != nil:
+			errs = append(errs, ex.validate(def.Type, subs, depth+1)...)
+		}
+	}
+	return errs
+}
+
+func (ex *executor) errorAt(pos int, format string, args ...any) *Error {
+	return &Error{Message: fmt.Sprintf(format, args...), Locations: []Location{location(ex.src, pos)}}
+}
+
+// selectionSet resolves a validated selection set on parent.
+func (ex *executor) selectionSet(obj *Object, parent any, sels []selection, path []any) *result {
+	groups, _ := ex.collect(obj, sels, map[string]bool{}, nil)
+	out := &result{}
+	for _, g := range groups {
+		first := g.fields[0]
+		if first.name == "__typename" {
+			out.set(g.key, obj.Name)
+			continue
+		}
+		def := obj.Fields[first.name]
+		args := map[string]any{}
+		for name, v := range def.Args {
+			if v != nil {
+				args[name] = v
+			}
+		}
+		for name, v := range first.args {
+			if val, ok := ex.value(v); ok {
+				args[name] = val
+			}
+		}
+
+		fieldPath := append(slices.Clip(path), g.key)
+		var value any
+		var err error
+		if def.Resolve != nil {
+			value, err = def.Resolve(parent, args)
+		} else {
+			value, err = resolveJSONField(parent, first.name)
+		}
+		if err != nil {
+			ex.errors = append(ex.errors, Error{Message: err.Error(), Locations: []Location{location(ex.src, first.pos)}, Path: fieldPath})
+			out.set(g.key, nil)
+			continue
+		}
+		out.set(g.key, ex.complete(def.Type, value, g.subselections(), fieldPath))
+	}
+	return out
+}
+
+// complete resolves the selection set of an object or list of objects.
+func (ex *executor) complete(t *Object, value any, sels []selection, path []any) any {
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return nil
+	}
+	if t == nil {
+		return value
+	}
+	if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		list := make([]any, v.Len())
+		for i := range list {
+			list[i] = ex.complete(t, v.Index(i).Interface(), sels, append(slices.Clip(path), i))
+		}
+		return list
+	}
+	return ex.selectionSet(t, v.Interface(), sels, path)
+}
+
+// resolveJSONField reads the field with the given JSON name from a struct,
+// or the key from a map.
+func resolveJSONField(parent any, name string) (any, error) {
+	v := reflect.ValueOf(parent)
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			break
+		}
+		f := v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key()))
+		if !f.IsValid() {
+			return nil, nil
+		}
+		return f.Interface(), nil
+	case reflect.Struct:
+		if f, ok := jsonField(v, name); ok {
+			return f.Interface(), nil
+		}
+	}
+	return nil, fmt.Errorf("cannot resolve field %q on %s", name, v.Type())
+}
+
+// jsonField returns the struct field encoded under name by encoding/json,
+// looking into embedded structs.
+func jsonField(v reflect.Value, name string) (reflect.Value, bool) {
+	t := v.Type()
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		tag, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if tag == "-" {
+			continue
+		}
+		if sf.Anonymous && tag == "" && sf.Type.Kind() == reflect.Struct {
+			if f, ok := jsonField(v.Field(i), name); ok {
+				return f, true
+			}
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if tag == "" {
+			tag = sf.Name
+		}
+		if tag == name {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// result is a response object, which keeps its keys in selection order.
+type result struct {
+	keys   []string
+	values []any
+}
+
+func (r *result) set(key string, value any) {
+	r.keys = append(r.keys, key)
+	r.values = append(r.values, value)
+}
+
+// MarshalJSON implements json.Marshaler.
+func (r *result) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range r.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, _ := json.Marshal(key)
+		buf.Write(k)
+		buf.WriteByte(':')
+		v, err := json.Marshal(r.values[i])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Int returns an integer argument, which variables pass as JSON numbers.
+func Int(args map[string]any, name string) (int, bool, error) {
+	switch v := args[name].(type) {
+	case nil:
+		return 0, false, nil
+	case int:
+		return v, true, nil
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) <= math.MaxInt32 {
+			return int(v), true, nil
+		}
+	}
+	return 0, false, fmt.Errorf("argument %q must be an Int", name)
+}
+
+// String returns a string argument.
+func String(args map[string]any, name string) (string, bool, error) {
+	switch v := args[name].(type) {
+	case nil:
+		return "", false, nil
+	case string:
+		return v, true, nil
+	}
+	return "", false, fmt.Errorf("argument %q must be a String", name)
+}
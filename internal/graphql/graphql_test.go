@@ -0,0 +1,162 @@
+package graphql
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testBook struct {
+	Title  string `json:"title"`
+	Pages  int    `json:"pages"`
+	Secret string `json:"-"`
+	testMeta
+}
+
+type testMeta struct {
+	Year int `json:"year"`
+}
+
+func testSchema() *Schema {
+	book := &Object{Name: "Book", Fields: map[string]*Field{
+		"title": {},
+		"pages": {},
+		"year":  {},
+		"upper": {Args: map[string]any{"suffix": ""}, Resolve: func(parent any, args map[string]any) (any, error) {
+			suffix, _, err := String(args, "suffix")
+			return parent.(testBook).Title + suffix, err
+		}},
+	}}
+	books := []testBook{{Title: "Dune", Pages: 412, testMeta: testMeta{1965}}, {Title: "Emma", Pages: 474, testMeta: testMeta{1815}}}
+	return &Schema{Query: &Object{Name: "Query", Fields: map[string]*Field{
+		"books": {Type: book, Args: map[string]any{"limit": 10}, Resolve: func(_ any, args map[string]any) (any, error) {
+			limit, _, err := Int(args, "limit")
+			if err != nil {
+				return nil, err
+			}
+			return books[:min(limit, len(books))], nil
+		}},
+		"book": {Type: book, Args: map[string]any{"title": nil}, Resolve: func(_ any, args map[string]any) (any, error) {
+			title, _, _ := String(args, "title")
+			for _, b := range books {
+				if b.Title == title {
+					return &b, nil
+				}
+			}
+			return nil, nil
+		}},
+		"broken": {Resolve: func(any, map[string]any) (any, error) {
+			return nil, errors.New("backend unavailable")
+		}},
+		"settings": {Resolve: func(any, map[string]any) (any, error) {
+			return map[string]int{"b": 2, "a": 1}, nil
+		}},
+	}}}
+}
+
+func execute(t *testing.T, req Request) string {
+	t.Helper()
+	data, err := json.Marshal(testSchema().Execute(req))
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestExecute(t *testing.T) {
+	for name, tc := range map[string]struct {
+		req  Request
+		want string
+	}{
+		"shorthand": {
+			req:  Request{Query: `{ books { title pages } }`},
+			want: `{"data":{"books":[{"title":"Dune","pages":412},{"title":"Emma","pages":474}]}}`,
+		},
+		"aliases, arguments and embedded fields": {
+			req: Request{Query: `query {
+				first: books(limit: 1) { title year }
+				emma: book(title: "Emma") { loud: upper(suffix: "!") }
+				missing: book(title: "Ulysses") { title }
+			}`},
+			want: `{"data":{"first":[{"title":"Dune","year":1965}],"emma":{"loud":"Emma!"},"missing":null}}`,
+		},
+		"variables and defaults": {
+			req: Request{
+				Query:     `query Books($limit: Int = 2, $title: String!) { books(limit: $limit) { title } book(title: $title) { pages } }`,
+				Variables: map[string]any{"limit": float64(1), "title": "Dune"},
+			},
+			want: `{"data":{"books":[{"title":"Dune"}],"book":{"pages":412}}}`,
+		},
+		"fragments, directives and typename": {
+			req: Request{
+				Query: `query($brief: Boolean!) { books(limit: 1) { ...Info pages @skip(if: $brief) ... on Book { __typename } } }
+					fragment Info on Book { title year @include(if: false) }`,
+				Variables: map[string]any{"brief": true},
+			},
+			want: `{"data":{"books":[{"title":"Dune","__typename":"Book"}]}}`,
+		},
+		"operation name": {
+			req:  Request{Query: `query A { books(limit: 1) { title } } query B { settings }`, OperationName: "B"},
+			want: `{"data":{"settings":{"a":1,"b":2}}}`,
+		},
+		"resolver errors": {
+			req:  Request{Query: `{ broken books(limit: 0) { title } }`},
+			want: `{"data":{"broken":null,"books":[]},"errors":[{"message":"backend unavailable","locations":[{"line":1,"column":3}],"path":["broken"]}]}`,
+		},
+		"argument errors": {
+			req:  Request{Query: `{ books(limit: "two") { title } }`},
+			want: `{"data":{"books":null},"errors":[{"message":"argument \"limit\" must be an Int","locations":[{"line":1,"column":3}],"path":["books"]}]}`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.JSONEq(t, tc.want, execute(t, tc.req))
+		})
+	}
+}
+
+func TestExecute_Errors(t *testing.T) {
+	for query, want := range map[string]string{
+		`{ books { title`:                                `Expected a name, found end of query.`,
+		`{ books { author } }`:                           `Cannot query field "author" on type "Book".`,
+		`{ books }`:                                      `Field "books" of type "Book" must have a selection of subfields.`,
+		`{ settings { a } }`:                             `Field "settings" must not have a selection since it is a scalar.`,
+		`{ books(order: "asc") { title } }`:              `Unknown argument "order" on field "Query.books".`,
+		`{ books(limit: $n) { title } }`:                 `Variable "$n" is not defined.`,
+		`{ books { ...Missing } }`:                       `Unknown fragment "Missing".`,
+		`{ books { title @deprecated } }`:                `Unknown directive "@deprecated".`,
+		`mutation { books { title } }`:                   `Only queries are supported, not mutations.`,
+		`query A { settings } query B { broken }`:        `Must provide operation name if query contains multiple operations.`,
+		`query($n: Int!) { books(limit: $n) { title } }`: `Variable "$n" of required type was not provided.`,
+		`{ books { title } } ?`:                          `Unexpected character '?'.`,
+		`{ book(title: "unterminated) { title } }`:       `Unterminated string.`,
+	} {
+		resp := testSchema().Execute(Request{Query: query})
+		assert.Nil(t, resp.Data, query)
+		require.NotEmpty(t, resp.Errors, query)
+		assert.Equal(t, want, resp.Errors[0].Message, query)
+	}
+}
+
+func TestExecute_ErrorLocation(t *testing.T) {
+	resp := testSchema().Execute(Request{Query: "{\n  books {\n    author\n  }\n}"})
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, []Location{{Line: 3, Column: 5}}, resp.Errors[0].Locations)
+}
+
+func TestParseValues(t *testing.T) {
+	doc, err := parse(`{ f(a: -12, b: 1.5e3, c: "tab\tsnow☃", d: [1, true, null, ENUM], e: {x: """block "quote" """}) }`)
+	require.Nil(t, err)
+	assert.Equal(t, map[string]any{
+		"a": -12,
+		"b": 1500.0,
+		"c": "tab\tsnow☃",
+		"d": []any{1, true, nil, "ENUM"},
+		"e": map[string]any{"x": `block "quote" `},
+	}, doc.operations[0].selections[0].args)
+
+	for _, src := range []string{`{ f(a: 012) }`, `{ f(a: 1.) }`, `{ f(a: 1x) }`, `{ f(a: "\q") }`} {
+		_, err := parse(src)
+		assert.NotNil(t, err, src)
+	}
+}
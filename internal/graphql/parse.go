@@ -0,0 +1,643 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokName
+	tokInt
+	tokFloat
+	tokString
+	tokPunct
+)
+
+type token struct {
+	kind tokenKind
+	text string // the decoded value for strings
+	pos  int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of query"
+	case tokString:
+		return strconv.Quote(t.text)
+	default:
+		return fmt.Sprintf("%q", t.text)
+	}
+}
+
+// punctuators lists the punctuators, longest first.
+var punctuators = []string{"...", "!", "$", "&", "(", ")", ":", "=", "@", "[", "]", "{", "|", "}"}
+
+// document is a parsed query document.
+type document struct {
+	operations []*operation
+	fragments  map[string]*fragment
+}
+
+type operation struct {
+	kind       string // query, mutation or subscription
+	name       string
+	variables  []variableDef
+	selections []selection
+	pos        int
+}
+
+type variableDef struct {
+	name       string
+	nonNull    bool
+	def        any
+	hasDefault bool
+}
+
+type fragment struct {
+	typeCond   string
+	selections []selection
+}
+
+type selectionKind int
+
+const (
+	selField selectionKind = iota
+	selSpread
+	selInline
+)
+
+// selection is a field, a fragment spread or an inline fragment.
+type selection struct {
+	kind selectionKind
+	pos  int
+	// alias, name and args describe fields; name also names spread
+	// fragments.
+	alias, name string
+	args        map[string]any
+	// typeCond is the type condition of inline fragments, if any.
+	typeCond   string
+	directives []directive
+	// selections is the selection set of fields and inline fragments.
+	selections []selection
+}
+
+// key returns the response key of a field.
+func (s *selection) key() string {
+	if s.alias != "" {
+		return s.alias
+	}
+	return s.name
+}
+
+type directive struct {
+	name string
+	args map[string]any
+	pos  int
+}
+
+// variable is a reference to an operation variable in a value.
+type variable string
+
+type parser struct {
+	src    string
+	tokens []token
+	i      int
+}
+
+// parse parses a query document.
+func parse(src string) (*document, *Error) {
+	p := &parser{src: src}
+	if err := p.lex(); err != nil {
+		return nil, err
+	}
+	doc := &document{fragments: map[string]*fragment{}}
+	for p.peek().kind != tokEOF {
+		t := p.peek()
+		switch {
+		case t.kind == tokPunct && t.text == "{":
+			sels, err := p.parseSelectionSet()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, &operation{kind: "query", selections: sels, pos: t.pos})
+		case t.kind == tokName && (t.text == "query" || t.text == "mutation" || t.text == "subscription"):
+			op, err := p.parseOperation()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, op)
+		case t.kind == tokName && t.text == "fragment":
+			name, f, err := p.parseFragment()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := doc.fragments[name]; ok {
+				return nil, p.errorf(t, "There can be only one fragment named %q.", name)
+			}
+			doc.fragments[name] = f
+		default:
+			return nil, p.errorf(t, "Unexpected %s.", t)
+		}
+	}
+	if len(doc.operations) == 0 {
+		return nil, p.errorf(p.peek(), "The query contains no operation.")
+	}
+	return doc, nil
+}
+
+func (p *parser) errorf(t token, format string, args ...any) *Error {
+	return &Error{Message: fmt.Sprintf(format, args...), Locations: []Location{location(p.src, t.pos)}}
+}
+
+// location returns the line and column of a byte offset.
+func location(src string, pos int) Location {
+	before := src[:pos]
+	line := strings.Count(before, "\n") + 1
+	column := utf8.RuneCountInString(before[strings.LastIndexByte(before, '\n')+1:]) + 1
+	return Location{Line: line, Column: column}
+}
+
+func (p *parser) lex() *Error {
+	src := p.src
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			i++
+		case strings.HasPrefix(src[i:], "\uFEFF"):
+			i += len("\uFEFF")
+		case c == '#':
+			for i < len(src) && src[i] != '\n' && src[i] != '\r' {
+				i++
+			}
+		case isNameStart(c):
+			j := i + 1
+			for j < len(src) && (isNameStart(src[j]) || isDigit(src[j])) {
+				j++
+			}
+			p.tokens = append(p.tokens, token{kind: tokName, text: src[i:j], pos: i})
+			i = j
+		case isDigit(c) || c == '-':
+			t, err := p.lexNumber(i)
+			if err != nil {
+				return err
+			}
+			p.tokens = append(p.tokens, t)
+			i += len(t.text)
+		case strings.HasPrefix(src[i:], `"""`):
+			end := strings.Index(src[i+3:], `"""`)
+			for end >= 0 && src[i+3+end-1] == '\\' {
+				next := strings.Index(src[i+3+end+3:], `"""`)
+				if next < 0 {
+					end = -1
+					break
+				}
+				end += 3 + next
+			}
+			if end < 0 {
+				return &Error{Message: "Unterminated string.", Locations: []Location{location(src, i)}}
+			}
+			text := strings.ReplaceAll(src[i+3:i+3+end], `\"""`, `"""`)
+			p.tokens = append(p.tokens, token{kind: tokString, text: text, pos: i})
+			i += 3 + end + 3
+		case c == '"':
+			s, n, err := unquote(src[i:])
+			if err != "" {
+				return &Error{Message: err, Locations: []Location{location(src, i)}}
+			}
+			p.tokens = append(p.tokens, token{kind: tokString, text: s, pos: i})
+			i += n
+		default:
+			punct := ""
+			for _, o := range punctuators {
+				if strings.HasPrefix(src[i:], o) {
+					punct = o
+					break
+				}
+			}
+			if punct == "" {
+				r, _ := utf8.DecodeRuneInString(src[i:])
+				return &Error{Message: fmt.Sprintf("Unexpected character %q.", r), Locations: []Location{location(src, i)}}
+			}
+			p.tokens = append(p.tokens, token{kind: tokPunct, text: punct, pos: i})
+			i += len(punct)
+		}
+	}
+	p.tokens = append(p.tokens, token{kind: tokEOF, pos: len(src)})
+	return nil
+}
+
+// lexNumber reads an Int or Float token starting at i.
+func (p *parser) lexNumber(i int) (token, *Error) {
+	src := p.src
+	j := i
+	if src[j] == '-' {
+		j++
+	}
+	digits := func() int {
+		start := j
+		for j < len(src) && isDigit(src[j]) {
+			j++
+		}
+		return j - start
+	}
+	if n := digits(); n == 0 || (n > 1 && src[j-n] == '0') {
+		return token{}, &Error{Message: fmt.Sprintf("Invalid number %q.", src[i:j]), Locations: []Location{location(src, i)}}
+	}
+	kind := tokInt
+	if j < len(src) && src[j] == '.' {
+		j++
+		kind = tokFloat
+		if digits() == 0 {
+			return token{}, &Error{Message: fmt.Sprintf("Invalid number %q.", src[i:j]), Locations: []Location{location(src, i)}}
+		}
+	}
+	if j < len(src) && (src[j] == 'e' || src[j] == 'E') {
+		j++
+		kind = tokFloat
+		if j < len(src) && (src[j] == '+' || src[j] == '-') {
+			j++
+		}
+		if digits() == 0 {
+			return token{}, &Error{Message: fmt.Sprintf("Invalid number %q.", src[i:j]), Locations: []Location{location(src, i)}}
+		}
+	}
+	if j < len(src) && (isNameStart(src[j]) || src[j] == '.') {
+		return token{}, &Error{Message: fmt.Sprintf("Invalid number %q.", src[i:j+1]), Locations: []Location{location(src, i)}}
+	}
+	return token{kind: kind, text: src[i:j], pos: i}, nil
+}
+
+// unquote decodes the string literal at the start of s, returning it and
+// the length of the literal, or an error message.
+func unquote(s string) (string, int, string) {
+	var b strings.Builder
+	for i := 1; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '"':
+			return b.String(), i + 1, ""
+		case c == '\n' || c == '\r':
+			return "", 0, "Unterminated string."
+		case c == '\\':
+			if i+1 >= len(s) {
+				return "", 0, "Unterminated string."
+			}
+			switch e := s[i+1]; e {
+			case '"', '\\', '/':
+				b.WriteByte(e)
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'u':
+				if i+6 > len(s) {
+					return "", 0, "Invalid unicode escape."
+				}
+				r, err := strconv.ParseUint(s[i+2:i+6], 16, 32)
+				if err != nil {
+					return "", 0, fmt.Sprintf("Invalid unicode escape %q.", s[i:i+6])
+				}
+				b.WriteRune(rune(r))
+				i += 4
+			default:
+				return "", 0, fmt.Sprintf("Invalid escape %q.", s[i:i+2])
+			}
+			i += 2
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return "", 0, "Unterminated string."
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.i]
+}
+
+func (p *parser) advance() token {
+	t := p.tokens[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+// accept consumes the punctuator if it is next.
+func (p *parser) accept(punct string) bool {
+	if t := p.peek(); t.kind == tokPunct && t.text == punct {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(punct string) *Error {
+	if !p.accept(punct) {
+		return p.errorf(p.peek(), "Expected %q, found %s.", punct, p.peek())
+	}
+	return nil
+}
+
+func (p *parser) expectName() (string, *Error) {
+	t := p.advance()
+	if t.kind != tokName {
+		return "", p.errorf(t, "Expected a name, found %s.", t)
+	}
+	return t.text, nil
+}
+
+func (p *parser) parseOperation() (*operation, *Error) {
+	t := p.advance()
+	op := &operation{kind: t.text, pos: t.pos}
+	if p.peek().kind == tokName {
+		op.name = p.advance().text
+	}
+	if p.accept("(") {
+		for !p.accept(")") {
+			def, err := p.parseVariableDef()
+			if err != nil {
+				return nil, err
+			}
+			op.variables = append(op.variables, def)
+		}
+	}
+	if _, err := p.parseDirectives(); err != nil {
+		return nil, err
+	}
+	sels, err := p.parseSelectionSet()
+	if err != nil {
+		return nil, err
+	}
+	op.selections = sels
+	return op, nil
+}
+
+func (p *parser) parseVariableDef() (variableDef, *Error) {
+	if err := p.expect("$"); err != nil {
+		return variableDef{}, err
+	}
+	name, err := p.expectName()
+	if err != nil {
+		return variableDef{}, err
+	}
+	if err := p.expect(":"); err != nil {
+		return variableDef{}, err
+	}
+	nonNull, err := p.parseType()
+	if err != nil {
+		return variableDef{}, err
+	}
+	def := variableDef{name: name, nonNull: nonNull}
+	if p.accept("=") {
+		if def.def, err = p.parseValue(true); err != nil {
+			return variableDef{}, err
+		}
+		def.hasDefault = true
+	}
+	if _, err := p.parseDirectives(); err != nil {
+		return variableDef{}, err
+	}
+	return def, nil
+}
+
+// parseType parses a type reference, reporting whether it is non-null.
+// Variable values are checked by the fields using them, so the type itself
+// is not kept.
+func (p *parser) parseType() (bool, *Error) {
+	if p.accept("[") {
+		if _, err := p.parseType(); err != nil {
+			return false, err
+		}
+		if err := p.expect("]"); err != nil {
+			return false, err
+		}
+	} else if _, err := p.expectName(); err != nil {
+		return false, err
+	}
+	return p.accept("!"), nil
+}
+
+func (p *parser) parseFragment() (string, *fragment, *Error) {
+	p.advance()
+	t := p.peek()
+	name, err := p.expectName()
+	if err != nil {
+		return "", nil, err
+	}
+	if name == "on" {
+		return "", nil, p.errorf(t, "Unexpected %s.", t)
+	}
+	if t := p.advance(); t.kind != tokName || t.text != "on" {
+		return "", nil, p.errorf(t, "Expected \"on\", found %s.", t)
+	}
+	typeCond, err := p.expectName()
+	if err != nil {
+		return "", nil, err
+	}
+	if _, err := p.parseDirectives(); err != nil {
+		return "", nil, err
+	}
+	sels, err := p.parseSelectionSet()
+	if err != nil {
+		return "", nil, err
+	}
+	return name, &fragment{typeCond: typeCond, selections: sels}, nil
+}
+
+func (p *parser) parseSelectionSet() ([]selection, *Error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var sels []selection
+	for !p.accept("}") {
+		sel, err := p.parseSelection()
+		if err != nil {
+			return nil, err
+		}
+		sels = append(sels, sel)
+	}
+	if len(sels) == 0 {
+		return nil, p.errorf(p.tokens[p.i-1], "Expected a selection, found \"}\".")
+	}
+	return sels, nil
+}
+
+func (p *parser) parseSelection() (selection, *Error) {
+	t := p.peek()
+	sel := selection{pos: t.pos}
+	var err *Error
+	if p.accept("...") {
+		switch next := p.peek(); {
+		case next.kind == tokName && next.text == "on":
+			p.advance()
+			sel.kind = selInline
+			if sel.typeCond, err = p.expectName(); err != nil {
+				return selection{}, err
+			}
+		case next.kind == tokName:
+			sel.kind = selSpread
+			sel.name = p.advance().text
+			sel.directives, err = p.parseDirectives()
+			return sel, err
+		default:
+			sel.kind = selInline
+		}
+		if sel.directives, err = p.parseDirectives(); err != nil {
+			return selection{}, err
+		}
+		sel.selections, err = p.parseSelectionSet()
+		return sel, err
+	}
+
+	if sel.name, err = p.expectName(); err != nil {
+		return selection{}, err
+	}
+	if p.accept(":") {
+		sel.alias = sel.name
+		if sel.name, err = p.expectName(); err != nil {
+			return selection{}, err
+		}
+	}
+	if sel.args, err = p.parseArguments(); err != nil {
+		return selection{}, err
+	}
+	if sel.directives, err = p.parseDirectives(); err != nil {
+		return selection{}, err
+	}
+	if t := p.peek(); t.kind == tokPunct && t.text == "{" {
+		sel.selections, err = p.parseSelectionSet()
+	}
+	return sel, err
+}
+
+// parseArguments parses optional arguments in parentheses.
+func (p *parser) parseArguments() (map[string]any, *Error) {
+	if !p.accept("(") {
+		return nil, nil
+	}
+	args := map[string]any{}
+	for !p.accept(")") {
+		t := p.peek()
+		name, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := args[name]; ok {
+			return nil, p.errorf(t, "There can be only one argument named %q.", name)
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		if args[name], err = p.parseValue(false); err != nil {
+			return nil, err
+		}
+	}
+	return args, nil
+}
+
+func (p *parser) parseDirectives() ([]directive, *Error) {
+	var directives []directive
+	for p.peek().kind == tokPunct && p.peek().text == "@" {
+		t := p.advance()
+		name, err := p.expectName()
+		if err != nil {
+			return nil, err
+		}
+		args, err := p.parseArguments()
+		if err != nil {
+			return nil, err
+		}
+		directives = append(directives, directive{name: name, args: args, pos: t.pos})
+	}
+	return directives, nil
+}
+
+// parseValue parses an input value; constant values may not reference
+// variables.
+func (p *parser) parseValue(constant bool) (any, *Error) {
+	t := p.advance()
+	switch t.kind {
+	case tokInt:
+		n, err := strconv.Atoi(t.text)
+		if err != nil {
+			return nil, p.errorf(t, "Invalid number %q.", t.text)
+		}
+		return n, nil
+	case tokFloat:
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, p.errorf(t, "Invalid number %q.", t.text)
+		}
+		return f, nil
+	case tokString:
+		return t.text, nil
+	case tokName:
+		switch t.text {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		// Enum values are passed to resolvers as strings.
+		return t.text, nil
+	case tokPunct:
+		switch t.text {
+		case "$":
+			if constant {
+				return nil, p.errorf(t, "Unexpected variable in a constant value.")
+			}
+			name, err := p.expectName()
+			return variable(name), err
+		case "[":
+			list := []any{}
+			for !p.accept("]") {
+				v, err := p.parseValue(constant)
+				if err != nil {
+					return nil, err
+				}
+				list = append(list, v)
+			}
+			return list, nil
+		case "{":
+			obj := map[string]any{}
+			for !p.accept("}") {
+				name, err := p.expectName()
+				if err != nil {
+					return nil, err
+				}
+				if err := p.expect(":"); err != nil {
+					return nil, err
+				}
+				if obj[name], err = p.parseValue(constant); err != nil {
+					return nil, err
+				}
+			}
+			return obj, nil
+		}
+	}
+	return nil, p.errorf(t, "Unexpected %s.", t)
+}
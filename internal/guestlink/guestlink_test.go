@@ -0,0 +1,70 @@
+package guestlink
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func TestSignVerify(t *testing.T) {
+	now := time.Now()
+	claims := Claims{ID: "abc", Mode: "party", Name: "wedding", Expires: now.Add(time.Hour).Unix(), Duration: 2 * time.Hour}
+
+	token, err := Sign(testSecret, claims)
+	require.NoError(t, err)
+
+	got, err := Verify(testSecret, token, now)
+	require.NoError(t, err)
+	assert.Equal(t, claims, got)
+}
+
+func TestVerify_Errors(t *testing.T) {
+	now := time.Now()
+	token, err := Sign(testSecret, Claims{ID: "abc", Mode: "album", Name: "Vacation", Expires: now.Add(time.Hour).Unix()})
+	require.NoError(t, err)
+	payload, sig, _ := strings.Cut(token, ".")
+
+	tampered, err := Sign(testSecret, Claims{ID: "abc", Mode: "album", Name: "Other", Expires: now.Add(time.Hour).Unix()})
+	require.NoError(t, err)
+	tamperedPayload, _, _ := strings.Cut(tampered, ".")
+
+	tests := []struct {
+		name  string
+		token string
+		now   time.Time
+		want  error
+	}{
+		{"no separator", payload, now, ErrMalformed},
+		{"bad signature encoding", payload + ".!!", now, ErrMalformed},
+		{"wrong secret", mustSign(t, "another-secret-another-secret-00", payload), now, ErrSignature},
+		{"tampered payload", tamperedPayload + "." + sig, now, ErrSignature},
+		{"expired", token, now.Add(2 * time.Hour), ErrExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Verify(testSecret, tt.token, tt.now)
+			assert.ErrorIs(t, err, tt.want)
+		})
+	}
+}
+
+// mustSign signs an already encoded payload with a different secret.
+func mustSign(t *testing.T, secret, payload string) string {
+	t.Helper()
+	return payload + "." + base64.RawURLEncoding.EncodeToString(mac(secret, payload))
+}
+
+func TestNewID(t *testing.T) {
+	a, err := NewID()
+	require.NoError(t, err)
+	b, err := NewID()
+	require.NoError(t, err)
+	assert.Len(t, a, 24)
+	assert.NotEqual(t, a, b)
+}
@@ -0,0 +1,209 @@
+// Package homeassistant polls entity states from Home Assistant for when
+// conditions such as ha["person.alex"] == "home".
+package homeassistant
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/tracing"
+)
+
+// Retry delays after a failed poll; the delay never exceeds the poll interval.
+const minRetryDelay = time.Second
+
+// requestTimeout bounds a single state request.
+const requestTimeout = 10 * time.Second
+
+// maxResponseSize bounds a state response, which includes its attributes.
+const maxResponseSize = 1 << 20
+
+// Home Assistant metrics
+var pollsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "immich_kiosk_scheduler_home_assistant_polls_total",
+		Help: "Total number of Home Assistant state polls by result",
+	},
+	[]string{"result"},
+)
+
+func init() {
+	prometheus.MustRegister(pollsTotal)
+}
+
+// Status describes the connection to Home Assistant.
+type Status struct {
+	URL       string `json:"url"`
+	Connected bool   `json:"connected"`
+	// States holds the last polled state of each referenced entity.
+	States      map[string]string `json:"states,omitempty"`
+	LastUpdate  *time.Time        `json:"last_update,omitempty"`
+	LastError   string            `json:"last_error,omitempty"`
+	LastErrorAt *time.Time        `json:"last_error_at,omitempty"`
+}
+
+// Client polls the states of the entities referenced by the schedule.
+type Client struct {
+	cfg      config.HomeAssistantConfig
+	client   *http.Client
+	entities func() []string
+	logger   *slog.Logger
+
+	states atomic.Pointer[map[string]string]
+
+	mu     sync.Mutex
+	status Status
+}
+
+// New creates a Client. entities returns the entity IDs to poll; it is
+// called before every poll so configuration reloads are picked up.
+func New(cfg config.HomeAssistantConfig, entities func() []string, logger *slog.Logger) *Client {
+	return &Client{
+		cfg:      cfg,
+		client:   &http.Client{Transport: tracing.NewTransport(nil)},
+		entities: entities,
+		logger:   logger,
+		status:   Status{URL: cfg.URL},
+	}
+}
+
+// States returns the last polled entity states. Entities that have not been
+// polled yet are missing. The map must not be modified.
+func (c *Client) States() map[string]string {
+	if states := c.states.Load(); states != nil {
+		return *states
+	}
+	return nil
+}
+
+// Status returns the current status.
+func (c *Client) Status() Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.status
+}
+
+// Run polls until the context is cancelled. After a failed poll the last
+// known states are kept and the poll is retried with a growing delay, so a
+// Home Assistant restart does not flip the schedule.
+func (c *Client) Run(ctx context.Context) {
+	interval := c.cfg.PollInterval()
+	delay := minRetryDelay
+	for {
+		wait := interval
+		if err := c.poll(ctx); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			c.fail(err)
+			wait, delay = delay, min(delay*2, interval)
+		} else {
+			delay = minRetryDelay
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// poll fetches the state of every referenced entity.
+func (c *Client) poll(ctx context.Context) error {
+	entities := c.entities()
+	states := make(map[string]string, len(entities))
+	for _, entity := range entities {
+		state, err := c.state(ctx, entity)
+		if err != nil {
+			return fmt.Errorf("%s: %w", entity, err)
+		}
+		states[entity] = state
+	}
+	c.states.Store(&states)
+	pollsTotal.WithLabelValues("success").Inc()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.status.Connected && c.status.LastError != "" {
+		c.logger.Info("Home Assistant reachable again", slog.String("url", c.cfg.URL))
+	}
+	now := time.Now()
+	c.status.Connected = true
+	c.status.States = states
+	c.status.LastUpdate = &now
+	c.status.LastError = ""
+	c.status.LastErrorAt = nil
+	return nil
+}
+
+// state returns the state of an entity, or "" when Home Assistant does not
+// know it.
+func (c *Client) state(ctx context.Context, entity string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	endpoint := strings.TrimSuffix(c.cfg.URL, "/") + "/api/states/" + url.PathEscape(entity)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.cfg.Token)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "immich-kiosk-scheduler")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return "", nil
+	case http.StatusUnauthorized:
+		return "", fmt.Errorf("unauthorized, check home_assistant.token")
+	default:
+		return "", fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	var body struct {
+		State string `json:"state"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&body); err != nil {
+		return "", fmt.Errorf("invalid response: %w", err)
+	}
+	return body.State, nil
+}
+
+// fail records and logs a failed poll. Only the first failure after a
+// successful poll is logged as an error.
+func (c *Client) fail(err error) {
+	pollsTotal.WithLabelValues("failure").Inc()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.status.Connected || c.status.LastError == "" {
+		c.logger.Error("Home Assistant unreachable, keeping last known states",
+			slog.String("url", c.cfg.URL), slog.Any("error", err))
+	} else {
+		c.logger.Debug("Home Assistant still unreachable", slog.Any("error", err))
+	}
+	now := time.Now()
+	c.status.Connected = false
+	c.status.LastError = err.Error()
+	c.status.LastErrorAt = &now
+}
@@ -0,0 +1,93 @@
+package homeassistant
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+)
+
+const testToken = "long-lived-access-token"
+
+func newTestClient(url string, entities ...string) *Client {
+	cfg := config.HomeAssistantConfig{URL: url + "/", Token: testToken}
+	return New(cfg, func() []string { return entities }, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestClient_Poll(t *testing.T) {
+	ha := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer "+testToken, r.Header.Get("Authorization"))
+		switch r.URL.Path {
+		case "/api/states/person.alex":
+			_, _ = io.WriteString(w, `{"entity_id": "person.alex", "state": "home", "attributes": {"friendly_name": "Alex"}}`)
+		case "/api/states/input_select.house_mode":
+			_, _ = io.WriteString(w, `{"entity_id": "input_select.house_mode", "state": "guests"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ha.Close()
+
+	c := newTestClient(ha.URL, "person.alex", "input_select.house_mode", "person.unknown")
+	assert.Nil(t, c.States())
+
+	require.NoError(t, c.poll(context.Background()))
+	assert.Equal(t, map[string]string{
+		"person.alex":             "home",
+		"input_select.house_mode": "guests",
+		"person.unknown":          "",
+	}, c.States())
+
+	status := c.Status()
+	assert.True(t, status.Connected)
+	assert.NotNil(t, status.LastUpdate)
+	assert.Equal(t, "home", status.States["person.alex"])
+}
+
+func TestClient_FailureKeepsStates(t *testing.T) {
+	var down atomic.Bool
+	ha := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			http.Error(w, "restarting", http.StatusBadGateway)
+			return
+		}
+		_, _ = io.WriteString(w, `{"state": "home"}`)
+	}))
+	defer ha.Close()
+
+	c := newTestClient(ha.URL, "person.alex")
+	require.NoError(t, c.poll(context.Background()))
+
+	down.Store(true)
+	err := c.poll(context.Background())
+	require.Error(t, err)
+	c.fail(err)
+
+	assert.Equal(t, "home", c.States()["person.alex"])
+	status := c.Status()
+	assert.False(t, status.Connected)
+	assert.Contains(t, status.LastError, "502")
+
+	down.Store(false)
+	require.NoError(t, c.poll(context.Background()))
+	assert.True(t, c.Status().Connected)
+	assert.Empty(t, c.Status().LastError)
+}
+
+func TestClient_Unauthorized(t *testing.T) {
+	ha := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer ha.Close()
+
+	err := newTestClient(ha.URL, "person.alex").poll(context.Background())
+	assert.ErrorContains(t, err, "person.alex: unauthorized")
+}
@@ -0,0 +1,160 @@
+// Package hooks delivers schedule events to configured webhooks.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/tracing"
+)
+
+// defaultTimeout applies to hooks without a configured timeout.
+const defaultTimeout = 10 * time.Second
+
+// Reasons a transition was detected.
+const (
+	ReasonSchedule   = "schedule"
+	ReasonReload     = "reload"
+	ReasonReevaluate = "reevaluate"
+	ReasonUpdate     = "update"
+	ReasonControl    = "control"
+	ReasonParty      = "party"
+	ReasonExpired    = "expired"
+	ReasonGuest      = "guest"
+	ReasonProfile    = "profile"
+	ReasonDiscovery  = "discovery"
+	ReasonChat       = "chat"
+)
+
+// Hook metrics
+var deliveriesTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "immich_kiosk_scheduler_hook_deliveries_total",
+		Help: "Total number of hook deliveries by hook and result",
+	},
+	[]string{"hook", "result"},
+)
+
+func init() {
+	prometheus.MustRegister(deliveriesTotal)
+}
+
+// Selection is the schedule decision served to kiosks.
+type Selection struct {
+	Schedule string `json:"schedule"`
+	Album    string `json:"album"`
+}
+
+// Event is the JSON body posted to hooks.
+type Event struct {
+	Event    string    `json:"event"`
+	Time     time.Time `json:"time"`
+	Reason   string    `json:"reason,omitempty"`
+	Previous Selection `json:"previous"`
+	Current  Selection `json:"current"`
+	// Added and Removed name the schedule entries changed by a discovery
+	// event.
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+	// Device is the display of a device event.
+	Device *Device `json:"device,omitempty"`
+}
+
+// Device describes a registered display in device events.
+type Device struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// LastSeen is when the device was last redirected; unset when it has not
+	// been since the server started.
+	LastSeen *time.Time `json:"last_seen,omitempty"`
+}
+
+// Dispatcher delivers events to hooks asynchronously.
+type Dispatcher struct {
+	client *http.Client
+	logger *slog.Logger
+	wg     sync.WaitGroup
+}
+
+// NewDispatcher creates a Dispatcher.
+func NewDispatcher(logger *slog.Logger) *Dispatcher {
+	return &Dispatcher{
+		client: &http.Client{Transport: tracing.NewTransport(nil)},
+		logger: logger,
+	}
+}
+
+// Send delivers the event to every hook subscribed to it without blocking.
+// Failures are logged and counted; deliveries are not retried.
+func (d *Dispatcher) Send(targets []config.HookConfig, event Event) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		d.logger.Error("failed to encode hook event", slog.Any("error", err))
+		return
+	}
+
+	for _, hook := range targets {
+		if !hook.Wants(event.Event) {
+			continue
+		}
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			if err := d.deliver(hook, body); err != nil {
+				deliveriesTotal.WithLabelValues(hook.Name, "failure").Inc()
+				d.logger.Warn("hook delivery failed",
+					slog.String("hook", hook.Name),
+					slog.String("event", event.Event),
+					slog.Any("error", err),
+				)
+				return
+			}
+			deliveriesTotal.WithLabelValues(hook.Name, "success").Inc()
+			d.logger.Debug("hook delivered", slog.String("hook", hook.Name), slog.String("event", event.Event))
+		}()
+	}
+}
+
+// Wait blocks until all in-flight deliveries have finished.
+func (d *Dispatcher) Wait() {
+	d.wg.Wait()
+}
+
+func (d *Dispatcher) deliver(hook config.HookConfig, body []byte) error {
+	timeout := hook.Timeout
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "immich-kiosk-scheduler")
+	for k, v := range hook.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
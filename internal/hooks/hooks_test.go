@@ -0,0 +1,67 @@
+package hooks
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDispatcher_Send(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		received []Event
+		headers  []http.Header
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev Event
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&ev))
+		mu.Lock()
+		received = append(received, ev)
+		headers = append(headers, r.Header.Clone())
+		mu.Unlock()
+	}))
+	defer ts.Close()
+
+	d := NewDispatcher(slog.Default())
+	d.Send([]config.HookConfig{
+		{Name: "all", URL: ts.URL, Headers: map[string]string{"Authorization": "Bearer secret"}},
+		{Name: "other", URL: ts.URL, Events: []string{"something-else"}},
+	}, Event{
+		Event:    config.HookEventTransition,
+		Time:     time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC),
+		Reason:   ReasonSchedule,
+		Previous: Selection{Schedule: "default", Album: "default-album"},
+		Current:  Selection{Schedule: "christmas", Album: "christmas-album"},
+	})
+	d.Wait()
+
+	require.Len(t, received, 1)
+	assert.Equal(t, config.HookEventTransition, received[0].Event)
+	assert.Equal(t, "christmas", received[0].Current.Schedule)
+	assert.Equal(t, "default-album", received[0].Previous.Album)
+	assert.Equal(t, "Bearer secret", headers[0].Get("Authorization"))
+	assert.Equal(t, "application/json", headers[0].Get("Content-Type"))
+	assert.Equal(t, 1.0, testutil.ToFloat64(deliveriesTotal.WithLabelValues("all", "success")))
+}
+
+func TestDispatcher_SendFailure(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	d := NewDispatcher(slog.Default())
+	d.Send([]config.HookConfig{{Name: "broken", URL: ts.URL}}, Event{Event: config.HookEventTransition})
+	d.Wait()
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(deliveriesTotal.WithLabelValues("broken", "failure")))
+}
@@ -0,0 +1,182 @@
+package immich
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/state"
+)
+
+// albumsDoc is the state document holding the last synced albums, so names
+// are available after a restart while Immich is unreachable.
+const albumsDoc = "albums"
+
+// Retry delays after a failed sync; the delay never exceeds the interval.
+const minRetryDelay = 5 * time.Second
+
+// CacheStatus describes the album cache.
+type CacheStatus struct {
+	Albums      int        `json:"albums"`
+	LastSync    *time.Time `json:"last_sync,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+}
+
+// cachedAlbums is the persisted cache.
+type cachedAlbums struct {
+	Synced time.Time `json:"synced"`
+	Albums []Album   `json:"albums"`
+}
+
+// AlbumCache keeps the names of the albums in Immich, refreshed on an
+// interval, for showing names instead of album IDs.
+type AlbumCache struct {
+	client   *Client
+	interval time.Duration
+	store    *state.Store
+	logger   *slog.Logger
+
+	albums atomic.Pointer[[]Album]
+	names  atomic.Pointer[map[string]string]
+
+	// syncMu serializes syncs, e.g. a forced sync during a scheduled one.
+	syncMu sync.Mutex
+	onSync func([]Album)
+
+	mu     sync.Mutex
+	status CacheStatus
+}
+
+// NewAlbumCache creates a cache refreshed every interval, starting from the
+// albums last saved in store.
+func NewAlbumCache(client *Client, interval time.Duration, store *state.Store, logger *slog.Logger) *AlbumCache {
+	c := &AlbumCache{client: client, interval: interval, store: store, logger: logger}
+
+	var saved cachedAlbums
+	if ok, err := store.Load(albumsDoc, &saved); err != nil {
+		logger.Error("failed to load the album cache", slog.Any("error", err))
+	} else if ok {
+		c.set(saved.Albums)
+		c.status.LastSync = &saved.Synced
+	}
+	return c
+}
+
+// OnSync registers a function called with the albums after every
+// successful sync. It must be called before Run.
+func (c *AlbumCache) OnSync(fn func([]Album)) {
+	c.onSync = fn
+}
+
+// Name returns the name of an album, or "" when it is not known.
+func (c *AlbumCache) Name(id string) string {
+	if names := c.names.Load(); names != nil {
+		return (*names)[id]
+	}
+	return ""
+}
+
+// Albums returns the cached albums sorted by name. The slice must not be
+// modified.
+func (c *AlbumCache) Albums() []Album {
+	if albums := c.albums.Load(); albums != nil {
+		return *albums
+	}
+	return nil
+}
+
+// Status returns the current status.
+func (c *AlbumCache) Status() CacheStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	status := c.status
+	status.Albums = len(c.Albums())
+	return status
+}
+
+// Run syncs until the context is cancelled. After a failed sync the cached
+// albums are kept and the sync is retried with a growing delay.
+func (c *AlbumCache) Run(ctx context.Context) {
+	delay := minRetryDelay
+	for {
+		wait := c.interval
+		if err := c.Sync(ctx); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			wait, delay = delay, min(delay*2, c.interval)
+		} else {
+			delay = minRetryDelay
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Sync refreshes the cache from Immich now.
+func (c *AlbumCache) Sync(ctx context.Context) error {
+	c.syncMu.Lock()
+	defer c.syncMu.Unlock()
+
+	albums, err := c.client.Albums(ctx)
+	if err != nil {
+		c.fail(err)
+		return err
+	}
+	c.set(albums)
+
+	now := time.Now()
+	if err := c.store.Save(albumsDoc, cachedAlbums{Synced: now, Albums: c.Albums()}); err != nil {
+		c.logger.Error("failed to persist the album cache", slog.Any("error", err))
+	}
+
+	c.mu.Lock()
+	if c.status.LastError != "" {
+		c.logger.Info("Immich albums synced again", slog.Int("albums", len(albums)))
+	}
+	c.status.LastSync = &now
+	c.status.LastError = ""
+	c.status.LastErrorAt = nil
+	c.mu.Unlock()
+
+	if c.onSync != nil {
+		c.onSync(c.Albums())
+	}
+	return nil
+}
+
+// set replaces the cached albums.
+func (c *AlbumCache) set(albums []Album) {
+	albums = slices.Clone(albums)
+	slices.SortStableFunc(albums, func(a, b Album) int { return cmp.Compare(a.AlbumName, b.AlbumName) })
+	names := make(map[string]string, len(albums))
+	for _, a := range albums {
+		names[a.ID] = a.AlbumName
+	}
+	c.albums.Store(&albums)
+	c.names.Store(&names)
+}
+
+// fail records and logs a failed sync. Only the first failure after a
+// successful sync is logged as an error.
+func (c *AlbumCache) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.status.LastError == "" {
+		c.logger.Error("failed to sync Immich albums, keeping cached names", slog.Any("error", err))
+	} else {
+		c.logger.Debug("Immich albums still not synced", slog.Any("error", err))
+	}
+	now := time.Now()
+	c.status.LastError = err.Error()
+	c.status.LastErrorAt = &now
+}
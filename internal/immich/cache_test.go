@@ -0,0 +1,46 @@
+package immich
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/state"
+)
+
+func TestAlbumCache_Sync(t *testing.T) {
+	store, err := state.Open(t.TempDir())
+	require.NoError(t, err)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	cache := NewAlbumCache(newMockImmich(t), config.DefaultImmichSyncInterval, store, logger)
+	assert.Empty(t, cache.Name("christmas"))
+	assert.Nil(t, cache.Status().LastSync)
+
+	require.NoError(t, cache.Sync(context.Background()))
+	assert.Equal(t, "Christmas", cache.Name("christmas"))
+	assert.Equal(t, "Family", cache.Name("family"))
+	assert.Equal(t, "Christmas", cache.Albums()[0].AlbumName, "sorted by name")
+	assert.Equal(t, 3, cache.Status().Albums)
+	assert.NotNil(t, cache.Status().LastSync)
+
+	// A restarted server has the names before Immich answers, and keeps them
+	// while it is down.
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer down.Close()
+	restarted := NewAlbumCache(New(config.ImmichConfig{URL: down.URL, APIKey: testAPIKey}), config.DefaultImmichSyncInterval, store, logger)
+	assert.Equal(t, "Summer", restarted.Name("summer"))
+
+	require.Error(t, restarted.Sync(context.Background()))
+	assert.Equal(t, "Summer", restarted.Name("summer"))
+	assert.Contains(t, restarted.Status().LastError, "502")
+}
@@ -0,0 +1,56 @@
+package immich
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+)
+
+// discoveryRegex matches album names following the discovery convention:
+// a title and a date range, e.g. "Christmas [12-01..12-31]", or a single
+// day, e.g. "Anniversary [06-14]".
+var discoveryRegex = regexp.MustCompile(`^\s*(.*?)\s*\[\s*(\d\d-\d\d)\s*(?:(?:\.\.|–|to)\s*(\d\d-\d\d)\s*)?\]\s*$`)
+
+// nonSlugRegex finds the runs of characters replaced in entry names.
+var nonSlugRegex = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Discover returns a schedule entry for every album whose name follows the
+// discovery convention, in the order of albums. Albums with invalid dates
+// are skipped, as are later albums whose entry name is already taken.
+func Discover(albums []Album) []config.ScheduleEntry {
+	var entries []config.ScheduleEntry
+	names := make(map[string]bool)
+	for _, a := range albums {
+		m := discoveryRegex.FindStringSubmatch(a.AlbumName)
+		if m == nil {
+			continue
+		}
+		entry := config.ScheduleEntry{
+			Name:       entryName(m[1], a.ID),
+			Album:      a.ID,
+			Start:      m[2],
+			End:        m[3],
+			Discovered: true,
+		}
+		if entry.End == "" {
+			entry.End = entry.Start
+		}
+		if entry.Validate() != nil || names[entry.Name] {
+			continue
+		}
+		names[entry.Name] = true
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// entryName derives a schedule entry name from an album title, e.g.
+// "Summer Party!" becomes "summer-party", falling back to the album ID.
+func entryName(title, id string) string {
+	name := strings.Trim(nonSlugRegex.ReplaceAllString(strings.ToLower(title), "-"), "-")
+	if name == "" {
+		return id
+	}
+	return name
+}
@@ -0,0 +1,29 @@
+package immich
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+)
+
+func TestDiscover(t *testing.T) {
+	albums := []Album{
+		{ID: "a1", AlbumName: "Christmas [12-01..12-31]"},
+		{ID: "a2", AlbumName: "Anniversary [06-14]"},
+		{ID: "a3", AlbumName: "Summer Party! [07-01 to 08-31]"},
+		{ID: "a4", AlbumName: "Holidays 2023"},
+		{ID: "a5", AlbumName: "Broken [13-01..12-31]"},
+		{ID: "a6", AlbumName: "christmas [11-15..12-31]"},
+		{ID: "a7", AlbumName: "[01-01]"},
+	}
+
+	assert.Equal(t, []config.ScheduleEntry{
+		{Name: "christmas", Album: "a1", Start: "12-01", End: "12-31", Discovered: true},
+		{Name: "anniversary", Album: "a2", Start: "06-14", End: "06-14", Discovered: true},
+		{Name: "summer-party", Album: "a3", Start: "07-01", End: "08-31", Discovered: true},
+		{Name: "a7", Album: "a7", Start: "01-01", End: "01-01", Discovered: true},
+	}, Discover(albums))
+	assert.Empty(t, Discover(nil))
+}
@@ -0,0 +1,295 @@
+// Package immich is a client for the parts of the Immich API the scheduler
+// uses: albums, people, tags and metadata search. It is shared by every
+// feature that checks or resolves what the configuration refers to.
+package immich
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/tracing"
+)
+
+// maxResponseSize bounds an API response. Album lists of large libraries
+// are the biggest responses.
+const maxResponseSize = 16 << 20
+
+// Errors returned for well-known API failures; other failures are *APIError.
+var (
+	ErrNotFound     = errors.New("not found in Immich")
+	ErrUnauthorized = errors.New("unauthorized, check immich.api_key")
+)
+
+// Immich metrics
+var requestsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "immich_kiosk_scheduler_immich_requests_total",
+		Help: "Total number of Immich API requests by endpoint and result",
+	},
+	[]string{"endpoint", "result"},
+)
+
+func init() {
+	prometheus.MustRegister(requestsTotal)
+}
+
+// APIError is an unexpected response from Immich.
+type APIError struct {
+	Status  int
+	Message string
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("unexpected status %d", e.Status)
+	}
+	return fmt.Sprintf("unexpected status %d: %s", e.Status, e.Message)
+}
+
+// Album is an Immich album.
+type Album struct {
+	ID         string    `json:"id"`
+	AlbumName  string    `json:"albumName"`
+	AssetCount int       `json:"assetCount"`
+	Shared     bool      `json:"shared"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// Person is a recognized face in Immich.
+type Person struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	IsHidden bool   `json:"isHidden"`
+}
+
+// Tag is an Immich tag. Value is the full path of nested tags, e.g.
+// "holidays/christmas".
+type Tag struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Asset is a photo or video.
+type Asset struct {
+	ID               string    `json:"id"`
+	Type             string    `json:"type"`
+	OriginalFileName string    `json:"originalFileName"`
+	FileCreatedAt    time.Time `json:"fileCreatedAt"`
+}
+
+// SearchRequest filters a metadata search. Zero fields do not filter.
+type SearchRequest struct {
+	AlbumIDs    []string   `json:"albumIds,omitempty"`
+	PersonIDs   []string   `json:"personIds,omitempty"`
+	TagIDs      []string   `json:"tagIds,omitempty"`
+	TakenAfter  *time.Time `json:"takenAfter,omitempty"`
+	TakenBefore *time.Time `json:"takenBefore,omitempty"`
+	Page        int        `json:"page,omitempty"`
+	Size        int        `json:"size,omitempty"`
+}
+
+// SearchResult is one page of search results.
+type SearchResult struct {
+	Items []Asset
+	Total int
+	// NextPage is the page to request next, or 0 after the last page.
+	NextPage int
+}
+
+// Client calls the Immich API.
+type Client struct {
+	base    string
+	apiKey  string
+	timeout time.Duration
+	client  *http.Client
+}
+
+// New creates a Client for the configured Immich server.
+func New(cfg config.ImmichConfig) *Client {
+	return &Client{
+		base:    strings.TrimSuffix(cfg.URL, "/"),
+		apiKey:  cfg.APIKey,
+		timeout: cfg.RequestTimeout(),
+		client:  &http.Client{Transport: tracing.NewTransport(nil)},
+	}
+}
+
+// Ping checks that Immich is reachable.
+func (c *Client) Ping(ctx context.Context) error {
+	var resp struct {
+		Res string `json:"res"`
+	}
+	return c.do(ctx, "ping", http.MethodGet, "/api/server/ping", nil, &resp)
+}
+
+// Albums returns all albums visible to the API key, owned and shared.
+func (c *Client) Albums(ctx context.Context) ([]Album, error) {
+	var owned, shared []Album
+	if err := c.do(ctx, "albums", http.MethodGet, "/api/albums", nil, &owned); err != nil {
+		return nil, err
+	}
+	if err := c.do(ctx, "albums", http.MethodGet, "/api/albums?shared=true", nil, &shared); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(owned))
+	for _, a := range owned {
+		seen[a.ID] = true
+	}
+	for _, a := range shared {
+		if !seen[a.ID] {
+			owned = append(owned, a)
+		}
+	}
+	return owned, nil
+}
+
+// Album returns the album with the given ID, or ErrNotFound.
+func (c *Client) Album(ctx context.Context, id string) (*Album, error) {
+	var album Album
+	path := "/api/albums/" + url.PathEscape(id) + "?withoutAssets=true"
+	if err := c.do(ctx, "album", http.MethodGet, path, nil, &album); err != nil {
+		return nil, err
+	}
+	return &album, nil
+}
+
+// People returns all people, including hidden ones.
+func (c *Client) People(ctx context.Context) ([]Person, error) {
+	var resp struct {
+		People []Person `json:"people"`
+	}
+	if err := c.do(ctx, "people", http.MethodGet, "/api/people?withHidden=true", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.People, nil
+}
+
+// Tags returns all tags.
+func (c *Client) Tags(ctx context.Context) ([]Tag, error) {
+	var tags []Tag
+	if err := c.do(ctx, "tags", http.MethodGet, "/api/tags", nil, &tags); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// Search returns one page of assets matching the request.
+func (c *Client) Search(ctx context.Context, req SearchRequest) (*SearchResult, error) {
+	var resp struct {
+		Assets struct {
+			Items    []Asset `json:"items"`
+			Total    int     `json:"total"`
+			NextPage *string `json:"nextPage"`
+		} `json:"assets"`
+	}
+	if err := c.do(ctx, "search", http.MethodPost, "/api/search/metadata", req, &resp); err != nil {
+		return nil, err
+	}
+
+	result := &SearchResult{Items: resp.Assets.Items, Total: resp.Assets.Total}
+	if resp.Assets.NextPage != nil {
+		// Immich sends the next page number as a string.
+		if _, err := fmt.Sscan(*resp.Assets.NextPage, &result.NextPage); err != nil {
+			return nil, fmt.Errorf("invalid next page %q", *resp.Assets.NextPage)
+		}
+	}
+	return result, nil
+}
+
+// do sends a request and decodes the JSON response into out. endpoint
+// labels the request metric.
+func (c *Client) do(ctx context.Context, endpoint, method, path string, in, out any) error {
+	err := c.request(ctx, method, path, in, out)
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	requestsTotal.WithLabelValues(endpoint, result).Inc()
+	if err != nil {
+		return fmt.Errorf("immich %s: %w", endpoint, err)
+	}
+	return nil
+}
+
+func (c *Client) request(ctx context.Context, method, path string, in, out any) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("x-api-key", c.apiKey)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "immich-kiosk-scheduler")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	r := io.LimitReader(resp.Body, maxResponseSize)
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return ErrUnauthorized
+	// Immich answers unknown or inaccessible IDs with 400 or 404.
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		var e struct {
+			Message any `json:"message"`
+		}
+		_ = json.NewDecoder(r).Decode(&e)
+		apiErr := &APIError{Status: resp.StatusCode, Message: errorMessage(e.Message)}
+		if resp.StatusCode == http.StatusBadRequest && strings.Contains(strings.ToLower(apiErr.Message), "not found") {
+			return ErrNotFound
+		}
+		return apiErr
+	}
+
+	if err := json.NewDecoder(r).Decode(out); err != nil {
+		return fmt.Errorf("invalid response: %w", err)
+	}
+	return nil
+}
+
+// errorMessage flattens Immich's error message, which is a string or a
+// list of validation messages.
+func errorMessage(m any) string {
+	switch m := m.(type) {
+	case string:
+		return m
+	case []any:
+		parts := make([]string, 0, len(m))
+		for _, p := range m {
+			parts = append(parts, fmt.Sprint(p))
+		}
+		return strings.Join(parts, "; ")
+	}
+	return ""
+}
@@ -0,0 +1,147 @@
+package immich
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+)
+
+const testAPIKey = "immich-api-key"
+
+// newMockImmich serves a small Immich library.
+func newMockImmich(t *testing.T) *Client {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/server/ping", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `{"res": "pong"}`)
+	})
+	mux.HandleFunc("GET /api/albums", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("shared") == "true" {
+			_, _ = io.WriteString(w, `[{"id": "christmas", "albumName": "Christmas", "shared": true}, {"id": "family", "albumName": "Family", "shared": true}]`)
+			return
+		}
+		_, _ = io.WriteString(w, `[{"id": "christmas", "albumName": "Christmas", "assetCount": 42, "shared": true}, {"id": "summer", "albumName": "Summer"}]`)
+	})
+	mux.HandleFunc("GET /api/albums/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") != "christmas" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = io.WriteString(w, `{"message": "Album not found", "error": "Bad Request", "statusCode": 400}`)
+			return
+		}
+		assert.Equal(t, "true", r.URL.Query().Get("withoutAssets"))
+		_, _ = io.WriteString(w, `{"id": "christmas", "albumName": "Christmas", "assetCount": 42}`)
+	})
+	mux.HandleFunc("GET /api/people", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `{"total": 2, "people": [{"id": "p1", "name": "Alex"}, {"id": "p2", "name": "", "isHidden": true}]}`)
+	})
+	mux.HandleFunc("GET /api/tags", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `[{"id": "t1", "name": "christmas", "value": "holidays/christmas"}]`)
+	})
+	mux.HandleFunc("POST /api/search/metadata", func(w http.ResponseWriter, r *http.Request) {
+		var req SearchRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		if req.Page == 2 {
+			_, _ = io.WriteString(w, `{"assets": {"total": 1, "items": [{"id": "a3"}], "nextPage": null}}`)
+			return
+		}
+		assert.Equal(t, []string{"p1"}, req.PersonIDs)
+		_, _ = io.WriteString(w, `{"assets": {"total": 2, "items": [{"id": "a1", "type": "IMAGE"}, {"id": "a2", "type": "VIDEO"}], "nextPage": "2"}}`)
+	})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-api-key") != testAPIKey {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return New(config.ImmichConfig{URL: srv.URL + "/", APIKey: testAPIKey})
+}
+
+func TestClient_Albums(t *testing.T) {
+	c := newMockImmich(t)
+	require.NoError(t, c.Ping(context.Background()))
+
+	albums, err := c.Albums(context.Background())
+	require.NoError(t, err)
+	var ids []string
+	for _, a := range albums {
+		ids = append(ids, a.ID)
+	}
+	assert.Equal(t, []string{"christmas", "summer", "family"}, ids)
+	assert.Equal(t, 42, albums[0].AssetCount)
+
+	album, err := c.Album(context.Background(), "christmas")
+	require.NoError(t, err)
+	assert.Equal(t, "Christmas", album.AlbumName)
+
+	_, err = c.Album(context.Background(), "deleted")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestClient_PeopleAndTags(t *testing.T) {
+	c := newMockImmich(t)
+
+	people, err := c.People(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []Person{{ID: "p1", Name: "Alex"}, {ID: "p2", IsHidden: true}}, people)
+
+	tags, err := c.Tags(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []Tag{{ID: "t1", Name: "christmas", Value: "holidays/christmas"}}, tags)
+}
+
+func TestClient_Search(t *testing.T) {
+	c := newMockImmich(t)
+
+	result, err := c.Search(context.Background(), SearchRequest{PersonIDs: []string{"p1"}})
+	require.NoError(t, err)
+	assert.Len(t, result.Items, 2)
+	assert.Equal(t, 2, result.NextPage)
+
+	result, err = c.Search(context.Background(), SearchRequest{Page: result.NextPage})
+	require.NoError(t, err)
+	assert.Equal(t, "a3", result.Items[0].ID)
+	assert.Zero(t, result.NextPage)
+}
+
+func TestClient_Errors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/tags":
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = io.WriteString(w, `{"message": ["database unavailable", "retry later"]}`)
+		case "/api/people":
+			time.Sleep(100 * time.Millisecond)
+		}
+	}))
+	defer srv.Close()
+
+	_, err := New(config.ImmichConfig{URL: srv.URL, APIKey: "wrong"}).Tags(context.Background())
+	var apiErr *APIError
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusInternalServerError, apiErr.Status)
+	assert.EqualError(t, err, "immich tags: unexpected status 500: database unavailable; retry later")
+
+	_, err = New(config.ImmichConfig{URL: srv.URL, APIKey: "key", Timeout: 10 * time.Millisecond}).People(context.Background())
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	_, err = newMockImmich(t).withKey("wrong").Albums(context.Background())
+	assert.ErrorIs(t, err, ErrUnauthorized)
+}
+
+// withKey returns a copy of the client using another API key.
+func (c *Client) withKey(key string) *Client {
+	clone := *c
+	clone.apiKey = key
+	return &clone
+}
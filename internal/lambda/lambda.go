@@ -0,0 +1,277 @@
+// Package lambda serves an http.Handler from AWS Lambda, translating API
+// Gateway and function URL events into HTTP requests via the Lambda runtime API.
+package lambda
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// RuntimeAPIEnv is the environment variable holding the runtime API address.
+const RuntimeAPIEnv = "AWS_LAMBDA_RUNTIME_API"
+
+// runtimeVersion is the path prefix of the Lambda runtime API.
+const runtimeVersion = "/2018-06-01/runtime"
+
+// Runtime polls the Lambda runtime API for invocations and answers them with
+// the handler's responses.
+type Runtime struct {
+	api     string
+	handler http.Handler
+	client  *http.Client
+	logger  *slog.Logger
+}
+
+// New creates a runtime for the runtime API at api (host:port).
+func New(api string, handler http.Handler, logger *slog.Logger) *Runtime {
+	return &Runtime{
+		api:     api,
+		handler: handler,
+		// Waiting for the next invocation blocks until one arrives.
+		client: &http.Client{},
+		logger: logger,
+	}
+}
+
+// Run handles invocations until ctx is canceled.
+func (rt *Runtime) Run(ctx context.Context) error {
+	rt.logger.Info("waiting for lambda invocations", slog.String("runtime_api", rt.api))
+	for {
+		id, event, err := rt.next(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		response, err := Handle(rt.handler, event)
+		if err != nil {
+			rt.logger.Error("invalid lambda event", slog.String("request_id", id), slog.Any("error", err))
+			err = rt.post(ctx, runtimeVersion+"/invocation/"+id+"/error", invocationError{
+				ErrorMessage: err.Error(),
+				ErrorType:    "InvalidEvent",
+			})
+		} else {
+			err = rt.post(ctx, runtimeVersion+"/invocation/"+id+"/response", json.RawMessage(response))
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// invocationError is the error body reported for a failed invocation.
+type invocationError struct {
+	ErrorMessage string `json:"errorMessage"`
+	ErrorType    string `json:"errorType"`
+}
+
+// next waits for the next invocation and returns its request ID and event.
+func (rt *Runtime) next(ctx context.Context) (string, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+rt.api+runtimeVersion+"/invocation/next", nil)
+	if err != nil {
+		return "", nil, err
+	}
+	resp, err := rt.client.Do(req)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to fetch next invocation: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, fmt.Errorf("failed to fetch next invocation: status %d", resp.StatusCode)
+	}
+	event, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read invocation: %w", err)
+	}
+	return resp.Header.Get("Lambda-Runtime-Aws-Request-Id"), event, nil
+}
+
+// post sends a JSON body to the runtime API.
+func (rt *Runtime) post(ctx context.Context, path string, body any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://"+rt.api+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := rt.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post to runtime API: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("runtime API answered %s with status %d", path, resp.StatusCode)
+	}
+	return nil
+}
+
+// event is an API Gateway REST (payload 1.0), HTTP API or function URL
+// (payload 2.0) event.
+type event struct {
+	Version string `json:"version"`
+
+	// Payload 2.0
+	RawPath        string            `json:"rawPath"`
+	RawQueryString string            `json:"rawQueryString"`
+	Cookies        []string          `json:"cookies"`
+	Headers        map[string]string `json:"headers"`
+
+	// Payload 1.0
+	HTTPMethod                      string              `json:"httpMethod"`
+	Path                            string              `json:"path"`
+	MultiValueHeaders               map[string][]string `json:"multiValueHeaders"`
+	QueryStringParameters           map[string]string   `json:"queryStringParameters"`
+	MultiValueQueryStringParameters map[string][]string `json:"multiValueQueryStringParameters"`
+
+	RequestContext struct {
+		HTTP struct {
+			Method   string `json:"method"`
+			SourceIP string `json:"sourceIp"`
+		} `json:"http"`
+		Identity struct {
+			SourceIP string `json:"sourceIp"`
+		} `json:"identity"`
+	} `json:"requestContext"`
+
+	Body            string `json:"body"`
+	IsBase64Encoded bool   `json:"isBase64Encoded"`
+}
+
+// response is the handler's answer in the format API Gateway expects.
+type response struct {
+	StatusCode        int                 `json:"statusCode"`
+	Headers           map[string]string   `json:"headers,omitempty"`
+	MultiValueHeaders map[string][]string `json:"multiValueHeaders,omitempty"`
+	Cookies           []string            `json:"cookies,omitempty"`
+	Body              string              `json:"body"`
+	IsBase64Encoded   bool                `json:"isBase64Encoded"`
+}
+
+// Handle serves a single API Gateway or function URL event with handler and
+// returns the encoded response.
+func Handle(handler http.Handler, data []byte) ([]byte, error) {
+	var ev event
+	if err := json.Unmarshal(data, &ev); err != nil {
+		return nil, fmt.Errorf("failed to decode event: %w", err)
+	}
+	req, err := ev.request()
+	if err != nil {
+		return nil, err
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return json.Marshal(ev.response(rec.Result()))
+}
+
+// request converts the event into an HTTP request.
+func (ev *event) request() (*http.Request, error) {
+	v2 := ev.Version == "2.0"
+
+	method, path, query, sourceIP := ev.HTTPMethod, ev.Path, "", ev.RequestContext.Identity.SourceIP
+	if v2 {
+		method, path, query, sourceIP = ev.RequestContext.HTTP.Method, ev.RawPath, ev.RawQueryString, ev.RequestContext.HTTP.SourceIP
+	} else {
+		values := url.Values{}
+		for key, vals := range ev.MultiValueQueryStringParameters {
+			values[key] = vals
+		}
+		for key, val := range ev.QueryStringParameters {
+			if _, ok := values[key]; !ok {
+				values.Set(key, val)
+			}
+		}
+		query = values.Encode()
+	}
+	if method == "" || path == "" {
+		return nil, errors.New("event is not an API Gateway or function URL request")
+	}
+
+	body := []byte(ev.Body)
+	if ev.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(ev.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode body: %w", err)
+		}
+		body = decoded
+	}
+
+	target := path
+	if query != "" {
+		target += "?" + query
+	}
+	req, err := http.NewRequest(method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("invalid request: %w", err)
+	}
+	req.RequestURI = target
+	req.RemoteAddr = sourceIP + ":0"
+
+	for key, vals := range ev.MultiValueHeaders {
+		for _, val := range vals {
+			req.Header.Add(key, val)
+		}
+	}
+	for key, val := range ev.Headers {
+		if req.Header.Get(key) == "" {
+			req.Header.Set(key, val)
+		}
+	}
+	if len(ev.Cookies) > 0 {
+		req.Header.Set("Cookie", strings.Join(ev.Cookies, "; "))
+	}
+	if host := req.Header.Get("Host"); host != "" {
+		req.Host = host
+	}
+	return req, nil
+}
+
+// response converts the handler's result into the event's response format.
+func (ev *event) response(res *http.Response) response {
+	body, _ := io.ReadAll(res.Body)
+	out := response{StatusCode: res.StatusCode}
+
+	// Compressed or otherwise binary bodies must be base64 encoded.
+	if utf8.Valid(body) && res.Header.Get("Content-Encoding") == "" {
+		out.Body = string(body)
+	} else {
+		out.Body = base64.StdEncoding.EncodeToString(body)
+		out.IsBase64Encoded = true
+	}
+
+	if ev.Version != "2.0" {
+		out.MultiValueHeaders = map[string][]string(res.Header)
+		return out
+	}
+	out.Headers = make(map[string]string, len(res.Header))
+	for key, vals := range res.Header {
+		if key == "Set-Cookie" {
+			out.Cookies = vals
+			continue
+		}
+		out.Headers[key] = strings.Join(vals, ",")
+	}
+	return out
+}
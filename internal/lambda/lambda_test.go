@@ -0,0 +1,136 @@
+package lambda
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// echoHandler redirects to a URL describing the request.
+var echoHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{Name: "seen", Value: "1"})
+	w.Header().Set("X-Remote", r.RemoteAddr)
+	w.Header().Set("X-User-Agent", r.UserAgent())
+	http.Redirect(w, r, "https://kiosk.example.com"+r.URL.Path+"?"+r.URL.RawQuery, http.StatusFound)
+})
+
+func TestHandle_FunctionURL(t *testing.T) {
+	event := `{
+		"version": "2.0",
+		"rawPath": "/frame",
+		"rawQueryString": "transition=fade",
+		"cookies": ["a=1"],
+		"headers": {"user-agent": "Fully Kiosk"},
+		"requestContext": {"http": {"method": "GET", "sourceIp": "203.0.113.7"}},
+		"isBase64Encoded": false
+	}`
+
+	out, err := Handle(echoHandler, []byte(event))
+	require.NoError(t, err)
+
+	var res response
+	require.NoError(t, json.Unmarshal(out, &res))
+	assert.Equal(t, http.StatusFound, res.StatusCode)
+	assert.Equal(t, "https://kiosk.example.com/frame?transition=fade", res.Headers["Location"])
+	assert.Equal(t, "203.0.113.7:0", res.Headers["X-Remote"])
+	assert.Equal(t, "Fully Kiosk", res.Headers["X-User-Agent"])
+	assert.Equal(t, []string{"seen=1"}, res.Cookies)
+	assert.False(t, res.IsBase64Encoded)
+}
+
+func TestHandle_RESTAPI(t *testing.T) {
+	event := `{
+		"httpMethod": "GET",
+		"path": "/",
+		"multiValueHeaders": {"User-Agent": ["Fully Kiosk"]},
+		"queryStringParameters": {"transition": "fade"},
+		"requestContext": {"identity": {"sourceIp": "203.0.113.7"}}
+	}`
+
+	out, err := Handle(echoHandler, []byte(event))
+	require.NoError(t, err)
+
+	var res response
+	require.NoError(t, json.Unmarshal(out, &res))
+	assert.Equal(t, http.StatusFound, res.StatusCode)
+	assert.Equal(t, []string{"https://kiosk.example.com/?transition=fade"}, res.MultiValueHeaders["Location"])
+	assert.Equal(t, []string{"Fully Kiosk"}, res.MultiValueHeaders["X-User-Agent"])
+}
+
+func TestHandle_BinaryBody(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		_, _ = w.Write([]byte{0x1f, 0x8b, 0x00})
+	})
+	event := `{"version": "2.0", "rawPath": "/api/v1/status", "requestContext": {"http": {"method": "GET"}}}`
+
+	out, err := Handle(handler, []byte(event))
+	require.NoError(t, err)
+
+	var res response
+	require.NoError(t, json.Unmarshal(out, &res))
+	assert.True(t, res.IsBase64Encoded)
+	assert.Equal(t, "H4sA", res.Body)
+}
+
+func TestHandle_InvalidEvent(t *testing.T) {
+	_, err := Handle(echoHandler, []byte(`{"detail-type": "Scheduled Event"}`))
+	assert.Error(t, err)
+
+	_, err = Handle(echoHandler, []byte(`not json`))
+	assert.Error(t, err)
+}
+
+func TestRuntime_Run(t *testing.T) {
+	responses := make(chan string, 1)
+	served := false
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == runtimeVersion+"/invocation/next":
+			if served {
+				// Block until the test cancels the runtime.
+				<-r.Context().Done()
+				return
+			}
+			served = true
+			w.Header().Set("Lambda-Runtime-Aws-Request-Id", "req-1")
+			_, _ = io.WriteString(w, `{"version": "2.0", "rawPath": "/", "requestContext": {"http": {"method": "GET"}}}`)
+		case r.Method == http.MethodPost && r.URL.Path == runtimeVersion+"/invocation/req-1/response":
+			body, _ := io.ReadAll(r.Body)
+			responses <- string(body)
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer api.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	rt := New(strings.TrimPrefix(api.URL, "http://"), echoHandler, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	go func() { done <- rt.Run(ctx) }()
+
+	select {
+	case body := <-responses:
+		assert.Contains(t, body, `"statusCode":302`)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no response posted to the runtime API")
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("runtime did not stop")
+	}
+}
@@ -0,0 +1,278 @@
+// Package lint runs extended checks on a configuration beyond what is required to load it.
+package lint
+
+import (
+	"fmt"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/scheduler"
+)
+
+// Severity ranks findings. Errors and warnings fail the check; info does not.
+type Severity string
+
+// Finding severities.
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityInfo    Severity = "info"
+)
+
+// Rule names.
+const (
+	RuleInvalidConfig      = "invalid-config"
+	RuleDuplicateName      = "duplicate-name"
+	RuleDuplicateAlbum     = "duplicate-album"
+	RuleReversedRange      = "reversed-range"
+	RuleShadowed           = "shadowed"
+	RuleUnreachableDefault = "unreachable-default"
+	RuleOverlap            = "overlap"
+	RuleGap                = "gap"
+	RuleUnknownAlbum       = "unknown-album"
+	RuleImmich             = "immich"
+)
+
+// reversedRangeDays is the length above which a year-wrapping range is
+// suspected to have its start and end swapped.
+const reversedRangeDays = 183
+
+// Finding is a single lint result.
+type Finding struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+	Entries  []string `json:"entries,omitempty"`
+}
+
+// Check runs all lint rules against a loaded configuration. With profiles,
+// each profile's schedule is checked and its findings are prefixed with the
+// profile name.
+func Check(cfg *config.Config) []Finding {
+	findings := []Finding{}
+
+	if err := cfg.Validate(); err != nil {
+		return append(findings, Finding{Rule: RuleInvalidConfig, Severity: SeverityError, Message: err.Error()})
+	}
+	if len(cfg.Profiles) == 0 {
+		return append(findings, checkSchedule(cfg)...)
+	}
+
+	for _, name := range cfg.ProfileNames() {
+		profileCfg, err := cfg.WithProfile(name)
+		if err != nil {
+			return append(findings, Finding{Rule: RuleInvalidConfig, Severity: SeverityError, Message: err.Error()})
+		}
+		for _, f := range checkSchedule(profileCfg) {
+			f.Message = fmt.Sprintf("profile %s: %s", name, f.Message)
+			findings = append(findings, f)
+		}
+	}
+	return findings
+}
+
+// checkSchedule runs the schedule rules against a validated configuration.
+func checkSchedule(cfg *config.Config) []Finding {
+	var findings []Finding
+
+	sched, err := scheduler.New(cfg)
+	if err != nil {
+		return append(findings, Finding{Rule: RuleInvalidConfig, Severity: SeverityError, Message: err.Error()})
+	}
+
+	findings = append(findings, duplicateNames(cfg)...)
+	findings = append(findings, duplicateAlbums(cfg, sched)...)
+	findings = append(findings, reversedRanges(cfg)...)
+	findings = append(findings, selectionFindings(cfg, sched)...)
+
+	for _, w := range sched.Warnings() {
+		var rule string
+		switch w.Kind {
+		case scheduler.WarningOverlap:
+			rule = RuleOverlap
+		case scheduler.WarningGap:
+			rule = RuleGap
+		default:
+			// Duplicate names, shared albums and shadowed entries have
+			// rules of their own above.
+			continue
+		}
+		findings = append(findings, Finding{Rule: rule, Severity: SeverityInfo, Message: w.Message, Entries: w.Entries})
+	}
+
+	return findings
+}
+
+// UnknownAlbums reports albums referenced by the configuration, in any
+// profile, that are not among the known album IDs, e.g. from Immich.
+func UnknownAlbums(cfg *config.Config, known map[string]bool) []Finding {
+	var findings []Finding
+	for _, ref := range cfg.AlbumRefs() {
+		if id := cfg.AlbumID(ref.Album); !known[id] {
+			findings = append(findings, Finding{
+				Rule:     RuleUnknownAlbum,
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("%s: album %q does not exist in Immich", ref.Where, id),
+			})
+		}
+	}
+	return findings
+}
+
+// Count returns the number of findings with the given severity.
+func Count(findings []Finding, severity Severity) int {
+	n := 0
+	for _, f := range findings {
+		if f.Severity == severity {
+			n++
+		}
+	}
+	return n
+}
+
+// duplicateNames reports entries sharing a name, which makes them ambiguous
+// in logs, metrics and the API.
+func duplicateNames(cfg *config.Config) []Finding {
+	positions := make(map[string][]int)
+	var order []string
+	for i, e := range cfg.Schedule {
+		if _, seen := positions[e.Name]; !seen {
+			order = append(order, e.Name)
+		}
+		positions[e.Name] = append(positions[e.Name], i+1)
+	}
+
+	var findings []Finding
+	for _, name := range order {
+		if pos := positions[name]; len(pos) > 1 {
+			findings = append(findings, Finding{
+				Rule:     RuleDuplicateName,
+				Severity: SeverityError,
+				Message:  fmt.Sprintf("name %q is used by %d entries (positions %v)", name, len(pos), pos),
+				Entries:  []string{name},
+			})
+		}
+	}
+	return findings
+}
+
+// duplicateAlbums reports albums used by more than one entry, including
+// rotated albums and albums referenced by alias. Reuse is a warning when
+// two of the entries overlap, and info when they never do, e.g. the same
+// album for two holidays.
+func duplicateAlbums(cfg *config.Config, sched *scheduler.Scheduler) []Finding {
+	overlaps := make(map[[2]string]bool)
+	for _, w := range sched.Warnings() {
+		if w.Kind == scheduler.WarningOverlap {
+			overlaps[[2]string{w.Entries[0], w.Entries[1]}] = true
+		}
+	}
+
+	albums := make(map[string][]string)
+	var order []string
+	for _, e := range cfg.Schedule {
+		for _, ref := range e.AlbumIDs() {
+			album := cfg.AlbumID(ref)
+			names := albums[album]
+			if len(names) == 0 {
+				order = append(order, album)
+			} else if names[len(names)-1] == e.Name {
+				continue // listed twice in one rotation
+			}
+			albums[album] = append(names, e.Name)
+		}
+	}
+
+	var findings []Finding
+	for _, album := range order {
+		names := albums[album]
+		if len(names) < 2 {
+			continue
+		}
+		f := Finding{
+			Rule:     RuleDuplicateAlbum,
+			Severity: SeverityInfo,
+			Message:  fmt.Sprintf("album %q is used by %d entries: %v", album, len(names), names),
+			Entries:  names,
+		}
+		if a, b, ok := overlappingPair(names, overlaps); ok {
+			f.Severity = SeverityWarning
+			f.Message += fmt.Sprintf("; entries %q and %q overlap", a, b)
+		}
+		findings = append(findings, f)
+	}
+	return findings
+}
+
+// overlappingPair returns the first two of names, in schedule order, whose
+// entries overlap.
+func overlappingPair(names []string, overlaps map[[2]string]bool) (string, string, bool) {
+	for i, a := range names {
+		for _, b := range names[i+1:] {
+			if overlaps[[2]string{a, b}] {
+				return a, b, true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// reversedRanges reports year-wrapping ranges that cover most of the year,
+// which usually means start and end were swapped.
+func reversedRanges(cfg *config.Config) []Finding {
+	var findings []Finding
+	for _, e := range cfg.Schedule {
+		if e.Start <= e.End {
+			continue
+		}
+		days := entryDays(e, cfg.EndBoundary)
+		if days <= reversedRangeDays {
+			continue
+		}
+		findings = append(findings, Finding{
+			Rule:     RuleReversedRange,
+			Severity: SeverityWarning,
+			Message: fmt.Sprintf("entry %q runs from %s across new year to %s (%d days); did you mean %s to %s?",
+				e.Name, e.Start, e.End, days, e.End, e.Start),
+			Entries: []string{e.Name},
+		})
+	}
+	return findings
+}
+
+// selectionFindings reports entries that are never selected because earlier
+// entries cover all of their days, and a default album that is never used.
+func selectionFindings(cfg *config.Config, sched *scheduler.Scheduler) []Finding {
+	coverage := sched.Coverage()
+
+	var findings []Finding
+	for i, e := range cfg.Schedule {
+		if coverage.Entries[i].SelectedDays > 0 {
+			continue
+		}
+		findings = append(findings, Finding{
+			Rule:     RuleShadowed,
+			Severity: SeverityWarning,
+			Message: fmt.Sprintf("entry %q (%s) is never selected: every day it covers is matched by an earlier entry",
+				e.Name, e.Dates()),
+			Entries: []string{e.Name},
+		})
+	}
+
+	if len(cfg.Schedule) > 0 && coverage.DefaultDays == 0 {
+		findings = append(findings, Finding{
+			Rule:     RuleUnreachableDefault,
+			Severity: SeverityWarning,
+			Message:  fmt.Sprintf("default_album %q is never used: every day of the year is covered by an entry", cfg.DefaultAlbum),
+		})
+	}
+	return findings
+}
+
+// entryDays returns the number of days of a leap year an entry matches.
+func entryDays(e config.ScheduleEntry, endBoundary string) int {
+	sched, err := scheduler.New(&config.Config{EndBoundary: endBoundary, Schedule: []config.ScheduleEntry{e}})
+	if err != nil {
+		return 0
+	}
+	return sched.Coverage().Entries[0].Days
+}
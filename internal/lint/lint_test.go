@@ -0,0 +1,175 @@
+package lint
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newLintConfig(entries ...config.ScheduleEntry) *config.Config {
+	return &config.Config{
+		KioskURL:     "https://kiosk.example.com",
+		DefaultAlbum: "default-album",
+		Port:         8080,
+		Schedule:     entries,
+	}
+}
+
+func findRule(findings []Finding, rule string) []Finding {
+	var matched []Finding
+	for _, f := range findings {
+		if f.Rule == rule {
+			matched = append(matched, f)
+		}
+	}
+	return matched
+}
+
+func TestCheck_InvalidConfig(t *testing.T) {
+	cfg := newLintConfig()
+	cfg.KioskURL = ""
+
+	findings := Check(cfg)
+	require.Len(t, findings, 1)
+	assert.Equal(t, RuleInvalidConfig, findings[0].Rule)
+	assert.Equal(t, SeverityError, findings[0].Severity)
+}
+
+func TestCheck_DuplicateName(t *testing.T) {
+	findings := Check(newLintConfig(
+		config.ScheduleEntry{Name: "summer", Album: "a", Start: "06-01", End: "06-30"},
+		config.ScheduleEntry{Name: "summer", Album: "b", Start: "07-01", End: "07-31"},
+	))
+
+	dups := findRule(findings, RuleDuplicateName)
+	require.Len(t, dups, 1)
+	assert.Equal(t, SeverityError, dups[0].Severity)
+	assert.Contains(t, dups[0].Message, "positions [1 2]")
+}
+
+func TestCheck_Profiles(t *testing.T) {
+	cfg := newLintConfig()
+	cfg.Profiles = []config.Profile{
+		{Name: "normal", Schedule: []config.ScheduleEntry{
+			{Name: "summer", Album: "a", Start: "06-01", End: "06-30"},
+		}},
+		{Name: "open-house", Schedule: []config.ScheduleEntry{
+			{Name: "summer", Album: "a", Start: "06-01", End: "06-30"},
+			{Name: "summer", Album: "b", Start: "07-01", End: "07-31"},
+		}},
+	}
+
+	dups := findRule(Check(cfg), RuleDuplicateName)
+	require.Len(t, dups, 1)
+	assert.True(t, strings.HasPrefix(dups[0].Message, "profile open-house: "), dups[0].Message)
+}
+
+func TestCheck_DuplicateAlbum(t *testing.T) {
+	findings := Check(newLintConfig(
+		config.ScheduleEntry{Name: "june", Album: "same", Start: "06-01", End: "06-30"},
+		config.ScheduleEntry{Name: "july", Album: "same", Start: "07-01", End: "07-31"},
+	))
+
+	dups := findRule(findings, RuleDuplicateAlbum)
+	require.Len(t, dups, 1)
+	assert.Equal(t, []string{"june", "july"}, dups[0].Entries)
+	// Entries that never overlap reuse an album on purpose.
+	assert.Equal(t, SeverityInfo, dups[0].Severity)
+}
+
+func TestCheck_DuplicateAlbumOverlapping(t *testing.T) {
+	findings := Check(newLintConfig(
+		config.ScheduleEntry{Name: "summer", Album: "beach", Start: "06-01", End: "08-31"},
+		config.ScheduleEntry{Name: "vacation", Albums: []string{"beach", "pool"}, RotateMinutes: 30, Start: "07-01", End: "07-14"},
+	))
+
+	dups := findRule(findings, RuleDuplicateAlbum)
+	require.Len(t, dups, 1)
+	assert.Equal(t, SeverityWarning, dups[0].Severity)
+	assert.Contains(t, dups[0].Message, `entries "summer" and "vacation" overlap`)
+	// The scheduler's own warnings are not reported twice.
+	assert.Len(t, findRule(findings, RuleShadowed), 1)
+	assert.Len(t, findRule(findings, RuleOverlap), 1)
+}
+
+func TestCheck_ReversedRange(t *testing.T) {
+	findings := Check(newLintConfig(
+		// Likely meant 06-21 to 09-21
+		config.ScheduleEntry{Name: "summer", Album: "a", Start: "09-21", End: "06-21"},
+		// A short year-wrap is fine
+		config.ScheduleEntry{Name: "christmas", Album: "b", Start: "11-15", End: "01-01"},
+	))
+
+	reversed := findRule(findings, RuleReversedRange)
+	require.Len(t, reversed, 1)
+	assert.Equal(t, []string{"summer"}, reversed[0].Entries)
+}
+
+func TestCheck_Shadowed(t *testing.T) {
+	findings := Check(newLintConfig(
+		config.ScheduleEntry{Name: "christmas", Album: "a", Start: "11-15", End: "01-01"},
+		config.ScheduleEntry{Name: "xmas-eve", Album: "b", Start: "12-24", End: "12-24"},
+	))
+
+	shadowed := findRule(findings, RuleShadowed)
+	require.Len(t, shadowed, 1)
+	assert.Equal(t, []string{"xmas-eve"}, shadowed[0].Entries)
+	assert.Equal(t, SeverityWarning, shadowed[0].Severity)
+}
+
+func TestCheck_UnreachableDefault(t *testing.T) {
+	findings := Check(newLintConfig(
+		config.ScheduleEntry{Name: "first-half", Album: "a", Start: "01-01", End: "06-30"},
+		config.ScheduleEntry{Name: "second-half", Album: "b", Start: "07-01", End: "12-31"},
+	))
+
+	assert.Len(t, findRule(findings, RuleUnreachableDefault), 1)
+	assert.Empty(t, findRule(findings, RuleGap))
+}
+
+func TestCheck_OverlapAndGapAreInfo(t *testing.T) {
+	findings := Check(newLintConfig(
+		config.ScheduleEntry{Name: "special", Album: "a", Start: "12-20", End: "12-26"},
+		config.ScheduleEntry{Name: "christmas", Album: "b", Start: "11-15", End: "01-01"},
+	))
+
+	assert.Equal(t, 0, Count(findings, SeverityError))
+	assert.Equal(t, 0, Count(findings, SeverityWarning))
+	assert.Len(t, findRule(findings, RuleOverlap), 1)
+	assert.Len(t, findRule(findings, RuleGap), 1)
+}
+
+func TestUnknownAlbums(t *testing.T) {
+	cfg := newLintConfig(
+		config.ScheduleEntry{Name: "christmas", Album: "christmas-album", Start: "12-01", End: "12-31"},
+		config.ScheduleEntry{Name: "summer", Albums: []string{"beach-album", "default-album"}, RotateMinutes: 30, Start: "06-01", End: "08-31"},
+	)
+	cfg.PartyModes = []config.PartyMode{{Name: "birthday", Album: "party-album"}}
+
+	known := map[string]bool{"default-album": true, "christmas-album": true}
+	findings := UnknownAlbums(cfg, known)
+	require.Len(t, findings, 2)
+	assert.Equal(t, RuleUnknownAlbum, findings[0].Rule)
+	assert.Equal(t, SeverityError, findings[0].Severity)
+	assert.Equal(t, `schedule entry summer: album "beach-album" does not exist in Immich`, findings[0].Message)
+	assert.Equal(t, `party mode birthday: album "party-album" does not exist in Immich`, findings[1].Message)
+
+	known["beach-album"], known["party-album"] = true, true
+	assert.Empty(t, UnknownAlbums(cfg, known))
+}
+
+func TestCheck_DuplicateAlbumAlias(t *testing.T) {
+	cfg := newLintConfig(
+		config.ScheduleEntry{Name: "a", Album: "xmas", Start: "12-01", End: "12-10"},
+		config.ScheduleEntry{Name: "b", Album: "5f0c9d4e-2b1a-4c3d-8e7f-6a5b4c3d2e1f", Start: "12-11", End: "12-20"},
+	)
+	cfg.Albums = map[string]string{"xmas": "5f0c9d4e-2b1a-4c3d-8e7f-6a5b4c3d2e1f"}
+	cfg.DefaultAlbum = "0a1b2c3d-4e5f-4a6b-8c7d-9e0f1a2b3c4d"
+
+	dups := findRule(Check(cfg), RuleDuplicateAlbum)
+	require.Len(t, dups, 1)
+	assert.Equal(t, []string{"a", "b"}, dups[0].Entries)
+}
@@ -0,0 +1,238 @@
+// Package logging provides slog handlers and logger setup for console and machine output.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Log output formats.
+const (
+	FormatAuto = "auto"
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// ANSI escape sequences used by the colorized text handler.
+const (
+	ansiReset  = "\x1b[0m"
+	ansiDim    = "\x1b[2m"
+	ansiRed    = "\x1b[31m"
+	ansiYellow = "\x1b[33m"
+	ansiBlue   = "\x1b[34m"
+	ansiCyan   = "\x1b[36m"
+)
+
+// ParseLevel converts a level name to a slog level, defaulting to info.
+func ParseLevel(level string) slog.Level {
+	switch level {
+	case "debug":
+		return slog.LevelDebug
+	case "warn":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// IsTerminal reports whether the file is attached to a terminal.
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// NewHandler creates a handler for the given format writing to f.
+// The auto format selects text output (colorized unless NO_COLOR is set)
+// when f is a terminal and JSON otherwise.
+func NewHandler(f *os.File, format string, opts *slog.HandlerOptions) slog.Handler {
+	tty := IsTerminal(f)
+	switch format {
+	case FormatText:
+		return NewTextHandler(f, opts, tty && os.Getenv("NO_COLOR") == "")
+	case FormatJSON:
+		return slog.NewJSONHandler(f, opts)
+	default:
+		if tty {
+			return NewTextHandler(f, opts, os.Getenv("NO_COLOR") == "")
+		}
+		return slog.NewJSONHandler(f, opts)
+	}
+}
+
+// TextHandler is a human-readable slog handler for interactive use.
+// Records are written as "15:04:05.000 INFO  message key=value".
+type TextHandler struct {
+	w     io.Writer
+	mu    *sync.Mutex
+	opts  slog.HandlerOptions
+	color bool
+	attrs string // pre-formatted attributes from WithAttrs
+	group string // group prefix from WithGroup, including trailing dot
+}
+
+// NewTextHandler creates a text handler. When color is true, levels and keys
+// are highlighted using ANSI escape sequences.
+func NewTextHandler(w io.Writer, opts *slog.HandlerOptions, color bool) *TextHandler {
+	h := &TextHandler{w: w, mu: &sync.Mutex{}, color: color}
+	if opts != nil {
+		h.opts = *opts
+	}
+	return h
+}
+
+// Enabled reports whether the handler handles records at the given level.
+func (h *TextHandler) Enabled(_ context.Context, level slog.Level) bool {
+	minLevel := slog.LevelInfo
+	if h.opts.Level != nil {
+		minLevel = h.opts.Level.Level()
+	}
+	return level >= minLevel
+}
+
+// Handle formats and writes a record.
+func (h *TextHandler) Handle(_ context.Context, r slog.Record) error {
+	var b strings.Builder
+
+	if !r.Time.IsZero() {
+		h.paint(&b, ansiDim, r.Time.Format("15:04:05.000"))
+		b.WriteByte(' ')
+	}
+	h.paint(&b, levelColor(r.Level), fmt.Sprintf("%-5s", r.Level.String()))
+	b.WriteByte(' ')
+	b.WriteString(r.Message)
+	b.WriteString(h.attrs)
+
+	r.Attrs(func(a slog.Attr) bool {
+		h.appendAttr(&b, h.group, a)
+		return true
+	})
+	b.WriteByte('\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, b.String())
+	return err
+}
+
+// WithAttrs returns a handler that includes the given attributes in every record.
+func (h *TextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var b strings.Builder
+	for _, a := range attrs {
+		h.appendAttr(&b, h.group, a)
+	}
+	clone := *h
+	clone.attrs = h.attrs + b.String()
+	return &clone
+}
+
+// WithGroup returns a handler that qualifies subsequent attribute keys with name.
+func (h *TextHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	clone.group = h.group + name + "."
+	return &clone
+}
+
+// appendAttr writes a single " key=value" pair, flattening groups.
+func (h *TextHandler) appendAttr(b *strings.Builder, prefix string, a slog.Attr) {
+	if h.opts.ReplaceAttr != nil && a.Value.Kind() != slog.KindGroup {
+		a = h.opts.ReplaceAttr(nil, a)
+	}
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+
+	if a.Value.Kind() == slog.KindGroup {
+		groupPrefix := prefix
+		if a.Key != "" {
+			groupPrefix += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			h.appendAttr(b, groupPrefix, ga)
+		}
+		return
+	}
+
+	b.WriteByte(' ')
+	h.paint(b, ansiCyan, prefix+a.Key)
+	b.WriteByte('=')
+	b.WriteString(formatValue(a.Value))
+}
+
+// paint writes s, wrapped in the given color when colors are enabled.
+func (h *TextHandler) paint(b *strings.Builder, color, s string) {
+	if !h.color {
+		b.WriteString(s)
+		return
+	}
+	b.WriteString(color)
+	b.WriteString(s)
+	b.WriteString(ansiReset)
+}
+
+// levelColor returns the color used for a log level.
+func levelColor(level slog.Level) string {
+	switch {
+	case level >= slog.LevelError:
+		return ansiRed
+	case level >= slog.LevelWarn:
+		return ansiYellow
+	case level >= slog.LevelInfo:
+		return ansiBlue
+	default:
+		return ansiDim
+	}
+}
+
+// formatValue renders a value, quoting strings that contain spaces or quotes.
+func formatValue(v slog.Value) string {
+	var s string
+	switch v.Kind() {
+	case slog.KindString:
+		s = v.String()
+	case slog.KindTime:
+		s = v.Time().Format(time.RFC3339)
+	case slog.KindAny:
+		if err, ok := v.Any().(error); ok {
+			s = err.Error()
+		} else {
+			s = fmt.Sprint(v.Any())
+		}
+	default:
+		s = v.String()
+	}
+
+	if needsQuoting(s) {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// needsQuoting reports whether a value must be quoted to stay unambiguous.
+func needsQuoting(s string) bool {
+	if s == "" {
+		return true
+	}
+	for _, r := range s {
+		if unicode.IsSpace(r) || r == '"' || r == '=' || !unicode.IsPrint(r) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLevel(t *testing.T) {
+	assert.Equal(t, slog.LevelDebug, ParseLevel("debug"))
+	assert.Equal(t, slog.LevelWarn, ParseLevel("warn"))
+	assert.Equal(t, slog.LevelError, ParseLevel("error"))
+	assert.Equal(t, slog.LevelInfo, ParseLevel("info"))
+	assert.Equal(t, slog.LevelInfo, ParseLevel("bogus"))
+}
+
+func TestTextHandler_Format(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewTextHandler(&buf, nil, false))
+
+	logger.Info("redirecting",
+		slog.String("schedule", "christmas"),
+		slog.String("note", "two words"),
+		slog.Int("count", 3),
+		slog.Any("error", errors.New("boom")),
+	)
+
+	line := buf.String()
+	assert.Contains(t, line, "INFO  redirecting")
+	assert.Contains(t, line, "schedule=christmas")
+	assert.Contains(t, line, `note="two words"`)
+	assert.Contains(t, line, "count=3")
+	assert.Contains(t, line, "error=boom")
+	assert.True(t, strings.HasSuffix(line, "\n"))
+	assert.NotContains(t, line, "\x1b[")
+}
+
+func TestTextHandler_Color(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewTextHandler(&buf, nil, true))
+
+	logger.Error("failed")
+
+	assert.Contains(t, buf.String(), ansiRed+"ERROR"+ansiReset)
+}
+
+func TestTextHandler_Level(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelWarn}, false))
+
+	logger.Info("hidden")
+	logger.Warn("shown")
+
+	assert.NotContains(t, buf.String(), "hidden")
+	assert.Contains(t, buf.String(), "WARN  shown")
+}
+
+func TestTextHandler_AttrsAndGroups(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewTextHandler(&buf, nil, false)).
+		With(slog.String("component", "server")).
+		WithGroup("http")
+
+	logger.Info("request", slog.Int("status", 302), slog.Group("client", slog.String("ip", "10.0.0.1")))
+
+	line := buf.String()
+	assert.Contains(t, line, "component=server")
+	assert.Contains(t, line, "http.status=302")
+	assert.Contains(t, line, "http.client.ip=10.0.0.1")
+}
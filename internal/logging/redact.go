@@ -0,0 +1,173 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces the values of redacted log attributes.
+const Redacted = "[redacted]"
+
+// RedactQuery, listed among the redacted fields, masks the query values of
+// URLs in all attributes instead of a single attribute.
+const RedactQuery = "query"
+
+// queryRegex finds the query string of a URL within a value.
+var queryRegex = regexp.MustCompile(`\?[^\s"'#]+`)
+
+// albumPlaceholder marks where URL templates hold the album ID.
+const albumPlaceholder = "{album}"
+
+// albumSegment matches the album ID taking the place of the placeholder.
+const albumSegment = `[^/?#\s"']+`
+
+// RedactHandler masks attributes before passing records to another handler,
+// e.g. so logs can be shipped to a hosted service without album IDs or
+// addresses.
+type RedactHandler struct {
+	next  slog.Handler
+	keys  map[string]bool
+	query bool
+	// albumPaths find the album IDs in the paths of URLs built from
+	// templates selecting the album in their path.
+	albumPaths []*regexp.Regexp
+}
+
+// NewRedactHandler creates a handler masking the attributes with the given
+// keys, in any group, and with RedactQuery the query values of URLs.
+// albumURLs are URL templates with an {album} placeholder in their path,
+// e.g. kiosk_url; with RedactQuery or "album" among the fields, the album
+// IDs in the paths of URLs built from them are masked too.
+func NewRedactHandler(next slog.Handler, fields []string, albumURLs ...string) *RedactHandler {
+	h := &RedactHandler{next: next, keys: make(map[string]bool, len(fields))}
+	for _, f := range fields {
+		if f == RedactQuery {
+			h.query = true
+		} else {
+			h.keys[f] = true
+		}
+	}
+	if h.query || h.keys["album"] {
+		for _, u := range albumURLs {
+			if re := albumPathRegex(u); re != nil {
+				h.albumPaths = append(h.albumPaths, re)
+			}
+		}
+	}
+	return h
+}
+
+// albumPathRegex returns a regex capturing the text around the album IDs
+// of URLs built from template, or nil when it has no {album} placeholder.
+func albumPathRegex(template string) *regexp.Regexp {
+	parts := strings.Split(template, albumPlaceholder)
+	if len(parts) < 2 {
+		return nil
+	}
+	var pattern strings.Builder
+	for _, part := range parts[:len(parts)-1] {
+		pattern.WriteString("(" + regexp.QuoteMeta(part) + ")" + albumSegment)
+	}
+	return regexp.MustCompile(pattern.String())
+}
+
+// Enabled reports whether the next handler handles records at the given level.
+func (h *RedactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle masks the record's attributes and passes it on.
+func (h *RedactHandler) Handle(ctx context.Context, r slog.Record) error {
+	redacted := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		redacted.AddAttrs(h.redact(a))
+		return true
+	})
+	return h.next.Handle(ctx, redacted)
+}
+
+// WithAttrs returns a handler that includes the masked attributes in every record.
+func (h *RedactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = h.redact(a)
+	}
+	clone := *h
+	clone.next = h.next.WithAttrs(redacted)
+	return &clone
+}
+
+// WithGroup returns a handler that qualifies subsequent attribute keys with name.
+func (h *RedactHandler) WithGroup(name string) slog.Handler {
+	clone := *h
+	clone.next = h.next.WithGroup(name)
+	return &clone
+}
+
+// redact masks an attribute, or the attributes of a group.
+func (h *RedactHandler) redact(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+	if h.keys[a.Key] {
+		return slog.String(a.Key, Redacted)
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindGroup:
+		group := a.Value.Group()
+		redacted := make([]slog.Attr, len(group))
+		for i, ga := range group {
+			redacted[i] = h.redact(ga)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(redacted...)}
+	case slog.KindString:
+		if h.query || len(h.albumPaths) > 0 {
+			return slog.String(a.Key, h.redactURLs(a.Value.String()))
+		}
+	case slog.KindAny:
+		// Errors often quote the URL of a failed request.
+		if err, ok := a.Value.Any().(error); ok && (h.query || len(h.albumPaths) > 0) {
+			msg := err.Error()
+			if redacted := h.redactURLs(msg); redacted != msg {
+				return slog.String(a.Key, redacted)
+			}
+		}
+	}
+	return a
+}
+
+// redactURLs masks the album IDs in the paths of URLs found in s and, with
+// RedactQuery, the values of their query strings.
+func (h *RedactHandler) redactURLs(s string) string {
+	for _, re := range h.albumPaths {
+		s = re.ReplaceAllStringFunc(s, func(match string) string {
+			var b strings.Builder
+			for _, prefix := range re.FindStringSubmatch(match)[1:] {
+				b.WriteString(prefix + Redacted)
+			}
+			return b.String()
+		})
+	}
+	if h.query {
+		s = redactQueries(s)
+	}
+	return s
+}
+
+// redactQueries masks the values in the query strings found in s, keeping
+// the parameter names.
+func redactQueries(s string) string {
+	if !strings.Contains(s, "?") {
+		return s
+	}
+	return queryRegex.ReplaceAllStringFunc(s, func(query string) string {
+		params := strings.Split(query[1:], "&")
+		for i, p := range params {
+			if name, _, ok := strings.Cut(p, "="); ok {
+				params[i] = name + "=" + Redacted
+			}
+		}
+		return "?" + strings.Join(params, "&")
+	})
+}
@@ -0,0 +1,70 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewRedactHandler(slog.NewJSONHandler(&buf, nil), []string{"album", "remote", RedactQuery}))
+
+	logger.With(slog.String("album", "default-album-id")).WithGroup("http").Info("redirecting",
+		slog.String("schedule", "christmas"),
+		slog.String("remote", "192.168.1.20:51234"),
+		slog.String("redirect_url", "https://kiosk.example.com/?album=christmas-album&transition=fade&show"),
+		slog.Group("request", slog.String("album", "christmas-album")),
+		slog.Any("error", errors.New(`Get "https://immich.local/api/albums?shared=true": timeout`)),
+	)
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	assert.Equal(t, Redacted, record["album"])
+	http := record["http"].(map[string]any)
+	assert.Equal(t, "christmas", http["schedule"])
+	assert.Equal(t, Redacted, http["remote"])
+	assert.Equal(t, "https://kiosk.example.com/?album=[redacted]&transition=[redacted]&show", http["redirect_url"])
+	assert.Equal(t, map[string]any{"album": Redacted}, http["request"])
+	assert.Equal(t, `Get "https://immich.local/api/albums?shared=[redacted]": timeout`, http["error"])
+}
+
+func TestRedactHandler_KeysOnly(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewRedactHandler(NewTextHandler(&buf, nil, false), []string{"album"}))
+
+	logger.Info("redirecting",
+		slog.String("album", "christmas-album"),
+		slog.String("redirect_url", "https://kiosk.example.com/?transition=fade"),
+	)
+
+	assert.Contains(t, buf.String(), "album=[redacted]")
+	assert.Contains(t, buf.String(), `redirect_url="https://kiosk.example.com/?transition=fade"`)
+}
+
+func TestRedactHandler_AlbumPath(t *testing.T) {
+	for _, fields := range [][]string{{RedactQuery}, {"album"}} {
+		var buf bytes.Buffer
+		logger := slog.New(NewRedactHandler(slog.NewJSONHandler(&buf, nil), fields,
+			"https://kiosk.example.com/albums/{album}/slideshow", "https://den.example.com/{album}"))
+
+		logger.Info("redirecting",
+			slog.String("redirect_url", "https://kiosk.example.com/albums/christmas-album/slideshow?transition=fade"),
+			slog.String("kiosk_url", "https://den.example.com/summer-album"),
+			slog.Any("error", errors.New(`Get "https://kiosk.example.com/albums/christmas-album/slideshow": timeout`)),
+			slog.String("health", "https://kiosk.example.com/healthz"),
+		)
+
+		var record map[string]any
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+		assert.Contains(t, record["redirect_url"], "https://kiosk.example.com/albums/[redacted]/slideshow?transition=")
+		assert.Equal(t, "https://den.example.com/[redacted]", record["kiosk_url"])
+		assert.Equal(t, `Get "https://kiosk.example.com/albums/[redacted]/slideshow": timeout`, record["error"])
+		assert.Equal(t, "https://kiosk.example.com/healthz", record["health"])
+	}
+}
@@ -0,0 +1,210 @@
+// Package metrics exports the application's Prometheus metrics to alternative backends.
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+)
+
+// Namespace is the common prefix of all application metrics.
+// Only metrics with this prefix are forwarded to StatsD.
+const Namespace = "immich_kiosk_scheduler_"
+
+// maxPacketSize keeps UDP packets below the typical Ethernet MTU.
+const maxPacketSize = 1432
+
+// StatsDExporter periodically forwards application metrics from a Prometheus
+// gatherer to a StatsD or DogStatsD server over UDP.
+// Counters are sent as deltas since the previous flush, gauges as absolute values.
+type StatsDExporter struct {
+	address  string
+	prefix   string
+	tagged   bool
+	interval time.Duration
+	gatherer prometheus.Gatherer
+	logger   *slog.Logger
+
+	conn     net.Conn
+	counters map[string]float64
+	gauges   map[string]bool
+}
+
+// NewStatsDExporter creates an exporter for the given configuration.
+func NewStatsDExporter(cfg config.StatsDConfig, gatherer prometheus.Gatherer) (*StatsDExporter, error) {
+	conn, err := net.Dial("udp", cfg.Address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial statsd at %s: %w", cfg.Address, err)
+	}
+
+	interval := cfg.Interval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+
+	return &StatsDExporter{
+		address:  cfg.Address,
+		prefix:   cfg.Prefix,
+		tagged:   cfg.Flavor == "dogstatsd",
+		interval: interval,
+		gatherer: gatherer,
+		logger:   slog.Default(),
+		conn:     conn,
+		counters: make(map[string]float64),
+		gauges:   make(map[string]bool),
+	}, nil
+}
+
+// Run flushes metrics every interval until the context is cancelled.
+// A final flush is performed before returning.
+func (e *StatsDExporter) Run(ctx context.Context) {
+	defer e.conn.Close()
+
+	e.logger.Info("exporting metrics to statsd",
+		slog.String("address", e.address),
+		slog.Duration("interval", e.interval),
+	)
+
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := e.Flush(); err != nil {
+				e.logger.Warn("final statsd flush failed", slog.Any("error", err))
+			}
+			return
+		case <-ticker.C:
+			if err := e.Flush(); err != nil {
+				e.logger.Warn("statsd flush failed", slog.Any("error", err))
+			}
+		}
+	}
+}
+
+// Flush gathers the current metric values and sends them to StatsD.
+func (e *StatsDExporter) Flush() error {
+	families, err := e.gatherer.Gather()
+	if err != nil {
+		return fmt.Errorf("failed to gather metrics: %w", err)
+	}
+
+	var lines []string
+	seenGauges := make(map[string]bool)
+
+	for _, mf := range families {
+		if !strings.HasPrefix(mf.GetName(), Namespace) {
+			continue
+		}
+		name := e.prefix + strings.TrimPrefix(mf.GetName(), Namespace)
+
+		for _, m := range mf.GetMetric() {
+			key := e.seriesKey(name, m.GetLabel())
+
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				value := m.GetCounter().GetValue()
+				delta := value - e.counters[key]
+				e.counters[key] = value
+				if delta > 0 {
+					lines = append(lines, e.line(key, delta, "c"))
+				}
+			case dto.MetricType_GAUGE:
+				seenGauges[key] = true
+				lines = append(lines, e.line(key, m.GetGauge().GetValue(), "g"))
+			}
+		}
+	}
+
+	// Gauge series that disappeared (e.g. after a Reset) are zeroed so that
+	// StatsD servers don't keep reporting a stale value.
+	for key := range e.gauges {
+		if !seenGauges[key] {
+			lines = append(lines, e.line(key, 0, "g"))
+		}
+	}
+	e.gauges = seenGauges
+
+	return e.send(lines)
+}
+
+// seriesKey builds the wire representation of a metric name and its labels.
+// DogStatsD uses tags; plain StatsD folds label values into the metric name.
+func (e *StatsDExporter) seriesKey(name string, labels []*dto.LabelPair) string {
+	if len(labels) == 0 {
+		return name
+	}
+
+	sorted := append([]*dto.LabelPair(nil), labels...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].GetName() < sorted[j].GetName() })
+
+	if e.tagged {
+		tags := make([]string, 0, len(sorted))
+		for _, l := range sorted {
+			tags = append(tags, sanitize(l.GetName())+":"+sanitize(l.GetValue()))
+		}
+		return name + "|#" + strings.Join(tags, ",")
+	}
+
+	parts := []string{name}
+	for _, l := range sorted {
+		parts = append(parts, sanitize(l.GetValue()))
+	}
+	return strings.Join(parts, ".")
+}
+
+// line formats a single StatsD line, placing the tags after the type for DogStatsD.
+func (e *StatsDExporter) line(key string, value float64, kind string) string {
+	name, tags, _ := strings.Cut(key, "|")
+	formatted := name + ":" + strconv.FormatFloat(value, 'f', -1, 64) + "|" + kind
+	if tags != "" {
+		formatted += "|" + tags
+	}
+	return formatted
+}
+
+// send writes the lines in as few UDP packets as possible.
+func (e *StatsDExporter) send(lines []string) error {
+	var buf bytes.Buffer
+	for _, l := range lines {
+		if buf.Len() > 0 && buf.Len()+1+len(l) > maxPacketSize {
+			if _, err := e.conn.Write(buf.Bytes()); err != nil {
+				return err
+			}
+			buf.Reset()
+		}
+		if buf.Len() > 0 {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(l)
+	}
+	if buf.Len() > 0 {
+		if _, err := e.conn.Write(buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sanitize replaces characters that have a meaning in the StatsD line protocol.
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ':', '|', ',', '#', '@', ' ', '\n':
+			return '_'
+		}
+		return r
+	}, s)
+}
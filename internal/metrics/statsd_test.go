@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+)
+
+func newTestExporter(t *testing.T, flavor string) (*StatsDExporter, *prometheus.Registry, net.PacketConn) {
+	t.Helper()
+
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	registry := prometheus.NewRegistry()
+	exporter, err := NewStatsDExporter(config.StatsDConfig{
+		Address: listener.LocalAddr().String(),
+		Prefix:  "iks.",
+		Flavor:  flavor,
+	}, registry)
+	require.NoError(t, err)
+	t.Cleanup(func() { exporter.conn.Close() })
+
+	return exporter, registry, listener
+}
+
+func readPacket(t *testing.T, conn net.PacketConn) []string {
+	t.Helper()
+	buf := make([]byte, maxPacketSize)
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	return strings.Split(string(buf[:n]), "\n")
+}
+
+func TestStatsDExporter_CounterDeltas(t *testing.T) {
+	exporter, registry, listener := newTestExporter(t, "statsd")
+
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "immich_kiosk_scheduler_redirects_total",
+		Help: "test",
+	}, []string{"schedule"})
+	registry.MustRegister(counter)
+
+	counter.WithLabelValues("christmas").Add(3)
+	require.NoError(t, exporter.Flush())
+	assert.Equal(t, []string{"iks.redirects_total.christmas:3|c"}, readPacket(t, listener))
+
+	counter.WithLabelValues("christmas").Add(2)
+	require.NoError(t, exporter.Flush())
+	assert.Equal(t, []string{"iks.redirects_total.christmas:2|c"}, readPacket(t, listener))
+}
+
+func TestStatsDExporter_DogStatsDTags(t *testing.T) {
+	exporter, registry, listener := newTestExporter(t, "dogstatsd")
+
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "immich_kiosk_scheduler_current_schedule",
+		Help: "test",
+	}, []string{"schedule"})
+	registry.MustRegister(gauge)
+
+	gauge.WithLabelValues("summer").Set(1)
+	require.NoError(t, exporter.Flush())
+	assert.Equal(t, []string{"iks.current_schedule:1|g|#schedule:summer"}, readPacket(t, listener))
+
+	// Reset gauges are reported as zero once
+	gauge.Reset()
+	gauge.WithLabelValues("fall").Set(1)
+	require.NoError(t, exporter.Flush())
+	assert.ElementsMatch(t, []string{
+		"iks.current_schedule:1|g|#schedule:fall",
+		"iks.current_schedule:0|g|#schedule:summer",
+	}, readPacket(t, listener))
+}
+
+func TestStatsDExporter_IgnoresForeignMetrics(t *testing.T) {
+	exporter, registry, listener := newTestExporter(t, "statsd")
+
+	registry.MustRegister(prometheus.NewCounter(prometheus.CounterOpts{Name: "go_something_total", Help: "test"}))
+	own := prometheus.NewGauge(prometheus.GaugeOpts{Name: "immich_kiosk_scheduler_up", Help: "test"})
+	registry.MustRegister(own)
+	own.Set(1)
+
+	require.NoError(t, exporter.Flush())
+	assert.Equal(t, []string{"iks.up:1|g"}, readPacket(t, listener))
+}
@@ -0,0 +1,358 @@
+// Package mqtt is a minimal MQTT 3.1.1 client. It keeps the last value of
+// the topics read by when conditions, such as mqtt["home/mode"] == "guest",
+// and publishes messages over the same connection.
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+)
+
+// Reconnect delays after a lost or failed connection.
+const (
+	minRetryDelay = time.Second
+	maxRetryDelay = time.Minute
+)
+
+// dialTimeout bounds connecting to the broker, including the handshake.
+const dialTimeout = 10 * time.Second
+
+// ErrNotConnected is returned when writing while the broker is unreachable.
+var ErrNotConnected = errors.New("not connected to the MQTT broker")
+
+// MQTT metrics
+var connectionsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "immich_kiosk_scheduler_mqtt_connections_total",
+		Help: "Total number of MQTT connection attempts by result",
+	},
+	[]string{"result"},
+)
+
+func init() {
+	prometheus.MustRegister(connectionsTotal)
+}
+
+// Status describes the connection to the broker.
+type Status struct {
+	Broker    string `json:"broker"`
+	Connected bool   `json:"connected"`
+	// Values holds the last value of each subscribed topic.
+	Values      map[string]string `json:"values,omitempty"`
+	LastConnect *time.Time        `json:"last_connect,omitempty"`
+	LastError   string            `json:"last_error,omitempty"`
+	LastErrorAt *time.Time        `json:"last_error_at,omitempty"`
+}
+
+// Client keeps a connection to the broker, subscribed to the topics read by
+// the schedule.
+type Client struct {
+	cfg    config.MQTTConfig
+	topics func() []string
+	logger *slog.Logger
+
+	values atomic.Pointer[map[string]string]
+
+	// writeMu serializes writes to conn.
+	writeMu sync.Mutex
+	conn    net.Conn
+
+	mu     sync.Mutex
+	status Status
+}
+
+// New creates a Client. topics returns the topics to subscribe to; it is
+// checked on every keep-alive so configuration reloads are picked up.
+func New(cfg config.MQTTConfig, topics func() []string, logger *slog.Logger) *Client {
+	return &Client{
+		cfg:    cfg,
+		topics: topics,
+		logger: logger,
+		status: Status{Broker: cfg.Broker},
+	}
+}
+
+// Values returns the last value of each topic received so far. The map
+// must not be modified.
+func (c *Client) Values() map[string]string {
+	if values := c.values.Load(); values != nil {
+		return *values
+	}
+	return nil
+}
+
+// Status returns the current status.
+func (c *Client) Status() Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	status := c.status
+	status.Values = c.Values()
+	return status
+}
+
+// Run connects to the broker and reconnects with a growing delay whenever
+// the connection is lost, until the context is cancelled. Values are kept
+// while disconnected; retained messages refresh them on reconnect.
+func (c *Client) Run(ctx context.Context) {
+	delay := minRetryDelay
+	for {
+		connected, err := c.session(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if connected {
+			delay = minRetryDelay
+		}
+		c.fail(err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxRetryDelay)
+	}
+}
+
+// session connects, subscribes and processes packets until the connection
+// fails. It reports whether the broker accepted the connection.
+func (c *Client) session(ctx context.Context) (bool, error) {
+	conn, err := c.dial(ctx)
+	if err != nil {
+		connectionsTotal.WithLabelValues("failure").Inc()
+		return false, err
+	}
+	defer conn.Close()
+	// Close the connection on shutdown to unblock the reader.
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	keepAlive := c.cfg.KeepAliveInterval()
+	r := bufio.NewReader(conn)
+	if err := c.handshake(conn, r, keepAlive); err != nil {
+		connectionsTotal.WithLabelValues("failure").Inc()
+		return false, err
+	}
+	connectionsTotal.WithLabelValues("success").Inc()
+
+	c.writeMu.Lock()
+	c.conn = conn
+	c.writeMu.Unlock()
+	defer func() {
+		c.writeMu.Lock()
+		c.conn = nil
+		c.writeMu.Unlock()
+	}()
+	c.connected()
+
+	errs := make(chan error, 1)
+	go func() { errs <- c.read(conn, r, keepAlive) }()
+
+	var subscribed []string
+	var packetID uint16
+	ticker := time.NewTicker(keepAlive / 2)
+	defer ticker.Stop()
+	for {
+		if topics := c.topics(); len(topics) > 0 {
+			if missing := newTopics(topics, subscribed); len(missing) > 0 {
+				packetID++
+				if err := c.send(subscribePacket(packetID, missing)); err != nil {
+					return true, err
+				}
+				subscribed = append(subscribed, missing...)
+				c.logger.Debug("subscribed to MQTT topics", slog.Any("topics", missing))
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			_ = c.send(encodePacket(packetDisconnect, 0, nil))
+			return true, ctx.Err()
+		case err := <-errs:
+			return true, err
+		case <-ticker.C:
+			if err := c.send(encodePacket(packetPingreq, 0, nil)); err != nil {
+				return true, err
+			}
+		}
+	}
+}
+
+// dial opens the network connection to the broker.
+func (c *Client) dial(ctx context.Context) (net.Conn, error) {
+	u, err := url.Parse(c.cfg.Broker)
+	if err != nil {
+		return nil, err
+	}
+	host := u.Host
+	secure := u.Scheme == "tls" || u.Scheme == "ssl" || u.Scheme == "mqtts"
+	if u.Port() == "" {
+		port := "1883"
+		if secure {
+			port = "8883"
+		}
+		host = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dialTimeout)
+	defer cancel()
+	if secure {
+		d := &tls.Dialer{Config: &tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12}}
+		return d.DialContext(ctx, "tcp", host)
+	}
+	var d net.Dialer
+	return d.DialContext(ctx, "tcp", host)
+}
+
+// handshake sends CONNECT and waits for the broker's CONNACK.
+func (c *Client) handshake(conn net.Conn, r *bufio.Reader, keepAlive time.Duration) error {
+	if err := conn.SetDeadline(time.Now().Add(dialTimeout)); err != nil {
+		return err
+	}
+	seconds := uint16(keepAlive / time.Second)
+	if _, err := conn.Write(connectPacket(c.cfg.ID(), c.cfg.Username, c.cfg.Password, seconds)); err != nil {
+		return err
+	}
+	p, err := readPacket(r)
+	if err != nil {
+		return fmt.Errorf("reading CONNACK: %w", err)
+	}
+	if p.kind != packetConnack || len(p.body) != 2 {
+		return fmt.Errorf("expected CONNACK, got packet type %d", p.kind)
+	}
+	if err := connackError(p.body[1]); err != nil {
+		return err
+	}
+	return conn.SetDeadline(time.Time{})
+}
+
+// read processes packets from the broker. The broker answers pings within
+// the keep-alive interval, so a longer silence means the connection is gone.
+func (c *Client) read(conn net.Conn, r *bufio.Reader, keepAlive time.Duration) error {
+	for {
+		if err := conn.SetReadDeadline(time.Now().Add(keepAlive * 3 / 2)); err != nil {
+			return err
+		}
+		p, err := readPacket(r)
+		if err != nil {
+			return err
+		}
+
+		switch p.kind {
+		case packetPublish:
+			m, err := parsePublish(p)
+			if err != nil {
+				return err
+			}
+			if m.qos == 1 {
+				if err := c.send(encodePacket(packetPuback, 0, binary.BigEndian.AppendUint16(nil, m.id))); err != nil {
+					return err
+				}
+			}
+			c.store(m.topic, string(m.payload))
+		case packetSuback:
+			if slices.Contains(p.body[min(2, len(p.body)):], 0x80) {
+				return errors.New("broker rejected a subscription")
+			}
+		case packetPingresp:
+		default:
+			c.logger.Debug("ignoring MQTT packet", slog.Int("type", int(p.kind)))
+		}
+	}
+}
+
+// send writes a packet to the current connection.
+func (c *Client) send(b []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.conn == nil {
+		return ErrNotConnected
+	}
+	return c.write(b)
+}
+
+// write writes b to the connection. The caller must hold writeMu.
+func (c *Client) write(b []byte) error {
+	if err := c.conn.SetWriteDeadline(time.Now().Add(dialTimeout)); err != nil {
+		return err
+	}
+	_, err := c.conn.Write(b)
+	return err
+}
+
+// store records the value of a topic.
+func (c *Client) store(topic, value string) {
+	for {
+		old := c.values.Load()
+		values := make(map[string]string)
+		if old != nil {
+			if (*old)[topic] == value {
+				return
+			}
+			for k, v := range *old {
+				values[k] = v
+			}
+		}
+		values[topic] = value
+		if c.values.CompareAndSwap(old, &values) {
+			return
+		}
+	}
+}
+
+// connected records a successful connection.
+func (c *Client) connected() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.status.LastError != "" {
+		c.logger.Info("connected to MQTT broker", slog.String("broker", c.cfg.Broker))
+	}
+	now := time.Now()
+	c.status.Connected = true
+	c.status.LastConnect = &now
+	c.status.LastError = ""
+	c.status.LastErrorAt = nil
+}
+
+// fail records and logs a lost or failed connection. Repeated failures
+// while the broker stays unreachable are logged at debug level.
+func (c *Client) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.status.Connected || c.status.LastError == "" {
+		c.logger.Error("MQTT connection lost, keeping last known values",
+			slog.String("broker", c.cfg.Broker), slog.Any("error", err))
+	} else {
+		c.logger.Debug("MQTT broker still unreachable", slog.Any("error", err))
+	}
+	now := time.Now()
+	c.status.Connected = false
+	c.status.LastError = err.Error()
+	c.status.LastErrorAt = &now
+}
+
+// newTopics returns the topics not yet subscribed to.
+func newTopics(topics, subscribed []string) []string {
+	var missing []string
+	for _, topic := range topics {
+		if !slices.Contains(subscribed, topic) {
+			missing = append(missing, topic)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,164 @@
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+)
+
+// publishPacket builds a QoS 0 PUBLISH packet, as sent by brokers.
+func publishPacket(topic string, payload []byte, retain bool) []byte {
+	var flags byte
+	if retain {
+		flags = 0x01
+	}
+	return encodePacket(packetPublish, flags, append(appendString(nil, topic), payload...))
+}
+
+func TestPacket_RoundTrip(t *testing.T) {
+	payload := bytes.Repeat([]byte("x"), 300) // needs a two-byte remaining length
+	p, err := readPacket(bufio.NewReader(bytes.NewReader(publishPacket("home/mode", payload, true))))
+	require.NoError(t, err)
+	assert.Equal(t, byte(packetPublish), p.kind)
+	assert.Equal(t, byte(0x01), p.flags)
+
+	m, err := parsePublish(p)
+	require.NoError(t, err)
+	assert.Equal(t, "home/mode", m.topic)
+	assert.Equal(t, payload, m.payload)
+}
+
+func TestConnectPacket(t *testing.T) {
+	p, err := readPacket(bufio.NewReader(bytes.NewReader(connectPacket("kiosk", "user", "secret", 30))))
+	require.NoError(t, err)
+	assert.Equal(t, byte(packetConnect), p.kind)
+
+	name, rest, err := readString(p.body)
+	require.NoError(t, err)
+	assert.Equal(t, "MQTT", name)
+	assert.Equal(t, []byte{protocolLevel311, 0xc2, 0, 30}, rest[:4])
+	id, rest, err := readString(rest[4:])
+	require.NoError(t, err)
+	assert.Equal(t, "kiosk", id)
+	user, rest, err := readString(rest)
+	require.NoError(t, err)
+	assert.Equal(t, "user", user)
+	password, _, err := readString(rest)
+	require.NoError(t, err)
+	assert.Equal(t, "secret", password)
+}
+
+// fakeBroker accepts one connection, answers the handshake and subscriptions,
+// and delivers retained values for subscribed topics.
+type fakeBroker struct {
+	listener net.Listener
+	retained map[string]string
+}
+
+func newFakeBroker(t *testing.T, retained map[string]string) *fakeBroker {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	b := &fakeBroker{listener: l, retained: retained}
+	t.Cleanup(func() { l.Close() })
+	go b.serve()
+	return b
+}
+
+func (b *fakeBroker) serve() {
+	conn, err := b.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		p, err := readPacket(r)
+		if err != nil {
+			return
+		}
+		switch p.kind {
+		case packetConnect:
+			_, _ = conn.Write(encodePacket(packetConnack, 0, []byte{0, 0}))
+		case packetSubscribe:
+			id := binary.BigEndian.Uint16(p.body)
+			rest := p.body[2:]
+			var codes []byte
+			var topics []string
+			for len(rest) > 0 {
+				topic, next, _ := readString(rest)
+				topics = append(topics, topic)
+				codes = append(codes, 0)
+				rest = next[1:]
+			}
+			_, _ = conn.Write(encodePacket(packetSuback, 0, append(binary.BigEndian.AppendUint16(nil, id), codes...)))
+			for _, topic := range topics {
+				if value, ok := b.retained[topic]; ok {
+					_, _ = conn.Write(publishPacket(topic, []byte(value), true))
+				}
+			}
+		case packetPingreq:
+			_, _ = conn.Write(encodePacket(packetPingresp, 0, nil))
+		case packetDisconnect:
+			return
+		}
+	}
+}
+
+func TestClient_Subscribe(t *testing.T) {
+	broker := newFakeBroker(t, map[string]string{"home/mode": "guest"})
+	cfg := config.MQTTConfig{Broker: "tcp://" + broker.listener.Addr().String(), KeepAlive: time.Second}
+	c := New(cfg, func() []string { return []string{"home/mode"} }, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		c.Run(ctx)
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool { return c.Values()["home/mode"] == "guest" }, 5*time.Second, 10*time.Millisecond)
+	status := c.Status()
+	assert.True(t, status.Connected)
+	assert.Equal(t, "guest", status.Values["home/mode"])
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("client did not stop")
+	}
+	assert.Equal(t, "guest", c.Values()["home/mode"])
+}
+
+func TestClient_ConnectionRefused(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = readPacket(bufio.NewReader(conn))
+		_, _ = conn.Write(encodePacket(packetConnack, 0, []byte{0, 4}))
+	}()
+
+	cfg := config.MQTTConfig{Broker: "tcp://" + l.Addr().String(), Username: "user", Password: "wrong"}
+	c := New(cfg, func() []string { return nil }, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	connected, err := c.session(context.Background())
+	assert.False(t, connected)
+	assert.ErrorContains(t, err, "bad username or password")
+}
@@ -0,0 +1,185 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// MQTT 3.1.1 control packet types.
+const (
+	packetConnect     = 1
+	packetConnack     = 2
+	packetPublish     = 3
+	packetPuback      = 4
+	packetSubscribe   = 8
+	packetSuback      = 9
+	packetPingreq     = 12
+	packetPingresp    = 13
+	packetDisconnect  = 14
+	protocolLevel311  = 4
+	maxRemainingBytes = 4
+)
+
+// maxPacketSize bounds the packets accepted from the broker.
+const maxPacketSize = 1 << 20
+
+// packet is a decoded control packet.
+type packet struct {
+	kind  byte
+	flags byte
+	body  []byte
+}
+
+// readPacket reads one control packet.
+func readPacket(r *bufio.Reader) (packet, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return packet{}, err
+	}
+
+	length, multiplier := 0, 1
+	for i := 0; ; i++ {
+		if i == maxRemainingBytes {
+			return packet{}, errors.New("malformed remaining length")
+		}
+		b, err := r.ReadByte()
+		if err != nil {
+			return packet{}, err
+		}
+		length += int(b&0x7f) * multiplier
+		if b&0x80 == 0 {
+			break
+		}
+		multiplier *= 128
+	}
+	if length > maxPacketSize {
+		return packet{}, fmt.Errorf("packet of %d bytes exceeds the limit", length)
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return packet{}, err
+	}
+	return packet{kind: header >> 4, flags: header & 0x0f, body: body}, nil
+}
+
+// encodePacket returns the wire form of a control packet.
+func encodePacket(kind, flags byte, body []byte) []byte {
+	buf := make([]byte, 0, len(body)+1+maxRemainingBytes)
+	buf = append(buf, kind<<4|flags)
+	n := len(body)
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		buf = append(buf, b)
+		if n == 0 {
+			break
+		}
+	}
+	return append(buf, body...)
+}
+
+// appendString appends a length-prefixed UTF-8 string.
+func appendString(buf []byte, s string) []byte {
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(s)))
+	return append(buf, s...)
+}
+
+// readString reads a length-prefixed string from the start of b and returns
+// the rest.
+func readString(b []byte) (string, []byte, error) {
+	if len(b) < 2 {
+		return "", nil, errors.New("truncated string")
+	}
+	n := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+n {
+		return "", nil, errors.New("truncated string")
+	}
+	return string(b[2 : 2+n]), b[2+n:], nil
+}
+
+// connectPacket builds a CONNECT packet with a clean session.
+func connectPacket(clientID, username, password string, keepAlive uint16) []byte {
+	var flags byte = 0x02 // clean session
+	if username != "" {
+		flags |= 0x80
+		if password != "" {
+			flags |= 0x40
+		}
+	}
+	body := appendString(nil, "MQTT")
+	body = append(body, protocolLevel311, flags)
+	body = binary.BigEndian.AppendUint16(body, keepAlive)
+	body = appendString(body, clientID)
+	if username != "" {
+		body = appendString(body, username)
+		if password != "" {
+			body = appendString(body, password)
+		}
+	}
+	return encodePacket(packetConnect, 0, body)
+}
+
+// subscribePacket builds a SUBSCRIBE packet for topics at QoS 0.
+func subscribePacket(id uint16, topics []string) []byte {
+	body := binary.BigEndian.AppendUint16(nil, id)
+	for _, topic := range topics {
+		body = appendString(body, topic)
+		body = append(body, 0)
+	}
+	return encodePacket(packetSubscribe, 0x02, body)
+}
+
+// message is a received PUBLISH.
+type message struct {
+	topic   string
+	payload []byte
+	// id is the packet identifier of a QoS 1 or 2 message, which must be
+	// acknowledged; zero for QoS 0.
+	id  uint16
+	qos byte
+}
+
+// parsePublish decodes a PUBLISH packet.
+func parsePublish(p packet) (message, error) {
+	topic, rest, err := readString(p.body)
+	if err != nil {
+		return message{}, err
+	}
+	m := message{topic: topic, qos: (p.flags >> 1) & 0x03}
+	if m.qos > 0 {
+		if len(rest) < 2 {
+			return message{}, errors.New("truncated packet identifier")
+		}
+		m.id = binary.BigEndian.Uint16(rest)
+		rest = rest[2:]
+	}
+	m.payload = rest
+	return m, nil
+}
+
+// connackError returns the error for a CONNACK return code.
+func connackError(code byte) error {
+	switch code {
+	case 0:
+		return nil
+	case 1:
+		return errors.New("connection refused: unacceptable protocol version")
+	case 2:
+		return errors.New("connection refused: client identifier rejected")
+	case 3:
+		return errors.New("connection refused: server unavailable")
+	case 4:
+		return errors.New("connection refused: bad username or password")
+	case 5:
+		return errors.New("connection refused: not authorized")
+	default:
+		return fmt.Errorf("connection refused: code %d", code)
+	}
+}
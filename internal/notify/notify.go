@@ -0,0 +1,238 @@
+// Package notify delivers schedule events to push notification services
+// such as ntfy, Pushover and Gotify.
+package notify
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/hooks"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/tracing"
+)
+
+// defaultTimeout applies to notifications without a configured timeout.
+const defaultTimeout = 10 * time.Second
+
+// Notification metrics
+var deliveriesTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "immich_kiosk_scheduler_notification_deliveries_total",
+		Help: "Total number of push notifications by notification and result",
+	},
+	[]string{"notification", "result"},
+)
+
+func init() {
+	prometheus.MustRegister(deliveriesTotal)
+}
+
+// Provider priorities by config.NotifyPriority*.
+var (
+	ntfyPriorities     = map[string]int{config.NotifyPriorityLow: 2, config.NotifyPriorityDefault: 3, config.NotifyPriorityHigh: 4}
+	pushoverPriorities = map[string]int{config.NotifyPriorityLow: -1, config.NotifyPriorityDefault: 0, config.NotifyPriorityHigh: 1}
+	gotifyPriorities   = map[string]int{config.NotifyPriorityLow: 2, config.NotifyPriorityDefault: 5, config.NotifyPriorityHigh: 8}
+)
+
+// ntfyTags are shown as emoji next to ntfy notifications.
+var ntfyTags = map[string]string{
+	config.HookEventTransition:      "framed_picture",
+	config.HookEventDiscovery:       "mag",
+	config.HookEventDeviceStale:     "warning",
+	config.HookEventDeviceRecovered: "white_check_mark",
+}
+
+// Message is a notification as shown to the user.
+type Message struct {
+	Title    string
+	Body     string
+	Priority string
+}
+
+// Format returns the message of an event. albumName is the Immich name of
+// the current album, if known.
+func Format(event hooks.Event, albumName string) Message {
+	album := cmp.Or(albumName, event.Current.Album)
+	switch event.Event {
+	case config.HookEventDiscovery:
+		var lines []string
+		if len(event.Added) > 0 {
+			lines = append(lines, "Added: "+strings.Join(event.Added, ", "))
+		}
+		if len(event.Removed) > 0 {
+			lines = append(lines, "Removed: "+strings.Join(event.Removed, ", "))
+		}
+		return Message{
+			Title:    "Kiosk schedule entries discovered",
+			Body:     strings.Join(lines, "\n"),
+			Priority: config.NotifyPriorityLow,
+		}
+	case config.HookEventDeviceStale:
+		since := "the scheduler started"
+		if event.Device.LastSeen != nil {
+			since = event.Device.LastSeen.Format("Monday 15:04")
+		}
+		return Message{
+			Title:    fmt.Sprintf("Display %s stopped polling", event.Device.Name),
+			Body:     fmt.Sprintf("%s has not asked for a slideshow since %s.", event.Device.Name, since),
+			Priority: config.NotifyPriorityHigh,
+		}
+	case config.HookEventDeviceRecovered:
+		return Message{
+			Title:    fmt.Sprintf("Display %s is back", event.Device.Name),
+			Body:     fmt.Sprintf("%s is polling again and shows %s.", event.Device.Name, event.Current.Schedule),
+			Priority: config.NotifyPriorityDefault,
+		}
+	default:
+		return Message{
+			Title:    "Kiosk now showing " + event.Current.Schedule,
+			Body:     fmt.Sprintf("Switched from %s to %s.\nAlbum: %s", event.Previous.Schedule, event.Current.Schedule, album),
+			Priority: config.NotifyPriorityDefault,
+		}
+	}
+}
+
+// Dispatcher delivers events to notification services asynchronously.
+type Dispatcher struct {
+	client *http.Client
+	logger *slog.Logger
+	wg     sync.WaitGroup
+}
+
+// NewDispatcher creates a Dispatcher.
+func NewDispatcher(logger *slog.Logger) *Dispatcher {
+	return &Dispatcher{
+		client: &http.Client{Transport: tracing.NewTransport(nil)},
+		logger: logger,
+	}
+}
+
+// Send delivers the event to every notification subscribed to it without
+// blocking. Failures are logged and counted; deliveries are not retried.
+func (d *Dispatcher) Send(targets []config.NotificationConfig, event hooks.Event, albumName string) {
+	msg := Format(event, albumName)
+	for _, target := range targets {
+		if !target.Wants(event.Event, event.Current.Schedule) {
+			continue
+		}
+		msg := msg
+		msg.Priority = cmp.Or(target.Priority, msg.Priority)
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			if err := d.deliver(target, event.Event, msg); err != nil {
+				deliveriesTotal.WithLabelValues(target.Name, "failure").Inc()
+				d.logger.Warn("notification delivery failed",
+					slog.String("notification", target.Name),
+					slog.String("provider", target.Provider),
+					slog.String("event", event.Event),
+					slog.Any("error", err),
+				)
+				return
+			}
+			deliveriesTotal.WithLabelValues(target.Name, "success").Inc()
+			d.logger.Debug("notification delivered", slog.String("notification", target.Name), slog.String("event", event.Event))
+		}()
+	}
+}
+
+// Wait blocks until all in-flight deliveries have finished.
+func (d *Dispatcher) Wait() {
+	d.wg.Wait()
+}
+
+func (d *Dispatcher) deliver(target config.NotificationConfig, event string, msg Message) error {
+	timeout := target.Timeout
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := newRequest(ctx, target, event, msg)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "immich-kiosk-scheduler")
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		// The providers explain rejected requests in the body.
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("unexpected status %s: %s", resp.Status, bytes.TrimSpace(detail))
+	}
+	return nil
+}
+
+// newRequest builds the provider's API request for a message.
+func newRequest(ctx context.Context, target config.NotificationConfig, event string, msg Message) (*http.Request, error) {
+	server := target.ServerURL()
+	switch target.Provider {
+	case config.NotifyNtfy:
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, server+"/"+url.PathEscape(target.Topic), strings.NewReader(msg.Body))
+		if err != nil {
+			return nil, err
+		}
+		// ntfy accepts UTF-8 headers RFC 2047 encoded.
+		req.Header.Set("Title", mime.QEncoding.Encode("utf-8", msg.Title))
+		req.Header.Set("Priority", strconv.Itoa(ntfyPriorities[msg.Priority]))
+		if tag := ntfyTags[event]; tag != "" {
+			req.Header.Set("Tags", tag)
+		}
+		if target.Token != "" {
+			req.Header.Set("Authorization", "Bearer "+target.Token)
+		}
+		return req, nil
+	case config.NotifyPushover:
+		form := url.Values{
+			"token":    {target.Token},
+			"user":     {target.User},
+			"title":    {msg.Title},
+			"message":  {msg.Body},
+			"priority": {strconv.Itoa(pushoverPriorities[msg.Priority])},
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, server+"/1/messages.json", strings.NewReader(form.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req, nil
+	case config.NotifyGotify:
+		body, err := json.Marshal(map[string]any{
+			"title":    msg.Title,
+			"message":  msg.Body,
+			"priority": gotifyPriorities[msg.Priority],
+		})
+		if err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, server+"/message", bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Gotify-Key", target.Token)
+		return req, nil
+	default:
+		return nil, fmt.Errorf("unknown provider %q", target.Provider)
+	}
+}
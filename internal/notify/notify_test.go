@@ -0,0 +1,129 @@
+package notify
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/hooks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// request is what a fake provider received.
+type request struct {
+	path   string
+	header http.Header
+	body   string
+}
+
+func serveProvider(t *testing.T) (string, func() map[string]request) {
+	t.Helper()
+	var (
+		mu       sync.Mutex
+		received = map[string]request{}
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		mu.Lock()
+		received[r.URL.Path] = request{path: r.URL.Path, header: r.Header.Clone(), body: string(body)}
+		mu.Unlock()
+	}))
+	t.Cleanup(ts.Close)
+	return ts.URL, func() map[string]request {
+		mu.Lock()
+		defer mu.Unlock()
+		return received
+	}
+}
+
+func TestDispatcher_Send(t *testing.T) {
+	server, received := serveProvider(t)
+
+	d := NewDispatcher(slog.Default())
+	d.Send([]config.NotificationConfig{
+		{Name: "ntfy", Provider: config.NotifyNtfy, URL: server, Topic: "kiosk", Token: "tk_secret"},
+		{Name: "pushover", Provider: config.NotifyPushover, URL: server, Token: "app", User: "family", Priority: config.NotifyPriorityHigh},
+		{Name: "gotify", Provider: config.NotifyGotify, URL: server + "/", Token: "gotify-app"},
+		{Name: "alerts-only", Provider: config.NotifyNtfy, URL: server, Topic: "alerts", Events: []string{config.HookEventDeviceStale}},
+		{Name: "fall-only", Provider: config.NotifyNtfy, URL: server, Topic: "fall", Schedules: []string{"fall"}},
+	}, hooks.Event{
+		Event:    config.HookEventTransition,
+		Time:     time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC),
+		Previous: hooks.Selection{Schedule: "fall", Album: "fall-album"},
+		Current:  hooks.Selection{Schedule: "christmas", Album: "christmas-album"},
+	}, "Christmas 🎄")
+	d.Wait()
+
+	got := received()
+	require.Len(t, got, 3)
+
+	ntfy := got["/kiosk"]
+	assert.Equal(t, "Kiosk now showing christmas", ntfy.header.Get("Title"))
+	assert.Equal(t, "3", ntfy.header.Get("Priority"))
+	assert.Equal(t, "framed_picture", ntfy.header.Get("Tags"))
+	assert.Equal(t, "Bearer tk_secret", ntfy.header.Get("Authorization"))
+	assert.Equal(t, "Switched from fall to christmas.\nAlbum: Christmas 🎄", ntfy.body)
+
+	form, err := url.ParseQuery(got["/1/messages.json"].body)
+	require.NoError(t, err)
+	assert.Equal(t, "app", form.Get("token"))
+	assert.Equal(t, "family", form.Get("user"))
+	assert.Equal(t, "Kiosk now showing christmas", form.Get("title"))
+	assert.Equal(t, "1", form.Get("priority"), "configured priority wins")
+
+	gotify := got["/message"]
+	assert.Equal(t, "gotify-app", gotify.header.Get("X-Gotify-Key"))
+	var msg struct {
+		Title    string `json:"title"`
+		Priority int    `json:"priority"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(gotify.body), &msg))
+	assert.Equal(t, "Kiosk now showing christmas", msg.Title)
+	assert.Equal(t, 5, msg.Priority)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(deliveriesTotal.WithLabelValues("ntfy", "success")))
+}
+
+func TestDispatcher_SendFailure(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"errors":["application token is invalid"]}`, http.StatusBadRequest)
+	}))
+	defer ts.Close()
+
+	d := NewDispatcher(slog.Default())
+	d.Send([]config.NotificationConfig{{Name: "broken", Provider: config.NotifyPushover, URL: ts.URL, Token: "x", User: "y"}},
+		hooks.Event{Event: config.HookEventTransition}, "")
+	d.Wait()
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(deliveriesTotal.WithLabelValues("broken", "failure")))
+}
+
+func TestFormat(t *testing.T) {
+	seen := time.Date(2025, 12, 1, 7, 30, 0, 0, time.UTC)
+	stale := Format(hooks.Event{
+		Event:  config.HookEventDeviceStale,
+		Device: &hooks.Device{ID: "kitchen", Name: "Kitchen", LastSeen: &seen},
+	}, "")
+	assert.Equal(t, Message{
+		Title:    "Display Kitchen stopped polling",
+		Body:     "Kitchen has not asked for a slideshow since Monday 07:30.",
+		Priority: config.NotifyPriorityHigh,
+	}, stale)
+
+	discovery := Format(hooks.Event{Event: config.HookEventDiscovery, Added: []string{"Trip", "Birthday"}}, "")
+	assert.Equal(t, "Added: Trip, Birthday", discovery.Body)
+	assert.Equal(t, config.NotifyPriorityLow, discovery.Priority)
+
+	transition := Format(hooks.Event{Event: config.HookEventTransition, Current: hooks.Selection{Schedule: "fall", Album: "fall-album"}}, "")
+	assert.Contains(t, transition.Body, "Album: fall-album", "falls back to the album reference")
+}
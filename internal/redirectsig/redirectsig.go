@@ -0,0 +1,95 @@
+// Package redirectsig signs redirect URLs so that a proxy in front of the
+// kiosk can verify they were generated by the scheduler.
+//
+// A signed URL ends with a timestamp and a signature parameter, e.g.
+// ...&ts=1735120800&sig=<hex>. The signature is the hex-encoded HMAC-SHA256
+// of the URL's path ("/" when empty), a "?" and the query string up to, not
+// including, "&sig=".
+package redirectsig
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Errors returned by Verify.
+var (
+	ErrMalformed = errors.New("malformed signed URL")
+	ErrSignature = errors.New("invalid URL signature")
+	ErrExpired   = errors.New("signed URL has expired")
+)
+
+// Sign appends the timestamp parameter tsParam with now and the signature
+// parameter sigParam to rawURL.
+func Sign(secret, rawURL, tsParam, sigParam string, now time.Time) (string, error) {
+	u, fragment, hasFragment := strings.Cut(rawURL, "#")
+	sep := "?"
+	if strings.Contains(u, "?") {
+		sep = "&"
+	}
+	u += sep + url.QueryEscape(tsParam) + "=" + strconv.FormatInt(now.Unix(), 10)
+
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return "", err
+	}
+	u += "&" + url.QueryEscape(sigParam) + "=" + hex.EncodeToString(mac(secret, message(parsed.EscapedPath(), parsed.RawQuery)))
+	if hasFragment {
+		u += "#" + fragment
+	}
+	return u, nil
+}
+
+// Verify checks the signature of a URL signed by Sign, e.g. the request URI
+// seen by a proxy, and that it was signed no more than maxAge before now.
+func Verify(secret, rawURL, tsParam, sigParam string, now time.Time, maxAge time.Duration) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return ErrMalformed
+	}
+	i := strings.LastIndex(parsed.RawQuery, "&"+url.QueryEscape(sigParam)+"=")
+	if i < 0 {
+		return ErrMalformed
+	}
+	signed, sig := parsed.RawQuery[:i], parsed.RawQuery[i+len(sigParam)+2:]
+	got, err := hex.DecodeString(sig)
+	if err != nil {
+		return ErrMalformed
+	}
+	if !hmac.Equal(got, mac(secret, message(parsed.EscapedPath(), signed))) {
+		return ErrSignature
+	}
+
+	query, err := url.ParseQuery(signed)
+	if err != nil {
+		return ErrMalformed
+	}
+	ts, err := strconv.ParseInt(query.Get(tsParam), 10, 64)
+	if err != nil {
+		return ErrMalformed
+	}
+	if now.Sub(time.Unix(ts, 0)) > maxAge {
+		return ErrExpired
+	}
+	return nil
+}
+
+// message returns the signed part of a URL.
+func message(path, query string) string {
+	if path == "" {
+		path = "/"
+	}
+	return path + "?" + query
+}
+
+func mac(secret, message string) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(message))
+	return h.Sum(nil)
+}
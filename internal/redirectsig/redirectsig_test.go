@@ -0,0 +1,72 @@
+package redirectsig
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func TestSignVerify(t *testing.T) {
+	now := time.Unix(1735120800, 0)
+
+	signed, err := Sign(testSecret, "https://kiosk.example.com?album=christmas&transition=fade", "ts", "sig", now)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(signed, "https://kiosk.example.com?album=christmas&transition=fade&ts=1735120800&sig="))
+
+	// The proxy sees the request URI, with the path the browser requests.
+	requestURI := strings.TrimPrefix(signed, "https://kiosk.example.com")
+	require.NoError(t, Verify(testSecret, "/"+requestURI, "ts", "sig", now.Add(time.Minute), 5*time.Minute))
+	require.NoError(t, Verify(testSecret, signed, "ts", "sig", now, 5*time.Minute))
+
+	// The signature is documented as the HMAC of path and query up to &sig=.
+	h := hmac.New(sha256.New, []byte(testSecret))
+	h.Write([]byte("/?album=christmas&transition=fade&ts=1735120800"))
+	assert.True(t, strings.HasSuffix(signed, "&sig="+hex.EncodeToString(h.Sum(nil))))
+}
+
+func TestSign_PathAndFragment(t *testing.T) {
+	now := time.Unix(1735120800, 0)
+	signed, err := Sign(testSecret, "https://kiosk.example.com/album/christmas#slide", "ts", "sig", now)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(signed, "https://kiosk.example.com/album/christmas?ts=1735120800&sig="))
+	assert.True(t, strings.HasSuffix(signed, "#slide"))
+
+	tampered := strings.Replace(signed, "/album/christmas", "/album/other", 1)
+	assert.ErrorIs(t, Verify(testSecret, tampered, "ts", "sig", now, time.Minute), ErrSignature)
+}
+
+func TestVerify_Errors(t *testing.T) {
+	now := time.Unix(1735120800, 0)
+	signed, err := Sign(testSecret, "https://kiosk.example.com/?album=christmas", "ts", "sig", now)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		url  string
+		now  time.Time
+		want error
+	}{
+		{name: "tampered album", url: strings.Replace(signed, "christmas", "other", 1), now: now, want: ErrSignature},
+		{name: "wrong secret", url: signed, now: now, want: ErrSignature},
+		{name: "expired", url: signed, now: now.Add(10 * time.Minute), want: ErrExpired},
+		{name: "unsigned", url: "https://kiosk.example.com/?album=christmas", now: now, want: ErrMalformed},
+		{name: "bad signature encoding", url: "https://kiosk.example.com/?album=christmas&sig=zz", now: now, want: ErrMalformed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret := testSecret
+			if tt.name == "wrong secret" {
+				secret = strings.Repeat("x", 32)
+			}
+			assert.ErrorIs(t, Verify(secret, tt.url, "ts", "sig", tt.now, 5*time.Minute), tt.want)
+		})
+	}
+}
@@ -0,0 +1,162 @@
+// Package remote loads the configuration from a key in Consul or etcd and
+// applies changes as they are written.
+package remote
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/tracing"
+)
+
+// Retry delays after a failed read or watch.
+const (
+	minRetryDelay = time.Second
+	maxRetryDelay = time.Minute
+)
+
+// Remote config metrics
+var updatesTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "immich_kiosk_scheduler_remote_config_updates_total",
+		Help: "Total number of remote configuration updates by result",
+	},
+	[]string{"result"},
+)
+
+func init() {
+	prometheus.MustRegister(updatesTotal)
+}
+
+// Status describes the state of the remote configuration.
+type Status struct {
+	Provider    string     `json:"provider"`
+	Key         string     `json:"key"`
+	Version     uint64     `json:"version,omitempty"` // version of the applied value
+	LastUpdate  *time.Time `json:"last_update,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+}
+
+// Watcher follows a key and applies each new value.
+type Watcher struct {
+	store  Store
+	key    string
+	logger *slog.Logger
+
+	mu     sync.Mutex
+	status Status
+	value  []byte
+}
+
+// NewWatcher creates a Watcher for the configured key.
+func NewWatcher(cfg config.RemoteConfig, logger *slog.Logger) (*Watcher, error) {
+	store, err := NewStore(cfg, &http.Client{Transport: tracing.NewTransport(nil)})
+	if err != nil {
+		return nil, err
+	}
+	return &Watcher{
+		store:  store,
+		key:    cfg.Key,
+		logger: logger,
+		status: Status{Provider: cfg.Provider, Key: cfg.Key},
+	}, nil
+}
+
+// Value returns the last successfully applied value, or nil.
+func (w *Watcher) Value() []byte {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.value
+}
+
+// Status returns the current status.
+func (w *Watcher) Status() Status {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.status
+}
+
+// Run reads the key, applies it, and then applies every change until the
+// context is cancelled. A value rejected by apply is reported and the
+// previous configuration keeps serving until the key changes again.
+func (w *Watcher) Run(ctx context.Context, apply func(value []byte) error) {
+	var (
+		version uint64
+		read    bool
+		delay   = minRetryDelay
+	)
+
+	for ctx.Err() == nil {
+		var (
+			value []byte
+			next  uint64
+			err   error
+		)
+		if !read {
+			value, next, err = w.store.Get(ctx)
+		} else {
+			value, next, err = w.store.Watch(ctx, version)
+		}
+
+		switch {
+		case ctx.Err() != nil:
+			return
+		case errors.Is(err, ErrNotFound):
+			read, version = true, next
+			w.fail(fmt.Errorf("%w; keeping current configuration", err))
+			continue
+		case err != nil:
+			w.fail(err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+			delay = min(delay*2, maxRetryDelay)
+			continue
+		}
+
+		read, version, delay = true, next, minRetryDelay
+		if bytes.Equal(value, w.Value()) {
+			continue
+		}
+
+		w.logger.Info("applying remote configuration", slog.String("key", w.key), slog.Uint64("version", next))
+		if err := apply(value); err != nil {
+			w.fail(fmt.Errorf("version %d: %w", next, err))
+			continue
+		}
+
+		w.mu.Lock()
+		now := time.Now()
+		w.value = value
+		w.status.Version = next
+		w.status.LastUpdate = &now
+		w.status.LastError = ""
+		w.status.LastErrorAt = nil
+		w.mu.Unlock()
+		updatesTotal.WithLabelValues("success").Inc()
+	}
+}
+
+// fail records and logs an error.
+func (w *Watcher) fail(err error) {
+	updatesTotal.WithLabelValues("failure").Inc()
+	w.logger.Error("remote configuration error", slog.String("key", w.key), slog.Any("error", err))
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	now := time.Now()
+	w.status.LastError = err.Error()
+	w.status.LastErrorAt = &now
+}
@@ -0,0 +1,175 @@
+package remote
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsulStore(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/kv/kiosk/config", r.URL.Path)
+		assert.Equal(t, "secret", r.Header.Get("X-Consul-Token"))
+
+		// A blocking query from index 10 sees the next write at index 11
+		if r.URL.Query().Get("index") == "10" {
+			assert.Equal(t, "5m0s", r.URL.Query().Get("wait"))
+			w.Header().Set("X-Consul-Index", "11")
+			fmt.Fprint(w, "default_album: second\n")
+			return
+		}
+		w.Header().Set("X-Consul-Index", "10")
+		fmt.Fprint(w, "default_album: first\n")
+	}))
+	defer ts.Close()
+
+	store, err := NewStore(config.RemoteConfig{Provider: "consul", Endpoint: ts.URL, Key: "/kiosk/config", Token: "secret"}, ts.Client())
+	require.NoError(t, err)
+
+	value, version, err := store.Get(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "default_album: first\n", string(value))
+	assert.Equal(t, uint64(10), version)
+
+	value, version, err = store.Watch(context.Background(), version)
+	require.NoError(t, err)
+	assert.Equal(t, "default_album: second\n", string(value))
+	assert.Equal(t, uint64(11), version)
+}
+
+func TestConsulStore_NotFound(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Consul-Index", "7")
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	store, err := NewStore(config.RemoteConfig{Provider: "consul", Endpoint: ts.URL, Key: "missing"}, ts.Client())
+	require.NoError(t, err)
+
+	_, version, err := store.Get(context.Background())
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, uint64(7), version)
+}
+
+func TestEtcdStore(t *testing.T) {
+	encode := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+		switch r.URL.Path {
+		case "/v3/auth/authenticate":
+			assert.Equal(t, "kiosk", body["name"])
+			fmt.Fprint(w, `{"token":"tok"}`)
+		case "/v3/kv/range":
+			assert.Equal(t, "tok", r.Header.Get("Authorization"))
+			assert.Equal(t, encode("/kiosk/config"), body["key"])
+			fmt.Fprintf(w, `{"header":{"revision":"42"},"kvs":[{"value":%q,"mod_revision":"40"}]}`, encode("default_album: first\n"))
+		case "/v3/watch":
+			create := body["create_request"].(map[string]any)
+			assert.Equal(t, "41", create["start_revision"])
+			fmt.Fprint(w, `{"result":{"header":{"revision":"42"},"created":true}}`+"\n")
+			fmt.Fprintf(w, `{"result":{"header":{"revision":"43"},"events":[{"kv":{"value":%q,"mod_revision":"43"}}]}}`+"\n", encode("default_album: second\n"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	store, err := NewStore(config.RemoteConfig{
+		Provider: "etcd", Endpoint: ts.URL, Key: "/kiosk/config", Username: "kiosk", Password: "pw",
+	}, ts.Client())
+	require.NoError(t, err)
+
+	value, version, err := store.Get(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "default_album: first\n", string(value))
+	assert.Equal(t, uint64(40), version)
+
+	value, version, err = store.Watch(context.Background(), version)
+	require.NoError(t, err)
+	assert.Equal(t, "default_album: second\n", string(value))
+	assert.Equal(t, uint64(43), version)
+}
+
+// fakeStore serves a scripted sequence of reads.
+type fakeStore struct {
+	mu      sync.Mutex
+	results []fakeResult
+}
+
+type fakeResult struct {
+	value   string
+	version uint64
+	err     error
+}
+
+func (f *fakeStore) next(ctx context.Context) ([]byte, uint64, error) {
+	f.mu.Lock()
+	if len(f.results) == 0 {
+		f.mu.Unlock()
+		<-ctx.Done()
+		return nil, 0, ctx.Err()
+	}
+	r := f.results[0]
+	f.results = f.results[1:]
+	f.mu.Unlock()
+	return []byte(r.value), r.version, r.err
+}
+
+func (f *fakeStore) Get(ctx context.Context) ([]byte, uint64, error) { return f.next(ctx) }
+
+func (f *fakeStore) Watch(ctx context.Context, _ uint64) ([]byte, uint64, error) { return f.next(ctx) }
+
+func TestWatcher_Run(t *testing.T) {
+	store := &fakeStore{results: []fakeResult{
+		{value: "good-1", version: 1},
+		{value: "bad", version: 2},
+		{version: 3, err: ErrNotFound},
+		{value: "good-2", version: 4},
+	}}
+	w := &Watcher{store: store, key: "kiosk", logger: slog.Default()}
+
+	var (
+		mu      sync.Mutex
+		applied []string
+	)
+	done := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		defer close(done)
+		w.Run(ctx, func(value []byte) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if string(value) == "bad" {
+				return errors.New("invalid configuration")
+			}
+			applied = append(applied, string(value))
+			return nil
+		})
+	}()
+
+	require.Eventually(t, func() bool { return w.Status().Version == 4 }, time.Second, 10*time.Millisecond)
+	cancel()
+	<-done
+
+	assert.Equal(t, []string{"good-1", "good-2"}, applied)
+	assert.Equal(t, "good-2", string(w.Value()))
+	assert.Empty(t, w.Status().LastError)
+}
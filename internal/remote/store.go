@@ -0,0 +1,277 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+)
+
+// ErrNotFound is returned when the key does not exist.
+var ErrNotFound = errors.New("key not found")
+
+// consulWait is how long a Consul blocking query waits for a change.
+const consulWait = 5 * time.Minute
+
+// requestTimeout bounds non-blocking requests.
+const requestTimeout = 30 * time.Second
+
+// Store reads a single key from a key-value store.
+type Store interface {
+	// Get returns the value of the key and its version.
+	Get(ctx context.Context) (value []byte, version uint64, err error)
+	// Watch blocks until the key changes after version and returns the new
+	// value and version. On ErrNotFound the returned version is still valid.
+	Watch(ctx context.Context, version uint64) (value []byte, newVersion uint64, err error)
+}
+
+// NewStore creates the Store for the configured provider.
+func NewStore(cfg config.RemoteConfig, client *http.Client) (Store, error) {
+	endpoint := strings.TrimRight(cfg.Endpoint, "/")
+	switch cfg.Provider {
+	case "consul":
+		return &consulStore{client: client, endpoint: endpoint, key: strings.TrimLeft(cfg.Key, "/"), token: cfg.Token}, nil
+	case "etcd":
+		return &etcdStore{client: client, endpoint: endpoint, key: cfg.Key, username: cfg.Username, password: cfg.Password}, nil
+	default:
+		return nil, fmt.Errorf("unknown remote provider %q", cfg.Provider)
+	}
+}
+
+// consulStore reads a key through the Consul KV HTTP API, watching it with
+// blocking queries.
+type consulStore struct {
+	client   *http.Client
+	endpoint string
+	key      string
+	token    string
+}
+
+func (c *consulStore) Get(ctx context.Context) ([]byte, uint64, error) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	return c.query(ctx, 0)
+}
+
+func (c *consulStore) Watch(ctx context.Context, version uint64) ([]byte, uint64, error) {
+	for {
+		reqCtx, cancel := context.WithTimeout(ctx, consulWait+requestTimeout)
+		value, index, err := c.query(reqCtx, version)
+		cancel()
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return nil, version, err
+		}
+		// The index is unchanged when the wait times out. It can also go
+		// backwards (e.g. after a snapshot restore), which counts as a change.
+		if index != version {
+			return value, index, err
+		}
+	}
+}
+
+// query performs a (blocking, when index > 0) read of the key.
+func (c *consulStore) query(ctx context.Context, index uint64) ([]byte, uint64, error) {
+	q := url.Values{"raw": {""}}
+	if index > 0 {
+		q.Set("index", strconv.FormatUint(index, 10))
+		q.Set("wait", consulWait.String())
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpoint+"/v1/kv/"+c.key+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, index, err
+	}
+	if c.token != "" {
+		req.Header.Set("X-Consul-Token", c.token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, index, err
+	}
+	defer resp.Body.Close()
+
+	newIndex, _ := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	switch resp.StatusCode {
+	case http.StatusOK:
+		body, err := io.ReadAll(resp.Body)
+		return body, newIndex, err
+	case http.StatusNotFound:
+		return nil, newIndex, ErrNotFound
+	default:
+		return nil, index, fmt.Errorf("consul: unexpected status %s", resp.Status)
+	}
+}
+
+// etcdStore reads a key through the etcd v3 JSON gateway, watching it with
+// the streaming watch API.
+type etcdStore struct {
+	client   *http.Client
+	endpoint string
+	key      string
+	username string
+	password string
+}
+
+// etcdKV is a key-value pair in etcd gateway responses. Byte fields are
+// base64-encoded and int64 fields are encoded as strings.
+type etcdKV struct {
+	Value       string `json:"value"`
+	ModRevision int64  `json:"mod_revision,string"`
+}
+
+type etcdHeader struct {
+	Revision int64 `json:"revision,string"`
+}
+
+type etcdRangeResponse struct {
+	Header etcdHeader `json:"header"`
+	KVs    []etcdKV   `json:"kvs"`
+}
+
+type etcdWatchResponse struct {
+	Result struct {
+		Header   etcdHeader `json:"header"`
+		Canceled bool       `json:"canceled"`
+		Events   []struct {
+			Type string `json:"type"` // PUT is the default and omitted
+			KV   etcdKV `json:"kv"`
+		} `json:"events"`
+	} `json:"result"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (e *etcdStore) Get(ctx context.Context) ([]byte, uint64, error) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	resp, err := e.post(ctx, "/v3/kv/range", map[string]any{"key": e.encodedKey()})
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	var out etcdRangeResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, 0, fmt.Errorf("etcd: failed to decode range response: %w", err)
+	}
+	if len(out.KVs) == 0 {
+		return nil, uint64(out.Header.Revision), ErrNotFound
+	}
+	value, err := base64.StdEncoding.DecodeString(out.KVs[0].Value)
+	return value, uint64(out.KVs[0].ModRevision), err
+}
+
+func (e *etcdStore) Watch(ctx context.Context, version uint64) ([]byte, uint64, error) {
+	resp, err := e.post(ctx, "/v3/watch", map[string]any{
+		"create_request": map[string]any{
+			"key":            e.encodedKey(),
+			"start_revision": strconv.FormatUint(version+1, 10),
+		},
+	})
+	if err != nil {
+		return nil, version, err
+	}
+	defer resp.Body.Close()
+
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var msg etcdWatchResponse
+		if err := dec.Decode(&msg); err != nil {
+			return nil, version, fmt.Errorf("etcd: watch stream: %w", err)
+		}
+		if msg.Error != nil {
+			return nil, version, fmt.Errorf("etcd: watch: %s", msg.Error.Message)
+		}
+		if msg.Result.Canceled {
+			return nil, version, errors.New("etcd: watch canceled")
+		}
+		if len(msg.Result.Events) == 0 {
+			continue
+		}
+
+		ev := msg.Result.Events[len(msg.Result.Events)-1]
+		if ev.Type == "DELETE" {
+			return nil, uint64(ev.KV.ModRevision), ErrNotFound
+		}
+		value, err := base64.StdEncoding.DecodeString(ev.KV.Value)
+		return value, uint64(ev.KV.ModRevision), err
+	}
+}
+
+func (e *etcdStore) encodedKey() string {
+	return base64.StdEncoding.EncodeToString([]byte(e.key))
+}
+
+// post sends a JSON request to the gateway, authenticating first when
+// credentials are configured.
+func (e *etcdStore) post(ctx context.Context, path string, body any) (*http.Response, error) {
+	var token string
+	if e.username != "" {
+		var err error
+		if token, err = e.authenticate(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint+path, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("etcd: %s: unexpected status %s", path, resp.Status)
+	}
+	return resp, nil
+}
+
+// authenticate exchanges the username and password for a short-lived token.
+func (e *etcdStore) authenticate(ctx context.Context) (string, error) {
+	data, _ := json.Marshal(map[string]string{"name": e.username, "password": e.password})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint+"/v3/auth/authenticate", bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("etcd: authentication failed: %s", resp.Status)
+	}
+
+	var out struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", fmt.Errorf("etcd: failed to decode authentication response: %w", err)
+	}
+	return out.Token, nil
+}
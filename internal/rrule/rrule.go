@@ -0,0 +1,455 @@
+// Package rrule parses and evaluates the RFC 5545 recurrence rules schedule
+// entries can use instead of, or within, a date range, such as
+//
+//	FREQ=YEARLY;BYMONTH=7;BYDAY=2MO                 the second Monday of July
+//	FREQ=MONTHLY;BYDAY=FR;BYMONTHDAY=13             every Friday the 13th
+//	FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1   the last weekday of each month
+//	FREQ=YEARLY;BYMONTH=11;BYDAY=4TH                Thanksgiving (US)
+//
+// A DTSTART line before the rule sets the day INTERVAL counts periods from,
+// and the day rules without BYDAY, BYMONTHDAY or BYYEARDAY repeat:
+//
+//	DTSTART:20260103
+//	RRULE:FREQ=WEEKLY;INTERVAL=2                    every other Saturday
+//
+// Rules select whole days; time-of-day parts (BYHOUR, ...) are left to when
+// conditions, and times in DTSTART and UNTIL are ignored.
+package rrule
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// frequency is the period a rule repeats in.
+type frequency int
+
+// Supported frequencies.
+const (
+	daily frequency = iota
+	weekly
+	monthly
+	yearly
+)
+
+var freqNames = map[string]frequency{"DAILY": daily, "WEEKLY": weekly, "MONTHLY": monthly, "YEARLY": yearly}
+
+var weekdayNames = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// weekdayNum is a BYDAY value: a weekday, optionally the nth (or, when
+// negative, nth last) one of the month or year.
+type weekdayNum struct {
+	n   int
+	day time.Weekday
+}
+
+// Rule is a parsed recurrence rule.
+type Rule struct {
+	src  string
+	freq frequency
+	// start is the DTSTART day, the first day occurrences may fall on and
+	// the one periods are counted from; zero when not given.
+	start    time.Time
+	interval int
+	// until is the last day occurrences may fall on; zero when unbounded.
+	until      time.Time
+	byMonth    []int
+	byMonthDay []int
+	byYearDay  []int
+	byDay      []weekdayNum
+	bySetPos   []int
+	wkst       time.Weekday
+}
+
+// Parse parses a recurrence rule such as "FREQ=YEARLY;BYMONTH=7;BYDAY=2MO".
+// An "RRULE:" prefix is accepted, as is a DTSTART line before the rule in
+// iCalendar form, such as "DTSTART:20260103" or
+// "DTSTART;VALUE=DATE:20260103".
+func Parse(src string) (*Rule, error) {
+	r := &Rule{src: src, freq: -1, interval: 1, wkst: time.Monday}
+	rule := ""
+	for line := range strings.Lines(src) {
+		line = strings.TrimSpace(line)
+		name, value, _ := strings.Cut(line, ":")
+		switch {
+		case line == "":
+		case strings.HasPrefix(strings.ToUpper(name), "DTSTART"):
+			if !r.start.IsZero() {
+				return nil, fmt.Errorf("DTSTART is given more than once")
+			}
+			start, err := parseDate("DTSTART", value)
+			if err != nil {
+				return nil, err
+			}
+			r.start = start
+		case rule != "":
+			return nil, fmt.Errorf("unexpected line %q, expected one rule", line)
+		default:
+			rule = line
+		}
+	}
+	if err := r.parseRule(strings.TrimPrefix(rule, "RRULE:")); err != nil {
+		return nil, err
+	}
+	if err := r.check(); err != nil {
+		return nil, err
+	}
+	if !r.start.IsZero() {
+		r.repeatStart()
+	}
+	return r, nil
+}
+
+// parseRule parses the NAME=VALUE parts of a rule.
+func (r *Rule) parseRule(rule string) error {
+	seen := make(map[string]bool)
+	for part := range strings.SplitSeq(rule, ";") {
+		name, value, ok := strings.Cut(part, "=")
+		name = strings.ToUpper(strings.TrimSpace(name))
+		if !ok || name == "" || value == "" {
+			return fmt.Errorf("invalid rule part %q, expected NAME=VALUE", part)
+		}
+		if seen[name] {
+			return fmt.Errorf("%s is given more than once", name)
+		}
+		seen[name] = true
+		value = strings.ToUpper(strings.TrimSpace(value))
+
+		var err error
+		switch name {
+		case "FREQ":
+			f, ok := freqNames[value]
+			if !ok {
+				return fmt.Errorf("FREQ=%s is not supported, use DAILY, WEEKLY, MONTHLY or YEARLY", value)
+			}
+			r.freq = f
+		case "INTERVAL":
+			r.interval, err = strconv.Atoi(value)
+			if err != nil || r.interval < 1 {
+				return fmt.Errorf("invalid INTERVAL %q", value)
+			}
+		case "UNTIL":
+			r.until, err = parseDate("UNTIL", value)
+		case "BYMONTH":
+			r.byMonth, err = parseInts(name, value, 1, 12, false)
+		case "BYMONTHDAY":
+			r.byMonthDay, err = parseInts(name, value, 1, 31, true)
+		case "BYYEARDAY":
+			r.byYearDay, err = parseInts(name, value, 1, 366, true)
+		case "BYSETPOS":
+			r.bySetPos, err = parseInts(name, value, 1, 366, true)
+		case "BYDAY":
+			r.byDay, err = parseByDay(value)
+		case "WKST":
+			day, ok := weekdayNames[value]
+			if !ok {
+				return fmt.Errorf("invalid WKST %q", value)
+			}
+			r.wkst = day
+		case "COUNT":
+			return fmt.Errorf("COUNT is not supported, use UNTIL")
+		case "BYHOUR", "BYMINUTE", "BYSECOND":
+			return fmt.Errorf("%s is not supported, rules select whole days; use a when condition for the time of day", name)
+		case "BYWEEKNO":
+			return fmt.Errorf("BYWEEKNO is not supported")
+		default:
+			return fmt.Errorf("unknown rule part %q", name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// check enforces the RFC 5545 restrictions on combining parts with FREQ,
+// and that the rule selects days when there is no DTSTART to repeat.
+func (r *Rule) check() error {
+	switch r.freq {
+	case -1:
+		return fmt.Errorf("FREQ is required")
+	case daily, weekly:
+		if slices.ContainsFunc(r.byDay, func(d weekdayNum) bool { return d.n != 0 }) {
+			return fmt.Errorf("BYDAY cannot number weekdays with FREQ=%s", r.freqName())
+		}
+	case monthly:
+		if slices.ContainsFunc(r.byDay, func(d weekdayNum) bool { return d.n < -5 || d.n > 5 }) {
+			return fmt.Errorf("BYDAY numbers must be between -5 and 5 with FREQ=MONTHLY")
+		}
+	}
+	if len(r.byYearDay) > 0 && r.freq != yearly {
+		return fmt.Errorf("BYYEARDAY requires FREQ=YEARLY")
+	}
+	if len(r.byMonthDay) > 0 && r.freq == weekly {
+		return fmt.Errorf("BYMONTHDAY cannot be used with FREQ=WEEKLY")
+	}
+	if r.start.IsZero() {
+		if r.interval > 1 {
+			return fmt.Errorf("INTERVAL needs a DTSTART line to count periods from")
+		}
+		if r.freq != daily && len(r.byDay) == 0 && len(r.byMonthDay) == 0 && len(r.byYearDay) == 0 {
+			return fmt.Errorf("FREQ=%s needs BYDAY, BYMONTHDAY or BYYEARDAY to select days, or a DTSTART line to repeat", r.freqName())
+		}
+	}
+	return nil
+}
+
+// repeatStart fills in the parts that, as RFC 5545 specifies, are taken
+// from DTSTART when the rule does not select days itself.
+func (r *Rule) repeatStart() {
+	if len(r.byDay) > 0 || len(r.byMonthDay) > 0 || len(r.byYearDay) > 0 {
+		return
+	}
+	switch r.freq {
+	case yearly:
+		if len(r.byMonth) == 0 {
+			r.byMonth = []int{int(r.start.Month())}
+		}
+		r.byMonthDay = []int{r.start.Day()}
+	case monthly:
+		r.byMonthDay = []int{r.start.Day()}
+	case weekly:
+		r.byDay = []weekdayNum{{day: r.start.Weekday()}}
+	}
+}
+
+// Start returns the DTSTART day, or false when the rule has none.
+func (r *Rule) Start() (time.Time, bool) {
+	return r.start, !r.start.IsZero()
+}
+
+// Interval returns the rule's INTERVAL, 1 when not set.
+func (r *Rule) Interval() int {
+	return r.interval
+}
+
+// String returns the rule as written.
+func (r *Rule) String() string {
+	return r.src
+}
+
+func (r *Rule) freqName() string {
+	for name, f := range freqNames {
+		if f == r.freq {
+			return name
+		}
+	}
+	return ""
+}
+
+// Includes reports whether the day of t, in t's location, is an occurrence.
+func (r *Rule) Includes(t time.Time) bool {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	if day.Before(r.start) || (!r.until.IsZero() && day.After(r.until)) {
+		return false
+	}
+	if len(r.bySetPos) == 0 {
+		return r.inInterval(day) && r.match(day)
+	}
+	start, end := r.period(day)
+	return slices.ContainsFunc(r.occurrences(start, end), day.Equal)
+}
+
+// Between returns the occurrences from the day of from up to, but not
+// including, the day of to, as midnight UTC on each day.
+func (r *Rule) Between(from, to time.Time) []time.Time {
+	from = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	to = time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	if !r.until.IsZero() && to.After(r.until) {
+		to = r.until.AddDate(0, 0, 1)
+	}
+	var days []time.Time
+	for start, end := r.period(from); start.Before(to); start, end = r.period(end) {
+		for _, d := range r.occurrences(start, end) {
+			if !d.Before(from) && d.Before(to) {
+				days = append(days, d)
+			}
+		}
+	}
+	return days
+}
+
+// occurrences returns the occurrences in the period from start to end,
+// ignoring UNTIL.
+func (r *Rule) occurrences(start, end time.Time) []time.Time {
+	if end.Before(r.start) || !r.inInterval(start) {
+		return nil
+	}
+	var set []time.Time
+	for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+		if r.match(d) {
+			set = append(set, d)
+		}
+	}
+	if len(r.bySetPos) > 0 {
+		// BYSETPOS picks from the days the other parts select in the period.
+		var picked []time.Time
+		for i, d := range set {
+			for _, pos := range r.bySetPos {
+				if pos-1 == i || len(set)+pos == i {
+					picked = append(picked, d)
+					break
+				}
+			}
+		}
+		set = picked
+	}
+	return slices.DeleteFunc(set, func(d time.Time) bool { return d.Before(r.start) })
+}
+
+// inInterval reports whether the period containing day is one INTERVAL
+// selects: a multiple of INTERVAL periods after the one containing DTSTART.
+func (r *Rule) inInterval(day time.Time) bool {
+	if r.interval == 1 {
+		return true
+	}
+	var n int
+	switch r.freq {
+	case yearly:
+		n = day.Year() - r.start.Year()
+	case monthly:
+		n = (day.Year()-r.start.Year())*12 + int(day.Month()) - int(r.start.Month())
+	case weekly:
+		week, _ := r.period(day)
+		first, _ := r.period(r.start)
+		n = int(week.Sub(first).Hours()/24) / 7
+	case daily:
+		n = int(day.Sub(r.start).Hours() / 24)
+	}
+	return n%r.interval == 0
+}
+
+// period returns the first day of the period containing day and the first
+// day after it.
+func (r *Rule) period(day time.Time) (time.Time, time.Time) {
+	switch r.freq {
+	case yearly:
+		start := time.Date(day.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(1, 0, 0)
+	case monthly:
+		start := time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, 0)
+	case weekly:
+		start := day.AddDate(0, 0, -((int(day.Weekday()) - int(r.wkst) + 7) % 7))
+		return start, start.AddDate(0, 0, 7)
+	}
+	return day, day.AddDate(0, 0, 1)
+}
+
+// match reports whether day satisfies every BY part other than BYSETPOS.
+func (r *Rule) match(day time.Time) bool {
+	if len(r.byMonth) > 0 && !slices.Contains(r.byMonth, int(day.Month())) {
+		return false
+	}
+	monthDays := daysIn(day.Year(), day.Month())
+	if len(r.byMonthDay) > 0 && !matchIndex(r.byMonthDay, day.Day(), monthDays) {
+		return false
+	}
+	yearDays := daysIn(day.Year(), 0)
+	if len(r.byYearDay) > 0 && !matchIndex(r.byYearDay, day.YearDay(), yearDays) {
+		return false
+	}
+	if len(r.byDay) == 0 {
+		return true
+	}
+
+	// Numbered weekdays count within the month for monthly rules and
+	// yearly rules limited to months, otherwise within the year.
+	index, length := day.YearDay(), yearDays
+	if r.freq == monthly || (r.freq == yearly && len(r.byMonth) > 0) {
+		index, length = day.Day(), monthDays
+	}
+	for _, d := range r.byDay {
+		if d.day != day.Weekday() {
+			continue
+		}
+		switch {
+		case d.n == 0:
+			return true
+		case d.n > 0 && (index-1)/7+1 == d.n:
+			return true
+		case d.n < 0 && (length-index)/7+1 == -d.n:
+			return true
+		}
+	}
+	return false
+}
+
+// matchIndex reports whether the 1-based index of a day within a period of
+// length days is one of values, where negative values count from the end.
+func matchIndex(values []int, index, length int) bool {
+	for _, v := range values {
+		if v == index || (v < 0 && length+v+1 == index) {
+			return true
+		}
+	}
+	return false
+}
+
+// daysIn returns the number of days in month of year, or in year when month
+// is 0.
+func daysIn(year int, month time.Month) int {
+	if month == 0 {
+		return time.Date(year, 12, 31, 0, 0, 0, 0, time.UTC).YearDay()
+	}
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// parseDate parses a DTSTART or UNTIL date or date-time; only the date is
+// used.
+func parseDate(name, value string) (time.Time, error) {
+	date, _, _ := strings.Cut(strings.TrimSpace(value), "T")
+	t, err := time.Parse("20060102", date)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s %q, expected YYYYMMDD", name, value)
+	}
+	return t, nil
+}
+
+// parseInts parses a comma-separated list of integers between lo and hi,
+// or -hi and -lo when negative values are allowed.
+func parseInts(name, value string, lo, hi int, negative bool) ([]int, error) {
+	var ints []int
+	for s := range strings.SplitSeq(value, ",") {
+		n, err := strconv.Atoi(strings.TrimPrefix(s, "+"))
+		abs := n
+		if n < 0 && negative {
+			abs = -n
+		}
+		if err != nil || abs < lo || abs > hi {
+			return nil, fmt.Errorf("invalid %s value %q", name, s)
+		}
+		ints = append(ints, n)
+	}
+	return ints, nil
+}
+
+// parseByDay parses a BYDAY list such as "MO,WE" or "2SU,-1FR".
+func parseByDay(value string) ([]weekdayNum, error) {
+	var days []weekdayNum
+	for s := range strings.SplitSeq(value, ",") {
+		if len(s) < 2 {
+			return nil, fmt.Errorf("invalid BYDAY value %q", s)
+		}
+		day, ok := weekdayNames[s[len(s)-2:]]
+		if !ok {
+			return nil, fmt.Errorf("invalid BYDAY value %q", s)
+		}
+		d := weekdayNum{day: day}
+		if num := s[:len(s)-2]; num != "" {
+			n, err := strconv.Atoi(strings.TrimPrefix(num, "+"))
+			if err != nil || n == 0 || n < -53 || n > 53 {
+				return nil, fmt.Errorf("invalid BYDAY value %q", s)
+			}
+			d.n = n
+		}
+		days = append(days, d)
+	}
+	return days, nil
+}
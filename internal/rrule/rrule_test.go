@@ -0,0 +1,116 @@
+package rrule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// occurrences returns the days of year the rule includes, as MM-DD.
+func occurrences(t *testing.T, src string, year int) []string {
+	t.Helper()
+	r, err := Parse(src)
+	require.NoError(t, err)
+	var days []string
+	for d := time.Date(year, 1, 1, 12, 0, 0, 0, time.UTC); d.Year() == year; d = d.AddDate(0, 0, 1) {
+		if r.Includes(d) {
+			days = append(days, d.Format("01-02"))
+		}
+	}
+	return days
+}
+
+func TestRule_Includes(t *testing.T) {
+	tests := []struct {
+		rule string
+		year int
+		want []string
+	}{
+		{"FREQ=YEARLY;BYMONTH=7;BYDAY=2MO", 2026, []string{"07-13"}},
+		{"RRULE:FREQ=YEARLY;BYMONTH=11;BYDAY=4TH", 2026, []string{"11-26"}},
+		{"FREQ=YEARLY;BYMONTH=5;BYDAY=-1MO", 2026, []string{"05-25"}},
+		{"FREQ=YEARLY;BYDAY=1SU", 2026, []string{"01-04"}},
+		{"FREQ=YEARLY;BYDAY=-1SU", 2026, []string{"12-27"}},
+		{"FREQ=YEARLY;BYYEARDAY=-1", 2024, []string{"12-31"}},
+		{"FREQ=YEARLY;BYYEARDAY=60", 2024, []string{"02-29"}},
+		{"FREQ=MONTHLY;BYDAY=FR;BYMONTHDAY=13", 2026, []string{"02-13", "03-13", "11-13"}},
+		{"FREQ=MONTHLY;BYMONTHDAY=-1;BYMONTH=2", 2028, []string{"02-29"}},
+		{"FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1;BYMONTH=1,2", 2026, []string{"01-30", "02-27"}},
+		{"FREQ=WEEKLY;BYDAY=SA,SU;BYMONTH=2;UNTIL=20260210", 2026, []string{"02-01", "02-07", "02-08"}},
+		{"FREQ=DAILY;BYMONTH=2;BYMONTHDAY=1,2", 2026, []string{"02-01", "02-02"}},
+		{"freq=yearly;bymonth=12;bymonthday=25", 2026, []string{"12-25"}},
+		// Intervals count periods from DTSTART, which also supplies the
+		// days of rules that do not select any.
+		{"DTSTART:20260117\nRRULE:FREQ=WEEKLY;INTERVAL=2;UNTIL=20260301", 2026, []string{"01-17", "01-31", "02-14", "02-28"}},
+		{"DTSTART;VALUE=DATE:20260101\nRRULE:FREQ=WEEKLY;INTERVAL=3;BYDAY=SA,SU;BYMONTH=1,2", 2026, []string{"01-03", "01-04", "01-24", "01-25", "02-14", "02-15"}},
+		{"DTSTART:20250110T090000Z\nRRULE:FREQ=MONTHLY;INTERVAL=5", 2026, []string{"04-10", "09-10"}},
+		{"DTSTART;TZID=Europe/Berlin:20240229T180000\nRRULE:FREQ=YEARLY;INTERVAL=2", 2026, nil},
+		{"DTSTART:20240229\nRRULE:FREQ=YEARLY;INTERVAL=4", 2028, []string{"02-29"}},
+		{"DTSTART:20260201\nRRULE:FREQ=MONTHLY;BYDAY=SA,SU;BYSETPOS=1,2;INTERVAL=2", 2026, []string{"02-01", "02-07", "04-04", "04-05", "06-06", "06-07", "08-01", "08-02", "10-03", "10-04", "12-05", "12-06"}},
+		{"DTSTART:20260305\nRRULE:FREQ=DAILY;INTERVAL=10;BYMONTH=3", 2026, []string{"03-05", "03-15", "03-25"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			assert.Equal(t, tt.want, occurrences(t, tt.rule, tt.year))
+		})
+	}
+}
+
+func TestRule_IncludesUsesLocalDay(t *testing.T) {
+	r, err := Parse("FREQ=YEARLY;BYMONTH=12;BYMONTHDAY=25")
+	require.NoError(t, err)
+
+	tokyo := time.FixedZone("JST", 9*60*60)
+	assert.True(t, r.Includes(time.Date(2026, 12, 25, 1, 0, 0, 0, tokyo)))
+	assert.False(t, r.Includes(time.Date(2026, 12, 24, 23, 0, 0, 0, tokyo)))
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		rule string
+		err  string
+	}{
+		{"BYMONTH=7", "FREQ is required"},
+		{"FREQ=HOURLY;BYDAY=MO", "FREQ=HOURLY is not supported, use DAILY, WEEKLY, MONTHLY or YEARLY"},
+		{"FREQ=YEARLY;BYMONTH=7", "FREQ=YEARLY needs BYDAY, BYMONTHDAY or BYYEARDAY to select days, or a DTSTART line to repeat"},
+		{"FREQ=WEEKLY;BYDAY=2MO", "BYDAY cannot number weekdays with FREQ=WEEKLY"},
+		{"FREQ=MONTHLY;BYDAY=6MO", "BYDAY numbers must be between -5 and 5 with FREQ=MONTHLY"},
+		{"FREQ=MONTHLY;BYYEARDAY=1", "BYYEARDAY requires FREQ=YEARLY"},
+		{"FREQ=WEEKLY;BYMONTHDAY=1", "BYMONTHDAY cannot be used with FREQ=WEEKLY"},
+		{"FREQ=YEARLY;BYMONTH=13;BYMONTHDAY=1", `invalid BYMONTH value "13"`},
+		{"FREQ=YEARLY;BYMONTH=-1;BYMONTHDAY=1", `invalid BYMONTH value "-1"`},
+		{"FREQ=YEARLY;BYDAY=XX", `invalid BYDAY value "XX"`},
+		{"FREQ=YEARLY;BYDAY=0MO", `invalid BYDAY value "0MO"`},
+		{"FREQ=YEARLY;BYDAY=MO;COUNT=3", "COUNT is not supported, use UNTIL"},
+		{"FREQ=DAILY;BYHOUR=18", "BYHOUR is not supported, rules select whole days; use a when condition for the time of day"},
+		{"FREQ=YEARLY;BYDAY=MO;INTERVAL=2", "INTERVAL needs a DTSTART line to count periods from"},
+		{"DTSTART:20260103\nRRULE:FREQ=WEEKLY;INTERVAL=0", `invalid INTERVAL "0"`},
+		{"DTSTART:2026-01-03\nRRULE:FREQ=WEEKLY", `invalid DTSTART "2026-01-03", expected YYYYMMDD`},
+		{"DTSTART:20260103\nDTSTART:20260104\nRRULE:FREQ=WEEKLY", "DTSTART is given more than once"},
+		{"FREQ=WEEKLY;BYDAY=SA\nFREQ=WEEKLY;BYDAY=SU", `unexpected line "FREQ=WEEKLY;BYDAY=SU", expected one rule`},
+		{"FREQ=YEARLY;BYDAY=MO;UNTIL=tomorrow", `invalid UNTIL "TOMORROW", expected YYYYMMDD`},
+		{"FREQ=YEARLY;FREQ=MONTHLY", "FREQ is given more than once"},
+		{"FREQ=YEARLY;BYDAY", `invalid rule part "BYDAY", expected NAME=VALUE`},
+		{"FREQ=YEARLY;BYEASTER=0", `unknown rule part "BYEASTER"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			_, err := Parse(tt.rule)
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func TestRule_Between(t *testing.T) {
+	r, err := Parse("FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=1,-1;UNTIL=20260315T120000Z")
+	require.NoError(t, err)
+
+	var days []string
+	for _, d := range r.Between(time.Date(2026, 1, 15, 18, 0, 0, 0, time.UTC), time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC)) {
+		days = append(days, d.Format("2006-01-02"))
+		assert.True(t, r.Includes(d))
+	}
+	assert.Equal(t, []string{"2026-01-30", "2026-02-02", "2026-02-27", "2026-03-02"}, days)
+}
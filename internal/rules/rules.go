@@ -0,0 +1,614 @@
+// Package rules compiles and evaluates the `when` conditions of schedule
+// entries: boolean expressions over the date, the device and the request's
+// query parameters, such as
+//
+//	weekday in ["Saturday", "Sunday"] && device == "kitchen"
+//	hour >= 18 || query.mode == "guest"
+//	ha["person.alex"] == "home"
+//	mqtt["home/mode"] == "guest"
+//
+// Expressions are type-checked when compiled, so mistakes are reported when
+// the configuration is loaded rather than when a kiosk is redirected.
+package rules
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Env is the context an expression is evaluated against.
+type Env struct {
+	Time time.Time
+	// Device identifies the display; empty when unknown.
+	Device string
+	// Query holds the request's query parameters; nil outside requests.
+	Query url.Values
+	// Sources holds external state by source and key, such as Home
+	// Assistant entity states under SourceHomeAssistant.
+	Sources map[string]map[string]string
+}
+
+// SourceHomeAssistant names the Home Assistant entity states, read as
+// ha["entity_id"].
+const SourceHomeAssistant = "ha"
+
+// SourceMQTT names the last values of MQTT topics, read as mqtt["topic"].
+const SourceMQTT = "mqtt"
+
+// sources lists the external state sources expressions may read.
+var sources = []string{SourceHomeAssistant, SourceMQTT}
+
+// Variables lists the names available to expressions.
+var Variables = []string{"date", "year", "month", "day", "weekday", "hour", "minute", "device", "query", SourceHomeAssistant, SourceMQTT}
+
+// Expr is a compiled expression.
+type Expr struct {
+	src  string
+	root node
+	// refs holds the keys read from each source.
+	refs map[string][]string
+}
+
+// Compile parses and type-checks src, which must be a boolean expression.
+func Compile(src string) (*Expr, error) {
+	p := &parser{src: src}
+	if err := p.lex(); err != nil {
+		return nil, err
+	}
+	root, k, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, p.errorf(t, "unexpected %s", t)
+	}
+	if k != kindBool {
+		return nil, fmt.Errorf("expression must be a condition, not a %s", k)
+	}
+	return &Expr{src: src, root: root, refs: p.refs}, nil
+}
+
+// References returns the keys the expression reads from source, in order of
+// appearance.
+func (e *Expr) References(source string) []string {
+	return e.refs[source]
+}
+
+// Clauses splits the expression into the conditions joined by && at its top
+// level, so callers can tell which of them does not hold. An expression with
+// a top-level || is a single clause.
+func (e *Expr) Clauses() []*Expr {
+	p := &parser{src: e.src}
+	if err := p.lex(); err != nil {
+		return []*Expr{e}
+	}
+	var clauses []*Expr
+	depth, start := 0, 0
+	for _, t := range p.tokens {
+		switch {
+		case t.kind != tokOp && t.kind != tokEOF:
+		case t.text == "(" || t.text == "[":
+			depth++
+		case t.text == ")" || t.text == "]":
+			depth--
+		case depth > 0:
+		case t.text == "||":
+			return []*Expr{e}
+		case t.text == "&&" || t.kind == tokEOF:
+			clause, err := Compile(strings.TrimSpace(e.src[start:t.pos]))
+			if err != nil {
+				return []*Expr{e}
+			}
+			clauses = append(clauses, clause)
+			start = t.pos + len(t.text)
+		}
+	}
+	return clauses
+}
+
+// Eval reports whether the expression holds for env.
+func (e *Expr) Eval(env Env) bool {
+	return e.root.eval(&env).b
+}
+
+// String returns the source of the expression.
+func (e *Expr) String() string {
+	return e.src
+}
+
+// kind is the type of a value.
+type kind int
+
+const (
+	kindBool kind = iota
+	kindInt
+	kindString
+)
+
+func (k kind) String() string {
+	switch k {
+	case kindBool:
+		return "bool"
+	case kindInt:
+		return "number"
+	default:
+		return "string"
+	}
+}
+
+// value holds a value of any kind; only the field of its kind is set.
+type value struct {
+	b bool
+	i int
+	s string
+}
+
+// node is an evaluable element of the syntax tree.
+type node interface {
+	eval(env *Env) value
+}
+
+type literal struct{ v value }
+
+func (n literal) eval(*Env) value { return n.v }
+
+type variable func(env *Env) value
+
+func (n variable) eval(env *Env) value { return n(env) }
+
+type not struct{ x node }
+
+func (n not) eval(env *Env) value { return value{b: !n.x.eval(env).b} }
+
+type and struct{ l, r node }
+
+func (n and) eval(env *Env) value { return value{b: n.l.eval(env).b && n.r.eval(env).b} }
+
+type or struct{ l, r node }
+
+func (n or) eval(env *Env) value { return value{b: n.l.eval(env).b || n.r.eval(env).b} }
+
+type compare struct {
+	op   string
+	k    kind
+	l, r node
+}
+
+func (n compare) eval(env *Env) value {
+	l, r := n.l.eval(env), n.r.eval(env)
+	var c int
+	switch n.k {
+	case kindBool:
+		if l.b != r.b {
+			c = 1
+		}
+	case kindInt:
+		c = cmpInt(l.i, r.i)
+	case kindString:
+		c = strings.Compare(l.s, r.s)
+	}
+	switch n.op {
+	case "==":
+		return value{b: c == 0}
+	case "!=":
+		return value{b: c != 0}
+	case "<":
+		return value{b: c < 0}
+	case "<=":
+		return value{b: c <= 0}
+	case ">":
+		return value{b: c > 0}
+	default:
+		return value{b: c >= 0}
+	}
+}
+
+type in struct {
+	x    node
+	list []value
+}
+
+func (n in) eval(env *Env) value {
+	x := n.x.eval(env)
+	for _, v := range n.list {
+		if v == x {
+			return value{b: true}
+		}
+	}
+	return value{}
+}
+
+func cmpInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// variables maps the names of scalar variables to their kind and accessor.
+var variables = map[string]struct {
+	k   kind
+	get variable
+}{
+	"date":    {kindString, func(env *Env) value { return value{s: env.Time.Format(time.DateOnly)} }},
+	"year":    {kindInt, func(env *Env) value { return value{i: env.Time.Year()} }},
+	"month":   {kindInt, func(env *Env) value { return value{i: int(env.Time.Month())} }},
+	"day":     {kindInt, func(env *Env) value { return value{i: env.Time.Day()} }},
+	"weekday": {kindString, func(env *Env) value { return value{s: env.Time.Weekday().String()} }},
+	"hour":    {kindInt, func(env *Env) value { return value{i: env.Time.Hour()} }},
+	"minute":  {kindInt, func(env *Env) value { return value{i: env.Time.Minute()} }},
+	"device":  {kindString, func(env *Env) value { return value{s: env.Device} }},
+}
+
+// queryParam returns an accessor for a query parameter; missing parameters
+// are empty strings.
+func queryParam(name string) variable {
+	return func(env *Env) value { return value{s: env.Query.Get(name)} }
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokInt
+	tokString
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+	// val holds the decoded value of an int or string literal.
+	val value
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of expression"
+	case tokString:
+		return strconv.Quote(t.val.s)
+	default:
+		return fmt.Sprintf("%q", t.text)
+	}
+}
+
+type parser struct {
+	src    string
+	tokens []token
+	next   int
+	refs   map[string][]string
+}
+
+func (p *parser) errorf(t token, format string, args ...any) error {
+	return fmt.Errorf("column %d: %s", t.pos+1, fmt.Sprintf(format, args...))
+}
+
+// operators lists the operator tokens, longest first.
+var operators = []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "!", "(", ")", "[", "]", ",", "."}
+
+// lex splits the source into tokens.
+func (p *parser) lex() error {
+	src := p.src
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case isIdentStart(c):
+			j := i + 1
+			for j < len(src) && (isIdentStart(src[j]) || isDigit(src[j])) {
+				j++
+			}
+			p.tokens = append(p.tokens, token{kind: tokIdent, text: src[i:j], pos: i})
+			i = j
+		case isDigit(c):
+			j := i + 1
+			for j < len(src) && isDigit(src[j]) {
+				j++
+			}
+			n, err := strconv.Atoi(src[i:j])
+			if err != nil {
+				return fmt.Errorf("column %d: invalid number %q", i+1, src[i:j])
+			}
+			p.tokens = append(p.tokens, token{kind: tokInt, text: src[i:j], pos: i, val: value{i: n}})
+			i = j
+		case c == '"' || c == '\'':
+			j := i + 1
+			for j < len(src) && src[j] != c {
+				if src[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(src) {
+				return fmt.Errorf("column %d: unterminated string", i+1)
+			}
+			text := src[i : j+1]
+			s, err := unquote(text)
+			if err != nil {
+				return fmt.Errorf("column %d: invalid string %s", i+1, text)
+			}
+			p.tokens = append(p.tokens, token{kind: tokString, text: text, pos: i, val: value{s: s}})
+			i = j + 1
+		default:
+			op := ""
+			for _, o := range operators {
+				if strings.HasPrefix(src[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return fmt.Errorf("column %d: unexpected character %q", i+1, c)
+			}
+			p.tokens = append(p.tokens, token{kind: tokOp, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	p.tokens = append(p.tokens, token{kind: tokEOF, pos: len(src)})
+	return nil
+}
+
+// unquote decodes a double- or single-quoted string literal.
+func unquote(text string) (string, error) {
+	if text[0] == '\'' {
+		text = `"` + strings.ReplaceAll(strings.ReplaceAll(text[1:len(text)-1], `\'`, `'`), `"`, `\"`) + `"`
+	}
+	return strconv.Unquote(text)
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.next]
+}
+
+func (p *parser) advance() token {
+	t := p.tokens[p.next]
+	if t.kind != tokEOF {
+		p.next++
+	}
+	return t
+}
+
+// accept consumes the next token if it is the given operator.
+func (p *parser) accept(op string) bool {
+	if t := p.peek(); t.kind == tokOp && t.text == op {
+		p.next++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(op string) error {
+	if !p.accept(op) {
+		t := p.peek()
+		return p.errorf(t, "expected %q, got %s", op, t)
+	}
+	return nil
+}
+
+// parseOr parses `a || b`.
+func (p *parser) parseOr() (node, kind, error) {
+	return p.parseLogical("||", p.parseAnd, func(l, r node) node { return or{l, r} })
+}
+
+// parseAnd parses `a && b`.
+func (p *parser) parseAnd() (node, kind, error) {
+	return p.parseLogical("&&", p.parseUnary, func(l, r node) node { return and{l, r} })
+}
+
+func (p *parser) parseLogical(op string, operand func() (node, kind, error), combine func(l, r node) node) (node, kind, error) {
+	start := p.peek()
+	l, k, err := operand()
+	if err != nil {
+		return nil, 0, err
+	}
+	for {
+		t := p.peek()
+		if !p.accept(op) {
+			return l, k, nil
+		}
+		if k != kindBool {
+			return nil, 0, p.errorf(start, "%s needs conditions, got a %s", op, k)
+		}
+		rstart := p.peek()
+		r, rk, err := operand()
+		if err != nil {
+			return nil, 0, err
+		}
+		if rk != kindBool {
+			return nil, 0, p.errorf(rstart, "%s needs conditions, got a %s after %s", op, rk, t)
+		}
+		l = combine(l, r)
+	}
+}
+
+// parseUnary parses `!a`.
+func (p *parser) parseUnary() (node, kind, error) {
+	t := p.peek()
+	if p.accept("!") {
+		x, k, err := p.parseUnary()
+		if err != nil {
+			return nil, 0, err
+		}
+		if k != kindBool {
+			return nil, 0, p.errorf(t, "! needs a condition, got a %s", k)
+		}
+		return not{x}, kindBool, nil
+	}
+	return p.parseComparison()
+}
+
+// parseComparison parses `a == b` and the other comparisons, and `a in [...]`.
+func (p *parser) parseComparison() (node, kind, error) {
+	l, lk, err := p.parseOperand()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	t := p.peek()
+	if t.kind == tokIdent && t.text == "in" {
+		p.advance()
+		list, err := p.parseList(lk)
+		if err != nil {
+			return nil, 0, err
+		}
+		return in{x: l, list: list}, kindBool, nil
+	}
+	if t.kind != tokOp {
+		return l, lk, nil
+	}
+	switch t.text {
+	case "==", "!=", "<", "<=", ">", ">=":
+	default:
+		return l, lk, nil
+	}
+	p.advance()
+
+	r, rk, err := p.parseOperand()
+	if err != nil {
+		return nil, 0, err
+	}
+	if lk != rk {
+		return nil, 0, p.errorf(t, "cannot compare %s with %s", lk, rk)
+	}
+	if lk == kindBool && t.text != "==" && t.text != "!=" {
+		return nil, 0, p.errorf(t, "%s does not apply to conditions", t.text)
+	}
+	return compare{op: t.text, k: lk, l: l, r: r}, kindBool, nil
+}
+
+// parseList parses a list of literals of kind k.
+func (p *parser) parseList(k kind) ([]value, error) {
+	if err := p.expect("["); err != nil {
+		return nil, err
+	}
+	var list []value
+	for {
+		t := p.advance()
+		var lk kind
+		switch t.kind {
+		case tokInt:
+			lk = kindInt
+		case tokString:
+			lk = kindString
+		default:
+			return nil, p.errorf(t, "expected a number or string in the list, got %s", t)
+		}
+		if lk != k {
+			return nil, p.errorf(t, "list element %s is a %s, expected a %s", t, lk, k)
+		}
+		list = append(list, t.val)
+		if p.accept("]") {
+			return list, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parseOperand parses a literal, a variable or a parenthesized expression.
+func (p *parser) parseOperand() (node, kind, error) {
+	t := p.advance()
+	switch t.kind {
+	case tokInt:
+		return literal{t.val}, kindInt, nil
+	case tokString:
+		return literal{t.val}, kindString, nil
+	case tokIdent:
+		switch t.text {
+		case "true", "false":
+			return literal{value{b: t.text == "true"}}, kindBool, nil
+		case "query":
+			return p.parseQuery()
+		}
+		if slices.Contains(sources, t.text) {
+			return p.parseSource(t.text)
+		}
+		v, ok := variables[t.text]
+		if !ok {
+			return nil, 0, p.errorf(t, "unknown variable %q (available: %s)", t.text, strings.Join(Variables, ", "))
+		}
+		return v.get, v.k, nil
+	case tokOp:
+		if t.text == "(" {
+			x, k, err := p.parseOr()
+			if err != nil {
+				return nil, 0, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, 0, err
+			}
+			return x, k, nil
+		}
+	}
+	return nil, 0, p.errorf(t, "expected a value, got %s", t)
+}
+
+// parseQuery parses `query.name` or `query["name"]` after `query`.
+func (p *parser) parseQuery() (node, kind, error) {
+	switch {
+	case p.accept("."):
+		t := p.advance()
+		if t.kind != tokIdent {
+			return nil, 0, p.errorf(t, "expected a parameter name after query., got %s", t)
+		}
+		return queryParam(t.text), kindString, nil
+	case p.accept("["):
+		t := p.advance()
+		if t.kind != tokString {
+			return nil, 0, p.errorf(t, "expected a quoted parameter name, got %s", t)
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, 0, err
+		}
+		return queryParam(t.val.s), kindString, nil
+	}
+	t := p.peek()
+	return nil, 0, p.errorf(t, `expected query.name or query["name"], got %s`, t)
+}
+
+// sourceValue returns an accessor for a key of an external source; missing
+// keys are empty strings.
+func sourceValue(source, key string) variable {
+	return func(env *Env) value { return value{s: env.Sources[source][key]} }
+}
+
+// parseSource parses `source["key"]` after the source name.
+func (p *parser) parseSource(source string) (node, kind, error) {
+	if err := p.expect("["); err != nil {
+		return nil, 0, err
+	}
+	t := p.advance()
+	if t.kind != tokString {
+		return nil, 0, p.errorf(t, "expected a quoted key after %s[, got %s", source, t)
+	}
+	if err := p.expect("]"); err != nil {
+		return nil, 0, err
+	}
+	if p.refs == nil {
+		p.refs = make(map[string][]string)
+	}
+	if !slices.Contains(p.refs[source], t.val.s) {
+		p.refs[source] = append(p.refs[source], t.val.s)
+	}
+	return sourceValue(source, t.val.s), kindString, nil
+}
@@ -0,0 +1,124 @@
+package rules
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpr_Eval(t *testing.T) {
+	// Saturday evening.
+	env := Env{
+		Time:   time.Date(2024, 12, 21, 19, 30, 0, 0, time.UTC),
+		Device: "kitchen",
+		Query:  url.Values{"mode": {"guest"}},
+		Sources: map[string]map[string]string{
+			SourceHomeAssistant: {"person.alex": "home"},
+		},
+	}
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`weekday == "Saturday"`, true},
+		{`weekday in ["Saturday", "Sunday"] && device == "kitchen"`, true},
+		{`weekday in ['Monday', 'Tuesday']`, false},
+		{`hour >= 18 && minute < 45`, true},
+		{`month == 12 && day > 20 && year == 2024`, true},
+		{`date >= "2024-12-20" && date <= "2024-12-26"`, true},
+		{`device != "kitchen" || query.mode == "guest"`, true},
+		{`query["mode"] == "guest"`, true},
+		{`query.missing == ""`, true},
+		{`!(hour < 18)`, true},
+		{`!true || false`, false},
+		{`(device == "hallway" || device == "kitchen") && month in [11, 12]`, true},
+		{`device == "hallway" || hour < 6`, false},
+		{`ha["person.alex"] == "home"`, true},
+		{`ha["person.sam"] == "home"`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			e, err := Compile(tt.expr)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, e.Eval(env))
+			assert.Equal(t, tt.expr, e.String())
+		})
+	}
+}
+
+func TestExpr_EvalWithoutRequest(t *testing.T) {
+	e, err := Compile(`device == "" && query.mode != "guest"`)
+	require.NoError(t, err)
+	assert.True(t, e.Eval(Env{Time: time.Now()}))
+}
+
+func TestCompile_Errors(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{``, "column 1: expected a value, got end of expression"},
+		{`hour`, "must be a condition, not a number"},
+		{`weekday == 6`, "column 9: cannot compare string with number"},
+		{`colour == "red"`, `column 1: unknown variable "colour"`},
+		{`hour > 18 &&`, "column 13: expected a value"},
+		{`hour && true`, "column 1: && needs conditions, got a number"},
+		{`month in ["December"]`, `list element "December" is a string, expected a number`},
+		{`weekday == "Saturday`, "column 12: unterminated string"},
+		{`hour >= 18 hour`, `column 12: unexpected "hour"`},
+		{`(hour > 18`, `column 11: expected ")"`},
+		{`device = "kitchen"`, "column 8: unexpected character '='"},
+		{`true < false`, "< does not apply to conditions"},
+		{`query.`, "expected a parameter name"},
+		{`!hour`, "! needs a condition, got a number"},
+		{`ha.person == "home"`, `column 3: expected "["`},
+		{`ha[person] == "home"`, "expected a quoted key after ha["},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := Compile(tt.expr)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestExpr_References(t *testing.T) {
+	e, err := Compile(`ha["person.alex"] == "home" || ha["person.sam"] == "home" && ha["person.alex"] != "away"`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"person.alex", "person.sam"}, e.References(SourceHomeAssistant))
+
+	e, err = Compile(`hour > 6`)
+	require.NoError(t, err)
+	assert.Empty(t, e.References(SourceHomeAssistant))
+}
+
+func TestExpr_Clauses(t *testing.T) {
+	tests := []struct {
+		src  string
+		want []string
+	}{
+		{`hour >= 18`, []string{`hour >= 18`}},
+		{`weekday == "Saturday" && (hour < 9 || hour >= 18) && query["room"] == "a && b"`,
+			[]string{`weekday == "Saturday"`, `(hour < 9 || hour >= 18)`, `query["room"] == "a && b"`}},
+		{`month == 12 && day > 20 || weekday == "Sunday"`, []string{`month == 12 && day > 20 || weekday == "Sunday"`}},
+		{`!(hour > 6 && hour < 22)`, []string{`!(hour > 6 && hour < 22)`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			e, err := Compile(tt.src)
+			require.NoError(t, err)
+			var got []string
+			for _, c := range e.Clauses() {
+				got = append(got, c.String())
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package scheduler
+
+import (
+	"time"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/rules"
+)
+
+// DefaultSchedule is the schedule name of decisions that fall back to the
+// default album.
+const DefaultSchedule = "default"
+
+// Decision is the album a backend selects for a point in time.
+type Decision struct {
+	// Schedule is the name of the selected entry, or DefaultSchedule.
+	Schedule string `json:"schedule"`
+	Album    string `json:"album"`
+	// Entry is the selected schedule entry, or nil when the backend did not
+	// select one of its listed entries.
+	Entry *config.ScheduleEntry `json:"-"`
+	// Until is when the decision may change; callers may reuse it before then.
+	Until time.Time `json:"until"`
+}
+
+// Backend decides which album is shown. The static schedule from the
+// configuration is one implementation; others can source decisions from
+// calendars, remote services or databases.
+type Backend interface {
+	// Resolve returns the decision for t.
+	Resolve(t time.Time) Decision
+	// NextTransition returns the first decision after t that selects a
+	// different schedule, and when it starts. It reports false when none is
+	// known.
+	NextTransition(t time.Time) (time.Time, Decision, bool)
+	// List returns the backend's schedule entries in evaluation order.
+	List() []config.ScheduleEntry
+}
+
+var _ Backend = (*Scheduler)(nil)
+
+// Resolve returns the decision of the static schedule for t. It holds until
+// the end of t's day, since entries cover whole days, unless a when
+// condition is involved. Conditions are evaluated without a device or query.
+func (s *Scheduler) Resolve(t time.Time) Decision {
+	return s.ResolveEnv(rules.Env{Time: t})
+}
+
+// ResolveEnv returns the decision for env.Time, evaluating when conditions
+// against env and the state of the sources set with SetSource.
+func (s *Scheduler) ResolveEnv(env rules.Env) Decision {
+	t := s.table.Load()
+	if !t.conditional {
+		return t.resolve(env)
+	}
+	return t.resolve(s.env(env))
+}
+
+// NextTransition returns the start of the next day on which a different
+// entry is selected and the decision for it.
+func (s *Scheduler) NextTransition(t time.Time) (time.Time, Decision, bool) {
+	tbl := s.table.Load()
+	env := s.env(rules.Env{Time: t})
+	current := tbl.resolve(env).Schedule
+	day := startOfDay(t)
+	for range daysInYear {
+		day = day.AddDate(0, 0, 1)
+		env.Time = day
+		if d := tbl.resolve(env); d.Schedule != current {
+			return day, d, true
+		}
+	}
+	return time.Time{}, Decision{}, false
+}
+
+// List returns the schedule entries.
+func (s *Scheduler) List() []config.ScheduleEntry {
+	return s.Entries()
+}
+
+// Step records how one schedule entry fared when resolving a point in time.
+type Step struct {
+	// Index is the entry's position in Entries.
+	Index int
+	Entry config.ScheduleEntry
+	// InRange reports whether the entry's date range includes the day and,
+	// for entries with a recurrence rule, whether an occurrence covers it.
+	// InDates and Occurs report the two separately.
+	InRange bool
+	InDates bool
+	Occurs  bool
+	// ConditionMet reports whether the entry's when condition holds; it is
+	// true for entries without one and false for entries out of range.
+	ConditionMet bool
+	// Selected marks the entry that is selected, the first one both in
+	// range and with its condition met.
+	Selected bool
+	// Clauses holds the conditions joined by && in the when condition of an
+	// entry in range, with whether each holds.
+	Clauses []Clause
+}
+
+// Clause is one of the conditions of a when condition.
+type Clause struct {
+	Condition string
+	Holds     bool
+}
+
+// Trace resolves env.Time like ResolveEnv and reports every entry in
+// evaluation order. Conditions are evaluated for all entries in range, so
+// entries overridden by an earlier one can be told apart from entries whose
+// condition does not hold.
+func (s *Scheduler) Trace(env rules.Env) []Step {
+	t := s.table.Load()
+	if t.conditional {
+		env = s.env(env)
+	}
+	doy := monthDayToDOY(int(env.Time.Month()), env.Time.Day())
+	steps := make([]Step, len(t.entries))
+	selected := false
+	for i := range t.entries {
+		r := &t.ranges[i]
+		st := Step{Index: i, Entry: t.entries[i], InDates: dateInRange(doy, *r), Occurs: r.occurs(env.Time)}
+		st.InRange = st.InDates && st.Occurs
+		if st.InRange {
+			st.ConditionMet = r.when == nil || r.when.Eval(env)
+			if r.when != nil {
+				for _, c := range r.when.Clauses() {
+					st.Clauses = append(st.Clauses, Clause{Condition: c.String(), Holds: c.Eval(env)})
+				}
+			}
+			st.Selected = st.ConditionMet && !selected
+			selected = selected || st.Selected
+		}
+		steps[i] = st
+	}
+	return steps
+}
+
+// resolve returns the table's decision for env: the first entry whose range
+// includes the day, whose recurrence rule, if any, has an occurrence on it
+// and whose condition, if any, holds.
+func (t *table) resolve(env rules.Env) Decision {
+	at := env.Time
+	d := Decision{Schedule: DefaultSchedule, Album: t.defaultAlbum, Until: startOfDay(at).AddDate(0, 0, 1)}
+	for _, i := range t.matches[monthDayToDOY(int(at.Month()), at.Day())-1] {
+		r := &t.ranges[i]
+		if !r.occurs(at) {
+			continue
+		}
+		if r.when != nil {
+			// Conditions can depend on the time of day, so the decision is
+			// only reused within the minute.
+			d.Until = at.Truncate(time.Minute).Add(time.Minute)
+			if !r.when.Eval(env) {
+				continue
+			}
+		}
+		d.Schedule, d.Album, d.Entry = r.name, r.album, &t.entries[i]
+		if len(r.albums) > 0 {
+			d.Album, d.Until = r.rotation(at, d.Until)
+		}
+		return d
+	}
+	return d
+}
+
+// rotation returns the album of a rotating range shown at t and when the
+// next one starts, or until if that is earlier. Turns are counted from the
+// Unix epoch, so every display agrees on the album.
+func (r *dateRange) rotation(t, until time.Time) (string, time.Time) {
+	seconds := int64(r.rotate / time.Second)
+	turn := t.Unix() / seconds
+	if t.Unix() < 0 && t.Unix()%seconds != 0 {
+		turn-- // round towards the earlier turn before 1970
+	}
+	if next := time.Unix((turn+1)*seconds, 0).In(t.Location()); next.Before(until) {
+		until = next
+	}
+	n := int64(len(r.albums))
+	return r.albums[(turn%n+n)%n], until
+}
+
+// startOfDay returns midnight at the start of t's day in t's location.
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,316 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/rules"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduler_Resolve(t *testing.T) {
+	cfg := &config.Config{
+		DefaultAlbum: "default-album",
+		Schedule: []config.ScheduleEntry{
+			{Name: "christmas", Album: "christmas-album", Start: "12-01", End: "12-26"},
+		},
+	}
+	s, err := New(cfg)
+	require.NoError(t, err)
+
+	d := s.Resolve(time.Date(2024, 12, 24, 15, 30, 0, 0, time.UTC))
+	assert.Equal(t, "christmas", d.Schedule)
+	assert.Equal(t, "christmas-album", d.Album)
+	require.NotNil(t, d.Entry)
+	assert.Equal(t, "christmas", d.Entry.Name)
+	assert.Equal(t, time.Date(2024, 12, 25, 0, 0, 0, 0, time.UTC), d.Until)
+
+	d = s.Resolve(time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, DefaultSchedule, d.Schedule)
+	assert.Equal(t, "default-album", d.Album)
+	assert.Nil(t, d.Entry)
+
+	at, next, ok := s.NextTransition(time.Date(2024, 12, 24, 15, 30, 0, 0, time.UTC))
+	require.True(t, ok)
+	assert.Equal(t, time.Date(2024, 12, 27, 0, 0, 0, 0, time.UTC), at)
+	assert.Equal(t, DefaultSchedule, next.Schedule)
+
+	assert.Equal(t, cfg.Schedule, s.List())
+}
+
+func TestScheduler_ResolveRotation(t *testing.T) {
+	cfg := &config.Config{
+		DefaultAlbum: "default-album",
+		Schedule: []config.ScheduleEntry{
+			{Name: "party", Albums: []string{"decor", "people", "pets"}, RotateMinutes: 15, Start: "06-01", End: "06-01"},
+		},
+	}
+	s, err := New(cfg)
+	require.NoError(t, err)
+
+	start := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	var albums []string
+	for at := start; at.Before(start.Add(time.Hour)); at = at.Add(15 * time.Minute) {
+		d := s.Resolve(at.Add(7 * time.Minute))
+		assert.Equal(t, "party", d.Schedule)
+		assert.Equal(t, at.Add(15*time.Minute), d.Until, "a decision holds until the next turn")
+		albums = append(albums, d.Album)
+	}
+	// 2024-06-01T00:00Z is turn 1908000 since the epoch, which selects "decor".
+	assert.Equal(t, []string{"decor", "people", "pets", "decor"}, albums)
+
+	// Displays in other time zones agree on the album.
+	berlin := time.FixedZone("CEST", 2*60*60)
+	assert.Equal(t, s.Resolve(start.Add(20*time.Minute)).Album, s.Resolve(start.Add(20*time.Minute).In(berlin)).Album)
+
+	// The last turn of the day ends with the entry.
+	d := s.Resolve(time.Date(2024, 6, 1, 23, 50, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2024, 6, 2, 0, 0, 0, 0, time.UTC), d.Until)
+}
+
+func TestScheduler_NextTransition_None(t *testing.T) {
+	s, err := New(&config.Config{DefaultAlbum: "default-album"})
+	require.NoError(t, err)
+
+	_, _, ok := s.NextTransition(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	assert.False(t, ok)
+}
+
+func TestScheduler_ResolveEnvWhen(t *testing.T) {
+	cfg := &config.Config{
+		DefaultAlbum: "default-album",
+		Schedule: []config.ScheduleEntry{
+			{Name: "evening", Album: "evening-album", Start: "01-01", End: "12-31", When: "hour >= 18"},
+			{Name: "winter", Album: "winter-album", Start: "12-01", End: "02-28"},
+		},
+	}
+	s, err := New(cfg)
+	require.NoError(t, err)
+	assert.True(t, s.Conditional())
+
+	evening := time.Date(2024, 12, 24, 19, 30, 15, 0, time.UTC)
+	d := s.ResolveEnv(rules.Env{Time: evening})
+	assert.Equal(t, "evening", d.Schedule)
+	assert.Equal(t, time.Date(2024, 12, 24, 19, 31, 0, 0, time.UTC), d.Until)
+
+	// A failed condition falls through to the next matching entry.
+	d = s.Resolve(time.Date(2024, 12, 24, 9, 0, 0, 0, time.UTC))
+	assert.Equal(t, "winter", d.Schedule)
+	assert.Equal(t, "default-album", s.Resolve(time.Date(2024, 7, 1, 9, 0, 0, 0, time.UTC)).Album)
+
+	// Coverage reports what applies when no condition holds.
+	c := s.Coverage()
+	assert.Equal(t, daysInYear, c.Entries[0].SelectedDays)
+	assert.Equal(t, 90, c.Entries[1].SelectedDays)
+	assert.Equal(t, daysInYear-90, c.DefaultDays)
+
+	_, err = New(&config.Config{Schedule: []config.ScheduleEntry{
+		{Name: "broken", Album: "a", Start: "01-01", End: "12-31", When: "hour >="},
+	}})
+	assert.ErrorContains(t, err, `invalid when for "broken"`)
+}
+
+func TestScheduler_Trace(t *testing.T) {
+	cfg := &config.Config{
+		DefaultAlbum: "default-album",
+		Schedule: []config.ScheduleEntry{
+			{Name: "evenings", Album: "evening-album", Start: "12-01", End: "12-31", When: "hour >= 18"},
+			{Name: "christmas-2026", Album: "christmas-album", Start: "12-20", End: "12-26", When: "year == 2026"},
+			{Name: "december", Album: "december-album", Start: "12-01", End: "12-31"},
+			{Name: "summer", Album: "summer-album", Start: "06-01", End: "08-31"},
+		},
+	}
+	s, err := New(cfg)
+	require.NoError(t, err)
+
+	at := time.Date(2026, 12, 25, 9, 0, 0, 0, time.UTC)
+	steps := s.Trace(rules.Env{Time: at})
+	require.Len(t, steps, 4)
+	assert.Equal(t, Step{Index: 0, Entry: cfg.Schedule[0], InRange: true, InDates: true, Occurs: true,
+		Clauses: []Clause{{Condition: "hour >= 18"}}}, steps[0])
+	assert.Equal(t, Step{Index: 1, Entry: cfg.Schedule[1], InRange: true, InDates: true, Occurs: true, ConditionMet: true, Selected: true,
+		Clauses: []Clause{{Condition: "year == 2026", Holds: true}}}, steps[1])
+	assert.Equal(t, Step{Index: 2, Entry: cfg.Schedule[2], InRange: true, InDates: true, Occurs: true, ConditionMet: true}, steps[2])
+	assert.Equal(t, Step{Index: 3, Entry: cfg.Schedule[3], Occurs: true}, steps[3])
+	assert.Equal(t, s.Resolve(at).Schedule, steps[1].Entry.Name)
+
+	steps = s.Trace(rules.Env{Time: at.AddDate(1, 0, 0).Add(10 * time.Hour)})
+	assert.True(t, steps[0].Selected)
+	assert.False(t, steps[1].ConditionMet)
+	assert.False(t, steps[2].Selected)
+}
+
+func TestScheduler_TraceReasons(t *testing.T) {
+	cfg := &config.Config{
+		DefaultAlbum: "default-album",
+		Schedule: []config.ScheduleEntry{
+			{Name: "game-night", Album: "game-album", RRule: "FREQ=WEEKLY;BYDAY=FR", When: `weekday == "Friday" && hour >= 18 && month == 12`},
+			{Name: "mondays", Album: "monday-album", RRule: "FREQ=WEEKLY;BYDAY=MO"},
+		},
+	}
+	s, err := New(cfg)
+	require.NoError(t, err)
+
+	// Friday, December 25, 2026 at 10:00.
+	steps := s.Trace(rules.Env{Time: time.Date(2026, 12, 25, 10, 0, 0, 0, time.UTC)})
+	require.Len(t, steps, 2)
+	assert.True(t, steps[0].InRange)
+	assert.False(t, steps[0].ConditionMet)
+	assert.Equal(t, []Clause{
+		{Condition: `weekday == "Friday"`, Holds: true},
+		{Condition: "hour >= 18"},
+		{Condition: "month == 12", Holds: true},
+	}, steps[0].Clauses)
+	assert.True(t, steps[1].InDates)
+	assert.False(t, steps[1].Occurs)
+	assert.False(t, steps[1].InRange)
+	assert.Nil(t, steps[1].Clauses)
+}
+
+func TestScheduler_ResolveRRule(t *testing.T) {
+	cfg := &config.Config{
+		DefaultAlbum: "default-album",
+		Schedule: []config.ScheduleEntry{
+			// The second full week of July, Monday to Sunday.
+			{Name: "camp", Album: "camp-album", RRule: "FREQ=YEARLY;BYMONTH=7;BYDAY=2MO", Days: 7},
+			{Name: "first-weekend", Album: "weekend-album", Start: "06-01", End: "08-31", RRule: "FREQ=MONTHLY;BYDAY=SA,SU;BYMONTHDAY=1,2,3,4,5,6,7"},
+		},
+	}
+	s, err := New(cfg)
+	require.NoError(t, err)
+
+	day := func(month time.Month, day int) time.Time { return time.Date(2026, month, day, 12, 0, 0, 0, time.UTC) }
+	assert.Equal(t, DefaultSchedule, s.Resolve(day(7, 12)).Schedule)
+	assert.Equal(t, "camp", s.Resolve(day(7, 13)).Schedule)
+	assert.Equal(t, "camp", s.Resolve(day(7, 19)).Schedule)
+	assert.Equal(t, DefaultSchedule, s.Resolve(day(7, 20)).Schedule)
+	assert.Equal(t, "camp", s.Resolve(time.Date(2027, 7, 12, 0, 0, 0, 0, time.UTC)).Schedule)
+
+	assert.Equal(t, "first-weekend", s.Resolve(day(6, 6)).Schedule)
+	assert.Equal(t, DefaultSchedule, s.Resolve(day(6, 13)).Schedule)
+	assert.Equal(t, DefaultSchedule, s.Resolve(day(9, 5)).Schedule, "outside start and end")
+	assert.Empty(t, s.GetMatchingSchedulesForDate(day(6, 13)))
+	assert.Equal(t, []int{0}, s.GetMatchingSchedulesForDate(day(7, 18)))
+	assert.Equal(t, []int{1}, s.GetMatchingSchedulesForDate(day(7, 4)))
+
+	at, next, ok := s.NextTransition(day(7, 1))
+	require.True(t, ok)
+	assert.Equal(t, time.Date(2026, 7, 4, 0, 0, 0, 0, time.UTC), at)
+	assert.Equal(t, "first-weekend", next.Schedule)
+
+	// The second Monday of July falls between 07-08 and 07-14, so the week
+	// can cover 07-08 to 07-20 depending on the year.
+	coverage := s.Coverage()
+	assert.Equal(t, 13, coverage.Entries[0].Days)
+	assert.Equal(t, 13, coverage.Entries[0].SelectedDays)
+	assert.Equal(t, daysInYear, coverage.DefaultDays)
+}
+
+func TestScheduler_ResolveRRuleInterval(t *testing.T) {
+	cfg := &config.Config{
+		DefaultAlbum: "default-album",
+		Schedule: []config.ScheduleEntry{
+			// Every other weekend, from Saturday 2026-01-03.
+			{Name: "grandparents", Album: "family-album", RRule: "DTSTART:20260103\nRRULE:FREQ=WEEKLY;INTERVAL=2", Days: 2},
+		},
+	}
+	s, err := New(cfg)
+	require.NoError(t, err)
+
+	var weekends []string
+	for d := time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC); d.Before(time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)); d = d.AddDate(0, 0, 1) {
+		if s.Resolve(d).Schedule == "grandparents" {
+			weekends = append(weekends, d.Format("01-02"))
+		}
+	}
+	assert.Equal(t, []string{"01-03", "01-04", "01-17", "01-18", "01-31"}, weekends)
+
+	at, _, ok := s.NextTransition(time.Date(2026, 1, 4, 12, 0, 0, 0, time.UTC))
+	require.True(t, ok)
+	assert.Equal(t, time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC), at)
+	at, _, ok = s.NextTransition(at)
+	require.True(t, ok)
+	assert.Equal(t, time.Date(2026, 1, 17, 0, 0, 0, 0, time.UTC), at)
+	assert.Equal(t, "DTSTART:20260103 RRULE:FREQ=WEEKLY;INTERVAL=2 for 2 days", cfg.Schedule[0].Dates())
+}
+
+func TestScheduler_ResolveRRuleIntervalBeyondCycle(t *testing.T) {
+	cfg := &config.Config{
+		DefaultAlbum: "default-album",
+		Schedule: []config.ScheduleEntry{
+			// The first Saturday of July every five years, which 28 years of
+			// occurrences do not all show.
+			{Name: "reunion", Album: "reunion-album", RRule: "DTSTART:20200101\nRRULE:FREQ=YEARLY;INTERVAL=5;BYMONTH=7;BYDAY=1SA"},
+		},
+	}
+	s, err := New(cfg)
+	require.NoError(t, err)
+
+	for _, day := range []time.Time{
+		time.Date(2050, 7, 2, 0, 0, 0, 0, time.UTC),
+		time.Date(2055, 7, 3, 0, 0, 0, 0, time.UTC),
+		time.Date(2060, 7, 3, 0, 0, 0, 0, time.UTC),
+	} {
+		assert.Equal(t, "reunion-album", s.Resolve(day).Album, day.Format(time.DateOnly))
+	}
+	assert.Equal(t, "default-album", s.Resolve(time.Date(2051, 7, 1, 0, 0, 0, 0, time.UTC)).Album)
+}
+
+func TestDwell_Apply(t *testing.T) {
+	cfg := &config.Config{
+		DefaultAlbum: "default-album",
+		Schedule: []config.ScheduleEntry{
+			{Name: "rain", Album: "rain-album", Start: "01-01", End: "12-31", When: `query.weather == "rain"`, MinDuration: "30m"},
+			{Name: "sun", Album: "sun-album", Start: "01-01", End: "12-31"},
+		},
+	}
+	s, err := New(cfg)
+	require.NoError(t, err)
+	resolve := func(at time.Time, weather string) Decision {
+		return s.ResolveEnv(rules.Env{Time: at, Query: map[string][]string{"weather": {weather}}})
+	}
+
+	w := NewDwell(nil)
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	// Entries without min_duration do not start a hold.
+	d, changed := w.Apply("tv", resolve(start, ""), start, s.List())
+	assert.Equal(t, "sun", d.Schedule)
+	assert.False(t, changed)
+
+	d, changed = w.Apply("tv", resolve(start, "rain"), start, s.List())
+	assert.Equal(t, "rain", d.Schedule)
+	assert.True(t, changed)
+
+	// The condition flapping back does not switch before 30 minutes.
+	later := start.Add(10 * time.Minute)
+	d, changed = w.Apply("tv", resolve(later, ""), later, s.List())
+	assert.Equal(t, "rain", d.Schedule)
+	assert.Equal(t, "rain-album", d.Album)
+	require.NotNil(t, d.Entry)
+	assert.Equal(t, start.Add(30*time.Minute), d.Until)
+	assert.False(t, changed)
+
+	// Other keys are held separately.
+	d, _ = w.Apply("den", resolve(later, ""), later, s.List())
+	assert.Equal(t, "sun", d.Schedule)
+
+	// The hold survives a restart.
+	w = NewDwell(w.Holds())
+	d, _ = w.Apply("tv", resolve(later, ""), later, s.List())
+	assert.Equal(t, "rain", d.Schedule)
+
+	// After min_duration, the next selection wins and the hold ends.
+	end := start.Add(30 * time.Minute)
+	d, changed = w.Apply("tv", resolve(end, ""), end, s.List())
+	assert.Equal(t, "sun", d.Schedule)
+	assert.True(t, changed)
+	assert.Empty(t, w.Holds())
+
+	// A held entry that was removed is not served.
+	_, _ = w.Apply("tv", resolve(end, "rain"), end, s.List())
+	d, _ = w.Apply("tv", resolve(end, ""), end, s.List()[1:])
+	assert.Equal(t, "sun", d.Schedule)
+}
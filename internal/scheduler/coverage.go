@@ -0,0 +1,85 @@
+package scheduler
+
+// EntryCoverage describes how much of the year a schedule entry covers.
+type EntryCoverage struct {
+	Name  string `json:"name"`
+	Album string `json:"album"`
+	// Days is the number of days matched by the entry's date range.
+	Days int `json:"days"`
+	// SelectedDays is the number of days on which the entry is selected,
+	// i.e. not overridden by an earlier entry. For an entry with a when
+	// condition or a recurrence rule, it counts the days on which the entry
+	// is evaluated.
+	SelectedDays int `json:"selected_days"`
+}
+
+// Coverage summarizes which entry is selected on each day of the year.
+type Coverage struct {
+	TotalDays    int             `json:"total_days"`
+	Entries      []EntryCoverage `json:"entries"`
+	DefaultAlbum string          `json:"default_album"`
+	DefaultDays  int             `json:"default_days"`
+	// Selection holds, for each day starting at 01-01, the index of the
+	// selected entry or -1 for the default album.
+	Selection []int `json:"selection"`
+}
+
+// YearSelection returns, for every day of a leap year starting at 01-01,
+// the index of the schedule entry selected on that day, or -1 when no entry
+// matches and the default album is used. Entries with a when condition or a
+// recurrence rule are skipped, so the selection is what applies when no
+// condition holds and no rule has an occurrence.
+func (s *Scheduler) YearSelection() []int {
+	return s.table.Load().yearSelection()
+}
+
+// yearSelection computes YearSelection for the table.
+func (t *table) yearSelection() []int {
+	selection := make([]int, daysInYear)
+	for day, m := range t.matches {
+		selection[day] = -1
+		for _, i := range m {
+			if r := &t.ranges[i]; r.when == nil && r.rrule == nil {
+				selection[day] = i
+				break
+			}
+		}
+	}
+	return selection
+}
+
+// Coverage computes per-entry coverage over a leap year.
+func (s *Scheduler) Coverage() Coverage {
+	t := s.table.Load()
+	selection := t.yearSelection()
+
+	c := Coverage{
+		TotalDays:    daysInYear,
+		Entries:      make([]EntryCoverage, len(t.ranges)),
+		DefaultAlbum: t.defaultAlbum,
+		Selection:    selection,
+	}
+	for i, r := range t.ranges {
+		c.Entries[i] = EntryCoverage{Name: r.name, Album: t.entries[i].AlbumLabel()}
+		for doy := 1; doy <= daysInYear; doy++ {
+			if r.covers(doy) {
+				c.Entries[i].Days++
+			}
+		}
+	}
+	for day, idx := range selection {
+		if idx < 0 {
+			c.DefaultDays++
+		} else {
+			c.Entries[idx].SelectedDays++
+		}
+		// Conditional entries before the selected one are evaluated first.
+		for _, i := range t.matches[day] {
+			if i == idx {
+				break
+			}
+			c.Entries[i].SelectedDays++
+		}
+	}
+	return c
+}
@@ -0,0 +1,92 @@
+package scheduler
+
+import (
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+)
+
+// maxHolds bounds the decisions a Dwell keeps, since keys can come from
+// clients, e.g. device names.
+const maxHolds = 1024
+
+// Hold is a selection kept until Until, the min_duration of its entry
+// after Since.
+type Hold struct {
+	Schedule string    `json:"schedule"`
+	Album    string    `json:"album"`
+	Since    time.Time `json:"since"`
+	Until    time.Time `json:"until"`
+}
+
+// Dwell keeps a selection for at least the min_duration of its entry, so
+// that conditions flapping back and forth do not switch the album every
+// minute. It holds one selection per key, e.g. per display, and is safe for
+// concurrent use.
+type Dwell struct {
+	mu   sync.Mutex
+	held map[string]Hold
+}
+
+// NewDwell returns a Dwell keeping the given holds, e.g. saved before a
+// restart.
+func NewDwell(held map[string]Hold) *Dwell {
+	w := &Dwell{held: maps.Clone(held)}
+	if w.held == nil {
+		w.held = make(map[string]Hold)
+	}
+	return w
+}
+
+// Holds returns a copy of the holds, for saving.
+func (w *Dwell) Holds() map[string]Hold {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return maps.Clone(w.held)
+}
+
+// Apply returns d, the decision for key at now, unless another selection is
+// held for key until after now: then it returns that selection, with Until
+// set to the end of the hold. A new selection starts a hold when its entry
+// has a min_duration. entries are the backend's entries, in which a held
+// selection must still exist. changed reports whether the holds changed.
+func (w *Dwell) Apply(key string, d Decision, now time.Time, entries []config.ScheduleEntry) (_ Decision, changed bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	h, ok := w.held[key]
+	if ok && h.Schedule == d.Schedule && h.Album == d.Album {
+		return d, false
+	}
+	if ok && now.Before(h.Until) {
+		i := slices.IndexFunc(entries, func(e config.ScheduleEntry) bool { return e.Name == h.Schedule })
+		if i >= 0 && slices.Contains(entries[i].AlbumIDs(), h.Album) {
+			return Decision{Schedule: h.Schedule, Album: h.Album, Entry: &entries[i], Until: h.Until}, false
+		}
+	}
+
+	var dwell time.Duration
+	if d.Entry != nil {
+		dwell = d.Entry.MinDwell()
+	}
+	if dwell <= 0 {
+		delete(w.held, key)
+		return d, ok
+	}
+	if !ok && len(w.held) >= maxHolds {
+		w.prune(now)
+		if len(w.held) >= maxHolds {
+			return d, false
+		}
+	}
+	w.held[key] = Hold{Schedule: d.Schedule, Album: d.Album, Since: now, Until: now.Add(dwell)}
+	return d, true
+}
+
+// prune drops the holds that ended before now. The caller must hold w.mu.
+func (w *Dwell) prune(now time.Time) {
+	maps.DeleteFunc(w.held, func(_ string, h Hold) bool { return !now.Before(h.Until) })
+}
@@ -2,12 +2,18 @@
 package scheduler
 
 import (
+	"errors"
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/rrule"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/rules"
 )
 
 // dateRange represents a parsed schedule entry with month/day values.
@@ -19,31 +25,110 @@ type dateRange struct {
 	endMonth   int
 	endDay     int
 	wrapsYear  bool // true if the range crosses year boundary (e.g., Nov-Jan)
+	// when is the entry's condition, or nil when the range alone decides.
+	when *rules.Expr
+	// rrule limits the range to the days of its occurrences, each lasting
+	// days days. possible marks the days of the year (1-366) an occurrence
+	// can cover in some year; the rule decides for a given date.
+	rrule    *rrule.Rule
+	days     int
+	possible []bool
+	// albums replaces album for entries rotating every rotate.
+	albums []string
+	rotate time.Duration
 }
 
+// daysInMonth holds the days in each month (1-indexed), allowing 29 for February.
+var daysInMonth = []int{0, 31, 29, 31, 30, 31, 30, 31, 31, 30, 31, 30, 31}
+
+// ErrEntryNotFound is returned when a mutation names an entry that does not exist.
+var ErrEntryNotFound = errors.New("schedule entry not found")
+
+// ErrEntryExists is returned when a mutation would give two entries the same name.
+var ErrEntryExists = errors.New("schedule entry already exists")
+
 // Scheduler determines which album to display based on the current date.
+// It is safe for concurrent use: lookups read an immutable table that
+// mutations replace atomically, so a lookup never sees a partial change.
 type Scheduler struct {
+	// mu serializes mutations; lookups do not take it.
+	mu    sync.Mutex
+	table atomic.Pointer[table]
+	// sources supplies external state to when conditions by source name.
+	sources atomic.Pointer[map[string]func() map[string]string]
+	// endBoundary is the end_boundary of entries that do not set their own.
+	endBoundary string
+}
+
+// table is the immutable schedule state of a Scheduler.
+type table struct {
 	defaultAlbum string
+	entries      []config.ScheduleEntry
 	ranges       []dateRange
+	warnings     []Warning
+	// matches holds, for each day of a leap year starting at 01-01, the
+	// indices of the ranges including that day in evaluation order, so
+	// lookups do not scan all ranges.
+	matches [daysInYear][]int
+	// conditional is set when any entry has a when condition.
+	conditional bool
 }
 
 // New creates a new Scheduler from the given configuration.
 func New(cfg *config.Config) (*Scheduler, error) {
-	s := &Scheduler{
-		defaultAlbum: cfg.DefaultAlbum,
-		ranges:       make([]dateRange, 0, len(cfg.Schedule)),
+	t, err := newTable(cfg.DefaultAlbum, cfg.EndBoundary, cfg.Schedule)
+	if err != nil {
+		return nil, err
+	}
+	s := &Scheduler{endBoundary: cfg.EndBoundary}
+	s.table.Store(t)
+	return s, nil
+}
+
+// newTable parses the entries and builds the lookup index. endBoundary is
+// the end_boundary of entries that do not set their own.
+func newTable(defaultAlbum, endBoundary string, entries []config.ScheduleEntry) (*table, error) {
+	t := &table{
+		defaultAlbum: defaultAlbum,
+		entries:      slices.Clone(entries),
+		ranges:       make([]dateRange, 0, len(entries)),
 	}
 
-	for _, entry := range cfg.Schedule {
-		startMonth, startDay, err := ParseMonthDay(entry.Start)
+	for _, entry := range entries {
+		start, end := entry.Start, entry.End
+		if entry.RRule != "" && start == "" && end == "" {
+			start, end = "01-01", "12-31"
+		}
+		startMonth, startDay, err := ParseMonthDay(start)
 		if err != nil {
 			return nil, fmt.Errorf("invalid start date for %q: %w", entry.Name, err)
 		}
 
-		endMonth, endDay, err := ParseMonthDay(entry.End)
+		endMonth, endDay, err := ParseMonthDay(end)
 		if err != nil {
 			return nil, fmt.Errorf("invalid end date for %q: %w", entry.Name, err)
 		}
+		if entry.EndExclusive(endBoundary) {
+			// The range ends the day before: in a leap year on 02-29 for
+			// an end of 03-01, which other years skip.
+			if start == end {
+				return nil, fmt.Errorf("entry %q covers no days: its exclusive end equals its start", entry.Name)
+			}
+			doy := monthDayToDOY(endMonth, endDay) - 1
+			if doy == 0 {
+				doy = daysInYear
+			}
+			endMonth, endDay = doyToMonthDay(doy)
+		}
+
+		var when *rules.Expr
+		if entry.When != "" {
+			when, err = rules.Compile(entry.When)
+			if err != nil {
+				return nil, fmt.Errorf("invalid when for %q: %w", entry.Name, err)
+			}
+			t.conditional = true
+		}
 
 		dr := dateRange{
 			name:       entry.Name,
@@ -53,12 +138,157 @@ func New(cfg *config.Config) (*Scheduler, error) {
 			endMonth:   endMonth,
 			endDay:     endDay,
 			wrapsYear:  isYearWrap(startMonth, startDay, endMonth, endDay),
+			when:       when,
+			albums:     slices.Clone(entry.Albums),
+			rotate:     time.Duration(entry.RotateMinutes) * time.Minute,
+		}
+		if entry.RRule != "" {
+			if dr.rrule, err = rrule.Parse(entry.RRule); err != nil {
+				return nil, fmt.Errorf("invalid rrule for %q: %w", entry.Name, err)
+			}
+			dr.days = max(entry.Days, 1)
+			dr.possible = possibleDays(dr.rrule, dr.days)
 		}
 
-		s.ranges = append(s.ranges, dr)
+		t.ranges = append(t.ranges, dr)
 	}
 
-	return s, nil
+	t.warnings = analyze(t.ranges)
+	t.index()
+
+	return t, nil
+}
+
+// index fills the per-day match lists. Each range only visits its own days,
+// so building the index is linear in the total length of all ranges.
+func (t *table) index() {
+	for i, r := range t.ranges {
+		start := monthDayToDOY(r.startMonth, r.startDay)
+		end := monthDayToDOY(r.endMonth, r.endDay)
+		if r.wrapsYear {
+			end += daysInYear
+		}
+		for doy := start; doy <= end; doy++ {
+			day := (doy - 1) % daysInYear
+			if r.possible == nil || r.possible[day+1] {
+				t.matches[day] = append(t.matches[day], i)
+			}
+		}
+	}
+}
+
+// Entries returns a copy of the schedule entries in evaluation order.
+func (s *Scheduler) Entries() []config.ScheduleEntry {
+	return slices.Clone(s.table.Load().entries)
+}
+
+// Replace swaps in a new default album and schedule.
+func (s *Scheduler) Replace(defaultAlbum string, entries []config.ScheduleEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.store(defaultAlbum, entries)
+}
+
+// SetDefaultAlbum changes the album used when no entry matches.
+func (s *Scheduler) SetDefaultAlbum(album string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.store(album, s.table.Load().entries)
+}
+
+// Add appends an entry, evaluated after the existing ones.
+func (s *Scheduler) Add(entry config.ScheduleEntry) error {
+	if err := entry.Validate(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := s.table.Load()
+	if entryIndex(t.entries, entry.Name) >= 0 {
+		return fmt.Errorf("%w: %q", ErrEntryExists, entry.Name)
+	}
+	return s.store(t.defaultAlbum, append(slices.Clone(t.entries), entry))
+}
+
+// Update replaces the entry with the given name, keeping its position.
+func (s *Scheduler) Update(name string, entry config.ScheduleEntry) error {
+	if err := entry.Validate(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := s.table.Load()
+	i := entryIndex(t.entries, name)
+	if i < 0 {
+		return fmt.Errorf("%w: %q", ErrEntryNotFound, name)
+	}
+	if entry.Name != name && entryIndex(t.entries, entry.Name) >= 0 {
+		return fmt.Errorf("%w: %q", ErrEntryExists, entry.Name)
+	}
+	entries := slices.Clone(t.entries)
+	entries[i] = entry
+	return s.store(t.defaultAlbum, entries)
+}
+
+// Remove deletes the entry with the given name.
+func (s *Scheduler) Remove(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := s.table.Load()
+	i := entryIndex(t.entries, name)
+	if i < 0 {
+		return fmt.Errorf("%w: %q", ErrEntryNotFound, name)
+	}
+	return s.store(t.defaultAlbum, slices.Delete(slices.Clone(t.entries), i, i+1))
+}
+
+// Move moves the entry with the given name to a position in evaluation
+// order, shifting the entries in between.
+func (s *Scheduler) Move(name string, position int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := s.table.Load()
+	i := entryIndex(t.entries, name)
+	if i < 0 {
+		return fmt.Errorf("%w: %q", ErrEntryNotFound, name)
+	}
+	if position < 0 || position >= len(t.entries) {
+		return fmt.Errorf("position must be between 0 and %d", len(t.entries)-1)
+	}
+	if i == position {
+		return nil
+	}
+	entries := slices.Delete(slices.Clone(t.entries), i, i+1)
+	return s.store(t.defaultAlbum, slices.Insert(entries, position, t.entries[i]))
+}
+
+// Clone returns a Scheduler serving the same schedule and sources, whose
+// mutations do not affect s, so a change can be prepared before it is
+// served.
+func (s *Scheduler) Clone() *Scheduler {
+	c := &Scheduler{endBoundary: s.endBoundary}
+	c.table.Store(s.table.Load())
+	c.sources.Store(s.sources.Load())
+	return c
+}
+
+// store builds and publishes a new table. The caller must hold s.mu.
+func (s *Scheduler) store(defaultAlbum string, entries []config.ScheduleEntry) error {
+	t, err := newTable(defaultAlbum, s.endBoundary, entries)
+	if err != nil {
+		return err
+	}
+	s.table.Store(t)
+	return nil
+}
+
+// entryIndex returns the index of the entry with the given name, or -1.
+func entryIndex(entries []config.ScheduleEntry, name string) int {
+	return slices.IndexFunc(entries, func(e config.ScheduleEntry) bool { return e.Name == name })
 }
 
 // ParseMonthDay parses a MM-DD string into month and day integers.
@@ -92,8 +322,6 @@ func isYearWrap(startMonth, startDay, endMonth, endDay int) bool {
 // monthDayToDOY converts a month/day to a day-of-year number (1-366).
 // This is used for date comparisons without worrying about the actual year.
 func monthDayToDOY(month, day int) int {
-	// Days in each month (using non-leap year, but allowing 29 for Feb)
-	daysInMonth := []int{0, 31, 29, 31, 30, 31, 30, 31, 31, 30, 31, 30, 31}
 	doy := 0
 	for m := 1; m < month; m++ {
 		doy += daysInMonth[m]
@@ -110,17 +338,7 @@ func (s *Scheduler) GetCurrentAlbum() string {
 // It evaluates schedules in order and returns the first match.
 // If no schedule matches, it returns the default album.
 func (s *Scheduler) GetAlbumForDate(t time.Time) string {
-	month := int(t.Month())
-	day := t.Day()
-	currentDOY := monthDayToDOY(month, day)
-
-	for _, r := range s.ranges {
-		if s.dateInRange(currentDOY, r) {
-			return r.album
-		}
-	}
-
-	return s.defaultAlbum
+	return s.Resolve(t).Album
 }
 
 // GetCurrentScheduleName returns the name of the current schedule (or "default").
@@ -131,21 +349,79 @@ func (s *Scheduler) GetCurrentScheduleName() string {
 // GetScheduleNameForDate returns the name of the matching schedule for the given date.
 // Returns "default" if no schedule matches.
 func (s *Scheduler) GetScheduleNameForDate(t time.Time) string {
-	month := int(t.Month())
-	day := t.Day()
-	currentDOY := monthDayToDOY(month, day)
+	return s.Resolve(t).Schedule
+}
+
+// GetMatchingSchedulesForDate returns the indices of all schedules whose range
+// includes the given date, in evaluation order. The first one is selected;
+// the others are overridden by it. Indices refer to Entries.
+func (s *Scheduler) GetMatchingSchedulesForDate(t time.Time) []int {
+	tbl := s.table.Load()
+	return slices.DeleteFunc(slices.Clone(tbl.matches[monthDayToDOY(int(t.Month()), t.Day())-1]), func(i int) bool {
+		return !tbl.ranges[i].occurs(t)
+	})
+}
+
+// NextChange returns the start of the first day after t on which a
+// different schedule is selected, and that schedule's name. It reports false
+// when the same schedule applies all year.
+func (s *Scheduler) NextChange(t time.Time) (time.Time, string, bool) {
+	at, d, ok := s.NextTransition(t)
+	return at, d.Schedule, ok
+}
+
+// recurrenceYears is how many years possibleDays evaluates rules over. The
+// Gregorian calendar repeats its weekdays and leap years every 28 years
+// between 1901 and 2099, so these years show every day a rule without an
+// INTERVAL can select.
+const recurrenceYears = 28
+
+// possibleDays returns, indexed by day of the year (1-366), the days an
+// occurrence of r lasting days days can cover in some year. Rules with a
+// DTSTART are evaluated from its year. It returns nil, every day being
+// possible, for rules with an INTERVAL, whose occurrences need not repeat
+// within recurrenceYears.
+func possibleDays(r *rrule.Rule, days int) []bool {
+	if r.Interval() > 1 {
+		return nil
+	}
+	possible := make([]bool, daysInYear+1)
+	start := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	if dtstart, ok := r.Start(); ok {
+		start = time.Date(dtstart.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	for _, d := range r.Between(start, start.AddDate(recurrenceYears, 0, 0)) {
+		for k := range days {
+			day := d.AddDate(0, 0, k)
+			possible[monthDayToDOY(int(day.Month()), day.Day())] = true
+		}
+	}
+	return possible
+}
 
-	for _, r := range s.ranges {
-		if s.dateInRange(currentDOY, r) {
-			return r.name
+// occurs reports whether the range's recurrence rule, if any, has an
+// occurrence covering the day of t.
+func (r *dateRange) occurs(t time.Time) bool {
+	if r.rrule == nil {
+		return true
+	}
+	for k := range r.days {
+		if r.rrule.Includes(t.AddDate(0, 0, -k)) {
+			return true
 		}
 	}
+	return false
+}
 
-	return "default"
+// covers reports whether the range can include the day of the year doy:
+// whether the date range includes it and, for ranges with a recurrence
+// rule, whether an occurrence can fall on it.
+func (r *dateRange) covers(doy int) bool {
+	return dateInRange(doy, *r) && (r.possible == nil || r.possible[doy])
 }
 
 // dateInRange checks if a day-of-year falls within the given date range.
-func (s *Scheduler) dateInRange(currentDOY int, r dateRange) bool {
+func dateInRange(currentDOY int, r dateRange) bool {
 	startDOY := monthDayToDOY(r.startMonth, r.startDay)
 	endDOY := monthDayToDOY(r.endMonth, r.endDay)
 
@@ -161,10 +437,58 @@ func (s *Scheduler) dateInRange(currentDOY int, r dateRange) bool {
 
 // GetDefaultAlbum returns the default album ID.
 func (s *Scheduler) GetDefaultAlbum() string {
-	return s.defaultAlbum
+	return s.table.Load().defaultAlbum
+}
+
+// Conditional reports whether any entry has a when condition, in which case
+// decisions can depend on the time of day and the requesting device.
+func (s *Scheduler) Conditional() bool {
+	return s.table.Load().conditional
+}
+
+// SetSource makes the state returned by fn available to when conditions
+// under the source name, e.g. rules.SourceHomeAssistant.
+func (s *Scheduler) SetSource(source string, fn func() map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sources := map[string]func() map[string]string{source: fn}
+	if current := s.sources.Load(); current != nil {
+		for name, f := range *current {
+			if name != source {
+				sources[name] = f
+			}
+		}
+	}
+	s.sources.Store(&sources)
+}
+
+// References returns the keys when conditions read from source, sorted.
+func (s *Scheduler) References(source string) []string {
+	var keys []string
+	for _, r := range s.table.Load().ranges {
+		if r.when != nil {
+			keys = append(keys, r.when.References(source)...)
+		}
+	}
+	slices.Sort(keys)
+	return slices.Compact(keys)
+}
+
+// env adds the state of the configured sources to env.
+func (s *Scheduler) env(env rules.Env) rules.Env {
+	sources := s.sources.Load()
+	if env.Sources != nil || sources == nil {
+		return env
+	}
+	env.Sources = make(map[string]map[string]string, len(*sources))
+	for name, fn := range *sources {
+		env.Sources[name] = fn()
+	}
+	return env
 }
 
 // GetScheduleCount returns the number of configured schedules.
 func (s *Scheduler) GetScheduleCount() int {
-	return len(s.ranges)
+	return len(s.table.Load().ranges)
 }
@@ -1,6 +1,8 @@
 package scheduler
 
 import (
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 
@@ -157,6 +159,51 @@ func TestScheduler_GetAlbum_FirstMatchWins(t *testing.T) {
 	assert.Equal(t, "christmas-album", album)
 }
 
+func TestScheduler_GetMatchingSchedulesForDate(t *testing.T) {
+	cfg := &config.Config{
+		DefaultAlbum: "default-album",
+		Schedule: []config.ScheduleEntry{
+			{Name: "special", Album: "special-album", Start: "12-20", End: "12-26"},
+			{Name: "christmas", Album: "christmas-album", Start: "11-15", End: "01-01"},
+		},
+	}
+
+	s, err := New(cfg)
+	require.NoError(t, err)
+
+	assert.Equal(t, []int{0, 1}, s.GetMatchingSchedulesForDate(time.Date(2024, 12, 25, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, []int{1}, s.GetMatchingSchedulesForDate(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)))
+	assert.Empty(t, s.GetMatchingSchedulesForDate(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestScheduler_NextChange(t *testing.T) {
+	cfg := &config.Config{
+		DefaultAlbum: "default-album",
+		Schedule: []config.ScheduleEntry{
+			{Name: "special", Album: "special-album", Start: "12-20", End: "12-26"},
+			{Name: "christmas", Album: "christmas-album", Start: "11-15", End: "01-01"},
+		},
+	}
+
+	s, err := New(cfg)
+	require.NoError(t, err)
+
+	at, name, ok := s.NextChange(time.Date(2024, 12, 10, 15, 30, 0, 0, time.UTC))
+	require.True(t, ok)
+	assert.Equal(t, time.Date(2024, 12, 20, 0, 0, 0, 0, time.UTC), at)
+	assert.Equal(t, "special", name)
+
+	at, name, ok = s.NextChange(time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC))
+	require.True(t, ok)
+	assert.Equal(t, time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC), at)
+	assert.Equal(t, "default", name)
+
+	empty, err := New(&config.Config{DefaultAlbum: "default-album"})
+	require.NoError(t, err)
+	_, _, ok = empty.NextChange(time.Now())
+	assert.False(t, ok)
+}
+
 func TestScheduler_GetCurrentAlbum(t *testing.T) {
 	cfg := &config.Config{
 		DefaultAlbum: "default-album",
@@ -203,3 +250,319 @@ func TestScheduler_EmptySchedule(t *testing.T) {
 	album := s.GetAlbumForDate(time.Date(2024, 7, 15, 0, 0, 0, 0, time.UTC))
 	assert.Equal(t, "default-album", album)
 }
+
+func TestScheduler_Warnings_Overlap(t *testing.T) {
+	cfg := &config.Config{
+		DefaultAlbum: "default-album",
+		Schedule: []config.ScheduleEntry{
+			{Name: "special", Album: "special-album", Start: "12-20", End: "12-26"},
+			{Name: "christmas", Album: "christmas-album", Start: "11-15", End: "01-01"},
+			{Name: "rest", Album: "rest-album", Start: "01-02", End: "11-14"},
+		},
+	}
+
+	s, err := New(cfg)
+	require.NoError(t, err)
+
+	warnings := s.Warnings()
+	require.Len(t, warnings, 1)
+	assert.Equal(t, WarningOverlap, warnings[0].Kind)
+	assert.Equal(t, []string{"special", "christmas"}, warnings[0].Entries)
+	assert.Equal(t, "12-20", warnings[0].Start)
+	assert.Equal(t, "12-26", warnings[0].End)
+	assert.Contains(t, warnings[0].Message, `"special" wins`)
+}
+
+func TestScheduler_Warnings_Gaps(t *testing.T) {
+	cfg := &config.Config{
+		DefaultAlbum: "default-album",
+		Schedule: []config.ScheduleEntry{
+			{Name: "spring", Album: "spring-album", Start: "03-20", End: "06-20"},
+			{Name: "fall", Album: "fall-album", Start: "09-22", End: "11-14"},
+		},
+	}
+
+	s, err := New(cfg)
+	require.NoError(t, err)
+
+	var gaps []Warning
+	for _, w := range s.Warnings() {
+		if w.Kind == WarningGap {
+			gaps = append(gaps, w)
+		}
+	}
+
+	// The gap around new year is reported as a single wrapping range
+	require.Len(t, gaps, 2)
+	assert.Equal(t, "06-21", gaps[0].Start)
+	assert.Equal(t, "09-21", gaps[0].End)
+	assert.Equal(t, "11-15", gaps[1].Start)
+	assert.Equal(t, "03-19", gaps[1].End)
+}
+
+func TestScheduler_Warnings_FullCoverage(t *testing.T) {
+	cfg := &config.Config{
+		DefaultAlbum: "favorites",
+		Schedule: []config.ScheduleEntry{
+			{Name: "christmas", Album: "christmas-album", Start: "11-15", End: "01-01"},
+			{Name: "winter", Album: "winter-album", Start: "01-02", End: "03-19"},
+			{Name: "spring", Album: "spring-album", Start: "03-20", End: "06-20"},
+			{Name: "summer", Album: "summer-album", Start: "06-21", End: "09-21"},
+			{Name: "fall", Album: "fall-album", Start: "09-22", End: "11-14"},
+		},
+	}
+
+	s, err := New(cfg)
+	require.NoError(t, err)
+	assert.Empty(t, s.Warnings())
+}
+
+func TestScheduler_Warnings_DuplicatesAndShadowed(t *testing.T) {
+	cfg := &config.Config{
+		DefaultAlbum: "default-album",
+		Schedule: []config.ScheduleEntry{
+			{Name: "christmas", Album: "christmas-album", Start: "11-15", End: "01-01"},
+			{Name: "xmas-eve", Album: "eve-album", Start: "12-24", End: "12-24"},
+			{Name: "snow", Album: "christmas-album", Start: "12-15", End: "01-31"},
+			{Name: "snow", Album: "snow-album", Start: "02-01", End: "02-28"},
+			{Name: "weekend", Album: "weekend-album", Start: "12-01", End: "12-31", When: `weekday in ["sat", "sun"]`},
+		},
+	}
+
+	s, err := New(cfg)
+	require.NoError(t, err)
+
+	byKind := make(map[string][]Warning)
+	for _, w := range s.Warnings() {
+		byKind[w.Kind] = append(byKind[w.Kind], w)
+	}
+
+	require.Len(t, byKind[WarningDuplicateName], 1)
+	assert.Equal(t, []string{"snow"}, byKind[WarningDuplicateName][0].Entries)
+	assert.Empty(t, byKind[WarningDuplicateName][0].Start)
+
+	require.Len(t, byKind[WarningSameAlbum], 1)
+	same := byKind[WarningSameAlbum][0]
+	assert.Equal(t, []string{"christmas", "snow"}, same.Entries)
+	assert.Equal(t, "12-15", same.Start)
+	assert.Equal(t, "01-01", same.End)
+	assert.Contains(t, same.Message, `"christmas-album"`)
+
+	// Christmas is selected before the conditional entry is evaluated on
+	// all of its days; snow still has January.
+	require.Len(t, byKind[WarningShadowed], 2)
+	assert.Equal(t, []string{"xmas-eve", "christmas"}, byKind[WarningShadowed][0].Entries)
+	assert.Equal(t, "12-24", byKind[WarningShadowed][0].Start)
+	assert.Equal(t, []string{"weekend", "christmas"}, byKind[WarningShadowed][1].Entries)
+}
+
+func TestScheduler_EndExclusive(t *testing.T) {
+	cfg := &config.Config{
+		DefaultAlbum: "default-album",
+		EndBoundary:  config.EndExclusive,
+		Schedule: []config.ScheduleEntry{
+			{Name: "winter", Album: "winter-album", Start: "12-21", End: "03-01"},
+			{Name: "spring", Album: "spring-album", Start: "03-01", End: "06-21"},
+			{Name: "summer", Album: "summer-album", Start: "06-21", End: "09-22", EndBoundary: config.EndInclusive},
+		},
+	}
+
+	s, err := New(cfg)
+	require.NoError(t, err)
+
+	tests := []struct {
+		date time.Time
+		want string
+	}{
+		{time.Date(2024, 2, 29, 23, 59, 0, 0, time.UTC), "winter-album"},
+		{time.Date(2025, 2, 28, 23, 59, 0, 0, time.UTC), "winter-album"},
+		{time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), "spring-album"},
+		{time.Date(2025, 6, 20, 0, 0, 0, 0, time.UTC), "spring-album"},
+		{time.Date(2025, 6, 21, 0, 0, 0, 0, time.UTC), "summer-album"},
+		{time.Date(2025, 9, 22, 0, 0, 0, 0, time.UTC), "summer-album"},
+		{time.Date(2025, 9, 23, 0, 0, 0, 0, time.UTC), "default-album"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, s.GetAlbumForDate(tt.date), tt.date.Format("2006-01-02"))
+	}
+
+	// Adjacent entries sharing a boundary day do not overlap.
+	for _, w := range s.Warnings() {
+		assert.NotEqual(t, WarningOverlap, w.Kind, w.Message)
+	}
+
+	// Entries added later use the same default boundary.
+	require.NoError(t, s.Add(config.ScheduleEntry{Name: "fall", Album: "fall-album", Start: "09-23", End: "12-21"}))
+	assert.Equal(t, "winter-album", s.GetAlbumForDate(time.Date(2025, 12, 21, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, "fall-album", s.GetAlbumForDate(time.Date(2025, 12, 20, 0, 0, 0, 0, time.UTC)))
+}
+
+func TestScheduler_Warnings_EmptySchedule(t *testing.T) {
+	s, err := New(&config.Config{DefaultAlbum: "default-album"})
+	require.NoError(t, err)
+
+	// A default-only configuration is intentional, not a gap
+	assert.Empty(t, s.Warnings())
+}
+
+func TestScheduler_Coverage(t *testing.T) {
+	cfg := &config.Config{
+		DefaultAlbum: "default-album",
+		Schedule: []config.ScheduleEntry{
+			{Name: "special", Album: "special-album", Start: "12-20", End: "12-26"},
+			{Name: "christmas", Album: "christmas-album", Start: "11-15", End: "01-01"},
+		},
+	}
+
+	s, err := New(cfg)
+	require.NoError(t, err)
+
+	c := s.Coverage()
+	assert.Equal(t, 366, c.TotalDays)
+	require.Len(t, c.Entries, 2)
+	assert.Equal(t, 7, c.Entries[0].Days)
+	assert.Equal(t, 7, c.Entries[0].SelectedDays)
+	assert.Equal(t, 48, c.Entries[1].Days)
+	assert.Equal(t, 41, c.Entries[1].SelectedDays)
+	assert.Equal(t, 366-48, c.DefaultDays)
+	assert.Equal(t, "default-album", c.DefaultAlbum)
+
+	require.Len(t, c.Selection, 366)
+	assert.Equal(t, 1, c.Selection[0])   // 01-01
+	assert.Equal(t, -1, c.Selection[1])  // 01-02
+	assert.Equal(t, 0, c.Selection[354]) // 12-20
+}
+
+func TestScheduler_Mutations(t *testing.T) {
+	s, err := New(&config.Config{
+		DefaultAlbum: "default",
+		Schedule:     []config.ScheduleEntry{{Name: "christmas", Album: "christmas", Start: "11-15", End: "01-01"}},
+	})
+	require.NoError(t, err)
+	christmas := time.Date(2024, 12, 24, 0, 0, 0, 0, time.UTC)
+	summer := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	require.NoError(t, s.Add(config.ScheduleEntry{Name: "summer", Album: "summer", Start: "06-21", End: "09-21"}))
+	assert.Equal(t, "summer", s.GetAlbumForDate(summer))
+	assert.Equal(t, 2, s.GetScheduleCount())
+	assert.ErrorIs(t, s.Add(config.ScheduleEntry{Name: "summer", Album: "other", Start: "06-21", End: "09-21"}), ErrEntryExists)
+	assert.Error(t, s.Add(config.ScheduleEntry{Name: "broken", Album: "x", Start: "13-01", End: "01-01"}))
+
+	require.NoError(t, s.Update("christmas", config.ScheduleEntry{Name: "christmas", Album: "advent", Start: "12-01", End: "12-24"}))
+	assert.Equal(t, "advent", s.GetAlbumForDate(christmas))
+	assert.Equal(t, "christmas", s.Entries()[0].Name)
+	assert.ErrorIs(t, s.Update("easter", config.ScheduleEntry{Name: "easter", Album: "x", Start: "04-01", End: "04-02"}), ErrEntryNotFound)
+
+	require.NoError(t, s.Move("summer", 0))
+	assert.Equal(t, []string{"summer", "christmas"}, []string{s.Entries()[0].Name, s.Entries()[1].Name})
+	assert.Error(t, s.Move("summer", 2))
+	assert.ErrorIs(t, s.Move("easter", 0), ErrEntryNotFound)
+
+	clone := s.Clone()
+	require.NoError(t, clone.Remove("christmas"))
+	assert.Equal(t, 1, clone.GetScheduleCount())
+	assert.Equal(t, 2, s.GetScheduleCount(), "mutating a clone leaves the original unchanged")
+
+	require.NoError(t, s.SetDefaultAlbum("fallback"))
+	require.NoError(t, s.Remove("summer"))
+	assert.Equal(t, "fallback", s.GetAlbumForDate(summer))
+	assert.ErrorIs(t, s.Remove("summer"), ErrEntryNotFound)
+
+	require.NoError(t, s.Replace("new-default", nil))
+	assert.Equal(t, "new-default", s.GetAlbumForDate(christmas))
+	assert.Empty(t, s.Entries())
+}
+
+func TestScheduler_ConcurrentMutations(t *testing.T) {
+	s, err := New(&config.Config{DefaultAlbum: "default"})
+	require.NoError(t, err)
+	day := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	var wg sync.WaitGroup
+	for i := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 10 {
+				name := fmt.Sprintf("entry-%d-%d", i, j)
+				assert.NoError(t, s.Add(config.ScheduleEntry{Name: name, Album: name, Start: "06-01", End: "07-31"}))
+				_ = s.GetAlbumForDate(day)
+				_ = s.Coverage()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 40, s.GetScheduleCount())
+	assert.Len(t, s.GetMatchingSchedulesForDate(day), 40)
+}
+
+// generatedConfig returns a configuration with n short, partly overlapping
+// entries spread over the year, like auto-generated per-event schedules.
+func generatedConfig(n int) *config.Config {
+	cfg := &config.Config{DefaultAlbum: "default"}
+	for i := range n {
+		start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, (i*37)%366)
+		end := start.AddDate(0, 0, i%20)
+		cfg.Schedule = append(cfg.Schedule, config.ScheduleEntry{
+			Name:  fmt.Sprintf("event-%d", i),
+			Album: fmt.Sprintf("album-%d", i),
+			Start: start.Format("01-02"),
+			End:   end.Format("01-02"),
+		})
+	}
+	return cfg
+}
+
+func TestScheduler_IndexMatchesLinearScan(t *testing.T) {
+	cfg := generatedConfig(300)
+	cfg.Schedule = append(cfg.Schedule, config.ScheduleEntry{Name: "winter", Album: "winter", Start: "12-01", End: "02-29"})
+	s, err := New(cfg)
+	require.NoError(t, err)
+
+	ranges := s.table.Load().ranges
+	for day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC); day.Year() == 2024; day = day.AddDate(0, 0, 1) {
+		doy := monthDayToDOY(int(day.Month()), day.Day())
+		var want []int
+		for i, r := range ranges {
+			if dateInRange(doy, r) {
+				want = append(want, i)
+			}
+		}
+		assert.Equal(t, want, s.GetMatchingSchedulesForDate(day), day.Format("01-02"))
+
+		wantAlbum := "default"
+		if len(want) > 0 {
+			wantAlbum = ranges[want[0]].album
+		}
+		assert.Equal(t, wantAlbum, s.GetAlbumForDate(day), day.Format("01-02"))
+	}
+}
+
+func BenchmarkScheduler_GetAlbumForDate(b *testing.B) {
+	for _, n := range []int{10, 100, 1000} {
+		b.Run(fmt.Sprintf("entries=%d", n), func(b *testing.B) {
+			s, err := New(generatedConfig(n))
+			require.NoError(b, err)
+			day := time.Date(2024, 8, 15, 12, 0, 0, 0, time.UTC)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for range b.N {
+				_ = s.GetAlbumForDate(day)
+			}
+		})
+	}
+}
+
+func BenchmarkScheduler_NextChange(b *testing.B) {
+	s, err := New(generatedConfig(1000))
+	require.NoError(b, err)
+	day := time.Date(2024, 8, 15, 12, 0, 0, 0, time.UTC)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		_, _, _ = s.NextChange(day)
+	}
+}
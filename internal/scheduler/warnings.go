@@ -0,0 +1,254 @@
+package scheduler
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Warning kinds reported by schedule analysis.
+const (
+	WarningOverlap       = "overlap"
+	WarningGap           = "gap"
+	WarningDuplicateName = "duplicate_name"
+	WarningSameAlbum     = "same_album"
+	WarningShadowed      = "shadowed"
+)
+
+// daysInYear covers every month/day combination, including February 29.
+const daysInYear = 366
+
+// Warning describes a potential problem with the schedule that does not
+// prevent it from being served, such as overlapping entries or uncovered days.
+// Start and End are empty for duplicate names, which concern no days.
+type Warning struct {
+	Kind    string   `json:"kind"`
+	Message string   `json:"message"`
+	Entries []string `json:"entries,omitempty"`
+	Start   string   `json:"start,omitempty"` // Format: MM-DD
+	End     string   `json:"end,omitempty"`   // Format: MM-DD
+}
+
+// LogValue implements slog.LogValuer.
+func (w Warning) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("kind", w.Kind),
+		slog.String("message", w.Message),
+		slog.String("start", w.Start),
+		slog.String("end", w.End),
+	)
+}
+
+// dayRun is a contiguous run of days of the year, which may wrap past 12-31.
+type dayRun struct {
+	start, end int
+}
+
+// Warnings returns the warnings of the current schedule: overlaps, gaps,
+// duplicate names, albums shown by overlapping entries and entries that are
+// never selected.
+func (s *Scheduler) Warnings() []Warning {
+	return s.table.Load().warnings
+}
+
+// analyze finds overlapping entries, days not covered by any entry, entries
+// sharing a name, overlapping entries showing the same album and entries
+// shadowed by earlier ones.
+func analyze(ranges []dateRange) []Warning {
+	warnings := duplicateNames(ranges)
+
+	covered := make([][]bool, len(ranges))
+	for i, r := range ranges {
+		covered[i] = make([]bool, daysInYear+1)
+		for doy := 1; doy <= daysInYear; doy++ {
+			covered[i][doy] = r.covers(doy)
+		}
+	}
+
+	// Overlaps: the earlier entry always wins because of first-match evaluation.
+	for i := range ranges {
+		for j := i + 1; j < len(ranges); j++ {
+			both := make([]bool, daysInYear+1)
+			for doy := 1; doy <= daysInYear; doy++ {
+				both[doy] = covered[i][doy] && covered[j][doy]
+			}
+			for _, run := range findRuns(both) {
+				warnings = append(warnings, Warning{
+					Kind: WarningOverlap,
+					Message: fmt.Sprintf("entries %q and %q overlap from %s to %s; %q wins because it is listed first",
+						ranges[i].name, ranges[j].name, formatDOY(run.start), formatDOY(run.end), ranges[i].name),
+					Entries: []string{ranges[i].name, ranges[j].name},
+					Start:   formatDOY(run.start),
+					End:     formatDOY(run.end),
+				})
+				if album := sharedAlbum(ranges[i], ranges[j]); album != "" {
+					warnings = append(warnings, Warning{
+						Kind: WarningSameAlbum,
+						Message: fmt.Sprintf("entries %q and %q both show album %q and overlap from %s to %s",
+							ranges[i].name, ranges[j].name, album, formatDOY(run.start), formatDOY(run.end)),
+						Entries: []string{ranges[i].name, ranges[j].name},
+						Start:   formatDOY(run.start),
+						End:     formatDOY(run.end),
+					})
+				}
+			}
+		}
+	}
+	warnings = append(warnings, shadowed(ranges, covered)...)
+
+	// Gaps are only interesting when there is a schedule to have gaps in.
+	if len(ranges) == 0 {
+		return warnings
+	}
+
+	uncovered := make([]bool, daysInYear+1)
+	for doy := 1; doy <= daysInYear; doy++ {
+		uncovered[doy] = true
+		for i := range ranges {
+			if covered[i][doy] {
+				uncovered[doy] = false
+				break
+			}
+		}
+	}
+	for _, run := range findRuns(uncovered) {
+		warnings = append(warnings, Warning{
+			Kind: WarningGap,
+			Message: fmt.Sprintf("no entry covers %s to %s; the default album is used",
+				formatDOY(run.start), formatDOY(run.end)),
+			Start: formatDOY(run.start),
+			End:   formatDOY(run.end),
+		})
+	}
+
+	return warnings
+}
+
+// duplicateNames reports names used by more than one entry, which makes the
+// entries ambiguous in logs, metrics and the API.
+func duplicateNames(ranges []dateRange) []Warning {
+	warnings := []Warning{}
+	counts := make(map[string]int)
+	for _, r := range ranges {
+		counts[r.name]++
+	}
+	for _, r := range ranges {
+		n := counts[r.name]
+		if n < 2 {
+			continue
+		}
+		warnings = append(warnings, Warning{
+			Kind:    WarningDuplicateName,
+			Message: fmt.Sprintf("name %q is used by %d entries", r.name, n),
+			Entries: []string{r.name},
+		})
+		counts[r.name] = 0 // report each name once
+	}
+	return warnings
+}
+
+// sharedAlbum returns an album shown by both entries, or "" when they show
+// different albums.
+func sharedAlbum(a, b dateRange) string {
+	for _, album := range a.albumList() {
+		if slices.Contains(b.albumList(), album) {
+			return album
+		}
+	}
+	return ""
+}
+
+// albumList returns the albums the entry shows: its rotation, or its album.
+func (r *dateRange) albumList() []string {
+	if len(r.albums) > 0 {
+		return r.albums
+	}
+	return []string{r.album}
+}
+
+// shadowed reports entries that are never selected because, on every day
+// they cover, an earlier entry without a condition or recurrence rule is
+// selected first. covered holds the days each entry covers.
+func shadowed(ranges []dateRange, covered [][]bool) []Warning {
+	warnings := []Warning{}
+	for j := range ranges {
+		var by []string
+		selected, days := false, 0
+		for doy := 1; doy <= daysInYear && !selected; doy++ {
+			if !covered[j][doy] {
+				continue
+			}
+			days++
+			winner := slices.IndexFunc(ranges[:j], func(r dateRange) bool {
+				return r.when == nil && r.rrule == nil && r.covers(doy)
+			})
+			if winner < 0 {
+				selected = true
+			} else if !slices.Contains(by, ranges[winner].name) {
+				by = append(by, ranges[winner].name)
+			}
+		}
+		if selected || days == 0 {
+			continue
+		}
+		r := ranges[j]
+		warnings = append(warnings, Warning{
+			Kind: WarningShadowed,
+			Message: fmt.Sprintf("entry %q is never selected: every day it covers is matched first by %s",
+				r.name, quoteList(by)),
+			Entries: append([]string{r.name}, by...),
+			Start:   formatDOY(monthDayToDOY(r.startMonth, r.startDay)),
+			End:     formatDOY(monthDayToDOY(r.endMonth, r.endDay)),
+		})
+	}
+	return warnings
+}
+
+// quoteList formats names as a quoted, comma-separated list.
+func quoteList(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = strconv.Quote(name)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// findRuns groups the set days into contiguous runs. A run touching both
+// 12-31 and 01-01 is reported as a single run wrapping the year.
+func findRuns(days []bool) []dayRun {
+	var runs []dayRun
+	for doy := 1; doy <= daysInYear; doy++ {
+		if !days[doy] {
+			continue
+		}
+		if len(runs) > 0 && runs[len(runs)-1].end == doy-1 {
+			runs[len(runs)-1].end = doy
+		} else {
+			runs = append(runs, dayRun{start: doy, end: doy})
+		}
+	}
+
+	if len(runs) > 1 && runs[0].start == 1 && runs[len(runs)-1].end == daysInYear {
+		runs[len(runs)-1].end = runs[0].end
+		runs = runs[1:]
+	}
+	return runs
+}
+
+// doyToMonthDay converts a day-of-year number (1-366) back to a month and day.
+func doyToMonthDay(doy int) (month, day int) {
+	month = 1
+	for month < 12 && doy > daysInMonth[month] {
+		doy -= daysInMonth[month]
+		month++
+	}
+	return month, doy
+}
+
+// formatDOY formats a day-of-year number as MM-DD.
+func formatDOY(doy int) string {
+	month, day := doyToMonthDay(doy)
+	return fmt.Sprintf("%02d-%02d", month, day)
+}
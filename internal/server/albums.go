@@ -0,0 +1,82 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/immich"
+)
+
+// albumsResponse is the body of GET /api/v1/albums and POST /api/v1/albums/sync.
+type albumsResponse struct {
+	Albums []immich.Album     `json:"albums"`
+	Status immich.CacheStatus `json:"status"`
+}
+
+// SetAlbumCache shows album names from the cache in the UI and status API
+// and, with immich.discovery, adds schedule entries for albums following
+// the naming convention, starting with the cached albums.
+// It must be called before the server starts.
+func (s *Server) SetAlbumCache(cache *immich.AlbumCache) {
+	s.albums = cache
+	if s.current().config.Immich.Discovery {
+		cache.OnSync(s.discover)
+		s.discover(cache.Albums())
+	}
+}
+
+// albumName returns the name of a referenced album, which may be an alias,
+// or "" when it is not known.
+func (s *Server) albumName(st *snapshot, ref string) string {
+	if s.albums == nil || ref == "" {
+		return ""
+	}
+	return s.albums.Name(st.config.AlbumID(ref))
+}
+
+// albumLabel describes referenced albums for display, by name where known.
+func (s *Server) albumLabel(st *snapshot, refs ...string) string {
+	labels := make([]string, len(refs))
+	for i, ref := range refs {
+		labels[i] = ref
+		if name := s.albumName(st, ref); name != "" {
+			labels[i] = name
+		}
+	}
+	return strings.Join(labels, ", ")
+}
+
+// handleListAlbums lists the cached Immich albums.
+func (s *Server) handleListAlbums(w http.ResponseWriter, r *http.Request) {
+	if s.albums == nil {
+		writeError(w, http.StatusNotFound, "immich.url is not configured")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.albumsResponse())
+}
+
+// handleSyncAlbums refreshes the album cache from Immich now.
+func (s *Server) handleSyncAlbums(w http.ResponseWriter, r *http.Request) {
+	if s.albums == nil {
+		writeError(w, http.StatusNotFound, "immich.url is not configured")
+		return
+	}
+	if err := s.albums.Sync(r.Context()); err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+	s.logger.Info("albums synced via API",
+		slog.Int("albums", len(s.albums.Albums())),
+		slog.String("token", tokenName(r.Context())),
+	)
+	writeJSON(w, http.StatusOK, s.albumsResponse())
+}
+
+func (s *Server) albumsResponse() albumsResponse {
+	albums := s.albums.Albums()
+	if albums == nil {
+		albums = []immich.Album{}
+	}
+	return albumsResponse{Albums: albums, Status: s.albums.Status()}
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/immich"
+)
+
+// newAlbumCacheTestServer returns a test server with an album cache backed
+// by a fake Immich knowing the test config's albums.
+func newAlbumCacheTestServer(t *testing.T) *Server {
+	immichSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("shared") == "true" {
+			_, _ = io.WriteString(w, `[]`)
+			return
+		}
+		_, _ = io.WriteString(w, `[{"id": "default-album-id", "albumName": "Everyday"}, {"id": "christmas-album", "albumName": "Christmas"}]`)
+	}))
+	t.Cleanup(immichSrv.Close)
+
+	srv := newWriteTestServer(t)
+	client := immich.New(config.ImmichConfig{URL: immichSrv.URL, APIKey: "key"})
+	srv.SetAlbumCache(immich.NewAlbumCache(client, config.DefaultImmichSyncInterval, srv.Store(), srv.logger))
+	return srv
+}
+
+func TestAPI_Albums(t *testing.T) {
+	srv := newAlbumCacheTestServer(t)
+
+	rec := guestRequest(srv, http.MethodGet, "/api/v1/albums")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp albumsResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	assert.Empty(t, resp.Albums)
+
+	assert.Equal(t, http.StatusUnauthorized, guestRequest(srv, http.MethodPost, "/api/v1/albums/sync").Code)
+	rec = apiRequest(srv, http.MethodPost, "/api/v1/albums/sync", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	assert.Len(t, resp.Albums, 2)
+	assert.Equal(t, "Christmas", resp.Albums[0].AlbumName)
+	assert.NotNil(t, resp.Status.LastSync)
+
+	rec = guestRequest(srv, http.MethodGet, "/api/v1/status")
+	var status statusResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&status))
+	assert.Equal(t, "Everyday", status.DefaultAlbumName)
+	assert.NotEmpty(t, status.AlbumName)
+	require.NotNil(t, status.AlbumCache)
+	assert.Equal(t, 2, status.AlbumCache.Albums)
+}
+
+func TestAPI_AlbumsWithoutImmich(t *testing.T) {
+	srv := newTestServer(t, newAPITestConfig())
+	assert.Equal(t, http.StatusNotFound, guestRequest(srv, http.MethodGet, "/api/v1/albums").Code)
+
+	var status statusResponse
+	require.NoError(t, json.NewDecoder(guestRequest(srv, http.MethodGet, "/api/v1/status").Body).Decode(&status))
+	assert.Empty(t, status.DefaultAlbumName)
+	assert.Nil(t, status.AlbumCache)
+}
+
+func TestUI_AlbumNames(t *testing.T) {
+	srv := newAlbumCacheTestServer(t)
+	require.NoError(t, srv.albums.Sync(context.Background()))
+
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ui/", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "<td>Christmas</td>")
+	assert.Contains(t, rec.Body.String(), "<td>Everyday</td>")
+}
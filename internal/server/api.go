@@ -0,0 +1,275 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/gitsync"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/homeassistant"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/immich"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/mqtt"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/remote"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/scheduler"
+)
+
+// statusResponse is the body of GET /api/v1/status.
+type statusResponse struct {
+	Schedule         string                `json:"schedule"`
+	Album            string                `json:"album"`
+	AlbumName        string                `json:"album_name,omitempty"`
+	DefaultAlbum     string                `json:"default_album"`
+	DefaultAlbumName string                `json:"default_album_name,omitempty"`
+	ScheduleCount    int                   `json:"schedule_count"`
+	ConfigRevision   string                `json:"config_revision"`
+	ConfigReload     ReloadStatus          `json:"config_reload"`
+	Warnings         []scheduler.Warning   `json:"warnings"`
+	GitSync          *gitsync.Status       `json:"git_sync,omitempty"`
+	RemoteConfig     *remote.Status        `json:"remote_config,omitempty"`
+	HomeAssistant    *homeassistant.Status `json:"home_assistant,omitempty"`
+	MQTT             *mqtt.Status          `json:"mqtt,omitempty"`
+	AlbumCache       *immich.CacheStatus   `json:"album_cache,omitempty"`
+	Override         *albumOverride        `json:"override,omitempty"`
+	Maintenance      bool                  `json:"maintenance"`
+	Profile          string                `json:"profile,omitempty"`
+}
+
+// schedulesResponse is the body of GET /api/v1/schedules.
+type schedulesResponse struct {
+	Revision     string                 `json:"revision"`
+	DefaultAlbum string                 `json:"default_album"`
+	Schedules    []config.ScheduleEntry `json:"schedules"`
+}
+
+// apiRoutes configures the /api/v1 routes.
+func (s *Server) apiRoutes(r chi.Router) {
+	r.Get("/status", s.handleStatus)
+	r.Get("/schedules", s.handleListSchedules)
+	r.Get("/schedules/{name}", s.handleGetSchedule)
+	r.Get("/coverage", s.handleCoverage)
+	r.Post("/reevaluate", s.handleReevaluate)
+	r.Post("/validate", s.handleValidate)
+	r.Get("/party", s.handlePartyStatus)
+	r.Get("/maintenance", s.handleMaintenanceStatus)
+	r.Get("/profile", s.handleProfileStatus)
+	r.Get("/albums", s.handleListAlbums)
+	r.Get("/stats", s.handleStats)
+	r.Get("/stats/export", s.handleStatsExport)
+	r.Get("/devices", s.handleListDevices)
+	r.Get("/graphql", s.handleGraphQL)
+	r.Post("/graphql", s.handleGraphQL)
+
+	r.Group(func(r chi.Router) {
+		r.Use(s.requireToken)
+		r.Get("/audit", s.handleAudit)
+		r.Get("/shadow", s.handleShadow)
+		r.Get("/config", s.handleConfig)
+
+		r.Group(func(r chi.Router) {
+			r.Use(s.requireEditor)
+			r.Post("/schedules", s.handleCreateSchedule)
+			r.Put("/schedules", s.handleReplaceSchedules)
+			r.Put("/schedules/{name}", s.handleUpdateSchedule)
+			r.Delete("/schedules/{name}", s.handleDeleteSchedule)
+			r.Post("/party/{name}", s.handleStartParty)
+			r.Delete("/party", s.handleStopParty)
+			r.Post("/override", s.handleStartOverride)
+			r.Delete("/override", s.handleClearOverride)
+			r.Post("/freeze", s.handleFreeze)
+			r.Delete("/freeze", s.handleUnfreeze)
+			r.Post("/guest-links", s.handleCreateGuestLink)
+			r.Post("/maintenance", s.handleMaintenance)
+			r.Put("/profile/{name}", s.handleSwitchProfile)
+			r.Post("/albums/sync", s.handleSyncAlbums)
+			r.Post("/devices", s.handleRegisterDevice)
+			r.Delete("/devices/{id}", s.handleRemoveDevice)
+		})
+	})
+}
+
+// handleStatus returns the currently active schedule.
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	st := s.current()
+	now := time.Now()
+	selection := s.selectionAt(st, now)
+	scheduleName, album := selection.Schedule, selection.Album
+	override := s.activeOverride(now)
+	reload := s.reloads.get()
+
+	// The status changes with the config, reload and sync attempts and at schedule transitions.
+	var lastAttempt string
+	if reload.LastAttempt != nil {
+		lastAttempt = reload.LastAttempt.String()
+	}
+	parts := []string{st.revision, scheduleName, album, lastAttempt, reload.LastError}
+	if override != nil {
+		parts = append(parts, override.Name, override.Until.String())
+	}
+
+	var gitStatus *gitsync.Status
+	if s.gitSync != nil {
+		status := s.gitSync.Status()
+		gitStatus = &status
+		parts = append(parts, status.Commit, status.LastError)
+		if status.LastAttempt != nil {
+			parts = append(parts, status.LastAttempt.String())
+		}
+	}
+
+	var remoteStatus *remote.Status
+	if s.remote != nil {
+		status := s.remote.Status()
+		remoteStatus = &status
+		parts = append(parts, strconv.FormatUint(status.Version, 10), status.LastError)
+	}
+
+	var haStatus *homeassistant.Status
+	if s.homeAssistant != nil {
+		status := s.homeAssistant.Status()
+		haStatus = &status
+		parts = append(parts, strconv.FormatBool(status.Connected), status.LastError)
+		for _, entity := range slices.Sorted(maps.Keys(status.States)) {
+			parts = append(parts, entity, status.States[entity])
+		}
+	}
+
+	var mqttStatus *mqtt.Status
+	if s.mqtt != nil {
+		status := s.mqtt.Status()
+		mqttStatus = &status
+		parts = append(parts, strconv.FormatBool(status.Connected), status.LastError)
+		for _, topic := range slices.Sorted(maps.Keys(status.Values)) {
+			parts = append(parts, topic, status.Values[topic])
+		}
+	}
+
+	var cacheStatus *immich.CacheStatus
+	albumName, defaultAlbumName := s.albumName(st, album), s.albumName(st, st.scheduler.GetDefaultAlbum())
+	if s.albums != nil {
+		status := s.albums.Status()
+		cacheStatus = &status
+		parts = append(parts, albumName, defaultAlbumName, strconv.Itoa(status.Albums), status.LastError)
+		if status.LastSync != nil {
+			parts = append(parts, status.LastSync.String())
+		}
+	}
+
+	if notModified(w, r, hashETag(parts...)) {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, statusResponse{
+		Schedule:         scheduleName,
+		Album:            album,
+		AlbumName:        albumName,
+		DefaultAlbum:     st.scheduler.GetDefaultAlbum(),
+		DefaultAlbumName: defaultAlbumName,
+		ScheduleCount:    st.scheduler.GetScheduleCount(),
+		ConfigRevision:   st.revision,
+		ConfigReload:     reload,
+		Warnings:         st.scheduler.Warnings(),
+		GitSync:          gitStatus,
+		RemoteConfig:     remoteStatus,
+		HomeAssistant:    haStatus,
+		MQTT:             mqttStatus,
+		AlbumCache:       cacheStatus,
+		Override:         override,
+		Maintenance:      st.config.Maintenance.Enabled,
+		Profile:          st.profile,
+	})
+}
+
+// configSourcesResponse is the body of GET /api/v1/config?sources=true.
+type configSourcesResponse struct {
+	Config map[string]any `json:"config"`
+	// Sources maps dotted setting keys to default, file, data, env, flag
+	// or api.
+	Sources map[string]string `json:"sources"`
+}
+
+// handleConfig returns the effective configuration with secrets redacted
+// and, with ?sources=true, where each setting was taken from.
+func (s *Server) handleConfig(w http.ResponseWriter, r *http.Request) {
+	cfg := s.current().config
+	if sources, _ := strconv.ParseBool(r.URL.Query().Get("sources")); sources {
+		writeJSON(w, http.StatusOK, configSourcesResponse{Config: cfg.RedactedSettings(), Sources: cfg.Sources()})
+		return
+	}
+	writeJSON(w, http.StatusOK, cfg.RedactedSettings())
+}
+
+// handleListSchedules returns the configured schedule entries in evaluation order.
+func (s *Server) handleListSchedules(w http.ResponseWriter, r *http.Request) {
+	st := s.current()
+	if notModified(w, r, `"`+st.revision+`"`) {
+		return
+	}
+
+	schedules := st.config.Schedule
+	if schedules == nil {
+		schedules = []config.ScheduleEntry{}
+	}
+
+	writeJSON(w, http.StatusOK, schedulesResponse{
+		Revision:     st.revision,
+		DefaultAlbum: st.config.DefaultAlbum,
+		Schedules:    schedules,
+	})
+}
+
+// handleCoverage returns how much of the year each schedule entry covers.
+func (s *Server) handleCoverage(w http.ResponseWriter, r *http.Request) {
+	st := s.current()
+	if notModified(w, r, `"`+st.revision+`"`) {
+		return
+	}
+	writeJSON(w, http.StatusOK, st.scheduler.Coverage())
+}
+
+// hashETag builds a strong ETag from the given parts.
+func hashETag(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// notModified sets the ETag header and, when the request's If-None-Match
+// matches it, writes 304 Not Modified and returns true.
+func notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+
+	for _, candidate := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// errorResponse is the body of API error responses.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// writeError writes an API error response.
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, errorResponse{Error: message})
+}
+
+// writeJSON writes v as a JSON response with the given status code.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,174 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/scheduler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newAPITestConfig() *config.Config {
+	return &config.Config{
+		KioskURL:          "https://kiosk.example.com",
+		DefaultAlbum:      "default-album-id",
+		Port:              8080,
+		PassthroughParams: []string{},
+		Schedule: []config.ScheduleEntry{
+			{Name: "christmas", Album: "christmas-album", Start: "11-15", End: "01-01"},
+		},
+	}
+}
+
+func TestAPI_Status(t *testing.T) {
+	srv := newTestServer(t, newAPITestConfig())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/status", nil)
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	assert.NotEmpty(t, rec.Header().Get("ETag"))
+
+	var status statusResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&status))
+	assert.Equal(t, "default-album-id", status.DefaultAlbum)
+	assert.Equal(t, 1, status.ScheduleCount)
+	assert.Equal(t, srv.current().revision, status.ConfigRevision)
+}
+
+func TestAPI_ListSchedules(t *testing.T) {
+	srv := newTestServer(t, newAPITestConfig())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/schedules", nil)
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var body schedulesResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+	assert.Equal(t, srv.current().revision, body.Revision)
+	require.Len(t, body.Schedules, 1)
+	assert.Equal(t, "christmas", body.Schedules[0].Name)
+	assert.Equal(t, "11-15", body.Schedules[0].Start)
+}
+
+func TestAPI_ETagNotModified(t *testing.T) {
+	srv := newTestServer(t, newAPITestConfig())
+
+	for _, path := range []string{"/api/v1/status", "/api/v1/schedules"} {
+		t.Run(path, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			rec := httptest.NewRecorder()
+			srv.router.ServeHTTP(rec, req)
+			require.Equal(t, http.StatusOK, rec.Code)
+			etag := rec.Header().Get("ETag")
+			require.NotEmpty(t, etag)
+
+			// Unchanged payloads return 304 without a body
+			req = httptest.NewRequest(http.MethodGet, path, nil)
+			req.Header.Set("If-None-Match", etag)
+			rec = httptest.NewRecorder()
+			srv.router.ServeHTTP(rec, req)
+			assert.Equal(t, http.StatusNotModified, rec.Code)
+			assert.Empty(t, rec.Body.String())
+
+			// Stale tags get a full response
+			req = httptest.NewRequest(http.MethodGet, path, nil)
+			req.Header.Set("If-None-Match", `"stale"`)
+			rec = httptest.NewRecorder()
+			srv.router.ServeHTTP(rec, req)
+			assert.Equal(t, http.StatusOK, rec.Code)
+		})
+	}
+}
+
+func TestAPI_ETagChangesWithConfig(t *testing.T) {
+	cfg := newAPITestConfig()
+	first := newTestServer(t, cfg)
+
+	changed := newAPITestConfig()
+	changed.Schedule[0].Album = "other-album"
+	second := newTestServer(t, changed)
+
+	get := func(srv *Server) string {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/schedules", nil)
+		rec := httptest.NewRecorder()
+		srv.router.ServeHTTP(rec, req)
+		return rec.Header().Get("ETag")
+	}
+
+	assert.NotEqual(t, get(first), get(second))
+}
+
+func TestAPI_Coverage(t *testing.T) {
+	srv := newTestServer(t, newAPITestConfig())
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/coverage", nil)
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `"`+srv.current().revision+`"`, rec.Header().Get("ETag"))
+
+	var body scheduler.Coverage
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+	require.Len(t, body.Entries, 1)
+	assert.Equal(t, "christmas", body.Entries[0].Name)
+	assert.Equal(t, 48, body.Entries[0].SelectedDays)
+	assert.Equal(t, 366-48, body.DefaultDays)
+	assert.Len(t, body.Selection, 366)
+}
+
+func TestAPI_Config(t *testing.T) {
+	srv := newWriteTestServer(t)
+
+	rec := apiRequest(srv, http.MethodGet, "/api/v1/config", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var body map[string]any
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+	assert.Equal(t, "default-album-id", body["default_album"])
+	token := body["api_tokens"].([]any)[0].(map[string]any)
+	assert.Equal(t, "automation", token["name"])
+	assert.Equal(t, "[redacted]", token["token"])
+	assert.NotContains(t, rec.Body.String(), testAPIToken)
+
+	assert.Equal(t, http.StatusUnauthorized, guestRequest(srv, http.MethodGet, "/api/v1/config").Code)
+}
+
+func TestAPI_ConfigSources(t *testing.T) {
+	cfg, err := config.LoadBytes([]byte(`
+kiosk_url: "https://kiosk.example.com"
+default_album: "default-album-id"
+api_tokens:
+  - name: automation
+    token: "`+testAPIToken+`"
+    role: editor
+`), "yaml")
+	require.NoError(t, err)
+	srv := newTestServer(t, cfg)
+
+	sources := func() map[string]string {
+		t.Helper()
+		rec := apiRequest(srv, http.MethodGet, "/api/v1/config?sources=true", "")
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var body configSourcesResponse
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+		assert.Equal(t, "default-album-id", body.Config["default_album"])
+		return body.Sources
+	}
+	assert.Equal(t, config.SourceData, sources()["default_album"])
+	assert.Equal(t, config.SourceDefault, sources()["maintenance.enabled"])
+
+	rec := apiRequest(srv, http.MethodPost, "/api/v1/maintenance", `{"enabled": true}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, config.SourceAPI, sources()["maintenance.enabled"])
+	assert.Equal(t, config.SourceData, sources()["kiosk_url"])
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// auditLog is the state store log holding audit entries.
+const auditLog = "audit"
+
+// Limits on the number of entries returned by GET /api/v1/audit.
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// auditEntry records one change made through the admin API.
+type auditEntry struct {
+	Time time.Time `json:"time"`
+	// Actor is the name of the API token that made the change, or the chat
+	// it came from, e.g. telegram:12345.
+	Actor  string `json:"actor"`
+	Action string `json:"action"`
+	Target string `json:"target,omitempty"`
+	// Before and After are the changed values; creations have no Before and
+	// deletions no After.
+	Before   any    `json:"before,omitempty"`
+	After    any    `json:"after,omitempty"`
+	Revision string `json:"revision,omitempty"`
+}
+
+// auditResponse is the body of GET /api/v1/audit.
+type auditResponse struct {
+	// Entries are ordered newest first.
+	Entries []auditEntry `json:"entries"`
+}
+
+// Audit actions.
+const (
+	auditScheduleCreate    = "schedule.create"
+	auditScheduleUpdate    = "schedule.update"
+	auditScheduleDelete    = "schedule.delete"
+	auditSchedulesReplace  = "schedules.replace"
+	auditPartyStart        = "party.start"
+	auditPartyStop         = "party.stop"
+	auditGuestLinkCreate   = "guest_link.create"
+	auditMaintenanceUpdate = "maintenance.update"
+	auditProfileSwitch     = "profile.switch"
+	auditDeviceRegister    = "device.register"
+	auditDeviceRemove      = "device.remove"
+	auditOverrideStart     = "override.start"
+	auditOverrideClear     = "override.clear"
+	auditFreezeStart       = "freeze.start"
+	auditFreezeStop        = "freeze.stop"
+)
+
+// audit records a change made through the admin API. The change has
+// already been applied, so a failure to record it is logged, not returned.
+func (s *Server) audit(r *http.Request, entry auditEntry) {
+	s.auditAs(tokenName(r.Context()), entry)
+}
+
+// auditAs records a change made by actor outside of the admin API, such as
+// a chat command.
+func (s *Server) auditAs(actor string, entry auditEntry) {
+	entry.Time = time.Now().UTC()
+	entry.Actor = actor
+	if err := s.store.Append(auditLog, entry); err != nil {
+		s.logger.Error("failed to record audit entry",
+			slog.String("action", entry.Action),
+			slog.String("target", entry.Target),
+			slog.Any("error", err),
+		)
+	}
+}
+
+// handleAudit lists recorded changes, newest first, optionally filtered by
+// actor, action, target and time.
+func (s *Server) handleAudit(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	limit := defaultAuditLimit
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxAuditLimit {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxAuditLimit))
+			return
+		}
+		limit = n
+	}
+	var since time.Time
+	if v := query.Get("since"); v != "" {
+		var err error
+		if since, err = time.Parse(time.RFC3339, v); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid since %q, expected RFC 3339", v))
+			return
+		}
+	}
+	match := func(field, value string) bool {
+		return !query.Has(field) || query.Get(field) == value
+	}
+
+	entries := []auditEntry{}
+	err := s.store.Records(auditLog, func(data json.RawMessage) error {
+		var entry auditEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return err
+		}
+		if entry.Time.Before(since) || !match("actor", entry.Actor) || !match("action", entry.Action) || !match("target", entry.Target) {
+			return nil
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		s.logger.Error("failed to read audit log", slog.Any("error", err))
+		writeError(w, http.StatusInternalServerError, "failed to read audit log")
+		return
+	}
+
+	slices.Reverse(entries)
+	writeJSON(w, http.StatusOK, auditResponse{Entries: entries[:min(limit, len(entries))]})
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+)
+
+func auditEntries(t *testing.T, srv *Server, query string) []auditEntry {
+	t.Helper()
+	rec := apiRequest(srv, http.MethodGet, "/api/v1/audit"+query, "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var resp auditResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	return resp.Entries
+}
+
+func TestAPI_Audit(t *testing.T) {
+	srv := newWriteTestServer(t)
+
+	rec := apiRequest(srv, http.MethodPost, "/api/v1/schedules", `{"name": "advent", "album": "advent-album", "start": "12-01", "end": "12-24"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	rec = apiRequest(srv, http.MethodPut, "/api/v1/schedules/christmas", `{"album": "new-christmas", "start": "12-20", "end": "12-26"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	rec = apiRequest(srv, http.MethodDelete, "/api/v1/schedules/advent", "")
+	require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+
+	// Rejected changes are not recorded
+	rec = apiRequest(srv, http.MethodDelete, "/api/v1/schedules/missing", "")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	entries := auditEntries(t, srv, "")
+	require.Len(t, entries, 3)
+	assert.Equal(t, auditScheduleDelete, entries[0].Action)
+	assert.Equal(t, auditScheduleUpdate, entries[1].Action)
+	assert.Equal(t, auditScheduleCreate, entries[2].Action)
+
+	update := entries[1]
+	assert.Equal(t, "automation", update.Actor)
+	assert.Equal(t, "christmas", update.Target)
+	assert.NotEmpty(t, update.Revision)
+	assert.WithinDuration(t, time.Now(), update.Time, time.Minute)
+	assert.Equal(t, "christmas-album", update.Before.(map[string]any)["album"])
+	assert.Equal(t, "new-christmas", update.After.(map[string]any)["album"])
+	assert.Nil(t, entries[0].After)
+	assert.Nil(t, entries[2].Before)
+
+	assert.Len(t, auditEntries(t, srv, "?limit=1"), 1)
+	assert.Len(t, auditEntries(t, srv, "?target=christmas"), 1)
+	assert.Len(t, auditEntries(t, srv, "?action=schedule.create"), 1)
+	assert.Empty(t, auditEntries(t, srv, "?actor=someone-else"))
+	assert.Empty(t, auditEntries(t, srv, "?since="+time.Now().Add(time.Hour).Format(time.RFC3339)))
+
+	rec = apiRequest(srv, http.MethodGet, "/api/v1/audit?limit=0", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = apiRequest(srv, http.MethodGet, "/api/v1/audit?since=yesterday", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestAPI_AuditRequiresToken(t *testing.T) {
+	srv := newWriteTestServer(t)
+	assert.Equal(t, http.StatusUnauthorized, guestRequest(srv, http.MethodGet, "/api/v1/audit").Code)
+}
+
+func TestAPI_AuditPersists(t *testing.T) {
+	cfg := newAPITestConfig()
+	cfg.APITokens = []config.APIToken{{Name: "automation", Token: testAPIToken}}
+	cfg.StateDir = t.TempDir()
+
+	srv := newTestServer(t, cfg)
+	rec := apiRequest(srv, http.MethodDelete, "/api/v1/schedules/christmas", "")
+	require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+
+	// A new server reading the same state directory sees the entry.
+	srv = newTestServer(t, cfg)
+	entries := auditEntries(t, srv, "")
+	require.Len(t, entries, 1)
+	assert.Equal(t, "christmas", entries[0].Target)
+}
+
+func TestAPI_AuditParty(t *testing.T) {
+	srv := newPartyTestServer(t)
+
+	require.Equal(t, http.StatusOK, apiRequest(srv, http.MethodPost, "/api/v1/party/birthday", "").Code)
+	require.Equal(t, http.StatusNoContent, apiRequest(srv, http.MethodDelete, "/api/v1/party", "").Code)
+	// Stopping when nothing runs changes nothing.
+	require.Equal(t, http.StatusNoContent, apiRequest(srv, http.MethodDelete, "/api/v1/party", "").Code)
+
+	entries := auditEntries(t, srv, "")
+	require.Len(t, entries, 2)
+	assert.Equal(t, auditPartyStop, entries[0].Action)
+	assert.Equal(t, auditPartyStart, entries[1].Action)
+	assert.Equal(t, "birthday", entries[1].Target)
+}
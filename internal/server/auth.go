@@ -0,0 +1,70 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+)
+
+// tokenKey is the context key holding the authenticated API token.
+type tokenKey struct{}
+
+// tokenName returns the name of the API token that authenticated the request.
+func tokenName(ctx context.Context) string {
+	token, _ := ctx.Value(tokenKey{}).(config.APIToken)
+	return token.Name
+}
+
+// requireToken only lets through requests presenting one of the configured
+// API tokens as a bearer token. Without configured tokens the endpoints are
+// disabled.
+func (s *Server) requireToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokens := s.current().config.APITokens
+		if len(tokens) == 0 {
+			writeError(w, http.StatusForbidden, "the admin API is disabled; configure api_tokens to enable it")
+			return
+		}
+
+		if t, ok := s.authenticate(r); ok {
+			ctx := context.WithValue(r.Context(), tokenKey{}, t)
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
+		w.Header().Set("WWW-Authenticate", `Bearer realm="immich-kiosk-scheduler"`)
+		writeError(w, http.StatusUnauthorized, "missing or invalid API token")
+	})
+}
+
+// authenticate returns the configured API token the request presents as a
+// bearer token.
+func (s *Server) authenticate(r *http.Request) (config.APIToken, bool) {
+	presented, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return config.APIToken{}, false
+	}
+	for _, t := range s.current().config.APITokens {
+		if subtle.ConstantTimeCompare([]byte(presented), []byte(t.Token)) == 1 {
+			return t, true
+		}
+	}
+	return config.APIToken{}, false
+}
+
+// requireEditor rejects requests authenticated with a viewer token. It must
+// run after requireToken.
+func (s *Server) requireEditor(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, _ := r.Context().Value(tokenKey{}).(config.APIToken)
+		if !token.CanEdit() {
+			writeError(w, http.StatusForbidden, fmt.Sprintf("token %q is read-only", token.Name))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/hooks"
+)
+
+// chatHelp lists the chat commands.
+const chatHelp = `/status - what the kiosk shows now
+/next - the next scheduled change
+/override <album> [duration] - show an album for a while, e.g. /override garden 2h
+/clear - return to the schedule`
+
+// Command runs a chat command such as "/override garden 2h" on behalf of
+// actor, e.g. telegram:12345, and returns the reply.
+func (s *Server) Command(actor, text string) string {
+	args := strings.Fields(text)
+	if len(args) == 0 {
+		return chatHelp
+	}
+	// Telegram addresses commands in groups as /status@kiosk_bot.
+	name, _, _ := strings.Cut(strings.TrimPrefix(strings.ToLower(args[0]), "/"), "@")
+	switch name {
+	case "status":
+		return s.chatStatus()
+	case "next":
+		return s.chatNext()
+	case "override":
+		return s.chatOverride(actor, args[1:])
+	case "clear":
+		return s.chatClear(actor)
+	case "help", "start":
+		return chatHelp
+	default:
+		return fmt.Sprintf("Unknown command %q.\n\n%s", args[0], chatHelp)
+	}
+}
+
+// chatStatus describes what the kiosk shows now.
+func (s *Server) chatStatus() string {
+	st := s.current()
+	now := time.Now()
+	current := s.selectionAt(st, now)
+	reply := fmt.Sprintf("Showing %s: %s.", current.Schedule, s.albumLabel(st, current.Album))
+	if o := s.activeOverride(now); o != nil && o.Mode == overrideModeFreeze {
+		reply += "\nFrozen until released; /clear returns to the schedule."
+	} else if o != nil {
+		reply += fmt.Sprintf("\n%s until %s; /clear returns to the schedule.", o.Name, formatChatTime(o.Until, now))
+	}
+	return reply
+}
+
+// chatNext describes the next scheduled change.
+func (s *Server) chatNext() string {
+	st := s.current()
+	now := time.Now()
+	at, next, ok := st.backend.NextTransition(now)
+	if !ok {
+		return "No schedule change within the next year."
+	}
+	return fmt.Sprintf("Next: %s (%s) from %s.", next.Schedule, s.albumLabel(st, next.Album), at.Format("Monday 2 January"))
+}
+
+// chatOverride shows an album named by a control album or an album alias.
+func (s *Server) chatOverride(actor string, args []string) string {
+	if len(args) == 0 || len(args) > 2 {
+		return "Usage: /override <album> [duration], e.g. /override garden 2h"
+	}
+	st := s.current()
+	a, ok := st.overrideAlbum(args[0])
+	if !ok && len(st.overrideAlbumNames()) == 0 {
+		return "No albums to show; configure control.albums or albums."
+	}
+	if !ok {
+		return fmt.Sprintf("Unknown album %q. Try one of: %s.", args[0], strings.Join(st.overrideAlbumNames(), ", "))
+	}
+	var d time.Duration
+	if len(args) == 2 {
+		var err error
+		d, err = time.ParseDuration(args[1])
+		if err != nil || d <= 0 {
+			return fmt.Sprintf("Invalid duration %q; use e.g. 30m or 2h.", args[1])
+		}
+	}
+
+	before := s.activeOverride(time.Now())
+	o := newAlbumOverride(a, d)
+	s.startOverride(o, hooks.ReasonChat)
+	s.auditAs(actor, auditEntry{Action: auditOverrideStart, Target: o.Name, Before: before, After: o})
+	return fmt.Sprintf("Showing %s until %s.", o.Name, formatChatTime(o.Until, time.Now()))
+}
+
+// chatClear ends the active override.
+func (s *Server) chatClear(actor string) string {
+	before := s.activeOverride(time.Now())
+	if before == nil || !s.clearOverride("", hooks.ReasonChat) {
+		return "No override is active."
+	}
+	s.auditAs(actor, auditEntry{Action: auditOverrideClear, Target: before.Name, Before: before})
+	return "Back to the schedule: " + s.chatStatus()
+}
+
+// formatChatTime renders a time for chat replies, with the day unless it
+// is today.
+func formatChatTime(t, now time.Time) string {
+	if t.YearDay() == now.YearDay() && t.Year() == now.Year() {
+		return t.Format("15:04")
+	}
+	return t.Format("Mon 2 Jan 15:04")
+}
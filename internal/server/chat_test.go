@@ -0,0 +1,74 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+)
+
+func newChatTestServer(t *testing.T) *Server {
+	t.Helper()
+	cfg := newAPITestConfig()
+	cfg.APITokens = []config.APIToken{{Name: "automation", Token: testAPIToken}}
+	cfg.Control.Albums = []config.ControlAlbum{{Name: "Garden", Album: "garden-album"}}
+	cfg.Albums = map[string]string{"beach": "beach-album"}
+	return newTestServer(t, cfg)
+}
+
+func TestCommand_Override(t *testing.T) {
+	srv := newChatTestServer(t)
+
+	assert.Equal(t, "No override is active.", srv.Command("telegram:42", "/clear"))
+
+	reply := srv.Command("telegram:42", "/override@kiosk_bot garden 90m")
+	assert.True(t, strings.HasPrefix(reply, "Showing Garden until "), reply)
+	o := srv.override.Load()
+	require.NotNil(t, o)
+	assert.Equal(t, "garden-album", o.Album)
+	assert.Equal(t, "override", srv.active.Schedule)
+
+	reply = srv.Command("telegram:42", "/status")
+	assert.Contains(t, reply, "Showing override: garden-album.")
+	assert.Contains(t, reply, "Garden until ")
+
+	srv.Command("telegram:42", "/override BEACH")
+	assert.Equal(t, "beach-album", srv.override.Load().Album, "album aliases work too")
+
+	reply = srv.Command("telegram:42", "/clear")
+	assert.True(t, strings.HasPrefix(reply, "Back to the schedule: Showing "), reply)
+	assert.Nil(t, srv.override.Load())
+
+	rec := apiRequest(srv, http.MethodGet, "/api/v1/audit?actor=telegram:42", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var audit auditResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&audit))
+	require.Len(t, audit.Entries, 3)
+	assert.Equal(t, auditOverrideClear, audit.Entries[0].Action)
+	assert.Equal(t, "beach", audit.Entries[0].Target)
+	assert.Equal(t, auditOverrideStart, audit.Entries[2].Action)
+}
+
+func TestCommand_Errors(t *testing.T) {
+	srv := newChatTestServer(t)
+
+	for text, want := range map[string]string{
+		"/override":              "Usage: /override <album> [duration]",
+		"/override garden 2h 1h": "Usage: /override <album> [duration]",
+		"/override attic":        `Unknown album "attic". Try one of: Garden, beach.`,
+		"/override garden soon":  `Invalid duration "soon"`,
+		"/override garden -1h":   `Invalid duration "-1h"`,
+		"/reboot":                `Unknown command "/reboot".`,
+	} {
+		assert.Contains(t, srv.Command("telegram:42", text), want, text)
+	}
+	assert.Nil(t, srv.override.Load())
+
+	assert.True(t, strings.HasPrefix(srv.Command("telegram:42", "/next"), "Next: "))
+	assert.Equal(t, chatHelp, srv.Command("telegram:42", "/help"))
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/hooks"
+)
+
+// controlPage is the data of the household control page.
+type controlPage struct {
+	Current  hooks.Selection
+	Override *albumOverride
+	Until    string
+	Albums   []controlButton
+	Parties  []controlButton
+}
+
+type controlButton struct {
+	Name     string
+	Duration string
+}
+
+// controlRoutes configures the /control routes.
+func (s *Server) controlRoutes(r chi.Router) {
+	r.Use(s.requireControl)
+	r.Get("/", s.handleControl)
+	r.Post("/show", s.handleControlShow)
+	r.Post("/party", s.handleControlParty)
+	r.Post("/reset", s.handleControlReset)
+}
+
+// requireControl hides the control page unless it is enabled, applies its
+// Basic Authentication and rejects form posts from other sites.
+func (s *Server) requireControl(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg := s.current().config.Control
+		if !cfg.Enabled {
+			s.handleNotFound(w, r)
+			return
+		}
+		if cfg.Username != "" && !validBasicAuth(r, cfg.Username, cfg.Password) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="control"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.Method == http.MethodPost && crossSite(r) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// controlAlbum returns the control page album with the given name.
+func (st *snapshot) controlAlbum(name string) (config.ControlAlbum, bool) {
+	i := slices.IndexFunc(st.config.Control.Albums, func(a config.ControlAlbum) bool { return a.Name == name })
+	if i < 0 {
+		return config.ControlAlbum{}, false
+	}
+	return st.config.Control.Albums[i], true
+}
+
+// newAlbumOverride returns the override showing a control album for d, or
+// its configured duration when zero.
+func newAlbumOverride(a config.ControlAlbum, d time.Duration) *albumOverride {
+	if d == 0 {
+		d = overrideDuration(a.Duration)
+	}
+	return &albumOverride{
+		Mode:  overrideModeAlbum,
+		Name:  a.Name,
+		Album: a.Album,
+		Until: time.Now().Add(d),
+	}
+}
+
+// handleControl renders the control page.
+func (s *Server) handleControl(w http.ResponseWriter, r *http.Request) {
+	st := s.current()
+	now := time.Now()
+
+	page := controlPage{
+		Current:  s.selectionAt(st, now),
+		Override: s.activeOverride(now),
+	}
+	if page.Override != nil {
+		page.Until = page.Override.untilLabel("15:04")
+		if page.Override.Until.YearDay() != now.YearDay() {
+			page.Until = page.Override.untilLabel("Mon 15:04")
+		}
+	}
+	for _, a := range st.config.Control.Albums {
+		page.Albums = append(page.Albums, controlButton{Name: a.Name, Duration: formatDuration(overrideDuration(a.Duration))})
+	}
+	for _, m := range st.config.PartyModes {
+		page.Parties = append(page.Parties, controlButton{Name: m.Name, Duration: formatDuration(overrideDuration(m.Duration))})
+	}
+
+	s.renderUI(w, http.StatusOK, "control", "Photo frame", 0, page)
+}
+
+// handleControlShow shows a control album for its configured duration.
+func (s *Server) handleControlShow(w http.ResponseWriter, r *http.Request) {
+	st := s.current()
+	name := r.PostFormValue("album")
+	a, ok := st.controlAlbum(name)
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown album %q", name), http.StatusBadRequest)
+		return
+	}
+	s.startOverride(newAlbumOverride(a, 0), hooks.ReasonControl)
+
+	http.Redirect(w, r, "/control/", http.StatusSeeOther)
+}
+
+// handleControlParty starts a party mode.
+func (s *Server) handleControlParty(w http.ResponseWriter, r *http.Request) {
+	name := r.PostFormValue("party")
+	mode, ok := s.current().partyMode(name)
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown party mode %q", name), http.StatusBadRequest)
+		return
+	}
+	s.startParty(mode, 0)
+
+	http.Redirect(w, r, "/control/", http.StatusSeeOther)
+}
+
+// handleControlReset returns to the schedule.
+func (s *Server) handleControlReset(w http.ResponseWriter, r *http.Request) {
+	s.clearOverride("", hooks.ReasonControl)
+	http.Redirect(w, r, "/control/", http.StatusSeeOther)
+}
+
+// crossSite reports whether the browser marked the request as coming from
+// another site, so form posts cannot be forged by other pages.
+func crossSite(r *http.Request) bool {
+	switch r.Header.Get("Sec-Fetch-Site") {
+	case "", "same-origin", "none":
+		return false
+	default:
+		return true
+	}
+}
+
+// formatDuration renders a duration for button labels, e.g. "3 hours".
+func formatDuration(d time.Duration) string {
+	switch {
+	case d == time.Hour:
+		return "1 hour"
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%d hours", d/time.Hour)
+	case d == time.Minute:
+		return "1 minute"
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%d minutes", d/time.Minute)
+	default:
+		return d.String()
+	}
+}
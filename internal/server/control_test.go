@@ -0,0 +1,111 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+)
+
+func newControlTestServer(t *testing.T) *Server {
+	t.Helper()
+	cfg := newAPITestConfig()
+	cfg.Schedule = nil
+	cfg.Control = config.ControlConfig{
+		Enabled:  true,
+		Username: "family",
+		Password: "secret",
+		Albums: []config.ControlAlbum{
+			{Name: "Vacation", Album: "vacation-album"},
+			{Name: "Party", Album: "party-album", Duration: 90 * time.Minute},
+		},
+	}
+	return newTestServer(t, cfg)
+}
+
+func controlRequest(srv *Server, method, target string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth("family", "secret")
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func redirectTarget(t *testing.T, srv *Server) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusFound, rec.Code)
+	return rec.Header().Get("Location")
+}
+
+func TestControl_Page(t *testing.T) {
+	srv := newControlTestServer(t)
+
+	rec := controlRequest(srv, http.MethodGet, "/control/", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	body := rec.Body.String()
+	assert.Contains(t, body, "Vacation<small>for 3 hours</small>")
+	assert.Contains(t, body, "Party<small>for 90 minutes</small>")
+	assert.NotContains(t, body, "Back to normal")
+}
+
+func TestControl_ShowAndReset(t *testing.T) {
+	srv := newControlTestServer(t)
+
+	rec := controlRequest(srv, http.MethodPost, "/control/show", url.Values{"album": {"Vacation"}})
+	require.Equal(t, http.StatusSeeOther, rec.Code)
+	assert.Contains(t, redirectTarget(t, srv), "album=vacation-album")
+	assert.Equal(t, "override", srv.active.Schedule)
+
+	o := srv.activeOverride(time.Now())
+	require.NotNil(t, o)
+	assert.WithinDuration(t, time.Now().Add(3*time.Hour), o.Until, time.Minute)
+
+	rec = controlRequest(srv, http.MethodGet, "/control/", nil)
+	assert.Contains(t, rec.Body.String(), "Back to normal")
+
+	rec = controlRequest(srv, http.MethodPost, "/control/reset", nil)
+	require.Equal(t, http.StatusSeeOther, rec.Code)
+	assert.Contains(t, redirectTarget(t, srv), "album=default-album-id")
+	assert.Equal(t, "default", srv.active.Schedule)
+}
+
+func TestControl_OverrideExpires(t *testing.T) {
+	srv := newControlTestServer(t)
+	srv.override.Store(&albumOverride{Name: "Vacation", Album: "vacation-album", Until: time.Now().Add(-time.Second)})
+
+	assert.Nil(t, srv.activeOverride(time.Now()))
+	assert.Contains(t, redirectTarget(t, srv), "album=default-album-id")
+}
+
+func TestControl_Errors(t *testing.T) {
+	srv := newControlTestServer(t)
+
+	rec := controlRequest(srv, http.MethodPost, "/control/show", url.Values{"album": {"Unknown"}})
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/control/", nil)
+	rec = httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/control/reset", nil)
+	req.SetBasicAuth("family", "secret")
+	req.Header.Set("Sec-Fetch-Site", "cross-site")
+	rec = httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	disabled := newTestServer(t, newAPITestConfig())
+	rec = controlRequest(disabled, http.MethodGet, "/control/", nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
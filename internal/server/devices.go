@@ -0,0 +1,435 @@
+package server
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/hooks"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/state"
+)
+
+// devicesDoc is the state document holding the registered devices.
+const devicesDoc = "devices"
+
+// maxDeviceIDLength limits device identifiers, which arrive in query strings.
+const maxDeviceIDLength = 128
+
+// deviceCheckInterval is how often registered devices are checked for
+// staleness.
+const deviceCheckInterval = time.Minute
+
+// deviceLastSeen exports when each registered device was last redirected,
+// so alerts can catch frames that stopped polling.
+var deviceLastSeen = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "immich_kiosk_scheduler_device_last_seen_timestamp_seconds",
+		Help: "Unix time of the last redirect served to each registered device",
+	},
+	[]string{"device"},
+)
+
+func init() {
+	prometheus.MustRegister(deviceLastSeen)
+}
+
+// registeredDevice is a display registered through the API. Its ID is the
+// value of the device query parameter the display sends.
+type registeredDevice struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Profile names the device profile applied to the device; empty uses
+	// the top-level passthrough settings.
+	Profile string `json:"profile,omitempty"`
+	// StaleAfter is the device's expected polling interval, e.g. "10m",
+	// overriding devices.stale_after.
+	StaleAfter   string    `json:"stale_after,omitempty"`
+	RegisteredAt time.Time `json:"registered_at"`
+}
+
+// staleAfter returns how long the device may go without a redirect before
+// it is stale; zero disables the check.
+func (d *registeredDevice) staleAfter(defaultStaleAfter time.Duration) time.Duration {
+	if d.StaleAfter == "" {
+		return defaultStaleAfter
+	}
+	// Validated on registration.
+	staleAfter, _ := time.ParseDuration(d.StaleAfter)
+	return staleAfter
+}
+
+// deviceActivity describes the last redirect served to a device.
+type deviceActivity struct {
+	LastSeen  time.Time
+	Album     string
+	Schedule  string
+	UserAgent string
+}
+
+// deviceStatus is an entry of GET /api/v1/devices.
+type deviceStatus struct {
+	registeredDevice
+	// LastSeen is unset until the device is redirected after registration
+	// or a restart.
+	LastSeen  *time.Time `json:"last_seen,omitempty"`
+	Album     string     `json:"album,omitempty"`
+	AlbumName string     `json:"album_name,omitempty"`
+	Schedule  string     `json:"schedule,omitempty"`
+	UserAgent string     `json:"user_agent,omitempty"`
+	// Stale reports that device_stale hooks fired and the device has not
+	// been redirected since.
+	Stale bool `json:"stale"`
+}
+
+// devicesResponse is the body of GET /api/v1/devices.
+type devicesResponse struct {
+	// Devices are ordered by name.
+	Devices []deviceStatus `json:"devices"`
+}
+
+// registerDeviceRequest is the body of POST /api/v1/devices.
+type registerDeviceRequest struct {
+	ID string `json:"id"`
+	// Name defaults to the ID.
+	Name       string `json:"name"`
+	Profile    string `json:"profile"`
+	StaleAfter string `json:"stale_after"`
+}
+
+// deviceRegistry holds the registered devices and their activity. Devices
+// are persisted; activity is kept in memory.
+type deviceRegistry struct {
+	store  *state.Store
+	logger *slog.Logger
+
+	// started is when the registry was created; devices not seen since are
+	// measured from it.
+	started time.Time
+
+	mu       sync.Mutex
+	devices  map[string]registeredDevice
+	activity map[string]deviceActivity
+	// stale holds the devices reported stale and not seen since.
+	stale map[string]bool
+}
+
+// newDeviceRegistry loads the registered devices from the store.
+func newDeviceRegistry(store *state.Store, logger *slog.Logger) *deviceRegistry {
+	r := &deviceRegistry{
+		store:    store,
+		logger:   logger,
+		started:  time.Now(),
+		devices:  make(map[string]registeredDevice),
+		activity: make(map[string]deviceActivity),
+		stale:    make(map[string]bool),
+	}
+	var saved []registeredDevice
+	if _, err := store.Load(devicesDoc, &saved); err != nil {
+		logger.Error("failed to load registered devices", slog.Any("error", err))
+	}
+	for _, d := range saved {
+		r.devices[d.ID] = d
+	}
+	return r
+}
+
+// register adds or replaces a device, keeping the registration time of a
+// replaced one, and reports whether it is new.
+func (r *deviceRegistry) register(d registeredDevice) (registeredDevice, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	previous, exists := r.devices[d.ID]
+	if exists {
+		d.RegisteredAt = previous.RegisteredAt
+	}
+	r.devices[d.ID] = d
+	r.save()
+	return d, !exists
+}
+
+// remove unregisters a device, reporting whether it was registered.
+func (r *deviceRegistry) remove(id string) (registeredDevice, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	d, ok := r.devices[id]
+	if !ok {
+		return registeredDevice{}, false
+	}
+	delete(r.devices, id)
+	delete(r.activity, id)
+	delete(r.stale, id)
+	deviceLastSeen.DeleteLabelValues(id)
+	r.save()
+	return d, true
+}
+
+// save persists the devices. The caller must hold mu.
+func (r *deviceRegistry) save() {
+	devices := slices.Collect(maps.Values(r.devices))
+	slices.SortFunc(devices, func(a, b registeredDevice) int { return strings.Compare(a.ID, b.ID) })
+	if err := r.store.Save(devicesDoc, devices); err != nil {
+		r.logger.Error("failed to persist registered devices", slog.Any("error", err))
+	}
+}
+
+// profile returns the device profile a registered device was given.
+func (r *deviceRegistry) profile(id string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.devices[id].Profile
+}
+
+// registered reports whether id is a registered device.
+func (r *deviceRegistry) registered(id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.devices[id]
+	return ok
+}
+
+// seen records a redirect served to a device. Unregistered devices are not
+// tracked, so arbitrary device parameters cannot grow the registry. When the
+// device was stale, seen returns its status before the redirect and true.
+func (r *deviceRegistry) seen(id string, activity deviceActivity) (deviceStatus, bool) {
+	if id == "" {
+		return deviceStatus{}, false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	d, ok := r.devices[id]
+	if !ok {
+		return deviceStatus{}, false
+	}
+	previous := r.status(d)
+	r.activity[id] = activity
+	delete(r.stale, id)
+	deviceLastSeen.WithLabelValues(id).Set(float64(activity.LastSeen.Unix()))
+	return previous, previous.Stale
+}
+
+// status returns the status of a registered device. The caller must hold mu.
+func (r *deviceRegistry) status(d registeredDevice) deviceStatus {
+	status := deviceStatus{registeredDevice: d, Stale: r.stale[d.ID]}
+	if a, ok := r.activity[d.ID]; ok {
+		status.LastSeen = &a.LastSeen
+		status.Album, status.Schedule, status.UserAgent = a.Album, a.Schedule, a.UserAgent
+	}
+	return status
+}
+
+// staleDevices returns the devices that went stale since the last check: not
+// redirected for their stale_after, or defaultStaleAfter, since their last
+// request or, without one, since they were registered or the server started.
+// Each is returned once until it is seen again.
+func (r *deviceRegistry) staleDevices(now time.Time, defaultStaleAfter time.Duration) []deviceStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var stale []deviceStatus
+	for id, d := range r.devices {
+		staleAfter := d.staleAfter(defaultStaleAfter)
+		if r.stale[id] || staleAfter == 0 {
+			continue
+		}
+		status := r.status(d)
+		since := r.started
+		if d.RegisteredAt.After(since) {
+			since = d.RegisteredAt
+		}
+		if status.LastSeen != nil {
+			since = *status.LastSeen
+		}
+		if now.Sub(since) >= staleAfter {
+			r.stale[id] = true
+			status.Stale = true
+			stale = append(stale, status)
+		}
+	}
+	slices.SortFunc(stale, func(a, b deviceStatus) int { return strings.Compare(a.ID, b.ID) })
+	return stale
+}
+
+// list returns the registered devices and their activity, ordered by name.
+func (r *deviceRegistry) list() []deviceStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	statuses := make([]deviceStatus, 0, len(r.devices))
+	for _, d := range r.devices {
+		statuses = append(statuses, r.status(d))
+	}
+	slices.SortFunc(statuses, func(a, b deviceStatus) int {
+		return cmp.Or(strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)), strings.Compare(a.ID, b.ID))
+	})
+	return statuses
+}
+
+// checkDevices fires device_stale hooks for registered devices that stopped
+// polling.
+func (s *Server) checkDevices(now time.Time) {
+	st := s.current()
+	for _, d := range s.devices.staleDevices(now, st.config.Devices.StaleAfter) {
+		s.logger.Warn("device stopped polling",
+			slog.String("device", d.ID),
+			slog.String("name", d.Name),
+			slog.Duration("stale_after", d.staleAfter(st.config.Devices.StaleAfter)),
+		)
+		// Previous and current are what the device was last sent.
+		last := hooks.Selection{Schedule: d.Schedule, Album: d.Album}
+		s.publish(st, hooks.Event{
+			Event:    config.HookEventDeviceStale,
+			Time:     now,
+			Previous: last,
+			Current:  last,
+			Device:   &hooks.Device{ID: d.ID, Name: d.Name, LastSeen: d.LastSeen},
+		})
+	}
+}
+
+// deviceSeen records a redirect served to a device and fires
+// device_recovered hooks when the device was stale.
+func (s *Server) deviceSeen(st *snapshot, id string, activity deviceActivity) {
+	previous, recovered := s.devices.seen(id, activity)
+	if !recovered {
+		return
+	}
+	s.logger.Info("device resumed polling", slog.String("device", previous.ID), slog.String("name", previous.Name))
+	s.publish(st, hooks.Event{
+		Event:    config.HookEventDeviceRecovered,
+		Time:     activity.LastSeen,
+		Previous: hooks.Selection{Schedule: previous.Schedule, Album: previous.Album},
+		Current:  hooks.Selection{Schedule: activity.Schedule, Album: activity.Album},
+		Device:   &hooks.Device{ID: previous.ID, Name: previous.Name, LastSeen: &activity.LastSeen},
+	})
+}
+
+// runDevices checks registered devices for staleness periodically until the
+// context is cancelled.
+func (s *Server) runDevices(ctx context.Context) {
+	ticker := time.NewTicker(deviceCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.checkDevices(now)
+		}
+	}
+}
+
+// validateDeviceID checks a device identifier.
+func validateDeviceID(id string) error {
+	if id == "" {
+		return fmt.Errorf("id is required")
+	}
+	if len(id) > maxDeviceIDLength {
+		return fmt.Errorf("id must be at most %d characters", maxDeviceIDLength)
+	}
+	if strings.ContainsFunc(id, unicode.IsControl) {
+		return fmt.Errorf("id must not contain control characters")
+	}
+	return nil
+}
+
+// handleListDevices lists the registered devices and when they were last
+// redirected.
+func (s *Server) handleListDevices(w http.ResponseWriter, r *http.Request) {
+	st := s.current()
+	devices := s.devices.list()
+	for i := range devices {
+		devices[i].AlbumName = s.albumName(st, devices[i].Album)
+	}
+	writeJSON(w, http.StatusOK, devicesResponse{Devices: devices})
+}
+
+// handleRegisterDevice registers a device, or updates a registered one.
+func (s *Server) handleRegisterDevice(w http.ResponseWriter, r *http.Request) {
+	var req registerDeviceRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	req.ID = strings.TrimSpace(req.ID)
+	if err := validateDeviceID(req.ID); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Name = strings.TrimSpace(req.Name); req.Name == "" {
+		req.Name = req.ID
+	}
+	if req.StaleAfter != "" {
+		staleAfter, err := time.ParseDuration(req.StaleAfter)
+		if err != nil || staleAfter < 0 || (staleAfter > 0 && staleAfter < deviceCheckInterval) {
+			writeError(w, http.StatusUnprocessableEntity, fmt.Sprintf("invalid stale_after %q: must be 0 or at least %s", req.StaleAfter, deviceCheckInterval))
+			return
+		}
+		req.StaleAfter = staleAfter.String()
+	}
+
+	st := s.current()
+	if req.Profile != "" {
+		if _, ok := st.profiles[req.Profile]; !ok {
+			writeError(w, http.StatusUnprocessableEntity, fmt.Sprintf("device profile %q not found", req.Profile))
+			return
+		}
+		if listed, ok := st.devices[req.ID]; ok {
+			writeError(w, http.StatusConflict, fmt.Sprintf("device %q is listed in device profile %q", req.ID, listed.name))
+			return
+		}
+	}
+
+	d, created := s.devices.register(registeredDevice{
+		ID:           req.ID,
+		Name:         req.Name,
+		Profile:      req.Profile,
+		StaleAfter:   req.StaleAfter,
+		RegisteredAt: time.Now().UTC(),
+	})
+
+	s.logger.Info("device registered via API",
+		slog.String("device", d.ID),
+		slog.String("profile", d.Profile),
+		slog.String("token", tokenName(r.Context())),
+	)
+	s.audit(r, auditEntry{Action: auditDeviceRegister, Target: d.ID, After: d})
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	writeJSON(w, status, d)
+}
+
+// handleRemoveDevice unregisters a device.
+func (s *Server) handleRemoveDevice(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	d, ok := s.devices.remove(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("device %q not found", id))
+		return
+	}
+
+	s.logger.Info("device removed via API",
+		slog.String("device", id),
+		slog.String("token", tokenName(r.Context())),
+	)
+	s.audit(r, auditEntry{Action: auditDeviceRemove, Target: id, Before: d})
+	w.WriteHeader(http.StatusNoContent)
+}
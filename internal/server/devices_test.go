@@ -0,0 +1,218 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/hooks"
+)
+
+func newDevicesTestServer(t *testing.T) *Server {
+	t.Helper()
+	cfg := newAPITestConfig()
+	cfg.APITokens = []config.APIToken{{Name: "automation", Token: testAPIToken}}
+	cfg.PassthroughParams = []string{"transition"}
+	cfg.DeviceProfiles = []config.DeviceProfile{
+		{Name: "eink", Devices: []string{"hallway"}, DefaultParams: map[string]string{"transition": "none"}},
+		{Name: "tv", Devices: []string{"living-room"}, DefaultParams: map[string]string{"duration": "10"}},
+	}
+	return newTestServer(t, cfg)
+}
+
+func listDevices(t *testing.T, srv *Server) []deviceStatus {
+	t.Helper()
+	rec := apiRequest(srv, http.MethodGet, "/api/v1/devices", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var resp devicesResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	return resp.Devices
+}
+
+func TestAPI_Devices(t *testing.T) {
+	srv := newDevicesTestServer(t)
+
+	rec := apiRequest(srv, http.MethodPost, "/api/v1/devices", `{"id": "kitchen", "name": "Kitchen frame", "profile": "tv"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	rec = apiRequest(srv, http.MethodPost, "/api/v1/devices", `{"id": "attic"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	req := httptest.NewRequest(http.MethodGet, "/?device=kitchen&transition=fade", nil)
+	req.Header.Set("User-Agent", "FrameBrowser/1.0")
+	rec = httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusFound, rec.Code)
+	u, err := url.Parse(rec.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "10", u.Query().Get("duration"), "registered profile applies")
+
+	devices := listDevices(t, srv)
+	require.Len(t, devices, 2)
+	assert.Equal(t, "attic", devices[0].Name, "name defaults to the ID")
+	assert.Nil(t, devices[0].LastSeen)
+	kitchen := devices[1]
+	assert.Equal(t, "Kitchen frame", kitchen.Name)
+	assert.Equal(t, "tv", kitchen.Profile)
+	require.NotNil(t, kitchen.LastSeen)
+	assert.Equal(t, "default-album-id", kitchen.Album)
+	assert.Equal(t, "FrameBrowser/1.0", kitchen.UserAgent)
+
+	// Re-registering updates the device and keeps its registration time.
+	rec = apiRequest(srv, http.MethodPost, "/api/v1/devices", `{"id": "kitchen", "name": "Kitchen"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var updated registeredDevice
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&updated))
+	assert.Equal(t, kitchen.RegisteredAt, updated.RegisteredAt)
+	assert.Empty(t, updated.Profile)
+
+	require.Equal(t, http.StatusNoContent, apiRequest(srv, http.MethodDelete, "/api/v1/devices/attic", "").Code)
+	assert.Equal(t, http.StatusNotFound, apiRequest(srv, http.MethodDelete, "/api/v1/devices/attic", "").Code)
+	assert.Len(t, listDevices(t, srv), 1)
+}
+
+func TestAPI_RegisterDeviceErrors(t *testing.T) {
+	srv := newDevicesTestServer(t)
+
+	for body, status := range map[string]int{
+		`{"name": "no id"}`:                        http.StatusBadRequest,
+		`{"id": "kitchen", "color": "red"}`:        http.StatusBadRequest,
+		`{"id": "kitchen", "profile": "missing"}`:  http.StatusUnprocessableEntity,
+		`{"id": "hallway", "profile": "tv"}`:       http.StatusConflict,
+		`{"id": "kitchen\u0000", "profile": "tv"}`: http.StatusBadRequest,
+	} {
+		rec := apiRequest(srv, http.MethodPost, "/api/v1/devices", body)
+		assert.Equal(t, status, rec.Code, body)
+	}
+	assert.Equal(t, http.StatusUnauthorized, guestRequest(srv, http.MethodPost, "/api/v1/devices").Code)
+}
+
+func TestDevices_SurviveRestart(t *testing.T) {
+	cfg := newAPITestConfig()
+	cfg.StateDir = t.TempDir()
+	cfg.APITokens = []config.APIToken{{Name: "automation", Token: testAPIToken}}
+	srv := newTestServer(t, cfg)
+	rec := apiRequest(srv, http.MethodPost, "/api/v1/devices", `{"id": "kitchen"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	devices := listDevices(t, newTestServer(t, cfg))
+	require.Len(t, devices, 1)
+	assert.Equal(t, "kitchen", devices[0].ID)
+}
+
+func TestDevices_LastSeenMetric(t *testing.T) {
+	srv := newDevicesTestServer(t)
+	require.Equal(t, http.StatusCreated, apiRequest(srv, http.MethodPost, "/api/v1/devices", `{"id": "metric-frame"}`).Code)
+
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?device=metric-frame", nil))
+	require.Equal(t, http.StatusFound, rec.Code)
+	assert.InDelta(t, float64(time.Now().Unix()), testutil.ToFloat64(deviceLastSeen.WithLabelValues("metric-frame")), 2)
+
+	require.Equal(t, http.StatusNoContent, apiRequest(srv, http.MethodDelete, "/api/v1/devices/metric-frame", "").Code)
+	assert.False(t, deviceLastSeen.DeleteLabelValues("metric-frame"), "series removed with the device")
+}
+
+func TestDevices_StaleHook(t *testing.T) {
+	events := make(chan hooks.Event, 4)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev hooks.Event
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&ev))
+		events <- ev
+	}))
+	defer ts.Close()
+
+	cfg := newAPITestConfig()
+	cfg.APITokens = []config.APIToken{{Name: "automation", Token: testAPIToken}}
+	cfg.Hooks = []config.HookConfig{{Name: "alerts", URL: ts.URL, Events: []string{config.HookEventDeviceStale}}}
+	cfg.Devices.StaleAfter = 10 * time.Minute
+	srv := newTestServer(t, cfg)
+	require.Equal(t, http.StatusCreated, apiRequest(srv, http.MethodPost, "/api/v1/devices", `{"id": "kitchen", "name": "Kitchen"}`).Code)
+	require.Equal(t, http.StatusCreated, apiRequest(srv, http.MethodPost, "/api/v1/devices", `{"id": "hallway"}`).Code)
+
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?device=kitchen", nil))
+	require.Equal(t, http.StatusFound, rec.Code)
+
+	srv.checkDevices(time.Now().Add(5 * time.Minute))
+	srv.hooks.Wait()
+	assert.Empty(t, events)
+
+	// Both are stale after ten minutes, and reported once.
+	srv.checkDevices(time.Now().Add(11 * time.Minute))
+	srv.checkDevices(time.Now().Add(12 * time.Minute))
+	srv.hooks.Wait()
+	require.Len(t, events, 2)
+	hallway, kitchen := <-events, <-events
+	if hallway.Device.ID != "hallway" {
+		hallway, kitchen = kitchen, hallway
+	}
+	assert.Equal(t, config.HookEventDeviceStale, kitchen.Event)
+	assert.Equal(t, "Kitchen", kitchen.Device.Name)
+	assert.NotNil(t, kitchen.Device.LastSeen)
+	assert.Equal(t, hooks.Selection{Schedule: "default", Album: "default-album-id"}, kitchen.Current)
+	assert.Nil(t, hallway.Device.LastSeen)
+
+	// A device seen again can go stale again.
+	rec = httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?device=kitchen", nil))
+	srv.checkDevices(time.Now().Add(11 * time.Minute))
+	srv.hooks.Wait()
+	require.Len(t, events, 1)
+	assert.Equal(t, "kitchen", (<-events).Device.ID)
+}
+
+func TestDevices_RecoveredHook(t *testing.T) {
+	events := make(chan hooks.Event, 4)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev hooks.Event
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&ev))
+		events <- ev
+	}))
+	defer ts.Close()
+
+	cfg := newAPITestConfig()
+	cfg.APITokens = []config.APIToken{{Name: "automation", Token: testAPIToken}}
+	cfg.Hooks = []config.HookConfig{{Name: "alerts", URL: ts.URL, Events: []string{config.HookEventDeviceStale, config.HookEventDeviceRecovered}}}
+	srv := newTestServer(t, cfg)
+
+	// Without devices.stale_after, only devices with their own interval are checked.
+	rec := apiRequest(srv, http.MethodPost, "/api/v1/devices", `{"id": "kitchen", "stale_after": "90s"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	require.Equal(t, http.StatusCreated, apiRequest(srv, http.MethodPost, "/api/v1/devices", `{"id": "hallway"}`).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, apiRequest(srv, http.MethodPost, "/api/v1/devices", `{"id": "attic", "stale_after": "10s"}`).Code)
+
+	srv.checkDevices(time.Now().Add(2 * time.Minute))
+	srv.hooks.Wait()
+	require.Len(t, events, 1)
+	stale := <-events
+	assert.Equal(t, config.HookEventDeviceStale, stale.Event)
+	assert.Equal(t, "kitchen", stale.Device.ID)
+	assert.True(t, listDevices(t, srv)[1].Stale)
+
+	rec = httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?device=kitchen", nil))
+	require.Equal(t, http.StatusFound, rec.Code)
+	srv.hooks.Wait()
+	require.Len(t, events, 1)
+	recovered := <-events
+	assert.Equal(t, config.HookEventDeviceRecovered, recovered.Event)
+	assert.Equal(t, "kitchen", recovered.Device.ID)
+	assert.NotNil(t, recovered.Device.LastSeen)
+	assert.Equal(t, hooks.Selection{}, recovered.Previous, "never seen before it went stale")
+	assert.Equal(t, hooks.Selection{Schedule: "default", Album: "default-album-id"}, recovered.Current)
+	assert.False(t, listDevices(t, srv)[1].Stale)
+
+	// Recovery is reported once.
+	rec = httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?device=kitchen", nil))
+	srv.hooks.Wait()
+	assert.Empty(t, events)
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/hooks"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/immich"
+)
+
+// withDiscovered appends the entries discovered from Immich to the
+// schedule, after the configured entries so those take precedence.
+// Discovered entries named like a configured entry are skipped. Callers
+// must hold reloadMu.
+func (s *Server) withDiscovered(cfg *config.Config) *config.Config {
+	if !cfg.Immich.Discovery || len(s.discovered) == 0 {
+		return cfg
+	}
+	clone := cfg.Clone()
+	for _, e := range s.discovered {
+		if !slices.ContainsFunc(cfg.Schedule, func(c config.ScheduleEntry) bool { return c.Name == e.Name }) {
+			clone.Schedule = append(clone.Schedule, e)
+		}
+	}
+	return clone
+}
+
+// discover updates the discovered entries from the synced albums. When
+// they changed, the schedule is rebuilt, discovery hooks are notified and
+// the selection is re-evaluated.
+func (s *Server) discover(albums []immich.Album) {
+	entries := immich.Discover(albums)
+
+	s.reloadMu.Lock()
+	if slices.EqualFunc(s.discovered, entries, func(a, b config.ScheduleEntry) bool { return a.Equal(b) }) {
+		s.reloadMu.Unlock()
+		return
+	}
+	added, removed := diffEntries(s.discovered, entries)
+
+	discovered := s.discovered
+	s.discovered = entries
+	st := s.current()
+	_, next, err := s.apply(st.config.WithoutProfile(st.profile))
+	if err != nil {
+		s.discovered = discovered
+		s.reloadMu.Unlock()
+		s.logger.Error("failed to apply discovered schedule entries", slog.Any("error", err))
+		return
+	}
+	s.reloadMu.Unlock()
+
+	s.logger.Info("schedule entries discovered from Immich",
+		slog.Any("added", added),
+		slog.Any("removed", removed),
+		slog.Int("discovered", len(entries)),
+		slog.String("revision", next.revision),
+	)
+	s.transitionMu.Lock()
+	active := s.active
+	s.transitionMu.Unlock()
+	s.publish(next, hooks.Event{
+		Event:    config.HookEventDiscovery,
+		Time:     time.Now(),
+		Reason:   hooks.ReasonDiscovery,
+		Previous: active,
+		Current:  active,
+		Added:    added,
+		Removed:  removed,
+	})
+	s.evaluate(hooks.ReasonDiscovery)
+}
+
+// diffEntries returns the names of the entries added and removed between
+// two discoveries.
+func diffEntries(previous, current []config.ScheduleEntry) (added, removed []string) {
+	has := func(entries []config.ScheduleEntry, name string) bool {
+		return slices.ContainsFunc(entries, func(e config.ScheduleEntry) bool { return e.Name == name })
+	}
+	for _, e := range current {
+		if !has(previous, e.Name) {
+			added = append(added, e.Name)
+		}
+	}
+	for _, e := range previous {
+		if !has(current, e.Name) {
+			removed = append(removed, e.Name)
+		}
+	}
+	return added, removed
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/hooks"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/immich"
+)
+
+func TestDiscovery_AddsAndRemovesEntries(t *testing.T) {
+	var albums atomic.Value
+	albums.Store(`[{"id": "easter-id", "albumName": "Easter [04-01..04-21]"}, {"id": "christmas-id", "albumName": "Christmas [12-01..12-31]"}]`)
+	immichSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("shared") == "true" {
+			_, _ = w.Write([]byte(`[]`))
+			return
+		}
+		_, _ = w.Write([]byte(albums.Load().(string)))
+	}))
+	defer immichSrv.Close()
+
+	events := make(chan hooks.Event, 4)
+	hookSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev hooks.Event
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&ev))
+		if ev.Event == config.HookEventDiscovery {
+			events <- ev
+		}
+	}))
+	defer hookSrv.Close()
+
+	cfg := newAPITestConfig()
+	cfg.Immich = config.ImmichConfig{URL: immichSrv.URL, APIKey: "key", Discovery: true}
+	cfg.Hooks = []config.HookConfig{{Name: "test", URL: hookSrv.URL}}
+	cfg.APITokens = []config.APIToken{{Name: "test", Token: testAPIToken}}
+	srv := newTestServer(t, cfg)
+	cache := immich.NewAlbumCache(immich.New(cfg.Immich), time.Hour, srv.Store(), srv.logger)
+	srv.SetAlbumCache(cache)
+	require.NoError(t, cache.Sync(context.Background()))
+
+	// The configured christmas entry wins over the discovered one.
+	schedule := srv.current().config.Schedule
+	require.Len(t, schedule, 2)
+	assert.Equal(t, "christmas-album", schedule[0].Album)
+	assert.Equal(t, config.ScheduleEntry{Name: "easter", Album: "easter-id", Start: "04-01", End: "04-21", Discovered: true}, schedule[1])
+	ev := <-events
+	assert.Equal(t, []string{"christmas", "easter"}, ev.Added)
+	assert.Equal(t, hooks.ReasonDiscovery, ev.Reason)
+
+	// Admin API changes keep discovered entries out of the configuration.
+	rec := apiRequest(srv, http.MethodPost, "/api/v1/schedules", `{"name": "summer", "album": "summer-album", "start": "07-01", "end": "08-31"}`)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	assert.Len(t, srv.current().config.Schedule, 3)
+
+	albums.Store(`[{"id": "christmas-id", "albumName": "Christmas [12-01..12-31]"}]`)
+	require.NoError(t, cache.Sync(context.Background()))
+	ev = <-events
+	assert.Equal(t, []string{"easter"}, ev.Removed)
+	assert.Empty(t, ev.Added)
+	for _, e := range srv.current().config.Schedule {
+		assert.NotEqual(t, "easter", e.Name)
+	}
+
+	// An unchanged sync sends no event.
+	require.NoError(t, cache.Sync(context.Background()))
+	srv.hooks.Wait()
+	assert.Empty(t, events)
+}
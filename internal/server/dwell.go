@@ -0,0 +1,93 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/scheduler"
+)
+
+// dwellDoc is the state document holding the selections kept for the
+// min_duration of their entries, so a restart does not cut them short.
+const dwellDoc = "dwell"
+
+// dwellSaveInterval is how often changed holds are saved, so requests
+// starting holds do not each write the state document.
+const dwellSaveInterval = 10 * time.Second
+
+// loadDwell returns the dwell with the persisted holds, if any.
+func (s *Server) loadDwell() *scheduler.Dwell {
+	var saved map[string]scheduler.Hold
+	if _, err := s.store.Load(dwellDoc, &saved); err != nil {
+		s.logger.Error("failed to load the held selections", slog.Any("error", err))
+	}
+	return scheduler.NewDwell(saved)
+}
+
+// resolve returns the decision of st for now, keeping a held selection.
+// Conditions are evaluated without a device, like the transition engine.
+func (s *Server) resolve(st *snapshot, now time.Time) scheduler.Decision {
+	return s.held(st, "", st.backend.Resolve(now), now)
+}
+
+// held returns d unless a selection of st for device is held past now by
+// its entry's min_duration. Only known devices, registered or listed in a
+// device profile, are held separately; others are not held, so arbitrary
+// device parameters cannot fill the holds.
+func (s *Server) held(st *snapshot, device string, d scheduler.Decision, now time.Time) scheduler.Decision {
+	if !st.dwells {
+		return d
+	}
+	key := st.dwellScope
+	if device != "" {
+		if !st.knownDevice(device) {
+			return d
+		}
+		key = strings.TrimPrefix(key+"/device/"+device, "/")
+	}
+	d, changed := s.dwell.Apply(key, d, now, st.backend.List())
+	if changed {
+		s.dwellDirty.Store(true)
+	}
+	return d
+}
+
+// knownDevice reports whether id is a registered device or listed in a
+// device profile.
+func (st *snapshot) knownDevice(id string) bool {
+	if _, ok := st.devices[id]; ok {
+		return true
+	}
+	return st.registry != nil && st.registry.registered(id)
+}
+
+// saveDwell persists the holds when they changed since the last save.
+func (s *Server) saveDwell() {
+	s.dwellMu.Lock()
+	defer s.dwellMu.Unlock()
+	if !s.dwellDirty.Swap(false) {
+		return
+	}
+	if err := s.store.Save(dwellDoc, s.dwell.Holds()); err != nil {
+		s.dwellDirty.Store(true)
+		s.logger.Error("failed to persist the held selections", slog.Any("error", err))
+	}
+}
+
+// runDwell saves changed holds periodically until the context is
+// cancelled.
+func (s *Server) runDwell(ctx context.Context) {
+	ticker := time.NewTicker(dwellSaveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.saveDwell()
+		}
+	}
+}
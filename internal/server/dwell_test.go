@@ -0,0 +1,53 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+)
+
+func TestServer_MinDuration(t *testing.T) {
+	cfg := newAPITestConfig()
+	cfg.StateDir = t.TempDir()
+	cfg.Schedule = []config.ScheduleEntry{
+		{Name: "party", Album: "party-album", Start: "01-01", End: "12-31", When: `query.mode == "party"`, MinDuration: "1h"},
+		{Name: "always", Album: "always-album", Start: "01-01", End: "12-31"},
+	}
+	srv := newTestServer(t, cfg)
+	for _, id := range []string{"tv", "den"} {
+		srv.devices.register(registeredDevice{ID: id})
+	}
+
+	target := func(srv *Server, query string) string {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		srv.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?"+query, nil))
+		require.Equal(t, http.StatusFound, rec.Code)
+		return rec.Header().Get("Location")
+	}
+
+	assert.Contains(t, target(srv, "device=tv&mode=party"), "album=party-album")
+	// The condition no longer holds, but the entry is kept for an hour.
+	assert.Contains(t, target(srv, "device=tv"), "album=party-album")
+	// Displays are held separately when conditions depend on the request.
+	assert.Contains(t, target(srv, "device=den"), "album=always-album")
+	// Unknown devices are not held.
+	assert.Contains(t, target(srv, "device=kitchen&mode=party"), "album=party-album")
+	assert.Contains(t, target(srv, "device=kitchen"), "album=always-album")
+	assert.NotContains(t, srv.dwell.Holds(), "device/kitchen")
+
+	// Holds are saved in batches, not on every request.
+	assert.NoFileExists(t, filepath.Join(cfg.StateDir, dwellDoc+".json"))
+
+	// A restart keeps the saved hold.
+	srv.saveDwell()
+	assert.FileExists(t, filepath.Join(cfg.StateDir, dwellDoc+".json"))
+	restarted := newTestServer(t, cfg)
+	assert.Contains(t, target(restarted, "device=tv"), "album=party-album")
+}
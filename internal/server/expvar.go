@@ -0,0 +1,88 @@
+package server
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net/http"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/immich"
+)
+
+// Lookups of the cached redirect base, reported on /debug/vars.
+var (
+	redirectBaseHits   atomic.Uint64
+	redirectBaseMisses atomic.Uint64
+)
+
+// cacheVars describes the use of a cache.
+type cacheVars struct {
+	Hits    uint64  `json:"hits"`
+	Misses  uint64  `json:"misses"`
+	HitRate float64 `json:"hit_rate"`
+}
+
+// newCacheVars computes the hit rate of a cache.
+func newCacheVars(hits, misses uint64) cacheVars {
+	v := cacheVars{Hits: hits, Misses: misses}
+	if total := hits + misses; total > 0 {
+		v.HitRate = float64(hits) / float64(total)
+	}
+	return v
+}
+
+// schedulerVars are the scheduler's own entries of /debug/vars.
+type schedulerVars struct {
+	Schedule       string              `json:"schedule"`
+	Album          string              `json:"album"`
+	Profile        string              `json:"profile,omitempty"`
+	ConfigRevision string              `json:"config_revision"`
+	Override       bool                `json:"override"`
+	Maintenance    bool                `json:"maintenance"`
+	RedirectsToday map[string]int64    `json:"redirects_today"`
+	RedirectCache  cacheVars           `json:"redirect_cache"`
+	AlbumCache     *immich.CacheStatus `json:"album_cache,omitempty"`
+}
+
+// debugVars collects the scheduler vars.
+func (s *Server) debugVars(now time.Time) schedulerVars {
+	st := s.current()
+	selection := s.selectionAt(st, now)
+	vars := schedulerVars{
+		Schedule:       selection.Schedule,
+		Album:          selection.Album,
+		Profile:        st.profile,
+		ConfigRevision: st.revision,
+		Override:       s.activeOverride(now) != nil,
+		Maintenance:    st.config.Maintenance.Enabled,
+		RedirectsToday: s.statsFor(1, now).Totals.Redirects,
+		RedirectCache:  newCacheVars(redirectBaseHits.Load(), redirectBaseMisses.Load()),
+	}
+	if s.albums != nil {
+		status := s.albums.Status()
+		vars.AlbumCache = &status
+	}
+	return vars
+}
+
+// handleDebugVars serves the published expvars, such as the command line
+// and memory statistics, with the goroutine count and scheduler vars, in
+// the format of expvar.Handler.
+func (s *Server) handleDebugVars(w http.ResponseWriter, r *http.Request) {
+	scheduler, err := json.Marshal(s.debugVars(time.Now()))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	fmt.Fprintf(w, "{\n")
+	expvar.Do(func(kv expvar.KeyValue) {
+		fmt.Fprintf(w, "%q: %s,\n", kv.Key, kv.Value)
+	})
+	fmt.Fprintf(w, "%q: %d,\n", "goroutines", runtime.NumGoroutine())
+	fmt.Fprintf(w, "%q: %s\n}\n", "scheduler", scheduler)
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebugVars(t *testing.T) {
+	cfg := newAPITestConfig()
+	cfg.Debug.Expvar = true
+	srv := newTestServer(t, cfg)
+	schedule := srv.selectionAt(srv.current(), time.Now()).Schedule
+
+	for range 2 {
+		rec := httptest.NewRecorder()
+		srv.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		require.Equal(t, http.StatusFound, rec.Code)
+	}
+
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var vars struct {
+		Memstats   map[string]any `json:"memstats"`
+		Goroutines int            `json:"goroutines"`
+		Scheduler  schedulerVars  `json:"scheduler"`
+	}
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&vars))
+	assert.NotEmpty(t, vars.Memstats)
+	assert.Positive(t, vars.Goroutines)
+	assert.Equal(t, schedule, vars.Scheduler.Schedule)
+	assert.Equal(t, int64(2), vars.Scheduler.RedirectsToday[schedule])
+	assert.Positive(t, vars.Scheduler.RedirectCache.Hits)
+	assert.Positive(t, vars.Scheduler.RedirectCache.HitRate)
+}
+
+func TestDebugVars_Disabled(t *testing.T) {
+	srv := newTestServer(t, newAPITestConfig())
+
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestNewCacheVars(t *testing.T) {
+	assert.Equal(t, cacheVars{Hits: 3, Misses: 1, HitRate: 0.75}, newCacheVars(3, 1))
+	assert.Equal(t, cacheVars{}, newCacheVars(0, 0))
+}
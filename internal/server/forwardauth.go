@@ -0,0 +1,156 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+)
+
+// forwardAuthUserHeader names the response header carrying the matched
+// token name or "network", for proxies that copy it to the upstream request.
+const forwardAuthUserHeader = "X-IKS-Auth-User"
+
+var forwardAuthTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "immich_kiosk_scheduler_forward_auth_total",
+		Help: "Total number of forward-auth checks by result (token, network, denied)",
+	},
+	[]string{"result"},
+)
+
+func init() {
+	prometheus.MustRegister(forwardAuthTotal)
+}
+
+// handleForwardAuth answers reverse proxy forward-auth checks with 200 when
+// the original request carries a configured token or comes from an allowed
+// network, and 401 otherwise.
+func (s *Server) handleForwardAuth(w http.ResponseWriter, r *http.Request) {
+	st := s.current()
+	cfg := st.config.ForwardAuth
+	if !cfg.Enabled {
+		s.handleNotFound(w, r)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+
+	if presented := forwardAuthToken(r, cfg.Param()); presented != "" {
+		for _, t := range cfg.Tokens {
+			if subtle.ConstantTimeCompare([]byte(presented), []byte(t.Token)) == 1 {
+				forwardAuthTotal.WithLabelValues("token").Inc()
+				w.Header().Set(forwardAuthUserHeader, t.Name)
+				setKioskAuthHeader(w, st.config.KioskAuth)
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+		}
+	}
+
+	if addr, ok := clientAddr(r, st.trustedProxies); ok {
+		for _, prefix := range st.authNetworks {
+			if prefix.Contains(addr) {
+				forwardAuthTotal.WithLabelValues("network").Inc()
+				w.Header().Set(forwardAuthUserHeader, "network")
+				setKioskAuthHeader(w, st.config.KioskAuth)
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+		}
+	}
+
+	forwardAuthTotal.WithLabelValues("denied").Inc()
+	w.Header().Set("WWW-Authenticate", `Bearer realm="immich-kiosk-scheduler"`)
+	http.Error(w, "Unauthorized", http.StatusUnauthorized)
+}
+
+// setKioskAuthHeader adds the kiosk password to an allowed forward-auth
+// response, when kiosk_auth.header is set.
+func setKioskAuthHeader(w http.ResponseWriter, auth config.KioskAuthConfig) {
+	if auth.Header != "" && auth.Password != "" {
+		w.Header().Set(auth.Header, auth.Password)
+	}
+}
+
+// forwardAuthToken returns the token of the original request: a bearer token,
+// or the token query parameter of the URI passed in X-Forwarded-Uri.
+func forwardAuthToken(r *http.Request, param string) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return token
+	}
+	if uri := r.Header.Get("X-Forwarded-Uri"); uri != "" {
+		if u, err := url.Parse(uri); err == nil {
+			return u.Query().Get(param)
+		}
+	}
+	return ""
+}
+
+// peerKey is the context key of the address of the TCP peer, kept before the
+// RealIP middleware replaces RemoteAddr with forwarding headers that any
+// client can send.
+type peerKey struct{}
+
+// peerMiddleware keeps the address of the TCP peer for clientAddr.
+func peerMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), peerKey{}, r.RemoteAddr)))
+	})
+}
+
+// clientAddr returns the client address: the TCP peer's, or, when the peer
+// is one of trusted, the last untrusted address of its X-Forwarded-For or
+// X-Real-IP header.
+func clientAddr(r *http.Request, trusted []netip.Prefix) (netip.Addr, bool) {
+	remote, ok := r.Context().Value(peerKey{}).(string)
+	if !ok {
+		remote = r.RemoteAddr
+	}
+	host := remote
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	peer, ok := parseAddr(host)
+	if !ok || !containsAddr(trusted, peer) {
+		return peer, ok
+	}
+
+	if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+		hops := strings.Split(strings.Join(forwarded, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			addr, ok := parseAddr(hops[i])
+			if !ok {
+				return netip.Addr{}, false
+			}
+			if i == 0 || !containsAddr(trusted, addr) {
+				return addr, true
+			}
+		}
+	}
+	if real := r.Header.Get("X-Real-IP"); real != "" {
+		return parseAddr(real)
+	}
+	return peer, true
+}
+
+// parseAddr parses an IP address, unmapping IPv4-mapped IPv6 addresses.
+func parseAddr(s string) (netip.Addr, bool) {
+	addr, err := netip.ParseAddr(strings.TrimSpace(s))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+// containsAddr reports whether one of prefixes contains addr.
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	return slices.ContainsFunc(prefixes, func(p netip.Prefix) bool { return p.Contains(addr) })
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"log/slog"
+	"maps"
+	"net/http"
+	"time"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/hooks"
+)
+
+// freeze pins the selection served now until released: the schedule entry
+// and its params, or the running override's album and params. Freezing
+// twice keeps the first freeze. It returns nil when nothing is served, i.e.
+// during quiet hours without a night album.
+func (s *Server) freeze(st *snapshot, now time.Time) *albumOverride {
+	current := s.selectionAt(st, now)
+	if current.Album == "" {
+		return nil
+	}
+	active := s.activeOverride(now)
+	if active != nil && active.Mode == overrideModeFreeze {
+		return active
+	}
+
+	o := &albumOverride{Mode: overrideModeFreeze, Name: current.Schedule, Album: current.Album}
+	if active != nil {
+		o.Params = maps.Clone(active.Params)
+	} else if d := s.resolve(st, now); d.Entry != nil && d.Schedule == current.Schedule {
+		o.entry = d.Entry
+	}
+	s.startOverride(o, hooks.ReasonUpdate)
+	return o
+}
+
+// handleFreeze keeps showing what is shown now, ignoring schedule changes
+// until the freeze is released.
+func (s *Server) handleFreeze(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	before := s.activeOverride(now)
+	o := s.freeze(s.current(), now)
+	if o == nil {
+		writeError(w, http.StatusConflict, "nothing to freeze: quiet hours are active without a night album")
+		return
+	}
+	if o != before {
+		s.logger.Info("selection frozen via API", slog.String("schedule", o.Name), slog.String("token", tokenName(r.Context())))
+		s.audit(r, auditEntry{Action: auditFreezeStart, Target: o.Name, Before: before, After: o})
+	}
+	writeJSON(w, http.StatusOK, o)
+}
+
+// handleUnfreeze releases a freeze, returning to the schedule. Other
+// overrides are left running.
+func (s *Server) handleUnfreeze(w http.ResponseWriter, r *http.Request) {
+	active := s.activeOverride(time.Now())
+	if s.clearOverride(overrideModeFreeze, hooks.ReasonUpdate) {
+		s.logger.Info("freeze released via API", slog.String("token", tokenName(r.Context())))
+		s.audit(r, auditEntry{Action: auditFreezeStop, Target: active.Name, Before: active})
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+)
+
+func TestAPI_Freeze(t *testing.T) {
+	cfg := newAPITestConfig()
+	cfg.APITokens = []config.APIToken{{Name: "automation", Token: testAPIToken}}
+	cfg.Schedule = []config.ScheduleEntry{
+		{Name: "always", Album: "always-album", Start: "01-01", End: "12-31", Params: map[string]string{"transition": "fade"}},
+	}
+	srv := newTestServer(t, cfg)
+
+	rec := apiRequest(srv, http.MethodPost, "/api/v1/freeze", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.NotContains(t, rec.Body.String(), "until")
+	var o albumOverride
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&o))
+	assert.Equal(t, overrideModeFreeze, o.Mode)
+	assert.Equal(t, "always", o.Name)
+	assert.Equal(t, "always-album", o.Album)
+
+	// Schedule changes wait for the freeze to be released.
+	rec = apiRequest(srv, http.MethodPut, "/api/v1/schedules/always",
+		`{"name": "always", "album": "other-album", "start": "01-01", "end": "12-31"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	target := redirectTarget(t, srv)
+	assert.Contains(t, target, "album=always-album")
+	assert.Contains(t, target, "transition=fade")
+	assert.Equal(t, "always", srv.active.Schedule)
+
+	// Freezing again keeps the first freeze.
+	frozen := srv.override.Load()
+	rec = apiRequest(srv, http.MethodPost, "/api/v1/freeze", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Same(t, frozen, srv.override.Load())
+
+	rec = apiRequest(srv, http.MethodDelete, "/api/v1/freeze", "")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Nil(t, srv.override.Load())
+	assert.Contains(t, redirectTarget(t, srv), "album=other-album")
+
+	rec = apiRequest(srv, http.MethodGet, "/api/v1/audit?action="+auditFreezeStart, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var audit auditResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&audit))
+	assert.Len(t, audit.Entries, 1)
+}
+
+func TestAPI_UnfreezeKeepsOverride(t *testing.T) {
+	srv := newChatTestServer(t)
+
+	rec := apiRequest(srv, http.MethodPost, "/api/v1/override", `{"album": "garden"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	rec = apiRequest(srv, http.MethodDelete, "/api/v1/freeze", "")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	require.NotNil(t, srv.override.Load())
+
+	// Freezing an override keeps its album until released.
+	rec = apiRequest(srv, http.MethodPost, "/api/v1/freeze", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	o := srv.override.Load()
+	assert.Equal(t, overrideModeFreeze, o.Mode)
+	assert.Equal(t, "garden-album", o.Album)
+	assert.True(t, o.Until.IsZero())
+}
+
+func TestAPI_FreezeQuietHours(t *testing.T) {
+	cfg := newAPITestConfig()
+	cfg.APITokens = []config.APIToken{{Name: "automation", Token: testAPIToken}}
+	cfg.QuietHours = quietHoursNow(config.QuietHoursNoAlbum)
+	srv := newTestServer(t, cfg)
+
+	rec := apiRequest(srv, http.MethodPost, "/api/v1/freeze", "")
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Nil(t, srv.override.Load())
+}
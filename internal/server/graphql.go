@@ -0,0 +1,158 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/graphql"
+)
+
+// maxGraphQLTransitions bounds the transitions argument.
+const maxGraphQLTransitions = 50
+
+// graphQLTransition is an upcoming schedule change.
+type graphQLTransition struct {
+	At        time.Time `json:"at"`
+	Schedule  string    `json:"schedule"`
+	Album     string    `json:"album"`
+	AlbumName string    `json:"album_name,omitempty"`
+}
+
+// scalarFields returns fields read from the JSON fields of the same names.
+func scalarFields(names ...string) map[string]*graphql.Field {
+	fields := make(map[string]*graphql.Field, len(names))
+	for _, name := range names {
+		fields[name] = &graphql.Field{}
+	}
+	return fields
+}
+
+// graphQLSchema returns the read-only GraphQL schema over the status,
+// schedule entries, upcoming transitions and devices. Field names follow
+// the JSON of the REST API.
+func (s *Server) graphQLSchema() *graphql.Schema {
+	warning := &graphql.Object{Name: "Warning", Fields: scalarFields("kind", "message", "entries", "start", "end")}
+	override := &graphql.Object{Name: "Override", Fields: scalarFields("mode", "name", "album", "params", "until")}
+	status := &graphql.Object{Name: "Status", Fields: scalarFields(
+		"schedule", "album", "album_name", "default_album", "default_album_name",
+		"schedule_count", "config_revision", "maintenance", "profile")}
+	status.Fields["override"] = &graphql.Field{Type: override}
+	status.Fields["warnings"] = &graphql.Field{Type: warning}
+	schedule := &graphql.Object{Name: "Schedule", Fields: scalarFields(
+		"name", "album", "albums", "start", "end", "end_boundary", "rrule", "days", "when", "params", "remove_params", "rotate_minutes", "min_duration", "discovered")}
+	transition := &graphql.Object{Name: "Transition", Fields: scalarFields("at", "schedule", "album", "album_name")}
+	device := &graphql.Object{Name: "Device", Fields: scalarFields(
+		"id", "name", "profile", "stale_after", "registered_at", "last_seen",
+		"album", "album_name", "schedule", "user_agent", "stale")}
+
+	return &graphql.Schema{Query: &graphql.Object{Name: "Query", Fields: map[string]*graphql.Field{
+		"status": {Type: status, Resolve: func(any, map[string]any) (any, error) {
+			return s.graphQLStatus(), nil
+		}},
+		"schedules": {Type: schedule, Resolve: func(any, map[string]any) (any, error) {
+			return s.current().config.Schedule, nil
+		}},
+		"schedule": {Type: schedule, Args: map[string]any{"name": nil}, Resolve: func(_ any, args map[string]any) (any, error) {
+			name, ok, err := graphql.String(args, "name")
+			if err != nil || !ok {
+				return nil, fmt.Errorf("argument \"name\" is required")
+			}
+			schedules := s.current().config.Schedule
+			if i := scheduleIndex(schedules, name); i >= 0 {
+				return schedules[i], nil
+			}
+			return nil, nil
+		}},
+		"transitions": {Type: transition, Args: map[string]any{"count": 5}, Resolve: func(_ any, args map[string]any) (any, error) {
+			count, _, err := graphql.Int(args, "count")
+			if err != nil {
+				return nil, err
+			}
+			if count < 1 || count > maxGraphQLTransitions {
+				return nil, fmt.Errorf("count must be between 1 and %d", maxGraphQLTransitions)
+			}
+			return s.upcomingTransitions(time.Now(), count), nil
+		}},
+		"devices": {Type: device, Resolve: func(any, map[string]any) (any, error) {
+			st := s.current()
+			devices := s.devices.list()
+			for i := range devices {
+				devices[i].AlbumName = s.albumName(st, devices[i].Album)
+			}
+			return devices, nil
+		}},
+	}}}
+}
+
+// graphQLStatus returns the fields of the status response the GraphQL
+// schema exposes.
+func (s *Server) graphQLStatus() *statusResponse {
+	st := s.current()
+	now := time.Now()
+	selection := s.selectionAt(st, now)
+	return &statusResponse{
+		Schedule:         selection.Schedule,
+		Album:            selection.Album,
+		AlbumName:        s.albumName(st, selection.Album),
+		DefaultAlbum:     st.scheduler.GetDefaultAlbum(),
+		DefaultAlbumName: s.albumName(st, st.scheduler.GetDefaultAlbum()),
+		ScheduleCount:    st.scheduler.GetScheduleCount(),
+		ConfigRevision:   st.revision,
+		Warnings:         st.scheduler.Warnings(),
+		Override:         s.activeOverride(now),
+		Maintenance:      st.config.Maintenance.Enabled,
+		Profile:          st.profile,
+	}
+}
+
+// upcomingTransitions returns up to count schedule changes after now.
+func (s *Server) upcomingTransitions(now time.Time, count int) []graphQLTransition {
+	st := s.current()
+	transitions := []graphQLTransition{}
+	for t := now; len(transitions) < count; {
+		at, next, ok := st.backend.NextTransition(t)
+		if !ok {
+			break
+		}
+		transitions = append(transitions, graphQLTransition{
+			At:        at,
+			Schedule:  next.Schedule,
+			Album:     next.Album,
+			AlbumName: s.albumName(st, next.Album),
+		})
+		t = at
+	}
+	return transitions
+}
+
+// handleGraphQL runs a read-only GraphQL query, sent as a JSON body or in
+// the query string of a GET request.
+func (s *Server) handleGraphQL(w http.ResponseWriter, r *http.Request) {
+	var req graphql.Request
+	if r.Method == http.MethodGet {
+		q := r.URL.Query()
+		req.Query, req.OperationName = q.Get("query"), q.Get("operationName")
+		if vars := q.Get("variables"); vars != "" {
+			if err := json.Unmarshal([]byte(vars), &req.Variables); err != nil {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid variables: %v", err))
+				return
+			}
+		}
+	} else if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err))
+		return
+	}
+	if req.Query == "" {
+		writeError(w, http.StatusBadRequest, "query is required")
+		return
+	}
+
+	resp := s.graphql.Execute(req)
+	status := http.StatusOK
+	if resp.Data == nil {
+		status = http.StatusBadRequest
+	}
+	writeJSON(w, status, resp)
+}
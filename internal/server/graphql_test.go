@@ -0,0 +1,74 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+)
+
+func graphQLRequest(t *testing.T, srv *Server, body string) map[string]any {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/graphql", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var resp map[string]any
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	require.Nil(t, resp["errors"])
+	return resp["data"].(map[string]any)
+}
+
+func TestGraphQL_Query(t *testing.T) {
+	cfg := newAPITestConfig()
+	cfg.Schedule = append(cfg.Schedule, config.ScheduleEntry{Name: "summer", Album: "summer-album", Start: "06-01", End: "09-01"})
+	srv := newTestServer(t, cfg)
+	srv.devices.devices["hallway"] = registeredDevice{ID: "hallway", Name: "Hallway", RegisteredAt: time.Now()}
+
+	data := graphQLRequest(t, srv, `{"query": "query Dashboard($n: Int) { status { schedule default_album } next: transitions(count: $n) { at schedule album } schedules { name } devices { id name stale } }", "variables": {"n": 3}}`)
+
+	status := data["status"].(map[string]any)
+	assert.Equal(t, "default-album-id", status["default_album"])
+	assert.Equal(t, srv.active.Schedule, status["schedule"])
+
+	next := data["next"].([]any)
+	require.Len(t, next, 3)
+	first := next[0].(map[string]any)
+	at, err := time.Parse(time.RFC3339, first["at"].(string))
+	require.NoError(t, err)
+	assert.True(t, at.After(time.Now()))
+	assert.NotEqual(t, srv.active.Schedule, first["schedule"])
+
+	assert.Equal(t, []any{map[string]any{"name": "christmas"}, map[string]any{"name": "summer"}}, data["schedules"])
+	assert.Equal(t, []any{map[string]any{"id": "hallway", "name": "Hallway", "stale": false}}, data["devices"])
+}
+
+func TestGraphQL_GetAndErrors(t *testing.T) {
+	srv := newTestServer(t, newAPITestConfig())
+
+	q := url.Values{"query": {`query($name: String!) { schedule(name: $name) { album } }`}, "variables": {`{"name": "christmas"}`}}
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/graphql?"+q.Encode(), nil))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.JSONEq(t, `{"data": {"schedule": {"album": "christmas-album"}}}`, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/graphql?query="+url.QueryEscape("{ status { password } }"), nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), `Cannot query field \"password\" on type \"Status\".`)
+
+	rec = httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/graphql", strings.NewReader(`{"query": "{ transitions(count: 500) { at } }"}`)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"data": {"transitions": null}, "errors": [{"message": "count must be between 1 and 50", "locations": [{"line": 1, "column": 3}], "path": ["transitions"]}]}`, rec.Body.String())
+}
@@ -0,0 +1,254 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/guestlink"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/hooks"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/state"
+)
+
+// defaultGuestLinkValidity applies to generated links without valid_for.
+const defaultGuestLinkValidity = 24 * time.Hour
+
+// guestLinksDoc is the state document holding the IDs of redeemed guest
+// links with their expiry, so a restart does not make them usable again.
+const guestLinksDoc = "guest-links"
+
+// guestLinkRequest is the body of POST /api/v1/guest-links.
+type guestLinkRequest struct {
+	// Mode is "album" for a control page album or "party" for a party mode.
+	Mode string `json:"mode"`
+	Name string `json:"name"`
+	// ValidFor is how long the link can be redeemed, e.g. "72h".
+	ValidFor string `json:"valid_for,omitempty"`
+	// Duration overrides how long the override lasts once redeemed.
+	Duration string `json:"duration,omitempty"`
+}
+
+// guestLinkResponse is the response to POST /api/v1/guest-links.
+type guestLinkResponse struct {
+	ID      string    `json:"id"`
+	URL     string    `json:"url"`
+	Expires time.Time `json:"expires"`
+}
+
+// guestPage is the data of the guest confirmation and result page.
+type guestPage struct {
+	Name     string
+	Duration string
+	Until    string
+	Error    string
+	Done     bool
+}
+
+// redeemedLinks remembers redeemed guest link IDs until the links expire.
+type redeemedLinks struct {
+	store  *state.Store
+	logger *slog.Logger
+
+	mu  sync.Mutex
+	ids map[string]time.Time
+}
+
+// newRedeemedLinks returns the redeemed links, with those persisted in store.
+func newRedeemedLinks(store *state.Store, logger *slog.Logger) *redeemedLinks {
+	l := &redeemedLinks{store: store, logger: logger}
+	if _, err := store.Load(guestLinksDoc, &l.ids); err != nil {
+		logger.Error("failed to load redeemed guest links", slog.Any("error", err))
+	}
+	if l.ids == nil {
+		l.ids = make(map[string]time.Time)
+	}
+	return l
+}
+
+// used reports whether the link has been redeemed.
+func (l *redeemedLinks) used(id string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, ok := l.ids[id]
+	return ok
+}
+
+// redeem marks the link as redeemed, reporting false if it already was.
+func (l *redeemedLinks) redeem(claims guestlink.Claims, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	maps.DeleteFunc(l.ids, func(_ string, expires time.Time) bool { return !now.Before(expires) })
+	if _, ok := l.ids[claims.ID]; ok {
+		return false
+	}
+	l.ids[claims.ID] = claims.ExpiresAt()
+	if err := l.store.Save(guestLinksDoc, l.ids); err != nil {
+		l.logger.Error("failed to persist redeemed guest links", slog.Any("error", err))
+	}
+	return true
+}
+
+// guestOverride resolves the override a guest link grants.
+func (st *snapshot) guestOverride(claims guestlink.Claims) (*albumOverride, error) {
+	switch claims.Mode {
+	case overrideModeAlbum:
+		if a, ok := st.controlAlbum(claims.Name); ok {
+			return newAlbumOverride(a, claims.Duration), nil
+		}
+	case overrideModeParty:
+		if mode, ok := st.partyMode(claims.Name); ok {
+			return newPartyOverride(mode, claims.Duration), nil
+		}
+	default:
+		return nil, fmt.Errorf("unknown mode %q", claims.Mode)
+	}
+	return nil, fmt.Errorf("%s %q is not configured", claims.Mode, claims.Name)
+}
+
+// guestRoutes configures the /guest routes.
+func (s *Server) guestRoutes(r chi.Router) {
+	r.Get("/{token}", s.handleGuestLink)
+	r.Post("/{token}", s.handleGuestLink)
+}
+
+// handleGuestLink shows what a guest link grants and, on POST, redeems it.
+// Showing a confirmation first keeps link previews in chat apps from using
+// up single-use links.
+func (s *Server) handleGuestLink(w http.ResponseWriter, r *http.Request) {
+	st := s.current()
+	if !st.config.GuestLinks.Enabled() {
+		s.handleNotFound(w, r)
+		return
+	}
+
+	now := time.Now()
+	fail := func(status int, message string) {
+		s.renderUI(w, status, "guest", "Photo frame", 0, guestPage{Error: message})
+	}
+
+	claims, err := guestlink.Verify(st.config.GuestLinks.Secret, chi.URLParam(r, "token"), now)
+	switch {
+	case errors.Is(err, guestlink.ErrExpired):
+		fail(http.StatusGone, "This link has expired.")
+		return
+	case err != nil:
+		fail(http.StatusForbidden, "This link is not valid.")
+		return
+	case s.guestLinks.used(claims.ID):
+		fail(http.StatusGone, "This link has already been used.")
+		return
+	}
+
+	o, err := st.guestOverride(claims)
+	if err != nil {
+		s.logger.Warn("guest link target unavailable", slog.String("link", claims.ID), slog.Any("error", err))
+		fail(http.StatusGone, "This link is no longer available.")
+		return
+	}
+	page := guestPage{Name: o.Name, Duration: formatDuration(o.Until.Sub(now).Round(time.Minute))}
+
+	if r.Method == http.MethodPost {
+		if crossSite(r) {
+			fail(http.StatusForbidden, "This link must be opened directly.")
+			return
+		}
+		if !s.guestLinks.redeem(claims, now) {
+			fail(http.StatusGone, "This link has already been used.")
+			return
+		}
+
+		s.logger.Info("guest link redeemed", slog.String("link", claims.ID), slog.String("mode", o.Mode), slog.String("name", o.Name))
+		s.startOverride(o, hooks.ReasonGuest)
+		page.Done = true
+		page.Until = o.Until.Format("15:04")
+	}
+
+	s.renderUI(w, http.StatusOK, "guest", "Photo frame", 0, page)
+}
+
+// handleCreateGuestLink signs a guest link for a configured album or party mode.
+func (s *Server) handleCreateGuestLink(w http.ResponseWriter, r *http.Request) {
+	st := s.current()
+	cfg := st.config.GuestLinks
+	if !cfg.Enabled() {
+		writeError(w, http.StatusNotFound, "guest links are not enabled")
+		return
+	}
+
+	var req guestLinkRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	validFor := defaultGuestLinkValidity
+	if req.ValidFor != "" {
+		d, err := time.ParseDuration(req.ValidFor)
+		if err != nil || d <= 0 {
+			writeError(w, http.StatusUnprocessableEntity, fmt.Sprintf("invalid valid_for %q", req.ValidFor))
+			return
+		}
+		validFor = d
+	}
+	if validFor > cfg.MaxValidity {
+		writeError(w, http.StatusUnprocessableEntity, fmt.Sprintf("valid_for must not exceed %s", cfg.MaxValidity))
+		return
+	}
+
+	id, err := guestlink.NewID()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to generate link")
+		return
+	}
+	claims := guestlink.Claims{ID: id, Mode: req.Mode, Name: req.Name, Expires: time.Now().Add(validFor).Unix()}
+	if req.Duration != "" {
+		if claims.Duration, err = time.ParseDuration(req.Duration); err != nil || claims.Duration <= 0 {
+			writeError(w, http.StatusUnprocessableEntity, fmt.Sprintf("invalid duration %q", req.Duration))
+			return
+		}
+	}
+	if _, err := st.guestOverride(claims); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	token, err := guestlink.Sign(cfg.Secret, claims)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to sign link")
+		return
+	}
+
+	s.logger.Info("guest link created",
+		slog.String("link", id),
+		slog.String("mode", claims.Mode),
+		slog.String("name", claims.Name),
+		slog.Time("expires", claims.ExpiresAt()),
+		slog.String("token", tokenName(r.Context())),
+	)
+	s.audit(r, auditEntry{Action: auditGuestLinkCreate, Target: claims.Name, After: claims})
+	writeJSON(w, http.StatusCreated, guestLinkResponse{
+		ID:      id,
+		URL:     guestBaseURL(r, cfg.BaseURL) + "/guest/" + token,
+		Expires: claims.ExpiresAt(),
+	})
+}
+
+// guestBaseURL returns the configured base URL, or one derived from the request.
+func guestBaseURL(r *http.Request, base string) string {
+	if base != "" {
+		return strings.TrimRight(base, "/")
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
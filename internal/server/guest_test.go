@@ -0,0 +1,182 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/guestlink"
+)
+
+const testGuestSecret = "0123456789abcdef0123456789abcdef"
+
+func newGuestTestServer(t *testing.T) *Server {
+	t.Helper()
+	return newTestServer(t, newGuestTestConfig())
+}
+
+func newGuestTestConfig() *config.Config {
+	cfg := newAPITestConfig()
+	cfg.Schedule = nil
+	cfg.APITokens = []config.APIToken{{Name: "automation", Token: testAPIToken}}
+	cfg.Control.Albums = []config.ControlAlbum{{Name: "Wedding", Album: "wedding-album"}}
+	cfg.PartyModes = []config.PartyMode{{Name: "birthday", Album: "party-album"}}
+	cfg.GuestLinks = config.GuestLinksConfig{Secret: testGuestSecret, BaseURL: "https://frame.example.com/", MaxValidity: 72 * time.Hour}
+	return cfg
+}
+
+func guestRequest(srv *Server, method, target string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+	return rec
+}
+
+func createGuestLink(t *testing.T, srv *Server, body string) string {
+	t.Helper()
+	rec := apiRequest(srv, http.MethodPost, "/api/v1/guest-links", body)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	var resp guestLinkResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	require.True(t, strings.HasPrefix(resp.URL, "https://frame.example.com/guest/"), resp.URL)
+	u, err := url.Parse(resp.URL)
+	require.NoError(t, err)
+	return u.Path
+}
+
+func TestGuestLink_Redeem(t *testing.T) {
+	srv := newGuestTestServer(t)
+	path := createGuestLink(t, srv, `{"mode": "album", "name": "Wedding", "duration": "6h"}`)
+
+	// Viewing the link does not redeem it
+	rec := guestRequest(srv, http.MethodGet, path)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "for 6 hours")
+	assert.Contains(t, rec.Header().Get("Content-Security-Policy"), "default-src 'none'")
+	assert.Nil(t, srv.activeOverride(time.Now()))
+
+	rec = guestRequest(srv, http.MethodPost, path)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Now showing")
+	o := srv.activeOverride(time.Now())
+	require.NotNil(t, o)
+	assert.Equal(t, "wedding-album", o.Album)
+	assert.WithinDuration(t, time.Now().Add(6*time.Hour), o.Until, time.Minute)
+
+	// Single use
+	rec = guestRequest(srv, http.MethodPost, path)
+	assert.Equal(t, http.StatusGone, rec.Code)
+	rec = guestRequest(srv, http.MethodGet, path)
+	assert.Equal(t, http.StatusGone, rec.Code)
+}
+
+func TestGuestLink_RedeemedAfterRestart(t *testing.T) {
+	cfg := newGuestTestConfig()
+	cfg.StateDir = t.TempDir()
+	srv := newTestServer(t, cfg)
+	path := createGuestLink(t, srv, `{"mode": "album", "name": "Wedding"}`)
+
+	rec := guestRequest(srv, http.MethodPost, path)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	// A restart remembers the link was used.
+	restarted := newTestServer(t, cfg)
+	rec = guestRequest(restarted, http.MethodPost, path)
+	assert.Equal(t, http.StatusGone, rec.Code)
+}
+
+func TestGuestLink_Party(t *testing.T) {
+	srv := newGuestTestServer(t)
+	path := createGuestLink(t, srv, `{"mode": "party", "name": "birthday"}`)
+
+	rec := guestRequest(srv, http.MethodPost, path)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NotNil(t, srv.activeParty(time.Now()))
+}
+
+func TestGuestLink_Rejected(t *testing.T) {
+	srv := newGuestTestServer(t)
+
+	expired, err := guestlink.Sign(testGuestSecret, guestlink.Claims{ID: "a", Mode: "album", Name: "Wedding", Expires: time.Now().Add(-time.Minute).Unix()})
+	require.NoError(t, err)
+	forged, err := guestlink.Sign("another-secret-of-at-least-32-chars!", guestlink.Claims{ID: "b", Mode: "album", Name: "Wedding", Expires: time.Now().Add(time.Hour).Unix()})
+	require.NoError(t, err)
+	removed, err := guestlink.Sign(testGuestSecret, guestlink.Claims{ID: "c", Mode: "album", Name: "Gone", Expires: time.Now().Add(time.Hour).Unix()})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{"expired", expired, http.StatusGone},
+		{"forged", forged, http.StatusForbidden},
+		{"malformed", "garbage", http.StatusForbidden},
+		{"unknown album", removed, http.StatusGone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := guestRequest(srv, http.MethodPost, "/guest/"+tt.token)
+			assert.Equal(t, tt.status, rec.Code)
+			assert.Nil(t, srv.activeOverride(time.Now()))
+		})
+	}
+}
+
+func TestGuestLink_CrossSitePost(t *testing.T) {
+	srv := newGuestTestServer(t)
+	path := createGuestLink(t, srv, `{"mode": "album", "name": "Wedding"}`)
+
+	req := httptest.NewRequest(http.MethodPost, path, nil)
+	req.Header.Set("Sec-Fetch-Site", "cross-site")
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Nil(t, srv.activeOverride(time.Now()))
+}
+
+func TestAPI_CreateGuestLinkErrors(t *testing.T) {
+	srv := newGuestTestServer(t)
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"unknown mode", `{"mode": "slideshow", "name": "Wedding"}`},
+		{"unknown album", `{"mode": "album", "name": "Nope"}`},
+		{"too long", `{"mode": "album", "name": "Wedding", "valid_for": "100h"}`},
+		{"bad duration", `{"mode": "album", "name": "Wedding", "duration": "-1h"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := apiRequest(srv, http.MethodPost, "/api/v1/guest-links", tt.body)
+			assert.Equal(t, http.StatusUnprocessableEntity, rec.Code, rec.Body.String())
+		})
+	}
+
+	// Creating links requires a token
+	rec := guestRequest(srv, http.MethodPost, "/api/v1/guest-links")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestGuestLink_Disabled(t *testing.T) {
+	cfg := newAPITestConfig()
+	cfg.APITokens = []config.APIToken{{Name: "automation", Token: testAPIToken}}
+	srv := newTestServer(t, cfg)
+
+	assert.Equal(t, http.StatusNotFound, guestRequest(srv, http.MethodGet, "/guest/anything").Code)
+	rec := apiRequest(srv, http.MethodPost, "/api/v1/guest-links", `{"mode": "album", "name": "Wedding"}`)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestLogPath(t *testing.T) {
+	assert.Equal(t, "/guest/[redacted]", logPath("/guest/eyJ.abc"))
+	assert.Equal(t, "/api/v1/status", logPath("/api/v1/status"))
+}
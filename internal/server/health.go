@@ -0,0 +1,224 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+)
+
+// Health statuses of the server and its components. A failed required
+// component fails the server; a failed optional one degrades it.
+const (
+	healthOK       = "ok"
+	healthDegraded = "degraded"
+	healthFail     = "fail"
+)
+
+// healthCheckTimeout bounds the checks of a health request.
+const healthCheckTimeout = 5 * time.Second
+
+// healthCheck is the result of checking one component.
+type healthCheck struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	Required  bool    `json:"required"`
+	LatencyMS float64 `json:"latency_ms"`
+	Message   string  `json:"message,omitempty"`
+}
+
+// healthResponse is the body of GET /healthz.
+type healthResponse struct {
+	Status      string        `json:"status"`
+	Schedule    string        `json:"schedule,omitempty"`
+	Album       string        `json:"album,omitempty"`
+	Maintenance bool          `json:"maintenance"`
+	Checks      []healthCheck `json:"checks"`
+}
+
+// healthComponent checks one component, returning its status and a
+// message explaining anything but ok, or details such as a deep probe's
+// response.
+type healthComponent struct {
+	name     string
+	required bool
+	check    func(ctx context.Context) (status, message string)
+}
+
+// healthComponents returns the components to check: the core ones and
+// the configured integrations. A deep check also probes the kiosk.
+func (s *Server) healthComponents(deep bool) []healthComponent {
+	kiosk := s.checkKiosk
+	if deep {
+		kiosk = s.probeKiosk
+	}
+	components := []healthComponent{
+		{"config", true, s.checkConfig},
+		{"scheduler", true, s.checkScheduler},
+		{"state", true, s.checkState},
+		{"kiosk", true, kiosk},
+	}
+	if s.albums != nil {
+		components = append(components, healthComponent{"immich", false, s.checkImmich})
+	}
+	if s.homeAssistant != nil {
+		components = append(components, healthComponent{"home_assistant", false, s.checkHomeAssistant})
+	}
+	if s.mqtt != nil {
+		components = append(components, healthComponent{"mqtt", false, s.checkMQTT})
+	}
+	return components
+}
+
+// checkConfig reports a failed reload, after which the previous
+// configuration is still served.
+func (s *Server) checkConfig(ctx context.Context) (string, string) {
+	if reload := s.reloads.get(); reload.LastError != "" {
+		return healthDegraded, "last reload failed, serving the previous configuration: " + reload.LastError
+	}
+	return healthOK, ""
+}
+
+// checkScheduler resolves the current selection.
+func (s *Server) checkScheduler(ctx context.Context) (string, string) {
+	s.selectionAt(s.current(), time.Now())
+	return healthOK, ""
+}
+
+// checkState verifies that runtime state can be saved.
+func (s *Server) checkState(ctx context.Context) (string, string) {
+	if err := s.store.Check(); err != nil {
+		return healthFail, err.Error()
+	}
+	return healthOK, ""
+}
+
+// checkKiosk reports a kiosk_url found to lead back to the scheduler.
+func (s *Server) checkKiosk(ctx context.Context) (string, string) {
+	if loop := s.loopTarget.Load(); loop != nil && *loop == s.current().config.KioskURL {
+		return healthFail, "kiosk_url points back at the scheduler"
+	}
+	return healthOK, ""
+}
+
+// probeKiosk checks for a loop and sends a HEAD request to kiosk_url, with
+// the default album in its path if it has a placeholder. The kiosk fails
+// when it cannot be reached or answers with a server error.
+func (s *Server) probeKiosk(ctx context.Context) (string, string) {
+	if status, message := s.checkKiosk(ctx); status != healthOK {
+		return status, message
+	}
+
+	cfg := s.current().config
+	target := strings.ReplaceAll(cfg.KioskURL, config.AlbumPlaceholder, url.PathEscape(cfg.AlbumID(cfg.DefaultAlbum)))
+	ctx, cancel := context.WithTimeout(ctx, kioskProbeTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, target, nil)
+	if err != nil {
+		return healthFail, err.Error()
+	}
+	resp, err := kioskClient.Do(req)
+	if err != nil {
+		return healthFail, err.Error()
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return healthFail, "kiosk answered " + resp.Status
+	}
+	return healthOK, "kiosk answered " + resp.Status
+}
+
+// checkImmich reports a failed album sync.
+func (s *Server) checkImmich(ctx context.Context) (string, string) {
+	if status := s.albums.Status(); status.LastError != "" {
+		return healthFail, status.LastError
+	}
+	return healthOK, ""
+}
+
+// checkHomeAssistant reports whether the last poll succeeded.
+func (s *Server) checkHomeAssistant(ctx context.Context) (string, string) {
+	if status := s.homeAssistant.Status(); !status.Connected {
+		return healthFail, status.LastError
+	}
+	return healthOK, ""
+}
+
+// checkMQTT reports whether the broker is connected.
+func (s *Server) checkMQTT(ctx context.Context) (string, string) {
+	if status := s.mqtt.Status(); !status.Connected {
+		return healthFail, status.LastError
+	}
+	return healthOK, ""
+}
+
+// health checks all components. The server fails when a required
+// component fails and is degraded when any other check is not ok.
+func (s *Server) health(ctx context.Context, deep bool) healthResponse {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	st := s.current()
+	selection := s.selectionAt(st, time.Now())
+	resp := healthResponse{
+		Status:      healthOK,
+		Schedule:    selection.Schedule,
+		Album:       selection.Album,
+		Maintenance: st.config.Maintenance.Enabled,
+	}
+	for _, c := range s.healthComponents(deep) {
+		start := time.Now()
+		status, message := c.check(ctx)
+		resp.Checks = append(resp.Checks, healthCheck{
+			Name:      c.name,
+			Status:    status,
+			Required:  c.required,
+			LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+			Message:   message,
+		})
+		switch {
+		case status == healthFail && c.required:
+			resp.Status = healthFail
+		case status != healthOK && resp.Status == healthOK:
+			resp.Status = healthDegraded
+		}
+	}
+	return resp
+}
+
+// redact removes what identifies the household's albums and setup from a
+// health response: the schedule, the album and the check messages.
+func (h *healthResponse) redact() {
+	h.Schedule, h.Album = "", ""
+	for i := range h.Checks {
+		h.Checks[i].Message = ""
+	}
+}
+
+// handleHealth reports the health of the server and its components,
+// answering 503 when a required component fails. ?deep=1, or health.deep,
+// also probes the kiosk. With health.detail minimal, only requests with an
+// API token get the full details.
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	cfg := s.current().config
+	deep, _ := strconv.ParseBool(r.URL.Query().Get("deep"))
+	resp := s.health(r.Context(), deep || cfg.Health.Deep)
+	if cfg.Health.Detail == config.HealthDetailMinimal {
+		if _, ok := s.authenticate(r); !ok {
+			resp.redact()
+		}
+	}
+	code := http.StatusOK
+	if resp.Status == healthFail {
+		code = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,189 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/mqtt"
+)
+
+// healthRequest requests /healthz and decodes the response.
+func healthRequest(t *testing.T, srv *Server) (int, healthResponse) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	var resp healthResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	return rec.Code, resp
+}
+
+// checkStatuses maps the checks of a health response to their status.
+func checkStatuses(resp healthResponse) map[string]string {
+	statuses := make(map[string]string, len(resp.Checks))
+	for _, c := range resp.Checks {
+		statuses[c.Name] = c.Status
+	}
+	return statuses
+}
+
+func TestHealth_Components(t *testing.T) {
+	srv := newTestServer(t, newAPITestConfig())
+
+	code, resp := healthRequest(t, srv)
+
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, healthOK, resp.Status)
+	assert.Equal(t, "default-album-id", resp.Album)
+	assert.Equal(t, map[string]string{"config": healthOK, "scheduler": healthOK, "state": healthOK, "kiosk": healthOK}, checkStatuses(resp))
+	for _, c := range resp.Checks {
+		assert.True(t, c.Required, c.Name)
+		assert.GreaterOrEqual(t, c.LatencyMS, 0.0, c.Name)
+	}
+}
+
+func TestHealth_RequiredComponentFails(t *testing.T) {
+	cfg := newAPITestConfig()
+	cfg.StateDir = t.TempDir()
+	srv := newTestServer(t, cfg)
+	require.NoError(t, os.RemoveAll(cfg.StateDir))
+
+	code, resp := healthRequest(t, srv)
+
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, healthFail, resp.Status)
+	assert.Equal(t, healthFail, checkStatuses(resp)["state"])
+}
+
+func TestHealth_KioskLoop(t *testing.T) {
+	srv := newTestServer(t, newAPITestConfig())
+	kioskURL := srv.current().config.KioskURL
+	srv.loopTarget.Store(&kioskURL)
+
+	code, resp := healthRequest(t, srv)
+
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, healthFail, checkStatuses(resp)["kiosk"])
+}
+
+func TestHealth_Degraded(t *testing.T) {
+	cfg := newAPITestConfig()
+	cfg.MQTT = config.MQTTConfig{Broker: "tcp://127.0.0.1:1883"}
+	srv := newTestServer(t, cfg)
+	srv.SetMQTT(mqtt.New(cfg.MQTT, srv.MQTTTopics, slog.Default()))
+	srv.reloads.record(errors.New("invalid schedule"))
+
+	code, resp := healthRequest(t, srv)
+
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, healthDegraded, resp.Status)
+	statuses := checkStatuses(resp)
+	assert.Equal(t, healthDegraded, statuses["config"])
+	assert.Equal(t, healthFail, statuses["mqtt"])
+	for _, c := range resp.Checks {
+		if c.Name == "config" {
+			assert.Contains(t, c.Message, "invalid schedule")
+		}
+	}
+}
+
+// kioskCheck returns the kiosk check of a health response.
+func kioskCheck(t *testing.T, resp healthResponse) healthCheck {
+	t.Helper()
+	for _, c := range resp.Checks {
+		if c.Name == "kiosk" {
+			return c
+		}
+	}
+	t.Fatal("no kiosk check")
+	return healthCheck{}
+}
+
+func TestHealth_DeepProbesKiosk(t *testing.T) {
+	status := http.StatusOK
+	var paths []string
+	kiosk := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodHead, r.Method)
+		paths = append(paths, r.URL.Path)
+		w.WriteHeader(status)
+	}))
+	defer kiosk.Close()
+
+	cfg := newAPITestConfig()
+	cfg.KioskURL = kiosk.URL + "/albums/{album}"
+	srv := newTestServer(t, cfg)
+
+	// Without deep, the kiosk is not requested.
+	code, resp := healthRequest(t, srv)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Empty(t, paths)
+	assert.Empty(t, kioskCheck(t, resp).Message)
+
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz?deep=1", nil))
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []string{"/albums/default-album-id"}, paths)
+	assert.Equal(t, healthCheck{Name: "kiosk", Status: healthOK, Required: true, Message: "kiosk answered 200 OK"},
+		withoutLatency(kioskCheck(t, resp)))
+
+	status = http.StatusBadGateway
+	rec = httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz?deep=true", nil))
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "kiosk answered 502 Bad Gateway", kioskCheck(t, resp).Message)
+}
+
+func TestHealth_DeepConfigKioskDown(t *testing.T) {
+	kiosk := httptest.NewServer(http.NotFoundHandler())
+	kiosk.Close()
+
+	cfg := newAPITestConfig()
+	cfg.KioskURL = kiosk.URL
+	cfg.Health.Deep = true
+	srv := newTestServer(t, cfg)
+
+	code, resp := healthRequest(t, srv)
+
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	check := kioskCheck(t, resp)
+	assert.Equal(t, healthFail, check.Status)
+	assert.Contains(t, check.Message, "connection refused")
+}
+
+// withoutLatency zeroes the latency of a check for comparing it.
+func withoutLatency(c healthCheck) healthCheck {
+	c.LatencyMS = 0
+	return c
+}
+
+func TestHealth_MinimalDetail(t *testing.T) {
+	cfg := newAPITestConfig()
+	cfg.Health.Detail = config.HealthDetailMinimal
+	cfg.APITokens = []config.APIToken{{Name: "monitor", Token: testAPIToken, Role: config.RoleViewer}}
+	srv := newTestServer(t, cfg)
+	srv.reloads.record(errors.New("invalid schedule"))
+
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	body := rec.Body.String()
+	assert.NotContains(t, body, "default-album-id")
+	assert.NotContains(t, body, `"schedule"`)
+	assert.NotContains(t, body, "invalid schedule")
+	assert.Contains(t, body, `"status":"degraded"`)
+
+	rec = apiRequest(srv, http.MethodGet, "/healthz", "")
+	body = rec.Body.String()
+	assert.Contains(t, body, `"album":"default-album-id"`)
+	assert.Contains(t, body, "invalid schedule")
+}
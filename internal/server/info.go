@@ -0,0 +1,83 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/hooks"
+)
+
+// infoPage is the data of the informational page on /.
+type infoPage struct {
+	Current     hooks.Selection
+	AlbumName   string
+	Override    *albumOverride
+	Until       string
+	Maintenance bool
+	// NextChange is the date the next scheduled change takes effect, empty
+	// when the same schedule applies all year.
+	NextChange   string
+	NextSchedule string
+	RedirectURL  string
+	Today        string
+	Revision     string
+	Version      string
+}
+
+// wantsInfo reports whether / should show the info page instead of
+// redirecting: on ?info, or for User-Agents not listed as kiosks.
+func wantsInfo(r *http.Request, cfg config.InfoPageConfig) bool {
+	if r.URL.Query().Has("info") {
+		return true
+	}
+	if len(cfg.KioskUserAgents) == 0 {
+		return false
+	}
+	ua := strings.ToLower(r.UserAgent())
+	for _, kiosk := range cfg.KioskUserAgents {
+		if strings.Contains(ua, strings.ToLower(kiosk)) {
+			return false
+		}
+	}
+	return true
+}
+
+// serveInfo renders the info page for a person who opened / in a browser.
+func (s *Server) serveInfo(w http.ResponseWriter, r *http.Request, st *snapshot) {
+	now := time.Now()
+	page := infoPage{
+		Current:     s.selectionAt(st, now),
+		Override:    s.activeOverride(now),
+		Maintenance: st.config.Maintenance.Enabled,
+		Today:       now.Format(time.DateOnly),
+		Revision:    st.revision,
+		Version:     s.version,
+	}
+	page.AlbumName = s.albumName(st, page.Current.Album)
+	if page.Override != nil {
+		page.Until = page.Override.untilLabel("Mon 15:04")
+	}
+	if at, next, ok := st.backend.NextTransition(now); ok {
+		page.NextChange = at.Format("Monday, 2 January 2006")
+		page.NextSchedule = next.Schedule
+	}
+
+	var params map[string]string
+	entry := st.entryAt(now)
+	if page.Override != nil {
+		params = page.Override.Params
+		if page.Override.Album != "" {
+			entry = nil
+		}
+	}
+	redirectURL, err := st.buildRedirectURL(r, page.Current.Album, entry, params)
+	if err != nil {
+		s.logger.Error("failed to build redirect URL", slog.Any("error", err))
+	}
+	page.RedirectURL = redirectURL
+
+	s.renderUI(w, http.StatusOK, "info", "Photo frame scheduler", len(st.config.Schedule), page)
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+)
+
+func TestWantsInfo(t *testing.T) {
+	kiosks := config.InfoPageConfig{KioskUserAgents: []string{"Fully Kiosk", "SmartTV"}}
+
+	tests := []struct {
+		name   string
+		target string
+		ua     string
+		cfg    config.InfoPageConfig
+		want   bool
+	}{
+		{"plain request", "/", "Mozilla/5.0 Firefox/130.0", config.InfoPageConfig{}, false},
+		{"info parameter", "/?info", "Mozilla/5.0 Fully Kiosk Browser", kiosks, true},
+		{"kiosk user agent", "/", "Mozilla/5.0 (Linux; Android 11) fully kiosk browser", kiosks, false},
+		{"other user agent", "/", "Mozilla/5.0 Firefox/130.0", kiosks, true},
+		{"no user agent", "/", "", kiosks, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			req.Header.Set("User-Agent", tt.ua)
+			assert.Equal(t, tt.want, wantsInfo(req, tt.cfg))
+		})
+	}
+}
+
+func TestRedirect_InfoPage(t *testing.T) {
+	cfg := newAPITestConfig()
+	cfg.InfoPage.KioskUserAgents = []string{"SmartTV"}
+	srv := newTestServer(t, cfg)
+	srv.SetVersion("1.2.3", "abc1234")
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("User-Agent", "Mozilla/5.0 Firefox/130.0")
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	body := rec.Body.String()
+	assert.Contains(t, body, "Next change")
+	assert.Contains(t, body, "Kiosks go to")
+	assert.Contains(t, body, "https://kiosk.example.com?album=")
+	assert.Contains(t, body, "1.2.3")
+	assert.Contains(t, body, srv.current().revision)
+
+	// Kiosks are still redirected.
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("User-Agent", "Mozilla/5.0 SmartTV")
+	rec = httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusFound, rec.Code)
+}
+
+func TestRedirect_InfoPageDuringMaintenance(t *testing.T) {
+	cfg := newAPITestConfig()
+	cfg.Maintenance.Enabled = true
+	srv := newTestServer(t, cfg)
+
+	rec := guestRequest(srv, http.MethodGet, "/?info")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Maintenance mode is on")
+}
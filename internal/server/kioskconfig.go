@@ -0,0 +1,60 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"maps"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+)
+
+// handleKioskConfig serves an Immich Kiosk configuration document with the
+// configured settings and the album selected now, for Kiosk deployments that
+// load their configuration from a URL. Overrides and quiet hours apply as
+// they do to redirects.
+func (s *Server) handleKioskConfig(w http.ResponseWriter, r *http.Request) {
+	st := s.current()
+	if !st.config.KioskConfig.Enabled {
+		s.handleNotFound(w, r)
+		return
+	}
+	if st.config.Maintenance.Enabled {
+		retryAfter := maintenanceRetryAfter(st.config.Maintenance)
+		w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Round(time.Second)/time.Second)))
+		http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	now := time.Now()
+	selection := s.selectionAt(st, now)
+	if selection.Album == "" {
+		// Quiet hours without a night album: Kiosk would show every album.
+		end, _ := st.config.QuietHours.Window(now)
+		w.Header().Set("Retry-After", strconv.Itoa(int(end.Sub(now).Round(time.Second)/time.Second)+1))
+		http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	doc := make(map[string]any, len(st.config.KioskConfig.Settings)+1)
+	maps.Copy(doc, st.config.KioskConfig.Settings)
+	doc[config.KioskConfigAlbumsKey] = []string{st.config.AlbumID(selection.Album)}
+	body, err := yaml.Marshal(doc)
+	if err != nil {
+		s.logger.Error("failed to render kiosk configuration", slog.Any("error", err))
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	if notModified(w, r, `"`+hex.EncodeToString(sum[:8])+`"`) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/yaml")
+	_, _ = w.Write(body)
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.yaml.in/yaml/v3"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+)
+
+func TestKioskConfig(t *testing.T) {
+	cfg := newAPITestConfig()
+	cfg.Albums = map[string]string{"beach": "beach-album-id"}
+	cfg.KioskConfig = config.KioskConfigConfig{
+		Enabled:  true,
+		Settings: map[string]any{"duration": 60, "show_time": true},
+	}
+	srv := newTestServer(t, cfg)
+
+	get := func(header http.Header) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/kiosk-config.yaml", nil)
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		rec := httptest.NewRecorder()
+		srv.router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get(nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/yaml", rec.Header().Get("Content-Type"))
+	var doc map[string]any
+	require.NoError(t, yaml.Unmarshal(rec.Body.Bytes(), &doc))
+	assert.Equal(t, 60, doc["duration"])
+	assert.Equal(t, true, doc["show_time"])
+	st := srv.current()
+	wantAlbum := st.config.AlbumID(srv.selectionAt(st, time.Now()).Album)
+	assert.Equal(t, []any{wantAlbum}, doc["albums"])
+
+	// An unchanged document is not sent again.
+	etag := rec.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+	assert.Equal(t, http.StatusNotModified, get(http.Header{"If-None-Match": {etag}}).Code)
+
+	// Overrides replace the scheduled album, resolving aliases.
+	srv.override.Store(&albumOverride{Mode: overrideModeAlbum, Name: "beach", Album: "beach", Until: time.Now().Add(time.Hour)})
+	require.NoError(t, yaml.Unmarshal(get(nil).Body.Bytes(), &doc))
+	assert.Equal(t, []any{"beach-album-id"}, doc["albums"])
+}
+
+func TestKioskConfig_Disabled(t *testing.T) {
+	srv := newTestServer(t, newAPITestConfig())
+
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/kiosk-config.yaml", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestKioskConfig_Maintenance(t *testing.T) {
+	cfg := newAPITestConfig()
+	cfg.KioskConfig.Enabled = true
+	cfg.Maintenance.Enabled = true
+	srv := newTestServer(t, cfg)
+
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/kiosk-config.yaml", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "300", rec.Header().Get("Retry-After"))
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/rules"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/scheduler"
+)
+
+// kioskPath is the route prefix of the named kiosks.
+const kioskPath = "/kiosks/"
+
+// newKioskSnapshots derives the serving state of each named kiosk from a
+// configuration with the active profile applied.
+func (s *Server) newKioskSnapshots(cfg *config.Config) (map[string]*snapshot, error) {
+	kiosks := make(map[string]*snapshot, len(cfg.Kiosks))
+	for _, k := range cfg.Kiosks {
+		kioskCfg, err := cfg.WithKiosk(k.Name)
+		if err != nil {
+			return nil, err
+		}
+		sched, err := scheduler.New(kioskCfg)
+		if err != nil {
+			return nil, fmt.Errorf("kiosk %s: failed to create scheduler: %w", k.Name, err)
+		}
+		s.setSources(sched)
+
+		st, err := newSnapshot(kioskCfg, sched)
+		if err != nil {
+			return nil, fmt.Errorf("kiosk %s: %w", k.Name, err)
+		}
+		st.kiosk = k.Name
+		st.dwellScope = "kiosk/" + k.Name
+		st.registry = s.devices
+		kiosks[k.Name] = st
+	}
+	return kiosks, nil
+}
+
+// setSources makes the states of the connected Home Assistant and MQTT
+// clients available to a scheduler's when conditions.
+func (s *Server) setSources(sched *scheduler.Scheduler) {
+	if s.homeAssistant != nil {
+		sched.SetSource(rules.SourceHomeAssistant, s.homeAssistant.States)
+	}
+	if s.mqtt != nil {
+		sched.SetSource(rules.SourceMQTT, s.mqtt.Values)
+	}
+}
+
+// all returns the snapshot and those of its kiosks and shadow configuration.
+func (st *snapshot) all() []*snapshot {
+	all := []*snapshot{st}
+	for _, kiosk := range st.kiosks {
+		all = append(all, kiosk)
+	}
+	if st.shadow != nil {
+		all = append(all, st.shadow)
+	}
+	return all
+}
+
+// schedulers returns the scheduler of the snapshot and those of its kiosks
+// and shadow configuration.
+func (st *snapshot) schedulers() []*scheduler.Scheduler {
+	var scheds []*scheduler.Scheduler
+	for _, sn := range st.all() {
+		scheds = append(scheds, sn.scheduler)
+	}
+	return scheds
+}
+
+// references returns the keys of a source read by the when conditions of
+// all schedules, including the kiosks' and the shadow configuration's.
+func (st *snapshot) references(source string) []string {
+	var keys []string
+	for _, sched := range st.schedulers() {
+		keys = append(keys, sched.References(source)...)
+	}
+	slices.Sort(keys)
+	return slices.Compact(keys)
+}
+
+// handleKioskRedirect redirects a named kiosk to its kiosk URL with the
+// album of its schedule.
+func (s *Server) handleKioskRedirect(w http.ResponseWriter, r *http.Request) {
+	kiosk, ok := s.current().kiosks[chi.URLParam(r, "name")]
+	if !ok {
+		s.handleNotFound(w, r)
+		return
+	}
+	s.serveRedirect(w, r, kiosk)
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+)
+
+func TestServer_KioskRedirect(t *testing.T) {
+	cfg := newAPITestConfig()
+	cfg.PassthroughParams = []string{"transition"}
+	cfg.Kiosks = []config.Kiosk{
+		{Name: "kitchen", KioskURL: "https://kitchen.example.com", Schedule: []config.ScheduleEntry{}},
+		{Name: "office", DefaultAlbum: "office-album", PassthroughParams: []string{}, Schedule: []config.ScheduleEntry{}},
+	}
+	srv := newTestServer(t, cfg)
+
+	location := func(target string) *url.URL {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		srv.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		require.Equal(t, http.StatusFound, rec.Code)
+		u, err := url.Parse(rec.Header().Get("Location"))
+		require.NoError(t, err)
+		return u
+	}
+
+	kitchen := location("/kiosks/kitchen?transition=fade")
+	assert.Equal(t, "kitchen.example.com", kitchen.Host)
+	assert.Equal(t, "default-album-id", kitchen.Query().Get("album"))
+	assert.Equal(t, "fade", kitchen.Query().Get("transition"))
+
+	office := location("/kiosks/office?transition=fade")
+	assert.Equal(t, "kiosk.example.com", office.Host)
+	assert.Equal(t, "office-album", office.Query().Get("album"))
+	assert.Empty(t, office.Query().Get("transition"))
+
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/kiosks/garage", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	// Kiosks follow configuration reloads.
+	next := newAPITestConfig()
+	next.Kiosks = []config.Kiosk{{Name: "kitchen", DefaultAlbum: "kitchen-album", Schedule: []config.ScheduleEntry{}}}
+	require.NoError(t, srv.Reload(func() (*config.Config, error) { return next, nil }))
+	assert.Equal(t, "kitchen-album", location("/kiosks/kitchen").Query().Get("album"))
+	rec = httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/kiosks/office", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
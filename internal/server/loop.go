@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// defaultMarkerHeader carries the instance ID when loop_protection.marker_header is unset.
+const defaultMarkerHeader = "X-IKS-Instance"
+
+// Probe timing: the probe waits for the listener to start and gives up on
+// slow kiosks quickly, since failing to reach kiosk_url proves nothing.
+const (
+	kioskProbeDelay   = time.Second
+	kioskProbeTimeout = 5 * time.Second
+)
+
+// kioskClient requests kiosk_url without following redirects, to see what
+// the kiosk itself answers.
+var kioskClient = &http.Client{
+	CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+}
+
+// newInstanceID returns a random ID identifying this scheduler process.
+func newInstanceID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// markerHeader returns the header carrying the instance ID.
+func (st *snapshot) markerHeader() string {
+	if h := st.config.LoopProtection.MarkerHeader; h != "" {
+		return h
+	}
+	return defaultMarkerHeader
+}
+
+// isLoop reports whether redirecting the request to target would lead the
+// client back here: either the probe found kiosk_url answered by this
+// scheduler, or target is the requested endpoint itself.
+func (s *Server) isLoop(st *snapshot, r *http.Request, target *url.URL) bool {
+	if loop := s.loopTarget.Load(); loop != nil && *loop == st.config.KioskURL {
+		return true
+	}
+
+	scheme := "http"
+	if r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https") {
+		scheme = "https"
+	}
+	return strings.EqualFold(hostPort(target.Host, target.Scheme), hostPort(r.Host, scheme)) &&
+		path.Clean("/"+target.Path) == path.Clean("/"+r.URL.Path)
+}
+
+// hostPort returns host with the scheme's default port added if it has none.
+func hostPort(host, scheme string) string {
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+	if scheme == "https" {
+		return net.JoinHostPort(host, "443")
+	}
+	return net.JoinHostPort(host, "80")
+}
+
+// probeKioskURL requests kiosk_url without following redirects and, if the
+// response carries this instance's marker header, records kiosk_url as
+// pointing back at the scheduler so redirects to it are refused.
+func (s *Server) probeKioskURL(ctx context.Context) {
+	st := s.current()
+	if !st.config.LoopProtection.Probe {
+		return
+	}
+	kioskURL := st.config.KioskURL
+
+	ctx, cancel := context.WithTimeout(ctx, kioskProbeTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, kioskURL, nil)
+	if err != nil {
+		return
+	}
+	resp, err := kioskClient.Do(req)
+	if err != nil {
+		s.logger.Debug("kiosk URL probe failed", slog.String("kiosk_url", kioskURL), slog.Any("error", err))
+		return
+	}
+	resp.Body.Close()
+
+	if resp.Header.Get(st.markerHeader()) != s.instanceID {
+		s.loopTarget.Store(nil)
+		return
+	}
+	s.loopTarget.Store(&kioskURL)
+	s.logger.Error("kiosk_url points back at the scheduler; redirects are refused until it is fixed",
+		slog.String("kiosk_url", kioskURL))
+}
+
+// probeKioskURLAfter probes kiosk_url once the listener had time to start.
+func (s *Server) probeKioskURLAfter(ctx context.Context, delay time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(delay):
+		s.probeKioskURL(ctx)
+	}
+}
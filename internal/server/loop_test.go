@@ -0,0 +1,83 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+)
+
+func TestRedirect_SelfTarget(t *testing.T) {
+	tests := []struct {
+		name     string
+		kioskURL string
+		host     string
+		want     int
+	}{
+		{"same host", "http://frame.local/", "frame.local", http.StatusLoopDetected},
+		{"same host with default port", "http://frame.local:80", "frame.local", http.StatusLoopDetected},
+		{"same host, other path", "http://frame.local/kiosk", "frame.local", http.StatusFound},
+		{"same host, other port", "http://frame.local:3000", "frame.local", http.StatusFound},
+		{"other host", "https://kiosk.example.com", "frame.local", http.StatusFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newAPITestConfig()
+			cfg.KioskURL = tt.kioskURL
+			srv := newTestServer(t, cfg)
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Host = tt.host
+			rec := httptest.NewRecorder()
+			srv.router.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.want, rec.Code)
+			if tt.want == http.StatusLoopDetected {
+				assert.Empty(t, rec.Header().Get("Location"))
+				assert.Contains(t, rec.Body.String(), "points back at this scheduler")
+			}
+		})
+	}
+}
+
+func TestProbeKioskURL(t *testing.T) {
+	cfg := newAPITestConfig()
+	cfg.LoopProtection = config.LoopProtectionConfig{MarkerHeader: "X-Frame-Scheduler", Probe: true}
+	srv := newTestServer(t, cfg)
+
+	// The kiosk URL reaches this scheduler through another name, e.g. a proxy.
+	ts := httptest.NewServer(srv.router)
+	defer ts.Close()
+	_, err := srv.update(func(cfg *config.Config) error {
+		cfg.KioskURL = ts.URL + "/"
+		return nil
+	})
+	require.NoError(t, err)
+
+	srv.probeKioskURL(context.Background())
+	require.NotNil(t, srv.loopTarget.Load())
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Host = "frame.example.com"
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusLoopDetected, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("X-Frame-Scheduler"))
+
+	// Pointing kiosk_url at a real kiosk clears the guard.
+	kiosk := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer kiosk.Close()
+	_, err = srv.update(func(cfg *config.Config) error {
+		cfg.KioskURL = kiosk.URL
+		return nil
+	})
+	require.NoError(t, err)
+	srv.probeKioskURL(context.Background())
+	assert.Nil(t, srv.loopTarget.Load())
+	assert.Equal(t, http.StatusFound, guestRequest(srv, http.MethodGet, "/").Code)
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+)
+
+// defaultMaintenanceRetryAfter applies when maintenance.retry_after is unset.
+const defaultMaintenanceRetryAfter = 5 * time.Minute
+
+// defaultMaintenanceMessage is shown when maintenance.message is unset.
+const defaultMaintenanceMessage = "The photo frame is taking a short break for maintenance and will be back soon."
+
+var maintenanceMode = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Name: "immich_kiosk_scheduler_maintenance_mode",
+		Help: "Whether maintenance mode is enabled (1 = enabled)",
+	},
+)
+
+func init() {
+	prometheus.MustRegister(maintenanceMode)
+}
+
+// maintenanceRequest is the body of POST /api/v1/maintenance.
+type maintenanceRequest struct {
+	Enabled *bool `json:"enabled"`
+	// Message and RetryAfter replace the configured values when set.
+	Message    *string `json:"message,omitempty"`
+	RetryAfter string  `json:"retry_after,omitempty"`
+}
+
+// maintenanceResponse is the body of GET and POST /api/v1/maintenance.
+type maintenanceResponse struct {
+	Enabled    bool   `json:"enabled"`
+	Message    string `json:"message"`
+	RetryAfter string `json:"retry_after"`
+}
+
+// maintenanceRetryAfter returns the configured Retry-After, or the default when zero.
+func maintenanceRetryAfter(m config.MaintenanceConfig) time.Duration {
+	if m.RetryAfter == 0 {
+		return defaultMaintenanceRetryAfter
+	}
+	return m.RetryAfter
+}
+
+// newMaintenanceResponse describes the maintenance configuration.
+func newMaintenanceResponse(m config.MaintenanceConfig) maintenanceResponse {
+	message := m.Message
+	if message == "" {
+		message = defaultMaintenanceMessage
+	}
+	return maintenanceResponse{Enabled: m.Enabled, Message: message, RetryAfter: maintenanceRetryAfter(m).String()}
+}
+
+// updateMaintenanceMetric records whether maintenance mode is enabled.
+func updateMaintenanceMetric(m config.MaintenanceConfig) {
+	if m.Enabled {
+		maintenanceMode.Set(1)
+	} else {
+		maintenanceMode.Set(0)
+	}
+}
+
+// serveMaintenance answers a kiosk with the maintenance page instead of a redirect.
+func (s *Server) serveMaintenance(w http.ResponseWriter, m config.MaintenanceConfig) {
+	retryAfter := maintenanceRetryAfter(m)
+	seconds := int(retryAfter.Round(time.Second) / time.Second)
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+
+	s.serveDisplayPage(w, pageMaintenance, displayPage{
+		Status:  http.StatusServiceUnavailable,
+		Message: newMaintenanceResponse(m).Message,
+		Refresh: seconds,
+	})
+}
+
+// handleMaintenanceStatus reports whether maintenance mode is enabled.
+func (s *Server) handleMaintenanceStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, newMaintenanceResponse(s.current().config.Maintenance))
+}
+
+// handleMaintenance enables or disables maintenance mode. Like other admin
+// API changes, it lasts until the configuration is next reloaded.
+func (s *Server) handleMaintenance(w http.ResponseWriter, r *http.Request) {
+	var req maintenanceRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Enabled == nil {
+		writeError(w, http.StatusBadRequest, "enabled is required")
+		return
+	}
+	var retryAfter time.Duration
+	if req.RetryAfter != "" {
+		var err error
+		if retryAfter, err = time.ParseDuration(req.RetryAfter); err != nil || retryAfter <= 0 {
+			writeError(w, http.StatusUnprocessableEntity, fmt.Sprintf("invalid retry_after %q", req.RetryAfter))
+			return
+		}
+	}
+
+	var before config.MaintenanceConfig
+	st, err := s.update(func(cfg *config.Config) error {
+		if err := s.checkIfMatch(r); err != nil {
+			return err
+		}
+		before = cfg.Maintenance
+		cfg.Maintenance.Enabled = *req.Enabled
+		if req.Message != nil {
+			cfg.Maintenance.Message = *req.Message
+		}
+		if retryAfter > 0 {
+			cfg.Maintenance.RetryAfter = retryAfter
+		}
+		return nil
+	})
+	if err != nil {
+		writeUpdateError(w, err)
+		return
+	}
+
+	s.logger.Info("maintenance mode updated",
+		slog.Bool("enabled", st.config.Maintenance.Enabled),
+		slog.String("token", tokenName(r.Context())),
+		slog.String("revision", st.revision),
+	)
+	s.audit(r, auditEntry{
+		Action:   auditMaintenanceUpdate,
+		Before:   newMaintenanceResponse(before),
+		After:    newMaintenanceResponse(st.config.Maintenance),
+		Revision: st.revision,
+	})
+
+	w.Header().Set("ETag", `"`+st.revision+`"`)
+	writeJSON(w, http.StatusOK, newMaintenanceResponse(st.config.Maintenance))
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+)
+
+func TestRedirect_Maintenance(t *testing.T) {
+	cfg := newAPITestConfig()
+	cfg.Maintenance = config.MaintenanceConfig{Enabled: true, Message: "Immich is upgrading", RetryAfter: 90 * time.Second}
+	srv := newTestServer(t, cfg)
+
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?transition=fade", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "90", rec.Header().Get("Retry-After"))
+	assert.Empty(t, rec.Header().Get("Location"))
+	assert.Contains(t, rec.Body.String(), "Immich is upgrading")
+	assert.Contains(t, rec.Body.String(), `content="90"`)
+	assert.Equal(t, 1.0, testutil.ToFloat64(maintenanceMode))
+}
+
+func TestAPI_Maintenance(t *testing.T) {
+	srv := newWriteTestServer(t)
+	assert.Equal(t, 0.0, testutil.ToFloat64(maintenanceMode))
+
+	rec := apiRequest(srv, http.MethodPost, "/api/v1/maintenance", `{"enabled": true, "retry_after": "10m"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var resp maintenanceResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	assert.True(t, resp.Enabled)
+	assert.Equal(t, "10m0s", resp.RetryAfter)
+	assert.Equal(t, defaultMaintenanceMessage, resp.Message)
+	assert.Equal(t, 1.0, testutil.ToFloat64(maintenanceMode))
+
+	rec = httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "600", rec.Header().Get("Retry-After"))
+
+	// Health stays up so orchestrators do not restart the server.
+	rec = httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"maintenance":true`)
+
+	rec = apiRequest(srv, http.MethodGet, "/api/v1/status", "")
+	assert.Contains(t, rec.Body.String(), `"maintenance":true`)
+
+	rec = apiRequest(srv, http.MethodPost, "/api/v1/maintenance", `{"enabled": false}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, 0.0, testutil.ToFloat64(maintenanceMode))
+	redirectTarget(t, srv)
+
+	entries := auditEntries(t, srv, "?action=maintenance.update")
+	assert.Len(t, entries, 2)
+}
+
+func TestAPI_MaintenanceErrors(t *testing.T) {
+	srv := newWriteTestServer(t)
+
+	rec := apiRequest(srv, http.MethodPost, "/api/v1/maintenance", `{}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = apiRequest(srv, http.MethodPost, "/api/v1/maintenance", `{"enabled": true, "retry_after": "soon"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	rec = guestRequest(srv, http.MethodPost, "/api/v1/maintenance")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = apiRequest(srv, http.MethodGet, "/api/v1/maintenance", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"enabled":false`)
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/email"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/hooks"
+)
+
+// upcomingDoc is the state document recording the upcoming emails sent, so
+// they are not sent again after a restart.
+const upcomingDoc = "upcoming-emails"
+
+// publish notifies the hooks, push notifications and email rules of st's
+// configuration of an event.
+func (s *Server) publish(st *snapshot, event hooks.Event) {
+	s.hooks.Send(st.config.Hooks, event)
+	s.notify.Send(st.config.Notifications, event, s.albumName(st, event.Current.Album))
+	s.email.Notify(st.config.Email, s.emailData(st, event, event.Time))
+}
+
+// emailData returns the template data of an event happening at at.
+func (s *Server) emailData(st *snapshot, event hooks.Event, at time.Time) email.Data {
+	return email.Data{
+		Event:             event,
+		At:                at,
+		PreviousAlbumName: s.albumName(st, event.Previous.Album),
+		CurrentAlbumName:  s.albumName(st, event.Current.Album),
+	}
+}
+
+// upcomingSent records the upcoming emails sent by key; see upcomingKey.
+type upcomingSent map[string]time.Time
+
+// upcomingKey identifies the email of a rule for a transition.
+func upcomingKey(rule string, at time.Time, schedule string) string {
+	return rule + "|" + at.Format(time.DateOnly) + "|" + schedule
+}
+
+// loadUpcomingSent returns the upcoming emails recorded as sent.
+func (s *Server) loadUpcomingSent() upcomingSent {
+	sent := upcomingSent{}
+	if _, err := s.store.Load(upcomingDoc, &sent); err != nil {
+		s.logger.Error("failed to load sent upcoming emails", slog.Any("error", err))
+	}
+	return sent
+}
+
+// checkUpcoming sends the upcoming emails of the next transition once it is
+// within a rule's before period. Each is sent once per transition.
+func (s *Server) checkUpcoming(now time.Time) {
+	st := s.current()
+	if !st.config.Email.Enabled() {
+		return
+	}
+	at, next, ok := st.backend.NextTransition(now)
+	if !ok {
+		return
+	}
+	current := st.backend.Resolve(now)
+	event := hooks.Event{
+		Event:    config.EmailEventUpcoming,
+		Time:     now,
+		Previous: hooks.Selection{Schedule: current.Schedule, Album: current.Album},
+		Current:  hooks.Selection{Schedule: next.Schedule, Album: next.Album},
+	}
+
+	s.upcomingMu.Lock()
+	defer s.upcomingMu.Unlock()
+	if s.upcoming == nil {
+		s.upcoming = s.loadUpcomingSent()
+	}
+	changed := false
+	// Transitions that have happened need no record.
+	for key, t := range s.upcoming {
+		if t.Before(now) {
+			delete(s.upcoming, key)
+			changed = true
+		}
+	}
+	for _, rule := range st.config.Email.Rules {
+		if !email.Matches(rule, event) || at.Sub(now) > rule.Before {
+			continue
+		}
+		key := upcomingKey(rule.Name, at, next.Schedule)
+		if _, sent := s.upcoming[key]; sent {
+			continue
+		}
+		s.logger.Info("sending upcoming transition email",
+			slog.String("rule", rule.Name),
+			slog.String("schedule", next.Schedule),
+			slog.Time("at", at),
+		)
+		s.email.Send(st.config.Email, rule, s.emailData(st, event, at))
+		s.upcoming[key] = at
+		changed = true
+	}
+	if changed {
+		if err := s.store.Save(upcomingDoc, s.upcoming); err != nil {
+			s.logger.Error("failed to persist sent upcoming emails", slog.Any("error", err))
+		}
+	}
+}
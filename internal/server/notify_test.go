@@ -0,0 +1,43 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+)
+
+func TestCheckUpcoming(t *testing.T) {
+	cfg := newAPITestConfig()
+	cfg.StateDir = t.TempDir()
+	cfg.Schedule = []config.ScheduleEntry{{Name: "christmas", Album: "christmas-album", Start: "12-01", End: "12-31"}}
+	cfg.Email = config.EmailConfig{
+		// Deliveries fail; only the bookkeeping is tested here.
+		SMTP:  config.SMTPConfig{Host: "127.0.0.1", Port: 1, TLS: config.SMTPNone},
+		From:  "kiosk@example.com",
+		To:    []string{"admin@example.com"},
+		Rules: []config.EmailRule{{Name: "heads-up", Event: config.EmailEventUpcoming, Before: 72 * time.Hour}},
+	}
+	srv := newTestServer(t, cfg)
+	defer srv.email.Wait()
+
+	srv.checkUpcoming(time.Date(2024, 11, 27, 12, 0, 0, 0, time.Local))
+	assert.Empty(t, srv.upcoming, "four days ahead")
+
+	srv.checkUpcoming(time.Date(2024, 11, 28, 12, 0, 0, 0, time.Local))
+	key := upcomingKey("heads-up", time.Date(2024, 12, 1, 0, 0, 0, 0, time.Local), "christmas")
+	require.Contains(t, srv.upcoming, key)
+
+	// Sent once, also after a restart.
+	restarted := newTestServer(t, cfg)
+	defer restarted.email.Wait()
+	restarted.checkUpcoming(time.Date(2024, 11, 29, 12, 0, 0, 0, time.Local))
+	assert.Len(t, restarted.upcoming, 1)
+
+	// Records of past transitions are dropped.
+	restarted.checkUpcoming(time.Date(2024, 12, 2, 12, 0, 0, 0, time.Local))
+	assert.NotContains(t, restarted.upcoming, key)
+}
@@ -0,0 +1,407 @@
+package server
+
+import (
+	"embed"
+	"net/http"
+	"path"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/graphql"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/scheduler"
+)
+
+// apiAccess is the authentication an API operation requires.
+type apiAccess int
+
+const (
+	apiPublic apiAccess = iota
+	apiToken            // any API token
+	apiEditor           // an API token with the editor role
+)
+
+// apiParam documents a path or query parameter.
+type apiParam struct {
+	name, in, description string
+}
+
+// apiOperation documents an API endpoint in the OpenAPI document. Request
+// and response bodies are described by their Go types, so the document
+// follows the handlers.
+type apiOperation struct {
+	method, path, tag, summary string
+	access                     apiAccess
+	params                     []apiParam
+	request                    reflect.Type // nil without a JSON body
+	status                     int
+	response                   reflect.Type // nil without a body
+	errors                     []int
+}
+
+// Common parameters.
+var (
+	scheduleNameParam = apiParam{"name", "path", "Schedule entry name"}
+	daysParam         = apiParam{"days", "query", "Number of days up to today (default 30)"}
+)
+
+// apiOperations lists the /api/v1 endpoints; a test checks it against the
+// router.
+var apiOperations = []apiOperation{
+	{method: http.MethodGet, path: "/status", tag: "status", summary: "Current schedule, album, override and config revision",
+		status: http.StatusOK, response: reflect.TypeFor[statusResponse]()},
+	{method: http.MethodPost, path: "/reevaluate", tag: "status", summary: "Recompute the active schedule now and fire transition hooks if it changed",
+		status: http.StatusOK, response: reflect.TypeFor[reevaluateResponse]()},
+	{method: http.MethodGet, path: "/config", tag: "status", summary: "Effective configuration with secrets redacted", access: apiToken,
+		params: []apiParam{{"sources", "query", "true to return {config, sources} with the source of each setting"}},
+		status: http.StatusOK, response: reflect.TypeFor[map[string]any]()},
+	{method: http.MethodGet, path: "/graphql", tag: "status", summary: "Run a read-only GraphQL query",
+		params: []apiParam{
+			{"query", "query", "GraphQL query"},
+			{"operationName", "query", "Operation to run when the query has several"},
+			{"variables", "query", "Variables as a JSON object"},
+		},
+		status: http.StatusOK, response: reflect.TypeFor[graphql.Response](), errors: []int{http.StatusBadRequest}},
+	{method: http.MethodPost, path: "/graphql", tag: "status", summary: "Run a read-only GraphQL query",
+		request: reflect.TypeFor[graphql.Request](), status: http.StatusOK, response: reflect.TypeFor[graphql.Response](),
+		errors: []int{http.StatusBadRequest}},
+	{method: http.MethodPost, path: "/validate", tag: "status", summary: "Lint a configuration (JSON or YAML) or schedule list without applying it",
+		request: reflect.TypeFor[map[string]any](), status: http.StatusOK, response: reflect.TypeFor[validateResponse](),
+		errors: []int{http.StatusBadRequest, http.StatusUnsupportedMediaType}},
+
+	{method: http.MethodGet, path: "/schedules", tag: "schedules", summary: "Schedule entries in evaluation order",
+		status: http.StatusOK, response: reflect.TypeFor[schedulesResponse]()},
+	{method: http.MethodPost, path: "/schedules", tag: "schedules", summary: "Create a schedule entry", access: apiEditor,
+		request: reflect.TypeFor[createScheduleRequest](), status: http.StatusCreated, response: reflect.TypeFor[scheduleResponse](),
+		errors: []int{http.StatusBadRequest, http.StatusConflict, http.StatusPreconditionFailed, http.StatusUnprocessableEntity}},
+	{method: http.MethodPut, path: "/schedules", tag: "schedules", summary: "Replace the whole schedule list atomically", access: apiEditor,
+		request: reflect.TypeFor[replaceSchedulesRequest](), status: http.StatusOK, response: reflect.TypeFor[replaceSchedulesResponse](),
+		errors: []int{http.StatusBadRequest, http.StatusPreconditionFailed, http.StatusUnprocessableEntity}},
+	{method: http.MethodGet, path: "/schedules/{name}", tag: "schedules", summary: "A schedule entry and its position", params: []apiParam{scheduleNameParam},
+		status: http.StatusOK, response: reflect.TypeFor[scheduleResponse](), errors: []int{http.StatusNotFound}},
+	{method: http.MethodPut, path: "/schedules/{name}", tag: "schedules", summary: "Replace, rename or move a schedule entry", access: apiEditor, params: []apiParam{scheduleNameParam},
+		request: reflect.TypeFor[updateScheduleRequest](), status: http.StatusOK, response: reflect.TypeFor[scheduleResponse](),
+		errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict, http.StatusPreconditionFailed, http.StatusUnprocessableEntity}},
+	{method: http.MethodDelete, path: "/schedules/{name}", tag: "schedules", summary: "Remove a schedule entry", access: apiEditor, params: []apiParam{scheduleNameParam},
+		status: http.StatusNoContent, errors: []int{http.StatusNotFound, http.StatusPreconditionFailed, http.StatusUnprocessableEntity}},
+	{method: http.MethodGet, path: "/coverage", tag: "schedules", summary: "Days covered per entry and the daily selection",
+		status: http.StatusOK, response: reflect.TypeFor[scheduler.Coverage]()},
+	{method: http.MethodGet, path: "/profile", tag: "schedules", summary: "Schedule profiles and the active one",
+		status: http.StatusOK, response: reflect.TypeFor[profileResponse]()},
+	{method: http.MethodPut, path: "/profile/{name}", tag: "schedules", summary: "Switch the active schedule profile", access: apiEditor,
+		params: []apiParam{{"name", "path", "Profile name"}}, status: http.StatusOK, response: reflect.TypeFor[profileResponse](),
+		errors: []int{http.StatusNotFound}},
+
+	{method: http.MethodGet, path: "/party", tag: "overrides", summary: "Party modes and the running one",
+		status: http.StatusOK, response: reflect.TypeFor[partyStatusResponse]()},
+	{method: http.MethodPost, path: "/party/{name}", tag: "overrides", summary: "Start a party mode", access: apiEditor,
+		params: []apiParam{{"name", "path", "Party mode name"}}, request: reflect.TypeFor[startPartyRequest](),
+		status: http.StatusOK, response: reflect.TypeFor[albumOverride](),
+		errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusUnprocessableEntity}},
+	{method: http.MethodDelete, path: "/party", tag: "overrides", summary: "Stop the running party mode", access: apiEditor,
+		status: http.StatusNoContent},
+	{method: http.MethodPost, path: "/override", tag: "overrides", summary: "Show a control album or aliased album for a while", access: apiEditor,
+		request: reflect.TypeFor[startOverrideRequest](), status: http.StatusOK, response: reflect.TypeFor[albumOverride](),
+		errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusUnprocessableEntity}},
+	{method: http.MethodDelete, path: "/override", tag: "overrides", summary: "End the running override or party mode", access: apiEditor,
+		status: http.StatusNoContent},
+	{method: http.MethodPost, path: "/freeze", tag: "overrides", summary: "Keep showing the current album until released", access: apiEditor,
+		status: http.StatusOK, response: reflect.TypeFor[albumOverride](), errors: []int{http.StatusConflict}},
+	{method: http.MethodDelete, path: "/freeze", tag: "overrides", summary: "Release a freeze and return to the schedule", access: apiEditor,
+		status: http.StatusNoContent},
+	{method: http.MethodPost, path: "/guest-links", tag: "overrides", summary: "Create a signed single-use guest link", access: apiEditor,
+		request: reflect.TypeFor[guestLinkRequest](), status: http.StatusCreated, response: reflect.TypeFor[guestLinkResponse](),
+		errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusUnprocessableEntity}},
+	{method: http.MethodGet, path: "/maintenance", tag: "overrides", summary: "Maintenance mode state",
+		status: http.StatusOK, response: reflect.TypeFor[maintenanceResponse]()},
+	{method: http.MethodPost, path: "/maintenance", tag: "overrides", summary: "Enable or disable maintenance mode", access: apiEditor,
+		request: reflect.TypeFor[maintenanceRequest](), status: http.StatusOK, response: reflect.TypeFor[maintenanceResponse](),
+		errors: []int{http.StatusBadRequest, http.StatusPreconditionFailed, http.StatusUnprocessableEntity}},
+
+	{method: http.MethodGet, path: "/devices", tag: "devices", summary: "Registered devices with their last request",
+		status: http.StatusOK, response: reflect.TypeFor[devicesResponse]()},
+	{method: http.MethodPost, path: "/devices", tag: "devices", summary: "Register or update a device", access: apiEditor,
+		request: reflect.TypeFor[registerDeviceRequest](), status: http.StatusCreated, response: reflect.TypeFor[registeredDevice](),
+		errors: []int{http.StatusBadRequest, http.StatusConflict, http.StatusUnprocessableEntity}},
+	{method: http.MethodDelete, path: "/devices/{id}", tag: "devices", summary: "Unregister a device", access: apiEditor,
+		params: []apiParam{{"id", "path", "Device ID"}}, status: http.StatusNoContent, errors: []int{http.StatusNotFound}},
+
+	{method: http.MethodGet, path: "/albums", tag: "albums", summary: "Immich albums cached for showing names",
+		status: http.StatusOK, response: reflect.TypeFor[albumsResponse](), errors: []int{http.StatusNotFound}},
+	{method: http.MethodPost, path: "/albums/sync", tag: "albums", summary: "Refresh the album cache from Immich now", access: apiEditor,
+		status: http.StatusOK, response: reflect.TypeFor[albumsResponse](), errors: []int{http.StatusNotFound, http.StatusBadGateway}},
+
+	{method: http.MethodGet, path: "/stats", tag: "stats", summary: "Daily redirects and active time per schedule", params: []apiParam{daysParam},
+		status: http.StatusOK, response: reflect.TypeFor[statsResponse](), errors: []int{http.StatusBadRequest}},
+	{method: http.MethodGet, path: "/stats/export", tag: "stats", summary: "Statistics as CSV (default) or JSON rows",
+		params: []apiParam{
+			{"format", "query", "csv or json"},
+			{"from", "query", "First day, YYYY-MM-DD"},
+			{"to", "query", "Last day, YYYY-MM-DD"},
+		},
+		status: http.StatusOK, response: reflect.TypeFor[[]statsRow](), errors: []int{http.StatusBadRequest}},
+	{method: http.MethodGet, path: "/shadow", tag: "stats", summary: "How the shadow configuration compared with the active one", access: apiToken,
+		status: http.StatusOK, response: reflect.TypeFor[shadowResponse]()},
+	{method: http.MethodGet, path: "/audit", tag: "stats", summary: "Changes made through the admin API, newest first", access: apiToken,
+		params: []apiParam{
+			{"limit", "query", "Maximum number of entries (default 100, at most 1000)"},
+			{"actor", "query", "Only entries by this token name"},
+			{"action", "query", "Only entries with this action, e.g. schedule.update"},
+			{"target", "query", "Only entries changing this target"},
+			{"since", "query", "Only entries at or after this RFC 3339 time"},
+		},
+		status: http.StatusOK, response: reflect.TypeFor[auditResponse](), errors: []int{http.StatusBadRequest}},
+}
+
+// openAPIDocument returns the OpenAPI 3 document of the /api/v1 endpoints.
+func openAPIDocument(version string) map[string]any {
+	b := schemaBuilder{schemas: map[string]any{}}
+	errorSchema := b.schema(reflect.TypeFor[errorResponse]())
+
+	paths := map[string]map[string]any{}
+	for _, op := range apiOperations {
+		responses := map[string]any{}
+		if op.response == nil {
+			responses[statusCode(op.status)] = map[string]any{"description": http.StatusText(op.status)}
+		} else {
+			responses[statusCode(op.status)] = map[string]any{
+				"description": http.StatusText(op.status),
+				"content":     map[string]any{"application/json": map[string]any{"schema": b.schema(op.response)}},
+			}
+		}
+		errors := op.errors
+		if op.access != apiPublic {
+			errors = append([]int{http.StatusUnauthorized, http.StatusForbidden}, errors...)
+		}
+		for _, status := range errors {
+			responses[statusCode(status)] = map[string]any{
+				"description": http.StatusText(status),
+				"content":     map[string]any{"application/json": map[string]any{"schema": errorSchema}},
+			}
+		}
+
+		operation := map[string]any{
+			"operationId": operationID(op),
+			"tags":        []string{op.tag},
+			"summary":     op.summary,
+			"responses":   responses,
+		}
+		switch op.access {
+		case apiToken:
+			operation["security"] = []map[string][]string{{"bearerAuth": {}}}
+		case apiEditor:
+			operation["security"] = []map[string][]string{{"bearerAuth": {}}}
+			operation["description"] = "Requires an API token with the editor role."
+		}
+		if len(op.params) > 0 {
+			params := make([]map[string]any, 0, len(op.params))
+			for _, p := range op.params {
+				params = append(params, map[string]any{
+					"name":        p.name,
+					"in":          p.in,
+					"description": p.description,
+					"required":    p.in == "path",
+					"schema":      map[string]string{"type": "string"},
+				})
+			}
+			operation["parameters"] = params
+		}
+		if op.request != nil {
+			operation["requestBody"] = map[string]any{
+				"required": true,
+				"content":  map[string]any{"application/json": map[string]any{"schema": b.schema(op.request)}},
+			}
+		}
+
+		p := "/api/v1" + op.path
+		if paths[p] == nil {
+			paths[p] = map[string]any{}
+		}
+		paths[p][strings.ToLower(op.method)] = operation
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "Immich Kiosk Scheduler API",
+			"version":     version,
+			"description": "Schedule status, schedule entries, overrides, devices and statistics.",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": b.schemas,
+			"securitySchemes": map[string]any{
+				"bearerAuth": map[string]string{"type": "http", "scheme": "bearer", "description": "An API token from api_tokens"},
+			},
+		},
+	}
+}
+
+// handleOpenAPI serves the OpenAPI document.
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, openAPIDocument(s.versionOrDev()))
+}
+
+// swaggerUIFiles holds the Swagger UI assets of the API docs page, served
+// by the scheduler itself so the page works offline.
+//
+//go:embed ui/swagger-ui
+var swaggerUIFiles embed.FS
+
+// docsPolicy is the content security policy of the API docs page, which
+// loads Swagger UI from the scheduler.
+const docsPolicy = "default-src 'none'; script-src 'self' 'nonce-%s'; style-src 'self' 'unsafe-inline'; img-src 'self' data:; connect-src 'self'; base-uri 'none'; form-action 'none'; frame-ancestors 'none'"
+
+// handleAPIDocs serves the Swagger UI page for the OpenAPI document.
+func (s *Server) handleAPIDocs(w http.ResponseWriter, r *http.Request) {
+	s.renderTemplate(w, http.StatusOK, uiPages["apidocs"], docsPolicy, uiView{Title: "API"})
+}
+
+// swaggerUIAssets are the files of swaggerUIFiles the API docs page loads;
+// no others are served.
+var swaggerUIAssets = []string{"swagger-ui-bundle.js", "swagger-ui.css"}
+
+// handleAPIDocsAsset serves a Swagger UI asset of the API docs page.
+func (s *Server) handleAPIDocsAsset(w http.ResponseWriter, r *http.Request) {
+	file := chi.URLParam(r, "file")
+	if !slices.Contains(swaggerUIAssets, file) {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	http.ServeFileFS(w, r, swaggerUIFiles, "ui/swagger-ui/"+file)
+}
+
+// versionOrDev returns the version set with SetVersion, or "dev".
+func (s *Server) versionOrDev() string {
+	if s.version == "" {
+		return "dev"
+	}
+	return s.version
+}
+
+// operationID derives an operation ID such as getSchedulesByName.
+func operationID(op apiOperation) string {
+	var id strings.Builder
+	id.WriteString(strings.ToLower(op.method))
+	for _, segment := range strings.Split(op.path, "/") {
+		if segment == "" {
+			continue
+		}
+		if param, ok := strings.CutPrefix(segment, "{"); ok {
+			segment = "by-" + strings.TrimSuffix(param, "}")
+		}
+		for _, word := range strings.Split(segment, "-") {
+			id.WriteString(exported(word))
+		}
+	}
+	return id.String()
+}
+
+func statusCode(status int) string {
+	return strconv.Itoa(status)
+}
+
+// schemaBuilder derives JSON schemas from Go types, following their json
+// tags, and collects the named structs as components.
+type schemaBuilder struct {
+	schemas map[string]any
+}
+
+func (b *schemaBuilder) schema(t reflect.Type) map[string]any {
+	switch t {
+	case reflect.TypeFor[time.Time]():
+		return map[string]any{"type": "string", "format": "date-time"}
+	case reflect.TypeFor[time.Duration]():
+		return map[string]any{"type": "integer", "description": "Nanoseconds"}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return b.schema(t.Elem())
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": b.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	case reflect.Struct:
+		name := schemaName(t)
+		if _, ok := b.schemas[name]; !ok {
+			b.schemas[name] = nil // reserved while the fields are described
+			b.schemas[name] = b.object(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	default:
+		return map[string]any{}
+	}
+}
+
+// object describes a struct's JSON fields; embedded structs are inlined.
+func (b *schemaBuilder) object(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	var required []string
+	var walk func(t reflect.Type)
+	walk = func(t reflect.Type) {
+		for i := range t.NumField() {
+			f := t.Field(i)
+			tag := f.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name, opts, _ := strings.Cut(tag, ",")
+			if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+				walk(f.Type)
+				continue
+			}
+			if !f.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			properties[name] = b.schema(f.Type)
+			if !strings.Contains(opts, "omitempty") && f.Type.Kind() != reflect.Pointer {
+				required = append(required, name)
+			}
+		}
+	}
+	walk(t)
+
+	obj := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		obj["required"] = required
+	}
+	return obj
+}
+
+// schemaName names the component of a struct: server types by their own
+// name, others prefixed with their package, e.g. ConfigScheduleEntry.
+func schemaName(t reflect.Type) string {
+	pkg := path.Base(t.PkgPath())
+	if pkg == "server" {
+		return exported(t.Name())
+	}
+	return exported(pkg) + exported(t.Name())
+}
+
+// exported capitalizes the first letter of a name.
+func exported(name string) string {
+	if name == "" {
+		return name
+	}
+	r := []rune(name)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
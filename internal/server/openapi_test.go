@@ -0,0 +1,86 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAPI_CoversRoutes(t *testing.T) {
+	srv := newTestServer(t, newAPITestConfig())
+
+	routes := map[string]bool{}
+	require.NoError(t, chi.Walk(srv.router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if strings.HasPrefix(route, "/api/v1/") {
+			routes[method+" "+strings.TrimSuffix(route, "/")] = true
+		}
+		return nil
+	}))
+	documented := map[string]bool{}
+	for _, op := range apiOperations {
+		documented[op.method+" /api/v1"+op.path] = true
+	}
+	assert.Equal(t, routes, documented)
+}
+
+func TestOpenAPI_Document(t *testing.T) {
+	srv := newTestServer(t, newAPITestConfig())
+	srv.SetVersion("1.2.3", "abc1234")
+
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Info    struct {
+			Version string `json:"version"`
+		} `json:"info"`
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas map[string]json.RawMessage `json:"schemas"`
+		} `json:"components"`
+	}
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&doc))
+	assert.Equal(t, "3.0.3", doc.OpenAPI)
+	assert.Equal(t, "1.2.3", doc.Info.Version)
+	assert.Contains(t, doc.Paths["/api/v1/status"], "get")
+	assert.Contains(t, doc.Paths["/api/v1/schedules/{name}"], "put")
+	assert.Contains(t, doc.Components.Schemas, "StatusResponse")
+}
+
+func TestAPIDocs(t *testing.T) {
+	srv := newTestServer(t, newAPITestConfig())
+
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/docs", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Security-Policy"), "script-src 'self' 'nonce-")
+	assert.NotContains(t, rec.Header().Get("Content-Security-Policy"), "https:")
+	assert.Contains(t, rec.Body.String(), `url: "/api/openapi.json"`)
+
+	for path, contentType := range map[string]string{
+		"/api/docs/swagger-ui-bundle.js": "text/javascript",
+		"/api/docs/swagger-ui.css":       "text/css",
+	} {
+		assert.Contains(t, rec.Body.String(), path)
+		rec := httptest.NewRecorder()
+		srv.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		require.Equal(t, http.StatusOK, rec.Code, path)
+		assert.Contains(t, rec.Header().Get("Content-Type"), contentType)
+		assert.NotEmpty(t, rec.Body.Bytes())
+	}
+
+	// Only the assets the page loads are served.
+	for _, path := range []string{"/api/docs/missing.js", "/api/docs/LICENSE", "/api/docs/NOTICE"} {
+		rec := httptest.NewRecorder()
+		srv.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusNotFound, rec.Code, path)
+	}
+}
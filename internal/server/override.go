@@ -0,0 +1,190 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/hooks"
+)
+
+// Override modes.
+const (
+	// overrideModeAlbum shows an album chosen on the control page.
+	overrideModeAlbum = "album"
+	// overrideModeParty applies a party mode from the configuration.
+	overrideModeParty = "party"
+	// overrideModeFreeze keeps the selection of the moment it was frozen
+	// until released.
+	overrideModeFreeze = "freeze"
+)
+
+// defaultOverrideDuration applies to control albums and party modes without
+// a configured duration.
+const defaultOverrideDuration = 3 * time.Hour
+
+// albumOverride temporarily replaces the scheduled selection.
+type albumOverride struct {
+	Mode  string `json:"mode"`
+	Name  string `json:"name"`
+	Album string `json:"album,omitempty"` // empty keeps the scheduled album
+	// Params are added to the redirect URL, taking precedence over
+	// passthrough parameters.
+	Params map[string]string `json:"params,omitempty"`
+	// Until is when the override ends; zero for a freeze, which lasts until
+	// released.
+	Until time.Time `json:"until,omitzero"`
+	// entry is the schedule entry a freeze pinned, whose params still apply.
+	entry *config.ScheduleEntry
+}
+
+// schedule returns the schedule name reported while the override is active.
+func (o *albumOverride) schedule() string {
+	switch o.Mode {
+	case overrideModeParty:
+		return "party"
+	case overrideModeFreeze:
+		return o.Name
+	}
+	return "override"
+}
+
+// untilLabel formats the end of the override with layout, or describes a
+// freeze as lasting until released.
+func (o *albumOverride) untilLabel(layout string) string {
+	if o.Until.IsZero() {
+		return "released"
+	}
+	return o.Until.Format(layout)
+}
+
+// overrideDuration returns the configured duration, or the default when unset.
+func overrideDuration(d time.Duration) time.Duration {
+	if d == 0 {
+		return defaultOverrideDuration
+	}
+	return d
+}
+
+// activeOverride returns the override in effect at the given time, or nil.
+func (s *Server) activeOverride(now time.Time) *albumOverride {
+	o := s.override.Load()
+	if o == nil || (!o.Until.IsZero() && !now.Before(o.Until)) {
+		return nil
+	}
+	return o
+}
+
+// startOverride replaces any active override and fires transition hooks.
+// The override ends on its own at o.Until, unless that is zero.
+func (s *Server) startOverride(o *albumOverride, reason string) {
+	s.override.Store(o)
+	s.logger.Info("override started",
+		slog.String("mode", o.Mode),
+		slog.String("name", o.Name),
+		slog.String("album", o.Album),
+		slog.Time("until", o.Until),
+	)
+	s.evaluate(reason)
+	if o.Until.IsZero() {
+		return
+	}
+
+	time.AfterFunc(time.Until(o.Until), func() {
+		if s.override.CompareAndSwap(o, nil) {
+			s.logger.Info("override expired", slog.String("mode", o.Mode), slog.String("name", o.Name))
+			s.evaluate(hooks.ReasonExpired)
+		}
+	})
+}
+
+// clearOverride ends the active override if it has the given mode (or any
+// mode when empty), reporting whether one was cleared.
+func (s *Server) clearOverride(mode, reason string) bool {
+	o := s.activeOverride(time.Now())
+	if o == nil || (mode != "" && o.Mode != mode) || !s.override.CompareAndSwap(o, nil) {
+		return false
+	}
+	s.logger.Info("override cleared", slog.String("mode", o.Mode), slog.String("name", o.Name))
+	s.evaluate(reason)
+	return true
+}
+
+// overrideAlbum returns the album an override names: a control album, or
+// an album alias shown for the default duration. Names are case-insensitive.
+func (st *snapshot) overrideAlbum(name string) (config.ControlAlbum, bool) {
+	for _, a := range st.config.Control.Albums {
+		if strings.EqualFold(a.Name, name) {
+			return a, true
+		}
+	}
+	if id, ok := st.config.Albums[strings.ToLower(name)]; ok {
+		return config.ControlAlbum{Name: strings.ToLower(name), Album: id}, true
+	}
+	return config.ControlAlbum{}, false
+}
+
+// overrideAlbumNames lists the albums overrides accept.
+func (st *snapshot) overrideAlbumNames() []string {
+	names := make([]string, 0, len(st.config.Control.Albums)+len(st.config.Albums))
+	for _, a := range st.config.Control.Albums {
+		names = append(names, a.Name)
+	}
+	for alias := range st.config.Albums {
+		names = append(names, alias)
+	}
+	slices.Sort(names[len(st.config.Control.Albums):])
+	return names
+}
+
+// startOverrideRequest is the body of POST /api/v1/override.
+type startOverrideRequest struct {
+	// Album is a control album name or an album alias.
+	Album string `json:"album"`
+	// Duration overrides the album's duration, e.g. "90m".
+	Duration string `json:"duration,omitempty"`
+}
+
+// handleStartOverride shows a control album or aliased album, replacing
+// any running override.
+func (s *Server) handleStartOverride(w http.ResponseWriter, r *http.Request) {
+	var req startOverrideRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	st := s.current()
+	a, ok := st.overrideAlbum(req.Album)
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("album %q not found; use one of: %s", req.Album, strings.Join(st.overrideAlbumNames(), ", ")))
+		return
+	}
+	var d time.Duration
+	if req.Duration != "" {
+		var err error
+		if d, err = time.ParseDuration(req.Duration); err != nil || d <= 0 {
+			writeError(w, http.StatusUnprocessableEntity, fmt.Sprintf("invalid duration %q", req.Duration))
+			return
+		}
+	}
+
+	before := s.activeOverride(time.Now())
+	o := newAlbumOverride(a, d)
+	s.startOverride(o, hooks.ReasonUpdate)
+	s.audit(r, auditEntry{Action: auditOverrideStart, Target: o.Name, Before: before, After: o})
+	writeJSON(w, http.StatusOK, o)
+}
+
+// handleClearOverride ends the running override, if any, including party
+// modes.
+func (s *Server) handleClearOverride(w http.ResponseWriter, r *http.Request) {
+	before := s.activeOverride(time.Now())
+	if before != nil && s.clearOverride("", hooks.ReasonUpdate) {
+		s.audit(r, auditEntry{Action: auditOverrideClear, Target: before.Name, Before: before})
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
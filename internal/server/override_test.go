@@ -0,0 +1,44 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPI_Override(t *testing.T) {
+	srv := newChatTestServer(t)
+
+	rec := apiRequest(srv, http.MethodPost, "/api/v1/override", `{"album": "garden", "duration": "45m"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var o albumOverride
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&o))
+	assert.Equal(t, overrideModeAlbum, o.Mode)
+	assert.Equal(t, "Garden", o.Name)
+	assert.Equal(t, "garden-album", o.Album)
+	assert.WithinDuration(t, time.Now().Add(45*time.Minute), o.Until, time.Minute)
+	assert.Equal(t, "override", srv.active.Schedule)
+
+	rec = apiRequest(srv, http.MethodPost, "/api/v1/override", `{"album": "attic"}`)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Contains(t, rec.Body.String(), "use one of: Garden, beach")
+	rec = apiRequest(srv, http.MethodPost, "/api/v1/override", `{"album": "beach", "duration": "-1h"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Equal(t, "garden-album", srv.override.Load().Album)
+
+	rec = apiRequest(srv, http.MethodDelete, "/api/v1/override", "")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Nil(t, srv.override.Load())
+	assert.NotEqual(t, "override", srv.active.Schedule)
+
+	rec = apiRequest(srv, http.MethodGet, "/api/v1/audit?action="+auditOverrideClear, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var audit auditResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&audit))
+	require.Len(t, audit.Entries, 1)
+	assert.Equal(t, "automation", audit.Entries[0].Actor)
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+)
+
+// Display pages replace redirects and browser error pages on displays.
+const (
+	pageMaintenance = "maintenance"
+	pageError       = "error"
+	pageNotFound    = "not_found"
+	pageQuietHours  = "quiet_hours"
+)
+
+// errorPageRefresh is how often the error page retries, in seconds.
+const errorPageRefresh = 60
+
+// displayPage is the data of pages served to displays in place of a redirect.
+type displayPage struct {
+	Status  int
+	Message string
+	// Refresh is how often the page reloads itself, in seconds; zero never.
+	Refresh int
+}
+
+// displayPagePolicy is the content security policy of display pages. Custom
+// pages may also load images and fonts, e.g. a background photo.
+const displayPagePolicy = "default-src 'none'; style-src 'nonce-%s'; img-src 'self' data: https:; font-src 'self' data: https:; base-uri 'none'; form-action 'none'; frame-ancestors 'none'"
+
+// loadPages parses the custom display pages.
+func loadPages(cfg config.PagesConfig) (map[string]*template.Template, error) {
+	pages := make(map[string]*template.Template)
+	for _, p := range []struct {
+		name string
+		page config.PageTemplate
+	}{{pageMaintenance, cfg.Maintenance}, {pageError, cfg.Error}, {pageNotFound, cfg.NotFound}, {pageQuietHours, cfg.QuietHours}} {
+		name, page := p.name, p.page
+		if !page.IsSet() {
+			continue
+		}
+		text := string(page.HTML)
+		if page.File != "" {
+			data, err := os.ReadFile(page.File)
+			if err != nil {
+				return nil, fmt.Errorf("pages.%s: %w", name, err)
+			}
+			text = string(data)
+		}
+		tmpl, err := template.New(name).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("pages.%s: %w", name, err)
+		}
+		pages[name] = tmpl
+	}
+	return pages, nil
+}
+
+// serveDisplayPage renders a custom display page when configured, or the
+// built-in message page (a blank page for quiet hours).
+func (s *Server) serveDisplayPage(w http.ResponseWriter, name string, page displayPage) {
+	tmpl, ok := s.current().pages[name]
+	if !ok {
+		tmpl = uiPages["message"]
+		if name == pageQuietHours {
+			tmpl = uiPages["blank"]
+		}
+	}
+	s.renderTemplate(w, page.Status, tmpl, displayPagePolicy, uiView{Title: "Photo frame", Page: page})
+}
+
+// handleNotFound answers unknown paths with the not found page, and API
+// paths with a JSON error.
+func (s *Server) handleNotFound(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	s.serveDisplayPage(w, pageNotFound, displayPage{
+		Status:  http.StatusNotFound,
+		Message: "There is nothing here.",
+	})
+}
+
+// serveRedirectError answers a display with the error page instead of a
+// redirect. The page retries periodically.
+func (s *Server) serveRedirectError(w http.ResponseWriter, status int, message string) {
+	s.serveDisplayPage(w, pageError, displayPage{Status: status, Message: message, Refresh: errorPageRefresh})
+}
+
+// quietHoursSchedule is the schedule reported during quiet hours.
+const quietHoursSchedule = "quiet_hours"
+
+// serveQuietHours answers a display with the blank quiet hours page, which
+// reloads itself when quiet hours end.
+func (s *Server) serveQuietHours(w http.ResponseWriter, now, end time.Time) {
+	s.updateCurrentScheduleMetric(quietHoursSchedule)
+	refresh := int(end.Sub(now).Round(time.Second)/time.Second) + 1
+	s.serveDisplayPage(w, pageQuietHours, displayPage{Status: http.StatusOK, Refresh: refresh})
+}
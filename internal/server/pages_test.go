@@ -0,0 +1,82 @@
+package server
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/scheduler"
+)
+
+func TestDisplayPages_Custom(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "maintenance.html")
+	require.NoError(t, os.WriteFile(file, []byte(`<style nonce="{{.Nonce}}"></style><h1>Custom {{.Page.Status}}</h1><p>{{.Page.Message}}</p>`), 0o600))
+
+	cfg := newAPITestConfig()
+	cfg.Maintenance = config.MaintenanceConfig{Enabled: true, Message: "<Upgrading>"}
+	cfg.Pages = config.PagesConfig{
+		Maintenance: config.PageTemplate{File: file},
+		NotFound:    config.PageTemplate{HTML: `<p>Lost? {{.Page.Status}}</p>`},
+	}
+	srv := newTestServer(t, cfg)
+
+	rec := guestRequest(srv, http.MethodGet, "/")
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "300", rec.Header().Get("Retry-After"))
+	assert.Contains(t, rec.Body.String(), "<h1>Custom 503</h1><p>&lt;Upgrading&gt;</p>")
+	assert.Contains(t, rec.Header().Get("Content-Security-Policy"), "img-src 'self' data: https:")
+
+	rec = guestRequest(srv, http.MethodGet, "/no-such-page")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "<p>Lost? 404</p>", rec.Body.String())
+
+	// API clients keep getting JSON.
+	rec = guestRequest(srv, http.MethodGet, "/api/v1/no-such-endpoint")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"error"`)
+}
+
+func TestDisplayPages_BuiltIn(t *testing.T) {
+	cfg := newAPITestConfig()
+	cfg.Debug.AllowDateOverride = true
+	srv := newTestServer(t, cfg)
+
+	rec := guestRequest(srv, http.MethodGet, "/?_date=not-a-date")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), `http-equiv="refresh" content="60"`)
+
+	rec = guestRequest(srv, http.MethodGet, "/no-such-page")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Contains(t, rec.Body.String(), "There is nothing here.")
+	assert.NotContains(t, rec.Body.String(), "http-equiv")
+}
+
+func TestDisplayPages_Invalid(t *testing.T) {
+	cfg := newAPITestConfig()
+	cfg.Pages.Error = config.PageTemplate{HTML: "{{.Page.Message"}
+	sched, err := scheduler.New(cfg)
+	require.NoError(t, err)
+	_, err = New(cfg, sched)
+	assert.ErrorContains(t, err, "pages.error")
+
+	cfg.Pages.Error = config.PageTemplate{File: filepath.Join(t.TempDir(), "missing.html")}
+	_, err = New(cfg, sched)
+	assert.ErrorContains(t, err, "pages.error")
+
+	// A reload with a broken page keeps the previous configuration.
+	srv := newTestServer(t, newAPITestConfig())
+	before := srv.current().revision
+	assert.Error(t, srv.Reload(func() (*config.Config, error) { return cfg, nil }))
+	assert.Equal(t, before, srv.current().revision)
+}
+
+func TestMaintenanceRetryAfterDefault(t *testing.T) {
+	assert.Equal(t, 5*time.Minute, maintenanceRetryAfter(config.MaintenanceConfig{}))
+}
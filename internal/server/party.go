@@ -0,0 +1,121 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/hooks"
+)
+
+// partyStatusResponse is the body of GET /api/v1/party.
+type partyStatusResponse struct {
+	Modes []string `json:"modes"`
+	// Active is the running party mode, or null.
+	Active *albumOverride `json:"active"`
+}
+
+// startPartyRequest is the optional body of POST /api/v1/party/{name}.
+type startPartyRequest struct {
+	// Duration overrides the configured duration, e.g. "90m".
+	Duration string `json:"duration,omitempty"`
+}
+
+// partyMode returns the configured party mode with the given name.
+func (st *snapshot) partyMode(name string) (config.PartyMode, bool) {
+	i := slices.IndexFunc(st.config.PartyModes, func(m config.PartyMode) bool { return m.Name == name })
+	if i < 0 {
+		return config.PartyMode{}, false
+	}
+	return st.config.PartyModes[i], true
+}
+
+// newPartyOverride returns the override applying a party mode for d, or
+// its configured duration when zero.
+func newPartyOverride(mode config.PartyMode, d time.Duration) *albumOverride {
+	if d == 0 {
+		d = overrideDuration(mode.Duration)
+	}
+	return &albumOverride{
+		Mode:   overrideModeParty,
+		Name:   mode.Name,
+		Album:  mode.Album,
+		Params: mode.Params,
+		Until:  time.Now().Add(d),
+	}
+}
+
+// startParty activates a party mode for d, or its configured duration when zero.
+func (s *Server) startParty(mode config.PartyMode, d time.Duration) *albumOverride {
+	o := newPartyOverride(mode, d)
+	s.startOverride(o, hooks.ReasonParty)
+	return o
+}
+
+// activeParty returns the running party mode, or nil.
+func (s *Server) activeParty(now time.Time) *albumOverride {
+	if o := s.activeOverride(now); o != nil && o.Mode == overrideModeParty {
+		return o
+	}
+	return nil
+}
+
+// handlePartyStatus lists the party modes and the running one.
+func (s *Server) handlePartyStatus(w http.ResponseWriter, r *http.Request) {
+	st := s.current()
+
+	resp := partyStatusResponse{Modes: []string{}, Active: s.activeParty(time.Now())}
+	for _, m := range st.config.PartyModes {
+		resp.Modes = append(resp.Modes, m.Name)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleStartParty activates a party mode, replacing any running override.
+func (s *Server) handleStartParty(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	mode, ok := s.current().partyMode(name)
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("party mode %q not found", name))
+		return
+	}
+
+	// The body is optional.
+	var req startPartyRequest
+	if err := decodeJSON(w, r, &req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var d time.Duration
+	if req.Duration != "" {
+		var err error
+		if d, err = time.ParseDuration(req.Duration); err != nil || d <= 0 {
+			writeError(w, http.StatusUnprocessableEntity, fmt.Sprintf("invalid duration %q", req.Duration))
+			return
+		}
+	}
+
+	o := s.startParty(mode, d)
+	s.logger.Info("party mode started via API", slog.String("party", name), slog.String("token", tokenName(r.Context())))
+	s.audit(r, auditEntry{Action: auditPartyStart, Target: name, After: o})
+	writeJSON(w, http.StatusOK, o)
+}
+
+// handleStopParty ends the running party mode, if any.
+func (s *Server) handleStopParty(w http.ResponseWriter, r *http.Request) {
+	active := s.activeParty(time.Now())
+	if s.clearOverride(overrideModeParty, hooks.ReasonParty) {
+		s.logger.Info("party mode stopped via API", slog.String("token", tokenName(r.Context())))
+		if active != nil {
+			s.audit(r, auditEntry{Action: auditPartyStop, Target: active.Name, Before: active})
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
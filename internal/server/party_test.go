@@ -0,0 +1,120 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/hooks"
+)
+
+func newPartyTestServer(t *testing.T, hookTargets ...config.HookConfig) *Server {
+	t.Helper()
+	cfg := newAPITestConfig()
+	cfg.Hooks = hookTargets
+	cfg.Schedule = nil
+	cfg.PassthroughParams = []string{"transition"}
+	cfg.APITokens = []config.APIToken{{Name: "automation", Token: testAPIToken}}
+	cfg.PartyModes = []config.PartyMode{
+		{Name: "birthday", Album: "party-album", Params: map[string]string{"duration": "5", "transition": "fade"}, Duration: 4 * time.Hour},
+		{Name: "fast", Params: map[string]string{"duration": "3"}},
+	}
+	return newTestServer(t, cfg)
+}
+
+func TestAPI_Party(t *testing.T) {
+	events := make(chan hooks.Event, 2)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev hooks.Event
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&ev))
+		events <- ev
+	}))
+	defer ts.Close()
+
+	srv := newPartyTestServer(t, config.HookConfig{Name: "test", URL: ts.URL})
+
+	rec := apiRequest(srv, http.MethodPost, "/api/v1/party/birthday", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var started albumOverride
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&started))
+	assert.Equal(t, "birthday", started.Name)
+	assert.WithinDuration(t, time.Now().Add(4*time.Hour), started.Until, time.Minute)
+
+	// Party params win over passthrough params
+	target, _ := url.Parse(redirectTarget(t, srv))
+	assert.Equal(t, "party-album", target.Query().Get("album"))
+	assert.Equal(t, "5", target.Query().Get("duration"))
+	assert.Equal(t, "fade", target.Query().Get("transition"))
+
+	rec = apiRequest(srv, http.MethodGet, "/api/v1/party", "")
+	var status partyStatusResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&status))
+	assert.Equal(t, []string{"birthday", "fast"}, status.Modes)
+	require.NotNil(t, status.Active)
+	assert.Equal(t, "birthday", status.Active.Name)
+
+	rec = apiRequest(srv, http.MethodDelete, "/api/v1/party", "")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Nil(t, srv.activeOverride(time.Now()))
+
+	// Deliveries are concurrent, so the start and end events may arrive in either order.
+	srv.hooks.Wait()
+	require.Len(t, events, 2)
+	current := map[string]hooks.Event{}
+	for range 2 {
+		ev := <-events
+		assert.Equal(t, hooks.ReasonParty, ev.Reason)
+		current[ev.Current.Schedule] = ev
+	}
+	assert.Equal(t, "party-album", current["party"].Current.Album)
+	assert.Equal(t, "party", current["default"].Previous.Schedule)
+}
+
+func TestAPI_PartyWithoutAlbumKeepsSchedule(t *testing.T) {
+	srv := newPartyTestServer(t)
+
+	rec := apiRequest(srv, http.MethodPost, "/api/v1/party/fast", `{"duration": "30m"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	o := srv.activeOverride(time.Now())
+	require.NotNil(t, o)
+	assert.WithinDuration(t, time.Now().Add(30*time.Minute), o.Until, time.Minute)
+
+	target, _ := url.Parse(redirectTarget(t, srv))
+	assert.Equal(t, "default-album-id", target.Query().Get("album"))
+	assert.Equal(t, "3", target.Query().Get("duration"))
+}
+
+func TestAPI_PartyExpires(t *testing.T) {
+	srv := newPartyTestServer(t)
+	mode, _ := srv.current().partyMode("birthday")
+
+	srv.startParty(mode, 10*time.Millisecond)
+	require.Eventually(t, func() bool {
+		srv.transitionMu.Lock()
+		defer srv.transitionMu.Unlock()
+		return srv.active.Schedule == "default"
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestAPI_PartyErrors(t *testing.T) {
+	srv := newPartyTestServer(t)
+
+	rec := apiRequest(srv, http.MethodPost, "/api/v1/party/missing", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = apiRequest(srv, http.MethodPost, "/api/v1/party/birthday", `{"duration": "-1h"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/party/birthday", nil)
+	rec = httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/hooks"
+)
+
+// profileDoc is the state document holding the profile chosen through the API.
+const profileDoc = "profile"
+
+// profileState is the persisted profile choice.
+type profileState struct {
+	Name string `json:"name"`
+}
+
+// profileResponse is the body of GET /api/v1/profile and PUT /api/v1/profile/{name}.
+type profileResponse struct {
+	Profiles []string `json:"profiles"`
+	// Active is empty when no profiles are configured.
+	Active string `json:"active"`
+}
+
+// loadProfile returns the persisted profile choice, or "" when there is none.
+func (s *Server) loadProfile() string {
+	var saved profileState
+	if _, err := s.store.Load(profileDoc, &saved); err != nil {
+		s.logger.Error("failed to load the active profile, using the configured one", slog.Any("error", err))
+		return ""
+	}
+	return saved.Name
+}
+
+// profileFor returns the profile to activate for a configuration: the one
+// chosen through the API if the configuration still has it, otherwise the
+// configured default.
+func (s *Server) profileFor(cfg *config.Config) string {
+	if s.profile != "" && slices.Contains(cfg.ProfileNames(), s.profile) {
+		return s.profile
+	}
+	if s.profile != "" {
+		s.logger.Warn("active profile no longer configured, using the default",
+			slog.String("profile", s.profile),
+			slog.String("default", cfg.DefaultProfile()),
+		)
+	}
+	return cfg.DefaultProfile()
+}
+
+// switchProfile activates a profile and persists the choice.
+func (s *Server) switchProfile(name string) (previous, next *snapshot, err error) {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	st := s.current()
+	if !slices.Contains(st.config.ProfileNames(), name) {
+		return nil, nil, &apiError{http.StatusNotFound, fmt.Sprintf("profile %q not found", name)}
+	}
+
+	chosen := s.profile
+	s.profile = name
+	previous, next, err = s.apply(st.config.WithoutProfile(st.profile))
+	if err != nil {
+		s.profile = chosen
+		return nil, nil, err
+	}
+	if err := s.store.Save(profileDoc, profileState{Name: name}); err != nil {
+		s.logger.Error("failed to persist the active profile", slog.String("profile", name), slog.Any("error", err))
+	}
+
+	s.evaluate(hooks.ReasonProfile)
+	return previous, next, nil
+}
+
+// handleProfileStatus lists the profiles and the active one.
+func (s *Server) handleProfileStatus(w http.ResponseWriter, r *http.Request) {
+	st := s.current()
+	writeJSON(w, http.StatusOK, profileResponse{Profiles: st.config.ProfileNames(), Active: st.profile})
+}
+
+// handleSwitchProfile activates a profile. Unlike other admin API changes,
+// the choice survives configuration reloads and restarts.
+func (s *Server) handleSwitchProfile(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	previous, next, err := s.switchProfile(name)
+	if err != nil {
+		writeUpdateError(w, err)
+		return
+	}
+
+	s.logger.Info("profile switched via API",
+		slog.String("previous", previous.profile),
+		slog.String("profile", next.profile),
+		slog.String("token", tokenName(r.Context())),
+		slog.String("revision", next.revision),
+	)
+	s.audit(r, auditEntry{Action: auditProfileSwitch, Target: name, Before: previous.profile, After: next.profile, Revision: next.revision})
+
+	w.Header().Set("ETag", `"`+next.revision+`"`)
+	writeJSON(w, http.StatusOK, profileResponse{Profiles: next.config.ProfileNames(), Active: next.profile})
+}
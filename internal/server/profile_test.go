@@ -0,0 +1,94 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+)
+
+func newProfileTestConfig(stateDir string) *config.Config {
+	cfg := newAPITestConfig()
+	cfg.StateDir = stateDir
+	cfg.APITokens = []config.APIToken{{Name: "automation", Token: testAPIToken}}
+	cfg.Debug = config.DebugConfig{AllowDateOverride: true}
+	cfg.Schedule = nil
+	cfg.Profiles = []config.Profile{
+		{Name: "normal", Schedule: []config.ScheduleEntry{
+			{Name: "christmas", Album: "christmas-album", Start: "11-15", End: "01-01"},
+		}},
+		{Name: "grandparents-visiting", Schedule: []config.ScheduleEntry{
+			{Name: "family", Album: "family-album", Start: "01-01", End: "12-31"},
+		}},
+	}
+	return cfg
+}
+
+func TestAPI_SwitchProfile(t *testing.T) {
+	dir := t.TempDir()
+	cfg := newProfileTestConfig(dir)
+	srv := newTestServer(t, cfg)
+	assert.Contains(t, redirectTarget(t, srv), "album=default-album-id")
+
+	rec := apiRequest(srv, http.MethodGet, "/api/v1/profile", "")
+	var status profileResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&status))
+	assert.Equal(t, profileResponse{Profiles: []string{"normal", "grandparents-visiting"}, Active: "normal"}, status)
+
+	rec = apiRequest(srv, http.MethodPut, "/api/v1/profile/grandparents-visiting", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, `"`+srv.current().revision+`"`, rec.Header().Get("ETag"))
+	assert.Equal(t, "grandparents-visiting", srv.current().profile)
+	assert.Contains(t, redirectTarget(t, srv), "album=family-album")
+
+	// The choice survives a reload and a restart.
+	require.NoError(t, srv.Reload(func() (*config.Config, error) { return newProfileTestConfig(dir), nil }))
+	assert.Contains(t, redirectTarget(t, srv), "album=family-album")
+	restarted := newTestServer(t, newProfileTestConfig(dir))
+	assert.Equal(t, "grandparents-visiting", restarted.current().profile)
+	assert.Contains(t, redirectTarget(t, restarted), "album=family-album")
+
+	rec = apiRequest(srv, http.MethodPut, "/api/v1/profile/open-house", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestAPI_SwitchProfileRequiresEditor(t *testing.T) {
+	srv := newTestServer(t, newProfileTestConfig(""))
+	assert.Equal(t, http.StatusUnauthorized, guestRequest(srv, http.MethodPut, "/api/v1/profile/grandparents-visiting").Code)
+	assert.Equal(t, "normal", srv.current().profile)
+}
+
+func TestAPI_ScheduleChangesApplyToActiveProfile(t *testing.T) {
+	srv := newTestServer(t, newProfileTestConfig(""))
+	rec := apiRequest(srv, http.MethodPut, "/api/v1/profile/grandparents-visiting", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = apiRequest(srv, http.MethodDelete, "/api/v1/schedules/family", "")
+	require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+	assert.Empty(t, srv.current().config.Schedule)
+
+	// The other profile is unchanged, and the change is kept when switching back.
+	rec = apiRequest(srv, http.MethodPut, "/api/v1/profile/normal", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "christmas", srv.current().config.Schedule[0].Name)
+	rec = apiRequest(srv, http.MethodPut, "/api/v1/profile/grandparents-visiting", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Empty(t, srv.current().config.Schedule)
+}
+
+func TestServer_RemovedProfileFallsBackToDefault(t *testing.T) {
+	dir := t.TempDir()
+	srv := newTestServer(t, newProfileTestConfig(dir))
+	rec := apiRequest(srv, http.MethodPut, "/api/v1/profile/grandparents-visiting", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	cfg := newProfileTestConfig(dir)
+	cfg.Profiles = cfg.Profiles[:1]
+	require.NoError(t, srv.Reload(func() (*config.Config, error) { return cfg, nil }))
+	assert.Equal(t, "normal", srv.current().profile)
+	assert.Len(t, srv.current().config.Schedule, 1)
+}
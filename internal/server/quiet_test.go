@@ -0,0 +1,104 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+)
+
+// quietHoursNow returns quiet hours that include the current time.
+func quietHoursNow(album string) config.QuietHoursConfig {
+	now := time.Now()
+	return config.QuietHoursConfig{
+		Start: now.Add(-time.Hour).Format("15:04"),
+		End:   now.Add(time.Hour).Format("15:04"),
+		Album: album,
+	}
+}
+
+func TestRedirect_QuietHoursAlbum(t *testing.T) {
+	cfg := newAPITestConfig()
+	cfg.QuietHours = quietHoursNow("night-album")
+	srv := newTestServer(t, cfg)
+
+	assert.Contains(t, redirectTarget(t, srv), "album=night-album")
+	assert.Equal(t, quietHoursSchedule, srv.selectionAt(srv.current(), time.Now()).Schedule)
+}
+
+func TestRedirect_QuietHoursBlank(t *testing.T) {
+	cfg := newAPITestConfig()
+	cfg.QuietHours = quietHoursNow(config.QuietHoursNoAlbum)
+	srv := newTestServer(t, cfg)
+
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("Location"))
+	assert.Contains(t, rec.Body.String(), "background: #000")
+	assert.Contains(t, rec.Body.String(), `http-equiv="refresh"`)
+}
+
+func TestRedirect_QuietHoursOutsideWindow(t *testing.T) {
+	now := time.Now()
+	cfg := newAPITestConfig()
+	cfg.QuietHours = config.QuietHoursConfig{
+		Start: now.Add(time.Hour).Format("15:04"),
+		End:   now.Add(2 * time.Hour).Format("15:04"),
+		Album: "night-album",
+	}
+	srv := newTestServer(t, cfg)
+
+	assert.NotContains(t, redirectTarget(t, srv), "night-album")
+}
+
+func TestRedirect_OverrideWinsOverQuietHours(t *testing.T) {
+	srv := newPartyTestServer(t)
+	cfg := srv.current().config.Clone()
+	cfg.QuietHours = quietHoursNow("")
+	_, _, err := srv.apply(cfg)
+	assert.NoError(t, err)
+
+	mode, _ := srv.current().partyMode("birthday")
+	srv.startParty(mode, time.Hour)
+	assert.Contains(t, redirectTarget(t, srv), "album=party-album")
+}
+
+func TestRedirect_NightParams(t *testing.T) {
+	window := quietHoursNow("")
+	cfg := newAPITestConfig()
+	cfg.NightParams = []config.NightParams{{
+		Start:  window.Start,
+		End:    window.End,
+		Params: map[string]string{"brightness": "30", "duration": "120"},
+	}}
+	srv := newTestServer(t, cfg)
+
+	target := redirectTarget(t, srv)
+	assert.Contains(t, target, "album=default-album-id")
+	assert.Contains(t, target, "brightness=30")
+	assert.Contains(t, target, "duration=120")
+}
+
+func TestRedirect_OverrideParamsWinOverNightParams(t *testing.T) {
+	srv := newPartyTestServer(t)
+	window := quietHoursNow("")
+	cfg := srv.current().config.Clone()
+	cfg.NightParams = []config.NightParams{{
+		Start:  window.Start,
+		End:    window.End,
+		Params: map[string]string{"brightness": "30", "transition": "none"},
+	}}
+	_, _, err := srv.apply(cfg)
+	assert.NoError(t, err)
+
+	mode, _ := srv.current().partyMode("birthday")
+	srv.startParty(mode, time.Hour)
+	target := redirectTarget(t, srv)
+	assert.Contains(t, target, "brightness=30")
+	assert.Contains(t, target, "transition=fade")
+}
@@ -0,0 +1,209 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/rules"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/scheduler"
+)
+
+// deviceParam is the query parameter identifying the display to when
+// conditions.
+const deviceParam = "device"
+
+// redirectBase is the part of a redirect that depends only on the selected
+// album and entry: the parsed kiosk URL with the album and default params.
+// The scheduled base is computed once per decision, i.e. once per day for
+// the static schedule, and cached on the snapshot, so the redirect hot path
+// neither matches schedules nor parses kiosk_url.
+type redirectBase struct {
+	// from and until bound the period the scheduled base is valid for.
+	from, until time.Time
+	schedule    string
+	album       string
+	entry       *config.ScheduleEntry
+	kiosk       url.URL
+	// query holds the album and default params; it must not be modified.
+	query url.Values
+	// plain is the redirect URL for requests without params.
+	plain string
+}
+
+// newRedirectBase prepares the redirect for album and the selected entry.
+func (st *snapshot) newRedirectBase(album string, entry *config.ScheduleEntry) (*redirectBase, error) {
+	album = st.config.AlbumID(album)
+	// A path template selects the album in the path instead of the query.
+	kioskURL := strings.ReplaceAll(st.config.KioskURL, config.AlbumPlaceholder, url.PathEscape(album))
+	u, err := url.Parse(kioskURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid kiosk URL: %w", err)
+	}
+
+	q := u.Query()
+	if !st.config.HasAlbumPath() {
+		q.Set("album", album)
+	}
+	for param, value := range st.config.DefaultParams {
+		q.Set(param, value)
+	}
+
+	b := &redirectBase{album: album, entry: entry, kiosk: *u, query: q}
+	b.plain = b.encode(applyEntry(cloneValues(q), entry))
+	return b, nil
+}
+
+// scheduledBase returns the redirect base of the schedule selected at now,
+// computing and caching it when the cached one is for another day.
+func (st *snapshot) scheduledBase(now time.Time) (*redirectBase, error) {
+	if b := st.base.Load(); b != nil && !now.Before(b.from) && now.Before(b.until) {
+		redirectBaseHits.Add(1)
+		return b, nil
+	}
+	redirectBaseMisses.Add(1)
+	b, err := st.scheduledBaseFor(now)
+	if err != nil {
+		return nil, err
+	}
+	st.base.Store(b)
+	return b, nil
+}
+
+// scheduledBaseFor computes the redirect base of the decision for t without
+// caching it.
+func (st *snapshot) scheduledBaseFor(t time.Time) (*redirectBase, error) {
+	return st.decisionBase(t, st.backend.Resolve(t))
+}
+
+// requestBase computes the redirect base for a request whose decision
+// depends on the request: on when conditions, evaluated against its device
+// and query parameters, or on the decision service's answer for its device.
+// It is not cached.
+func (st *snapshot) requestBase(t time.Time, r *http.Request) (*redirectBase, error) {
+	return st.decisionBase(t, st.decide(t, r))
+}
+
+// decide returns the decision for a request at t, asking the decision
+// service for the request's device or evaluating when conditions against
+// its device and query parameters if they matter. The service is only asked
+// for known devices, registered or listed in a device profile; others get
+// the configured device's decision, so arbitrary device parameters cannot
+// make the scheduler call the service.
+func (st *snapshot) decide(t time.Time, r *http.Request) scheduler.Decision {
+	if st.decisions != nil {
+		device := r.URL.Query().Get(deviceParam)
+		if !st.knownDevice(device) {
+			device = ""
+		}
+		return st.decisions.ResolveDevice(t, device)
+	}
+	if !st.conditional() {
+		return st.backend.Resolve(t)
+	}
+	q := r.URL.Query()
+	return st.scheduler.ResolveEnv(rules.Env{Time: t, Device: q.Get(deviceParam), Query: q})
+}
+
+// perDevice reports whether decisions depend on the requesting device, so
+// they cannot share the cached redirect base.
+func (st *snapshot) perDevice() bool {
+	return st.decisions != nil || st.conditional()
+}
+
+// conditional reports whether decisions depend on the request, which is the
+// case when schedule entries have when conditions and no decision service
+// is configured.
+func (st *snapshot) conditional() bool {
+	return st.config.Decision.URL == "" && st.scheduler.Conditional()
+}
+
+// decisionBase prepares the redirect base of decision d made at t.
+func (st *snapshot) decisionBase(t time.Time, d scheduler.Decision) (*redirectBase, error) {
+	b, err := st.newRedirectBase(d.Album, d.Entry)
+	if err != nil {
+		return nil, err
+	}
+	b.schedule = d.Schedule
+	b.from = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	b.until = d.Until
+	return b, nil
+}
+
+// build returns the redirect URL for the request, adding passthrough params,
+// the entry's params and extra params to the base.
+func (b *redirectBase) build(st *snapshot, r *http.Request, extra map[string]string) string {
+	if r.URL.RawQuery == "" && len(extra) == 0 {
+		return b.plain
+	}
+
+	q := cloneValues(b.query)
+	query := r.URL.Query()
+
+	// The requesting device's profile may add default params and replace
+	// the passthrough rules.
+	passthrough := st.passthrough
+	if profile := st.deviceProfile(query); profile != nil {
+		passthrough = profile.passthrough
+		for param, value := range profile.defaultParams {
+			q.Set(param, value)
+		}
+	}
+
+	// Add passthrough params from the original request. Renamed params are
+	// applied first so a param sent under the kiosk's own name wins.
+	for from, to := range st.config.ParamMap {
+		if value := query.Get(from); value != "" {
+			q.Set(to, value)
+		}
+	}
+	for param, values := range query {
+		if _, renamed := st.config.ParamMap[param]; renamed {
+			continue
+		}
+		if passthrough.forwards(param) && values[0] != "" {
+			// URL encoding happens automatically when we call q.Encode()
+			q.Set(param, values[0])
+		}
+	}
+	applyEntry(q, b.entry)
+	for param, value := range extra {
+		q.Set(param, value)
+	}
+
+	return b.encode(q)
+}
+
+// encode returns the kiosk URL with the given query.
+func (b *redirectBase) encode(q url.Values) string {
+	u := b.kiosk
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// applyEntry applies the params and removals of a schedule entry to q.
+func applyEntry(q url.Values, entry *config.ScheduleEntry) url.Values {
+	if entry == nil {
+		return q
+	}
+	for param, value := range entry.Params {
+		q.Set(param, value)
+	}
+	for _, param := range entry.RemoveParams {
+		q.Del(param)
+	}
+	return q
+}
+
+// cloneValues copies q so params can be set and deleted on the copy. The
+// value slices are shared, so they must not be appended to.
+func cloneValues(q url.Values) url.Values {
+	clone := make(url.Values, len(q)+4)
+	for key, values := range q {
+		clone[key] = values
+	}
+	return clone
+}
@@ -0,0 +1,240 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/decision"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/redirectsig"
+)
+
+func TestSnapshot_ScheduledBaseCachedPerDay(t *testing.T) {
+	srv := newTestServer(t, newAPITestConfig())
+	st := srv.current()
+
+	christmas := time.Date(2024, 12, 24, 9, 0, 0, 0, time.Local)
+	first, err := st.scheduledBase(christmas)
+	require.NoError(t, err)
+	assert.Equal(t, "christmas", first.schedule)
+	assert.Equal(t, "https://kiosk.example.com?album=christmas-album", first.plain)
+
+	// Later the same day the cached base is reused.
+	again, err := st.scheduledBase(christmas.Add(14 * time.Hour))
+	require.NoError(t, err)
+	assert.Same(t, first, again)
+
+	// At the next day's boundary it is replaced.
+	next, err := st.scheduledBase(time.Date(2024, 12, 25, 0, 0, 0, 0, time.Local))
+	require.NoError(t, err)
+	assert.NotSame(t, first, next)
+	assert.Same(t, next, st.base.Load())
+
+	summer, err := st.scheduledBase(time.Date(2024, 7, 1, 12, 0, 0, 0, time.Local))
+	require.NoError(t, err)
+	assert.Equal(t, "default", summer.schedule)
+	assert.Equal(t, "https://kiosk.example.com?album=default-album-id", summer.plain)
+}
+
+func TestRedirectBase_Build(t *testing.T) {
+	cfg := newAPITestConfig()
+	cfg.PassthroughParams = []string{"transition"}
+	cfg.DefaultParams = map[string]string{"duration": "30"}
+	srv := newTestServer(t, cfg)
+	st := srv.current()
+
+	entry := &config.ScheduleEntry{Params: map[string]string{"image_effect": "none"}, RemoveParams: []string{"duration"}}
+	base, err := st.newRedirectBase("album-1", entry)
+	require.NoError(t, err)
+
+	plain := httptest.NewRequest(http.MethodGet, "/", nil)
+	assert.Equal(t, "https://kiosk.example.com?album=album-1&image_effect=none", base.build(st, plain, nil))
+
+	withParams := httptest.NewRequest(http.MethodGet, "/?transition=fade", nil)
+	assert.Equal(t, "https://kiosk.example.com?album=album-1&image_effect=none&transition=fade", base.build(st, withParams, nil))
+	assert.Equal(t, "https://kiosk.example.com?album=album-1&duration=5&image_effect=none&transition=fade",
+		base.build(st, withParams, map[string]string{"duration": "5"}))
+
+	// Building with params leaves the cached base untouched.
+	assert.Equal(t, "https://kiosk.example.com?album=album-1&image_effect=none", base.build(st, plain, nil))
+	assert.Equal(t, "30", base.query.Get("duration"))
+}
+
+func TestServer_ReloadDropsCachedRedirect(t *testing.T) {
+	srv := newTestServer(t, newAPITestConfig())
+	assert.Contains(t, redirectTarget(t, srv), "kiosk.example.com")
+
+	next := newAPITestConfig()
+	next.KioskURL = "https://frame.example.com"
+	require.NoError(t, srv.Reload(func() (*config.Config, error) { return next, nil }))
+
+	assert.Contains(t, redirectTarget(t, srv), "frame.example.com")
+}
+
+func TestRedirect_KioskAuth(t *testing.T) {
+	cfg := newAPITestConfig()
+	cfg.PassthroughParams = []string{"transition", "password"}
+	cfg.KioskAuth = config.KioskAuthConfig{Password: "kiosk s3cret"}
+	srv := newTestServer(t, cfg)
+
+	// The client cannot replace the password.
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?transition=fade&password=guess", nil))
+	require.Equal(t, http.StatusFound, rec.Code)
+	location := rec.Header().Get("Location")
+	assert.Contains(t, location, "transition=fade")
+	assert.Contains(t, location, "&password=kiosk+s3cret")
+	assert.NotContains(t, location, "guess")
+
+	st := srv.current()
+	assert.Equal(t, "https://kiosk.example.com/?album=a&pw=x#slide", (&snapshot{config: &config.Config{
+		KioskAuth: config.KioskAuthConfig{Password: "x", Param: "pw"},
+	}}).withKioskAuth("https://kiosk.example.com/?album=a#slide"))
+	assert.Equal(t, "https://kiosk.example.com/?password=kiosk+s3cret", st.withKioskAuth("https://kiosk.example.com/"))
+}
+
+func TestRedirect_CacheBust(t *testing.T) {
+	cfg := newAPITestConfig()
+	cfg.PassthroughMode = config.PassthroughAllExcept
+	cfg.CacheBust = config.CacheBustConfig{Mode: config.CacheBustRevision}
+	srv := newTestServer(t, cfg)
+
+	location := func(target string) url.Values {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		srv.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		require.Equal(t, http.StatusFound, rec.Code)
+		u, err := url.Parse(rec.Header().Get("Location"))
+		require.NoError(t, err)
+		return u.Query()
+	}
+
+	// The revision is stable within a schedule period and cannot be set by
+	// the client.
+	first := location("/?_ts=forged")
+	require.Len(t, first["_ts"], 1)
+	assert.Len(t, first.Get("_ts"), 12)
+	assert.Equal(t, first.Get("_ts"), location("/").Get("_ts"))
+
+	other := srv.current().withCacheBust("https://kiosk.example.com/", "christmas", "christmas-album", time.Now())
+	assert.NotContains(t, other, "_ts="+first.Get("_ts"))
+
+	// A configuration change gives a new revision.
+	next := newAPITestConfig()
+	next.CacheBust = cfg.CacheBust
+	next.DefaultAlbum = "other-album"
+	require.NoError(t, srv.Reload(func() (*config.Config, error) { return next, nil }))
+	assert.NotEqual(t, first.Get("_ts"), location("/").Get("_ts"))
+
+	timestamp := &snapshot{config: &config.Config{CacheBust: config.CacheBustConfig{Mode: config.CacheBustTimestamp, Param: "v"}}}
+	assert.Equal(t, "https://kiosk.example.com/?album=a&v=1767225600#slide",
+		timestamp.withCacheBust("https://kiosk.example.com/?album=a#slide", "default", "a", time.Unix(1767225600, 0)))
+}
+
+func TestRedirect_Signed(t *testing.T) {
+	const secret = "0123456789abcdef0123456789abcdef"
+	cfg := newAPITestConfig()
+	cfg.PassthroughMode = config.PassthroughAllExcept
+	cfg.KioskAuth = config.KioskAuthConfig{Password: "kiosk-s3cret"}
+	cfg.Signing = config.SigningConfig{Secret: secret}
+	srv := newTestServer(t, cfg)
+
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?transition=fade&sig=forged&ts=1", nil))
+	require.Equal(t, http.StatusFound, rec.Code)
+
+	location := rec.Header().Get("Location")
+	assert.Equal(t, 1, strings.Count(location, "sig="), location)
+	assert.Contains(t, location, "&password=kiosk-s3cret&ts=")
+	assert.NoError(t, redirectsig.Verify(secret, location, "ts", "sig", time.Now(), time.Minute))
+}
+
+func TestRedirect_DeviceProfiles(t *testing.T) {
+	cfg := newAPITestConfig()
+	cfg.PassthroughParams = []string{"transition", "device"}
+	cfg.DefaultParams = map[string]string{"transition": "fade", "duration": "60"}
+	cfg.DeviceProfiles = []config.DeviceProfile{{
+		Name:              "eink",
+		Devices:           []string{"hallway"},
+		PassthroughParams: []string{"refresh"},
+		DefaultParams:     map[string]string{"transition": "none"},
+	}}
+	srv := newTestServer(t, cfg)
+
+	redirect := func(target string) url.Values {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		srv.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		require.Equal(t, http.StatusFound, rec.Code)
+		u, err := url.Parse(rec.Header().Get("Location"))
+		require.NoError(t, err)
+		return u.Query()
+	}
+
+	q := redirect("/?device=hallway&refresh=300&transition=slide")
+	assert.Equal(t, "none", q.Get("transition"), "profile default, transition not passed through")
+	assert.Equal(t, "60", q.Get("duration"))
+	assert.Equal(t, "300", q.Get("refresh"))
+	assert.False(t, q.Has("device"))
+
+	q = redirect("/?device=tv&refresh=300&transition=slide")
+	assert.Equal(t, "slide", q.Get("transition"))
+	assert.Equal(t, "tv", q.Get("device"))
+	assert.False(t, q.Has("refresh"))
+}
+
+func TestServer_RedirectDecisionPerDevice(t *testing.T) {
+	answer := "first"
+	var calls atomic.Int32
+	service := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		var req decision.Request
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		_ = json.NewEncoder(w).Encode(decision.Response{Album: req.Device + "-" + answer})
+	}))
+	defer service.Close()
+
+	cfg := newAPITestConfig()
+	cfg.APITokens = []config.APIToken{{Name: "automation", Token: testAPIToken}}
+	cfg.Decision = config.DecisionConfig{URL: service.URL, Device: "scheduler"}
+	srv := newTestServer(t, cfg)
+	for _, id := range []string{"kitchen", "hallway"} {
+		srv.devices.register(registeredDevice{ID: id})
+	}
+
+	target := func(device string) string {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		srv.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?device="+device, nil))
+		require.Equal(t, http.StatusFound, rec.Code)
+		return rec.Header().Get("Location")
+	}
+	assert.Contains(t, target("kitchen"), "album=kitchen-first")
+	assert.Contains(t, target("hallway"), "album=hallway-first")
+	assert.Contains(t, redirectTarget(t, srv), "album=scheduler-first")
+
+	// Unknown devices get the configured device's decision without asking
+	// the service again.
+	before := calls.Load()
+	for i := range 5 {
+		assert.Contains(t, target(fmt.Sprintf("unknown-%d", i)), "album=scheduler-first")
+	}
+	assert.Equal(t, before, calls.Load())
+
+	// Decisions are cached until a re-evaluation drops them.
+	answer = "second"
+	assert.Contains(t, target("kitchen"), "album=kitchen-first")
+	rec := apiRequest(srv, http.MethodPost, "/api/v1/reevaluate", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, target("kitchen"), "album=kitchen-second")
+}
@@ -0,0 +1,409 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/netip"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/decision"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/hooks"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/scheduler"
+)
+
+// Reload metrics
+var (
+	configReloadsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "immich_kiosk_scheduler_config_reloads_total",
+			Help: "Total number of configuration reload attempts by result",
+		},
+		[]string{"result"},
+	)
+
+	configLastReloadSuccessful = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "immich_kiosk_scheduler_config_last_reload_successful",
+			Help: "Whether the last configuration reload attempt succeeded (1 = success)",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(configReloadsTotal)
+	prometheus.MustRegister(configLastReloadSuccessful)
+	configLastReloadSuccessful.Set(1)
+}
+
+// snapshot is the configuration-derived state used to serve requests.
+// It is immutable and replaced as a whole when the configuration is reloaded,
+// so requests never observe a partially applied configuration.
+type snapshot struct {
+	// config has the active profile's schedule as its Schedule.
+	config   *config.Config
+	revision string
+	// profile is the active profile, or empty without profiles.
+	profile   string
+	scheduler *scheduler.Scheduler
+	// backend decides the album; it is the static scheduler unless another
+	// decision source is configured.
+	backend scheduler.Backend
+	// decisions is the backend when a decision service is configured, which
+	// decides per requesting device.
+	decisions   *decision.Backend
+	passthrough *passthroughRules
+	// devices holds the device profiles by the identifiers they list, and
+	// profiles by name.
+	devices  map[string]*deviceProfile
+	profiles map[string]*deviceProfile
+	// registry assigns device profiles to registered devices.
+	registry *deviceRegistry
+	// pages holds the custom display pages by name.
+	pages map[string]*template.Template
+	// authNetworks holds the parsed forward_auth.allowed_networks.
+	authNetworks []netip.Prefix
+	// trustedProxies holds the parsed forward_auth.trusted_proxies.
+	trustedProxies []netip.Prefix
+	// kiosk names the kiosk the snapshot serves, or is empty for the
+	// top-level configuration, whose snapshot holds the named kiosks'.
+	kiosk  string
+	kiosks map[string]*snapshot
+	// shadow is the serving state of the candidate configuration of
+	// shadow.config, or nil.
+	shadow *snapshot
+	// candidate marks the snapshot of the shadow configuration.
+	candidate bool
+	// dwells is set when entries have a min_duration. dwellScope keeps the
+	// selections held for a kiosk or the shadow configuration apart.
+	dwells     bool
+	dwellScope string
+	// base caches the redirect of the currently scheduled album.
+	base atomic.Pointer[redirectBase]
+}
+
+// newSnapshot derives the serving state from a configuration and scheduler.
+func newSnapshot(cfg *config.Config, sched *scheduler.Scheduler) (*snapshot, error) {
+	// Clients cannot replace the kiosk password, the cache-busting
+	// parameter or the signature.
+	var reserved []string
+	if param := cfg.KioskAuth.QueryParam(); param != "" {
+		reserved = append(reserved, param)
+	}
+	if param := cfg.CacheBust.QueryParam(); param != "" {
+		reserved = append(reserved, param)
+	}
+	if cfg.Signing.Enabled() {
+		reserved = append(reserved, cfg.Signing.SignatureParam(), cfg.Signing.TimestampParamName())
+	}
+	passthrough := newPassthroughRules(cfg.PassthroughMode, cfg.PassthroughParams, cfg.BlockedParams, reserved)
+
+	devices := make(map[string]*deviceProfile)
+	profiles := make(map[string]*deviceProfile, len(cfg.DeviceProfiles))
+	for _, p := range cfg.DeviceProfiles {
+		profile := &deviceProfile{name: p.Name, passthrough: passthrough, defaultParams: p.DefaultParams}
+		if p.OverridesPassthrough() {
+			profile.passthrough = newPassthroughRules(p.PassthroughMode, p.PassthroughParams, p.BlockedParams, reserved)
+		}
+		profiles[p.Name] = profile
+		for _, d := range p.Devices {
+			devices[d] = profile
+		}
+	}
+
+	pages, err := loadPages(cfg.Pages)
+	if err != nil {
+		return nil, err
+	}
+	authNetworks, err := cfg.ForwardAuth.Networks()
+	if err != nil {
+		return nil, err
+	}
+	trustedProxies, err := cfg.ForwardAuth.Proxies()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		backend   scheduler.Backend = sched
+		decisions *decision.Backend
+	)
+	if cfg.Decision.URL != "" {
+		decisions = decision.New(cfg.Decision, sched, slog.Default())
+		backend = decisions
+	}
+
+	return &snapshot{
+		config:         cfg,
+		revision:       cfg.Revision(),
+		scheduler:      sched,
+		backend:        backend,
+		decisions:      decisions,
+		passthrough:    passthrough,
+		devices:        devices,
+		profiles:       profiles,
+		pages:          pages,
+		authNetworks:   authNetworks,
+		trustedProxies: trustedProxies,
+		dwells: slices.ContainsFunc(cfg.Schedule, func(e config.ScheduleEntry) bool {
+			return e.MinDuration != ""
+		}),
+	}, nil
+}
+
+// passthroughRules decide which client query parameters are forwarded.
+type passthroughRules struct {
+	allExcept bool
+	allowed   map[string]bool
+	// blocked holds the lowercased parameters not forwarded in all_except mode.
+	blocked map[string]bool
+}
+
+// newPassthroughRules builds the rules of a passthrough mode. The reserved
+// params, like the scheduler's own, are never forwarded.
+func newPassthroughRules(mode string, params, blocked, reserved []string) *passthroughRules {
+	// Build passthrough params map for O(1) lookup
+	rules := &passthroughRules{
+		allExcept: mode == config.PassthroughAllExcept,
+		allowed:   make(map[string]bool, len(params)),
+		blocked:   map[string]bool{"album": true, "info": true, dateOverrideParam: true},
+	}
+	for _, p := range params {
+		if sanitized, valid := config.SanitizeParam(p); valid {
+			rules.allowed[sanitized] = true
+		}
+	}
+	for _, p := range blocked {
+		rules.blocked[strings.ToLower(strings.TrimSpace(p))] = true
+	}
+	for _, param := range reserved {
+		delete(rules.allowed, param)
+		rules.blocked[strings.ToLower(param)] = true
+	}
+	return rules
+}
+
+// forwards reports whether a client query parameter is passed on to the kiosk.
+func (p *passthroughRules) forwards(param string) bool {
+	if p.allExcept {
+		_, valid := config.SanitizeParam(param)
+		return valid && !p.blocked[strings.ToLower(param)]
+	}
+	return p.allowed[param]
+}
+
+// deviceProfile holds the passthrough rules and default params of the
+// devices of a device profile.
+type deviceProfile struct {
+	name          string
+	passthrough   *passthroughRules
+	defaultParams map[string]string
+}
+
+// ReloadStatus describes the outcome of configuration reloads.
+type ReloadStatus struct {
+	LastAttempt *time.Time `json:"last_attempt,omitempty"`
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+}
+
+// reloadTracker records reload outcomes for the status API.
+type reloadTracker struct {
+	mu     sync.Mutex
+	status ReloadStatus
+}
+
+func (t *reloadTracker) record(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	t.status.LastAttempt = &now
+	if err != nil {
+		t.status.LastError = err.Error()
+		return
+	}
+	t.status.LastSuccess = &now
+	t.status.LastError = ""
+}
+
+func (t *reloadTracker) get() ReloadStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.status
+}
+
+// current returns the snapshot used to serve requests.
+func (s *Server) current() *snapshot {
+	return s.state.Load()
+}
+
+// Reload loads a new configuration and applies it atomically. If loading,
+// validation or scheduler construction fails, the error is recorded and
+// returned and the server keeps serving the previous configuration.
+//
+// Settings that shape the HTTP listener and routes (port, h2c, metrics,
+// tracing, compression) and the state directory only take effect after a restart.
+func (s *Server) Reload(load func() (*config.Config, error)) error {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	err := s.reload(load)
+	s.reloads.record(err)
+
+	if err != nil {
+		configReloadsTotal.WithLabelValues("failure").Inc()
+		configLastReloadSuccessful.Set(0)
+		s.logger.Error("configuration reload failed, keeping previous configuration",
+			slog.String("revision", s.current().revision),
+			slog.Any("error", err),
+		)
+		return err
+	}
+
+	configReloadsTotal.WithLabelValues("success").Inc()
+	configLastReloadSuccessful.Set(1)
+
+	// The new configuration may select a different album right away.
+	s.evaluate(hooks.ReasonReload)
+	return nil
+}
+
+func (s *Server) reload(load func() (*config.Config, error)) error {
+	cfg, err := load()
+	if err != nil {
+		return err
+	}
+
+	previous, next, err := s.apply(cfg)
+	if err != nil {
+		return err
+	}
+
+	if previous.revision == next.revision {
+		s.logger.Info("configuration unchanged", slog.String("revision", next.revision))
+		return nil
+	}
+	s.logger.Info("configuration reloaded",
+		slog.String("previous_revision", previous.revision),
+		slog.String("revision", next.revision),
+		slog.Int("schedules", next.scheduler.GetScheduleCount()),
+	)
+	return nil
+}
+
+// apply validates a configuration and makes it the serving configuration,
+// using the active profile's schedule followed by the entries discovered
+// from Immich. Callers must hold reloadMu.
+func (s *Server) apply(cfg *config.Config) (previous, next *snapshot, err error) {
+	return s.applyWith(cfg, nil)
+}
+
+// applyWith implements apply. sched, when set, is a changed copy of the
+// serving scheduler to serve cfg with; it is brought in line with cfg's
+// schedule, which the profile and discovered entries can reorder.
+func (s *Server) applyWith(cfg *config.Config, sched *scheduler.Scheduler) (previous, next *snapshot, err error) {
+	// Discovered entries are added anew, never taken from the input.
+	cfg = cfg.WithoutDiscovered()
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	profile := s.profileFor(cfg)
+	if cfg, err = cfg.WithProfile(profile); err != nil {
+		return nil, nil, err
+	}
+	cfg = s.withDiscovered(cfg)
+
+	switch {
+	case sched == nil:
+		if sched, err = scheduler.New(cfg); err != nil {
+			return nil, nil, fmt.Errorf("failed to create scheduler: %w", err)
+		}
+	case sched.GetDefaultAlbum() != cfg.DefaultAlbum || !reflect.DeepEqual(sched.Entries(), cfg.Schedule):
+		if err = sched.Replace(cfg.DefaultAlbum, cfg.Schedule); err != nil {
+			return nil, nil, fmt.Errorf("failed to update scheduler: %w", err)
+		}
+	}
+	s.setSources(sched)
+
+	next, err = newSnapshot(cfg, sched)
+	if err != nil {
+		return nil, nil, err
+	}
+	next.profile = profile
+	next.registry = s.devices
+	if next.kiosks, err = s.newKioskSnapshots(cfg); err != nil {
+		return nil, nil, err
+	}
+	next.shadow = s.newShadowSnapshot(cfg)
+	previous = s.state.Swap(next)
+	updateMaintenanceMetric(cfg.Maintenance)
+	if previous.config.KioskURL != cfg.KioskURL {
+		go s.probeKioskURL(context.Background())
+	}
+	for _, w := range sched.Warnings() {
+		s.logger.Warn("schedule warning", slog.Any("warning", w))
+	}
+	return previous, next, nil
+}
+
+// update applies a change to a copy of the serving configuration. The change
+// function may reject the change by returning an error, which is returned
+// unchanged. Changes made this way last until the configuration is next
+// reloaded from its source.
+func (s *Server) update(change func(cfg *config.Config) error) (*snapshot, error) {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	st := s.current()
+	cfg := st.config.Clone()
+	if err := change(cfg); err != nil {
+		return nil, err
+	}
+	return s.commit(st, cfg, nil)
+}
+
+// updateSchedule changes the schedule entries through the mutation methods
+// of a copy of the serving scheduler, which then serves the changed
+// schedule. Like update, the change function may reject the change.
+func (s *Server) updateSchedule(change func(sched *scheduler.Scheduler) error) (*snapshot, error) {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	st := s.current()
+	sched := st.scheduler.Clone()
+	if err := change(sched); err != nil {
+		return nil, err
+	}
+	cfg := st.config.Clone()
+	cfg.Schedule = sched.Entries()
+	return s.commit(st, cfg, sched)
+}
+
+// commit serves cfg, a changed copy of the configuration of st, with sched
+// if set. Callers must hold reloadMu.
+func (s *Server) commit(st *snapshot, cfg *config.Config, sched *scheduler.Scheduler) (*snapshot, error) {
+	// Schedule changes apply to the active profile.
+	changed := cfg.WithoutProfile(st.profile)
+	changed.MarkChanged(st.config.WithoutProfile(st.profile), config.SourceAPI)
+	previous, next, err := s.applyWith(changed, sched)
+	if err != nil {
+		return nil, err
+	}
+	s.logger.Info("configuration updated",
+		slog.String("previous_revision", previous.revision),
+		slog.String("revision", next.revision),
+	)
+
+	s.evaluate(hooks.ReasonUpdate)
+	return next, nil
+}
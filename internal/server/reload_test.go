@@ -0,0 +1,84 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_Reload(t *testing.T) {
+	srv := newTestServer(t, newAPITestConfig())
+	before := srv.current().revision
+
+	next := newAPITestConfig()
+	next.DefaultAlbum = "new-default"
+	next.Schedule = nil
+
+	require.NoError(t, srv.Reload(func() (*config.Config, error) { return next, nil }))
+
+	assert.NotEqual(t, before, srv.current().revision)
+	assert.Equal(t, 1.0, testutil.ToFloat64(configLastReloadSuccessful))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, req)
+	assert.Equal(t, "https://kiosk.example.com?album=new-default", rec.Header().Get("Location"))
+}
+
+func TestServer_ReloadKeepsLastKnownGood(t *testing.T) {
+	tests := []struct {
+		name string
+		load func() (*config.Config, error)
+	}{
+		{
+			name: "load error",
+			load: func() (*config.Config, error) { return nil, errors.New("yaml: line 3: bad indentation") },
+		},
+		{
+			name: "invalid config",
+			load: func() (*config.Config, error) {
+				cfg := newAPITestConfig()
+				cfg.KioskURL = "ftp://kiosk.example.com"
+				return cfg, nil
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestServer(t, newAPITestConfig())
+			before := srv.current()
+			failures := testutil.ToFloat64(configReloadsTotal.WithLabelValues("failure"))
+
+			err := srv.Reload(tt.load)
+			require.Error(t, err)
+
+			// Still serving the previous configuration
+			assert.Same(t, before, srv.current())
+			assert.Equal(t, failures+1, testutil.ToFloat64(configReloadsTotal.WithLabelValues("failure")))
+			assert.Equal(t, 0.0, testutil.ToFloat64(configLastReloadSuccessful))
+
+			// The error is exposed on the status API
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/status", nil)
+			rec := httptest.NewRecorder()
+			srv.router.ServeHTTP(rec, req)
+			var status statusResponse
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&status))
+			assert.Equal(t, err.Error(), status.ConfigReload.LastError)
+			assert.NotNil(t, status.ConfigReload.LastAttempt)
+			assert.Nil(t, status.ConfigReload.LastSuccess)
+
+			req = httptest.NewRequest(http.MethodGet, "/", nil)
+			rec = httptest.NewRecorder()
+			srv.router.ServeHTTP(rec, req)
+			assert.Equal(t, http.StatusFound, rec.Code)
+		})
+	}
+}
@@ -0,0 +1,423 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/scheduler"
+)
+
+// maxRequestBody bounds JSON request bodies.
+const maxRequestBody = 1 << 20
+
+// createScheduleRequest is the body of POST /api/v1/schedules.
+type createScheduleRequest struct {
+	config.ScheduleEntry
+	// Position is the 0-based index in evaluation order; the first matching
+	// entry wins, so lower positions take priority. Omitted appends the entry.
+	Position *int `json:"position,omitempty"`
+}
+
+// updateScheduleRequest is the body of PUT /api/v1/schedules/{name}.
+type updateScheduleRequest struct {
+	config.ScheduleEntry
+	// Position moves the entry in evaluation order; omitted keeps it in place.
+	Position *int `json:"position,omitempty"`
+}
+
+// scheduleResponse is the body returned for a single schedule entry.
+type scheduleResponse struct {
+	Revision string               `json:"revision"`
+	Position int                  `json:"position"`
+	Schedule config.ScheduleEntry `json:"schedule"`
+	// Warnings lists the schedule warnings involving this entry.
+	Warnings []scheduler.Warning `json:"warnings"`
+}
+
+// replaceSchedulesRequest is the body of PUT /api/v1/schedules.
+type replaceSchedulesRequest struct {
+	Schedules []config.ScheduleEntry `json:"schedules"`
+}
+
+// replaceSchedulesResponse is the response to PUT /api/v1/schedules.
+type replaceSchedulesResponse struct {
+	Revision  string                 `json:"revision"`
+	Schedules []config.ScheduleEntry `json:"schedules"`
+	Diff      scheduleDiff           `json:"diff"`
+	Warnings  []scheduler.Warning    `json:"warnings"`
+}
+
+// scheduleDiff describes a schedule replacement, matching entries by name.
+type scheduleDiff struct {
+	Added   []config.ScheduleEntry `json:"added"`
+	Removed []config.ScheduleEntry `json:"removed"`
+	Changed []scheduleChange       `json:"changed"`
+	// Reordered is set when entries present before and after the change are
+	// evaluated in a different relative order.
+	Reordered bool `json:"reordered"`
+}
+
+// scheduleChange is an entry kept under the same name with a different
+// album or date range.
+type scheduleChange struct {
+	Name   string               `json:"name"`
+	Before config.ScheduleEntry `json:"before"`
+	After  config.ScheduleEntry `json:"after"`
+}
+
+// apiError is returned by configuration changes to reject a request with a
+// specific status code.
+type apiError struct {
+	status  int
+	message string
+}
+
+func (e *apiError) Error() string {
+	return e.message
+}
+
+// handleGetSchedule returns a single schedule entry and its position.
+func (s *Server) handleGetSchedule(w http.ResponseWriter, r *http.Request) {
+	st := s.current()
+	name := chi.URLParam(r, "name")
+
+	position := scheduleIndex(st.config.Schedule, name)
+	if position < 0 {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("schedule %q not found", name))
+		return
+	}
+	if notModified(w, r, `"`+st.revision+`"`) {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, scheduleResponse{
+		Revision: st.revision,
+		Position: position,
+		Schedule: st.config.Schedule[position],
+		Warnings: warningsFor(st.scheduler, name),
+	})
+}
+
+// handleCreateSchedule inserts a schedule entry into the live configuration.
+func (s *Server) handleCreateSchedule(w http.ResponseWriter, r *http.Request) {
+	var req createScheduleRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	entry := req.ScheduleEntry
+	if err := entry.Validate(); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	var position int
+	st, err := s.updateSchedule(func(sched *scheduler.Scheduler) error {
+		if err := s.checkIfMatch(r); err != nil {
+			return err
+		}
+		count := sched.GetScheduleCount()
+		position = count
+		if req.Position != nil {
+			if *req.Position < 0 || *req.Position > count {
+				return &apiError{http.StatusUnprocessableEntity, fmt.Sprintf("position must be between 0 and %d", count)}
+			}
+			position = *req.Position
+		}
+		if err := sched.Add(entry); err != nil {
+			return scheduleError(err, entry.Name)
+		}
+		return sched.Move(entry.Name, position)
+	})
+	if err != nil {
+		writeUpdateError(w, err)
+		return
+	}
+
+	s.logger.Info("schedule created",
+		slog.String("schedule", entry.Name),
+		slog.Int("position", position),
+		slog.String("token", tokenName(r.Context())),
+		slog.String("revision", st.revision),
+	)
+
+	s.audit(r, auditEntry{Action: auditScheduleCreate, Target: entry.Name, After: entry, Revision: st.revision})
+
+	w.Header().Set("Location", "/api/v1/schedules/"+url.PathEscape(entry.Name))
+	w.Header().Set("ETag", `"`+st.revision+`"`)
+	writeJSON(w, http.StatusCreated, scheduleResponse{
+		Revision: st.revision,
+		Position: position,
+		Schedule: entry,
+		Warnings: warningsFor(st.scheduler, entry.Name),
+	})
+}
+
+// handleUpdateSchedule replaces a schedule entry, optionally renaming or
+// moving it.
+func (s *Server) handleUpdateSchedule(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	var req updateScheduleRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	entry := req.ScheduleEntry
+	if entry.Name == "" {
+		entry.Name = name
+	}
+	if err := entry.Validate(); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	var position int
+	var before config.ScheduleEntry
+	st, err := s.updateSchedule(func(sched *scheduler.Scheduler) error {
+		if err := s.checkIfMatch(r); err != nil {
+			return err
+		}
+		entries := sched.Entries()
+		current := scheduleIndex(entries, name)
+		if current < 0 {
+			return &apiError{http.StatusNotFound, fmt.Sprintf("schedule %q not found", name)}
+		}
+		before = entries[current]
+
+		position = current
+		if req.Position != nil {
+			if *req.Position < 0 || *req.Position >= len(entries) {
+				return &apiError{http.StatusUnprocessableEntity, fmt.Sprintf("position must be between 0 and %d", len(entries)-1)}
+			}
+			position = *req.Position
+		}
+		if err := sched.Update(name, entry); err != nil {
+			return scheduleError(err, entry.Name)
+		}
+		return sched.Move(entry.Name, position)
+	})
+	if err != nil {
+		writeUpdateError(w, err)
+		return
+	}
+
+	s.logger.Info("schedule updated",
+		slog.String("schedule", name),
+		slog.String("new_name", entry.Name),
+		slog.Int("position", position),
+		slog.String("token", tokenName(r.Context())),
+		slog.String("revision", st.revision),
+	)
+	s.audit(r, auditEntry{Action: auditScheduleUpdate, Target: name, Before: before, After: entry, Revision: st.revision})
+
+	w.Header().Set("ETag", `"`+st.revision+`"`)
+	writeJSON(w, http.StatusOK, scheduleResponse{
+		Revision: st.revision,
+		Position: position,
+		Schedule: entry,
+		Warnings: warningsFor(st.scheduler, entry.Name),
+	})
+}
+
+// handleDeleteSchedule removes a schedule entry.
+func (s *Server) handleDeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	var before config.ScheduleEntry
+	st, err := s.updateSchedule(func(sched *scheduler.Scheduler) error {
+		if err := s.checkIfMatch(r); err != nil {
+			return err
+		}
+		entries := sched.Entries()
+		i := scheduleIndex(entries, name)
+		if i < 0 {
+			return &apiError{http.StatusNotFound, fmt.Sprintf("schedule %q not found", name)}
+		}
+		before = entries[i]
+		return sched.Remove(name)
+	})
+	if err != nil {
+		writeUpdateError(w, err)
+		return
+	}
+
+	s.logger.Info("schedule deleted",
+		slog.String("schedule", name),
+		slog.String("token", tokenName(r.Context())),
+		slog.String("revision", st.revision),
+	)
+	s.audit(r, auditEntry{Action: auditScheduleDelete, Target: name, Before: before, Revision: st.revision})
+
+	w.Header().Set("ETag", `"`+st.revision+`"`)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleReplaceSchedules atomically replaces the whole schedule list.
+func (s *Server) handleReplaceSchedules(w http.ResponseWriter, r *http.Request) {
+	var req replaceSchedulesRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	// Guard against wiping the schedule with a malformed body; [] clears it.
+	if req.Schedules == nil {
+		writeError(w, http.StatusBadRequest, "schedules is required")
+		return
+	}
+
+	seen := make(map[string]bool, len(req.Schedules))
+	for i, entry := range req.Schedules {
+		if err := entry.Validate(); err != nil {
+			writeError(w, http.StatusUnprocessableEntity, fmt.Sprintf("schedule %d: %v", i, err))
+			return
+		}
+		if seen[entry.Name] {
+			writeError(w, http.StatusUnprocessableEntity, fmt.Sprintf("schedule %q is listed more than once", entry.Name))
+			return
+		}
+		seen[entry.Name] = true
+	}
+
+	var diff scheduleDiff
+	var before []config.ScheduleEntry
+	st, err := s.updateSchedule(func(sched *scheduler.Scheduler) error {
+		if err := s.checkIfMatch(r); err != nil {
+			return err
+		}
+		before = sched.Entries()
+		diff = diffSchedules(before, req.Schedules)
+		return sched.Replace(sched.GetDefaultAlbum(), req.Schedules)
+	})
+	if err != nil {
+		writeUpdateError(w, err)
+		return
+	}
+
+	s.logger.Info("schedules replaced",
+		slog.Int("added", len(diff.Added)),
+		slog.Int("removed", len(diff.Removed)),
+		slog.Int("changed", len(diff.Changed)),
+		slog.Bool("reordered", diff.Reordered),
+		slog.String("token", tokenName(r.Context())),
+		slog.String("revision", st.revision),
+	)
+	s.audit(r, auditEntry{Action: auditSchedulesReplace, Before: before, After: st.config.Schedule, Revision: st.revision})
+
+	w.Header().Set("ETag", `"`+st.revision+`"`)
+	writeJSON(w, http.StatusOK, replaceSchedulesResponse{
+		Revision:  st.revision,
+		Schedules: st.config.Schedule,
+		Diff:      diff,
+		Warnings:  st.scheduler.Warnings(),
+	})
+}
+
+// diffSchedules compares two schedule lists by entry name.
+func diffSchedules(before, after []config.ScheduleEntry) scheduleDiff {
+	diff := scheduleDiff{
+		Added:   []config.ScheduleEntry{},
+		Removed: []config.ScheduleEntry{},
+		Changed: []scheduleChange{},
+	}
+
+	var keptBefore, keptAfter []string
+	for _, old := range before {
+		i := scheduleIndex(after, old.Name)
+		if i < 0 {
+			diff.Removed = append(diff.Removed, old)
+			continue
+		}
+		keptBefore = append(keptBefore, old.Name)
+		if !after[i].Equal(old) {
+			diff.Changed = append(diff.Changed, scheduleChange{Name: old.Name, Before: old, After: after[i]})
+		}
+	}
+	for _, entry := range after {
+		if scheduleIndex(before, entry.Name) < 0 {
+			diff.Added = append(diff.Added, entry)
+			continue
+		}
+		keptAfter = append(keptAfter, entry.Name)
+	}
+	diff.Reordered = !slices.Equal(keptBefore, keptAfter)
+
+	return diff
+}
+
+// checkIfMatch rejects the request with 412 Precondition Failed when it
+// carries an If-Match header that does not match the current configuration
+// revision. Requests without If-Match are not checked. Callers must hold
+// reloadMu so the revision cannot change before the update is applied.
+func (s *Server) checkIfMatch(r *http.Request) error {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return nil
+	}
+
+	etag := `"` + s.current().revision + `"`
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == etag || candidate == "*" {
+			return nil
+		}
+	}
+	return &apiError{http.StatusPreconditionFailed, fmt.Sprintf("configuration revision is %s; reload and retry", etag)}
+}
+
+// scheduleIndex returns the position of the named entry, or -1.
+func scheduleIndex(schedule []config.ScheduleEntry, name string) int {
+	return slices.IndexFunc(schedule, func(e config.ScheduleEntry) bool { return e.Name == name })
+}
+
+// warningsFor returns the schedule warnings involving the named entry.
+func warningsFor(sched *scheduler.Scheduler, name string) []scheduler.Warning {
+	warnings := []scheduler.Warning{}
+	for _, w := range sched.Warnings() {
+		if slices.Contains(w.Entries, name) {
+			warnings = append(warnings, w)
+		}
+	}
+	return warnings
+}
+
+// decodeJSON decodes a JSON request body, rejecting unknown fields.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+	return nil
+}
+
+// scheduleError maps the errors of scheduler mutations to API errors; name
+// is the entry a conflict is reported for.
+func scheduleError(err error, name string) error {
+	if errors.Is(err, scheduler.ErrEntryExists) {
+		return &apiError{http.StatusConflict, fmt.Sprintf("schedule %q already exists", name)}
+	}
+	return err
+}
+
+// writeUpdateError reports a failed configuration change.
+func writeUpdateError(w http.ResponseWriter, err error) {
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		writeError(w, apiErr.status, apiErr.message)
+		return
+	}
+	// The changed configuration failed validation.
+	writeError(w, http.StatusUnprocessableEntity, err.Error())
+}
@@ -3,12 +3,19 @@ package server
 
 import (
 	"context"
+	"crypto/sha256"
 	"crypto/subtle"
-	"encoding/json"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -17,7 +24,20 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/email"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/gitsync"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/graphql"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/homeassistant"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/hooks"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/immich"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/mqtt"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/notify"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/redirectsig"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/remote"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/rules"
 	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/scheduler"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/state"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/tracing"
 )
 
 // Metrics for Prometheus
@@ -37,91 +57,368 @@ var (
 		},
 		[]string{"schedule"},
 	)
+
+	accessLogDropped = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "immich_kiosk_scheduler_access_log_dropped_total",
+			Help: "Total number of successful redirect log entries dropped by sampling",
+		},
+	)
+)
+
+// Process metrics, so dashboards can show the version and restarts of each
+// instance.
+var (
+	buildInfo = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "immich_kiosk_scheduler_build_info",
+			Help: "Version and commit of the running build (always 1)",
+		},
+		[]string{"version", "commit"},
+	)
+
+	startTime = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "immich_kiosk_scheduler_start_time_seconds",
+			Help: "Unix time the process started",
+		},
+	)
+
+	up = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "immich_kiosk_scheduler_up",
+			Help: "Whether the scheduler is serving (1) or shutting down (0)",
+		},
+	)
 )
 
 func init() {
 	prometheus.MustRegister(redirectsTotal)
 	prometheus.MustRegister(currentSchedule)
+	prometheus.MustRegister(accessLogDropped)
+	prometheus.MustRegister(buildInfo, startTime, up)
+	startTime.Set(float64(time.Now().Unix()))
+}
+
+// compressibleTypes are the content types gzip-compressed for API and UI responses.
+var compressibleTypes = []string{
+	"application/json",
+	"application/javascript",
+	"application/yaml",
+	"image/svg+xml",
+	"text/calendar",
+	"text/css",
+	"text/csv",
+	"text/html",
+	"text/javascript",
+	"text/plain",
+}
+
+// logSampledKey is the context key marking whether a request's logs are sampled in.
+type logSampledKey struct{}
+
+// logSampler keeps 1 in every rate redirect log entries.
+type logSampler struct {
+	rate  uint64
+	count atomic.Uint64
+}
+
+// sample reports whether the next entry should be logged.
+func (l *logSampler) sample() bool {
+	if l.rate <= 1 {
+		return true
+	}
+	return (l.count.Add(1)-1)%l.rate == 0
+}
+
+// isLogSampled reports whether the request was selected for logging.
+func isLogSampled(ctx context.Context) bool {
+	sampled, ok := ctx.Value(logSampledKey{}).(bool)
+	return !ok || sampled
 }
 
 // Server is the HTTP server for immich-kiosk-scheduler.
 type Server struct {
-	router            chi.Router
-	scheduler         *scheduler.Scheduler
-	kioskURL          string
-	passthroughParams map[string]bool
-	port              int
-	logger            *slog.Logger
-	metricsUsername   string
-	metricsPassword   string
+	router           chi.Router
+	state            atomic.Pointer[snapshot]
+	reloadMu         sync.Mutex
+	reloads          reloadTracker
+	port             int
+	logger           *slog.Logger
+	metricsUsername  string
+	metricsPassword  string
+	exposeMetrics    bool
+	sampler          *logSampler
+	tracingEnabled   bool
+	compressionLevel int
+	h2c              bool
+	expvar           bool
+	// adminAddr is the address of the admin listener; empty serves the
+	// admin surface on the public port. adminRouter is its router.
+	adminAddr   string
+	adminRouter chi.Router
+	// metricsPort serves /metrics alone when set. metricsRouter is its router.
+	metricsPort   int
+	metricsRouter chi.Router
+	hooks         *hooks.Dispatcher
+	email         *email.Notifier
+	notify        *notify.Dispatcher
+	graphql       *graphql.Schema
+	// upcoming records the upcoming emails sent; nil until first loaded.
+	upcomingMu    sync.Mutex
+	upcoming      upcomingSent
+	transitionMu  sync.Mutex
+	active        hooks.Selection
+	gate          transitionGate
+	gitSync       *gitsync.Syncer
+	remote        *remote.Watcher
+	homeAssistant *homeassistant.Client
+	mqtt          *mqtt.Client
+	albums        *immich.AlbumCache
+	stats         *statsRecorder
+	devices       *deviceRegistry
+	// profile is the profile chosen through the API, which survives reloads
+	// and restarts; empty uses the configured one. Guarded by reloadMu.
+	profile string
+	// discovered holds the entries discovered from Immich album names.
+	// Guarded by reloadMu.
+	discovered []config.ScheduleEntry
+	override   atomic.Pointer[albumOverride]
+	guestLinks *redeemedLinks
+	store      *state.Store
+	version    string
+	instanceID string
+	// loopTarget is a kiosk_url found to lead back to the scheduler.
+	loopTarget atomic.Pointer[string]
+	// reportedSchedule is the schedule last set on the current_schedule gauge.
+	reportedSchedule atomic.Pointer[string]
+	scheduleMetricMu sync.Mutex
+	// inFlight counts the requests being served by the listeners.
+	inFlight atomic.Int64
+	// shadow records the comparisons with the shadow configuration.
+	shadow shadowRecorder
+	// dwell holds selections for the min_duration of their entries;
+	// dwellDirty is set when the holds changed since they were saved and
+	// dwellMu orders saving them.
+	dwell      *scheduler.Dwell
+	dwellDirty atomic.Bool
+	dwellMu    sync.Mutex
 }
 
 // New creates a new Server instance.
 func New(cfg *config.Config, sched *scheduler.Scheduler) (*Server, error) {
-	// Build passthrough params map for O(1) lookup
-	passthroughMap := make(map[string]bool)
-	for _, p := range cfg.PassthroughParams {
-		sanitized, valid := config.SanitizeParam(p)
-		if valid {
-			passthroughMap[sanitized] = true
+	s := &Server{
+		port:            cfg.Port,
+		logger:          slog.Default(),
+		metricsUsername: cfg.MetricsUsername,
+		metricsPassword: cfg.MetricsPassword,
+		exposeMetrics:   cfg.Metrics.PrometheusEnabled(),
+		sampler:         &logSampler{rate: uint64(max(cfg.AccessLog.SampleRate, 1))},
+		tracingEnabled:  cfg.Tracing.Enabled,
+		h2c:             cfg.H2C,
+		expvar:          cfg.Debug.Expvar,
+		adminAddr:       cfg.AdminListen,
+		metricsPort:     cfg.MetricsPort,
+		hooks:           hooks.NewDispatcher(slog.Default()),
+		email:           email.NewNotifier(slog.Default()),
+		notify:          notify.NewDispatcher(slog.Default()),
+		instanceID:      newInstanceID(),
+	}
+	store, err := state.Open(cfg.StateDir)
+	if err != nil {
+		return nil, err
+	}
+	s.store = store
+	s.stats = newStatsRecorder(store, s.logger)
+	s.devices = newDeviceRegistry(store, s.logger)
+	s.dwell = s.loadDwell()
+	s.guestLinks = newRedeemedLinks(store, s.logger)
+
+	var profile string
+	if len(cfg.Profiles) > 0 {
+		s.profile = s.loadProfile()
+		profile = s.profileFor(cfg)
+		// The scheduler is rebuilt from the active profile's schedule.
+		if cfg, err = cfg.WithProfile(profile); err != nil {
+			return nil, err
+		}
+		if sched, err = scheduler.New(cfg); err != nil {
+			return nil, fmt.Errorf("failed to create scheduler: %w", err)
 		}
 	}
-
-	s := &Server{
-		scheduler:         sched,
-		kioskURL:          cfg.KioskURL,
-		passthroughParams: passthroughMap,
-		port:              cfg.Port,
-		logger:            slog.Default(),
-		metricsUsername:   cfg.MetricsUsername,
-		metricsPassword:   cfg.MetricsPassword,
+	st, err := newSnapshot(cfg, sched)
+	if err != nil {
+		return nil, err
+	}
+	st.profile = profile
+	st.registry = s.devices
+	if st.kiosks, err = s.newKioskSnapshots(cfg); err != nil {
+		return nil, err
+	}
+	st.shadow = s.newShadowSnapshot(cfg)
+	s.state.Store(st)
+	updateMaintenanceMetric(cfg.Maintenance)
+	s.active = s.selectionAt(s.current(), time.Now())
+	if cfg.Compression.Enabled {
+		s.compressionLevel = cfg.Compression.Level
 	}
 
+	s.graphql = s.graphQLSchema()
 	s.setupRoutes()
+	up.Set(1)
 	return s, nil
 }
 
+// SetGitSync reports the syncer's status in the status API.
+// It must be called before the server starts.
+func (s *Server) SetGitSync(syncer *gitsync.Syncer) {
+	s.gitSync = syncer
+}
+
+// SetRemote reports the remote configuration status in the status API.
+// It must be called before the server starts.
+func (s *Server) SetRemote(watcher *remote.Watcher) {
+	s.remote = watcher
+}
+
+// SetHomeAssistant makes the client's entity states available to when
+// conditions and reports its status in the status API.
+// It must be called before the server starts.
+func (s *Server) SetHomeAssistant(client *homeassistant.Client) {
+	s.homeAssistant = client
+	for _, sched := range s.current().schedulers() {
+		sched.SetSource(rules.SourceHomeAssistant, client.States)
+	}
+}
+
+// HomeAssistantEntities returns the Home Assistant entities read by the
+// current schedules' when conditions.
+func (s *Server) HomeAssistantEntities() []string {
+	return s.current().references(rules.SourceHomeAssistant)
+}
+
+// SetMQTT makes the client's topic values available to when conditions and
+// reports its status in the status API.
+// It must be called before the server starts.
+func (s *Server) SetMQTT(client *mqtt.Client) {
+	s.mqtt = client
+	for _, sched := range s.current().schedulers() {
+		sched.SetSource(rules.SourceMQTT, client.Values)
+	}
+}
+
+// MQTTTopics returns the MQTT topics read by the current schedules' when
+// conditions.
+func (s *Server) MQTTTopics() []string {
+	return s.current().references(rules.SourceMQTT)
+}
+
+// Store returns the state store, for components that persist their state
+// alongside the server's.
+func (s *Server) Store() *state.Store {
+	return s.store
+}
+
+// SetVersion reports the version on the info page and, with the commit, in
+// the build_info metric. It must be called before the server starts.
+func (s *Server) SetVersion(version, commit string) {
+	s.version = version
+	buildInfo.Reset()
+	buildInfo.WithLabelValues(version, commit).Set(1)
+}
+
 // setupRoutes configures the HTTP routes.
+// With an admin listener, the API, UI and metrics are served there only;
+// with a metrics port, metrics are served there only.
 func (s *Server) setupRoutes() {
+	r := s.newRouter()
+
+	// Routes
+	r.Get("/", s.handleRedirect)
+	r.Get(kioskPath+"{name}", s.handleKioskRedirect)
+	r.HandleFunc("/auth/verify", s.handleForwardAuth)
+	r.Post("/chat/slack", s.handleSlack)
+	r.Post("/chat/discord", s.handleDiscord)
+
+	// API and UI responses are compressed; redirects are not.
+	r.Group(func(r chi.Router) {
+		r.Use(s.compress)
+		r.Get("/healthz", s.handleHealth)
+		r.Get("/kiosk-config.yaml", s.handleKioskConfig)
+		r.Route("/control", s.controlRoutes)
+		r.Route("/guest", s.guestRoutes)
+	})
+	s.router = r
+
+	// The admin surface moves to its own listener when one is configured.
+	admin := r
+	if s.adminAddr != "" {
+		admin = s.newRouter()
+		admin.With(s.compress).Get("/healthz", s.handleHealth)
+		s.adminRouter = admin
+	}
+	admin.Group(func(r chi.Router) {
+		r.Use(s.compress)
+		r.Route("/api/v1", s.apiRoutes)
+		r.Get("/api/openapi.json", s.handleOpenAPI)
+		r.Get("/api/docs", s.handleAPIDocs)
+		r.Get("/api/docs/{file}", s.handleAPIDocsAsset)
+		r.Route("/ui", s.uiRoutes)
+	})
+	if s.expvar {
+		admin.Get("/debug/vars", s.handleDebugVars)
+	}
+
+	// Metrics with optional basic auth (not exposed when exporting to StatsD).
+	// The metrics port skips the rate limit and access log of the other
+	// listeners, so scrapes are neither throttled nor logged.
+	metrics := admin
+	if s.metricsPort != 0 {
+		metrics = chi.NewRouter()
+		metrics.Use(middleware.Recoverer)
+		s.metricsRouter = metrics
+	}
+	if s.exposeMetrics {
+		if s.metricsUsername != "" && s.metricsPassword != "" {
+			metrics.With(s.basicAuthMiddleware).Get("/metrics", promhttp.Handler().ServeHTTP)
+		} else {
+			metrics.Get("/metrics", promhttp.Handler().ServeHTTP)
+		}
+	}
+}
+
+// newRouter creates a router with the middleware shared by all listeners.
+func (s *Server) newRouter() chi.Router {
 	r := chi.NewRouter()
 
 	// Middleware
 	r.Use(middleware.RequestID)
+	r.Use(peerMiddleware)
 	r.Use(middleware.RealIP)
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.Throttle(100)) // Rate limit: 100 concurrent requests
+	if s.tracingEnabled {
+		r.Use(tracing.Middleware)
+	}
 	r.Use(s.securityHeadersMiddleware)
 	r.Use(s.loggingMiddleware)
 
-	// Routes
-	r.Get("/", s.handleRedirect)
-	r.Get("/healthz", s.handleHealth)
+	r.NotFound(s.handleNotFound)
+	return r
+}
 
-	// Metrics with optional basic auth
-	if s.metricsUsername != "" && s.metricsPassword != "" {
-		r.With(s.basicAuthMiddleware).Get("/metrics", promhttp.Handler().ServeHTTP)
-	} else {
-		r.Get("/metrics", promhttp.Handler().ServeHTTP)
+// compress adds response compression to a group of routes, when enabled.
+func (s *Server) compress(next http.Handler) http.Handler {
+	if s.compressionLevel == 0 {
+		return next
 	}
-
-	s.router = r
+	return middleware.Compress(s.compressionLevel, compressibleTypes...)(next)
 }
 
 // basicAuthMiddleware provides HTTP Basic Authentication for protected endpoints.
 func (s *Server) basicAuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		user, pass, ok := r.BasicAuth()
-		if !ok {
-			w.Header().Set("WWW-Authenticate", `Basic realm="metrics"`)
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			return
-		}
-
-		// Constant time comparison to prevent timing attacks
-		userMatch := subtle.ConstantTimeCompare([]byte(user), []byte(s.metricsUsername)) == 1
-		passMatch := subtle.ConstantTimeCompare([]byte(pass), []byte(s.metricsPassword)) == 1
-
-		if !userMatch || !passMatch {
+		if !validBasicAuth(r, s.metricsUsername, s.metricsPassword) {
 			w.Header().Set("WWW-Authenticate", `Basic realm="metrics"`)
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
@@ -131,6 +428,20 @@ func (s *Server) basicAuthMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// validBasicAuth reports whether the request carries the given Basic
+// Authentication credentials.
+func validBasicAuth(r *http.Request, username, password string) bool {
+	user, pass, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+
+	// Constant time comparison to prevent timing attacks
+	userMatch := subtle.ConstantTimeCompare([]byte(user), []byte(username)) == 1
+	passMatch := subtle.ConstantTimeCompare([]byte(pass), []byte(password)) == 1
+	return userMatch && passMatch
+}
+
 // securityHeadersMiddleware adds security headers to responses.
 func (s *Server) securityHeadersMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -144,139 +455,529 @@ func (s *Server) securityHeadersMiddleware(next http.Handler) http.Handler {
 }
 
 // loggingMiddleware logs HTTP requests.
+// Successful redirects are sampled; errors are always logged.
 func (s *Server) loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
+		sampled := true
+		if r.URL.Path == "/" || strings.HasPrefix(r.URL.Path, kioskPath) {
+			sampled = s.sampler.sample()
+			r = r.WithContext(context.WithValue(r.Context(), logSampledKey{}, sampled))
+		}
+
 		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
 		next.ServeHTTP(ww, r)
 
-		s.logger.Info("http request",
+		if !sampled && ww.Status() < http.StatusBadRequest {
+			accessLogDropped.Inc()
+			return
+		}
+
+		attrs := []any{
 			slog.String("method", r.Method),
-			slog.String("path", r.URL.Path),
+			slog.String("path", logPath(r.URL.Path)),
 			slog.Int("status", ww.Status()),
 			slog.Duration("duration", time.Since(start)),
 			slog.String("remote", r.RemoteAddr),
-		)
+		}
+		if sc, ok := tracing.SpanFromContext(r.Context()); ok {
+			attrs = append(attrs,
+				slog.String("trace_id", sc.TraceIDString()),
+				slog.String("span_id", sc.SpanIDString()),
+			)
+		}
+		s.logger.Info("http request", attrs...)
 	})
 }
 
+// logPath returns the request path for logs, hiding guest link tokens.
+func logPath(path string) string {
+	if strings.HasPrefix(path, "/guest/") {
+		return "/guest/[redacted]"
+	}
+	return path
+}
+
 // handleRedirect redirects to the kiosk URL with the appropriate album.
 func (s *Server) handleRedirect(w http.ResponseWriter, r *http.Request) {
-	album := s.scheduler.GetCurrentAlbum()
-	scheduleName := s.scheduler.GetCurrentScheduleName()
+	s.serveRedirect(w, r, s.current())
+}
+
+// serveRedirect redirects to the kiosk URL of a snapshot, the top-level one
+// or a named kiosk's, with the appropriate album. Overrides, quiet hours and
+// maintenance mode apply to every kiosk; only the top-level one is reported
+// as the current schedule.
+func (s *Server) serveRedirect(w http.ResponseWriter, r *http.Request, st *snapshot) {
+	w.Header().Set(st.markerHeader(), s.instanceID)
+	if wantsInfo(r, st.config.InfoPage) {
+		s.serveInfo(w, r, st)
+		return
+	}
+	if st.config.Maintenance.Enabled {
+		s.serveMaintenance(w, st.config.Maintenance)
+		return
+	}
+
+	now := time.Now()
+	overridden := false
+	if st.config.Debug.AllowDateOverride {
+		date, ok, err := dateOverride(r)
+		if err != nil {
+			s.serveRedirectError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if ok {
+			now, overridden = date, true
+			s.logger.Debug("using overridden date", slog.String("date", now.Format(time.DateOnly)))
+		}
+	}
 
-	// Build redirect URL
-	redirectURL, err := s.buildRedirectURL(r, album)
+	c, err := s.choose(st, r, now, overridden)
 	if err != nil {
 		s.logger.Error("failed to build redirect URL", slog.Any("error", err))
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		s.serveRedirectError(w, http.StatusInternalServerError, "The slideshow could not be loaded. Retrying shortly.")
+		return
+	}
+	if st.shadow != nil && st.kiosk == "" {
+		s.compareShadow(st, r, now, overridden, c)
+	}
+	if c.base == nil {
+		s.serveQuietHours(w, now, c.quietUntil)
+		return
+	}
+	base, scheduleName, params := c.base, c.schedule, c.params
+
+	// Build redirect URL; the kiosk password and signature are added after
+	// it is logged.
+	redirectURL := st.withCacheBust(base.build(st, r, params), scheduleName, base.album, now)
+	if s.isLoop(st, r, &base.kiosk) {
+		s.logger.Error("refusing to redirect to the scheduler itself", slog.String("kiosk_url", st.config.KioskURL))
+		s.serveRedirectError(w, http.StatusLoopDetected, "The kiosk URL points back at this scheduler. Please fix kiosk_url in the configuration.")
 		return
 	}
 
 	// Update metrics
 	redirectsTotal.WithLabelValues(scheduleName).Inc()
-	s.updateCurrentScheduleMetric(scheduleName)
+	if !overridden && st.kiosk == "" {
+		s.updateCurrentScheduleMetric(scheduleName)
+		s.stats.redirect(scheduleName, now)
+	}
+
+	s.deviceSeen(st, r.URL.Query().Get(deviceParam), deviceActivity{
+		LastSeen:  time.Now(),
+		Album:     base.album,
+		Schedule:  scheduleName,
+		UserAgent: r.UserAgent(),
+	})
+
+	if isLogSampled(r.Context()) {
+		s.logger.Info("redirecting",
+			slog.String("schedule", scheduleName),
+			slog.String("album", base.album),
+			slog.String("redirect_url", redirectURL),
+		)
+	}
+
+	location, err := st.signed(st.withKioskAuth(redirectURL), time.Now())
+	if err != nil {
+		s.logger.Error("failed to sign redirect URL", slog.Any("error", err))
+		s.serveRedirectError(w, http.StatusInternalServerError, "The slideshow could not be loaded. Retrying shortly.")
+		return
+	}
+	http.Redirect(w, r, location, http.StatusFound)
+}
+
+// redirectChoice is what a snapshot serves a request.
+type redirectChoice struct {
+	base     *redirectBase
+	schedule string
+	// params are added to the redirect, such as override and night params.
+	params map[string]string
+	// quietUntil is the end of quiet hours showing a blank page instead of
+	// an album; base is nil then.
+	quietUntil time.Time
+}
 
-	s.logger.Info("redirecting",
-		slog.String("schedule", scheduleName),
-		slog.String("album", album),
-		slog.String("redirect_url", redirectURL),
+// choose decides what st serves a request at now: the scheduled album, an
+// active override or the quiet hours album. Overrides (control page, party
+// modes) and quiet hours apply to the present, not to debug dates.
+func (s *Server) choose(st *snapshot, r *http.Request, now time.Time, overridden bool) (redirectChoice, error) {
+	var (
+		c   redirectChoice
+		err error
 	)
+	switch {
+	case st.dwells && !overridden:
+		device := ""
+		if st.perDevice() {
+			device = r.URL.Query().Get(deviceParam)
+		}
+		c.base, err = st.decisionBase(now, s.held(st, device, st.decide(now, r), now))
+	case st.perDevice():
+		c.base, err = st.requestBase(now, r)
+	case overridden:
+		c.base, err = st.scheduledBaseFor(now)
+	default:
+		c.base, err = st.scheduledBase(now)
+	}
+	if err != nil {
+		return c, err
+	}
+	c.schedule = c.base.schedule
+	if o := s.activeOverride(now); o != nil && !overridden {
+		c.params, c.schedule = o.Params, o.schedule()
+		if o.Album != "" {
+			// The entry's params belong to its album, not the override's,
+			// except for the entry a freeze pinned.
+			if c.base, err = st.newRedirectBase(o.Album, o.entry); err != nil {
+				return c, err
+			}
+		}
+	} else if end, quiet := st.config.QuietHours.Window(now); quiet && !overridden {
+		album := st.config.QuietHours.NightAlbum()
+		if album == "" {
+			return redirectChoice{schedule: quietHoursSchedule, quietUntil: end}, nil
+		}
+		c.schedule = quietHoursSchedule
+		if c.base, err = st.newRedirectBase(album, nil); err != nil {
+			return c, err
+		}
+	}
+	if st.config.Transitions.Enabled() && !overridden && st.kiosk == "" && !st.candidate {
+		if c, err = s.gated(st, now, c); err != nil || c.base == nil {
+			return c, err
+		}
+	}
+	if night := st.config.NightParamsAt(now); night != nil && !overridden {
+		// Override params win over night params.
+		maps.Copy(night, c.params)
+		c.params = night
+	}
+	return c, nil
+}
 
-	http.Redirect(w, r, redirectURL, http.StatusFound)
+// signed signs a redirect URL when signing is enabled.
+func (st *snapshot) signed(redirectURL string, now time.Time) (string, error) {
+	signing := st.config.Signing
+	if !signing.Enabled() {
+		return redirectURL, nil
+	}
+	return redirectsig.Sign(signing.Secret, redirectURL, signing.TimestampParamName(), signing.SignatureParam(), now)
 }
 
-// buildRedirectURL constructs the redirect URL with album and passthrough params.
-func (s *Server) buildRedirectURL(r *http.Request, album string) (string, error) {
-	u, err := url.Parse(s.kioskURL)
+// withKioskAuth adds the kiosk password to a redirect URL. It is added only
+// to the Location of redirects, never to URLs that are logged or shown.
+func (st *snapshot) withKioskAuth(redirectURL string) string {
+	param := st.config.KioskAuth.QueryParam()
+	if param == "" {
+		return redirectURL
+	}
+	return appendParam(redirectURL, param, st.config.KioskAuth.Password)
+}
+
+// withCacheBust adds the cache_bust parameter to a redirect URL: the Unix
+// time of now, or a revision of the configuration, schedule and album, which
+// changes only when one of them does.
+func (st *snapshot) withCacheBust(redirectURL, schedule, album string, now time.Time) string {
+	param := st.config.CacheBust.QueryParam()
+	if param == "" {
+		return redirectURL
+	}
+	value := strconv.FormatInt(now.Unix(), 10)
+	if st.config.CacheBust.Mode == config.CacheBustRevision {
+		sum := sha256.Sum256([]byte(st.revision + "\x00" + schedule + "\x00" + album))
+		value = hex.EncodeToString(sum[:6])
+	}
+	return appendParam(redirectURL, param, value)
+}
+
+// appendParam adds a query parameter to a URL without re-encoding the
+// existing query, keeping any fragment last.
+func appendParam(rawURL, param, value string) string {
+	u, fragment, hasFragment := strings.Cut(rawURL, "#")
+	sep := "?"
+	if strings.Contains(u, "?") {
+		sep = "&"
+	}
+	u += sep + url.QueryEscape(param) + "=" + url.QueryEscape(value)
+	if hasFragment {
+		u += "#" + fragment
+	}
+	return u
+}
+
+// deviceProfile returns the device profile of the requesting device, or nil
+// when it has none. Profiles listing the device win over the profile it was
+// registered with.
+func (st *snapshot) deviceProfile(q url.Values) *deviceProfile {
+	if len(st.profiles) == 0 {
+		return nil
+	}
+	id := q.Get(deviceParam)
+	if profile, ok := st.devices[id]; ok {
+		return profile
+	}
+	if st.registry == nil || id == "" {
+		return nil
+	}
+	return st.profiles[st.registry.profile(id)]
+}
+
+// entryAt returns the schedule entry selected for t, or nil when the
+// default album applies.
+func (st *snapshot) entryAt(t time.Time) *config.ScheduleEntry {
+	return st.backend.Resolve(t).Entry
+}
+
+// buildRedirectURL constructs the redirect URL with album, default and
+// passthrough params. Passthrough params override default params, and the
+// params of the selected schedule entry override both; extra params, e.g.
+// from a party mode, take precedence over all of them.
+func (st *snapshot) buildRedirectURL(r *http.Request, album string, entry *config.ScheduleEntry, extra map[string]string) (string, error) {
+	base, err := st.newRedirectBase(album, entry)
 	if err != nil {
-		return "", fmt.Errorf("invalid kiosk URL: %w", err)
+		return "", err
 	}
+	return base.build(st, r, extra), nil
+}
 
-	q := u.Query()
-	q.Set("album", album)
+// dateOverrideHeader and dateOverrideParam select the date used by the
+// redirect endpoint when debug.allow_date_override is enabled.
+const (
+	dateOverrideHeader = "X-IKS-Date"
+	dateOverrideParam  = "_date"
+)
 
-	// Add passthrough params from the original request
-	for param := range s.passthroughParams {
-		if value := r.URL.Query().Get(param); value != "" {
-			// URL encoding happens automatically when we call q.Encode()
-			q.Set(param, value)
-		}
+// dateOverride returns the date requested via the X-IKS-Date header or the
+// _date query parameter, in YYYY-MM-DD or RFC 3339 format. The header takes
+// precedence.
+func dateOverride(r *http.Request) (time.Time, bool, error) {
+	value := r.Header.Get(dateOverrideHeader)
+	if value == "" {
+		value = r.URL.Query().Get(dateOverrideParam)
+	}
+	if value == "" {
+		return time.Time{}, false, nil
 	}
 
-	u.RawQuery = q.Encode()
-	return u.String(), nil
+	if t, err := time.ParseInLocation(time.DateOnly, value, time.Local); err == nil {
+		return t, true, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, true, nil
+	}
+	return time.Time{}, false, fmt.Errorf("invalid date override %q: expected YYYY-MM-DD or RFC 3339", value)
 }
 
 // updateCurrentScheduleMetric updates the current_schedule gauge.
+// It only touches the gauge when the schedule differs from the last one
+// reported, since it is called on every redirect.
 func (s *Server) updateCurrentScheduleMetric(active string) {
+	if reported := s.reportedSchedule.Load(); reported != nil && *reported == active {
+		return
+	}
+	s.scheduleMetricMu.Lock()
+	defer s.scheduleMetricMu.Unlock()
 	// Reset all to 0
 	currentSchedule.Reset()
 	// Set active to 1
 	currentSchedule.WithLabelValues(active).Set(1)
+	s.reportedSchedule.Store(&active)
 }
 
-// handleHealth returns a simple health check response.
-func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
-	response := map[string]any{
-		"status":   "ok",
-		"schedule": s.scheduler.GetCurrentScheduleName(),
-		"album":    s.scheduler.GetCurrentAlbum(),
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	_ = json.NewEncoder(w).Encode(response)
-}
-
-// Start begins listening for HTTP requests.
-func (s *Server) Start() error {
-	addr := fmt.Sprintf(":%d", s.port)
+// newHTTPServer creates the HTTP server listening on addr. With h2c it
+// also accepts HTTP/2 without TLS, from clients with prior knowledge.
+func (s *Server) newHTTPServer(addr string, handler http.Handler) *http.Server {
 	srv := &http.Server{
 		Addr:              addr,
-		Handler:           s.router,
+		Handler:           handler,
 		ReadTimeout:       5 * time.Second,
 		ReadHeaderTimeout: 2 * time.Second,
 		WriteTimeout:      10 * time.Second,
 		IdleTimeout:       120 * time.Second,
 	}
-	s.logger.Info("starting server", slog.String("addr", addr))
-	return srv.ListenAndServe()
+	if s.h2c {
+		var protocols http.Protocols
+		protocols.SetHTTP1(true)
+		protocols.SetUnencryptedHTTP2(true)
+		srv.Protocols = &protocols
+	}
+	return srv
 }
 
-// StartWithContext begins listening for HTTP requests with graceful shutdown support.
-func (s *Server) StartWithContext(ctx context.Context) error {
-	addr := fmt.Sprintf(":%d", s.port)
-	srv := &http.Server{
-		Addr:              addr,
-		Handler:           s.router,
-		ReadTimeout:       5 * time.Second,
-		ReadHeaderTimeout: 2 * time.Second,
-		WriteTimeout:      10 * time.Second,
-		IdleTimeout:       120 * time.Second,
+// httpServers creates the HTTP servers of the public and, if configured,
+// admin and metrics listeners.
+func (s *Server) httpServers() []*http.Server {
+	servers := []*http.Server{s.newHTTPServer(fmt.Sprintf(":%d", s.port), s.router)}
+	if s.adminRouter != nil {
+		servers = append(servers, s.newHTTPServer(s.adminAddr, s.adminRouter))
+	}
+	if s.metricsRouter != nil {
+		servers = append(servers, s.newHTTPServer(fmt.Sprintf(":%d", s.metricsPort), s.metricsRouter))
+	}
+	return servers
+}
+
+// listenerName names the listener serving handler, for logging.
+func (s *Server) listenerName(handler http.Handler) string {
+	switch {
+	case s.adminRouter != nil && handler == s.adminRouter:
+		return "admin"
+	case s.metricsRouter != nil && handler == s.metricsRouter:
+		return "metrics"
+	default:
+		return "public"
 	}
+}
 
-	// Start server in goroutine
-	errCh := make(chan error, 1)
+// listen serves srv in the background, sending any error but a shutdown
+// to errCh. Its requests are counted while in flight.
+func (s *Server) listen(srv *http.Server, errCh chan<- error) {
+	s.logger.Info("starting server", slog.String("addr", srv.Addr), slog.String("listener", s.listenerName(srv.Handler)), slog.Bool("h2c", s.h2c))
+	srv.Handler = s.countInFlight(srv.Handler)
 	go func() {
-		s.logger.Info("starting server", slog.String("addr", addr))
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			errCh <- err
 		}
 	}()
+}
+
+// Start begins listening for HTTP requests.
+func (s *Server) Start() error {
+	servers := s.httpServers()
+	errCh := make(chan error, len(servers))
+	for _, srv := range servers {
+		s.listen(srv, errCh)
+	}
+	return <-errCh
+}
+
+// StartWithContext begins listening for HTTP requests with graceful shutdown support.
+func (s *Server) StartWithContext(ctx context.Context) error {
+	servers := s.httpServers()
+
+	go s.runTransitions(ctx)
+	go s.runStats(ctx)
+	go s.runDwell(ctx)
+	go s.runDevices(ctx)
+	go s.probeKioskURLAfter(ctx, kioskProbeDelay)
+
+	errCh := make(chan error, len(servers))
+	for _, srv := range servers {
+		s.listen(srv, errCh)
+	}
 
 	// Wait for context cancellation or error
 	select {
 	case <-ctx.Done():
-		s.logger.Info("shutting down server")
-		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-		return srv.Shutdown(shutdownCtx)
+		return s.shutdown(servers)
 	case err := <-errCh:
 		return err
 	}
 }
 
+// countInFlight counts the requests next is serving, so shutdown can tell
+// how many it drops.
+func (s *Server) countInFlight(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.inFlight.Add(1)
+		defer s.inFlight.Add(-1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// shutdown stops the listeners and drains the server within the configured
+// shutdown timeout: in-flight requests may finish, then statistics are
+// flushed and pending hooks, emails and notifications sent. Requests still
+// running when the drain timeout expires are dropped.
+func (s *Server) shutdown(servers []*http.Server) error {
+	up.Set(0)
+	cfg := s.current().config.Shutdown
+	timeout := cfg.TotalTimeout()
+	s.logger.Info("shutting down server", slog.Duration("timeout", timeout), slog.Int64("in_flight", s.inFlight.Load()))
+	start := time.Now()
+	// Requests get the drain timeout; deliveries get the rest of the
+	// timeout, so slow requests cannot leave them no time at all.
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), cfg.DrainTimeout())
+	defer cancelDrain()
+	ctx, cancel := context.WithDeadline(context.Background(), start.Add(timeout))
+	defer cancel()
+
+	dropped, err := s.drain(drainCtx, servers)
+	s.flushStats(time.Now())
+	s.saveDwell()
+	flushed := waitContext(ctx, s.hooks.Wait, s.email.Wait, s.notify.Wait)
+
+	attrs := []any{
+		slog.Duration("duration", time.Since(start)),
+		slog.Int64("dropped_requests", dropped),
+		slog.Bool("deliveries_flushed", flushed),
+	}
+	if dropped > 0 || !flushed {
+		s.logger.Warn("server stopped before draining completely", attrs...)
+	} else {
+		s.logger.Info("server stopped", attrs...)
+	}
+	return err
+}
+
+// drain stops the listeners from accepting connections and waits for their
+// in-flight requests until ctx is done. It then closes the connections left
+// and returns the number of requests they were serving.
+func (s *Server) drain(ctx context.Context, servers []*http.Server) (int64, error) {
+	errs := make([]error, len(servers))
+	var wg sync.WaitGroup
+	for i, srv := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = srv.Shutdown(ctx)
+		}()
+	}
+	wg.Wait()
+
+	dropped := s.inFlight.Load()
+	var err error
+	for i, srv := range servers {
+		if errors.Is(errs[i], context.DeadlineExceeded) {
+			// Reported as dropped requests instead.
+			_ = srv.Close()
+			continue
+		}
+		err = errors.Join(err, errs[i])
+	}
+	return dropped, err
+}
+
+// waitContext calls the wait functions in turn and reports whether they
+// all returned before ctx was done.
+func waitContext(ctx context.Context, waits ...func()) bool {
+	done := make(chan struct{})
+	go func() {
+		for _, wait := range waits {
+			wait()
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		select {
+		case <-done:
+			return true
+		default:
+			return false
+		}
+	}
+}
+
+// Handler returns the handler serving all routes of the public listener,
+// for hosting the server in another runtime such as a serverless function.
+func (s *Server) Handler() http.Handler {
+	return s.router
+}
+
 // Router returns the chi router for testing.
 func (s *Server) Router() chi.Router {
 	return s.router
@@ -1,11 +1,21 @@
 package server
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"log/slog"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/homeassistant"
 	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/scheduler"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -66,6 +76,283 @@ func TestServer_RedirectWithPassthroughParams(t *testing.T) {
 	assert.Contains(t, location, "duration=30")
 }
 
+func TestServer_RedirectPathTemplate(t *testing.T) {
+	cfg := &config.Config{
+		KioskURL:          "https://kiosk.example.com/album/{album}?theme=dark",
+		DefaultAlbum:      "default album/id",
+		Port:              8080,
+		PassthroughParams: []string{"transition"},
+		Schedule:          []config.ScheduleEntry{},
+	}
+
+	srv := newTestServer(t, cfg)
+
+	req := httptest.NewRequest(http.MethodGet, "/?transition=fade", nil)
+	rec := httptest.NewRecorder()
+
+	srv.router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusFound, rec.Code)
+	assert.Equal(t, "https://kiosk.example.com/album/default%20album%2Fid?theme=dark&transition=fade", rec.Header().Get("Location"))
+}
+
+func TestServer_RedirectAlbumAlias(t *testing.T) {
+	cfg := &config.Config{
+		KioskURL:     "https://kiosk.example.com/album/{album}",
+		DefaultAlbum: "Family",
+		Port:         8080,
+		Albums:       map[string]string{"family": "0a1b2c3d-4e5f-4a6b-8c7d-9e0f1a2b3c4d"},
+	}
+
+	srv := newTestServer(t, cfg)
+
+	assert.Equal(t, "https://kiosk.example.com/album/0a1b2c3d-4e5f-4a6b-8c7d-9e0f1a2b3c4d", redirectTarget(t, srv))
+}
+
+func TestServer_RedirectDefaultParams(t *testing.T) {
+	cfg := &config.Config{
+		KioskURL:          "https://kiosk.example.com?show_time=true",
+		DefaultAlbum:      "default-album-id",
+		Port:              8080,
+		PassthroughParams: []string{"transition"},
+		DefaultParams:     map[string]string{"transition": "cross-fade", "duration": "30"},
+		Schedule:          []config.ScheduleEntry{},
+	}
+
+	srv := newTestServer(t, cfg)
+
+	tests := []struct {
+		name     string
+		query    string
+		expected string
+	}{
+		{
+			name:     "defaults applied",
+			query:    "",
+			expected: "https://kiosk.example.com?album=default-album-id&duration=30&show_time=true&transition=cross-fade",
+		},
+		{
+			name:     "passthrough overrides default",
+			query:    "?transition=none",
+			expected: "https://kiosk.example.com?album=default-album-id&duration=30&show_time=true&transition=none",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/"+tt.query, nil)
+			rec := httptest.NewRecorder()
+
+			srv.router.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusFound, rec.Code)
+			assert.Equal(t, tt.expected, rec.Header().Get("Location"))
+		})
+	}
+}
+
+func TestServer_RedirectWhenConditions(t *testing.T) {
+	cfg := &config.Config{
+		KioskURL:          "https://kiosk.example.com",
+		DefaultAlbum:      "default-album-id",
+		Port:              8080,
+		PassthroughParams: []string{"transition"},
+		Debug:             config.DebugConfig{AllowDateOverride: true},
+		Schedule: []config.ScheduleEntry{
+			{Name: "kitchen-weekend", Album: "kitchen-album", Start: "01-01", End: "12-31",
+				When: `device == "kitchen" && weekday in ["Saturday", "Sunday"]`},
+			{Name: "guests", Album: "guest-album", Start: "01-01", End: "12-31", When: `query.mode == "guest"`},
+		},
+	}
+
+	srv := newTestServer(t, cfg)
+
+	tests := []struct {
+		name     string
+		query    string
+		expected string
+	}{
+		{
+			name:     "device on a weekend",
+			query:    "?_date=2024-12-21&device=kitchen&transition=fade",
+			expected: "https://kiosk.example.com?album=kitchen-album&transition=fade",
+		},
+		{
+			name:     "device on a weekday",
+			query:    "?_date=2024-12-23&device=kitchen",
+			expected: "https://kiosk.example.com?album=default-album-id",
+		},
+		{
+			name:     "query parameter",
+			query:    "?_date=2024-12-23&mode=guest",
+			expected: "https://kiosk.example.com?album=guest-album",
+		},
+		{
+			name:     "no condition holds",
+			query:    "?_date=2024-12-21&device=hallway",
+			expected: "https://kiosk.example.com?album=default-album-id",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/"+tt.query, nil)
+			rec := httptest.NewRecorder()
+
+			srv.router.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusFound, rec.Code)
+			assert.Equal(t, tt.expected, rec.Header().Get("Location"))
+		})
+	}
+}
+
+func TestServer_RedirectHomeAssistantPresence(t *testing.T) {
+	ha := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `{"state": "home"}`)
+	}))
+	defer ha.Close()
+
+	cfg := newAPITestConfig()
+	cfg.HomeAssistant = config.HomeAssistantConfig{URL: ha.URL, Token: "long-lived-access-token"}
+	cfg.Schedule = append([]config.ScheduleEntry{
+		{Name: "family", Album: "family-album", Start: "01-01", End: "12-31", When: `ha["person.alex"] == "home"`},
+	}, cfg.Schedule...)
+	srv := newTestServer(t, cfg)
+	assert.Equal(t, []string{"person.alex"}, srv.HomeAssistantEntities())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := homeassistant.New(cfg.HomeAssistant, srv.HomeAssistantEntities, slog.Default())
+	srv.SetHomeAssistant(client)
+	go client.Run(ctx)
+
+	assert.Eventually(t, func() bool {
+		return strings.Contains(redirectTarget(t, srv), "album=family-album")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The source survives a reload.
+	require.NoError(t, srv.Reload(func() (*config.Config, error) { return cfg.Clone(), nil }))
+	assert.Contains(t, redirectTarget(t, srv), "album=family-album")
+}
+
+func TestServer_RedirectEntryParams(t *testing.T) {
+	cfg := &config.Config{
+		KioskURL:          "https://kiosk.example.com",
+		DefaultAlbum:      "default-album-id",
+		Port:              8080,
+		PassthroughParams: []string{"transition", "duration"},
+		DefaultParams:     map[string]string{"image_effect": "zoom", "duration": "30"},
+		Debug:             config.DebugConfig{AllowDateOverride: true},
+		Schedule: []config.ScheduleEntry{
+			{
+				Name: "christmas", Album: "christmas-album", Start: "11-15", End: "01-01",
+				Params:       map[string]string{"image_effect": "none", "transition": "fade"},
+				RemoveParams: []string{"duration"},
+			},
+		},
+	}
+
+	srv := newTestServer(t, cfg)
+
+	tests := []struct {
+		name     string
+		query    string
+		expected string
+	}{
+		{
+			name:     "entry overrides and removes params",
+			query:    "?_date=2024-12-25&transition=none&duration=10",
+			expected: "https://kiosk.example.com?album=christmas-album&image_effect=none&transition=fade",
+		},
+		{
+			name:     "default album keeps defaults",
+			query:    "?_date=2024-07-01&duration=10",
+			expected: "https://kiosk.example.com?album=default-album-id&duration=10&image_effect=zoom",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/"+tt.query, nil)
+			rec := httptest.NewRecorder()
+
+			srv.router.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusFound, rec.Code)
+			assert.Equal(t, tt.expected, rec.Header().Get("Location"))
+		})
+	}
+}
+
+func TestServer_RedirectParamMap(t *testing.T) {
+	cfg := &config.Config{
+		KioskURL:          "https://kiosk.example.com",
+		DefaultAlbum:      "default-album-id",
+		Port:              8080,
+		PassthroughParams: []string{"duration"},
+		ParamMap:          map[string]string{"t": "transition", "d": "duration"},
+		Schedule:          []config.ScheduleEntry{},
+	}
+
+	srv := newTestServer(t, cfg)
+
+	tests := []struct {
+		name     string
+		query    string
+		expected string
+	}{
+		{
+			name:     "renamed",
+			query:    "?t=fade",
+			expected: "https://kiosk.example.com?album=default-album-id&transition=fade",
+		},
+		{
+			name:     "kiosk name wins",
+			query:    "?d=5&duration=10",
+			expected: "https://kiosk.example.com?album=default-album-id&duration=10",
+		},
+		{
+			name:     "original name not forwarded",
+			query:    "?transition=fade",
+			expected: "https://kiosk.example.com?album=default-album-id",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/"+tt.query, nil)
+			rec := httptest.NewRecorder()
+
+			srv.router.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusFound, rec.Code)
+			assert.Equal(t, tt.expected, rec.Header().Get("Location"))
+		})
+	}
+}
+
+func TestServer_RedirectAllExceptPassthrough(t *testing.T) {
+	cfg := &config.Config{
+		KioskURL:        "https://kiosk.example.com",
+		DefaultAlbum:    "default-album-id",
+		Port:            8080,
+		PassthroughMode: config.PassthroughAllExcept,
+		BlockedParams:   []string{"Password"},
+		Schedule:        []config.ScheduleEntry{},
+	}
+
+	srv := newTestServer(t, cfg)
+
+	req := httptest.NewRequest(http.MethodGet, "/?transition=fade&new_kiosk_option=1&password=x&album=evil&_date=2024-12-25&bad%20name=1", nil)
+	rec := httptest.NewRecorder()
+
+	srv.router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusFound, rec.Code)
+	assert.Equal(t, "https://kiosk.example.com?album=default-album-id&new_kiosk_option=1&transition=fade", rec.Header().Get("Location"))
+}
+
 func TestServer_RedirectFiltersUnallowedParams(t *testing.T) {
 	cfg := &config.Config{
 		KioskURL:          "https://kiosk.example.com",
@@ -155,6 +442,26 @@ func TestServer_Metrics(t *testing.T) {
 	assert.Contains(t, rec.Body.String(), "# HELP")
 }
 
+func TestServer_MetricsDisabledForStatsD(t *testing.T) {
+	cfg := &config.Config{
+		KioskURL:          "https://kiosk.example.com",
+		DefaultAlbum:      "default-album-id",
+		Port:              8080,
+		PassthroughParams: []string{},
+		Schedule:          []config.ScheduleEntry{},
+		Metrics:           config.MetricsConfig{Backend: "statsd"},
+	}
+
+	srv := newTestServer(t, cfg)
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	rec := httptest.NewRecorder()
+
+	srv.router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
 func TestServer_RedirectIncrementsMetrics(t *testing.T) {
 	cfg := &config.Config{
 		KioskURL:          "https://kiosk.example.com",
@@ -179,6 +486,145 @@ func TestServer_RedirectIncrementsMetrics(t *testing.T) {
 	assert.Contains(t, rec.Body.String(), "immich_kiosk_scheduler_redirects_total")
 }
 
+func TestServer_AccessLogSampling(t *testing.T) {
+	cfg := &config.Config{
+		KioskURL:          "https://kiosk.example.com",
+		DefaultAlbum:      "default-album-id",
+		Port:              8080,
+		PassthroughParams: []string{},
+		Schedule:          []config.ScheduleEntry{},
+		AccessLog:         config.AccessLogConfig{SampleRate: 3},
+	}
+
+	srv := newTestServer(t, cfg)
+	var buf bytes.Buffer
+	srv.logger = slog.New(slog.NewJSONHandler(&buf, nil))
+
+	dropped := testutil.ToFloat64(accessLogDropped)
+	for i := 0; i < 6; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		srv.router.ServeHTTP(rec, req)
+		require.Equal(t, http.StatusFound, rec.Code)
+	}
+
+	// 1 in 3 redirects is logged (one "redirecting" and one "http request" entry each)
+	assert.Equal(t, 2, strings.Count(buf.String(), `"msg":"http request"`))
+	assert.Equal(t, 2, strings.Count(buf.String(), `"msg":"redirecting"`))
+	assert.Equal(t, dropped+4, testutil.ToFloat64(accessLogDropped))
+
+	// Non-redirect requests are not sampled
+	buf.Reset()
+	req := httptest.NewRequest(http.MethodGet, "/nonexistent", nil)
+	srv.router.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Contains(t, buf.String(), `"status":404`)
+}
+
+func TestServer_TracingLogsTraceID(t *testing.T) {
+	cfg := &config.Config{
+		KioskURL:          "https://kiosk.example.com",
+		DefaultAlbum:      "default-album-id",
+		Port:              8080,
+		PassthroughParams: []string{},
+		Schedule:          []config.ScheduleEntry{},
+		Tracing:           config.TracingConfig{Enabled: true},
+	}
+
+	srv := newTestServer(t, cfg)
+	var buf bytes.Buffer
+	srv.logger = slog.New(slog.NewJSONHandler(&buf, nil))
+
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	srv.router.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Contains(t, buf.String(), `"trace_id":"4bf92f3577b34da6a3ce929d0e0e4736"`)
+}
+
+func TestServer_Compression(t *testing.T) {
+	cfg := &config.Config{
+		KioskURL:          "https://kiosk.example.com",
+		DefaultAlbum:      "default-album-id",
+		Port:              8080,
+		PassthroughParams: []string{},
+		Schedule:          []config.ScheduleEntry{},
+		Compression:       config.CompressionConfig{Enabled: true, Level: 5},
+	}
+
+	srv := newTestServer(t, cfg)
+
+	// JSON responses are compressed when the client accepts gzip
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, req)
+
+	assert.Equal(t, "gzip", rec.Header().Get("Content-Encoding"))
+	gz, err := gzip.NewReader(rec.Body)
+	require.NoError(t, err)
+	body, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "ok")
+
+	// Redirects are never compressed
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec = httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusFound, rec.Code)
+	assert.Empty(t, rec.Header().Get("Content-Encoding"))
+}
+
+func TestServer_DateOverride(t *testing.T) {
+	newConfig := func(allow bool) *config.Config {
+		return &config.Config{
+			KioskURL:          "https://kiosk.example.com",
+			DefaultAlbum:      "default-album-id",
+			Port:              8080,
+			PassthroughParams: []string{},
+			Schedule: []config.ScheduleEntry{
+				{Name: "christmas", Album: "christmas-album", Start: "12-24", End: "12-26"},
+			},
+			Debug: config.DebugConfig{AllowDateOverride: allow},
+		}
+	}
+
+	tests := []struct {
+		name         string
+		allow        bool
+		target       string
+		header       string
+		wantCode     int
+		wantLocation string
+	}{
+		{"header", true, "/", "2030-12-25", http.StatusFound, "https://kiosk.example.com?album=christmas-album"},
+		{"query param", true, "/?_date=2030-12-25", "", http.StatusFound, "https://kiosk.example.com?album=christmas-album"},
+		{"rfc3339", true, "/", "2030-12-25T08:00:00Z", http.StatusFound, "https://kiosk.example.com?album=christmas-album"},
+		{"header wins", true, "/?_date=2030-12-25", "2030-06-01", http.StatusFound, "https://kiosk.example.com?album=default-album-id"},
+		{"invalid", true, "/?_date=tomorrow", "", http.StatusBadRequest, ""},
+		{"disabled", false, "/?_date=tomorrow", "2030-12-25", http.StatusFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestServer(t, newConfig(tt.allow))
+
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.header != "" {
+				req.Header.Set("X-IKS-Date", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			srv.router.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantLocation != "" {
+				assert.Equal(t, tt.wantLocation, rec.Header().Get("Location"))
+			}
+		})
+	}
+}
+
 func TestServer_NotFound(t *testing.T) {
 	cfg := &config.Config{
 		KioskURL:          "https://kiosk.example.com",
@@ -197,3 +643,131 @@ func TestServer_NotFound(t *testing.T) {
 
 	assert.Equal(t, http.StatusNotFound, rec.Code)
 }
+
+func TestServer_H2C(t *testing.T) {
+	// h2cGet requests /healthz over HTTP/2 without TLS from a server with
+	// or without h2c.
+	h2cGet := func(t *testing.T, h2c bool) (*http.Response, error) {
+		cfg := newAPITestConfig()
+		cfg.H2C = h2c
+		srv := newTestServer(t, cfg)
+		hs := srv.newHTTPServer("", srv.router)
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		go func() { _ = hs.Serve(ln) }()
+		t.Cleanup(func() { _ = hs.Close() })
+
+		var protocols http.Protocols
+		protocols.SetUnencryptedHTTP2(true)
+		client := &http.Client{Transport: &http.Transport{Protocols: &protocols}, Timeout: 5 * time.Second}
+		return client.Get("http://" + ln.Addr().String() + "/healthz")
+	}
+
+	resp, err := h2cGet(t, true)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 2, resp.ProtoMajor)
+
+	_, err = h2cGet(t, false)
+	assert.Error(t, err)
+}
+
+func TestServer_Drain(t *testing.T) {
+	// drain serves one request that takes hold to finish and drains the
+	// server with a timeout of 100ms.
+	drain := func(t *testing.T, hold time.Duration) (int64, error) {
+		srv := newTestServer(t, newAPITestConfig())
+		started := make(chan struct{})
+		hs := srv.newHTTPServer("", srv.countInFlight(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			time.Sleep(hold)
+		})))
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		go func() { _ = hs.Serve(ln) }()
+		go func() {
+			if resp, err := http.Get("http://" + ln.Addr().String() + "/"); err == nil {
+				resp.Body.Close()
+			}
+		}()
+		<-started
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		return srv.drain(ctx, []*http.Server{hs})
+	}
+
+	dropped, err := drain(t, 10*time.Millisecond)
+	require.NoError(t, err)
+	assert.Zero(t, dropped)
+
+	dropped, err = drain(t, time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), dropped)
+}
+
+func TestServer_AdminListener(t *testing.T) {
+	cfg := newAPITestConfig()
+	cfg.AdminListen = "127.0.0.1:8081"
+	cfg.Metrics.Backend = "prometheus"
+	srv := newTestServer(t, cfg)
+	require.NotNil(t, srv.adminRouter)
+
+	get := func(h http.Handler, target string) int {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec.Code
+	}
+
+	// The public listener keeps the redirect and household pages.
+	assert.Equal(t, http.StatusFound, get(srv.router, "/"))
+	assert.Equal(t, http.StatusOK, get(srv.router, "/healthz"))
+	for _, target := range []string{"/api/v1/status", "/ui/", "/metrics"} {
+		assert.Equal(t, http.StatusNotFound, get(srv.router, target), target)
+		assert.Equal(t, http.StatusOK, get(srv.adminRouter, target), target)
+	}
+
+	assert.Equal(t, http.StatusOK, get(srv.adminRouter, "/healthz"))
+	assert.Equal(t, http.StatusNotFound, get(srv.adminRouter, "/"))
+	assert.Len(t, srv.httpServers(), 2)
+}
+
+func TestServer_MetricsPort(t *testing.T) {
+	cfg := newAPITestConfig()
+	cfg.AdminListen = "127.0.0.1:8081"
+	cfg.MetricsPort = 9090
+	cfg.Metrics.Backend = "prometheus"
+	srv := newTestServer(t, cfg)
+	require.NotNil(t, srv.metricsRouter)
+
+	get := func(h http.Handler, target string) int {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, get(srv.metricsRouter, "/metrics"))
+	assert.Equal(t, http.StatusNotFound, get(srv.adminRouter, "/metrics"))
+	assert.Equal(t, http.StatusNotFound, get(srv.router, "/metrics"))
+	assert.Equal(t, http.StatusNotFound, get(srv.metricsRouter, "/"))
+	assert.Equal(t, http.StatusOK, get(srv.adminRouter, "/api/v1/status"))
+
+	servers := srv.httpServers()
+	require.Len(t, servers, 3)
+	assert.Equal(t, ":9090", servers[2].Addr)
+	assert.Equal(t, "metrics", srv.listenerName(servers[2].Handler))
+}
+
+func TestServer_ProcessMetrics(t *testing.T) {
+	srv := newTestServer(t, newAPITestConfig())
+	srv.SetVersion("1.2.3", "abc1234")
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(buildInfo.WithLabelValues("1.2.3", "abc1234")))
+	assert.Equal(t, 1, testutil.CollectAndCount(buildInfo))
+	assert.Equal(t, 1.0, testutil.ToFloat64(up))
+	assert.InDelta(t, float64(time.Now().Unix()), testutil.ToFloat64(startTime), 600)
+
+	require.NoError(t, srv.shutdown(nil))
+	assert.Equal(t, 0.0, testutil.ToFloat64(up))
+}
@@ -0,0 +1,214 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/scheduler"
+)
+
+// Shadow evaluation metrics
+var shadowComparisonsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "immich_kiosk_scheduler_shadow_comparisons_total",
+		Help: "Total number of redirects compared with the shadow configuration by result",
+	},
+	[]string{"result"},
+)
+
+func init() {
+	prometheus.MustRegister(shadowComparisonsTotal)
+}
+
+// Shadow comparison results.
+const (
+	shadowMatch    = "match"
+	shadowMismatch = "mismatch"
+	shadowError    = "error"
+)
+
+// shadowMaxMismatches bounds the mismatches kept for the shadow API.
+const shadowMaxMismatches = 50
+
+// newShadowSnapshot loads the candidate configuration named by
+// shadow.config and derives its serving state, with the profile chosen at
+// runtime if the candidate has it. An invalid candidate turns shadow
+// evaluation off instead of failing the active configuration.
+func (s *Server) newShadowSnapshot(cfg *config.Config) *snapshot {
+	path := cfg.Shadow.Config
+	if path == "" {
+		return nil
+	}
+	st, err := s.loadShadow(path)
+	if err != nil {
+		s.logger.Error("failed to load shadow configuration, shadow evaluation is off",
+			slog.String("file", path),
+			slog.Any("error", err),
+		)
+		return nil
+	}
+	s.logger.Info("shadow evaluation enabled", slog.String("file", path), slog.String("revision", st.revision))
+	return st
+}
+
+func (s *Server) loadShadow(path string) (*snapshot, error) {
+	cfg, err := config.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	if cfg, err = cfg.WithProfile(s.profileFor(cfg)); err != nil {
+		return nil, err
+	}
+	sched, err := scheduler.New(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scheduler: %w", err)
+	}
+	s.setSources(sched)
+	st, err := newSnapshot(cfg, sched)
+	if err != nil {
+		return nil, err
+	}
+	st.registry = s.devices
+	st.candidate, st.dwellScope = true, "shadow"
+	return st, nil
+}
+
+// shadowSelection is what a configuration serves a request.
+type shadowSelection struct {
+	Schedule string `json:"schedule"`
+	// Album and URL are empty during quiet hours without a night album.
+	Album string `json:"album,omitempty"`
+	URL   string `json:"url,omitempty"`
+}
+
+// shadowMismatchEntry is a redirect the candidate would have served
+// differently.
+type shadowMismatchEntry struct {
+	Time      time.Time       `json:"time"`
+	Device    string          `json:"device,omitempty"`
+	Active    shadowSelection `json:"active"`
+	Candidate shadowSelection `json:"candidate"`
+}
+
+// shadowRecorder counts the comparisons with a candidate revision and keeps
+// the latest mismatches. A new revision starts over.
+type shadowRecorder struct {
+	mu         sync.Mutex
+	revision   string
+	since      time.Time
+	matches    int64
+	mismatches int64
+	errors     int64
+	recent     []shadowMismatchEntry
+}
+
+// record counts a comparison with the candidate revision, keeping m when
+// it is a mismatch.
+func (r *shadowRecorder) record(revision, result string, m *shadowMismatchEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if revision != r.revision {
+		r.revision, r.since = revision, time.Now()
+		r.matches, r.mismatches, r.errors, r.recent = 0, 0, 0, nil
+	}
+	switch result {
+	case shadowMatch:
+		r.matches++
+	case shadowMismatch:
+		r.mismatches++
+		r.recent = append(r.recent, *m)
+		if len(r.recent) > shadowMaxMismatches {
+			r.recent = r.recent[len(r.recent)-shadowMaxMismatches:]
+		}
+	default:
+		r.errors++
+	}
+}
+
+// selection returns what a choice serves a request.
+func (c redirectChoice) selection(st *snapshot, r *http.Request) shadowSelection {
+	if c.base == nil {
+		return shadowSelection{Schedule: c.schedule}
+	}
+	return shadowSelection{Schedule: c.schedule, Album: c.base.album, URL: c.base.build(st, r, c.params)}
+}
+
+// compareShadow resolves a redirect with the candidate configuration of st
+// and records whether it agrees with active, the choice of st itself. The
+// cache-busting parameter, which depends on the configuration's revision,
+// is left out of the comparison.
+func (s *Server) compareShadow(st *snapshot, r *http.Request, now time.Time, overridden bool, active redirectChoice) {
+	shadow := st.shadow
+	candidate, err := s.choose(shadow, r, now, overridden)
+	if err != nil {
+		shadowComparisonsTotal.WithLabelValues(shadowError).Inc()
+		s.shadow.record(shadow.revision, shadowError, nil)
+		s.logger.Warn("shadow configuration failed to resolve the redirect", slog.Any("error", err))
+		return
+	}
+
+	want, got := active.selection(st, r), candidate.selection(shadow, r)
+	if want == got {
+		shadowComparisonsTotal.WithLabelValues(shadowMatch).Inc()
+		s.shadow.record(shadow.revision, shadowMatch, nil)
+		return
+	}
+	m := &shadowMismatchEntry{Time: now, Device: r.URL.Query().Get(deviceParam), Active: want, Candidate: got}
+	shadowComparisonsTotal.WithLabelValues(shadowMismatch).Inc()
+	s.shadow.record(shadow.revision, shadowMismatch, m)
+	if isLogSampled(r.Context()) {
+		s.logger.Info("shadow configuration would serve differently",
+			slog.String("schedule", want.Schedule),
+			slog.String("album", want.Album),
+			slog.String("shadow_schedule", got.Schedule),
+			slog.String("shadow_album", got.Album),
+			slog.String("shadow_redirect_url", got.URL),
+		)
+	}
+}
+
+// shadowResponse is the body of GET /api/v1/shadow.
+type shadowResponse struct {
+	Enabled bool `json:"enabled"`
+	// Config is the path of the candidate configuration and Revision its
+	// revision; the counts start over when it changes, at Since.
+	Config     string     `json:"config,omitempty"`
+	Revision   string     `json:"revision,omitempty"`
+	Since      *time.Time `json:"since,omitempty"`
+	Matches    int64      `json:"matches"`
+	Mismatches int64      `json:"mismatches"`
+	Errors     int64      `json:"errors"`
+	// RecentMismatches holds the latest mismatches, oldest first.
+	RecentMismatches []shadowMismatchEntry `json:"recent_mismatches"`
+}
+
+// handleShadow reports how the candidate configuration compared with the
+// active one on the redirects served since it was loaded.
+func (s *Server) handleShadow(w http.ResponseWriter, r *http.Request) {
+	st := s.current()
+	resp := shadowResponse{RecentMismatches: []shadowMismatchEntry{}}
+	if st.shadow != nil {
+		resp.Enabled = true
+		resp.Config = st.config.Shadow.Config
+		resp.Revision = st.shadow.revision
+
+		s.shadow.mu.Lock()
+		if s.shadow.revision == st.shadow.revision {
+			since := s.shadow.since
+			resp.Since = &since
+			resp.Matches, resp.Mismatches, resp.Errors = s.shadow.matches, s.shadow.mismatches, s.shadow.errors
+			resp.RecentMismatches = append(resp.RecentMismatches, s.shadow.recent...)
+		}
+		s.shadow.mu.Unlock()
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
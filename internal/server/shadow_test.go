@@ -0,0 +1,83 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+)
+
+// writeShadowConfig writes a candidate configuration showing album all year.
+func writeShadowConfig(t *testing.T, path, album string) {
+	t.Helper()
+	data := `kiosk_url: https://kiosk.example.com
+default_album: default-album-id
+schedule:
+  - name: always
+    album: ` + album + `
+    start: "01-01"
+    end: "12-31"
+`
+	require.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+}
+
+// shadowStatus fetches GET /api/v1/shadow.
+func shadowStatus(t *testing.T, srv *Server) shadowResponse {
+	t.Helper()
+	rec := apiRequest(srv, http.MethodGet, "/api/v1/shadow", "")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var resp shadowResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	return resp
+}
+
+func TestShadow_Compare(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "candidate.yaml")
+	writeShadowConfig(t, path, "candidate-album")
+
+	cfg := newAPITestConfig()
+	cfg.APITokens = []config.APIToken{{Name: "automation", Token: testAPIToken}}
+	cfg.Schedule = []config.ScheduleEntry{{Name: "always", Album: "active-album", Start: "01-01", End: "12-31"}}
+	cfg.Shadow.Config = path
+	srv := newTestServer(t, cfg)
+
+	// The candidate never changes the response.
+	assert.Contains(t, redirectTarget(t, srv), "album=active-album")
+	resp := shadowStatus(t, srv)
+	assert.True(t, resp.Enabled)
+	assert.Equal(t, path, resp.Config)
+	assert.Equal(t, int64(1), resp.Mismatches)
+	require.Len(t, resp.RecentMismatches, 1)
+	m := resp.RecentMismatches[0]
+	assert.Equal(t, "active-album", m.Active.Album)
+	assert.Equal(t, "candidate-album", m.Candidate.Album)
+	assert.Equal(t, "https://kiosk.example.com?album=candidate-album", m.Candidate.URL)
+
+	// A reload reads the candidate again and starts the counts over.
+	writeShadowConfig(t, path, "active-album")
+	require.NoError(t, srv.Reload(func() (*config.Config, error) { return cfg.Clone(), nil }))
+	redirectTarget(t, srv)
+	resp = shadowStatus(t, srv)
+	assert.Equal(t, int64(1), resp.Matches)
+	assert.Zero(t, resp.Mismatches)
+	assert.Empty(t, resp.RecentMismatches)
+}
+
+func TestShadow_InvalidCandidate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "candidate.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("kiosk_url: not a url\n"), 0o600))
+
+	cfg := newAPITestConfig()
+	cfg.APITokens = []config.APIToken{{Name: "automation", Token: testAPIToken}}
+	cfg.Shadow.Config = path
+	srv := newTestServer(t, cfg)
+
+	assert.NotEmpty(t, redirectTarget(t, srv))
+	assert.False(t, shadowStatus(t, srv).Enabled)
+}
@@ -0,0 +1,203 @@
+package server
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// maxSlashClockSkew bounds the age of a signed slash command request, so
+// captured requests cannot be replayed later.
+const maxSlashClockSkew = 5 * time.Minute
+
+// maxSlashBody bounds slash command request bodies.
+const maxSlashBody = 64 << 10
+
+// Discord interaction and response types.
+const (
+	discordPing               = 1
+	discordApplicationCommand = 2
+	discordPong               = 1
+	discordChannelMessage     = 4
+	discordSubcommand         = 1
+)
+
+// slashCommandsTotal counts slash command requests by platform and result.
+var slashCommandsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "immich_kiosk_scheduler_slash_commands_total",
+		Help: "Total number of Slack and Discord slash commands by platform and result (handled, denied)",
+	},
+	[]string{"platform", "result"},
+)
+
+func init() {
+	prometheus.MustRegister(slashCommandsTotal)
+}
+
+// discordInteraction is the part of a Discord interaction the scheduler reads.
+type discordInteraction struct {
+	Type int `json:"type"`
+	Data struct {
+		Name    string          `json:"name"`
+		Options []discordOption `json:"options"`
+	} `json:"data"`
+	// Member is set in servers, User in direct messages.
+	Member *struct {
+		User discordUser `json:"user"`
+	} `json:"member"`
+	User *discordUser `json:"user"`
+}
+
+type discordUser struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+}
+
+type discordOption struct {
+	Name    string          `json:"name"`
+	Type    int             `json:"type"`
+	Value   any             `json:"value"`
+	Options []discordOption `json:"options"`
+}
+
+// handleSlack runs a Slack slash command, e.g. /kiosk override garden 2h.
+func (s *Server) handleSlack(w http.ResponseWriter, r *http.Request) {
+	secret := s.current().config.SlashCommands.SlackSigningSecret
+	if secret == "" {
+		s.handleNotFound(w, r)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSlashBody))
+	if err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	timestamp := r.Header.Get("X-Slack-Request-Timestamp")
+	if !freshSlashTimestamp(timestamp, time.Now()) || !validSlackSignature(secret, timestamp, body, r.Header.Get("X-Slack-Signature")) {
+		slashCommandsTotal.WithLabelValues("slack", "denied").Inc()
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	reply := s.Command("slack:"+form.Get("user_name"), form.Get("text"))
+	slashCommandsTotal.WithLabelValues("slack", "handled").Inc()
+	writeJSON(w, http.StatusOK, map[string]string{"response_type": "in_channel", "text": reply})
+}
+
+// handleDiscord answers Discord interactions: pings when the endpoint is
+// set up, and /kiosk commands.
+func (s *Server) handleDiscord(w http.ResponseWriter, r *http.Request) {
+	cfg := s.current().config.SlashCommands
+	if cfg.DiscordPublicKey == "" {
+		s.handleNotFound(w, r)
+		return
+	}
+	key, err := cfg.DiscordKey()
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSlashBody))
+	if err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	timestamp := r.Header.Get("X-Signature-Timestamp")
+	if !freshSlashTimestamp(timestamp, time.Now()) || !validDiscordSignature(key, timestamp, body, r.Header.Get("X-Signature-Ed25519")) {
+		// Discord sends badly signed requests on purpose to check this.
+		slashCommandsTotal.WithLabelValues("discord", "denied").Inc()
+		http.Error(w, "invalid request signature", http.StatusUnauthorized)
+		return
+	}
+	var interaction discordInteraction
+	if err := json.Unmarshal(body, &interaction); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	switch interaction.Type {
+	case discordPing:
+		writeJSON(w, http.StatusOK, map[string]int{"type": discordPong})
+	case discordApplicationCommand:
+		reply := s.Command("discord:"+interaction.username(), discordCommandText(interaction.Data.Options))
+		slashCommandsTotal.WithLabelValues("discord", "handled").Inc()
+		writeJSON(w, http.StatusOK, map[string]any{
+			"type": discordChannelMessage,
+			"data": map[string]string{"content": reply},
+		})
+	default:
+		http.Error(w, fmt.Sprintf("unsupported interaction type %d", interaction.Type), http.StatusBadRequest)
+	}
+}
+
+// username returns the name of the user who sent an interaction.
+func (i *discordInteraction) username() string {
+	if i.Member != nil {
+		return i.Member.User.Username
+	}
+	if i.User != nil {
+		return i.User.Username
+	}
+	return ""
+}
+
+// discordCommandText turns the options of a /kiosk command into command
+// text: subcommands such as /kiosk override album:garden duration:2h give
+// "override garden 2h", and a single text option is used as it is.
+func discordCommandText(options []discordOption) string {
+	var fields []string
+	for _, o := range options {
+		if o.Type == discordSubcommand {
+			fields = append(fields, o.Name, discordCommandText(o.Options))
+			continue
+		}
+		fields = append(fields, fmt.Sprint(o.Value))
+	}
+	return strings.Join(strings.Fields(strings.Join(fields, " ")), " ")
+}
+
+// freshSlashTimestamp reports whether a request timestamp in Unix seconds
+// is within maxSlashClockSkew of now.
+func freshSlashTimestamp(timestamp string, now time.Time) bool {
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	skew := now.Sub(time.Unix(sec, 0))
+	return skew < maxSlashClockSkew && skew > -maxSlashClockSkew
+}
+
+// validSlackSignature verifies Slack's v0 request signature.
+func validSlackSignature(secret, timestamp string, body []byte, signature string) bool {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + timestamp + ":"))
+	mac.Write(body)
+	want := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(signature), []byte(want))
+}
+
+// validDiscordSignature verifies Discord's Ed25519 request signature.
+func validDiscordSignature(key ed25519.PublicKey, timestamp string, body []byte, signature string) bool {
+	sig, err := hex.DecodeString(signature)
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return false
+	}
+	return ed25519.Verify(key, append([]byte(timestamp), body...), sig)
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+)
+
+const testSlackSecret = "8f742231b10e8888abcd99yyyzzz85a5"
+
+func slackRequest(srv *Server, form url.Values, timestamp time.Time, secret string) *httptest.ResponseRecorder {
+	body := form.Encode()
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + ts + ":" + body))
+
+	req := httptest.NewRequest(http.MethodPost, "/chat/slack", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Slack-Request-Timestamp", ts)
+	req.Header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac.Sum(nil)))
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestSlack_Command(t *testing.T) {
+	form := url.Values{"command": {"/kiosk"}, "text": {"override garden 2h"}, "user_name": {"alex"}}
+	disabled := newChatTestServer(t)
+	assert.Equal(t, http.StatusNotFound, slackRequest(disabled, form, time.Now(), testSlackSecret).Code, "disabled without a secret")
+
+	cfg := newAPITestConfig()
+	cfg.Control.Albums = []config.ControlAlbum{{Name: "Garden", Album: "garden-album"}}
+	cfg.SlashCommands.SlackSigningSecret = testSlackSecret
+	srv := newTestServer(t, cfg)
+
+	rec := slackRequest(srv, form, time.Now(), testSlackSecret)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var resp map[string]string
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	assert.Equal(t, "in_channel", resp["response_type"])
+	assert.True(t, strings.HasPrefix(resp["text"], "Showing Garden until "), resp["text"])
+	require.NotNil(t, srv.override.Load())
+	assert.Equal(t, "garden-album", srv.override.Load().Album)
+
+	assert.Equal(t, http.StatusUnauthorized, slackRequest(srv, form, time.Now(), "wrong-secret").Code)
+	assert.Equal(t, http.StatusUnauthorized, slackRequest(srv, form, time.Now().Add(-10*time.Minute), testSlackSecret).Code, "replayed")
+}
+
+func discordRequest(srv *Server, key ed25519.PrivateKey, body string) *httptest.ResponseRecorder {
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	sig := ed25519.Sign(key, []byte(ts+body))
+
+	req := httptest.NewRequest(http.MethodPost, "/chat/discord", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Signature-Timestamp", ts)
+	req.Header.Set("X-Signature-Ed25519", hex.EncodeToString(sig))
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestDiscord_Interactions(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	cfg := newAPITestConfig()
+	cfg.Control.Albums = []config.ControlAlbum{{Name: "Garden", Album: "garden-album"}}
+	cfg.SlashCommands.DiscordPublicKey = hex.EncodeToString(public)
+	srv := newTestServer(t, cfg)
+
+	rec := discordRequest(srv, private, `{"type": 1}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.JSONEq(t, `{"type": 1}`, rec.Body.String())
+
+	rec = discordRequest(srv, private, `{"type": 2, "member": {"user": {"id": "1", "username": "alex"}},
+		"data": {"name": "kiosk", "options": [{"name": "override", "type": 1, "options": [
+			{"name": "album", "type": 3, "value": "garden"}, {"name": "duration", "type": 3, "value": "2h"}]}]}}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var resp struct {
+		Type int `json:"type"`
+		Data struct {
+			Content string `json:"content"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	assert.Equal(t, discordChannelMessage, resp.Type)
+	assert.True(t, strings.HasPrefix(resp.Data.Content, "Showing Garden until "), resp.Data.Content)
+
+	_, other, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, discordRequest(srv, other, `{"type": 1}`).Code)
+}
+
+func TestDiscordCommandText(t *testing.T) {
+	assert.Equal(t, "status", discordCommandText([]discordOption{{Name: "status", Type: discordSubcommand}}))
+	assert.Equal(t, "override garden 2h", discordCommandText([]discordOption{{Name: "command", Type: 3, Value: " override  garden 2h"}}))
+	assert.Equal(t, "", discordCommandText(nil))
+}
@@ -0,0 +1,427 @@
+package server
+
+import (
+	"cmp"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/state"
+)
+
+// statsDoc is the state document holding the daily statistics.
+const statsDoc = "stats"
+
+// statsFlushInterval is how often the active schedule's time is accounted
+// and the statistics are saved.
+const statsFlushInterval = time.Minute
+
+// defaultStatsDays is the number of days returned by GET /api/v1/stats and
+// shown in the UI when days is not given.
+const defaultStatsDays = 30
+
+// dayStats aggregates one day, by schedule name. Schedule names include
+// "default", "quiet_hours", "party" and "override".
+type dayStats struct {
+	Date      string           `json:"date"`
+	Redirects map[string]int64 `json:"redirects"`
+	// ActiveSeconds is how long each schedule was active while the
+	// scheduler was running.
+	ActiveSeconds map[string]float64 `json:"active_seconds"`
+}
+
+// statsTotals sums the days of a stats response.
+type statsTotals struct {
+	Redirects     map[string]int64   `json:"redirects"`
+	ActiveSeconds map[string]float64 `json:"active_seconds"`
+}
+
+// statsResponse is the body of GET /api/v1/stats.
+type statsResponse struct {
+	RetentionDays int `json:"retention_days"`
+	// Days are ordered oldest first; days without data are left out.
+	Days   []dayStats  `json:"days"`
+	Totals statsTotals `json:"totals"`
+}
+
+// statsRecorder rolls redirects and active schedule time into daily
+// buckets, in local time.
+type statsRecorder struct {
+	mu   sync.Mutex
+	days map[string]*dayStats
+	// since is when the active schedule's time was last accounted.
+	since time.Time
+	dirty bool
+}
+
+// newStatsRecorder creates a recorder starting from the statistics saved in
+// store.
+func newStatsRecorder(store *state.Store, logger *slog.Logger) *statsRecorder {
+	r := &statsRecorder{days: map[string]*dayStats{}, since: time.Now()}
+	var saved []dayStats
+	if _, err := store.Load(statsDoc, &saved); err != nil {
+		logger.Error("failed to load statistics", slog.Any("error", err))
+	}
+	for _, d := range saved {
+		r.day(d.Date)
+		maps.Copy(r.days[d.Date].Redirects, d.Redirects)
+		maps.Copy(r.days[d.Date].ActiveSeconds, d.ActiveSeconds)
+	}
+	return r
+}
+
+// day returns the bucket of a date, creating it if needed. Callers must
+// hold mu.
+func (r *statsRecorder) day(date string) *dayStats {
+	d, ok := r.days[date]
+	if !ok {
+		d = &dayStats{Date: date, Redirects: map[string]int64{}, ActiveSeconds: map[string]float64{}}
+		r.days[date] = d
+	}
+	return d
+}
+
+// redirect counts a redirect served for the schedule.
+func (r *statsRecorder) redirect(schedule string, t time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.day(t.Format(time.DateOnly)).Redirects[schedule]++
+	r.dirty = true
+}
+
+// account adds the time since the last call to the schedule that was
+// active meanwhile, split at midnight.
+func (r *statsRecorder) account(schedule string, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for r.since.Before(now) {
+		y, m, d := r.since.Date()
+		end := time.Date(y, m, d+1, 0, 0, 0, 0, r.since.Location())
+		if now.Before(end) {
+			end = now
+		}
+		r.day(r.since.Format(time.DateOnly)).ActiveSeconds[schedule] += end.Sub(r.since).Seconds()
+		r.since = end
+		r.dirty = true
+	}
+	r.since = now
+}
+
+// prune drops the days older than the retention.
+func (r *statsRecorder) prune(retention int, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	oldest := now.AddDate(0, 0, 1-retention).Format(time.DateOnly)
+	for date := range r.days {
+		if date < oldest {
+			delete(r.days, date)
+			r.dirty = true
+		}
+	}
+}
+
+// save persists the statistics when they changed since the last save.
+func (r *statsRecorder) save(store *state.Store) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.dirty {
+		return nil
+	}
+	if err := store.Save(statsDoc, r.between("", "9999-12-31")); err != nil {
+		return err
+	}
+	r.dirty = false
+	return nil
+}
+
+// rangeDays returns copies of the days from one date to another, inclusive,
+// oldest first.
+func (r *statsRecorder) rangeDays(from, to string) []dayStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.between(from, to)
+}
+
+// between implements rangeDays. Callers must hold mu.
+func (r *statsRecorder) between(from, to string) []dayStats {
+	days := make([]dayStats, 0, len(r.days))
+	for date, d := range r.days {
+		if date >= from && date <= to {
+			days = append(days, dayStats{Date: date, Redirects: maps.Clone(d.Redirects), ActiveSeconds: maps.Clone(d.ActiveSeconds)})
+		}
+	}
+	slices.SortFunc(days, func(a, b dayStats) int { return cmp.Compare(a.Date, b.Date) })
+	return days
+}
+
+// accountActive adds the time since it was last accounted to the active
+// schedule.
+func (s *Server) accountActive(now time.Time) {
+	s.transitionMu.Lock()
+	defer s.transitionMu.Unlock()
+	s.stats.account(s.active.Schedule, now)
+}
+
+// flushStats accounts the active schedule's time, drops expired days and
+// saves the statistics.
+func (s *Server) flushStats(now time.Time) {
+	s.accountActive(now)
+	s.stats.prune(s.current().config.Stats.Retention(), now)
+	if err := s.stats.save(s.store); err != nil {
+		s.logger.Error("failed to save statistics", slog.Any("error", err))
+	}
+}
+
+// runStats flushes the statistics periodically until the context is
+// cancelled.
+func (s *Server) runStats(ctx context.Context) {
+	ticker := time.NewTicker(statsFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.flushStats(time.Now())
+		}
+	}
+}
+
+// statsDays parses the days query parameter, the number of days up to and
+// including today to return.
+func statsDays(r *http.Request, retention int) (int, error) {
+	v := r.URL.Query().Get("days")
+	if v == "" {
+		return min(defaultStatsDays, retention), nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 || n > retention {
+		return 0, fmt.Errorf("days must be between 1 and %d", retention)
+	}
+	return n, nil
+}
+
+// statsFor returns the statistics of the last days, up to and including today.
+func (s *Server) statsFor(days int, now time.Time) statsResponse {
+	s.accountActive(now)
+
+	from := now.AddDate(0, 0, 1-days).Format(time.DateOnly)
+	resp := statsResponse{
+		RetentionDays: s.current().config.Stats.Retention(),
+		Days:          s.stats.rangeDays(from, now.Format(time.DateOnly)),
+		Totals:        statsTotals{Redirects: map[string]int64{}, ActiveSeconds: map[string]float64{}},
+	}
+	for _, d := range resp.Days {
+		for schedule, n := range d.Redirects {
+			resp.Totals.Redirects[schedule] += n
+		}
+		for schedule, secs := range d.ActiveSeconds {
+			resp.Totals.ActiveSeconds[schedule] += secs
+		}
+	}
+	return resp
+}
+
+// handleStats returns the daily statistics of the last days.
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	days, err := statsDays(r, s.current().config.Stats.Retention())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, s.statsFor(days, time.Now()))
+}
+
+// statsRow is one row of the statistics export: a schedule on a day.
+type statsRow struct {
+	Date          string  `json:"date"`
+	Schedule      string  `json:"schedule"`
+	Redirects     int64   `json:"redirects"`
+	ActiveSeconds float64 `json:"active_seconds"`
+}
+
+// handleStatsExport exports the statistics from one date to another as CSV
+// or JSON rows, one per day and schedule. The period defaults to all
+// retained days.
+func (s *Server) handleStatsExport(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "json" {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown format %q, expected csv or json", format))
+		return
+	}
+
+	now := time.Now()
+	from := now.AddDate(0, 0, 1-s.current().config.Stats.Retention()).Format(time.DateOnly)
+	to := now.Format(time.DateOnly)
+	for name, date := range map[string]*string{"from": &from, "to": &to} {
+		if v := query.Get(name); v != "" {
+			if _, err := time.Parse(time.DateOnly, v); err != nil {
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid %s date %q, expected YYYY-MM-DD", name, v))
+				return
+			}
+			*date = v
+		}
+	}
+	if from > to {
+		writeError(w, http.StatusBadRequest, "from must not be after to")
+		return
+	}
+
+	s.accountActive(now)
+	rows := []statsRow{}
+	for _, d := range s.stats.rangeDays(from, to) {
+		schedules := slices.Collect(maps.Keys(d.Redirects))
+		for name := range d.ActiveSeconds {
+			if _, ok := d.Redirects[name]; !ok {
+				schedules = append(schedules, name)
+			}
+		}
+		slices.Sort(schedules)
+		for _, name := range schedules {
+			rows = append(rows, statsRow{Date: d.Date, Schedule: name, Redirects: d.Redirects[name], ActiveSeconds: d.ActiveSeconds[name]})
+		}
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="stats-%s-%s.%s"`, from, to, format))
+	if format == "json" {
+		writeJSON(w, http.StatusOK, rows)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"date", "schedule", "redirects", "active_seconds"})
+	for _, row := range rows {
+		_ = cw.Write([]string{row.Date, row.Schedule, strconv.FormatInt(row.Redirects, 10), strconv.FormatFloat(row.ActiveSeconds, 'f', 0, 64)})
+	}
+	cw.Flush()
+}
+
+// Dimensions of the daily redirects chart on the statistics page, in pixels.
+const (
+	statsBarWidth    = 12
+	statsBarGap      = 2
+	statsChartHeight = 120
+)
+
+// statsPage is the data of the statistics page.
+type statsPage struct {
+	Days        int
+	Width       int
+	Height      int
+	BarWidth    int
+	Bars        []statsBar
+	MostPerDay  int64
+	Schedules   []statsSchedule
+	DayOptions  []int
+	FirstDate   string
+	LastDate    string
+	TotalServed int64
+}
+
+// statsBar is one day in the redirects chart.
+type statsBar struct {
+	X, Y, Height int
+	Title        string
+}
+
+type statsSchedule struct {
+	// Index is the index of the schedule entry, or -1 for other schedules
+	// such as the default album.
+	Index     int
+	Name      string
+	Redirects int64
+	// ActiveHours is the time the schedule was active, in hours.
+	ActiveHours string
+}
+
+// handleStatsPage renders the redirects per day and the time each schedule
+// was active.
+func (s *Server) handleStatsPage(w http.ResponseWriter, r *http.Request) {
+	st := s.current()
+	retention := st.config.Stats.Retention()
+	days, err := statsDays(r, retention)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	stats := s.statsFor(days, now)
+	byDate := make(map[string]dayStats, len(stats.Days))
+	for _, d := range stats.Days {
+		byDate[d.Date] = d
+	}
+
+	page := statsPage{
+		Days:      days,
+		Width:     days * (statsBarWidth + statsBarGap),
+		Height:    statsChartHeight,
+		BarWidth:  statsBarWidth,
+		FirstDate: now.AddDate(0, 0, 1-days).Format(time.DateOnly),
+		LastDate:  now.Format(time.DateOnly),
+	}
+	for _, n := range []int{7, 30, 90, 365} {
+		if n <= retention {
+			page.DayOptions = append(page.DayOptions, n)
+		}
+	}
+
+	redirects := make([]int64, days)
+	for i := range days {
+		for _, n := range byDate[now.AddDate(0, 0, i+1-days).Format(time.DateOnly)].Redirects {
+			redirects[i] += n
+		}
+		page.MostPerDay = max(page.MostPerDay, redirects[i])
+		page.TotalServed += redirects[i]
+	}
+	for i, n := range redirects {
+		height := 0
+		if page.MostPerDay > 0 {
+			height = int(n * statsChartHeight / page.MostPerDay)
+		}
+		page.Bars = append(page.Bars, statsBar{
+			X:      i * (statsBarWidth + statsBarGap),
+			Y:      statsChartHeight - height,
+			Height: height,
+			Title:  fmt.Sprintf("%s: %d redirects", now.AddDate(0, 0, i+1-days).Format(time.DateOnly), n),
+		})
+	}
+
+	names := slices.Collect(maps.Keys(stats.Totals.Redirects))
+	for name := range stats.Totals.ActiveSeconds {
+		if _, ok := stats.Totals.Redirects[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	for _, name := range names {
+		page.Schedules = append(page.Schedules, statsSchedule{
+			Index:       slices.IndexFunc(st.config.Schedule, func(e config.ScheduleEntry) bool { return e.Name == name }),
+			Name:        name,
+			Redirects:   stats.Totals.Redirects[name],
+			ActiveHours: fmt.Sprintf("%.1f", stats.Totals.ActiveSeconds[name]/3600),
+		})
+	}
+	slices.SortFunc(page.Schedules, func(a, b statsSchedule) int {
+		return cmp.Or(
+			cmp.Compare(stats.Totals.ActiveSeconds[b.Name], stats.Totals.ActiveSeconds[a.Name]),
+			cmp.Compare(b.Redirects, a.Redirects),
+			cmp.Compare(a.Name, b.Name),
+		)
+	})
+
+	s.renderUI(w, http.StatusOK, "stats", "Statistics", len(st.config.Schedule), page)
+}
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/state"
+)
+
+func newTestStatsRecorder(t *testing.T) *statsRecorder {
+	t.Helper()
+	store, err := state.Open("")
+	require.NoError(t, err)
+	return newStatsRecorder(store, slog.Default())
+}
+
+func TestStatsRecorder_AccountSplitsAtMidnight(t *testing.T) {
+	r := newTestStatsRecorder(t)
+	r.since = time.Date(2024, 12, 24, 23, 0, 0, 0, time.Local)
+
+	r.account("christmas", time.Date(2024, 12, 25, 1, 30, 0, 0, time.Local))
+	r.account("default", time.Date(2024, 12, 25, 2, 0, 0, 0, time.Local))
+
+	days := r.rangeDays("2024-12-24", "2024-12-25")
+	require.Len(t, days, 2)
+	assert.Equal(t, map[string]float64{"christmas": 3600}, days[0].ActiveSeconds)
+	assert.Equal(t, map[string]float64{"christmas": 5400, "default": 1800}, days[1].ActiveSeconds)
+}
+
+func TestStatsRecorder_Prune(t *testing.T) {
+	r := newTestStatsRecorder(t)
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.Local)
+	r.redirect("default", now.AddDate(0, 0, -7))
+	r.redirect("default", now.AddDate(0, 0, -6))
+	r.redirect("default", now)
+
+	r.prune(7, now)
+
+	days := r.rangeDays("", "9999-12-31")
+	require.Len(t, days, 2)
+	assert.Equal(t, "2024-03-04", days[0].Date)
+	assert.Equal(t, "2024-03-10", days[1].Date)
+}
+
+func TestAPI_Stats(t *testing.T) {
+	srv := newTestServer(t, newAPITestConfig())
+	schedule := srv.selectionAt(srv.current(), time.Now()).Schedule
+
+	for range 3 {
+		rec := httptest.NewRecorder()
+		srv.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		require.Equal(t, http.StatusFound, rec.Code)
+	}
+
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/stats?days=7", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var resp statsResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	assert.Equal(t, 365, resp.RetentionDays)
+	require.Len(t, resp.Days, 1)
+	assert.Equal(t, time.Now().Format(time.DateOnly), resp.Days[0].Date)
+	assert.Equal(t, map[string]int64{schedule: 3}, resp.Days[0].Redirects)
+	assert.Equal(t, int64(3), resp.Totals.Redirects[schedule])
+	assert.Contains(t, resp.Totals.ActiveSeconds, schedule)
+
+	rec = httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/stats?days=400", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestStats_DebugDateNotCounted(t *testing.T) {
+	cfg := newAPITestConfig()
+	cfg.Debug.AllowDateOverride = true
+	srv := newTestServer(t, cfg)
+
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?_date=2024-12-25", nil))
+	require.Equal(t, http.StatusFound, rec.Code)
+
+	assert.Empty(t, srv.statsFor(7, time.Now()).Totals.Redirects)
+}
+
+func TestStats_SurviveRestart(t *testing.T) {
+	cfg := newAPITestConfig()
+	cfg.StateDir = t.TempDir()
+	srv := newTestServer(t, cfg)
+
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusFound, rec.Code)
+	srv.flushStats(time.Now())
+
+	restarted := newTestServer(t, cfg)
+	var total int64
+	for _, n := range restarted.statsFor(1, time.Now()).Totals.Redirects {
+		total += n
+	}
+	assert.Equal(t, int64(1), total)
+}
+
+func TestUI_Stats(t *testing.T) {
+	srv := newTestServer(t, newAPITestConfig())
+	srv.stats.redirect("christmas", time.Now())
+
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ui/stats?days=7", nil))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	body := rec.Body.String()
+	assert.Contains(t, body, "1 redirects from")
+	assert.Contains(t, body, `<span class="swatch e0"></span></td><td>christmas</td>`)
+	assert.Contains(t, body, "<rect")
+}
+
+func TestAPI_StatsExport(t *testing.T) {
+	srv := newTestServer(t, newAPITestConfig())
+	christmas := time.Date(2024, 12, 25, 12, 0, 0, 0, time.Local)
+	srv.stats.redirect("christmas", christmas)
+	srv.stats.redirect("christmas", christmas)
+	srv.stats.redirect("party", christmas)
+	srv.stats.since = time.Date(2024, 12, 26, 0, 0, 0, 0, time.Local)
+	srv.stats.account("christmas", time.Date(2024, 12, 26, 1, 0, 0, 0, time.Local))
+	srv.stats.since = time.Now()
+	srv.stats.redirect("default", time.Date(2025, 1, 10, 12, 0, 0, 0, time.Local))
+
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/stats/export?format=csv&from=2024-12-01&to=2024-12-31", nil))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Equal(t, "text/csv; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="stats-2024-12-01-2024-12-31.csv"`, rec.Header().Get("Content-Disposition"))
+	assert.Equal(t, "date,schedule,redirects,active_seconds\n"+
+		"2024-12-25,christmas,2,0\n"+
+		"2024-12-25,party,1,0\n"+
+		"2024-12-26,christmas,0,3600\n", rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/stats/export?format=json&from=2025-01-01&to=2025-01-31", nil))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var rows []statsRow
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&rows))
+	assert.Equal(t, []statsRow{{Date: "2025-01-10", Schedule: "default", Redirects: 1}}, rows)
+}
+
+func TestAPI_StatsExportErrors(t *testing.T) {
+	srv := newTestServer(t, newAPITestConfig())
+	for _, target := range []string{
+		"/api/v1/stats/export?format=xlsx",
+		"/api/v1/stats/export?from=12-01",
+		"/api/v1/stats/export?from=2024-12-31&to=2024-12-01",
+	} {
+		rec := httptest.NewRecorder()
+		srv.router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		assert.Equal(t, http.StatusBadRequest, rec.Code, target)
+	}
+}
@@ -0,0 +1,261 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/hooks"
+)
+
+// transitionCheckInterval is how often the active schedule is re-evaluated
+// in the background. Schedules change at day boundaries, so a minute is
+// plenty to fire hooks promptly.
+const transitionCheckInterval = time.Minute
+
+// Transition metrics
+var transitionsSuppressedTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "immich_kiosk_scheduler_transitions_suppressed_total",
+		Help: "Total number of selection changes held back by the transition cooldown or debounce, by reason",
+	},
+	[]string{"reason"},
+)
+
+func init() {
+	prometheus.MustRegister(transitionsSuppressedTotal)
+}
+
+// Reasons a selection change is held back.
+const (
+	suppressedCooldown = "cooldown"
+	suppressedDebounce = "debounce"
+)
+
+// transitionGate holds back selection changes that come within the
+// cooldown of the last transition or do not last for the debounce. It is
+// guarded by Server.transitionMu.
+type transitionGate struct {
+	// changed is when the active selection last changed; zero until it has.
+	changed time.Time
+	// pending is the change held back, seen first at pendingSince.
+	pending      hooks.Selection
+	pendingSince time.Time
+	retry        *time.Timer
+	// held is what redirects read: the change held back and the selection
+	// shown instead, or nil when nothing is held back.
+	held atomic.Pointer[heldTransition]
+}
+
+// heldTransition is a change held back by the transition gate.
+type heldTransition struct {
+	pending, active hooks.Selection
+	// until is when the change is evaluated again.
+	until time.Time
+}
+
+// wait returns how long next, a change of the active selection, must wait
+// under cfg, and why. It returns zero when the change may happen now.
+func (g *transitionGate) wait(cfg config.TransitionsConfig, next hooks.Selection, now time.Time) (time.Duration, string) {
+	if next != g.pending {
+		g.pending, g.pendingSince = next, now
+	}
+	var wait time.Duration
+	reason := ""
+	if d := g.pendingSince.Add(cfg.Debounce).Sub(now); d > wait {
+		wait, reason = d, suppressedDebounce
+	}
+	if !g.changed.IsZero() {
+		if d := g.changed.Add(cfg.Cooldown).Sub(now); d > wait {
+			wait, reason = d, suppressedCooldown
+		}
+	}
+	return wait, reason
+}
+
+// settle forgets the change held back, if any.
+func (g *transitionGate) settle() {
+	g.pending, g.pendingSince = hooks.Selection{}, time.Time{}
+	g.held.Store(nil)
+	if g.retry != nil {
+		g.retry.Stop()
+		g.retry = nil
+	}
+}
+
+// gated returns the choice showing the active selection when c, the choice
+// of a top-level redirect at now, is the change the transition gate holds
+// back. A change not evaluated yet is evaluated first, so displays do not
+// see it before the gate does.
+func (s *Server) gated(st *snapshot, now time.Time, c redirectChoice) (redirectChoice, error) {
+	chose := func(sel hooks.Selection) bool {
+		album := ""
+		if c.base != nil {
+			album = c.base.album
+		}
+		return sel.Schedule == c.schedule && st.config.AlbumID(sel.Album) == album
+	}
+
+	s.transitionMu.Lock()
+	active := s.active
+	s.transitionMu.Unlock()
+	if chose(active) {
+		return c, nil
+	}
+	if h := s.gate.held.Load(); h == nil || !chose(h.pending) {
+		s.evaluate(hooks.ReasonSchedule)
+	}
+	h := s.gate.held.Load()
+	if h == nil || !chose(h.pending) {
+		return c, nil
+	}
+
+	shown := redirectChoice{schedule: h.active.Schedule}
+	if h.active.Album == "" {
+		shown.quietUntil = h.until
+		return shown, nil
+	}
+	var entry *config.ScheduleEntry
+	entries := st.backend.List()
+	if i := slices.IndexFunc(entries, func(e config.ScheduleEntry) bool { return e.Name == h.active.Schedule }); i >= 0 {
+		entry = &entries[i]
+	}
+	var err error
+	shown.base, err = st.newRedirectBase(h.active.Album, entry)
+	return shown, err
+}
+
+// reevaluateResponse is the body of POST /api/v1/reevaluate.
+type reevaluateResponse struct {
+	Changed  bool            `json:"changed"`
+	Previous hooks.Selection `json:"previous"`
+	Current  hooks.Selection `json:"current"`
+}
+
+// selectionAt resolves the album served at the given time: an active
+// override from the control page, quiet hours, or else the schedule.
+func (s *Server) selectionAt(st *snapshot, now time.Time) hooks.Selection {
+	d := s.resolve(st, now)
+	if o := s.activeOverride(now); o != nil {
+		album := o.Album
+		if album == "" {
+			album = d.Album
+		}
+		return hooks.Selection{Schedule: o.schedule(), Album: album}
+	}
+	if _, quiet := st.config.QuietHours.Window(now); quiet {
+		return hooks.Selection{Schedule: quietHoursSchedule, Album: st.config.QuietHours.NightAlbum()}
+	}
+	return hooks.Selection{Schedule: d.Schedule, Album: d.Album}
+}
+
+// evaluate recomputes the active selection and, when it differs from the
+// last one seen, records the transition and notifies hooks. With
+// transitions.cooldown or transitions.debounce, a change may be held back
+// and evaluated again once it may happen; current is then previous.
+func (s *Server) evaluate(reason string) (previous, current hooks.Selection, changed bool) {
+	s.transitionMu.Lock()
+	defer s.transitionMu.Unlock()
+
+	st := s.current()
+	now := time.Now()
+	previous = s.active
+	current = s.selectionAt(st, now)
+	if current == previous {
+		s.gate.settle()
+		return previous, current, false
+	}
+	if cfg := st.config.Transitions; cfg.Enabled() {
+		first := current != s.gate.pending
+		if wait, why := s.gate.wait(cfg, current, now); wait > 0 {
+			if first {
+				transitionsSuppressedTotal.WithLabelValues(why).Inc()
+				s.logger.Debug("schedule transition held back",
+					slog.String("reason", why),
+					slog.String("schedule", current.Schedule),
+					slog.Duration("wait", wait),
+				)
+			}
+			s.gate.held.Store(&heldTransition{pending: current, active: previous, until: now.Add(wait)})
+			if s.gate.retry != nil {
+				s.gate.retry.Stop()
+			}
+			s.gate.retry = time.AfterFunc(wait, func() { s.evaluate(reason) })
+			return previous, previous, false
+		}
+	}
+	s.gate.settle()
+	s.gate.changed = now
+	s.stats.account(previous.Schedule, now)
+	s.active = current
+
+	s.logger.Info("schedule transition",
+		slog.String("reason", reason),
+		slog.String("previous_schedule", previous.Schedule),
+		slog.String("schedule", current.Schedule),
+		slog.String("album", current.Album),
+	)
+	s.updateCurrentScheduleMetric(current.Schedule)
+
+	s.publish(st, hooks.Event{
+		Event:    config.HookEventTransition,
+		Time:     time.Now(),
+		Reason:   reason,
+		Previous: previous,
+		Current:  current,
+	})
+	return previous, current, true
+}
+
+// runTransitions re-evaluates the active schedule periodically until the
+// context is cancelled.
+func (s *Server) runTransitions(ctx context.Context) {
+	ticker := time.NewTicker(transitionCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.evaluate(hooks.ReasonSchedule)
+			s.checkUpcoming(now)
+		}
+	}
+}
+
+// resetCaches drops the cached redirects and decision service answers of
+// the snapshot, its kiosks and shadow configuration, and refreshes the
+// Immich album cache, so nothing decided before an out-of-band change is
+// reused.
+func (s *Server) resetCaches(ctx context.Context) {
+	for _, st := range s.current().all() {
+		st.base.Store(nil)
+		if st.decisions != nil {
+			st.decisions.Reset()
+		}
+	}
+	if s.albums != nil {
+		if err := s.albums.Sync(ctx); err != nil {
+			s.logger.Warn("failed to refresh the album cache", slog.Any("error", err))
+		}
+	}
+}
+
+// handleReevaluate clears the caches and recomputes the active schedule
+// immediately, firing transition hooks if it changed.
+func (s *Server) handleReevaluate(w http.ResponseWriter, r *http.Request) {
+	s.resetCaches(r.Context())
+	previous, current, changed := s.evaluate(hooks.ReasonReevaluate)
+	writeJSON(w, http.StatusOK, reevaluateResponse{
+		Changed:  changed,
+		Previous: previous,
+		Current:  current,
+	})
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/hooks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_ReloadFiresTransitionHook(t *testing.T) {
+	events := make(chan hooks.Event, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev hooks.Event
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&ev))
+		events <- ev
+	}))
+	defer ts.Close()
+
+	cfg := newAPITestConfig()
+	cfg.Schedule = nil
+	cfg.Hooks = []config.HookConfig{{Name: "test", URL: ts.URL}}
+	srv := newTestServer(t, cfg)
+
+	next := newAPITestConfig()
+	next.Schedule = []config.ScheduleEntry{{Name: "always", Album: "always-album", Start: "01-01", End: "12-31"}}
+	next.Hooks = cfg.Hooks
+	require.NoError(t, srv.Reload(func() (*config.Config, error) { return next, nil }))
+	srv.hooks.Wait()
+
+	require.Len(t, events, 1)
+	ev := <-events
+	assert.Equal(t, config.HookEventTransition, ev.Event)
+	assert.Equal(t, hooks.ReasonReload, ev.Reason)
+	assert.Equal(t, hooks.Selection{Schedule: "default", Album: "default-album-id"}, ev.Previous)
+	assert.Equal(t, hooks.Selection{Schedule: "always", Album: "always-album"}, ev.Current)
+}
+
+func TestAPI_Reevaluate(t *testing.T) {
+	srv := newTestServer(t, newAPITestConfig())
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/reevaluate", nil)
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var body reevaluateResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+	assert.False(t, body.Changed)
+	assert.Equal(t, srv.selectionAt(srv.current(), time.Now()), body.Current)
+}
+
+func TestAPI_ReevaluateDropsCachedRedirect(t *testing.T) {
+	srv := newTestServer(t, newAPITestConfig())
+	st := srv.current()
+
+	// A redirect cached before an out-of-band change.
+	stale, err := st.newRedirectBase("stale-album", nil)
+	require.NoError(t, err)
+	stale.from, stale.until = time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	st.base.Store(stale)
+	assert.Contains(t, redirectTarget(t, srv), "album=stale-album")
+
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/reevaluate", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, redirectTarget(t, srv), "stale-album")
+}
+
+func TestServer_TransitionCooldown(t *testing.T) {
+	cfg := newAPITestConfig()
+	cfg.APITokens = []config.APIToken{{Name: "automation", Token: testAPIToken}}
+	cfg.Schedule = []config.ScheduleEntry{{Name: "always", Album: "always-album", Start: "01-01", End: "12-31"}}
+	cfg.Control.Albums = []config.ControlAlbum{{Name: "Party", Album: "party-album"}}
+	cfg.Transitions.Cooldown = time.Hour
+	srv := newTestServer(t, cfg)
+	suppressed := testutil.ToFloat64(transitionsSuppressedTotal.WithLabelValues(suppressedCooldown))
+
+	// The first transition is not held back.
+	rec := apiRequest(srv, http.MethodPost, "/api/v1/override", `{"album": "party"}`)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.Contains(t, redirectTarget(t, srv), "album=party-album")
+
+	// Returning to the schedule waits for the cooldown.
+	rec = apiRequest(srv, http.MethodDelete, "/api/v1/override", "")
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "override", srv.active.Schedule)
+	assert.Contains(t, redirectTarget(t, srv), "album=party-album")
+	assert.Equal(t, suppressed+1, testutil.ToFloat64(transitionsSuppressedTotal.WithLabelValues(suppressedCooldown)))
+
+	// Changing back to the active selection drops the held change.
+	rec = apiRequest(srv, http.MethodPost, "/api/v1/override", `{"album": "party"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Nil(t, srv.gate.held.Load())
+	assert.Contains(t, redirectTarget(t, srv), "album=party-album")
+}
+
+func TestServer_TransitionDebounce(t *testing.T) {
+	cfg := newAPITestConfig()
+	cfg.Schedule = []config.ScheduleEntry{
+		{Name: "evening", Album: "evening-album", Start: "01-01", End: "12-31", When: `query.mode == "evening"`},
+		{Name: "always", Album: "always-album", Start: "01-01", End: "12-31"},
+	}
+	cfg.Transitions.Debounce = 50 * time.Millisecond
+	srv := newTestServer(t, cfg)
+	srv.active = hooks.Selection{Schedule: "evening", Album: "evening-album"}
+
+	// The schedule changed away from evening, but only shows once the
+	// change lasted for the debounce.
+	assert.Contains(t, redirectTarget(t, srv), "album=evening-album")
+	require.NotNil(t, srv.gate.held.Load())
+	assert.Eventually(t, func() bool {
+		return srv.gate.held.Load() == nil
+	}, time.Second, 10*time.Millisecond)
+	assert.Contains(t, redirectTarget(t, srv), "album=always-album")
+}
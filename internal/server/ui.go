@@ -0,0 +1,273 @@
+package server
+
+import (
+	"bytes"
+	"crypto/rand"
+	"embed"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/rules"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/scheduler"
+)
+
+//go:embed ui/*.html
+var uiFiles embed.FS
+
+// uiFuncs are the functions available to UI templates.
+var uiFuncs = template.FuncMap{
+	"color": scheduleColor,
+}
+
+// uiPages holds one template set per page, each combined with the layout.
+var uiPages = map[string]*template.Template{
+	"calendar": parseUIPage("calendar.html"),
+	"day":      parseUIPage("day.html"),
+	"info":     parseUIPage("info.html"),
+	"stats":    parseUIPage("stats.html"),
+	// The control, guest and display pages are standalone, without the layout.
+	"control": parseStandalonePage("control.html"),
+	"guest":   parseStandalonePage("guest.html"),
+	"message": parseStandalonePage("message.html"),
+	"blank":   parseStandalonePage("blank.html"),
+	"apidocs": parseStandalonePage("apidocs.html"),
+}
+
+// uiPolicy is the content security policy of UI pages; it only allows the
+// page's own style block.
+const uiPolicy = "default-src 'none'; style-src 'nonce-%s'; base-uri 'none'; form-action 'self'; frame-ancestors 'none'"
+
+func parseUIPage(name string) *template.Template {
+	return template.Must(template.New("layout.html").Funcs(uiFuncs).ParseFS(uiFiles, "ui/layout.html", "ui/"+name))
+}
+
+func parseStandalonePage(name string) *template.Template {
+	return template.Must(template.New(name).Funcs(uiFuncs).ParseFS(uiFiles, "ui/"+name))
+}
+
+// scheduleColor returns a distinct color for a schedule entry, or grey for
+// the default album.
+func scheduleColor(idx int) template.CSS {
+	if idx < 0 {
+		return "#e5e7eb"
+	}
+	// Golden-angle hue spacing keeps neighbouring entries distinguishable.
+	return template.CSS(fmt.Sprintf("hsl(%d, 65%%, 55%%)", (idx*137)%360))
+}
+
+// uiView is the data passed to the layout; Page holds the page's own data.
+type uiView struct {
+	Title string
+	// Nonce authorizes the page's style block under the UI content security policy.
+	Nonce string
+	// Entries is the number of schedule entries, for generating their colors.
+	Entries int
+	Page    any
+}
+
+// calendarPage is the data of the year heatmap.
+type calendarPage struct {
+	Year, PrevYear, NextYear int
+	Months                   []calendarMonth
+	Entries                  []calendarEntry
+	DefaultAlbum             string
+	DefaultDays              int
+	Warnings                 []scheduler.Warning
+}
+
+type calendarMonth struct {
+	Name string
+	// Blanks is the number of empty cells before the 1st, as weeks start on Monday.
+	Blanks int
+	Days   []calendarDay
+}
+
+type calendarDay struct {
+	Date  string
+	Title string
+	// Entry is the index of the selected entry, or -1 for the default album.
+	Entry   int
+	Overlap bool
+	Today   bool
+}
+
+type calendarEntry struct {
+	Index        int
+	Name, Album  string
+	SelectedDays int
+}
+
+// dayPage is the data of the day preview.
+type dayPage struct {
+	Date        time.Time
+	Prev, Next  string
+	Schedule    string
+	Album       string
+	AlbumName   string
+	Entry       int
+	RedirectURL string
+	Matches     []dayMatch
+}
+
+type dayMatch struct {
+	Index       int
+	Name, Album string
+	Dates       string
+	Selected    bool
+}
+
+// uiRoutes configures the /ui routes.
+func (s *Server) uiRoutes(r chi.Router) {
+	r.Get("/", s.handleCalendar)
+	r.Get("/day/{date}", s.handleDayPreview)
+	r.Get("/stats", s.handleStatsPage)
+}
+
+// handleCalendar renders a year heatmap colored by the selected schedule.
+func (s *Server) handleCalendar(w http.ResponseWriter, r *http.Request) {
+	st := s.current()
+
+	year := time.Now().Year()
+	if value := r.URL.Query().Get("year"); value != "" {
+		y, err := strconv.Atoi(value)
+		if err != nil || y < 1 || y > 9999 {
+			http.Error(w, "invalid year", http.StatusBadRequest)
+			return
+		}
+		year = y
+	}
+
+	page := calendarPage{
+		Year:         year,
+		PrevYear:     year - 1,
+		NextYear:     year + 1,
+		DefaultAlbum: s.albumLabel(st, st.scheduler.GetDefaultAlbum()),
+		Warnings:     st.scheduler.Warnings(),
+	}
+	for i, e := range st.config.Schedule {
+		page.Entries = append(page.Entries, calendarEntry{Index: i, Name: e.Name, Album: s.albumLabel(st, e.AlbumIDs()...)})
+	}
+
+	today := time.Now().Format(time.DateOnly)
+	for m := time.January; m <= time.December; m++ {
+		first := time.Date(year, m, 1, 0, 0, 0, 0, time.UTC)
+		month := calendarMonth{Name: m.String()[:3], Blanks: (int(first.Weekday()) + 6) % 7}
+
+		for d := first; d.Month() == m; d = d.AddDate(0, 0, 1) {
+			day := calendarDay{Date: d.Format(time.DateOnly), Entry: -1}
+			matches := st.scheduler.GetMatchingSchedulesForDate(d)
+			if len(matches) > 0 {
+				day.Entry = matches[0]
+				page.Entries[day.Entry].SelectedDays++
+			} else {
+				page.DefaultDays++
+			}
+			day.Overlap = len(matches) > 1
+			day.Today = day.Date == today
+			day.Title = dayTitle(day.Date, st.config.Schedule, matches, page.DefaultAlbum)
+			month.Days = append(month.Days, day)
+		}
+		page.Months = append(page.Months, month)
+	}
+
+	s.renderUI(w, http.StatusOK, "calendar", fmt.Sprintf("Schedule %d", year), len(page.Entries), page)
+}
+
+// dayTitle describes a day for the heatmap tooltip.
+func dayTitle(date string, entries []config.ScheduleEntry, matches []int, defaultAlbum string) string {
+	if len(matches) == 0 {
+		return fmt.Sprintf("%s: default (%s)", date, defaultAlbum)
+	}
+	selected := entries[matches[0]]
+	title := fmt.Sprintf("%s: %s (%s)", date, selected.Name, selected.Album)
+	if len(matches) > 1 {
+		names := make([]string, 0, len(matches)-1)
+		for _, idx := range matches[1:] {
+			names = append(names, entries[idx].Name)
+		}
+		title += "; overrides " + strings.Join(names, ", ")
+	}
+	return title
+}
+
+// handleDayPreview shows what kiosks are served on a given date. Query
+// parameters are passed through to the redirect URL as they are by /.
+func (s *Server) handleDayPreview(w http.ResponseWriter, r *http.Request) {
+	st := s.current()
+
+	date, err := time.Parse(time.DateOnly, chi.URLParam(r, "date"))
+	if err != nil {
+		http.Error(w, "invalid date, expected YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+
+	page := dayPage{
+		Date:  date,
+		Prev:  date.AddDate(0, 0, -1).Format(time.DateOnly),
+		Next:  date.AddDate(0, 0, 1).Format(time.DateOnly),
+		Entry: -1,
+	}
+	// When conditions see the preview's query parameters, as they would on /.
+	q := r.URL.Query()
+	d := st.scheduler.ResolveEnv(rules.Env{Time: date, Device: q.Get(deviceParam), Query: q})
+	page.Schedule, page.Album, page.AlbumName = d.Schedule, d.Album, s.albumName(st, d.Album)
+	for _, idx := range st.scheduler.GetMatchingSchedulesForDate(date) {
+		e := st.config.Schedule[idx]
+		selected := d.Entry != nil && e.Name == d.Schedule
+		if selected {
+			page.Entry = idx
+		}
+		page.Matches = append(page.Matches, dayMatch{
+			Index: idx, Name: e.Name, Album: s.albumLabel(st, e.AlbumIDs()...), Dates: e.Dates(),
+			Selected: selected,
+		})
+	}
+
+	page.RedirectURL, err = st.buildRedirectURL(r, page.Album, d.Entry, nil)
+	if err != nil {
+		s.logger.Error("failed to build redirect URL", slog.Any("error", err))
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	s.renderUI(w, http.StatusOK, "day", date.Format("Monday, 2 January 2006"), len(st.config.Schedule), page)
+}
+
+// renderUI renders a built-in UI page.
+func (s *Server) renderUI(w http.ResponseWriter, status int, name, title string, entries int, page any) {
+	s.renderTemplate(w, status, uiPages[name], uiPolicy, uiView{Title: title, Entries: entries, Page: page})
+}
+
+// renderTemplate renders a page with a fresh nonce under the content
+// security policy, whose %s is replaced by the nonce.
+func (s *Server) renderTemplate(w http.ResponseWriter, status int, tmpl *template.Template, policy string, view uiView) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		s.logger.Error("failed to generate nonce", slog.Any("error", err))
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	view.Nonce = base64.StdEncoding.EncodeToString(nonce)
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, view); err != nil {
+		s.logger.Error("failed to render page", slog.String("page", tmpl.Name()), slog.Any("error", err))
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Security-Policy", fmt.Sprintf(policy, view.Nonce))
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_, _ = buf.WriteTo(w)
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+)
+
+func TestUI_Calendar(t *testing.T) {
+	cfg := newAPITestConfig()
+	cfg.Schedule = append(cfg.Schedule, config.ScheduleEntry{Name: "advent", Album: "advent-album", Start: "12-01", End: "12-24"})
+	srv := newTestServer(t, cfg)
+
+	for _, target := range []string{"/ui", "/ui/", "/ui/?year=2024"} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		rec := httptest.NewRecorder()
+		srv.router.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code, target)
+		assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+		assert.Contains(t, rec.Header().Get("Content-Security-Policy"), "style-src 'nonce-")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/ui/?year=2024", nil)
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, req)
+	body := rec.Body.String()
+	assert.Contains(t, body, `href="/ui/day/2024-02-29"`)
+	assert.Contains(t, body, `title="2024-12-10: christmas (christmas-album); overrides advent"`)
+	assert.Contains(t, body, `title="2024-06-01: default (default-album-id)"`)
+
+	req = httptest.NewRequest(http.MethodGet, "/ui/?year=abc", nil)
+	rec = httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestUI_DayPreview(t *testing.T) {
+	srv := newTestServer(t, newAPITestConfig())
+
+	req := httptest.NewRequest(http.MethodGet, "/ui/day/2024-12-25", nil)
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	body := rec.Body.String()
+	assert.Contains(t, body, "Wednesday, 25 December 2024")
+	assert.Contains(t, body, "<strong>christmas</strong>")
+	assert.Contains(t, body, "https://kiosk.example.com?album=christmas-album")
+
+	req = httptest.NewRequest(http.MethodGet, "/ui/day/2024-13-01", nil)
+	rec = httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/lint"
+)
+
+// validateResponse is the body of POST /api/v1/validate.
+type validateResponse struct {
+	// Valid is false when any finding is an error. Warnings are counted
+	// separately so callers can decide whether they fail a pipeline.
+	Valid    bool           `json:"valid"`
+	Errors   int            `json:"errors"`
+	Warnings int            `json:"warnings"`
+	Info     int            `json:"info"`
+	Findings []lint.Finding `json:"findings"`
+}
+
+// handleValidate lints a candidate configuration without applying it. The
+// body is either a full configuration (JSON or YAML, by Content-Type) or a
+// JSON array of schedule entries, which is checked against the rest of the
+// running configuration.
+func (s *Server) handleValidate(w http.ResponseWriter, r *http.Request) {
+	format, err := requestFormat(r)
+	if err != nil {
+		writeError(w, http.StatusUnsupportedMediaType, err.Error())
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("failed to read request body: %v", err))
+		return
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		writeError(w, http.StatusBadRequest, "request body is empty")
+		return
+	}
+
+	var cfg *config.Config
+	if format == "json" && data[0] == '[' {
+		var schedule []config.ScheduleEntry
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err = dec.Decode(&schedule); err == nil {
+			st := s.current()
+			cfg = st.config.Clone()
+			cfg.Schedule = schedule
+			// The list replaces the active profile's schedule.
+			cfg = cfg.WithoutProfile(st.profile)
+		} else {
+			err = fmt.Errorf("invalid schedule list: %w", err)
+		}
+	} else {
+		cfg, err = config.LoadBytes(data, format)
+	}
+
+	var findings []lint.Finding
+	if err != nil {
+		findings = []lint.Finding{{Rule: lint.RuleInvalidConfig, Severity: lint.SeverityError, Message: err.Error()}}
+	} else {
+		findings = lint.Check(cfg)
+	}
+
+	errors := lint.Count(findings, lint.SeverityError)
+	writeJSON(w, http.StatusOK, validateResponse{
+		Valid:    errors == 0,
+		Errors:   errors,
+		Warnings: lint.Count(findings, lint.SeverityWarning),
+		Info:     lint.Count(findings, lint.SeverityInfo),
+		Findings: findings,
+	})
+}
+
+// requestFormat maps the request Content-Type to a config format. A missing
+// Content-Type is treated as JSON.
+func requestFormat(r *http.Request) (string, error) {
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		return "json", nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", fmt.Errorf("invalid Content-Type: %w", err)
+	}
+	switch mediaType {
+	case "application/json":
+		return "json", nil
+	case "application/yaml", "application/x-yaml", "text/yaml", "text/x-yaml":
+		return "yaml", nil
+	default:
+		return "", fmt.Errorf("unsupported Content-Type %q (use application/json or application/yaml)", mediaType)
+	}
+}
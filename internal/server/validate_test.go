@@ -0,0 +1,95 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/lint"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPI_Validate(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantValid   bool
+		wantRule    string
+	}{
+		{
+			name:        "valid json config",
+			contentType: "application/json",
+			body:        `{"kiosk_url": "https://kiosk.example.com", "default_album": "default", "schedule": [{"name": "xmas", "album": "a", "start": "12-01", "end": "12-31"}]}`,
+			wantValid:   true,
+		},
+		{
+			name:        "yaml config missing kiosk url",
+			contentType: "application/yaml",
+			body:        "default_album: default\nschedule: []\n",
+			wantValid:   false,
+			wantRule:    lint.RuleInvalidConfig,
+		},
+		{
+			name:        "schedule list with duplicate names",
+			contentType: "application/json",
+			body:        `[{"name": "x", "album": "a", "start": "01-01", "end": "01-31"}, {"name": "x", "album": "b", "start": "02-01", "end": "02-28"}]`,
+			wantValid:   false,
+			wantRule:    lint.RuleDuplicateName,
+		},
+		{
+			name:      "schedule list with unknown field",
+			body:      `[{"name": "x", "album": "a", "start": "01-01", "end": "01-31", "colour": "red"}]`,
+			wantValid: false,
+			wantRule:  lint.RuleInvalidConfig,
+		},
+		{
+			name:      "valid schedule list",
+			body:      `[{"name": "summer", "album": "s", "start": "06-01", "end": "08-31"}]`,
+			wantValid: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestServer(t, newAPITestConfig())
+			before := srv.current().revision
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/validate", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rec := httptest.NewRecorder()
+			srv.router.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+			var body validateResponse
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+			assert.Equal(t, tt.wantValid, body.Valid)
+			if tt.wantRule != "" {
+				require.NotEmpty(t, body.Findings)
+				assert.Equal(t, tt.wantRule, body.Findings[0].Rule)
+			}
+			// Nothing is applied
+			assert.Equal(t, before, srv.current().revision)
+		})
+	}
+}
+
+func TestAPI_ValidateBadRequest(t *testing.T) {
+	srv := newTestServer(t, newAPITestConfig())
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/validate", strings.NewReader("kiosk_url = x"))
+	req.Header.Set("Content-Type", "text/plain")
+	rec := httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/validate", strings.NewReader("  "))
+	rec = httptest.NewRecorder()
+	srv.router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -0,0 +1,183 @@
+// Package state persists runtime state, such as the audit log, in a
+// directory so it survives restarts. Without a directory, state is kept in
+// memory only.
+package state
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Store appends records to named logs and saves named documents.
+type Store struct {
+	dir string
+
+	mu   sync.Mutex
+	logs map[string][][]byte // in-memory logs when dir is empty
+	docs map[string][]byte   // in-memory documents when dir is empty
+}
+
+// Open returns a store writing to dir, creating it if needed. An empty dir
+// keeps state in memory.
+func Open(dir string) (*Store, error) {
+	s := &Store{dir: dir, logs: map[string][][]byte{}, docs: map[string][]byte{}}
+	if dir == "" {
+		return s, nil
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create state directory: %w", err)
+	}
+	return s, nil
+}
+
+// Persistent reports whether the store writes to disk.
+func (s *Store) Persistent() bool {
+	return s.dir != ""
+}
+
+// Append adds a record to the named log.
+func (s *Store) Append(log string, record any) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.dir == "" {
+		s.logs[log] = append(s.logs[log], data)
+		return nil
+	}
+	f, err := os.OpenFile(s.path(log+".jsonl"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Records calls fn with each record of the named log, oldest first. A
+// missing log has no records.
+func (s *Store) Records(log string, fn func(json.RawMessage) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.dir == "" {
+		for _, data := range s.logs[log] {
+			if err := fn(data); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	f, err := os.Open(s.path(log + ".jsonl"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if err := fn(json.RawMessage(line)); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// Save replaces the named document.
+func (s *Store) Save(name string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.dir == "" {
+		s.docs[name] = data
+		return nil
+	}
+	// Write and rename so a crash never leaves a partial document.
+	tmp, err := os.CreateTemp(s.dir, name+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path(name+".json"))
+}
+
+// Load reads the named document into v, reporting false if it does not exist.
+func (s *Store) Load(name string, v any) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var data []byte
+	if s.dir == "" {
+		var ok bool
+		if data, ok = s.docs[name]; !ok {
+			return false, nil
+		}
+	} else {
+		var err error
+		data, err = os.ReadFile(s.path(name + ".json"))
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("failed to decode %s: %w", name, err)
+	}
+	return true, nil
+}
+
+func (s *Store) path(file string) string {
+	return filepath.Join(s.dir, file)
+}
+
+// Check verifies that the store can write to its directory.
+func (s *Store) Check() error {
+	if s.dir == "" {
+		return nil
+	}
+	f, err := os.CreateTemp(s.dir, ".check-*")
+	if err != nil {
+		return err
+	}
+	name := f.Name()
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Remove(name)
+}
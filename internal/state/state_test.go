@@ -0,0 +1,93 @@
+package state
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type record struct {
+	N int `json:"n"`
+}
+
+func readAll(t *testing.T, s *Store, log string) []record {
+	t.Helper()
+	var records []record
+	require.NoError(t, s.Records(log, func(data json.RawMessage) error {
+		var r record
+		if err := json.Unmarshal(data, &r); err != nil {
+			return err
+		}
+		records = append(records, r)
+		return nil
+	}))
+	return records
+}
+
+func TestStore(t *testing.T) {
+	for name, dir := range map[string]string{"memory": "", "disk": filepath.Join(t.TempDir(), "state")} {
+		t.Run(name, func(t *testing.T) {
+			s, err := Open(dir)
+			require.NoError(t, err)
+			assert.Equal(t, dir != "", s.Persistent())
+
+			assert.Empty(t, readAll(t, s, "events"))
+			require.NoError(t, s.Append("events", record{1}))
+			require.NoError(t, s.Append("events", record{2}))
+			assert.Equal(t, []record{{1}, {2}}, readAll(t, s, "events"))
+
+			var doc record
+			ok, err := s.Load("doc", &doc)
+			require.NoError(t, err)
+			assert.False(t, ok)
+			require.NoError(t, s.Save("doc", record{7}))
+			ok, err = s.Load("doc", &doc)
+			require.NoError(t, err)
+			assert.True(t, ok)
+			assert.Equal(t, 7, doc.N)
+		})
+	}
+}
+
+func TestStore_SurvivesReopen(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir)
+	require.NoError(t, err)
+	require.NoError(t, s.Append("events", record{1}))
+	require.NoError(t, s.Save("doc", record{2}))
+
+	s, err = Open(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []record{{1}}, readAll(t, s, "events"))
+	var doc record
+	ok, err := s.Load("doc", &doc)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 2, doc.N)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2, "no temporary files are left behind")
+}
+
+func TestStore_Check(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "state")
+	s, err := Open(dir)
+	require.NoError(t, err)
+	require.NoError(t, s.Check())
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "the check file is removed")
+
+	require.NoError(t, os.RemoveAll(dir))
+	assert.Error(t, s.Check())
+
+	memory, err := Open("")
+	require.NoError(t, err)
+	assert.NoError(t, memory.Check())
+}
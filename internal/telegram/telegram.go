@@ -0,0 +1,207 @@
+// Package telegram is a Telegram bot answering schedule commands such as
+// /status and /override from allowed chats, using long polling.
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/tracing"
+)
+
+// Retry delays after a failed poll.
+const (
+	minRetryDelay = time.Second
+	maxRetryDelay = time.Minute
+)
+
+// pollTimeout is how long Telegram holds a poll open waiting for messages.
+const pollTimeout = 50 * time.Second
+
+// requestTimeout bounds requests other than polls.
+const requestTimeout = 10 * time.Second
+
+// maxResponseSize bounds a Bot API response.
+const maxResponseSize = 1 << 20
+
+// Telegram metrics
+var commandsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "immich_kiosk_scheduler_telegram_commands_total",
+		Help: "Total number of Telegram commands by result",
+	},
+	[]string{"result"},
+)
+
+func init() {
+	prometheus.MustRegister(commandsTotal)
+}
+
+// Handler runs a command on behalf of actor and returns the reply.
+type Handler func(actor, text string) string
+
+// Bot answers commands sent to it in allowed chats.
+type Bot struct {
+	cfg     config.TelegramConfig
+	client  *http.Client
+	handler Handler
+	logger  *slog.Logger
+
+	// offset acknowledges the updates handled so far.
+	offset int64
+}
+
+// New creates a Bot.
+func New(cfg config.TelegramConfig, handler Handler, logger *slog.Logger) *Bot {
+	return &Bot{
+		cfg:     cfg,
+		client:  &http.Client{Transport: tracing.NewTransport(nil)},
+		handler: handler,
+		logger:  logger,
+	}
+}
+
+// update is a Bot API update; only messages are requested.
+type update struct {
+	ID      int64    `json:"update_id"`
+	Message *message `json:"message"`
+}
+
+type message struct {
+	ID   int64 `json:"message_id"`
+	Chat struct {
+		ID int64 `json:"id"`
+	} `json:"chat"`
+	Text string `json:"text"`
+}
+
+// response is the envelope of Bot API responses.
+type response struct {
+	OK          bool            `json:"ok"`
+	Description string          `json:"description"`
+	Result      json.RawMessage `json:"result"`
+}
+
+// Run polls for commands until the context is cancelled, retrying failed
+// polls with a growing delay.
+func (b *Bot) Run(ctx context.Context) {
+	b.logger.Info("telegram bot started", slog.Int("allowed_chats", len(b.cfg.AllowedChats)))
+	delay := minRetryDelay
+	for {
+		err := b.poll(ctx, pollTimeout)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			delay = minRetryDelay
+			continue
+		}
+		b.logger.Warn("telegram poll failed", slog.Any("error", err), slog.Duration("retry_in", delay))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxRetryDelay)
+	}
+}
+
+// poll fetches pending updates, waiting up to timeout for new ones, and
+// answers the commands among them.
+func (b *Bot) poll(ctx context.Context, timeout time.Duration) error {
+	params := url.Values{
+		"offset":          {strconv.FormatInt(b.offset, 10)},
+		"timeout":         {strconv.Itoa(int(timeout.Seconds()))},
+		"allowed_updates": {`["message"]`},
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout+requestTimeout)
+	defer cancel()
+	var updates []update
+	if err := b.call(ctx, "getUpdates", params, &updates); err != nil {
+		return err
+	}
+	for _, u := range updates {
+		b.offset = max(b.offset, u.ID+1)
+		if u.Message != nil {
+			b.handle(ctx, u.Message)
+		}
+	}
+	return nil
+}
+
+// handle answers a message if it is a command from an allowed chat.
+func (b *Bot) handle(ctx context.Context, msg *message) {
+	if !strings.HasPrefix(msg.Text, "/") {
+		return
+	}
+	chat := msg.Chat.ID
+	if !b.cfg.Allowed(chat) {
+		commandsTotal.WithLabelValues("denied").Inc()
+		// Logged so the chat ID can be found and allowed.
+		b.logger.Warn("telegram command from a chat that is not allowed", slog.Int64("chat", chat))
+		return
+	}
+	command, _, _ := strings.Cut(msg.Text, " ")
+	b.logger.Info("telegram command", slog.Int64("chat", chat), slog.String("command", command))
+	reply := b.handler("telegram:"+strconv.FormatInt(chat, 10), msg.Text)
+	commandsTotal.WithLabelValues("handled").Inc()
+
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	params := url.Values{
+		"chat_id":             {strconv.FormatInt(chat, 10)},
+		"text":                {reply},
+		"reply_to_message_id": {strconv.FormatInt(msg.ID, 10)},
+	}
+	if err := b.call(ctx, "sendMessage", params, nil); err != nil {
+		b.logger.Warn("telegram reply failed", slog.Int64("chat", chat), slog.Any("error", err))
+	}
+}
+
+// call invokes a Bot API method and decodes its result into v, if not nil.
+func (b *Bot) call(ctx context.Context, method string, params url.Values, v any) error {
+	endpoint := b.cfg.APIURL() + "/bot" + b.cfg.Token + "/" + method
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewBufferString(params.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", "immich-kiosk-scheduler")
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		// The error includes the URL, which holds the token.
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			return fmt.Errorf("%s: %w", method, uerr.Err)
+		}
+		return err
+	}
+	defer resp.Body.Close()
+
+	var r response
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&r); err != nil {
+		return fmt.Errorf("%s: unexpected response %s: %w", method, resp.Status, err)
+	}
+	if !r.OK {
+		return fmt.Errorf("%s: %s", method, r.Description)
+	}
+	if v == nil {
+		return nil
+	}
+	return json.Unmarshal(r.Result, v)
+}
@@ -0,0 +1,88 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+)
+
+// fakeAPI is a Bot API server returning queued updates once.
+type fakeAPI struct {
+	mu      sync.Mutex
+	updates string
+	polls   []url.Values
+	replies []url.Values
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.URL.Path {
+	case "/bottest-token/getUpdates":
+		f.polls = append(f.polls, r.PostForm)
+		fmt.Fprintf(w, `{"ok": true, "result": [%s]}`, f.updates)
+		f.updates = ""
+	case "/bottest-token/sendMessage":
+		f.replies = append(f.replies, r.PostForm)
+		fmt.Fprint(w, `{"ok": true, "result": {}}`)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"ok": false, "description": "Not Found"}`)
+	}
+}
+
+func TestBot_Poll(t *testing.T) {
+	api := &fakeAPI{updates: `
+		{"update_id": 7, "message": {"message_id": 1, "chat": {"id": 42}, "text": "/override garden 2h"}},
+		{"update_id": 8, "message": {"message_id": 2, "chat": {"id": 99}, "text": "/clear"}},
+		{"update_id": 9, "message": {"message_id": 3, "chat": {"id": 42}, "text": "thanks!"}}`}
+	ts := httptest.NewServer(api)
+	defer ts.Close()
+
+	var commands []string
+	bot := New(config.TelegramConfig{Token: "test-token", AllowedChats: []int64{42}, URL: ts.URL},
+		func(actor, text string) string {
+			commands = append(commands, actor+" "+text)
+			return "Showing garden until 18:00."
+		}, slog.Default())
+
+	require.NoError(t, bot.poll(context.Background(), 0))
+	require.NoError(t, bot.poll(context.Background(), 0))
+
+	assert.Equal(t, []string{"telegram:42 /override garden 2h"}, commands, "only commands from allowed chats")
+	require.Len(t, api.replies, 1)
+	assert.Equal(t, "42", api.replies[0].Get("chat_id"))
+	assert.Equal(t, "1", api.replies[0].Get("reply_to_message_id"))
+	assert.Equal(t, "Showing garden until 18:00.", api.replies[0].Get("text"))
+
+	require.Len(t, api.polls, 2)
+	assert.Equal(t, "0", api.polls[0].Get("offset"))
+	assert.Equal(t, "10", api.polls[1].Get("offset"), "handled updates are acknowledged")
+}
+
+func TestBot_CallError(t *testing.T) {
+	ts := httptest.NewServer(&fakeAPI{})
+	defer ts.Close()
+
+	bot := New(config.TelegramConfig{Token: "wrong-token", AllowedChats: []int64{42}, URL: ts.URL}, nil, slog.Default())
+	err := bot.poll(context.Background(), 0)
+	require.Error(t, err)
+	assert.Equal(t, "getUpdates: Not Found", err.Error())
+
+	// Network errors do not leak the token in the URL.
+	ts.Close()
+	err = bot.poll(context.Background(), 0)
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "wrong-token")
+}
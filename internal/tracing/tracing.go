@@ -0,0 +1,186 @@
+// Package tracing implements W3C Trace Context propagation for incoming and outgoing HTTP requests.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// Header names defined by the W3C Trace Context specification.
+const (
+	TraceparentHeader = "traceparent"
+	TracestateHeader  = "tracestate"
+)
+
+// flagSampled is the trace-flags bit indicating the caller may have recorded the trace.
+const flagSampled = 0x01
+
+// SpanContext identifies a span within a distributed trace.
+type SpanContext struct {
+	TraceID    [16]byte
+	SpanID     [8]byte
+	Flags      byte
+	TraceState string
+}
+
+// spanKey is the context key for the current span.
+type spanKey struct{}
+
+// NewRoot starts a new sampled trace with random identifiers.
+func NewRoot() SpanContext {
+	var sc SpanContext
+	_, _ = rand.Read(sc.TraceID[:])
+	_, _ = rand.Read(sc.SpanID[:])
+	sc.Flags = flagSampled
+	return sc
+}
+
+// Child returns a new span in the same trace, inheriting flags and trace state.
+func (sc SpanContext) Child() SpanContext {
+	child := sc
+	_, _ = rand.Read(child.SpanID[:])
+	return child
+}
+
+// TraceIDString returns the trace ID as lowercase hex.
+func (sc SpanContext) TraceIDString() string {
+	return hex.EncodeToString(sc.TraceID[:])
+}
+
+// SpanIDString returns the span ID as lowercase hex.
+func (sc SpanContext) SpanIDString() string {
+	return hex.EncodeToString(sc.SpanID[:])
+}
+
+// Traceparent formats the span as a version 00 traceparent header value.
+func (sc SpanContext) Traceparent() string {
+	return "00-" + sc.TraceIDString() + "-" + sc.SpanIDString() + "-" + hex.EncodeToString([]byte{sc.Flags})
+}
+
+// Parse parses a traceparent header value. It returns false for malformed
+// values and for the all-zero trace or span IDs, which the spec forbids.
+func Parse(traceparent string) (SpanContext, bool) {
+	var sc SpanContext
+
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 {
+		return sc, false
+	}
+	version := parts[0]
+	if len(version) != 2 || !isLowerHex(version) || version == "ff" {
+		return sc, false
+	}
+	// Version 00 has exactly four fields; future versions may append more.
+	if version == "00" && len(parts) != 4 {
+		return sc, false
+	}
+
+	if !decodeField(parts[1], sc.TraceID[:]) || !decodeField(parts[2], sc.SpanID[:]) {
+		return sc, false
+	}
+	var flags [1]byte
+	if !decodeField(parts[3], flags[:]) {
+		return sc, false
+	}
+	sc.Flags = flags[0]
+
+	if sc.TraceID == [16]byte{} || sc.SpanID == [8]byte{} {
+		return sc, false
+	}
+	return sc, true
+}
+
+// decodeField decodes a fixed-length lowercase hex field into dst.
+func decodeField(field string, dst []byte) bool {
+	if len(field) != hex.EncodedLen(len(dst)) || !isLowerHex(field) {
+		return false
+	}
+	_, err := hex.Decode(dst, []byte(field))
+	return err == nil
+}
+
+// isLowerHex reports whether s only contains lowercase hexadecimal digits.
+func isLowerHex(s string) bool {
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// Extract reads the span context from request headers.
+func Extract(h http.Header) (SpanContext, bool) {
+	sc, ok := Parse(h.Get(TraceparentHeader))
+	if !ok {
+		return sc, false
+	}
+	sc.TraceState = h.Get(TracestateHeader)
+	return sc, true
+}
+
+// Inject writes the span context to request headers.
+func Inject(sc SpanContext, h http.Header) {
+	h.Set(TraceparentHeader, sc.Traceparent())
+	if sc.TraceState != "" {
+		h.Set(TracestateHeader, sc.TraceState)
+	} else {
+		h.Del(TracestateHeader)
+	}
+}
+
+// ContextWithSpan returns a context carrying the span.
+func ContextWithSpan(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanKey{}, sc)
+}
+
+// SpanFromContext returns the span carried by the context, if any.
+func SpanFromContext(ctx context.Context) (SpanContext, bool) {
+	sc, ok := ctx.Value(spanKey{}).(SpanContext)
+	return sc, ok
+}
+
+// Middleware continues the caller's trace (or starts a new one) for every
+// request, making the server span available through SpanFromContext.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sc, ok := Extract(r.Header)
+		if ok {
+			sc = sc.Child()
+		} else {
+			sc = NewRoot()
+		}
+		next.ServeHTTP(w, r.WithContext(ContextWithSpan(r.Context(), sc)))
+	})
+}
+
+// Transport is an http.RoundTripper that propagates the trace from the
+// request context to outgoing requests. Requests without a span in their
+// context (for example when tracing is disabled) are sent unchanged.
+type Transport struct {
+	Base http.RoundTripper
+}
+
+// NewTransport wraps base (or http.DefaultTransport when nil).
+func NewTransport(base http.RoundTripper) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{Base: base}
+}
+
+// RoundTrip injects a child span into the request headers and sends it.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	sc, ok := SpanFromContext(req.Context())
+	if !ok {
+		return t.Base.RoundTrip(req)
+	}
+
+	// RoundTrippers must not modify the caller's request.
+	out := req.Clone(req.Context())
+	Inject(sc.Child(), out.Header)
+	return t.Base.RoundTrip(out)
+}
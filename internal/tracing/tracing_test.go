@@ -0,0 +1,122 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const validTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		valid bool
+	}{
+		{"valid", validTraceparent, true},
+		{"future version with extra field", "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", true},
+		{"empty", "", false},
+		{"uppercase hex", "00-4BF92F3577B34DA6A3CE929D0E0E4736-00F067AA0BA902B7-01", false},
+		{"zero trace id", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", false},
+		{"zero span id", "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false},
+		{"invalid version", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"short trace id", "00-4bf92f3577b34da6-00f067aa0ba902b7-01", false},
+		{"version 00 with extra field", validTraceparent + "-extra", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, ok := Parse(tt.input)
+			assert.Equal(t, tt.valid, ok)
+		})
+	}
+}
+
+func TestSpanContext_RoundTrip(t *testing.T) {
+	sc, ok := Parse(validTraceparent)
+	require.True(t, ok)
+	assert.Equal(t, validTraceparent, sc.Traceparent())
+
+	child := sc.Child()
+	assert.Equal(t, sc.TraceID, child.TraceID)
+	assert.NotEqual(t, sc.SpanID, child.SpanID)
+	assert.Equal(t, sc.Flags, child.Flags)
+}
+
+func TestMiddleware_ContinuesTrace(t *testing.T) {
+	var got SpanContext
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ok bool
+		got, ok = SpanFromContext(r.Context())
+		require.True(t, ok)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(TraceparentHeader, validTraceparent)
+	req.Header.Set(TracestateHeader, "vendor=value")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", got.TraceIDString())
+	assert.NotEqual(t, "00f067aa0ba902b7", got.SpanIDString())
+	assert.Equal(t, "vendor=value", got.TraceState)
+}
+
+func TestMiddleware_StartsTrace(t *testing.T) {
+	var got SpanContext
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = SpanFromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(TraceparentHeader, "garbage")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	_, ok := Parse(got.Traceparent())
+	assert.True(t, ok)
+}
+
+func TestTransport_InjectsTraceparent(t *testing.T) {
+	var received http.Header
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+	}))
+	defer upstream.Close()
+
+	client := &http.Client{Transport: NewTransport(nil)}
+	sc, _ := Parse(validTraceparent)
+	sc.TraceState = "vendor=value"
+
+	req, err := http.NewRequestWithContext(ContextWithSpan(context.Background(), sc), http.MethodGet, upstream.URL, nil)
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	outgoing, ok := Parse(received.Get(TraceparentHeader))
+	require.True(t, ok)
+	assert.Equal(t, sc.TraceID, outgoing.TraceID)
+	assert.NotEqual(t, sc.SpanID, outgoing.SpanID)
+	assert.Equal(t, "vendor=value", received.Get(TracestateHeader))
+	// The caller's request is left untouched
+	assert.Empty(t, req.Header.Get(TraceparentHeader))
+}
+
+func TestTransport_NoSpan(t *testing.T) {
+	var received http.Header
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+	}))
+	defer upstream.Close()
+
+	client := &http.Client{Transport: NewTransport(nil)}
+	resp, err := client.Get(upstream.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Empty(t, received.Get(TraceparentHeader))
+}
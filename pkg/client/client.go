@@ -0,0 +1,408 @@
+// Package client is a Go client for the immich-kiosk-scheduler HTTP API
+// (/api/v1). Types mirror the JSON the server returns; fields a client
+// rarely needs are left out and ignored when decoding.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client calls the API of one scheduler.
+type Client struct {
+	baseURL string
+	token   string
+
+	// HTTPClient sends the requests; http.DefaultClient when nil.
+	HTTPClient *http.Client
+}
+
+// New returns a client for the scheduler at baseURL, e.g.
+// http://scheduler:8080. token is an API token from api_tokens; it may be
+// empty when only reading public endpoints.
+func New(baseURL, token string) *Client {
+	return &Client{baseURL: strings.TrimSuffix(baseURL, "/"), token: token}
+}
+
+// Error is an error response from the API.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// IsNotFound reports whether err is a 404 response from the API.
+func IsNotFound(err error) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// ScheduleEntry is a schedule entry.
+type ScheduleEntry struct {
+	Name          string            `json:"name"`
+	Album         string            `json:"album"`
+	Start         string            `json:"start"` // Format: MM-DD
+	End           string            `json:"end"`   // Format: MM-DD
+	EndBoundary   string            `json:"end_boundary,omitempty"`
+	RRule         string            `json:"rrule,omitempty"`
+	Days          int               `json:"days,omitempty"`
+	Params        map[string]string `json:"params,omitempty"`
+	RemoveParams  []string          `json:"remove_params,omitempty"`
+	When          string            `json:"when,omitempty"`
+	Albums        []string          `json:"albums,omitempty"`
+	RotateMinutes int               `json:"rotate_minutes,omitempty"`
+	MinDuration   string            `json:"min_duration,omitempty"`
+	Discovered    bool              `json:"discovered,omitempty"`
+}
+
+// Warning is a schedule warning, such as overlapping entries.
+type Warning struct {
+	Kind    string   `json:"kind"`
+	Message string   `json:"message"`
+	Entries []string `json:"entries,omitempty"`
+	Start   string   `json:"start"`
+	End     string   `json:"end"`
+}
+
+// Override is a temporary album override, such as a running party mode.
+type Override struct {
+	Mode   string            `json:"mode"`
+	Name   string            `json:"name"`
+	Album  string            `json:"album,omitempty"`
+	Params map[string]string `json:"params,omitempty"`
+	// Until is zero for a freeze, which lasts until released.
+	Until time.Time `json:"until,omitzero"`
+}
+
+// Status is the current selection of the scheduler.
+type Status struct {
+	Schedule         string    `json:"schedule"`
+	Album            string    `json:"album"`
+	AlbumName        string    `json:"album_name,omitempty"`
+	DefaultAlbum     string    `json:"default_album"`
+	DefaultAlbumName string    `json:"default_album_name,omitempty"`
+	ScheduleCount    int       `json:"schedule_count"`
+	ConfigRevision   string    `json:"config_revision"`
+	Warnings         []Warning `json:"warnings"`
+	Override         *Override `json:"override,omitempty"`
+	Maintenance      bool      `json:"maintenance"`
+	Profile          string    `json:"profile,omitempty"`
+}
+
+// Schedules is the schedule list in evaluation order.
+type Schedules struct {
+	Revision     string          `json:"revision"`
+	DefaultAlbum string          `json:"default_album"`
+	Schedules    []ScheduleEntry `json:"schedules"`
+}
+
+// Schedule is a single schedule entry and its position in evaluation order.
+type Schedule struct {
+	Revision string        `json:"revision"`
+	Position int           `json:"position"`
+	Schedule ScheduleEntry `json:"schedule"`
+	Warnings []Warning     `json:"warnings"`
+}
+
+// Selection is a schedule and the album it shows.
+type Selection struct {
+	Schedule string `json:"schedule"`
+	Album    string `json:"album"`
+}
+
+// Reevaluation is the result of Reevaluate.
+type Reevaluation struct {
+	Changed  bool      `json:"changed"`
+	Previous Selection `json:"previous"`
+	Current  Selection `json:"current"`
+}
+
+// Party lists the party modes and the running one, if any.
+type Party struct {
+	Modes  []string  `json:"modes"`
+	Active *Override `json:"active"`
+}
+
+// Transition is an upcoming schedule change.
+type Transition struct {
+	At        time.Time `json:"at"`
+	Schedule  string    `json:"schedule"`
+	Album     string    `json:"album"`
+	AlbumName string    `json:"album_name,omitempty"`
+}
+
+// Maintenance is the maintenance mode state.
+type Maintenance struct {
+	Enabled    bool   `json:"enabled"`
+	Message    string `json:"message"`
+	RetryAfter string `json:"retry_after"`
+}
+
+// Device is a registered device and its last redirect.
+type Device struct {
+	ID           string     `json:"id"`
+	Name         string     `json:"name"`
+	Profile      string     `json:"profile,omitempty"`
+	StaleAfter   string     `json:"stale_after,omitempty"`
+	RegisteredAt time.Time  `json:"registered_at"`
+	LastSeen     *time.Time `json:"last_seen,omitempty"`
+	Album        string     `json:"album,omitempty"`
+	AlbumName    string     `json:"album_name,omitempty"`
+	Schedule     string     `json:"schedule,omitempty"`
+	UserAgent    string     `json:"user_agent,omitempty"`
+	Stale        bool       `json:"stale"`
+}
+
+// DeviceRegistration registers or updates a device.
+type DeviceRegistration struct {
+	ID         string `json:"id"`
+	Name       string `json:"name,omitempty"`
+	Profile    string `json:"profile,omitempty"`
+	StaleAfter string `json:"stale_after,omitempty"`
+}
+
+// DayStats are the redirects and active seconds per schedule on a day.
+type DayStats struct {
+	Date          string             `json:"date"`
+	Redirects     map[string]int64   `json:"redirects"`
+	ActiveSeconds map[string]float64 `json:"active_seconds"`
+}
+
+// Stats are the daily statistics of a period, oldest day first.
+type Stats struct {
+	RetentionDays int        `json:"retention_days"`
+	Days          []DayStats `json:"days"`
+	Totals        struct {
+		Redirects     map[string]int64   `json:"redirects"`
+		ActiveSeconds map[string]float64 `json:"active_seconds"`
+	} `json:"totals"`
+}
+
+// Status returns the current schedule, album and override.
+func (c *Client) Status(ctx context.Context) (*Status, error) {
+	var status Status
+	return &status, c.do(ctx, http.MethodGet, "/status", nil, &status)
+}
+
+// Reevaluate recomputes the active schedule now.
+func (c *Client) Reevaluate(ctx context.Context) (*Reevaluation, error) {
+	var result Reevaluation
+	return &result, c.do(ctx, http.MethodPost, "/reevaluate", nil, &result)
+}
+
+// Transitions returns the next count schedule changes, read through the
+// GraphQL endpoint.
+func (c *Client) Transitions(ctx context.Context, count int) ([]Transition, error) {
+	req := map[string]any{
+		"query":     "query($n: Int) { transitions(count: $n) { at schedule album album_name } }",
+		"variables": map[string]int{"n": count},
+	}
+	var resp struct {
+		Data struct {
+			Transitions []Transition `json:"transitions"`
+		} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/graphql", req, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Errors) > 0 {
+		return nil, errors.New(resp.Errors[0].Message)
+	}
+	return resp.Data.Transitions, nil
+}
+
+// ListSchedules returns the schedule entries in evaluation order.
+func (c *Client) ListSchedules(ctx context.Context) (*Schedules, error) {
+	var schedules Schedules
+	return &schedules, c.do(ctx, http.MethodGet, "/schedules", nil, &schedules)
+}
+
+// GetSchedule returns the schedule entry with the given name.
+func (c *Client) GetSchedule(ctx context.Context, name string) (*Schedule, error) {
+	var schedule Schedule
+	return &schedule, c.do(ctx, http.MethodGet, "/schedules/"+url.PathEscape(name), nil, &schedule)
+}
+
+// scheduleRequest is the body of schedule creates and updates.
+type scheduleRequest struct {
+	ScheduleEntry
+	Position *int `json:"position,omitempty"`
+}
+
+// CreateSchedule adds a schedule entry at position, or at the end when nil.
+func (c *Client) CreateSchedule(ctx context.Context, entry ScheduleEntry, position *int) (*Schedule, error) {
+	var schedule Schedule
+	return &schedule, c.do(ctx, http.MethodPost, "/schedules", scheduleRequest{entry, position}, &schedule)
+}
+
+// UpdateSchedule replaces the schedule entry with the given name, moving it
+// to position unless nil.
+func (c *Client) UpdateSchedule(ctx context.Context, name string, entry ScheduleEntry, position *int) (*Schedule, error) {
+	var schedule Schedule
+	return &schedule, c.do(ctx, http.MethodPut, "/schedules/"+url.PathEscape(name), scheduleRequest{entry, position}, &schedule)
+}
+
+// DeleteSchedule removes the schedule entry with the given name.
+func (c *Client) DeleteSchedule(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, "/schedules/"+url.PathEscape(name), nil, nil)
+}
+
+// Party returns the party modes and the running one.
+func (c *Client) Party(ctx context.Context) (*Party, error) {
+	var party Party
+	return &party, c.do(ctx, http.MethodGet, "/party", nil, &party)
+}
+
+// StartParty overrides the schedule with a party mode for d, or its
+// configured duration when zero.
+func (c *Client) StartParty(ctx context.Context, name string, d time.Duration) (*Override, error) {
+	body := map[string]string{}
+	if d > 0 {
+		body["duration"] = d.String()
+	}
+	var override Override
+	return &override, c.do(ctx, http.MethodPost, "/party/"+url.PathEscape(name), body, &override)
+}
+
+// StopParty ends the running party mode.
+func (c *Client) StopParty(ctx context.Context) error {
+	return c.do(ctx, http.MethodDelete, "/party", nil, nil)
+}
+
+// Override shows album, a control album name or an album alias, for d, or
+// the album's configured duration when zero. It replaces any running
+// override or party mode.
+func (c *Client) Override(ctx context.Context, album string, d time.Duration) (*Override, error) {
+	body := map[string]string{"album": album}
+	if d > 0 {
+		body["duration"] = d.String()
+	}
+	var override Override
+	return &override, c.do(ctx, http.MethodPost, "/override", body, &override)
+}
+
+// ClearOverride ends the running override or party mode.
+func (c *Client) ClearOverride(ctx context.Context) error {
+	return c.do(ctx, http.MethodDelete, "/override", nil, nil)
+}
+
+// Freeze keeps showing the current album, ignoring schedule changes until
+// Unfreeze is called.
+func (c *Client) Freeze(ctx context.Context) (*Override, error) {
+	var override Override
+	return &override, c.do(ctx, http.MethodPost, "/freeze", nil, &override)
+}
+
+// Unfreeze releases a freeze, returning to the schedule.
+func (c *Client) Unfreeze(ctx context.Context) error {
+	return c.do(ctx, http.MethodDelete, "/freeze", nil, nil)
+}
+
+// Maintenance returns the maintenance mode state.
+func (c *Client) Maintenance(ctx context.Context) (*Maintenance, error) {
+	var m Maintenance
+	return &m, c.do(ctx, http.MethodGet, "/maintenance", nil, &m)
+}
+
+// SetMaintenance enables or disables maintenance mode.
+func (c *Client) SetMaintenance(ctx context.Context, enabled bool) (*Maintenance, error) {
+	var m Maintenance
+	return &m, c.do(ctx, http.MethodPost, "/maintenance", map[string]bool{"enabled": enabled}, &m)
+}
+
+// ListDevices returns the registered devices ordered by name.
+func (c *Client) ListDevices(ctx context.Context) ([]Device, error) {
+	var resp struct {
+		Devices []Device `json:"devices"`
+	}
+	return resp.Devices, c.do(ctx, http.MethodGet, "/devices", nil, &resp)
+}
+
+// RegisterDevice registers a device, or updates it when the ID exists.
+func (c *Client) RegisterDevice(ctx context.Context, device DeviceRegistration) (*Device, error) {
+	var registered Device
+	return &registered, c.do(ctx, http.MethodPost, "/devices", device, &registered)
+}
+
+// RemoveDevice unregisters a device.
+func (c *Client) RemoveDevice(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/devices/"+url.PathEscape(id), nil, nil)
+}
+
+// Stats returns the statistics of the last days, or the server's default
+// period when days is zero.
+func (c *Client) Stats(ctx context.Context, days int) (*Stats, error) {
+	path := "/stats"
+	if days > 0 {
+		path += "?days=" + strconv.Itoa(days)
+	}
+	var stats Stats
+	return &stats, c.do(ctx, http.MethodGet, path, nil, &stats)
+}
+
+// do sends a request to the API, encoding body and decoding the response
+// into out when they are not nil.
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("encoding request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+"/api/v1"+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var e struct {
+			Error string `json:"error"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		if json.Unmarshal(data, &e) != nil || e.Error == "" {
+			e.Error = strings.TrimSpace(string(data))
+		}
+		return &Error{StatusCode: resp.StatusCode, Message: e.Error}
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decoding %s %s response: %w", method, path, err)
+	}
+	return nil
+}
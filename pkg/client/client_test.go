@@ -0,0 +1,180 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/config"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/scheduler"
+	"github.com/sharkusmanch/immich-kiosk-scheduler/internal/server"
+)
+
+const testToken = "test-token-0123456789abcdef"
+
+// newTestAPI runs the scheduler's own handler, so the client is checked
+// against the real API.
+func newTestAPI(t *testing.T) *httptest.Server {
+	t.Helper()
+	cfg := &config.Config{
+		KioskURL:          "https://kiosk.example.com",
+		DefaultAlbum:      "default-album-id",
+		Port:              8080,
+		PassthroughParams: []string{},
+		Schedule: []config.ScheduleEntry{
+			{Name: "christmas", Album: "christmas-album", Start: "11-15", End: "01-01"},
+		},
+		APITokens:  []config.APIToken{{Name: "automation", Token: testToken}},
+		PartyModes: []config.PartyMode{{Name: "dinner", Album: "party-album"}},
+		Control:    config.ControlConfig{Albums: []config.ControlAlbum{{Name: "Garden", Album: "garden-album"}}},
+	}
+	sched, err := scheduler.New(cfg)
+	require.NoError(t, err)
+	srv, err := server.New(cfg, sched)
+	require.NoError(t, err)
+
+	ts := httptest.NewServer(srv.Handler())
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestClient_Schedules(t *testing.T) {
+	ts := newTestAPI(t)
+	c := New(ts.URL+"/", testToken)
+	ctx := context.Background()
+
+	status, err := c.Status(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "default-album-id", status.DefaultAlbum)
+	assert.Equal(t, 1, status.ScheduleCount)
+
+	first := 0
+	created, err := c.CreateSchedule(ctx, ScheduleEntry{Name: "summer", Album: "summer-album", Start: "06-01", End: "09-01"}, &first)
+	require.NoError(t, err)
+	assert.Equal(t, 0, created.Position)
+	assert.Equal(t, "summer-album", created.Schedule.Album)
+
+	schedules, err := c.ListSchedules(ctx)
+	require.NoError(t, err)
+	require.Len(t, schedules.Schedules, 2)
+	assert.Equal(t, "summer", schedules.Schedules[0].Name)
+
+	updated, err := c.UpdateSchedule(ctx, "summer", ScheduleEntry{Name: "summer", Album: "beach-album", Start: "06-01", End: "09-01"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "beach-album", updated.Schedule.Album)
+
+	require.NoError(t, c.DeleteSchedule(ctx, "summer"))
+	_, err = c.GetSchedule(ctx, "summer")
+	require.Error(t, err)
+	assert.True(t, IsNotFound(err), err)
+}
+
+func TestClient_Party(t *testing.T) {
+	ts := newTestAPI(t)
+	c := New(ts.URL, testToken)
+	ctx := context.Background()
+
+	override, err := c.StartParty(ctx, "dinner", 90*time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, "party-album", override.Album)
+	assert.WithinDuration(t, time.Now().Add(90*time.Minute), override.Until, time.Minute)
+
+	status, err := c.Status(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, status.Override)
+	assert.Equal(t, "dinner", status.Override.Name)
+
+	require.NoError(t, c.StopParty(ctx))
+	party, err := c.Party(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dinner"}, party.Modes)
+	assert.Nil(t, party.Active)
+}
+
+func TestClient_Freeze(t *testing.T) {
+	ts := newTestAPI(t)
+	c := New(ts.URL, testToken)
+	ctx := context.Background()
+
+	before, err := c.Status(ctx)
+	require.NoError(t, err)
+
+	frozen, err := c.Freeze(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "freeze", frozen.Mode)
+	assert.Equal(t, before.Album, frozen.Album)
+	assert.True(t, frozen.Until.IsZero())
+
+	status, err := c.Status(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, status.Override)
+	assert.Equal(t, before.Schedule, status.Schedule)
+
+	require.NoError(t, c.Unfreeze(ctx))
+	status, err = c.Status(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, status.Override)
+}
+
+func TestClient_Override(t *testing.T) {
+	ts := newTestAPI(t)
+	c := New(ts.URL, testToken)
+	ctx := context.Background()
+
+	override, err := c.Override(ctx, "garden", 0)
+	require.NoError(t, err)
+	assert.Equal(t, "Garden", override.Name)
+	assert.Equal(t, "garden-album", override.Album)
+
+	require.NoError(t, c.ClearOverride(ctx))
+	status, err := c.Status(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, status.Override)
+
+	transitions, err := c.Transitions(ctx, 2)
+	require.NoError(t, err)
+	require.Len(t, transitions, 2)
+	assert.True(t, transitions[0].At.After(time.Now()))
+	assert.True(t, transitions[1].At.After(transitions[0].At))
+
+	_, err = c.Transitions(ctx, 0)
+	assert.EqualError(t, err, "count must be between 1 and 50")
+}
+
+func TestClient_Devices(t *testing.T) {
+	ts := newTestAPI(t)
+	c := New(ts.URL, testToken)
+	ctx := context.Background()
+
+	device, err := c.RegisterDevice(ctx, DeviceRegistration{ID: "hallway", Name: "Hallway"})
+	require.NoError(t, err)
+	assert.Equal(t, "Hallway", device.Name)
+
+	devices, err := c.ListDevices(ctx)
+	require.NoError(t, err)
+	require.Len(t, devices, 1)
+	assert.Equal(t, "hallway", devices[0].ID)
+
+	require.NoError(t, c.RemoveDevice(ctx, "hallway"))
+	assert.True(t, IsNotFound(c.RemoveDevice(ctx, "hallway")))
+}
+
+func TestClient_Errors(t *testing.T) {
+	ts := newTestAPI(t)
+	ctx := context.Background()
+
+	_, err := New(ts.URL, "").SetMaintenance(ctx, true)
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+
+	_, err = New(ts.URL, testToken).StartParty(ctx, "disco", 0)
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+	assert.Contains(t, apiErr.Message, "disco")
+}